@@ -39,7 +39,7 @@ func (c *Client) Start(_ context.Context) error {
 		"address", c.serverAddress,
 	)
 
-	c.restyClient = restylib.New()
+	c.restyClient = restylib.New().SetBaseURL(c.serverAddress)
 
 	c.log.Info("Start Client is successful")
 
@@ -0,0 +1,253 @@
+// Package resty предоставляет функционал для работы с клиентом на основе github.com/go-resty/resty/v2.
+package resty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/common/repeater"
+)
+
+// Параметры возобновляемой загрузки содержимого.
+const (
+	// UploadChunkSize - размер части содержимого по умолчанию.
+	UploadChunkSize = 4 << 20
+
+	// UploadChunkTimeout - время на передачу одной части.
+	UploadChunkTimeout = 2 * time.Minute
+
+	headerUploadOffset = "Upload-Offset" // смещение части и количество принятых сервером байт
+)
+
+// ErrUploadInterrupted указывает на то, что все попытки передать часть содержимого исчерпаны.
+var ErrUploadInterrupted = errors.New("upload interrupted")
+
+// StatusError описывает ответ сервера с неожиданным HTTP-кодом.
+type StatusError struct {
+	Code int
+}
+
+// Error возвращает текст ошибки.
+func (e *StatusError) Error() string {
+	return "unexpected response status " + strconv.Itoa(e.Code)
+}
+
+// ContentUpload описывает содержимое бинарной записи для загрузки.
+type ContentUpload struct {
+	Src       io.ReaderAt // источник содержимого
+	Token     string      // токен авторизации
+	ItemID    string      // идентификатор записи
+	Hash      string      // SHA-256 содержимого (пусто - без проверки)
+	Size      int64       // размер содержимого
+	Version   int64       // текущая версия записи (0 - без проверки)
+	ChunkSize int64       // размер части (0 - UploadChunkSize)
+}
+
+// uploadSession - сессия загрузки из ответа сервера.
+type uploadSession struct {
+	ID     string `json:"id"`
+	Offset int64  `json:"offset"`
+}
+
+// uploadedItem - запись из ответа сервера после завершения загрузки.
+type uploadedItem struct {
+	Version int64 `json:"version"`
+}
+
+// UploadContent загружает содержимое бинарной записи частями.
+//
+// Каждая часть передаётся с повторами через repeater. После неудачной попытки
+// количество принятых сервером байт запрашивается заново, поэтому после обрыва
+// соединения передача продолжается с места остановки. Возвращает новую версию записи.
+func (c *Client) UploadContent(ctx context.Context, upload *ContentUpload) (int64, error) {
+	chunkSize := upload.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = UploadChunkSize
+	}
+
+	var session uploadSession
+
+	resp, err := c.restyClient.R().
+		SetContext(ctx).
+		SetAuthToken(upload.Token).
+		SetBody(map[string]any{"size": upload.Size, "hash": upload.Hash}).
+		SetResult(&session).
+		Post("/vault/items/" + upload.ItemID + "/uploads")
+	if err != nil {
+		return 0, fmt.Errorf("create upload: %w", err)
+	}
+
+	if resp.StatusCode() != http.StatusCreated {
+		return 0, fmt.Errorf("create upload: %w", &StatusError{Code: resp.StatusCode()})
+	}
+
+	c.log.Info("Upload created", "item", upload.ItemID, "upload", session.ID, "size", upload.Size)
+
+	offset := session.Offset
+
+	for offset < upload.Size {
+		end := min(offset+chunkSize, upload.Size)
+
+		offset, err = c.sendChunk(ctx, upload, session.ID, offset, end)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var item uploadedItem
+
+	req := c.restyClient.R().
+		SetContext(ctx).
+		SetAuthToken(upload.Token).
+		SetResult(&item)
+
+	if upload.Version > 0 {
+		req.SetQueryParam("version", strconv.FormatInt(upload.Version, 10))
+	}
+
+	resp, err = req.Post("/vault/uploads/" + session.ID + "/complete")
+	if err != nil {
+		return 0, fmt.Errorf("complete upload: %w", err)
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return 0, fmt.Errorf("complete upload: %w", &StatusError{Code: resp.StatusCode()})
+	}
+
+	c.log.Info("Upload completed", "item", upload.ItemID, "upload", session.ID, "version", item.Version)
+
+	return item.Version, nil
+}
+
+// sendChunk передаёт часть содержимого [offset, end) с повторами.
+// Возвращает количество принятых сервером байт.
+func (c *Client) sendChunk(
+	ctx context.Context,
+	upload *ContentUpload,
+	uploadID string,
+	offset, end int64,
+) (int64, error) {
+	stale := false
+
+	rep := repeater.New[int64, int64]().
+		SetFunc(func(ctx context.Context, end int64) (int64, error) {
+			if stale {
+				current, err := c.uploadOffset(ctx, upload.Token, uploadID)
+				if err != nil {
+					return 0, err
+				}
+
+				offset, stale = current, false
+			}
+
+			if offset >= end {
+				// Часть уже принята, но ответ о ней потерялся.
+				return offset, nil
+			}
+
+			next, err := c.patchChunk(ctx, upload, uploadID, offset, end)
+			if err != nil {
+				stale = true
+
+				return 0, err
+			}
+
+			return next, nil
+		}).
+		SetCondition(func(err error) bool { return err == nil || !retryable(err) }).
+		SetDurationLimit(UploadChunkTimeout, 0)
+
+	doneCh, retryCh := rep.Run(ctx, end)
+
+	done := <-doneCh
+
+	for event := range retryCh {
+		c.log.Warn("Upload chunk retry", event.Err, "upload", uploadID, "attempt", event.Attempt)
+	}
+
+	if errors.Is(done.Err, repeater.ErrAttemptsOver) {
+		return 0, fmt.Errorf("%w: at offset %d", ErrUploadInterrupted, offset)
+	}
+
+	if done.Err != nil {
+		return 0, fmt.Errorf("upload chunk: %w", done.Err)
+	}
+
+	return done.Result, nil
+}
+
+// patchChunk отправляет одну часть содержимого.
+func (c *Client) patchChunk(
+	ctx context.Context,
+	upload *ContentUpload,
+	uploadID string,
+	offset, end int64,
+) (int64, error) {
+	chunk := make([]byte, end-offset)
+
+	if _, err := upload.Src.ReadAt(chunk, offset); err != nil && !errors.Is(err, io.EOF) {
+		return 0, fmt.Errorf("read content: %w", err)
+	}
+
+	resp, err := c.restyClient.R().
+		SetContext(ctx).
+		SetAuthToken(upload.Token).
+		SetHeader("Content-Type", "application/offset+octet-stream").
+		SetHeader(headerUploadOffset, strconv.FormatInt(offset, 10)).
+		SetBody(chunk).
+		Patch("/vault/uploads/" + uploadID)
+	if err != nil {
+		return 0, fmt.Errorf("patch upload: %w", err)
+	}
+
+	if resp.StatusCode() != http.StatusNoContent {
+		return 0, &StatusError{Code: resp.StatusCode()}
+	}
+
+	return parseOffset(resp.Header().Get(headerUploadOffset))
+}
+
+// uploadOffset запрашивает количество принятых сервером байт.
+func (c *Client) uploadOffset(ctx context.Context, token, uploadID string) (int64, error) {
+	resp, err := c.restyClient.R().
+		SetContext(ctx).
+		SetAuthToken(token).
+		Head("/vault/uploads/" + uploadID)
+	if err != nil {
+		return 0, fmt.Errorf("head upload: %w", err)
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		return 0, &StatusError{Code: resp.StatusCode()}
+	}
+
+	return parseOffset(resp.Header().Get(headerUploadOffset))
+}
+
+// parseOffset разбирает количество принятых сервером байт из заголовка ответа.
+func parseOffset(value string) (int64, error) {
+	offset, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse %s: %w", headerUploadOffset, err)
+	}
+
+	return offset, nil
+}
+
+// retryable проверяет, имеет ли смысл повторить попытку после ошибки:
+// повторяются сетевые ошибки, ошибки сервера и расхождение смещения.
+func retryable(err error) bool {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return true
+	}
+
+	return statusErr.Code >= http.StatusInternalServerError ||
+		statusErr.Code == http.StatusConflict ||
+		statusErr.Code == http.StatusTooManyRequests
+}
@@ -0,0 +1,105 @@
+package resty_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/client/client/http/resty"
+	"github.com/mr-filatik/go-goph-keeper/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyUploadServer - сервер загрузки, который при одном из запросов частей
+// принимает только половину части и отвечает ошибкой, как при обрыве соединения.
+type flakyUploadServer struct {
+	received  bytes.Buffer
+	failPatch int // номер запроса части, который завершается ошибкой
+	patches   int
+	version   string
+	mu        sync.Mutex
+}
+
+func (s *flakyUploadServer) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	offset := strconv.Itoa(s.received.Len())
+
+	switch {
+	case req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/uploads"):
+		resp.Header().Set("Content-Type", "application/json")
+		resp.WriteHeader(http.StatusCreated)
+		_, _ = resp.Write([]byte(`{"id":"u1","offset":0}`))
+	case !strings.HasPrefix(req.URL.Path, "/vault/uploads/u1") && req.Method != http.MethodPost:
+		resp.WriteHeader(http.StatusNotFound)
+	case req.Method == http.MethodHead:
+		resp.Header().Set("Upload-Offset", offset)
+	case req.Method == http.MethodPatch:
+		s.patches++
+
+		body, _ := io.ReadAll(req.Body)
+
+		if req.Header.Get("Upload-Offset") != offset {
+			resp.Header().Set("Upload-Offset", offset)
+			resp.WriteHeader(http.StatusConflict)
+
+			return
+		}
+
+		if s.patches == s.failPatch {
+			s.received.Write(body[:len(body)/2])
+			resp.WriteHeader(http.StatusBadGateway)
+
+			return
+		}
+
+		s.received.Write(body)
+		resp.Header().Set("Upload-Offset", strconv.Itoa(s.received.Len()))
+		resp.WriteHeader(http.StatusNoContent)
+	case req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/complete"):
+		s.version = req.URL.Query().Get("version")
+		resp.Header().Set("Content-Type", "application/json")
+		_, _ = resp.Write([]byte(`{"version":3}`))
+	default:
+		resp.WriteHeader(http.StatusNotFound)
+	}
+}
+
+/*
+	===== Client.UploadContent =====
+*/
+
+func TestClient_UploadContent_Resume(t *testing.T) {
+	t.Parallel()
+
+	fake := &flakyUploadServer{failPatch: 2} //nolint:exhaustruct // остальные поля заполняются сервером
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client := resty.NewClient(&resty.ClientConfig{ServerAddress: server.URL}, testutil.NewMockLogger())
+	require.NoError(t, client.Start(context.Background()))
+
+	data := strings.Repeat("0123456789", 5)
+
+	version, err := client.UploadContent(context.Background(), &resty.ContentUpload{
+		Src:       strings.NewReader(data),
+		Token:     "token",
+		ItemID:    "item-1",
+		Hash:      "",
+		Size:      int64(len(data)),
+		Version:   2,
+		ChunkSize: 16,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), version)
+	assert.Equal(t, data, fake.received.String(), "content is resumed from the accepted offset")
+	assert.Equal(t, "2", fake.version)
+}
@@ -0,0 +1,169 @@
+// Package server предоставляет функционал для запуска приложения сервера.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/common/logger"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/backup"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/config"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+)
+
+// Подкоманды резервного копирования.
+const (
+	commandBackup  = "backup"
+	commandRestore = "restore"
+
+	// backupFileMode - права доступа к файлу архива.
+	backupFileMode = 0o600
+)
+
+var (
+	errBackupPathNotSet = errors.New("backup file is not set")
+	errPersistentNotSet = errors.New("database connection string or storage file is not set")
+)
+
+// runBackupCommand выполняет подкоманду `server backup <file> [flags]`: сохраняет
+// согласованный снимок хранилища из конфигурации в файл архива.
+//
+// Архив шифруется паролем BackupPassphrase, если он задан.
+//
+// Параметры:
+//   - ctx: контекст выполнения;
+//   - args: аргументы после названия подкоманды;
+//   - log: логгер.
+func runBackupCommand(ctx context.Context, args []string, log logger.Logger) error {
+	path, appConfig, err := parseBackupArgs(args)
+	if err != nil {
+		return err
+	}
+
+	stor, err := createStorage(ctx, appConfig, log)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = stor.Close() }()
+
+	snap, err := stor.Snapshot(ctx)
+	if err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+
+	if err := writeBackupFile(path, snap, appConfig.BackupPassphrase); err != nil {
+		return err
+	}
+
+	log.Info("Backup created", append([]any{"path", path}, snapshotStats(snap)...)...)
+
+	return nil
+}
+
+// runRestoreCommand выполняет подкоманду `server restore <file> [flags]`: загружает
+// файл архива в пустое хранилище из конфигурации.
+//
+// Хранилище может быть другого вида, чем то, из которого создан архив.
+// Данные записей остаются зашифрованными прежним мастер-ключом, поэтому
+// сервер с восстановленными данными запускается с тем же HashKey (или UnsealCheck).
+//
+// Параметры:
+//   - ctx: контекст выполнения;
+//   - args: аргументы после названия подкоманды;
+//   - log: логгер.
+func runRestoreCommand(ctx context.Context, args []string, log logger.Logger) error {
+	path, appConfig, err := parseBackupArgs(args)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+
+	defer func() { _ = file.Close() }()
+
+	snap, header, err := backup.Read(file, appConfig.BackupPassphrase)
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+
+	stor, err := createStorage(ctx, appConfig, log)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = stor.Close() }()
+
+	if err := stor.Restore(ctx, snap); err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+
+	log.Info("Backup restored",
+		append([]any{"path", path, "created", header.CreatedAt}, snapshotStats(snap)...)...)
+
+	return nil
+}
+
+// parseBackupArgs разбирает путь архива и конфигурацию хранилища.
+// Хранилище в памяти не подходит: его данные не переживают процесс.
+func parseBackupArgs(args []string) (string, *config.Config, error) {
+	if len(args) == 0 || args[0] == "" {
+		return "", nil, errBackupPathNotSet
+	}
+
+	appConfig := config.InitializeFromArgs(args[1:])
+
+	if appConfig.Database == "" && appConfig.StorageFile == "" {
+		return "", nil, errPersistentNotSet
+	}
+
+	return args[0], appConfig, nil
+}
+
+// writeBackupFile записывает архив во временный файл рядом с path
+// и переименовывает его, чтобы по пути path не остался неполный архив.
+func writeBackupFile(path string, snap *entity.Snapshot, passphrase string) error {
+	tmpPath := path + ".tmp"
+
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, backupFileMode)
+	if err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+
+	_, err = backup.Write(file, snap, passphrase)
+	if err == nil {
+		err = file.Sync()
+	}
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+
+	if err != nil {
+		_ = os.Remove(tmpPath)
+
+		return fmt.Errorf("backup: %w", err)
+	}
+
+	return nil
+}
+
+// snapshotStats возвращает количество данных снимка для журнала.
+func snapshotStats(snap *entity.Snapshot) []any {
+	items := 0
+
+	for _, vault := range snap.Vaults {
+		items += len(vault.Items)
+	}
+
+	return []any{"users", len(snap.Users), "vaults", len(snap.Vaults), "items", items}
+}
@@ -0,0 +1,285 @@
+// Package backup предоставляет формат архива резервной копии данных сервера.
+//
+// Архив начинается строкой заголовка в формате JSON (см. Header), за которой
+// следует снимок хранилища в формате JSON, сжатый gzip. Если задан пароль,
+// сжатый снимок шифруется AES-256-GCM ключом, полученным из пароля функцией
+// scrypt, а заголовок защищается от подмены как дополнительные данные шифра.
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// FormatName - название формата архива в заголовке.
+	FormatName = "goph-keeper-backup"
+
+	// FormatVersion - версия формата архива, создаваемого Write.
+	FormatVersion = 1
+
+	// EncryptionScrypt - шифрование AES-256-GCM ключом из пароля (scrypt).
+	EncryptionScrypt = "scrypt-aes256gcm"
+
+	// maxHeaderSize - наибольший размер строки заголовка.
+	maxHeaderSize = 4096
+
+	// Параметры scrypt для получения ключа из пароля.
+	scryptN       = 1 << 15
+	scryptR       = 8
+	scryptP       = 1
+	scryptKeySize = 32
+	scryptSalt    = 16
+)
+
+// Возможные ошибки при чтении архива.
+var (
+	ErrInvalidArchive     = errors.New("invalid backup archive")
+	ErrUnsupportedVersion = errors.New("unsupported backup version")
+	ErrPassphraseRequired = errors.New("backup is encrypted, passphrase required")
+	ErrWrongPassphrase    = errors.New("wrong backup passphrase or corrupted archive")
+)
+
+// Header описывает заголовок архива.
+type Header struct {
+	CreatedAt  time.Time `json:"createdAt"`
+	Format     string    `json:"format"`               // FormatName
+	Encryption string    `json:"encryption,omitempty"` // способ шифрования (пусто - без шифрования)
+	Salt       []byte    `json:"salt,omitempty"`       // соль scrypt
+	Nonce      []byte    `json:"nonce,omitempty"`      // nonce AES-GCM
+	Version    int       `json:"version"`              // версия формата
+}
+
+// Write записывает снимок хранилища в архив.
+//
+// Параметры:
+//   - dst: вывод архива;
+//   - snap: снимок хранилища;
+//   - passphrase: пароль шифрования (пусто - архив не шифруется).
+//
+// Возвращает заголовок записанного архива.
+func Write(dst io.Writer, snap *entity.Snapshot, passphrase string) (*Header, error) {
+	header := &Header{
+		CreatedAt:  time.Now().UTC(),
+		Format:     FormatName,
+		Encryption: "",
+		Salt:       nil,
+		Nonce:      nil,
+		Version:    FormatVersion,
+	}
+
+	if passphrase == "" {
+		if err := writeHeader(dst, header); err != nil {
+			return nil, err
+		}
+
+		if err := writeBody(dst, snap); err != nil {
+			return nil, err
+		}
+
+		return header, nil
+	}
+
+	var body bytes.Buffer
+
+	if err := writeBody(&body, snap); err != nil {
+		return nil, err
+	}
+
+	header.Encryption = EncryptionScrypt
+	header.Salt = make([]byte, scryptSalt)
+
+	if _, err := rand.Read(header.Salt); err != nil {
+		return nil, fmt.Errorf("generate salt: %w", err)
+	}
+
+	aead, err := newCipher(passphrase, header.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	header.Nonce = make([]byte, aead.NonceSize())
+
+	if _, err := rand.Read(header.Nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+
+	line, err := headerLine(header)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := dst.Write(line); err != nil {
+		return nil, fmt.Errorf("write header: %w", err)
+	}
+
+	if _, err := dst.Write(aead.Seal(nil, header.Nonce, body.Bytes(), line)); err != nil {
+		return nil, fmt.Errorf("write body: %w", err)
+	}
+
+	return header, nil
+}
+
+// Read читает снимок хранилища из архива.
+//
+// Параметры:
+//   - src: архив;
+//   - passphrase: пароль шифрования (для незашифрованного архива не используется).
+func Read(src io.Reader, passphrase string) (*entity.Snapshot, *Header, error) {
+	reader := bufio.NewReaderSize(src, maxHeaderSize)
+
+	line, err := reader.ReadSlice('\n')
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: header: %w", ErrInvalidArchive, err)
+	}
+
+	line = append([]byte(nil), line...)
+
+	//nolint:exhaustruct // поля заполняются при чтении
+	header := &Header{}
+	if err := json.Unmarshal(line, header); err != nil || header.Format != FormatName {
+		return nil, nil, fmt.Errorf("%w: unknown header", ErrInvalidArchive)
+	}
+
+	if header.Version != FormatVersion {
+		return nil, nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, header.Version)
+	}
+
+	var body io.Reader = reader
+
+	switch header.Encryption {
+	case "":
+	case EncryptionScrypt:
+		plain, err := openBody(reader, header, line, passphrase)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		body = bytes.NewReader(plain)
+	default:
+		return nil, nil, fmt.Errorf("%w: encryption %q", ErrUnsupportedVersion, header.Encryption)
+	}
+
+	snap, err := readBody(body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return snap, header, nil
+}
+
+// openBody расшифровывает сжатый снимок зашифрованного архива.
+func openBody(src io.Reader, header *Header, line []byte, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, ErrPassphraseRequired
+	}
+
+	aead, err := newCipher(passphrase, header.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(header.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("%w: nonce", ErrInvalidArchive)
+	}
+
+	sealed, err := io.ReadAll(src)
+	if err != nil {
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+
+	plain, err := aead.Open(nil, header.Nonce, sealed, line)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+
+	return plain, nil
+}
+
+// newCipher создаёт шифр AES-256-GCM с ключом, полученным из пароля.
+func newCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, scryptKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("derive key: %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("aes: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("gcm: %w", err)
+	}
+
+	return aead, nil
+}
+
+// headerLine кодирует заголовок в строку архива.
+func headerLine(header *Header) ([]byte, error) {
+	line, err := json.Marshal(header)
+	if err != nil {
+		return nil, fmt.Errorf("marshal header: %w", err)
+	}
+
+	return append(line, '\n'), nil
+}
+
+// writeHeader записывает строку заголовка.
+func writeHeader(dst io.Writer, header *Header) error {
+	line, err := headerLine(header)
+	if err != nil {
+		return err
+	}
+
+	if _, err := dst.Write(line); err != nil {
+		return fmt.Errorf("write header: %w", err)
+	}
+
+	return nil
+}
+
+// writeBody записывает снимок в формате JSON, сжатый gzip.
+func writeBody(dst io.Writer, snap *entity.Snapshot) error {
+	zipped := gzip.NewWriter(dst)
+
+	if err := json.NewEncoder(zipped).Encode(snap); err != nil {
+		return fmt.Errorf("write body: %w", err)
+	}
+
+	if err := zipped.Close(); err != nil {
+		return fmt.Errorf("write body: %w", err)
+	}
+
+	return nil
+}
+
+// readBody читает снимок в формате JSON, сжатый gzip.
+func readBody(src io.Reader) (*entity.Snapshot, error) {
+	zipped, err := gzip.NewReader(src)
+	if err != nil {
+		return nil, fmt.Errorf("%w: body: %w", ErrInvalidArchive, err)
+	}
+	defer func() { _ = zipped.Close() }()
+
+	//nolint:exhaustruct // поля заполняются при чтении
+	snap := &entity.Snapshot{}
+	if err := json.NewDecoder(zipped).Decode(snap); err != nil {
+		return nil, fmt.Errorf("%w: body: %w", ErrInvalidArchive, err)
+	}
+
+	return snap, nil
+}
@@ -0,0 +1,106 @@
+package backup_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/backup"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSnapshot() *entity.Snapshot {
+	updatedAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	return &entity.Snapshot{
+		Users:  []*entity.User{{ID: "u1", Email: "user@example.com", PasswordHash: "hash"}},
+		Tokens: []string{"u1"},
+		Keys:   []*entity.DataKey{},
+		Vaults: []*entity.VaultSnapshot{{
+			OwnerID: "u1",
+			Seq:     3,
+			Items: []*entity.VaultItem{{
+				ID:          "i1",
+				OwnerID:     "",
+				Type:        entity.ItemLogin,
+				Title:       "mail",
+				Description: "",
+				Meta:        map[string]string{"url": "https://mail.example.com"},
+				Username:    "user",
+				Data:        "secret",
+				Content:     nil,
+				FolderID:    "",
+				Version:     2,
+				UpdatedAt:   updatedAt,
+				Seq:         3,
+				Deleted:     false,
+			}},
+			Tombstones: []*entity.Tombstone{},
+			Revisions:  []*entity.Revision{},
+			Trash:      []*entity.TrashItem{},
+			Folders:    []*entity.Folder{},
+		}},
+	}
+}
+
+/*
+	===== Write / Read =====
+*/
+
+func TestWriteRead(t *testing.T) {
+	t.Parallel()
+
+	for _, passphrase := range []string{"", "correct horse battery staple"} {
+		var archive bytes.Buffer
+
+		written, err := backup.Write(&archive, testSnapshot(), passphrase)
+		require.NoError(t, err)
+		assert.Equal(t, passphrase != "", written.Encryption != "")
+		assert.NotContains(t, archive.String(), `"secret"`, "body is compressed")
+
+		snap, header, err := backup.Read(&archive, passphrase)
+		require.NoError(t, err)
+		assert.Equal(t, backup.FormatVersion, header.Version)
+		assert.Equal(t, written.CreatedAt, header.CreatedAt)
+		assert.Equal(t, testSnapshot(), snap)
+	}
+}
+
+func TestRead_Encrypted(t *testing.T) {
+	t.Parallel()
+
+	var archive bytes.Buffer
+
+	_, err := backup.Write(&archive, testSnapshot(), "passphrase")
+	require.NoError(t, err)
+
+	_, _, err = backup.Read(bytes.NewReader(archive.Bytes()), "")
+	require.ErrorIs(t, err, backup.ErrPassphraseRequired)
+
+	_, _, err = backup.Read(bytes.NewReader(archive.Bytes()), "other")
+	require.ErrorIs(t, err, backup.ErrWrongPassphrase)
+
+	// Заголовок защищён шифром, поэтому его подмена обнаруживается.
+	tampered := bytes.Replace(archive.Bytes(), []byte(`"createdAt":"2`), []byte(`"createdAt":"1`), 1)
+	_, _, err = backup.Read(bytes.NewReader(tampered), "passphrase")
+	require.ErrorIs(t, err, backup.ErrWrongPassphrase)
+}
+
+func TestRead_Invalid(t *testing.T) {
+	t.Parallel()
+
+	_, _, err := backup.Read(strings.NewReader("not an archive"), "")
+	require.ErrorIs(t, err, backup.ErrInvalidArchive)
+
+	_, _, err = backup.Read(strings.NewReader(`{"format":"other","version":1}`+"\n"), "")
+	require.ErrorIs(t, err, backup.ErrInvalidArchive)
+
+	_, _, err = backup.Read(strings.NewReader(`{"format":"goph-keeper-backup","version":99}`+"\n"), "")
+	require.ErrorIs(t, err, backup.ErrUnsupportedVersion)
+
+	_, _, err = backup.Read(strings.NewReader(`{"format":"goph-keeper-backup","version":1}`+"\nbody"), "")
+	require.ErrorIs(t, err, backup.ErrInvalidArchive)
+}
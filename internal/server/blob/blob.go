@@ -0,0 +1,73 @@
+// Package blob предоставляет хранилища содержимого бинарных записей.
+//
+// Содержимое адресуется SHA-256 хешем: одинаковое содержимое хранится один раз,
+// а целостность проверяется при сохранении.
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// HashSize - длина хеша содержимого в шестнадцатеричном виде.
+const HashSize = 64
+
+var (
+	// ErrNotFound указывает на то, что содержимое не найдено.
+	ErrNotFound = errors.New("blob not found")
+
+	// ErrTooLarge указывает на превышение допустимого размера содержимого.
+	ErrTooLarge = errors.New("blob too large")
+
+	// ErrHashMismatch указывает на расхождение хеша полученного содержимого с ожидаемым.
+	ErrHashMismatch = errors.New("blob hash mismatch")
+
+	// ErrInvalidHash указывает на некорректный хеш содержимого.
+	ErrInvalidHash = errors.New("invalid blob hash")
+)
+
+// Info описывает сохранённое содержимое.
+type Info struct {
+	Hash string // SHA-256 содержимого (hex, нижний регистр)
+	Size int64  // размер содержимого в байтах
+}
+
+// IBlobStore - интерфейс для всех хранилищ содержимого.
+type IBlobStore interface {
+	// Put сохраняет содержимое, читая src до конца.
+	//
+	// Параметры:
+	//   - maxSize: наибольший размер содержимого (0 - без ограничения),
+	//     при превышении возвращается ErrTooLarge;
+	//   - wantHash: ожидаемый хеш содержимого (пусто - без проверки),
+	//     при расхождении возвращается ErrHashMismatch.
+	//
+	// Содержимое становится доступным только после успешной проверки.
+	Put(ctx context.Context, src io.Reader, maxSize int64, wantHash string) (*Info, error)
+
+	// Open открывает содержимое для чтения. Если содержимого нет, возвращается ErrNotFound.
+	Open(ctx context.Context, hash string) (io.ReadCloser, *Info, error)
+
+	// Sweep удаляет содержимое, сохранённое раньше before, для которого keep возвращает false.
+	// Возвращает количество удалённых объектов.
+	//
+	// Повторное сохранение того же содержимого обновляет его время сохранения.
+	Sweep(ctx context.Context, before time.Time, keep func(hash string) bool) (int, error)
+}
+
+// ValidHash проверяет, что строка является хешем содержимого.
+func ValidHash(hash string) bool {
+	if len(hash) != HashSize {
+		return false
+	}
+
+	for _, char := range hash {
+		if (char < '0' || char > '9') && (char < 'a' || char > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,221 @@
+// Package blob предоставляет хранилища содержимого бинарных записей.
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	diskDirPerm = 0o700   // права каталогов хранилища
+	diskTmpDir  = "tmp"   // каталог незавершённых сохранений
+	diskTmpName = "put-*" // шаблон имени временного файла
+	diskFanout  = 2       // длина префикса хеша для имени подкаталога
+)
+
+// DiskStore описывает хранилище содержимого в каталоге на локальном диске.
+//
+// Содержимое с хешем h хранится в файле <dir>/<h[:2]>/<h>. Сохранение
+// выполняется во временный файл, который переименовывается после проверки,
+// поэтому частично записанное содержимое никогда не становится доступным.
+type DiskStore struct {
+	dir string
+}
+
+var _ IBlobStore = (*DiskStore)(nil)
+
+// NewDiskStore создаёт и инициализирует новый экзепляр *DiskStore.
+//
+// Параметры:
+//   - dir: каталог хранилища (создаётся при отсутствии).
+func NewDiskStore(dir string) (*DiskStore, error) {
+	if err := os.MkdirAll(filepath.Join(dir, diskTmpDir), diskDirPerm); err != nil {
+		return nil, fmt.Errorf("create blob dir: %w", err)
+	}
+
+	store := &DiskStore{
+		dir: dir,
+	}
+
+	return store, nil
+}
+
+// Put сохраняет содержимое на диск.
+func (d *DiskStore) Put(
+	ctx context.Context,
+	src io.Reader,
+	maxSize int64,
+	wantHash string,
+) (*Info, error) {
+	if wantHash != "" && !ValidHash(wantHash) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidHash, wantHash)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Join(d.dir, diskTmpDir), diskTmpName)
+	if err != nil {
+		return nil, fmt.Errorf("create temp blob: %w", err)
+	}
+
+	defer func() {
+		// После переименования файла удалять уже нечего.
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if maxSize > 0 {
+		// Читается на байт больше, чтобы отличить превышение размера.
+		src = io.LimitReader(src, maxSize+1)
+	}
+
+	hasher := sha256.New()
+
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), src)
+	if err != nil {
+		return nil, fmt.Errorf("write blob: %w", err)
+	}
+
+	if maxSize > 0 && size > maxSize {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, maxSize)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("write blob: %w", err)
+	}
+
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	if wantHash != "" && hash != wantHash {
+		return nil, fmt.Errorf("%w: got %s, want %s", ErrHashMismatch, hash, wantHash)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return nil, fmt.Errorf("sync blob: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("close blob: %w", err)
+	}
+
+	path := d.path(hash)
+
+	if err := os.MkdirAll(filepath.Dir(path), diskDirPerm); err != nil {
+		return nil, fmt.Errorf("create blob dir: %w", err)
+	}
+
+	// Переименование заменяет уже сохранённое одинаковое содержимое
+	// и обновляет время сохранения, которое учитывает Sweep.
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, fmt.Errorf("commit blob: %w", err)
+	}
+
+	info := &Info{
+		Hash: hash,
+		Size: size,
+	}
+
+	return info, nil
+}
+
+// Open открывает содержимое на диске для чтения.
+func (d *DiskStore) Open(_ context.Context, hash string) (io.ReadCloser, *Info, error) {
+	if !ValidHash(hash) {
+		return nil, nil, fmt.Errorf("%w: %q", ErrInvalidHash, hash)
+	}
+
+	file, err := os.Open(d.path(hash))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, fmt.Errorf("%w: %s", ErrNotFound, hash)
+		}
+
+		return nil, nil, fmt.Errorf("open blob: %w", err)
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+
+		return nil, nil, fmt.Errorf("stat blob: %w", err)
+	}
+
+	info := &Info{
+		Hash: hash,
+		Size: stat.Size(),
+	}
+
+	return file, info, nil
+}
+
+// Sweep удаляет с диска ненужное содержимое и брошенные временные файлы.
+func (d *DiskStore) Sweep(
+	ctx context.Context,
+	before time.Time,
+	keep func(hash string) bool,
+) (int, error) {
+	count := 0
+
+	err := filepath.WalkDir(d.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		if entry.IsDir() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return fmt.Errorf("stat %s: %w", path, err)
+		}
+
+		if !info.ModTime().Before(before) {
+			return nil
+		}
+
+		if filepath.Base(filepath.Dir(path)) == diskTmpDir {
+			return removeFile(path)
+		}
+
+		if !ValidHash(entry.Name()) || keep(entry.Name()) {
+			return nil
+		}
+
+		if err := removeFile(path); err != nil {
+			return err
+		}
+
+		count++
+
+		return nil
+	})
+	if err != nil {
+		return count, fmt.Errorf("sweep blobs: %w", err)
+	}
+
+	return count, nil
+}
+
+// path возвращает путь до файла содержимого.
+func (d *DiskStore) path(hash string) string {
+	return filepath.Join(d.dir, hash[:diskFanout], hash)
+}
+
+// removeFile удаляет файл, если он ещё существует.
+func removeFile(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package blob_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/blob"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+
+	return hex.EncodeToString(sum[:])
+}
+
+/*
+	===== DiskStore =====
+*/
+
+func TestDiskStore_PutOpen(t *testing.T) {
+	t.Parallel()
+
+	store, err := blob.NewDiskStore(t.TempDir())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	data := "binary content"
+
+	info, err := store.Put(ctx, strings.NewReader(data), 0, sha256Hex(data))
+	require.NoError(t, err)
+	assert.Equal(t, sha256Hex(data), info.Hash)
+	assert.Equal(t, int64(len(data)), info.Size)
+
+	again, err := store.Put(ctx, strings.NewReader(data), 0, "")
+	require.NoError(t, err)
+	assert.Equal(t, info, again, "same content is stored once")
+
+	reader, opened, err := store.Open(ctx, info.Hash)
+	require.NoError(t, err)
+
+	defer func() { _ = reader.Close() }()
+
+	got, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, data, string(got))
+	assert.Equal(t, info, opened)
+}
+
+func TestDiskStore_PutErrors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	store, err := blob.NewDiskStore(dir)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	_, err = store.Put(ctx, strings.NewReader("12345"), 4, "")
+	require.ErrorIs(t, err, blob.ErrTooLarge)
+
+	info, err := store.Put(ctx, strings.NewReader("1234"), 4, "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), info.Size)
+
+	_, err = store.Put(ctx, strings.NewReader("data"), 0, sha256Hex("other"))
+	require.ErrorIs(t, err, blob.ErrHashMismatch)
+
+	_, _, err = store.Open(ctx, sha256Hex("data"))
+	require.ErrorIs(t, err, blob.ErrNotFound, "rejected content must not be stored")
+
+	_, err = store.Put(ctx, strings.NewReader("data"), 0, "not-a-hash")
+	require.ErrorIs(t, err, blob.ErrInvalidHash)
+
+	_, _, err = store.Open(ctx, "../../etc/passwd")
+	require.ErrorIs(t, err, blob.ErrInvalidHash)
+
+	tmp, err := os.ReadDir(filepath.Join(dir, "tmp"))
+	require.NoError(t, err)
+	assert.Empty(t, tmp, "temporary files must be removed")
+}
+
+func TestDiskStore_Sweep(t *testing.T) {
+	t.Parallel()
+
+	store, err := blob.NewDiskStore(t.TempDir())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	kept, err := store.Put(ctx, strings.NewReader("kept"), 0, "")
+	require.NoError(t, err)
+	orphan, err := store.Put(ctx, strings.NewReader("orphan"), 0, "")
+	require.NoError(t, err)
+
+	keep := func(hash string) bool { return hash == kept.Hash }
+
+	count, err := store.Sweep(ctx, time.Now().Add(-time.Hour), keep)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count, "fresh content is not swept")
+
+	count, err = store.Sweep(ctx, time.Now().Add(time.Hour), keep)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	_, _, err = store.Open(ctx, orphan.Hash)
+	require.ErrorIs(t, err, blob.ErrNotFound)
+
+	reader, _, err := store.Open(ctx, kept.Hash)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+}
+
+func TestValidHash(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, blob.ValidHash(sha256Hex("data")))
+	assert.False(t, blob.ValidHash(strings.ToUpper(sha256Hex("data"))))
+	assert.False(t, blob.ValidHash("abc"))
+	assert.False(t, blob.ValidHash(""))
+}
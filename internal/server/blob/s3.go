@@ -0,0 +1,536 @@
+// Package blob предоставляет хранилища содержимого бинарных записей.
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// S3DefaultRegion - регион подписи запросов по умолчанию.
+	S3DefaultRegion = "us-east-1"
+
+	// S3MinPartSize - наименьший размер части составной загрузки (кроме последней).
+	S3MinPartSize = 5 << 20
+
+	// S3DefaultPartSize - размер части составной загрузки по умолчанию.
+	S3DefaultPartSize = 16 << 20
+
+	s3MaxCopySize = 5 << 30 // наибольший размер объекта для копирования одним запросом
+	s3TmpPrefix   = "tmp/"  // префикс ключей незавершённых сохранений
+	s3ListMaxKeys = "1000"  // размер страницы списка объектов
+)
+
+// ErrInvalidS3Config указывает на некорректные параметры подключения к S3.
+var ErrInvalidS3Config = errors.New("invalid s3 config")
+
+// S3Config - параметры подключения к S3-совместимому хранилищу.
+type S3Config struct {
+	Client    *http.Client // HTTP-клиент (nil - http.DefaultClient)
+	Endpoint  string       // адрес сервиса, например http://localhost:9000
+	Bucket    string       // корзина (должна существовать)
+	Region    string       // регион (пусто - S3DefaultRegion)
+	AccessKey string
+	SecretKey string
+	Prefix    string // префикс ключей объектов внутри корзины
+	PartSize  int64  // размер части составной загрузки (0 - S3DefaultPartSize)
+}
+
+// S3Store описывает хранилище содержимого в корзине S3-совместимого сервиса.
+//
+// Содержимое с хешем h хранится в объекте <prefix><h[:2]>/<h>. Адресация
+// корзины - по пути (path-style), что поддерживают и AWS S3, и MinIO.
+//
+// Содержимое больше размера части сохраняется составной загрузкой. Если хеш
+// содержимого известен заранее, части загружаются сразу под итоговым ключом
+// и загрузка завершается только после проверки хеша. Иначе содержимое
+// сохраняется под временным ключом и затем копируется под итоговый.
+//
+// Брошенные составные загрузки сервис не удаляет сам: для корзины стоит
+// настроить правило жизненного цикла AbortIncompleteMultipartUpload.
+type S3Store struct {
+	client    *http.Client
+	endpoint  *url.URL
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	prefix    string
+	partSize  int64
+}
+
+var _ IBlobStore = (*S3Store)(nil)
+
+// NewS3Store создаёт и инициализирует новый экзепляр *S3Store
+// и проверяет доступность корзины.
+//
+// Параметры:
+//   - ctx: контекст проверки корзины;
+//   - conf: параметры подключения.
+func NewS3Store(ctx context.Context, conf *S3Config) (*S3Store, error) {
+	endpoint, err := url.Parse(conf.Endpoint)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("%w: endpoint %q", ErrInvalidS3Config, conf.Endpoint)
+	}
+
+	if conf.Bucket == "" {
+		return nil, fmt.Errorf("%w: empty bucket", ErrInvalidS3Config)
+	}
+
+	store := &S3Store{
+		client:    conf.Client,
+		endpoint:  &url.URL{Scheme: endpoint.Scheme, Host: endpoint.Host}, //nolint:exhaustruct // только адрес сервиса
+		bucket:    conf.Bucket,
+		region:    conf.Region,
+		accessKey: conf.AccessKey,
+		secretKey: conf.SecretKey,
+		prefix:    conf.Prefix,
+		partSize:  max(conf.PartSize, S3MinPartSize),
+	}
+
+	if store.client == nil {
+		store.client = http.DefaultClient
+	}
+
+	if store.region == "" {
+		store.region = S3DefaultRegion
+	}
+
+	if conf.PartSize == 0 {
+		store.partSize = S3DefaultPartSize
+	}
+
+	resp, err := store.do(ctx, &s3Request{header: nil, query: nil, method: http.MethodHead, key: "", body: nil})
+	if err != nil {
+		return nil, fmt.Errorf("s3 bucket %s: %w", conf.Bucket, err)
+	}
+
+	_ = resp.Body.Close()
+
+	return store, nil
+}
+
+// Put сохраняет содержимое в корзину.
+func (s *S3Store) Put(
+	ctx context.Context,
+	src io.Reader,
+	maxSize int64,
+	wantHash string,
+) (*Info, error) {
+	if wantHash != "" && !ValidHash(wantHash) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidHash, wantHash)
+	}
+
+	if maxSize > 0 {
+		// Читается на байт больше, чтобы отличить превышение размера.
+		src = io.LimitReader(src, maxSize+1)
+	}
+
+	part := make([]byte, s.partSize)
+
+	size, last, err := readPart(src, part)
+	if err != nil {
+		return nil, err
+	}
+
+	if last {
+		return s.putSmall(ctx, part[:size], maxSize, wantHash)
+	}
+
+	return s.putMultipart(ctx, src, part, maxSize, wantHash)
+}
+
+// Open открывает содержимое в корзине для чтения.
+func (s *S3Store) Open(ctx context.Context, hash string) (io.ReadCloser, *Info, error) {
+	if !ValidHash(hash) {
+		return nil, nil, fmt.Errorf("%w: %q", ErrInvalidHash, hash)
+	}
+
+	resp, err := s.do(ctx, &s3Request{header: nil, query: nil, method: http.MethodGet, key: s.key(hash), body: nil})
+	if err != nil {
+		var s3Err *S3Error
+		if errors.As(err, &s3Err) && s3Err.StatusCode == http.StatusNotFound {
+			return nil, nil, fmt.Errorf("%w: %s", ErrNotFound, hash)
+		}
+
+		return nil, nil, fmt.Errorf("open blob: %w", err)
+	}
+
+	info := &Info{
+		Hash: hash,
+		Size: resp.ContentLength,
+	}
+
+	return resp.Body, info, nil
+}
+
+// Sweep удаляет из корзины ненужное содержимое и брошенные временные объекты.
+func (s *S3Store) Sweep(
+	ctx context.Context,
+	before time.Time,
+	keep func(hash string) bool,
+) (int, error) {
+	count := 0
+	token := ""
+
+	for {
+		page, err := s.list(ctx, token)
+		if err != nil {
+			return count, fmt.Errorf("sweep blobs: %w", err)
+		}
+
+		for _, object := range page.Contents {
+			if !object.LastModified.Before(before) {
+				continue
+			}
+
+			name := strings.TrimPrefix(object.Key, s.prefix)
+			hash := path.Base(name)
+
+			switch {
+			case strings.HasPrefix(name, s3TmpPrefix):
+				// Брошенный временный объект удаляется, но не учитывается.
+			case !ValidHash(hash) || name != path.Join(hash[:diskFanout], hash) || keep(hash):
+				continue
+			default:
+				count++
+			}
+
+			if err := s.delete(ctx, object.Key); err != nil {
+				return count, fmt.Errorf("sweep blobs: %w", err)
+			}
+		}
+
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return count, nil
+		}
+
+		token = page.NextContinuationToken
+	}
+}
+
+// putSmall сохраняет содержимое, уместившееся в одну часть, одним запросом.
+func (s *S3Store) putSmall(ctx context.Context, data []byte, maxSize int64, wantHash string) (*Info, error) {
+	size := int64(len(data))
+
+	if maxSize > 0 && size > maxSize {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, maxSize)
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	if wantHash != "" && hash != wantHash {
+		return nil, fmt.Errorf("%w: got %s, want %s", ErrHashMismatch, hash, wantHash)
+	}
+
+	resp, err := s.do(ctx, &s3Request{
+		header: http.Header{"Content-Type": {"application/octet-stream"}},
+		query:  nil,
+		method: http.MethodPut,
+		key:    s.key(hash),
+		body:   data,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("put blob: %w", err)
+	}
+
+	_ = resp.Body.Close()
+
+	info := &Info{
+		Hash: hash,
+		Size: size,
+	}
+
+	return info, nil
+}
+
+// putMultipart сохраняет содержимое составной загрузкой. В part - уже прочитанная первая часть.
+func (s *S3Store) putMultipart(
+	ctx context.Context,
+	src io.Reader,
+	part []byte,
+	maxSize int64,
+	wantHash string,
+) (*Info, error) {
+	// Итоговый ключ известен заранее только по ожидаемому хешу.
+	key := s.prefix + s3TmpPrefix + uuid.New().String()
+	if wantHash != "" {
+		key = s.key(wantHash)
+	}
+
+	uploadID, err := s.initiate(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("put blob: %w", err)
+	}
+
+	completed := false
+
+	defer func() {
+		if !completed {
+			// Незавершённые части занимают место, пока загрузка не отменена.
+			_ = s.abort(context.WithoutCancel(ctx), key, uploadID)
+		}
+	}()
+
+	hasher := sha256.New()
+	parts := make([]s3Part, 0)
+	size := int64(len(part))
+
+	for last := false; ; {
+		hasher.Write(part)
+
+		if maxSize > 0 && size > maxSize {
+			return nil, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, maxSize)
+		}
+
+		etag, err := s.uploadPart(ctx, key, uploadID, len(parts)+1, part)
+		if err != nil {
+			return nil, fmt.Errorf("put blob part %d: %w", len(parts)+1, err)
+		}
+
+		parts = append(parts, s3Part{PartNumber: len(parts) + 1, ETag: etag})
+
+		if last {
+			break
+		}
+
+		var read int
+
+		read, last, err = readPart(src, part[:cap(part)])
+		if err != nil {
+			return nil, err
+		}
+
+		if read == 0 {
+			break
+		}
+
+		part = part[:read]
+		size += int64(read)
+	}
+
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	if wantHash != "" && hash != wantHash {
+		return nil, fmt.Errorf("%w: got %s, want %s", ErrHashMismatch, hash, wantHash)
+	}
+
+	if err := s.complete(ctx, key, uploadID, parts); err != nil {
+		return nil, fmt.Errorf("put blob: %w", err)
+	}
+
+	completed = true
+
+	if wantHash == "" {
+		if err := s.commitTemp(ctx, key, hash, size); err != nil {
+			return nil, err
+		}
+	}
+
+	info := &Info{
+		Hash: hash,
+		Size: size,
+	}
+
+	return info, nil
+}
+
+// commitTemp копирует временный объект под итоговый ключ и удаляет его.
+func (s *S3Store) commitTemp(ctx context.Context, tmpKey, hash string, size int64) error {
+	defer func() { _ = s.delete(context.WithoutCancel(ctx), tmpKey) }()
+
+	if size > s3MaxCopySize {
+		return fmt.Errorf("%w: %d bytes without expected hash (copy limit %d)", ErrTooLarge, size, s3MaxCopySize)
+	}
+
+	source := "/" + s3Escape(s.bucket, false) + "/" + s3Escape(tmpKey, true)
+
+	err := s.doXML(ctx, &s3Request{
+		header: http.Header{"X-Amz-Copy-Source": {source}},
+		query:  nil,
+		method: http.MethodPut,
+		key:    s.key(hash),
+		body:   nil,
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("commit blob: %w", err)
+	}
+
+	return nil
+}
+
+// initiate начинает составную загрузку и возвращает её идентификатор.
+func (s *S3Store) initiate(ctx context.Context, key string) (string, error) {
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+
+	err := s.doXML(ctx, &s3Request{
+		header: http.Header{"Content-Type": {"application/octet-stream"}},
+		query:  url.Values{"uploads": {""}},
+		method: http.MethodPost,
+		key:    key,
+		body:   nil,
+	}, &result)
+	if err != nil {
+		return "", fmt.Errorf("initiate multipart: %w", err)
+	}
+
+	return result.UploadID, nil
+}
+
+// uploadPart загружает часть составной загрузки и возвращает её ETag.
+func (s *S3Store) uploadPart(ctx context.Context, key, uploadID string, number int, data []byte) (string, error) {
+	resp, err := s.do(ctx, &s3Request{
+		header: nil,
+		query:  url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}},
+		method: http.MethodPut,
+		key:    key,
+		body:   data,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	_ = resp.Body.Close()
+
+	return resp.Header.Get("ETag"), nil
+}
+
+// complete завершает составную загрузку.
+func (s *S3Store) complete(ctx context.Context, key, uploadID string, parts []s3Part) error {
+	body, err := xml.Marshal(s3CompleteUpload{
+		XMLName: xml.Name{Space: "", Local: "CompleteMultipartUpload"},
+		Parts:   parts,
+	})
+	if err != nil {
+		return fmt.Errorf("encode parts: %w", err)
+	}
+
+	err = s.doXML(ctx, &s3Request{
+		header: http.Header{"Content-Type": {"application/xml"}},
+		query:  url.Values{"uploadId": {uploadID}},
+		method: http.MethodPost,
+		key:    key,
+		body:   body,
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("complete multipart: %w", err)
+	}
+
+	return nil
+}
+
+// abort отменяет составную загрузку.
+func (s *S3Store) abort(ctx context.Context, key, uploadID string) error {
+	resp, err := s.do(ctx, &s3Request{
+		header: nil,
+		query:  url.Values{"uploadId": {uploadID}},
+		method: http.MethodDelete,
+		key:    key,
+		body:   nil,
+	})
+	if err != nil {
+		return fmt.Errorf("abort multipart: %w", err)
+	}
+
+	_ = resp.Body.Close()
+
+	return nil
+}
+
+// list возвращает страницу объектов с префиксом хранилища.
+func (s *S3Store) list(ctx context.Context, token string) (*s3ListResult, error) {
+	query := url.Values{"list-type": {"2"}, "max-keys": {s3ListMaxKeys}}
+	if s.prefix != "" {
+		query.Set("prefix", s.prefix)
+	}
+
+	if token != "" {
+		query.Set("continuation-token", token)
+	}
+
+	var result s3ListResult
+
+	err := s.doXML(ctx, &s3Request{header: nil, query: query, method: http.MethodGet, key: "", body: nil}, &result)
+	if err != nil {
+		return nil, fmt.Errorf("list objects: %w", err)
+	}
+
+	return &result, nil
+}
+
+// delete удаляет объект, если он ещё существует.
+func (s *S3Store) delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, &s3Request{header: nil, query: nil, method: http.MethodDelete, key: key, body: nil})
+	if err != nil {
+		var s3Err *S3Error
+		if errors.As(err, &s3Err) && s3Err.StatusCode == http.StatusNotFound {
+			return nil
+		}
+
+		return fmt.Errorf("delete %s: %w", key, err)
+	}
+
+	_ = resp.Body.Close()
+
+	return nil
+}
+
+// key возвращает ключ объекта содержимого.
+func (s *S3Store) key(hash string) string {
+	return s.prefix + hash[:diskFanout] + "/" + hash
+}
+
+// readPart читает из src часть размером до len(buf).
+// Возвращает количество прочитанных байт и признак конца содержимого.
+func readPart(src io.Reader, buf []byte) (int, bool, error) {
+	read, err := io.ReadFull(src, buf)
+
+	switch {
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return read, true, nil
+	case err != nil:
+		return read, false, fmt.Errorf("read blob: %w", err)
+	default:
+		return read, false, nil
+	}
+}
+
+// s3Part - завершённая часть составной загрузки.
+type s3Part struct {
+	ETag       string `xml:"ETag"`
+	PartNumber int    `xml:"PartNumber"`
+}
+
+// s3CompleteUpload - тело запроса завершения составной загрузки.
+type s3CompleteUpload struct {
+	XMLName xml.Name `xml:"CompleteMultipartUpload"`
+	Parts   []s3Part `xml:"Part"`
+}
+
+// s3ListResult - страница списка объектов (ListObjectsV2).
+type s3ListResult struct {
+	NextContinuationToken string     `xml:"NextContinuationToken"`
+	Contents              []s3Object `xml:"Contents"`
+	IsTruncated           bool       `xml:"IsTruncated"`
+}
+
+// s3Object - объект в списке объектов.
+type s3Object struct {
+	LastModified time.Time `xml:"LastModified"`
+	Key          string    `xml:"Key"`
+}
@@ -0,0 +1,259 @@
+// Package blob предоставляет хранилища содержимого бинарных записей.
+package blob
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	s3Algorithm   = "AWS4-HMAC-SHA256" // алгоритм подписи запросов
+	s3Service     = "s3"               // название сервиса в области подписи
+	s3Terminator  = "aws4_request"     // окончание области подписи
+	s3DateTime    = "20060102T150405Z" // формат времени подписи
+	s3DateLen     = 8                  // длина даты в начале времени подписи
+	s3ErrorBody   = 64 << 10           // наибольший читаемый размер ответа с ошибкой
+	s3EmptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// S3Error описывает ошибку, которую вернул S3-совместимый сервис.
+type S3Error struct {
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+	StatusCode int    `xml:"-"`
+}
+
+// Error возвращает текст ошибки.
+func (e *S3Error) Error() string {
+	return fmt.Sprintf("s3: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// s3Request описывает запрос к S3-совместимому сервису.
+type s3Request struct {
+	header http.Header
+	query  url.Values
+	method string
+	key    string // ключ объекта (пусто - запрос к корзине)
+	body   []byte
+}
+
+// do подписывает и выполняет запрос. Ответ с кодом не из 2xx
+// (или с ошибкой в теле при коде 200) возвращается как *S3Error.
+// Тело успешного ответа закрывает вызывающий.
+func (s *S3Store) do(ctx context.Context, sreq *s3Request) (*http.Response, error) {
+	escaped := "/" + s3Escape(s.bucket, false)
+	if sreq.key != "" {
+		escaped += "/" + s3Escape(sreq.key, true)
+	}
+
+	target := *s.endpoint
+	target.Path, _ = url.PathUnescape(escaped)
+	target.RawPath = escaped
+	target.RawQuery = s3CanonicalQuery(sreq.query)
+
+	req, err := http.NewRequestWithContext(ctx, sreq.method, target.String(), bytes.NewReader(sreq.body))
+	if err != nil {
+		return nil, fmt.Errorf("s3 request: %w", err)
+	}
+
+	for name, values := range sreq.header {
+		req.Header[name] = values
+	}
+
+	payloadHash := s3EmptySHA256
+	if len(sreq.body) > 0 {
+		sum := sha256.Sum256(sreq.body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+
+	s3Sign(req, payloadHash, s.accessKey, s.secretKey, s.region, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3 %s: %w", sreq.method, err)
+	}
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, s3ResponseError(resp)
+	}
+
+	return resp, nil
+}
+
+// doXML выполняет запрос и разбирает XML-ответ в out.
+//
+// Некоторые операции (завершение составной загрузки, копирование) сообщают
+// об ошибке в теле ответа с кодом 200, поэтому корневой элемент проверяется.
+func (s *S3Store) doXML(ctx context.Context, sreq *s3Request, out any) error {
+	resp, err := s.do(ctx, sreq)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("s3 read response: %w", err)
+	}
+
+	var root struct {
+		XMLName xml.Name
+	}
+
+	if err := xml.Unmarshal(body, &root); err == nil && root.XMLName.Local == "Error" {
+		s3Err := &S3Error{Code: "", Message: "", StatusCode: resp.StatusCode}
+		_ = xml.Unmarshal(body, s3Err)
+
+		return s3Err
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	if err := xml.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("s3 decode response: %w", err)
+	}
+
+	return nil
+}
+
+// s3ResponseError читает ошибку из ответа и закрывает его тело.
+func s3ResponseError(resp *http.Response) error {
+	defer func() { _ = resp.Body.Close() }()
+
+	s3Err := &S3Error{
+		Code:       http.StatusText(resp.StatusCode),
+		Message:    "",
+		StatusCode: resp.StatusCode,
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, s3ErrorBody))
+	_ = xml.Unmarshal(body, s3Err)
+
+	return s3Err
+}
+
+// s3Sign подписывает запрос по схеме AWS Signature Version 4.
+//
+// Подписываются все заголовки запроса, кроме Authorization, User-Agent и Content-Length,
+// а также Host. Тело запроса подписывается его хешем payloadHash.
+func s3Sign(req *http.Request, payloadHash, accessKey, secretKey, region string, now time.Time) {
+	amzDate := now.UTC().Format(s3DateTime)
+	date := amzDate[:s3DateLen]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	values := map[string]string{"host": host}
+	names := []string{"host"}
+
+	for name, vals := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "authorization" || lower == "user-agent" || lower == "content-length" {
+			continue
+		}
+
+		values[lower] = strings.TrimSpace(strings.Join(vals, ","))
+		names = append(names, lower)
+	}
+
+	sort.Strings(names)
+
+	var headers strings.Builder
+	for _, name := range names {
+		headers.WriteString(name + ":" + values[name] + "\n")
+	}
+
+	signedHeaders := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		s3CanonicalQuery(req.URL.Query()),
+		headers.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, region, s3Service, s3Terminator}, "/")
+	canonicalSum := sha256.Sum256([]byte(canonical))
+	toSign := strings.Join([]string{s3Algorithm, amzDate, scope, hex.EncodeToString(canonicalSum[:])}, "\n")
+
+	key := s3HMAC([]byte("AWS4"+secretKey), date)
+	key = s3HMAC(key, region)
+	key = s3HMAC(key, s3Service)
+	key = s3HMAC(key, s3Terminator)
+
+	signature := hex.EncodeToString(s3HMAC(key, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, accessKey, scope, signedHeaders, signature))
+}
+
+// s3HMAC вычисляет HMAC-SHA256 строки data.
+func s3HMAC(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
+
+// s3CanonicalQuery возвращает параметры запроса в каноническом виде: отсортированными
+// по имени и закодированными по RFC 3986.
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+
+	for _, key := range keys {
+		vals := append([]string(nil), query[key]...)
+		sort.Strings(vals)
+
+		for _, val := range vals {
+			pairs = append(pairs, s3Escape(key, false)+"="+s3Escape(val, false))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// s3Escape кодирует строку по RFC 3986, оставляя только незарезервированные символы
+// (и '/', если keepSlash).
+func s3Escape(value string, keepSlash bool) string {
+	var out strings.Builder
+
+	for _, char := range []byte(value) {
+		switch {
+		case char >= 'A' && char <= 'Z', char >= 'a' && char <= 'z', char >= '0' && char <= '9',
+			char == '-', char == '_', char == '.', char == '~', keepSlash && char == '/':
+			out.WriteByte(char)
+		default:
+			fmt.Fprintf(&out, "%%%02X", char)
+		}
+	}
+
+	return out.String()
+}
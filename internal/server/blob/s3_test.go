@@ -0,0 +1,408 @@
+package blob_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/blob"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	fakeBucket   = "vault"
+	fakePageSize = 2 // размер страницы списка, чтобы проверить продолжение
+)
+
+// fakeObject - объект в корзине fakeS3.
+type fakeObject struct {
+	modified time.Time
+	data     []byte
+}
+
+// fakeS3 - минимальная замена S3-совместимого сервиса в памяти: одна корзина,
+// адресация по пути, объекты, копирование, составная загрузка и ListObjectsV2.
+// Проверяет наличие подписи и соответствие тела заголовку X-Amz-Content-Sha256.
+type fakeS3 struct {
+	objects map[string]*fakeObject
+	uploads map[string]map[int][]byte // незавершённые составные загрузки
+	mu      sync.Mutex
+}
+
+func newFakeS3(t *testing.T) (*fakeS3, *httptest.Server) {
+	t.Helper()
+
+	fake := &fakeS3{
+		objects: make(map[string]*fakeObject),
+		uploads: make(map[string]map[int][]byte),
+		mu:      sync.Mutex{},
+	}
+
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	return fake, server
+}
+
+//nolint:cyclop,funlen,gocognit // разбор всех поддерживаемых операций в одном месте
+func (f *fakeS3) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	body, _ := io.ReadAll(req.Body)
+	sum := sha256.Sum256(body)
+
+	if !strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") ||
+		req.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		fakeError(resp, http.StatusForbidden, "SignatureDoesNotMatch")
+
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/"), "/")
+	if bucket != fakeBucket {
+		fakeError(resp, http.StatusNotFound, "NoSuchBucket")
+
+		return
+	}
+
+	query := req.URL.Query()
+	uploadID := query.Get("uploadId")
+
+	switch {
+	case key == "" && req.Method == http.MethodHead:
+	case key == "" && req.Method == http.MethodGet:
+		f.list(resp, query)
+	case req.Method == http.MethodPost && query.Has("uploads"):
+		uploadID = uuid.New().String()
+		f.uploads[uploadID] = make(map[int][]byte)
+		fmt.Fprintf(resp, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", uploadID)
+	case req.Method == http.MethodPut && uploadID != "":
+		parts, ok := f.uploads[uploadID]
+		if !ok {
+			fakeError(resp, http.StatusNotFound, "NoSuchUpload")
+
+			return
+		}
+
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		parts[number] = body
+		resp.Header().Set("ETag", strconv.Quote(strconv.Itoa(number)))
+	case req.Method == http.MethodPost && uploadID != "":
+		parts, ok := f.uploads[uploadID]
+		if !ok {
+			fakeError(resp, http.StatusNotFound, "NoSuchUpload")
+
+			return
+		}
+
+		var complete struct {
+			Parts []struct {
+				ETag       string `xml:"ETag"`
+				PartNumber int    `xml:"PartNumber"`
+			} `xml:"Part"`
+		}
+
+		_ = xml.Unmarshal(body, &complete)
+
+		var data []byte
+
+		for i, part := range complete.Parts {
+			if part.PartNumber != i+1 || part.ETag != strconv.Quote(strconv.Itoa(i+1)) {
+				fakeError(resp, http.StatusOK, "InvalidPart")
+
+				return
+			}
+
+			data = append(data, parts[part.PartNumber]...)
+		}
+
+		delete(f.uploads, uploadID)
+		f.objects[key] = &fakeObject{modified: time.Now(), data: data}
+		fmt.Fprint(resp, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+	case req.Method == http.MethodDelete && uploadID != "":
+		delete(f.uploads, uploadID)
+		resp.WriteHeader(http.StatusNoContent)
+	case req.Method == http.MethodPut && req.Header.Get("X-Amz-Copy-Source") != "":
+		source, ok := f.objects[strings.TrimPrefix(req.Header.Get("X-Amz-Copy-Source"), "/"+fakeBucket+"/")]
+		if !ok {
+			fakeError(resp, http.StatusNotFound, "NoSuchKey")
+
+			return
+		}
+
+		f.objects[key] = &fakeObject{modified: time.Now(), data: source.data}
+		fmt.Fprint(resp, "<CopyObjectResult></CopyObjectResult>")
+	case req.Method == http.MethodPut:
+		f.objects[key] = &fakeObject{modified: time.Now(), data: body}
+	case req.Method == http.MethodGet:
+		object, ok := f.objects[key]
+		if !ok {
+			fakeError(resp, http.StatusNotFound, "NoSuchKey")
+
+			return
+		}
+
+		resp.Header().Set("Content-Length", strconv.Itoa(len(object.data)))
+		_, _ = resp.Write(object.data)
+	case req.Method == http.MethodDelete:
+		delete(f.objects, key)
+		resp.WriteHeader(http.StatusNoContent)
+	default:
+		fakeError(resp, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+// list отвечает страницей ListObjectsV2.
+func (f *fakeS3) list(resp http.ResponseWriter, query map[string][]string) {
+	prefix := ""
+	if values := query["prefix"]; len(values) > 0 {
+		prefix = values[0]
+	}
+
+	keys := make([]string, 0, len(f.objects))
+
+	for key := range f.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	// Как и в S3, продолжение не зависит от удалений между страницами.
+	start := 0
+	if values := query["continuation-token"]; len(values) > 0 {
+		start = sort.SearchStrings(keys, values[0]+"\x00")
+	}
+
+	end := min(start+fakePageSize, len(keys))
+
+	fmt.Fprint(resp, "<ListBucketResult>")
+
+	for _, key := range keys[start:end] {
+		fmt.Fprintf(resp, "<Contents><Key>%s</Key><LastModified>%s</LastModified></Contents>",
+			key, f.objects[key].modified.UTC().Format(time.RFC3339Nano))
+	}
+
+	if end < len(keys) {
+		fmt.Fprintf(resp, "<IsTruncated>true</IsTruncated><NextContinuationToken>%s</NextContinuationToken>",
+			keys[end-1])
+	}
+
+	fmt.Fprint(resp, "</ListBucketResult>")
+}
+
+// counts возвращает количество объектов и незавершённых составных загрузок.
+func (f *fakeS3) counts() (int, int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.objects), len(f.uploads)
+}
+
+func fakeError(resp http.ResponseWriter, code int, s3Code string) {
+	resp.WriteHeader(code)
+	fmt.Fprintf(resp, "<Error><Code>%s</Code><Message>fake</Message></Error>", s3Code)
+}
+
+// newS3Store создаёт хранилище поверх fakeS3 или, если задана переменная окружения
+// TEST_S3_ENDPOINT, поверх настоящего S3-совместимого сервиса (например, MinIO).
+func newS3Store(t *testing.T) (*blob.S3Store, *fakeS3) {
+	t.Helper()
+
+	conf := &blob.S3Config{
+		Client:    nil,
+		Endpoint:  os.Getenv("TEST_S3_ENDPOINT"),
+		Bucket:    os.Getenv("TEST_S3_BUCKET"),
+		Region:    "",
+		AccessKey: os.Getenv("TEST_S3_ACCESS_KEY"),
+		SecretKey: os.Getenv("TEST_S3_SECRET_KEY"),
+		Prefix:    "test-" + uuid.New().String() + "/",
+		PartSize:  blob.S3MinPartSize,
+	}
+
+	var fake *fakeS3
+
+	if conf.Endpoint == "" {
+		var server *httptest.Server
+
+		fake, server = newFakeS3(t)
+		conf.Endpoint = server.URL
+		conf.Bucket = fakeBucket
+		conf.AccessKey = "key"
+		conf.SecretKey = "secret"
+	}
+
+	store, err := blob.NewS3Store(context.Background(), conf)
+	require.NoError(t, err)
+
+	return store, fake
+}
+
+/*
+	===== S3Store =====
+*/
+
+func TestS3Store_PutOpen(t *testing.T) {
+	t.Parallel()
+
+	store, _ := newS3Store(t)
+	ctx := context.Background()
+
+	small := "binary content"
+	large := strings.Repeat("0123456789abcdef", blob.S3MinPartSize/8) // две части
+
+	for name, data := range map[string]string{"small": small, "large": large, "empty": ""} {
+		for _, wantHash := range []string{"", sha256Hex(data)} {
+			info, err := store.Put(ctx, strings.NewReader(data), 0, wantHash)
+			require.NoError(t, err, name)
+			assert.Equal(t, sha256Hex(data), info.Hash, name)
+			assert.Equal(t, int64(len(data)), info.Size, name)
+
+			reader, opened, err := store.Open(ctx, info.Hash)
+			require.NoError(t, err, name)
+
+			got, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			require.NoError(t, reader.Close())
+			assert.Equal(t, len(data), len(got), name)
+			assert.Equal(t, data, string(got), name)
+			assert.Equal(t, info, opened, name)
+		}
+	}
+}
+
+func TestS3Store_PutErrors(t *testing.T) {
+	t.Parallel()
+
+	store, fake := newS3Store(t)
+	ctx := context.Background()
+
+	large := strings.Repeat("x", blob.S3MinPartSize+10)
+
+	_, err := store.Put(ctx, strings.NewReader("12345"), 4, "")
+	require.ErrorIs(t, err, blob.ErrTooLarge)
+
+	_, err = store.Put(ctx, strings.NewReader(large), blob.S3MinPartSize+9, "")
+	require.ErrorIs(t, err, blob.ErrTooLarge)
+
+	_, err = store.Put(ctx, strings.NewReader("data"), 0, sha256Hex("other"))
+	require.ErrorIs(t, err, blob.ErrHashMismatch)
+
+	_, err = store.Put(ctx, strings.NewReader(large), 0, sha256Hex("other"))
+	require.ErrorIs(t, err, blob.ErrHashMismatch)
+
+	_, err = store.Put(ctx, strings.NewReader("data"), 0, "not-a-hash")
+	require.ErrorIs(t, err, blob.ErrInvalidHash)
+
+	_, _, err = store.Open(ctx, sha256Hex("data"))
+	require.ErrorIs(t, err, blob.ErrNotFound, "rejected content must not be stored")
+
+	_, _, err = store.Open(ctx, "../../etc/passwd")
+	require.ErrorIs(t, err, blob.ErrInvalidHash)
+
+	if fake != nil {
+		objects, uploads := fake.counts()
+		assert.Zero(t, objects, "temporary objects must be removed")
+		assert.Zero(t, uploads, "multipart uploads must be aborted")
+	}
+}
+
+func TestS3Store_Sweep(t *testing.T) {
+	t.Parallel()
+
+	store, _ := newS3Store(t)
+	ctx := context.Background()
+
+	hashes := make([]string, 0)
+
+	for _, data := range []string{"kept", "orphan-1", "orphan-2", "orphan-3"} {
+		info, err := store.Put(ctx, strings.NewReader(data), 0, "")
+		require.NoError(t, err)
+
+		hashes = append(hashes, info.Hash)
+	}
+
+	keep := func(hash string) bool { return hash == hashes[0] }
+
+	count, err := store.Sweep(ctx, time.Now().Add(-time.Hour), keep)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count, "fresh content is not swept")
+
+	count, err = store.Sweep(ctx, time.Now().Add(time.Hour), keep)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	for _, hash := range hashes[1:] {
+		_, _, err = store.Open(ctx, hash)
+		require.ErrorIs(t, err, blob.ErrNotFound)
+	}
+
+	reader, _, err := store.Open(ctx, hashes[0])
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+}
+
+func TestNewS3Store_Errors(t *testing.T) {
+	t.Parallel()
+
+	_, server := newFakeS3(t)
+
+	tests := []struct {
+		name string
+		conf blob.S3Config
+	}{
+		{
+			name: "bad endpoint",
+			conf: blob.S3Config{
+				Client: nil, Endpoint: "localhost:9000", Bucket: fakeBucket, Region: "",
+				AccessKey: "key", SecretKey: "secret", Prefix: "", PartSize: 0,
+			},
+		},
+		{
+			name: "no bucket",
+			conf: blob.S3Config{
+				Client: nil, Endpoint: server.URL, Bucket: "", Region: "",
+				AccessKey: "key", SecretKey: "secret", Prefix: "", PartSize: 0,
+			},
+		},
+		{
+			name: "missing bucket",
+			conf: blob.S3Config{
+				Client: nil, Endpoint: server.URL, Bucket: "other", Region: "",
+				AccessKey: "key", SecretKey: "secret", Prefix: "", PartSize: 0,
+			},
+		},
+		{
+			name: "bad credentials",
+			conf: blob.S3Config{
+				Client: nil, Endpoint: server.URL, Bucket: fakeBucket, Region: "",
+				AccessKey: "other", SecretKey: "secret", Prefix: "", PartSize: 0,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := blob.NewS3Store(context.Background(), &tt.conf)
+			require.Error(t, err)
+		})
+	}
+}
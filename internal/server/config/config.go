@@ -1,20 +1,128 @@
 // Package config предоставляет функционал для загрузки конфигурации приложения.
 package config
 
+import (
+	"os"
+	"time"
+)
+
 // Костанты - значения по умолчанию.
 const (
 	DefaultServerAddress string = "localhost:8080" // адрес сервера
 	DefaultHashKey       string = ""               // ключ хэширования
 	DefaultCryptoJWTKey  string = ""               // путь до ключа JWT
 	DefaultDatabase      string = ""               // строка подключения к базе данных
+	DefaultStorageFile   string = ""               // путь до файла встроенного хранилища
+
+	DefaultTombstoneRetention time.Duration = 30 * 24 * time.Hour // время хранения отметок об удалении
+	DefaultRevisionLimit      int           = 10                  // количество прежних версий записи
+	DefaultRevisionMaxAge     time.Duration = 90 * 24 * time.Hour // время хранения прежних версий записи
+	DefaultTrashRetention     time.Duration = 30 * 24 * time.Hour // время хранения записей в корзине
+	DefaultQuotaMaxItems      int64         = 10000               // количество записей пользователя
+	DefaultQuotaMaxBytes      int64         = 100 << 20           // суммарный размер записей пользователя
+	DefaultQuotaMaxItemBytes  int64         = 1 << 20             // размер одной записи
+	DefaultBlobDir            string        = "blobs"             // каталог содержимого бинарных записей
+	DefaultBlobMaxSize        int64         = 256 << 20           // максимальный размер содержимого бинарной записи
+	DefaultUploadDir          string        = "uploads"           // каталог незавершённых загрузок
+	DefaultUploadTTL          time.Duration = 24 * time.Hour      // время жизни незавершённой загрузки
+	DefaultBlobS3Endpoint     string        = ""                  // адрес S3-совместимого хранилища содержимого
+	DefaultBlobS3Bucket       string        = "goph-keeper"       // корзина S3 для содержимого
+	DefaultBlobS3Region       string        = "us-east-1"         // регион S3
+	DefaultBlobS3AccessKey    string        = ""                  // ключ доступа S3
+	DefaultBlobS3SecretKey    string        = ""                  // секретный ключ S3
+	DefaultBlobS3Prefix       string        = "blobs/"            // префикс ключей содержимого в корзине
+	DefaultBlobS3PartSize     int64         = 16 << 20            // размер части составной загрузки S3
+	DefaultHashKeyPrevious    string        = ""                  // прежний ключ хэширования
+	DefaultUnsealCheck        string        = ""                  // контрольное значение мастер-ключа
+	DefaultAdminToken         string        = ""                  // токен административных запросов
+	DefaultBackupPassphrase   string        = ""                  // пароль шифрования резервных копий
+	DefaultCacheSize          int           = 0                   // размер кэша хранилища в записях
+	DefaultCacheTTL           time.Duration = time.Minute         // время жизни записи кэша хранилища
 )
 
 // Config - структура, содержащая основные параметры приложения.
 type Config struct {
 	ServerAddress string // Aдрес сервера
-	HashKey       string // Ключ хэширования
+	HashKey       string // Ключ хэширования (мастер-ключ шифрования данных хранилища, пусто - без шифрования)
 	CryptoJWTKey  string // Ключ для JWT
 	Database      string // Строка подключения к базе данных
+	StorageFile   string // Путь до файла встроенного хранилища
+
+	// Время хранения отметок об удалении записей (0 - хранить бессрочно).
+	// Клиент, не синхронизировавшийся дольше этого времени, должен выполнить полную синхронизацию.
+	TombstoneRetention time.Duration
+
+	// Количество хранимых прежних версий каждой записи (0 - не хранить).
+	RevisionLimit int
+
+	// Время хранения прежних версий записей (0 - хранить бессрочно).
+	RevisionMaxAge time.Duration
+
+	// Время хранения удалённых записей в корзине (0 - хранить бессрочно).
+	TrashRetention time.Duration
+
+	// Наибольшее количество записей пользователя (0 - без ограничения).
+	QuotaMaxItems int64
+
+	// Наибольший суммарный размер записей пользователя в байтах (0 - без ограничения).
+	QuotaMaxBytes int64
+
+	// Наибольший размер одной записи в байтах (0 - без ограничения).
+	QuotaMaxItemBytes int64
+
+	// Каталог хранилища содержимого бинарных записей.
+	BlobDir string
+
+	// Максимальный размер содержимого бинарной записи в байтах (0 - без ограничения).
+	BlobMaxSize int64
+
+	// Каталог незавершённых возобновляемых загрузок содержимого.
+	UploadDir string
+
+	// Время после последней записи, через которое незавершённая загрузка удаляется (0 - никогда).
+	UploadTTL time.Duration
+
+	// Адрес S3-совместимого хранилища содержимого бинарных записей (пусто - каталог BlobDir).
+	BlobS3Endpoint string
+
+	// Корзина S3 для содержимого бинарных записей.
+	BlobS3Bucket string
+
+	// Регион S3.
+	BlobS3Region string
+
+	// Ключ доступа S3.
+	BlobS3AccessKey string
+
+	// Секретный ключ S3.
+	BlobS3SecretKey string
+
+	// Префикс ключей содержимого в корзине S3.
+	BlobS3Prefix string
+
+	// Размер части составной загрузки S3 в байтах (0 - по умолчанию).
+	BlobS3PartSize int64
+
+	// Прежний ключ хэширования (при смене HashKey). Ключи данных пользователей,
+	// зашифрованные им, при запуске перешифровываются текущим ключом.
+	HashKeyPrevious string
+
+	// Контрольное значение мастер-ключа, выданное командой `seal init` (пусто - без запечатанного режима).
+	// В запечатанном режиме сервер запускается без HashKey и CryptoJWTKey и получает их
+	// из мастер-ключа, когда операторы передадут достаточное количество его долей.
+	UnsealCheck string
+
+	// AdminToken - токен административных запросов /sys/* (пусто - запросы отключены).
+	AdminToken string
+
+	// BackupPassphrase - пароль шифрования резервных копий (пусто - копии не шифруются).
+	BackupPassphrase string
+
+	// Наибольшее количество записей в кэше хранилища (0 - без кэша).
+	CacheSize int
+
+	// Время жизни записи кэша хранилища (0 - до вытеснения или изменения).
+	CacheTTL time.Duration
 }
 
 // Initialize создаёт и иницализирует объект *Config.
@@ -23,8 +131,14 @@ type Config struct {
 //   - значения из флагов командной строки;
 //   - значения из переменных окружения.
 func Initialize() *Config {
+	return InitializeFromArgs(os.Args[1:])
+}
+
+// InitializeFromArgs создаёт и иницализирует объект *Config аналогично Initialize,
+// но флаги командной строки берутся из указанных аргументов (например, после подкоманды).
+func InitializeFromArgs(args []string) *Config {
 	envsConf := GetConfigEnvsFromOS()
-	flagsConf, _ := GetConfigFlagsFromOS()
+	flagsConf, _ := GetConfigFlagsFromArgs(args)
 
 	config := CreateConfigDefault().
 		OverrideConfigFromEnvs(envsConf).
@@ -41,6 +155,32 @@ func CreateConfigDefault() *Config {
 		HashKey:       DefaultHashKey,
 		CryptoJWTKey:  DefaultCryptoJWTKey,
 		Database:      DefaultDatabase,
+		StorageFile:   DefaultStorageFile,
+
+		TombstoneRetention: DefaultTombstoneRetention,
+		RevisionLimit:      DefaultRevisionLimit,
+		RevisionMaxAge:     DefaultRevisionMaxAge,
+		TrashRetention:     DefaultTrashRetention,
+		QuotaMaxItems:      DefaultQuotaMaxItems,
+		QuotaMaxBytes:      DefaultQuotaMaxBytes,
+		QuotaMaxItemBytes:  DefaultQuotaMaxItemBytes,
+		BlobDir:            DefaultBlobDir,
+		BlobMaxSize:        DefaultBlobMaxSize,
+		UploadDir:          DefaultUploadDir,
+		UploadTTL:          DefaultUploadTTL,
+		BlobS3Endpoint:     DefaultBlobS3Endpoint,
+		BlobS3Bucket:       DefaultBlobS3Bucket,
+		BlobS3Region:       DefaultBlobS3Region,
+		BlobS3AccessKey:    DefaultBlobS3AccessKey,
+		BlobS3SecretKey:    DefaultBlobS3SecretKey,
+		BlobS3Prefix:       DefaultBlobS3Prefix,
+		BlobS3PartSize:     DefaultBlobS3PartSize,
+		HashKeyPrevious:    DefaultHashKeyPrevious,
+		UnsealCheck:        DefaultUnsealCheck,
+		AdminToken:         DefaultAdminToken,
+		BackupPassphrase:   DefaultBackupPassphrase,
+		CacheSize:          DefaultCacheSize,
+		CacheTTL:           DefaultCacheTTL,
 	}
 
 	return config
@@ -52,6 +192,54 @@ func (c *Config) ValidateConfig() *Config {
 		return c
 	}
 
+	if c.TombstoneRetention < 0 {
+		c.TombstoneRetention = 0
+	}
+
+	if c.RevisionLimit < 0 {
+		c.RevisionLimit = 0
+	}
+
+	if c.RevisionMaxAge < 0 {
+		c.RevisionMaxAge = 0
+	}
+
+	if c.TrashRetention < 0 {
+		c.TrashRetention = 0
+	}
+
+	if c.QuotaMaxItems < 0 {
+		c.QuotaMaxItems = 0
+	}
+
+	if c.QuotaMaxBytes < 0 {
+		c.QuotaMaxBytes = 0
+	}
+
+	if c.QuotaMaxItemBytes < 0 {
+		c.QuotaMaxItemBytes = 0
+	}
+
+	if c.BlobMaxSize < 0 {
+		c.BlobMaxSize = 0
+	}
+
+	if c.UploadTTL < 0 {
+		c.UploadTTL = 0
+	}
+
+	if c.BlobS3PartSize < 0 {
+		c.BlobS3PartSize = 0
+	}
+
+	if c.CacheSize < 0 {
+		c.CacheSize = 0
+	}
+
+	if c.CacheTTL < 0 {
+		c.CacheTTL = 0
+	}
+
 	// c.ServerAddress = "http://" + stripHTTPPrefix(c.ServerAddress)
 
 	return c
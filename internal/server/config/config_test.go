@@ -3,6 +3,7 @@ package config_test
 import (
 	"flag"
 	"testing"
+	"time"
 
 	"github.com/mr-filatik/go-goph-keeper/internal/server/config"
 	"github.com/stretchr/testify/assert"
@@ -28,8 +29,36 @@ func createTestsForGetConfigEnvs() []testGetConfigEnvs {
 				config.EnvKeyServerAddress: "example.com:8080",
 				config.EnvKeyDatabase:      "database.one:8080",
 				config.EnvKeyCryptoJWTKey:  "secret-jwt-key",
+				config.EnvKeyStorageFile:   "/var/lib/goph-keeper.db",
+
+				config.EnvKeyTombstoneRetention: "48h",
+				config.EnvKeyRevisionLimit:      "5",
+				config.EnvKeyCacheTTL:           "30s",
+				config.EnvKeyCacheSize:          "500",
+				config.EnvKeyBackupPassphrase:   "backup-secret",
+				config.EnvKeyAdminToken:         "admin-secret",
+				config.EnvKeyUnsealCheck:        "3:abcd",
+				config.EnvKeyHashKeyPrevious:    "old-hash-key",
+				config.EnvKeyBlobS3PartSize:     "8388608",
+				config.EnvKeyBlobS3Prefix:       "keeper/",
+				config.EnvKeyBlobS3SecretKey:    "secret",
+				config.EnvKeyBlobS3AccessKey:    "access",
+				config.EnvKeyBlobS3Region:       "eu-central-1",
+				config.EnvKeyBlobS3Bucket:       "vault",
+				config.EnvKeyBlobS3Endpoint:     "http://localhost:9000",
+				config.EnvKeyUploadTTL:          "2h",
+				config.EnvKeyUploadDir:          "/tmp/uploads",
+				config.EnvKeyBlobMaxSize:        "4096",
+				config.EnvKeyBlobDir:            "/tmp/blobs",
+				config.EnvKeyQuotaMaxItemBytes:  "1024",
+				config.EnvKeyQuotaMaxBytes:      "2048",
+				config.EnvKeyQuotaMaxItems:      "500",
+				config.EnvKeyTrashRetention:     "72h",
+				config.EnvKeyRevisionMaxAge:     "24h",
 			},
 			want: config.EnvsConfig{
+				StorageFile:          "/var/lib/goph-keeper.db",
+				StorageFileIsValue:   true,
 				HashKey:              "my-hash-key",
 				HashKeyIsValue:       true,
 				ServerAddress:        "example.com:8080",
@@ -38,6 +67,55 @@ func createTestsForGetConfigEnvs() []testGetConfigEnvs {
 				DatabaseIsValue:      true,
 				CryptoJWTKey:         "secret-jwt-key",
 				CryptoJWTKeyIsValue:  true,
+
+				TombstoneRetention:        48 * time.Hour,
+				TombstoneRetentionIsValue: true,
+				RevisionLimit:             5,
+				RevisionLimitIsValue:      true,
+				RevisionMaxAge:            24 * time.Hour,
+				RevisionMaxAgeIsValue:     true,
+				TrashRetention:            72 * time.Hour,
+				TrashRetentionIsValue:     true,
+				QuotaMaxItems:             500,
+				QuotaMaxItemsIsValue:      true,
+				QuotaMaxBytes:             2048,
+				QuotaMaxBytesIsValue:      true,
+				QuotaMaxItemBytes:         1024,
+				QuotaMaxItemBytesIsValue:  true,
+				BlobDir:                   "/tmp/blobs",
+				BlobDirIsValue:            true,
+				BlobMaxSize:               4096,
+				BlobMaxSizeIsValue:        true,
+				UploadDir:                 "/tmp/uploads",
+				UploadDirIsValue:          true,
+				UploadTTL:                 2 * time.Hour,
+				UploadTTLIsValue:          true,
+				BlobS3Endpoint:            "http://localhost:9000",
+				BlobS3EndpointIsValue:     true,
+				BlobS3Bucket:              "vault",
+				BlobS3BucketIsValue:       true,
+				BlobS3Region:              "eu-central-1",
+				BlobS3RegionIsValue:       true,
+				BlobS3AccessKey:           "access",
+				BlobS3AccessKeyIsValue:    true,
+				BlobS3SecretKey:           "secret",
+				BlobS3SecretKeyIsValue:    true,
+				BlobS3Prefix:              "keeper/",
+				BlobS3PrefixIsValue:       true,
+				BlobS3PartSize:            8388608,
+				BlobS3PartSizeIsValue:     true,
+				HashKeyPrevious:           "old-hash-key",
+				HashKeyPreviousIsValue:    true,
+				UnsealCheck:               "3:abcd",
+				UnsealCheckIsValue:        true,
+				AdminToken:                "admin-secret",
+				AdminTokenIsValue:         true,
+				BackupPassphrase:          "backup-secret",
+				BackupPassphraseIsValue:   true,
+				CacheSize:                 500,
+				CacheSizeIsValue:          true,
+				CacheTTL:                  30 * time.Second,
+				CacheTTLIsValue:           true,
 			},
 		},
 		{
@@ -47,6 +125,8 @@ func createTestsForGetConfigEnvs() []testGetConfigEnvs {
 				config.EnvKeyCryptoJWTKey:  "secret-jwt-key",
 			},
 			want: config.EnvsConfig{
+				StorageFile:          "",
+				StorageFileIsValue:   false,
 				HashKey:              "",
 				HashKeyIsValue:       false,
 				ServerAddress:        "example.com:8080",
@@ -55,12 +135,63 @@ func createTestsForGetConfigEnvs() []testGetConfigEnvs {
 				DatabaseIsValue:      false,
 				CryptoJWTKey:         "secret-jwt-key",
 				CryptoJWTKeyIsValue:  true,
+
+				TombstoneRetention:        0,
+				TombstoneRetentionIsValue: false,
+				RevisionLimit:             0,
+				RevisionLimitIsValue:      false,
+				RevisionMaxAge:            0,
+				RevisionMaxAgeIsValue:     false,
+				TrashRetention:            0,
+				TrashRetentionIsValue:     false,
+				QuotaMaxItems:             0,
+				QuotaMaxItemsIsValue:      false,
+				QuotaMaxBytes:             0,
+				QuotaMaxBytesIsValue:      false,
+				QuotaMaxItemBytes:         0,
+				QuotaMaxItemBytesIsValue:  false,
+				BlobDir:                   "",
+				BlobDirIsValue:            false,
+				BlobMaxSize:               0,
+				BlobMaxSizeIsValue:        false,
+				UploadDir:                 "",
+				UploadDirIsValue:          false,
+				UploadTTL:                 0,
+				UploadTTLIsValue:          false,
+				BlobS3Endpoint:            "",
+				BlobS3EndpointIsValue:     false,
+				BlobS3Bucket:              "",
+				BlobS3BucketIsValue:       false,
+				BlobS3Region:              "",
+				BlobS3RegionIsValue:       false,
+				BlobS3AccessKey:           "",
+				BlobS3AccessKeyIsValue:    false,
+				BlobS3SecretKey:           "",
+				BlobS3SecretKeyIsValue:    false,
+				BlobS3Prefix:              "",
+				BlobS3PrefixIsValue:       false,
+				BlobS3PartSize:            0,
+				BlobS3PartSizeIsValue:     false,
+				HashKeyPrevious:           "",
+				HashKeyPreviousIsValue:    false,
+				UnsealCheck:               "",
+				UnsealCheckIsValue:        false,
+				AdminToken:                "",
+				AdminTokenIsValue:         false,
+				BackupPassphrase:          "",
+				BackupPassphraseIsValue:   false,
+				CacheSize:                 0,
+				CacheSizeIsValue:          false,
+				CacheTTL:                  0,
+				CacheTTLIsValue:           false,
 			},
 		},
 		{
 			name: "empty values",
 			args: map[string]string{},
 			want: config.EnvsConfig{
+				StorageFile:          "",
+				StorageFileIsValue:   false,
 				HashKey:              "",
 				HashKeyIsValue:       false,
 				ServerAddress:        "",
@@ -69,6 +200,55 @@ func createTestsForGetConfigEnvs() []testGetConfigEnvs {
 				DatabaseIsValue:      false,
 				CryptoJWTKey:         "",
 				CryptoJWTKeyIsValue:  false,
+
+				TombstoneRetention:        0,
+				TombstoneRetentionIsValue: false,
+				RevisionLimit:             0,
+				RevisionLimitIsValue:      false,
+				RevisionMaxAge:            0,
+				RevisionMaxAgeIsValue:     false,
+				TrashRetention:            0,
+				TrashRetentionIsValue:     false,
+				QuotaMaxItems:             0,
+				QuotaMaxItemsIsValue:      false,
+				QuotaMaxBytes:             0,
+				QuotaMaxBytesIsValue:      false,
+				QuotaMaxItemBytes:         0,
+				QuotaMaxItemBytesIsValue:  false,
+				BlobDir:                   "",
+				BlobDirIsValue:            false,
+				BlobMaxSize:               0,
+				BlobMaxSizeIsValue:        false,
+				UploadDir:                 "",
+				UploadDirIsValue:          false,
+				UploadTTL:                 0,
+				UploadTTLIsValue:          false,
+				BlobS3Endpoint:            "",
+				BlobS3EndpointIsValue:     false,
+				BlobS3Bucket:              "",
+				BlobS3BucketIsValue:       false,
+				BlobS3Region:              "",
+				BlobS3RegionIsValue:       false,
+				BlobS3AccessKey:           "",
+				BlobS3AccessKeyIsValue:    false,
+				BlobS3SecretKey:           "",
+				BlobS3SecretKeyIsValue:    false,
+				BlobS3Prefix:              "",
+				BlobS3PrefixIsValue:       false,
+				BlobS3PartSize:            0,
+				BlobS3PartSizeIsValue:     false,
+				HashKeyPrevious:           "",
+				HashKeyPreviousIsValue:    false,
+				UnsealCheck:               "",
+				UnsealCheckIsValue:        false,
+				AdminToken:                "",
+				AdminTokenIsValue:         false,
+				BackupPassphrase:          "",
+				BackupPassphraseIsValue:   false,
+				CacheSize:                 0,
+				CacheSizeIsValue:          false,
+				CacheTTL:                  0,
+				CacheTTLIsValue:           false,
 			},
 		},
 	}
@@ -105,6 +285,82 @@ func TestGetConfigEnvs(t *testing.T) {
 
 			assert.Equal(t, internalTest.want.CryptoJWTKey, config.CryptoJWTKey)
 			assert.Equal(t, internalTest.want.CryptoJWTKeyIsValue, config.CryptoJWTKeyIsValue)
+
+			assert.Equal(t, internalTest.want.StorageFile, config.StorageFile)
+			assert.Equal(t, internalTest.want.StorageFileIsValue, config.StorageFileIsValue)
+
+			assert.Equal(t, internalTest.want.TombstoneRetention, config.TombstoneRetention)
+			assert.Equal(t,
+				internalTest.want.TombstoneRetentionIsValue, config.TombstoneRetentionIsValue)
+
+			assert.Equal(t, internalTest.want.RevisionLimit, config.RevisionLimit)
+			assert.Equal(t, internalTest.want.RevisionLimitIsValue, config.RevisionLimitIsValue)
+
+			assert.Equal(t, internalTest.want.RevisionMaxAge, config.RevisionMaxAge)
+			assert.Equal(t, internalTest.want.RevisionMaxAgeIsValue, config.RevisionMaxAgeIsValue)
+
+			assert.Equal(t, internalTest.want.TrashRetention, config.TrashRetention)
+			assert.Equal(t, internalTest.want.TrashRetentionIsValue, config.TrashRetentionIsValue)
+
+			assert.Equal(t, internalTest.want.QuotaMaxItems, config.QuotaMaxItems)
+			assert.Equal(t, internalTest.want.QuotaMaxItemsIsValue, config.QuotaMaxItemsIsValue)
+
+			assert.Equal(t, internalTest.want.QuotaMaxBytes, config.QuotaMaxBytes)
+			assert.Equal(t, internalTest.want.QuotaMaxBytesIsValue, config.QuotaMaxBytesIsValue)
+
+			assert.Equal(t, internalTest.want.QuotaMaxItemBytes, config.QuotaMaxItemBytes)
+			assert.Equal(t, internalTest.want.QuotaMaxItemBytesIsValue, config.QuotaMaxItemBytesIsValue)
+
+			assert.Equal(t, internalTest.want.BlobDir, config.BlobDir)
+			assert.Equal(t, internalTest.want.BlobDirIsValue, config.BlobDirIsValue)
+
+			assert.Equal(t, internalTest.want.BlobMaxSize, config.BlobMaxSize)
+			assert.Equal(t, internalTest.want.BlobMaxSizeIsValue, config.BlobMaxSizeIsValue)
+
+			assert.Equal(t, internalTest.want.UploadDir, config.UploadDir)
+			assert.Equal(t, internalTest.want.UploadDirIsValue, config.UploadDirIsValue)
+
+			assert.Equal(t, internalTest.want.UploadTTL, config.UploadTTL)
+			assert.Equal(t, internalTest.want.UploadTTLIsValue, config.UploadTTLIsValue)
+
+			assert.Equal(t, internalTest.want.BlobS3Endpoint, config.BlobS3Endpoint)
+			assert.Equal(t, internalTest.want.BlobS3EndpointIsValue, config.BlobS3EndpointIsValue)
+
+			assert.Equal(t, internalTest.want.BlobS3Bucket, config.BlobS3Bucket)
+			assert.Equal(t, internalTest.want.BlobS3BucketIsValue, config.BlobS3BucketIsValue)
+
+			assert.Equal(t, internalTest.want.BlobS3Region, config.BlobS3Region)
+			assert.Equal(t, internalTest.want.BlobS3RegionIsValue, config.BlobS3RegionIsValue)
+
+			assert.Equal(t, internalTest.want.BlobS3AccessKey, config.BlobS3AccessKey)
+			assert.Equal(t, internalTest.want.BlobS3AccessKeyIsValue, config.BlobS3AccessKeyIsValue)
+
+			assert.Equal(t, internalTest.want.BlobS3SecretKey, config.BlobS3SecretKey)
+			assert.Equal(t, internalTest.want.BlobS3SecretKeyIsValue, config.BlobS3SecretKeyIsValue)
+
+			assert.Equal(t, internalTest.want.BlobS3Prefix, config.BlobS3Prefix)
+			assert.Equal(t, internalTest.want.BlobS3PrefixIsValue, config.BlobS3PrefixIsValue)
+
+			assert.Equal(t, internalTest.want.BlobS3PartSize, config.BlobS3PartSize)
+			assert.Equal(t, internalTest.want.BlobS3PartSizeIsValue, config.BlobS3PartSizeIsValue)
+
+			assert.Equal(t, internalTest.want.HashKeyPrevious, config.HashKeyPrevious)
+			assert.Equal(t, internalTest.want.HashKeyPreviousIsValue, config.HashKeyPreviousIsValue)
+
+			assert.Equal(t, internalTest.want.UnsealCheck, config.UnsealCheck)
+			assert.Equal(t, internalTest.want.UnsealCheckIsValue, config.UnsealCheckIsValue)
+
+			assert.Equal(t, internalTest.want.AdminToken, config.AdminToken)
+			assert.Equal(t, internalTest.want.AdminTokenIsValue, config.AdminTokenIsValue)
+
+			assert.Equal(t, internalTest.want.BackupPassphrase, config.BackupPassphrase)
+			assert.Equal(t, internalTest.want.BackupPassphraseIsValue, config.BackupPassphraseIsValue)
+
+			assert.Equal(t, internalTest.want.CacheSize, config.CacheSize)
+			assert.Equal(t, internalTest.want.CacheSizeIsValue, config.CacheSizeIsValue)
+
+			assert.Equal(t, internalTest.want.CacheTTL, config.CacheTTL)
+			assert.Equal(t, internalTest.want.CacheTTLIsValue, config.CacheTTLIsValue)
 		})
 	}
 }
@@ -128,8 +384,35 @@ func createTestsForGetConfigFlags() []testGetConfigFlags {
 				"-" + config.FlagServerAddress, "example.com:8080",
 				"-" + config.FlagDatabase, "database.one:8080",
 				"-" + config.FlagCryptoJWTKey, "secret-jwt-key",
+				"-" + config.FlagStorageFile, "/var/lib/goph-keeper.db",
+				"-" + config.FlagTombstoneRetention, "48h",
+				"-" + config.FlagRevisionLimit, "5",
+				"-" + config.FlagCacheTTL, "30s",
+				"-" + config.FlagCacheSize, "500",
+				"-" + config.FlagBackupPassphrase, "backup-secret",
+				"-" + config.FlagAdminToken, "admin-secret",
+				"-" + config.FlagUnsealCheck, "3:abcd",
+				"-" + config.FlagHashKeyPrevious, "old-hash-key",
+				"-" + config.FlagBlobS3PartSize, "8388608",
+				"-" + config.FlagBlobS3Prefix, "keeper/",
+				"-" + config.FlagBlobS3SecretKey, "secret",
+				"-" + config.FlagBlobS3AccessKey, "access",
+				"-" + config.FlagBlobS3Region, "eu-central-1",
+				"-" + config.FlagBlobS3Bucket, "vault",
+				"-" + config.FlagBlobS3Endpoint, "http://localhost:9000",
+				"-" + config.FlagUploadTTL, "2h",
+				"-" + config.FlagUploadDir, "/tmp/uploads",
+				"-" + config.FlagBlobMaxSize, "4096",
+				"-" + config.FlagBlobDir, "/tmp/blobs",
+				"-" + config.FlagQuotaMaxItemBytes, "1024",
+				"-" + config.FlagQuotaMaxBytes, "2048",
+				"-" + config.FlagQuotaMaxItems, "500",
+				"-" + config.FlagTrashRetention, "72h",
+				"-" + config.FlagRevisionMaxAge, "24h",
 			},
 			want: config.FlagsConfig{
+				StorageFile:          "/var/lib/goph-keeper.db",
+				StorageFileIsValue:   true,
 				HashKey:              "my-hash-key",
 				HashKeyIsValue:       true,
 				ServerAddress:        "example.com:8080",
@@ -138,6 +421,55 @@ func createTestsForGetConfigFlags() []testGetConfigFlags {
 				DatabaseIsValue:      true,
 				CryptoJWTKey:         "secret-jwt-key",
 				CryptoJWTKeyIsValue:  true,
+
+				TombstoneRetention:        48 * time.Hour,
+				TombstoneRetentionIsValue: true,
+				RevisionLimit:             5,
+				RevisionLimitIsValue:      true,
+				RevisionMaxAge:            24 * time.Hour,
+				RevisionMaxAgeIsValue:     true,
+				TrashRetention:            72 * time.Hour,
+				TrashRetentionIsValue:     true,
+				QuotaMaxItems:             500,
+				QuotaMaxItemsIsValue:      true,
+				QuotaMaxBytes:             2048,
+				QuotaMaxBytesIsValue:      true,
+				QuotaMaxItemBytes:         1024,
+				QuotaMaxItemBytesIsValue:  true,
+				BlobDir:                   "/tmp/blobs",
+				BlobDirIsValue:            true,
+				BlobMaxSize:               4096,
+				BlobMaxSizeIsValue:        true,
+				UploadDir:                 "/tmp/uploads",
+				UploadDirIsValue:          true,
+				UploadTTL:                 2 * time.Hour,
+				UploadTTLIsValue:          true,
+				BlobS3Endpoint:            "http://localhost:9000",
+				BlobS3EndpointIsValue:     true,
+				BlobS3Bucket:              "vault",
+				BlobS3BucketIsValue:       true,
+				BlobS3Region:              "eu-central-1",
+				BlobS3RegionIsValue:       true,
+				BlobS3AccessKey:           "access",
+				BlobS3AccessKeyIsValue:    true,
+				BlobS3SecretKey:           "secret",
+				BlobS3SecretKeyIsValue:    true,
+				BlobS3Prefix:              "keeper/",
+				BlobS3PrefixIsValue:       true,
+				BlobS3PartSize:            8388608,
+				BlobS3PartSizeIsValue:     true,
+				HashKeyPrevious:           "old-hash-key",
+				HashKeyPreviousIsValue:    true,
+				UnsealCheck:               "3:abcd",
+				UnsealCheckIsValue:        true,
+				AdminToken:                "admin-secret",
+				AdminTokenIsValue:         true,
+				BackupPassphrase:          "backup-secret",
+				BackupPassphraseIsValue:   true,
+				CacheSize:                 500,
+				CacheSizeIsValue:          true,
+				CacheTTL:                  30 * time.Second,
+				CacheTTLIsValue:           true,
 			},
 		},
 		{
@@ -147,6 +479,8 @@ func createTestsForGetConfigFlags() []testGetConfigFlags {
 				"-" + config.FlagCryptoJWTKey, "secret-jwt-key",
 			},
 			want: config.FlagsConfig{
+				StorageFile:          "",
+				StorageFileIsValue:   false,
 				HashKey:              "",
 				HashKeyIsValue:       false,
 				ServerAddress:        "example.com:8080",
@@ -155,12 +489,63 @@ func createTestsForGetConfigFlags() []testGetConfigFlags {
 				DatabaseIsValue:      false,
 				CryptoJWTKey:         "secret-jwt-key",
 				CryptoJWTKeyIsValue:  true,
+
+				TombstoneRetention:        0,
+				TombstoneRetentionIsValue: false,
+				RevisionLimit:             0,
+				RevisionLimitIsValue:      false,
+				RevisionMaxAge:            0,
+				RevisionMaxAgeIsValue:     false,
+				TrashRetention:            0,
+				TrashRetentionIsValue:     false,
+				QuotaMaxItems:             0,
+				QuotaMaxItemsIsValue:      false,
+				QuotaMaxBytes:             0,
+				QuotaMaxBytesIsValue:      false,
+				QuotaMaxItemBytes:         0,
+				QuotaMaxItemBytesIsValue:  false,
+				BlobDir:                   "",
+				BlobDirIsValue:            false,
+				BlobMaxSize:               0,
+				BlobMaxSizeIsValue:        false,
+				UploadDir:                 "",
+				UploadDirIsValue:          false,
+				UploadTTL:                 0,
+				UploadTTLIsValue:          false,
+				BlobS3Endpoint:            "",
+				BlobS3EndpointIsValue:     false,
+				BlobS3Bucket:              "",
+				BlobS3BucketIsValue:       false,
+				BlobS3Region:              "",
+				BlobS3RegionIsValue:       false,
+				BlobS3AccessKey:           "",
+				BlobS3AccessKeyIsValue:    false,
+				BlobS3SecretKey:           "",
+				BlobS3SecretKeyIsValue:    false,
+				BlobS3Prefix:              "",
+				BlobS3PrefixIsValue:       false,
+				BlobS3PartSize:            0,
+				BlobS3PartSizeIsValue:     false,
+				HashKeyPrevious:           "",
+				HashKeyPreviousIsValue:    false,
+				UnsealCheck:               "",
+				UnsealCheckIsValue:        false,
+				AdminToken:                "",
+				AdminTokenIsValue:         false,
+				BackupPassphrase:          "",
+				BackupPassphraseIsValue:   false,
+				CacheSize:                 0,
+				CacheSizeIsValue:          false,
+				CacheTTL:                  0,
+				CacheTTLIsValue:           false,
 			},
 		},
 		{
 			name: "empty values",
 			args: []string{},
 			want: config.FlagsConfig{
+				StorageFile:          "",
+				StorageFileIsValue:   false,
 				HashKey:              "",
 				HashKeyIsValue:       false,
 				ServerAddress:        "",
@@ -169,6 +554,55 @@ func createTestsForGetConfigFlags() []testGetConfigFlags {
 				DatabaseIsValue:      false,
 				CryptoJWTKey:         "",
 				CryptoJWTKeyIsValue:  false,
+
+				TombstoneRetention:        0,
+				TombstoneRetentionIsValue: false,
+				RevisionLimit:             0,
+				RevisionLimitIsValue:      false,
+				RevisionMaxAge:            0,
+				RevisionMaxAgeIsValue:     false,
+				TrashRetention:            0,
+				TrashRetentionIsValue:     false,
+				QuotaMaxItems:             0,
+				QuotaMaxItemsIsValue:      false,
+				QuotaMaxBytes:             0,
+				QuotaMaxBytesIsValue:      false,
+				QuotaMaxItemBytes:         0,
+				QuotaMaxItemBytesIsValue:  false,
+				BlobDir:                   "",
+				BlobDirIsValue:            false,
+				BlobMaxSize:               0,
+				BlobMaxSizeIsValue:        false,
+				UploadDir:                 "",
+				UploadDirIsValue:          false,
+				UploadTTL:                 0,
+				UploadTTLIsValue:          false,
+				BlobS3Endpoint:            "",
+				BlobS3EndpointIsValue:     false,
+				BlobS3Bucket:              "",
+				BlobS3BucketIsValue:       false,
+				BlobS3Region:              "",
+				BlobS3RegionIsValue:       false,
+				BlobS3AccessKey:           "",
+				BlobS3AccessKeyIsValue:    false,
+				BlobS3SecretKey:           "",
+				BlobS3SecretKeyIsValue:    false,
+				BlobS3Prefix:              "",
+				BlobS3PrefixIsValue:       false,
+				BlobS3PartSize:            0,
+				BlobS3PartSizeIsValue:     false,
+				HashKeyPrevious:           "",
+				HashKeyPreviousIsValue:    false,
+				UnsealCheck:               "",
+				UnsealCheckIsValue:        false,
+				AdminToken:                "",
+				AdminTokenIsValue:         false,
+				BackupPassphrase:          "",
+				BackupPassphraseIsValue:   false,
+				CacheSize:                 0,
+				CacheSizeIsValue:          false,
+				CacheTTL:                  0,
+				CacheTTLIsValue:           false,
 			},
 		},
 	}
@@ -203,6 +637,82 @@ func TestGetConfigFlags(t *testing.T) {
 
 			assert.Equal(t, internalTest.want.CryptoJWTKey, config.CryptoJWTKey)
 			assert.Equal(t, internalTest.want.CryptoJWTKeyIsValue, config.CryptoJWTKeyIsValue)
+
+			assert.Equal(t, internalTest.want.StorageFile, config.StorageFile)
+			assert.Equal(t, internalTest.want.StorageFileIsValue, config.StorageFileIsValue)
+
+			assert.Equal(t, internalTest.want.TombstoneRetention, config.TombstoneRetention)
+			assert.Equal(t,
+				internalTest.want.TombstoneRetentionIsValue, config.TombstoneRetentionIsValue)
+
+			assert.Equal(t, internalTest.want.RevisionLimit, config.RevisionLimit)
+			assert.Equal(t, internalTest.want.RevisionLimitIsValue, config.RevisionLimitIsValue)
+
+			assert.Equal(t, internalTest.want.RevisionMaxAge, config.RevisionMaxAge)
+			assert.Equal(t, internalTest.want.RevisionMaxAgeIsValue, config.RevisionMaxAgeIsValue)
+
+			assert.Equal(t, internalTest.want.TrashRetention, config.TrashRetention)
+			assert.Equal(t, internalTest.want.TrashRetentionIsValue, config.TrashRetentionIsValue)
+
+			assert.Equal(t, internalTest.want.QuotaMaxItems, config.QuotaMaxItems)
+			assert.Equal(t, internalTest.want.QuotaMaxItemsIsValue, config.QuotaMaxItemsIsValue)
+
+			assert.Equal(t, internalTest.want.QuotaMaxBytes, config.QuotaMaxBytes)
+			assert.Equal(t, internalTest.want.QuotaMaxBytesIsValue, config.QuotaMaxBytesIsValue)
+
+			assert.Equal(t, internalTest.want.QuotaMaxItemBytes, config.QuotaMaxItemBytes)
+			assert.Equal(t, internalTest.want.QuotaMaxItemBytesIsValue, config.QuotaMaxItemBytesIsValue)
+
+			assert.Equal(t, internalTest.want.BlobDir, config.BlobDir)
+			assert.Equal(t, internalTest.want.BlobDirIsValue, config.BlobDirIsValue)
+
+			assert.Equal(t, internalTest.want.BlobMaxSize, config.BlobMaxSize)
+			assert.Equal(t, internalTest.want.BlobMaxSizeIsValue, config.BlobMaxSizeIsValue)
+
+			assert.Equal(t, internalTest.want.UploadDir, config.UploadDir)
+			assert.Equal(t, internalTest.want.UploadDirIsValue, config.UploadDirIsValue)
+
+			assert.Equal(t, internalTest.want.UploadTTL, config.UploadTTL)
+			assert.Equal(t, internalTest.want.UploadTTLIsValue, config.UploadTTLIsValue)
+
+			assert.Equal(t, internalTest.want.BlobS3Endpoint, config.BlobS3Endpoint)
+			assert.Equal(t, internalTest.want.BlobS3EndpointIsValue, config.BlobS3EndpointIsValue)
+
+			assert.Equal(t, internalTest.want.BlobS3Bucket, config.BlobS3Bucket)
+			assert.Equal(t, internalTest.want.BlobS3BucketIsValue, config.BlobS3BucketIsValue)
+
+			assert.Equal(t, internalTest.want.BlobS3Region, config.BlobS3Region)
+			assert.Equal(t, internalTest.want.BlobS3RegionIsValue, config.BlobS3RegionIsValue)
+
+			assert.Equal(t, internalTest.want.BlobS3AccessKey, config.BlobS3AccessKey)
+			assert.Equal(t, internalTest.want.BlobS3AccessKeyIsValue, config.BlobS3AccessKeyIsValue)
+
+			assert.Equal(t, internalTest.want.BlobS3SecretKey, config.BlobS3SecretKey)
+			assert.Equal(t, internalTest.want.BlobS3SecretKeyIsValue, config.BlobS3SecretKeyIsValue)
+
+			assert.Equal(t, internalTest.want.BlobS3Prefix, config.BlobS3Prefix)
+			assert.Equal(t, internalTest.want.BlobS3PrefixIsValue, config.BlobS3PrefixIsValue)
+
+			assert.Equal(t, internalTest.want.BlobS3PartSize, config.BlobS3PartSize)
+			assert.Equal(t, internalTest.want.BlobS3PartSizeIsValue, config.BlobS3PartSizeIsValue)
+
+			assert.Equal(t, internalTest.want.HashKeyPrevious, config.HashKeyPrevious)
+			assert.Equal(t, internalTest.want.HashKeyPreviousIsValue, config.HashKeyPreviousIsValue)
+
+			assert.Equal(t, internalTest.want.UnsealCheck, config.UnsealCheck)
+			assert.Equal(t, internalTest.want.UnsealCheckIsValue, config.UnsealCheckIsValue)
+
+			assert.Equal(t, internalTest.want.AdminToken, config.AdminToken)
+			assert.Equal(t, internalTest.want.AdminTokenIsValue, config.AdminTokenIsValue)
+
+			assert.Equal(t, internalTest.want.BackupPassphrase, config.BackupPassphrase)
+			assert.Equal(t, internalTest.want.BackupPassphraseIsValue, config.BackupPassphraseIsValue)
+
+			assert.Equal(t, internalTest.want.CacheSize, config.CacheSize)
+			assert.Equal(t, internalTest.want.CacheSizeIsValue, config.CacheSizeIsValue)
+
+			assert.Equal(t, internalTest.want.CacheTTL, config.CacheTTL)
+			assert.Equal(t, internalTest.want.CacheTTLIsValue, config.CacheTTLIsValue)
 		})
 	}
 }
@@ -220,6 +730,31 @@ func TestCreateConfigDefault(t *testing.T) {
 	assert.Equal(t, config.DefaultHashKey, defaultConfig.HashKey)
 	assert.Equal(t, config.DefaultCryptoJWTKey, defaultConfig.CryptoJWTKey)
 	assert.Equal(t, config.DefaultDatabase, defaultConfig.Database)
+	assert.Equal(t, config.DefaultStorageFile, defaultConfig.StorageFile)
+	assert.Equal(t, config.DefaultTombstoneRetention, defaultConfig.TombstoneRetention)
+	assert.Equal(t, config.DefaultRevisionLimit, defaultConfig.RevisionLimit)
+	assert.Equal(t, config.DefaultRevisionMaxAge, defaultConfig.RevisionMaxAge)
+	assert.Equal(t, config.DefaultTrashRetention, defaultConfig.TrashRetention)
+	assert.Equal(t, config.DefaultQuotaMaxItems, defaultConfig.QuotaMaxItems)
+	assert.Equal(t, config.DefaultQuotaMaxBytes, defaultConfig.QuotaMaxBytes)
+	assert.Equal(t, config.DefaultQuotaMaxItemBytes, defaultConfig.QuotaMaxItemBytes)
+	assert.Equal(t, config.DefaultBlobDir, defaultConfig.BlobDir)
+	assert.Equal(t, config.DefaultBlobMaxSize, defaultConfig.BlobMaxSize)
+	assert.Equal(t, config.DefaultUploadDir, defaultConfig.UploadDir)
+	assert.Equal(t, config.DefaultUploadTTL, defaultConfig.UploadTTL)
+	assert.Equal(t, config.DefaultBlobS3Endpoint, defaultConfig.BlobS3Endpoint)
+	assert.Equal(t, config.DefaultBlobS3Bucket, defaultConfig.BlobS3Bucket)
+	assert.Equal(t, config.DefaultBlobS3Region, defaultConfig.BlobS3Region)
+	assert.Equal(t, config.DefaultBlobS3AccessKey, defaultConfig.BlobS3AccessKey)
+	assert.Equal(t, config.DefaultBlobS3SecretKey, defaultConfig.BlobS3SecretKey)
+	assert.Equal(t, config.DefaultBlobS3Prefix, defaultConfig.BlobS3Prefix)
+	assert.Equal(t, config.DefaultBlobS3PartSize, defaultConfig.BlobS3PartSize)
+	assert.Equal(t, config.DefaultHashKeyPrevious, defaultConfig.HashKeyPrevious)
+	assert.Equal(t, config.DefaultUnsealCheck, defaultConfig.UnsealCheck)
+	assert.Equal(t, config.DefaultAdminToken, defaultConfig.AdminToken)
+	assert.Equal(t, config.DefaultBackupPassphrase, defaultConfig.BackupPassphrase)
+	assert.Equal(t, config.DefaultCacheSize, defaultConfig.CacheSize)
+	assert.Equal(t, config.DefaultCacheTTL, defaultConfig.CacheTTL)
 }
 
 /*
@@ -238,6 +773,57 @@ func TestOverrideConfigFromEnvs(t *testing.T) {
 		ServerAddressIsValue: false,
 		HashKey:              "",
 		HashKeyIsValue:       false,
+		StorageFile:          "test-storage-file",
+		StorageFileIsValue:   true,
+
+		TombstoneRetention:        time.Hour,
+		TombstoneRetentionIsValue: true,
+		RevisionLimit:             3,
+		RevisionLimitIsValue:      true,
+		RevisionMaxAge:            0,
+		RevisionMaxAgeIsValue:     false,
+		TrashRetention:            0,
+		TrashRetentionIsValue:     false,
+		QuotaMaxItems:             0,
+		QuotaMaxItemsIsValue:      false,
+		QuotaMaxBytes:             0,
+		QuotaMaxBytesIsValue:      false,
+		QuotaMaxItemBytes:         0,
+		QuotaMaxItemBytesIsValue:  false,
+		BlobDir:                   "",
+		BlobDirIsValue:            false,
+		BlobMaxSize:               0,
+		BlobMaxSizeIsValue:        false,
+		UploadDir:                 "",
+		UploadDirIsValue:          false,
+		UploadTTL:                 0,
+		UploadTTLIsValue:          false,
+		BlobS3Endpoint:            "",
+		BlobS3EndpointIsValue:     false,
+		BlobS3Bucket:              "",
+		BlobS3BucketIsValue:       false,
+		BlobS3Region:              "",
+		BlobS3RegionIsValue:       false,
+		BlobS3AccessKey:           "",
+		BlobS3AccessKeyIsValue:    false,
+		BlobS3SecretKey:           "",
+		BlobS3SecretKeyIsValue:    false,
+		BlobS3Prefix:              "",
+		BlobS3PrefixIsValue:       false,
+		BlobS3PartSize:            0,
+		BlobS3PartSizeIsValue:     false,
+		HashKeyPrevious:           "",
+		HashKeyPreviousIsValue:    false,
+		UnsealCheck:               "",
+		UnsealCheckIsValue:        false,
+		AdminToken:                "",
+		AdminTokenIsValue:         false,
+		BackupPassphrase:          "",
+		BackupPassphraseIsValue:   false,
+		CacheSize:                 0,
+		CacheSizeIsValue:          false,
+		CacheTTL:                  0,
+		CacheTTLIsValue:           false,
 	}
 
 	defaultConfig := config.CreateConfigDefault().
@@ -248,6 +834,10 @@ func TestOverrideConfigFromEnvs(t *testing.T) {
 
 	assert.Equal(t, "test-crypto-jwt-key", defaultConfig.CryptoJWTKey)
 	assert.Equal(t, "test-database", defaultConfig.Database)
+	assert.Equal(t, "test-storage-file", defaultConfig.StorageFile)
+	assert.Equal(t, time.Hour, defaultConfig.TombstoneRetention)
+	assert.Equal(t, 3, defaultConfig.RevisionLimit)
+	assert.Equal(t, config.DefaultRevisionMaxAge, defaultConfig.RevisionMaxAge)
 }
 
 /*
@@ -266,6 +856,57 @@ func TestOverrideConfigFromFlags(t *testing.T) {
 		ServerAddressIsValue: false,
 		HashKey:              "",
 		HashKeyIsValue:       false,
+		StorageFile:          "test-storage-file",
+		StorageFileIsValue:   true,
+
+		TombstoneRetention:        time.Hour,
+		TombstoneRetentionIsValue: true,
+		RevisionLimit:             3,
+		RevisionLimitIsValue:      true,
+		RevisionMaxAge:            0,
+		RevisionMaxAgeIsValue:     false,
+		TrashRetention:            0,
+		TrashRetentionIsValue:     false,
+		QuotaMaxItems:             0,
+		QuotaMaxItemsIsValue:      false,
+		QuotaMaxBytes:             0,
+		QuotaMaxBytesIsValue:      false,
+		QuotaMaxItemBytes:         0,
+		QuotaMaxItemBytesIsValue:  false,
+		BlobDir:                   "",
+		BlobDirIsValue:            false,
+		BlobMaxSize:               0,
+		BlobMaxSizeIsValue:        false,
+		UploadDir:                 "",
+		UploadDirIsValue:          false,
+		UploadTTL:                 0,
+		UploadTTLIsValue:          false,
+		BlobS3Endpoint:            "",
+		BlobS3EndpointIsValue:     false,
+		BlobS3Bucket:              "",
+		BlobS3BucketIsValue:       false,
+		BlobS3Region:              "",
+		BlobS3RegionIsValue:       false,
+		BlobS3AccessKey:           "",
+		BlobS3AccessKeyIsValue:    false,
+		BlobS3SecretKey:           "",
+		BlobS3SecretKeyIsValue:    false,
+		BlobS3Prefix:              "",
+		BlobS3PrefixIsValue:       false,
+		BlobS3PartSize:            0,
+		BlobS3PartSizeIsValue:     false,
+		HashKeyPrevious:           "",
+		HashKeyPreviousIsValue:    false,
+		UnsealCheck:               "",
+		UnsealCheckIsValue:        false,
+		AdminToken:                "",
+		AdminTokenIsValue:         false,
+		BackupPassphrase:          "",
+		BackupPassphraseIsValue:   false,
+		CacheSize:                 0,
+		CacheSizeIsValue:          false,
+		CacheTTL:                  0,
+		CacheTTLIsValue:           false,
 	}
 
 	defaultConfig := config.CreateConfigDefault().
@@ -276,6 +917,10 @@ func TestOverrideConfigFromFlags(t *testing.T) {
 
 	assert.Equal(t, "test-crypto-jwt-key", defaultConfig.CryptoJWTKey)
 	assert.Equal(t, "test-database", defaultConfig.Database)
+	assert.Equal(t, "test-storage-file", defaultConfig.StorageFile)
+	assert.Equal(t, time.Hour, defaultConfig.TombstoneRetention)
+	assert.Equal(t, 3, defaultConfig.RevisionLimit)
+	assert.Equal(t, config.DefaultRevisionMaxAge, defaultConfig.RevisionMaxAge)
 }
 
 /*
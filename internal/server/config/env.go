@@ -3,6 +3,8 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"time"
 )
 
 // Ключи для поиска переменных окружения.
@@ -11,6 +13,32 @@ const (
 	EnvKeyHashKey       = "HASH_KEY"
 	EnvKeyCryptoJWTKey  = "CRYPTO_JWT_KEY"
 	EnvKeyDatabase      = "DATABASE"
+	EnvKeyStorageFile   = "STORAGE_FILE"
+
+	EnvKeyTombstoneRetention = "TOMBSTONE_RETENTION"
+	EnvKeyRevisionLimit      = "REVISION_LIMIT"
+	EnvKeyRevisionMaxAge     = "REVISION_MAX_AGE"
+	EnvKeyTrashRetention     = "TRASH_RETENTION"
+	EnvKeyQuotaMaxItems      = "QUOTA_MAX_ITEMS"
+	EnvKeyQuotaMaxBytes      = "QUOTA_MAX_BYTES"
+	EnvKeyQuotaMaxItemBytes  = "QUOTA_MAX_ITEM_BYTES"
+	EnvKeyBlobDir            = "BLOB_DIR"
+	EnvKeyBlobMaxSize        = "BLOB_MAX_SIZE"
+	EnvKeyUploadDir          = "UPLOAD_DIR"
+	EnvKeyUploadTTL          = "UPLOAD_TTL"
+	EnvKeyBlobS3Endpoint     = "BLOB_S3_ENDPOINT"
+	EnvKeyBlobS3Bucket       = "BLOB_S3_BUCKET"
+	EnvKeyBlobS3Region       = "BLOB_S3_REGION"
+	EnvKeyBlobS3AccessKey    = "BLOB_S3_ACCESS_KEY"
+	EnvKeyBlobS3SecretKey    = "BLOB_S3_SECRET_KEY"
+	EnvKeyBlobS3Prefix       = "BLOB_S3_PREFIX"
+	EnvKeyBlobS3PartSize     = "BLOB_S3_PART_SIZE"
+	EnvKeyHashKeyPrevious    = "HASH_KEY_PREVIOUS"
+	EnvKeyUnsealCheck        = "UNSEAL_CHECK"
+	EnvKeyAdminToken         = "ADMIN_TOKEN"
+	EnvKeyBackupPassphrase   = "BACKUP_PASSPHRASE"
+	EnvKeyCacheSize          = "CACHE_SIZE"
+	EnvKeyCacheTTL           = "CACHE_TTL"
 )
 
 // EnvsConfig - структура, содержащая основные переменные окружения для приложения.
@@ -19,10 +47,61 @@ type EnvsConfig struct {
 	HashKey              string // ключ хэширования
 	ServerAddress        string // адрес сервера
 	Database             string // строка подключения к базе данных
+	StorageFile          string // путь до файла встроенного хранилища
 	CryptoJWTKeyIsValue  bool
 	HashKeyIsValue       bool
 	ServerAddressIsValue bool
 	DatabaseIsValue      bool
+	StorageFileIsValue   bool
+
+	TombstoneRetention        time.Duration // время хранения отметок об удалении
+	TombstoneRetentionIsValue bool
+	RevisionLimit             int // количество прежних версий записи
+	RevisionLimitIsValue      bool
+	RevisionMaxAge            time.Duration // время хранения прежних версий записи
+	RevisionMaxAgeIsValue     bool
+	TrashRetention            time.Duration // время хранения записей в корзине
+	TrashRetentionIsValue     bool
+	QuotaMaxItems             int64 // количество записей пользователя
+	QuotaMaxItemsIsValue      bool
+	QuotaMaxBytes             int64 // суммарный размер записей пользователя
+	QuotaMaxBytesIsValue      bool
+	QuotaMaxItemBytes         int64 // размер одной записи
+	QuotaMaxItemBytesIsValue  bool
+	BlobDir                   string // каталог содержимого бинарных записей
+	BlobDirIsValue            bool
+	BlobMaxSize               int64 // максимальный размер содержимого бинарной записи
+	BlobMaxSizeIsValue        bool
+	UploadDir                 string // каталог незавершённых загрузок
+	UploadDirIsValue          bool
+	UploadTTL                 time.Duration // время жизни незавершённой загрузки
+	UploadTTLIsValue          bool
+	BlobS3Endpoint            string // адрес S3-совместимого хранилища содержимого
+	BlobS3EndpointIsValue     bool
+	BlobS3Bucket              string // корзина S3 для содержимого
+	BlobS3BucketIsValue       bool
+	BlobS3Region              string // регион S3
+	BlobS3RegionIsValue       bool
+	BlobS3AccessKey           string // ключ доступа S3
+	BlobS3AccessKeyIsValue    bool
+	BlobS3SecretKey           string // секретный ключ S3
+	BlobS3SecretKeyIsValue    bool
+	BlobS3Prefix              string // префикс ключей содержимого в корзине
+	BlobS3PrefixIsValue       bool
+	BlobS3PartSize            int64 // размер части составной загрузки S3
+	BlobS3PartSizeIsValue     bool
+	HashKeyPrevious           string // прежний ключ хэширования
+	HashKeyPreviousIsValue    bool
+	UnsealCheck               string // контрольное значение мастер-ключа
+	UnsealCheckIsValue        bool
+	AdminToken                string // токен административных запросов
+	AdminTokenIsValue         bool
+	BackupPassphrase          string // пароль шифрования резервных копий
+	BackupPassphraseIsValue   bool
+	CacheSize                 int // размер кэша хранилища в записях
+	CacheSizeIsValue          bool
+	CacheTTL                  time.Duration // время жизни записи кэша хранилища
+	CacheTTLIsValue           bool
 }
 
 // EnvReader — интерфейс для чтения переменных окружения.
@@ -39,6 +118,57 @@ func GetConfigEnvs(getenv EnvReader) *EnvsConfig {
 		ServerAddressIsValue: false,
 		Database:             "",
 		DatabaseIsValue:      false,
+		StorageFile:          "",
+		StorageFileIsValue:   false,
+
+		TombstoneRetention:        0,
+		TombstoneRetentionIsValue: false,
+		RevisionLimit:             0,
+		RevisionLimitIsValue:      false,
+		RevisionMaxAge:            0,
+		RevisionMaxAgeIsValue:     false,
+		TrashRetention:            0,
+		TrashRetentionIsValue:     false,
+		QuotaMaxItems:             0,
+		QuotaMaxItemsIsValue:      false,
+		QuotaMaxBytes:             0,
+		QuotaMaxBytesIsValue:      false,
+		QuotaMaxItemBytes:         0,
+		QuotaMaxItemBytesIsValue:  false,
+		BlobDir:                   "",
+		BlobDirIsValue:            false,
+		BlobMaxSize:               0,
+		BlobMaxSizeIsValue:        false,
+		UploadDir:                 "",
+		UploadDirIsValue:          false,
+		UploadTTL:                 0,
+		UploadTTLIsValue:          false,
+		BlobS3Endpoint:            "",
+		BlobS3EndpointIsValue:     false,
+		BlobS3Bucket:              "",
+		BlobS3BucketIsValue:       false,
+		BlobS3Region:              "",
+		BlobS3RegionIsValue:       false,
+		BlobS3AccessKey:           "",
+		BlobS3AccessKeyIsValue:    false,
+		BlobS3SecretKey:           "",
+		BlobS3SecretKeyIsValue:    false,
+		BlobS3Prefix:              "",
+		BlobS3PrefixIsValue:       false,
+		BlobS3PartSize:            0,
+		BlobS3PartSizeIsValue:     false,
+		HashKeyPrevious:           "",
+		HashKeyPreviousIsValue:    false,
+		UnsealCheck:               "",
+		UnsealCheckIsValue:        false,
+		AdminToken:                "",
+		AdminTokenIsValue:         false,
+		BackupPassphrase:          "",
+		BackupPassphraseIsValue:   false,
+		CacheSize:                 0,
+		CacheSizeIsValue:          false,
+		CacheTTL:                  0,
+		CacheTTLIsValue:           false,
 	}
 
 	envCryptoKey, envIsValue := getenv(EnvKeyCryptoJWTKey)
@@ -65,6 +195,180 @@ func GetConfigEnvs(getenv EnvReader) *EnvsConfig {
 		config.DatabaseIsValue = true
 	}
 
+	envStorageFile, envIsValue := getenv(EnvKeyStorageFile)
+	if envIsValue && envStorageFile != "" {
+		config.StorageFile = envStorageFile
+		config.StorageFileIsValue = true
+	}
+
+	envRetention, envIsValue := getenv(EnvKeyTombstoneRetention)
+	if envIsValue && envRetention != "" {
+		if retention, err := time.ParseDuration(envRetention); err == nil {
+			config.TombstoneRetention = retention
+			config.TombstoneRetentionIsValue = true
+		}
+	}
+
+	envRevisionLimit, envIsValue := getenv(EnvKeyRevisionLimit)
+	if envIsValue && envRevisionLimit != "" {
+		if limit, err := strconv.Atoi(envRevisionLimit); err == nil {
+			config.RevisionLimit = limit
+			config.RevisionLimitIsValue = true
+		}
+	}
+
+	envRevisionMaxAge, envIsValue := getenv(EnvKeyRevisionMaxAge)
+	if envIsValue && envRevisionMaxAge != "" {
+		if maxAge, err := time.ParseDuration(envRevisionMaxAge); err == nil {
+			config.RevisionMaxAge = maxAge
+			config.RevisionMaxAgeIsValue = true
+		}
+	}
+
+	envTrashRetention, envIsValue := getenv(EnvKeyTrashRetention)
+	if envIsValue && envTrashRetention != "" {
+		if value, err := time.ParseDuration(envTrashRetention); err == nil {
+			config.TrashRetention = value
+			config.TrashRetentionIsValue = true
+		}
+	}
+
+	envQuotaMaxItems, envIsValue := getenv(EnvKeyQuotaMaxItems)
+	if envIsValue && envQuotaMaxItems != "" {
+		if value, err := strconv.ParseInt(envQuotaMaxItems, 10, 64); err == nil {
+			config.QuotaMaxItems = value
+			config.QuotaMaxItemsIsValue = true
+		}
+	}
+
+	envQuotaMaxBytes, envIsValue := getenv(EnvKeyQuotaMaxBytes)
+	if envIsValue && envQuotaMaxBytes != "" {
+		if value, err := strconv.ParseInt(envQuotaMaxBytes, 10, 64); err == nil {
+			config.QuotaMaxBytes = value
+			config.QuotaMaxBytesIsValue = true
+		}
+	}
+
+	envQuotaMaxItemBytes, envIsValue := getenv(EnvKeyQuotaMaxItemBytes)
+	if envIsValue && envQuotaMaxItemBytes != "" {
+		if value, err := strconv.ParseInt(envQuotaMaxItemBytes, 10, 64); err == nil {
+			config.QuotaMaxItemBytes = value
+			config.QuotaMaxItemBytesIsValue = true
+		}
+	}
+
+	envBlobDir, envIsValue := getenv(EnvKeyBlobDir)
+	if envIsValue && envBlobDir != "" {
+		config.BlobDir = envBlobDir
+		config.BlobDirIsValue = true
+	}
+
+	envBlobMaxSize, envIsValue := getenv(EnvKeyBlobMaxSize)
+	if envIsValue && envBlobMaxSize != "" {
+		if value, err := strconv.ParseInt(envBlobMaxSize, 10, 64); err == nil {
+			config.BlobMaxSize = value
+			config.BlobMaxSizeIsValue = true
+		}
+	}
+
+	envUploadDir, envIsValue := getenv(EnvKeyUploadDir)
+	if envIsValue && envUploadDir != "" {
+		config.UploadDir = envUploadDir
+		config.UploadDirIsValue = true
+	}
+
+	envUploadTTL, envIsValue := getenv(EnvKeyUploadTTL)
+	if envIsValue && envUploadTTL != "" {
+		if value, err := time.ParseDuration(envUploadTTL); err == nil {
+			config.UploadTTL = value
+			config.UploadTTLIsValue = true
+		}
+	}
+
+	envBlobS3Endpoint, envIsValue := getenv(EnvKeyBlobS3Endpoint)
+	if envIsValue && envBlobS3Endpoint != "" {
+		config.BlobS3Endpoint = envBlobS3Endpoint
+		config.BlobS3EndpointIsValue = true
+	}
+
+	envBlobS3Bucket, envIsValue := getenv(EnvKeyBlobS3Bucket)
+	if envIsValue && envBlobS3Bucket != "" {
+		config.BlobS3Bucket = envBlobS3Bucket
+		config.BlobS3BucketIsValue = true
+	}
+
+	envBlobS3Region, envIsValue := getenv(EnvKeyBlobS3Region)
+	if envIsValue && envBlobS3Region != "" {
+		config.BlobS3Region = envBlobS3Region
+		config.BlobS3RegionIsValue = true
+	}
+
+	envBlobS3AccessKey, envIsValue := getenv(EnvKeyBlobS3AccessKey)
+	if envIsValue && envBlobS3AccessKey != "" {
+		config.BlobS3AccessKey = envBlobS3AccessKey
+		config.BlobS3AccessKeyIsValue = true
+	}
+
+	envBlobS3SecretKey, envIsValue := getenv(EnvKeyBlobS3SecretKey)
+	if envIsValue && envBlobS3SecretKey != "" {
+		config.BlobS3SecretKey = envBlobS3SecretKey
+		config.BlobS3SecretKeyIsValue = true
+	}
+
+	envBlobS3Prefix, envIsValue := getenv(EnvKeyBlobS3Prefix)
+	if envIsValue && envBlobS3Prefix != "" {
+		config.BlobS3Prefix = envBlobS3Prefix
+		config.BlobS3PrefixIsValue = true
+	}
+
+	envBlobS3PartSize, envIsValue := getenv(EnvKeyBlobS3PartSize)
+	if envIsValue && envBlobS3PartSize != "" {
+		if value, err := strconv.ParseInt(envBlobS3PartSize, 10, 64); err == nil {
+			config.BlobS3PartSize = value
+			config.BlobS3PartSizeIsValue = true
+		}
+	}
+
+	envHashKeyPrevious, envIsValue := getenv(EnvKeyHashKeyPrevious)
+	if envIsValue && envHashKeyPrevious != "" {
+		config.HashKeyPrevious = envHashKeyPrevious
+		config.HashKeyPreviousIsValue = true
+	}
+
+	envUnsealCheck, envIsValue := getenv(EnvKeyUnsealCheck)
+	if envIsValue && envUnsealCheck != "" {
+		config.UnsealCheck = envUnsealCheck
+		config.UnsealCheckIsValue = true
+	}
+
+	envAdminToken, envIsValue := getenv(EnvKeyAdminToken)
+	if envIsValue && envAdminToken != "" {
+		config.AdminToken = envAdminToken
+		config.AdminTokenIsValue = true
+	}
+
+	envBackupPassphrase, envIsValue := getenv(EnvKeyBackupPassphrase)
+	if envIsValue && envBackupPassphrase != "" {
+		config.BackupPassphrase = envBackupPassphrase
+		config.BackupPassphraseIsValue = true
+	}
+
+	envCacheSize, envIsValue := getenv(EnvKeyCacheSize)
+	if envIsValue && envCacheSize != "" {
+		if value, err := strconv.Atoi(envCacheSize); err == nil {
+			config.CacheSize = value
+			config.CacheSizeIsValue = true
+		}
+	}
+
+	envCacheTTL, envIsValue := getenv(EnvKeyCacheTTL)
+	if envIsValue && envCacheTTL != "" {
+		if value, err := time.ParseDuration(envCacheTTL); err == nil {
+			config.CacheTTL = value
+			config.CacheTTLIsValue = true
+		}
+	}
+
 	return config
 }
 
@@ -99,5 +403,105 @@ func (c *Config) OverrideConfigFromEnvs(conf *EnvsConfig) *Config {
 		c.Database = conf.Database
 	}
 
+	if conf.StorageFileIsValue {
+		c.StorageFile = conf.StorageFile
+	}
+
+	if conf.TombstoneRetentionIsValue {
+		c.TombstoneRetention = conf.TombstoneRetention
+	}
+
+	if conf.RevisionLimitIsValue {
+		c.RevisionLimit = conf.RevisionLimit
+	}
+
+	if conf.RevisionMaxAgeIsValue {
+		c.RevisionMaxAge = conf.RevisionMaxAge
+	}
+
+	if conf.TrashRetentionIsValue {
+		c.TrashRetention = conf.TrashRetention
+	}
+
+	if conf.QuotaMaxItemsIsValue {
+		c.QuotaMaxItems = conf.QuotaMaxItems
+	}
+
+	if conf.QuotaMaxBytesIsValue {
+		c.QuotaMaxBytes = conf.QuotaMaxBytes
+	}
+
+	if conf.QuotaMaxItemBytesIsValue {
+		c.QuotaMaxItemBytes = conf.QuotaMaxItemBytes
+	}
+
+	if conf.BlobDirIsValue {
+		c.BlobDir = conf.BlobDir
+	}
+
+	if conf.BlobMaxSizeIsValue {
+		c.BlobMaxSize = conf.BlobMaxSize
+	}
+
+	if conf.UploadDirIsValue {
+		c.UploadDir = conf.UploadDir
+	}
+
+	if conf.UploadTTLIsValue {
+		c.UploadTTL = conf.UploadTTL
+	}
+
+	if conf.BlobS3EndpointIsValue {
+		c.BlobS3Endpoint = conf.BlobS3Endpoint
+	}
+
+	if conf.BlobS3BucketIsValue {
+		c.BlobS3Bucket = conf.BlobS3Bucket
+	}
+
+	if conf.BlobS3RegionIsValue {
+		c.BlobS3Region = conf.BlobS3Region
+	}
+
+	if conf.BlobS3AccessKeyIsValue {
+		c.BlobS3AccessKey = conf.BlobS3AccessKey
+	}
+
+	if conf.BlobS3SecretKeyIsValue {
+		c.BlobS3SecretKey = conf.BlobS3SecretKey
+	}
+
+	if conf.BlobS3PrefixIsValue {
+		c.BlobS3Prefix = conf.BlobS3Prefix
+	}
+
+	if conf.BlobS3PartSizeIsValue {
+		c.BlobS3PartSize = conf.BlobS3PartSize
+	}
+
+	if conf.HashKeyPreviousIsValue {
+		c.HashKeyPrevious = conf.HashKeyPrevious
+	}
+
+	if conf.UnsealCheckIsValue {
+		c.UnsealCheck = conf.UnsealCheck
+	}
+
+	if conf.AdminTokenIsValue {
+		c.AdminToken = conf.AdminToken
+	}
+
+	if conf.BackupPassphraseIsValue {
+		c.BackupPassphrase = conf.BackupPassphrase
+	}
+
+	if conf.CacheSizeIsValue {
+		c.CacheSize = conf.CacheSize
+	}
+
+	if conf.CacheTTLIsValue {
+		c.CacheTTL = conf.CacheTTL
+	}
+
 	return c
 }
@@ -5,6 +5,8 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 )
 
 // Ключи для поиска флагов.
@@ -13,11 +15,63 @@ const (
 	FlagHashKey       = "hash-key"
 	FlagCryptoJWTKey  = "crypto-jwt-key"
 	FlagDatabase      = "database"
+	FlagStorageFile   = "storage-file"
+
+	FlagTombstoneRetention = "tombstone-retention"
+	FlagRevisionLimit      = "revision-limit"
+	FlagRevisionMaxAge     = "revision-max-age"
+	FlagTrashRetention     = "trash-retention"
+	FlagQuotaMaxItems      = "quota-max-items"
+	FlagQuotaMaxBytes      = "quota-max-bytes"
+	FlagQuotaMaxItemBytes  = "quota-max-item-bytes"
+	FlagBlobDir            = "blob-dir"
+	FlagBlobMaxSize        = "blob-max-size"
+	FlagUploadDir          = "upload-dir"
+	FlagUploadTTL          = "upload-ttl"
+	FlagBlobS3Endpoint     = "blob-s3-endpoint"
+	FlagBlobS3Bucket       = "blob-s3-bucket"
+	FlagBlobS3Region       = "blob-s3-region"
+	FlagBlobS3AccessKey    = "blob-s3-access-key"
+	FlagBlobS3SecretKey    = "blob-s3-secret-key"
+	FlagBlobS3Prefix       = "blob-s3-prefix"
+	FlagBlobS3PartSize     = "blob-s3-part-size"
+	FlagHashKeyPrevious    = "hash-key-previous"
+	FlagUnsealCheck        = "unseal-check"
+	FlagAdminToken         = "admin-token"
+	FlagBackupPassphrase   = "backup-passphrase"
+	FlagCacheSize          = "cache-size"
+	FlagCacheTTL           = "cache-ttl"
 
 	DescriptionServerAddress = "HTTP server run address"
 	DescriptionHashKey       = "hash key"
 	DescriptionCryptoJWTKey  = "crypto key for JWT"
 	DescriptionDatabase      = "connection string for database"
+	DescriptionStorageFile   = "path to embedded storage file (used when database is not set)"
+
+	DescriptionTombstoneRetention = "how long to keep deletion markers for sync, e.g. 720h (0 keeps forever)"
+	DescriptionRevisionLimit      = "how many previous versions of each item to keep (0 disables history)"
+	DescriptionRevisionMaxAge     = "how long to keep previous versions of items, e.g. 2160h (0 keeps forever)"
+	DescriptionTrashRetention     = "how long deleted items stay in trash before purge, e.g. 720h (0 keeps forever)"
+	DescriptionQuotaMaxItems      = "max number of items per user (0 is unlimited)"
+	DescriptionQuotaMaxBytes      = "max total size of items per user in bytes (0 is unlimited)"
+	DescriptionQuotaMaxItemBytes  = "max size of a single item in bytes (0 is unlimited)"
+	DescriptionBlobDir            = "Path to directory for binary item contents"
+	DescriptionBlobMaxSize        = "Max size of binary item content in bytes (0 - unlimited)"
+	DescriptionUploadDir          = "Path to directory for unfinished resumable uploads"
+	DescriptionUploadTTL          = "Time after last activity to expire resumable upload"
+	DescriptionBlobS3Endpoint     = "S3-compatible endpoint for binary item contents (empty - local directory)"
+	DescriptionBlobS3Bucket       = "S3 bucket for binary item contents"
+	DescriptionBlobS3Region       = "S3 region"
+	DescriptionBlobS3AccessKey    = "S3 access key"
+	DescriptionBlobS3SecretKey    = "S3 secret key"
+	DescriptionBlobS3Prefix       = "Key prefix for binary item contents in S3 bucket"
+	DescriptionBlobS3PartSize     = "S3 multipart upload part size in bytes"
+	DescriptionHashKeyPrevious    = "previous hash key (master key rotation)"
+	DescriptionUnsealCheck        = "unseal check value printed by 'seal init' (enables sealed mode)"
+	DescriptionAdminToken         = "token for administrative endpoints such as /sys/backup (empty disables them)"
+	DescriptionBackupPassphrase   = "passphrase to encrypt backups with (empty - backups are not encrypted)"
+	DescriptionCacheSize          = "storage cache size in items (0 - disabled)"
+	DescriptionCacheTTL           = "storage cache entry lifetime (0 - unlimited)"
 )
 
 // FlagsConfig - структура, содержащая основные переменные окружения для приложения.
@@ -26,10 +80,61 @@ type FlagsConfig struct {
 	HashKey              string // ключ хэширования
 	ServerAddress        string // адрес сервера
 	Database             string // строка подключения к базе данных
+	StorageFile          string // путь до файла встроенного хранилища
 	CryptoJWTKeyIsValue  bool
 	HashKeyIsValue       bool
 	ServerAddressIsValue bool
 	DatabaseIsValue      bool
+	StorageFileIsValue   bool
+
+	TombstoneRetention        time.Duration // время хранения отметок об удалении
+	TombstoneRetentionIsValue bool
+	RevisionLimit             int // количество прежних версий записи
+	RevisionLimitIsValue      bool
+	RevisionMaxAge            time.Duration // время хранения прежних версий записи
+	RevisionMaxAgeIsValue     bool
+	TrashRetention            time.Duration // время хранения записей в корзине
+	TrashRetentionIsValue     bool
+	QuotaMaxItems             int64 // количество записей пользователя
+	QuotaMaxItemsIsValue      bool
+	QuotaMaxBytes             int64 // суммарный размер записей пользователя
+	QuotaMaxBytesIsValue      bool
+	QuotaMaxItemBytes         int64 // размер одной записи
+	QuotaMaxItemBytesIsValue  bool
+	BlobDir                   string // каталог содержимого бинарных записей
+	BlobDirIsValue            bool
+	BlobMaxSize               int64 // максимальный размер содержимого бинарной записи
+	BlobMaxSizeIsValue        bool
+	UploadDir                 string // каталог незавершённых загрузок
+	UploadDirIsValue          bool
+	UploadTTL                 time.Duration // время жизни незавершённой загрузки
+	UploadTTLIsValue          bool
+	BlobS3Endpoint            string // адрес S3-совместимого хранилища содержимого
+	BlobS3EndpointIsValue     bool
+	BlobS3Bucket              string // корзина S3 для содержимого
+	BlobS3BucketIsValue       bool
+	BlobS3Region              string // регион S3
+	BlobS3RegionIsValue       bool
+	BlobS3AccessKey           string // ключ доступа S3
+	BlobS3AccessKeyIsValue    bool
+	BlobS3SecretKey           string // секретный ключ S3
+	BlobS3SecretKeyIsValue    bool
+	BlobS3Prefix              string // префикс ключей содержимого в корзине
+	BlobS3PrefixIsValue       bool
+	BlobS3PartSize            int64 // размер части составной загрузки S3
+	BlobS3PartSizeIsValue     bool
+	HashKeyPrevious           string // прежний ключ хэширования
+	HashKeyPreviousIsValue    bool
+	UnsealCheck               string // контрольное значение мастер-ключа
+	UnsealCheckIsValue        bool
+	AdminToken                string // токен административных запросов
+	AdminTokenIsValue         bool
+	BackupPassphrase          string // пароль шифрования резервных копий
+	BackupPassphraseIsValue   bool
+	CacheSize                 int // размер кэша хранилища в записях
+	CacheSizeIsValue          bool
+	CacheTTL                  time.Duration // время жизни записи кэша хранилища
+	CacheTTLIsValue           bool
 }
 
 // GetConfigFlags получает конфиг из указанных аргументов.
@@ -43,12 +148,88 @@ func GetConfigFlags(flagSet *flag.FlagSet, args []string) (*FlagsConfig, error)
 		ServerAddressIsValue: false,
 		Database:             "",
 		DatabaseIsValue:      false,
+		StorageFile:          "",
+		StorageFileIsValue:   false,
+
+		TombstoneRetention:        0,
+		TombstoneRetentionIsValue: false,
+		RevisionLimit:             0,
+		RevisionLimitIsValue:      false,
+		RevisionMaxAge:            0,
+		RevisionMaxAgeIsValue:     false,
+		TrashRetention:            0,
+		TrashRetentionIsValue:     false,
+		QuotaMaxItems:             0,
+		QuotaMaxItemsIsValue:      false,
+		QuotaMaxBytes:             0,
+		QuotaMaxBytesIsValue:      false,
+		QuotaMaxItemBytes:         0,
+		QuotaMaxItemBytesIsValue:  false,
+		BlobDir:                   "",
+		BlobDirIsValue:            false,
+		BlobMaxSize:               0,
+		BlobMaxSizeIsValue:        false,
+		UploadDir:                 "",
+		UploadDirIsValue:          false,
+		UploadTTL:                 0,
+		UploadTTLIsValue:          false,
+		BlobS3Endpoint:            "",
+		BlobS3EndpointIsValue:     false,
+		BlobS3Bucket:              "",
+		BlobS3BucketIsValue:       false,
+		BlobS3Region:              "",
+		BlobS3RegionIsValue:       false,
+		BlobS3AccessKey:           "",
+		BlobS3AccessKeyIsValue:    false,
+		BlobS3SecretKey:           "",
+		BlobS3SecretKeyIsValue:    false,
+		BlobS3Prefix:              "",
+		BlobS3PrefixIsValue:       false,
+		BlobS3PartSize:            0,
+		BlobS3PartSizeIsValue:     false,
+		HashKeyPrevious:           "",
+		HashKeyPreviousIsValue:    false,
+		UnsealCheck:               "",
+		UnsealCheckIsValue:        false,
+		AdminToken:                "",
+		AdminTokenIsValue:         false,
+		BackupPassphrase:          "",
+		BackupPassphraseIsValue:   false,
+		CacheSize:                 0,
+		CacheSizeIsValue:          false,
+		CacheTTL:                  0,
+		CacheTTLIsValue:           false,
 	}
 
 	argCryptoKey := flagSet.String(FlagCryptoJWTKey, "", DescriptionCryptoJWTKey)
 	argHashKey := flagSet.String(FlagHashKey, "", DescriptionHashKey)
 	argAddress := flagSet.String(FlagServerAddress, "", DescriptionServerAddress)
 	argDatabase := flagSet.String(FlagDatabase, "", DescriptionDatabase)
+	argStorageFile := flagSet.String(FlagStorageFile, "", DescriptionStorageFile)
+	argRetention := flagSet.String(FlagTombstoneRetention, "", DescriptionTombstoneRetention)
+	argRevisionLimit := flagSet.String(FlagRevisionLimit, "", DescriptionRevisionLimit)
+	argRevisionMaxAge := flagSet.String(FlagRevisionMaxAge, "", DescriptionRevisionMaxAge)
+	argTrashRetention := flagSet.String(FlagTrashRetention, "", DescriptionTrashRetention)
+	argQuotaMaxItems := flagSet.String(FlagQuotaMaxItems, "", DescriptionQuotaMaxItems)
+	argQuotaMaxBytes := flagSet.String(FlagQuotaMaxBytes, "", DescriptionQuotaMaxBytes)
+	argQuotaMaxItemBytes := flagSet.String(FlagQuotaMaxItemBytes, "", DescriptionQuotaMaxItemBytes)
+	argBlobDir := flagSet.String(FlagBlobDir, "", DescriptionBlobDir)
+	argBlobMaxSize := flagSet.String(FlagBlobMaxSize, "", DescriptionBlobMaxSize)
+	argUploadDir := flagSet.String(FlagUploadDir, "", DescriptionUploadDir)
+	argUploadTTL := flagSet.String(FlagUploadTTL, "", DescriptionUploadTTL)
+	argBlobS3Endpoint := flagSet.String(FlagBlobS3Endpoint, "", DescriptionBlobS3Endpoint)
+	argBlobS3Bucket := flagSet.String(FlagBlobS3Bucket, "", DescriptionBlobS3Bucket)
+	argBlobS3Region := flagSet.String(FlagBlobS3Region, "", DescriptionBlobS3Region)
+	argBlobS3AccessKey := flagSet.String(FlagBlobS3AccessKey, "", DescriptionBlobS3AccessKey)
+	argBlobS3SecretKey := flagSet.String(FlagBlobS3SecretKey, "", DescriptionBlobS3SecretKey)
+	argBlobS3Prefix := flagSet.String(FlagBlobS3Prefix, "", DescriptionBlobS3Prefix)
+	argBlobS3PartSize := flagSet.String(FlagBlobS3PartSize, "", DescriptionBlobS3PartSize)
+	argHashKeyPrevious := flagSet.String(FlagHashKeyPrevious, "", DescriptionHashKeyPrevious)
+	argUnsealCheck := flagSet.String(FlagUnsealCheck, "", DescriptionUnsealCheck)
+	argAdminToken := flagSet.String(FlagAdminToken, "", DescriptionAdminToken)
+	argBackupPassphrase := flagSet.String(FlagBackupPassphrase, "", DescriptionBackupPassphrase)
+	argCacheSize := flagSet.String(FlagCacheSize, "", DescriptionCacheSize)
+	argCacheTTL := flagSet.String(FlagCacheTTL, "", DescriptionCacheTTL)
 
 	if err := flagSet.Parse(args); err != nil {
 		return nil, fmt.Errorf("parse argument %w", err)
@@ -74,14 +255,204 @@ func GetConfigFlags(flagSet *flag.FlagSet, args []string) (*FlagsConfig, error)
 		config.DatabaseIsValue = true
 	}
 
+	if argStorageFile != nil && *argStorageFile != "" {
+		config.StorageFile = *argStorageFile
+		config.StorageFileIsValue = true
+	}
+
+	if argRetention != nil && *argRetention != "" {
+		retention, err := time.ParseDuration(*argRetention)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s %w", FlagTombstoneRetention, err)
+		}
+
+		config.TombstoneRetention = retention
+		config.TombstoneRetentionIsValue = true
+	}
+
+	if argRevisionLimit != nil && *argRevisionLimit != "" {
+		limit, err := strconv.Atoi(*argRevisionLimit)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s %w", FlagRevisionLimit, err)
+		}
+
+		config.RevisionLimit = limit
+		config.RevisionLimitIsValue = true
+	}
+
+	if argRevisionMaxAge != nil && *argRevisionMaxAge != "" {
+		maxAge, err := time.ParseDuration(*argRevisionMaxAge)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s %w", FlagRevisionMaxAge, err)
+		}
+
+		config.RevisionMaxAge = maxAge
+		config.RevisionMaxAgeIsValue = true
+	}
+
+	if argTrashRetention != nil && *argTrashRetention != "" {
+		value, err := time.ParseDuration(*argTrashRetention)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s %w", FlagTrashRetention, err)
+		}
+
+		config.TrashRetention = value
+		config.TrashRetentionIsValue = true
+	}
+
+	if argQuotaMaxItems != nil && *argQuotaMaxItems != "" {
+		value, err := strconv.ParseInt(*argQuotaMaxItems, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s %w", FlagQuotaMaxItems, err)
+		}
+
+		config.QuotaMaxItems = value
+		config.QuotaMaxItemsIsValue = true
+	}
+
+	if argQuotaMaxBytes != nil && *argQuotaMaxBytes != "" {
+		value, err := strconv.ParseInt(*argQuotaMaxBytes, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s %w", FlagQuotaMaxBytes, err)
+		}
+
+		config.QuotaMaxBytes = value
+		config.QuotaMaxBytesIsValue = true
+	}
+
+	if argQuotaMaxItemBytes != nil && *argQuotaMaxItemBytes != "" {
+		value, err := strconv.ParseInt(*argQuotaMaxItemBytes, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s %w", FlagQuotaMaxItemBytes, err)
+		}
+
+		config.QuotaMaxItemBytes = value
+		config.QuotaMaxItemBytesIsValue = true
+	}
+
+	if argBlobDir != nil && *argBlobDir != "" {
+		config.BlobDir = *argBlobDir
+		config.BlobDirIsValue = true
+	}
+
+	if argBlobMaxSize != nil && *argBlobMaxSize != "" {
+		value, err := strconv.ParseInt(*argBlobMaxSize, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s %w", FlagBlobMaxSize, err)
+		}
+
+		config.BlobMaxSize = value
+		config.BlobMaxSizeIsValue = true
+	}
+
+	if argUploadDir != nil && *argUploadDir != "" {
+		config.UploadDir = *argUploadDir
+		config.UploadDirIsValue = true
+	}
+
+	if argUploadTTL != nil && *argUploadTTL != "" {
+		value, err := time.ParseDuration(*argUploadTTL)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s %w", FlagUploadTTL, err)
+		}
+
+		config.UploadTTL = value
+		config.UploadTTLIsValue = true
+	}
+
+	if argBlobS3Endpoint != nil && *argBlobS3Endpoint != "" {
+		config.BlobS3Endpoint = *argBlobS3Endpoint
+		config.BlobS3EndpointIsValue = true
+	}
+
+	if argBlobS3Bucket != nil && *argBlobS3Bucket != "" {
+		config.BlobS3Bucket = *argBlobS3Bucket
+		config.BlobS3BucketIsValue = true
+	}
+
+	if argBlobS3Region != nil && *argBlobS3Region != "" {
+		config.BlobS3Region = *argBlobS3Region
+		config.BlobS3RegionIsValue = true
+	}
+
+	if argBlobS3AccessKey != nil && *argBlobS3AccessKey != "" {
+		config.BlobS3AccessKey = *argBlobS3AccessKey
+		config.BlobS3AccessKeyIsValue = true
+	}
+
+	if argBlobS3SecretKey != nil && *argBlobS3SecretKey != "" {
+		config.BlobS3SecretKey = *argBlobS3SecretKey
+		config.BlobS3SecretKeyIsValue = true
+	}
+
+	if argBlobS3Prefix != nil && *argBlobS3Prefix != "" {
+		config.BlobS3Prefix = *argBlobS3Prefix
+		config.BlobS3PrefixIsValue = true
+	}
+
+	if argBlobS3PartSize != nil && *argBlobS3PartSize != "" {
+		value, err := strconv.ParseInt(*argBlobS3PartSize, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s %w", FlagBlobS3PartSize, err)
+		}
+
+		config.BlobS3PartSize = value
+		config.BlobS3PartSizeIsValue = true
+	}
+
+	if argHashKeyPrevious != nil && *argHashKeyPrevious != "" {
+		config.HashKeyPrevious = *argHashKeyPrevious
+		config.HashKeyPreviousIsValue = true
+	}
+
+	if argUnsealCheck != nil && *argUnsealCheck != "" {
+		config.UnsealCheck = *argUnsealCheck
+		config.UnsealCheckIsValue = true
+	}
+
+	if argAdminToken != nil && *argAdminToken != "" {
+		config.AdminToken = *argAdminToken
+		config.AdminTokenIsValue = true
+	}
+
+	if argBackupPassphrase != nil && *argBackupPassphrase != "" {
+		config.BackupPassphrase = *argBackupPassphrase
+		config.BackupPassphraseIsValue = true
+	}
+
+	if argCacheSize != nil && *argCacheSize != "" {
+		value, err := strconv.Atoi(*argCacheSize)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s %w", FlagCacheSize, err)
+		}
+
+		config.CacheSize = value
+		config.CacheSizeIsValue = true
+	}
+
+	if argCacheTTL != nil && *argCacheTTL != "" {
+		value, err := time.ParseDuration(*argCacheTTL)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s %w", FlagCacheTTL, err)
+		}
+
+		config.CacheTTL = value
+		config.CacheTTLIsValue = true
+	}
+
 	return config, nil
 }
 
 // GetConfigFlagsFromOS получает значения флагов из аргументов запуска приложения в ОС.
 func GetConfigFlagsFromOS() (*FlagsConfig, error) {
+	return GetConfigFlagsFromArgs(os.Args[1:])
+}
+
+// GetConfigFlagsFromArgs получает значения флагов из указанных аргументов запуска приложения.
+func GetConfigFlagsFromArgs(args []string) (*FlagsConfig, error) {
 	fs := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 
-	config, err := GetConfigFlags(fs, os.Args[1:])
+	config, err := GetConfigFlags(fs, args)
 	if err != nil {
 		return nil, fmt.Errorf("get flag config %w", err)
 	}
@@ -111,5 +482,105 @@ func (c *Config) OverrideConfigFromFlags(conf *FlagsConfig) *Config {
 		c.Database = conf.Database
 	}
 
+	if conf.StorageFileIsValue {
+		c.StorageFile = conf.StorageFile
+	}
+
+	if conf.TombstoneRetentionIsValue {
+		c.TombstoneRetention = conf.TombstoneRetention
+	}
+
+	if conf.RevisionLimitIsValue {
+		c.RevisionLimit = conf.RevisionLimit
+	}
+
+	if conf.RevisionMaxAgeIsValue {
+		c.RevisionMaxAge = conf.RevisionMaxAge
+	}
+
+	if conf.TrashRetentionIsValue {
+		c.TrashRetention = conf.TrashRetention
+	}
+
+	if conf.QuotaMaxItemsIsValue {
+		c.QuotaMaxItems = conf.QuotaMaxItems
+	}
+
+	if conf.QuotaMaxBytesIsValue {
+		c.QuotaMaxBytes = conf.QuotaMaxBytes
+	}
+
+	if conf.QuotaMaxItemBytesIsValue {
+		c.QuotaMaxItemBytes = conf.QuotaMaxItemBytes
+	}
+
+	if conf.BlobDirIsValue {
+		c.BlobDir = conf.BlobDir
+	}
+
+	if conf.BlobMaxSizeIsValue {
+		c.BlobMaxSize = conf.BlobMaxSize
+	}
+
+	if conf.UploadDirIsValue {
+		c.UploadDir = conf.UploadDir
+	}
+
+	if conf.UploadTTLIsValue {
+		c.UploadTTL = conf.UploadTTL
+	}
+
+	if conf.BlobS3EndpointIsValue {
+		c.BlobS3Endpoint = conf.BlobS3Endpoint
+	}
+
+	if conf.BlobS3BucketIsValue {
+		c.BlobS3Bucket = conf.BlobS3Bucket
+	}
+
+	if conf.BlobS3RegionIsValue {
+		c.BlobS3Region = conf.BlobS3Region
+	}
+
+	if conf.BlobS3AccessKeyIsValue {
+		c.BlobS3AccessKey = conf.BlobS3AccessKey
+	}
+
+	if conf.BlobS3SecretKeyIsValue {
+		c.BlobS3SecretKey = conf.BlobS3SecretKey
+	}
+
+	if conf.BlobS3PrefixIsValue {
+		c.BlobS3Prefix = conf.BlobS3Prefix
+	}
+
+	if conf.BlobS3PartSizeIsValue {
+		c.BlobS3PartSize = conf.BlobS3PartSize
+	}
+
+	if conf.HashKeyPreviousIsValue {
+		c.HashKeyPrevious = conf.HashKeyPrevious
+	}
+
+	if conf.UnsealCheckIsValue {
+		c.UnsealCheck = conf.UnsealCheck
+	}
+
+	if conf.AdminTokenIsValue {
+		c.AdminToken = conf.AdminToken
+	}
+
+	if conf.BackupPassphraseIsValue {
+		c.BackupPassphrase = conf.BackupPassphrase
+	}
+
+	if conf.CacheSizeIsValue {
+		c.CacheSize = conf.CacheSize
+	}
+
+	if conf.CacheTTLIsValue {
+		c.CacheTTL = conf.CacheTTL
+	}
+
 	return c
 }
@@ -0,0 +1,306 @@
+// Package envelope предоставляет конвертное шифрование данных хранилища:
+// данные шифруются ключами данных, а ключи данных - мастер-ключом сервера.
+//
+// Смена мастер-ключа требует перешифровать только ключи данных,
+// а не все зашифрованные ими значения.
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// DataKeySize - размер ключа данных в байтах.
+	DataKeySize = 32
+
+	// sealedPrefix - префикс зашифрованного значения (с версией формата).
+	sealedPrefix = "enc1:"
+
+	// masterKeyIDSize - размер идентификатора мастер-ключа в байтах.
+	masterKeyIDSize = 8
+)
+
+// Метки для получения ключей из секретов и ключей данных.
+const (
+	labelMasterKey   = "goph-keeper master key"
+	labelMasterKeyID = "goph-keeper master key id"
+	labelEncrypt     = "goph-keeper data encrypt"
+	labelNonce       = "goph-keeper data nonce"
+)
+
+// Возможные ошибки при шифровании.
+var (
+	ErrEmptyMasterKey    = errors.New("empty master key")
+	ErrUnknownMasterKey  = errors.New("unknown master key")
+	ErrInvalidDataKey    = errors.New("invalid data key")
+	ErrInvalidCiphertext = errors.New("invalid ciphertext")
+)
+
+// MasterKey описывает мастер-ключ сервера, которым шифруются ключи данных.
+type MasterKey struct {
+	aead cipher.AEAD
+	id   string
+}
+
+// NewMasterKey создаёт мастер-ключ из секрета конфигурации.
+//
+// Идентификатор ключа вычисляется из секрета, поэтому один и тот же секрет
+// всегда даёт ключ с тем же идентификатором.
+func NewMasterKey(secret string) (*MasterKey, error) {
+	if secret == "" {
+		return nil, ErrEmptyMasterKey
+	}
+
+	key := derive([]byte(secret), labelMasterKey)
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MasterKey{
+		aead: aead,
+		id:   hex.EncodeToString(derive(key, labelMasterKeyID)[:masterKeyIDSize]),
+	}, nil
+}
+
+// ID возвращает идентификатор мастер-ключа.
+func (k *MasterKey) ID() string {
+	return k.id
+}
+
+// Keyring описывает текущий мастер-ключ и прежние ключи, которые ещё могут
+// использоваться для расшифровки ключей данных.
+type Keyring struct {
+	current *MasterKey
+	keys    map[string]*MasterKey // id -> ключ
+}
+
+// NewKeyring создаёт набор мастер-ключей.
+//
+// Параметры:
+//   - current: секрет текущего мастер-ключа, которым шифруются новые ключи данных;
+//   - previous: секреты прежних мастер-ключей (пустые пропускаются).
+func NewKeyring(current string, previous ...string) (*Keyring, error) {
+	key, err := NewMasterKey(current)
+	if err != nil {
+		return nil, fmt.Errorf("current master key: %w", err)
+	}
+
+	ring := &Keyring{
+		current: key,
+		keys:    map[string]*MasterKey{key.ID(): key},
+	}
+
+	for _, secret := range previous {
+		if secret == "" {
+			continue
+		}
+
+		prev, err := NewMasterKey(secret)
+		if err != nil {
+			return nil, fmt.Errorf("previous master key: %w", err)
+		}
+
+		if _, ok := ring.keys[prev.ID()]; !ok {
+			ring.keys[prev.ID()] = prev
+		}
+	}
+
+	return ring, nil
+}
+
+// CurrentID возвращает идентификатор текущего мастер-ключа.
+func (r *Keyring) CurrentID() string {
+	return r.current.ID()
+}
+
+// Wrap шифрует ключ данных текущим мастер-ключом и возвращает его
+// вместе с идентификатором мастер-ключа.
+func (r *Keyring) Wrap(dataKey []byte) ([]byte, string, error) {
+	if len(dataKey) != DataKeySize {
+		return nil, "", ErrInvalidDataKey
+	}
+
+	wrapped, err := seal(r.current.aead, dataKey, []byte(r.current.ID()))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return wrapped, r.current.ID(), nil
+}
+
+// Unwrap расшифровывает ключ данных мастер-ключом masterID.
+// Если такого ключа нет в наборе, возвращается ErrUnknownMasterKey.
+func (r *Keyring) Unwrap(wrapped []byte, masterID string) ([]byte, error) {
+	key, ok := r.keys[masterID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMasterKey, masterID)
+	}
+
+	dataKey, err := open(key.aead, wrapped, []byte(masterID))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(dataKey) != DataKeySize {
+		return nil, ErrInvalidDataKey
+	}
+
+	return dataKey, nil
+}
+
+// NewDataKey создаёт случайный ключ данных.
+func NewDataKey() ([]byte, error) {
+	key := make([]byte, DataKeySize)
+
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("generate data key: %w", err)
+	}
+
+	return key, nil
+}
+
+// Cipher шифрует строковые значения ключом данных (AES-256-GCM).
+//
+// Зашифрованное значение имеет вид "enc1:" + base64url(nonce || шифротекст).
+// Дополнительные данные (aad) привязывают шифротекст к месту хранения:
+// значение, расшифрованное с другими aad, не пройдёт проверку.
+type Cipher struct {
+	aead     cipher.AEAD
+	nonceKey []byte // ключ для вычисления nonce детерминированного шифрования
+}
+
+// NewCipher создаёт шифратор значений из ключа данных.
+func NewCipher(dataKey []byte) (*Cipher, error) {
+	if len(dataKey) != DataKeySize {
+		return nil, ErrInvalidDataKey
+	}
+
+	aead, err := newAEAD(derive(dataKey, labelEncrypt))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cipher{
+		aead:     aead,
+		nonceKey: derive(dataKey, labelNonce),
+	}, nil
+}
+
+// Seal шифрует значение со случайным nonce. Пустое значение остаётся пустым.
+func (c *Cipher) Seal(plaintext, aad string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	sealed, err := seal(c.aead, []byte(plaintext), []byte(aad))
+	if err != nil {
+		return "", err
+	}
+
+	return sealedPrefix + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// SealDeterministic шифрует значение с nonce, вычисленным из значения и aad:
+// одинаковые значения дают одинаковый шифротекст, поэтому по нему можно искать.
+// Раскрывается только равенство значений. Пустое значение остаётся пустым.
+func (c *Cipher) SealDeterministic(plaintext, aad string) string {
+	if plaintext == "" {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, c.nonceKey)
+	mac.Write([]byte(aad))
+	mac.Write([]byte{0})
+	mac.Write([]byte(plaintext))
+
+	nonce := mac.Sum(nil)[:c.aead.NonceSize()]
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), []byte(aad))
+
+	return sealedPrefix + base64.RawURLEncoding.EncodeToString(sealed)
+}
+
+// Open расшифровывает значение, зашифрованное Seal или SealDeterministic.
+// Незашифрованное значение (без префикса формата) возвращается как есть.
+func (c *Cipher) Open(value, aad string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(value, sealedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidCiphertext, err)
+	}
+
+	plaintext, err := open(c.aead, sealed, []byte(aad))
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// IsSealed сообщает, что значение зашифровано.
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, sealedPrefix)
+}
+
+// seal шифрует данные со случайным nonce и возвращает nonce || шифротекст.
+func seal(aead cipher.AEAD, plaintext, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// open расшифровывает данные вида nonce || шифротекст.
+func open(aead cipher.AEAD, sealed, aad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize()+aead.Overhead() {
+		return nil, ErrInvalidCiphertext
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCiphertext, err)
+	}
+
+	return plaintext, nil
+}
+
+// newAEAD создаёт AES-256-GCM для ключа.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("aes: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("gcm: %w", err)
+	}
+
+	return aead, nil
+}
+
+// derive получает 32-байтовый ключ из key для назначения label (HMAC-SHA256).
+func derive(key []byte, label string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(label))
+
+	return mac.Sum(nil)
+}
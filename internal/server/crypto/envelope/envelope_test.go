@@ -0,0 +1,109 @@
+package envelope_test
+
+import (
+	"testing"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/crypto/envelope"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+	===== Keyring =====
+*/
+
+func TestKeyring_WrapUnwrap(t *testing.T) {
+	t.Parallel()
+
+	oldRing, err := envelope.NewKeyring("old-secret")
+	require.NoError(t, err)
+
+	dataKey, err := envelope.NewDataKey()
+	require.NoError(t, err)
+
+	wrapped, oldID, err := oldRing.Wrap(dataKey)
+	require.NoError(t, err)
+	assert.Equal(t, oldRing.CurrentID(), oldID)
+
+	newRing, err := envelope.NewKeyring("new-secret", "", "old-secret")
+	require.NoError(t, err)
+	assert.NotEqual(t, oldID, newRing.CurrentID())
+
+	unwrapped, err := newRing.Unwrap(wrapped, oldID)
+	require.NoError(t, err)
+	assert.Equal(t, dataKey, unwrapped)
+
+	rewrapped, newID, err := newRing.Wrap(unwrapped)
+	require.NoError(t, err)
+	assert.Equal(t, newRing.CurrentID(), newID)
+
+	_, err = oldRing.Unwrap(rewrapped, newID)
+	require.ErrorIs(t, err, envelope.ErrUnknownMasterKey)
+
+	_, err = newRing.Unwrap(wrapped, newID)
+	require.ErrorIs(t, err, envelope.ErrInvalidCiphertext, "key wrapped by another master key")
+
+	_, err = envelope.NewKeyring("")
+	require.ErrorIs(t, err, envelope.ErrEmptyMasterKey)
+}
+
+/*
+	===== Cipher =====
+*/
+
+func TestCipher_SealOpen(t *testing.T) {
+	t.Parallel()
+
+	dataKey, err := envelope.NewDataKey()
+	require.NoError(t, err)
+
+	cipher, err := envelope.NewCipher(dataKey)
+	require.NoError(t, err)
+
+	first, err := cipher.Seal("Bank", "user-1/title")
+	require.NoError(t, err)
+
+	second, err := cipher.Seal("Bank", "user-1/title")
+	require.NoError(t, err)
+
+	assert.True(t, envelope.IsSealed(first))
+	assert.NotEqual(t, first, second, "random nonce")
+	assert.NotContains(t, first, "Bank")
+
+	opened, err := cipher.Open(first, "user-1/title")
+	require.NoError(t, err)
+	assert.Equal(t, "Bank", opened)
+
+	_, err = cipher.Open(first, "user-2/title")
+	require.ErrorIs(t, err, envelope.ErrInvalidCiphertext)
+
+	_, err = cipher.Open("enc1:!!!", "user-1/title")
+	require.ErrorIs(t, err, envelope.ErrInvalidCiphertext)
+
+	plain, err := cipher.Open("legacy title", "user-1/title")
+	require.NoError(t, err)
+	assert.Equal(t, "legacy title", plain)
+
+	empty, err := cipher.Seal("", "user-1/title")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+}
+
+func TestCipher_SealDeterministic(t *testing.T) {
+	t.Parallel()
+
+	dataKey, err := envelope.NewDataKey()
+	require.NoError(t, err)
+
+	cipher, err := envelope.NewCipher(dataKey)
+	require.NoError(t, err)
+
+	first := cipher.SealDeterministic("user@example.com", "email")
+	assert.Equal(t, first, cipher.SealDeterministic("user@example.com", "email"))
+	assert.NotEqual(t, first, cipher.SealDeterministic("other@example.com", "email"))
+	assert.NotEqual(t, first, cipher.SealDeterministic("user@example.com", "login"))
+
+	opened, err := cipher.Open(first, "email")
+	require.NoError(t, err)
+	assert.Equal(t, "user@example.com", opened)
+}
@@ -0,0 +1,182 @@
+// Package shamir предоставляет разделение секрета по схеме Шамира над полем GF(2^8).
+//
+// Каждый байт секрета - свободный член случайного многочлена степени threshold-1,
+// а доля - значения многочленов в точке x. Любые threshold долей восстанавливают
+// секрет, меньшее количество не даёт о нём никакой информации.
+package shamir
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+const (
+	// MinThreshold - наименьшее количество долей для восстановления секрета.
+	MinThreshold = 2
+
+	// MaxParts - наибольшее количество долей (точки x - ненулевые элементы поля).
+	MaxParts = 255
+
+	// gfReduce - неприводимый многочлен x^8 + x^4 + x^3 + x + 1 (без старшего бита).
+	gfReduce = 0x1b
+)
+
+// Возможные ошибки при разделении и восстановлении секрета.
+var (
+	ErrInvalidParams = errors.New("invalid shamir parameters")
+	ErrInvalidShares = errors.New("invalid shamir shares")
+)
+
+// Split делит секрет на parts долей, любые threshold из которых восстанавливают его.
+//
+// Доля содержит значения многочленов для каждого байта секрета и последним
+// байтом - точку x, поэтому она на байт длиннее секрета.
+func Split(secret []byte, parts, threshold int) ([][]byte, error) {
+	switch {
+	case len(secret) == 0:
+		return nil, fmt.Errorf("%w: empty secret", ErrInvalidParams)
+	case threshold < MinThreshold || threshold > parts:
+		return nil, fmt.Errorf("%w: threshold %d of %d parts", ErrInvalidParams, threshold, parts)
+	case parts > MaxParts:
+		return nil, fmt.Errorf("%w: %d parts, max %d", ErrInvalidParams, parts, MaxParts)
+	}
+
+	shares := make([][]byte, parts)
+	for index := range shares {
+		shares[index] = make([]byte, len(secret)+1)
+		shares[index][len(secret)] = byte(index + 1)
+	}
+
+	coeffs := make([]byte, threshold-1)
+
+	for pos, value := range secret {
+		if _, err := rand.Read(coeffs); err != nil {
+			return nil, fmt.Errorf("generate coefficients: %w", err)
+		}
+
+		for _, share := range shares {
+			share[pos] = evaluate(value, coeffs, share[len(secret)])
+		}
+	}
+
+	return shares, nil
+}
+
+// Combine восстанавливает секрет из долей, полученных Split.
+//
+// Результат верен, только если долей не меньше порога разделения: проверить это
+// по самим долям нельзя, поэтому секрет следует сверять с контрольным значением.
+func Combine(shares [][]byte) ([]byte, error) {
+	if len(shares) < MinThreshold {
+		return nil, fmt.Errorf("%w: need at least %d shares", ErrInvalidShares, MinThreshold)
+	}
+
+	size := len(shares[0])
+	if size < MinThreshold {
+		return nil, fmt.Errorf("%w: share is too short", ErrInvalidShares)
+	}
+
+	xs := make([]byte, len(shares))
+	seen := make(map[byte]struct{}, len(shares))
+
+	for index, share := range shares {
+		if len(share) != size {
+			return nil, fmt.Errorf("%w: shares have different length", ErrInvalidShares)
+		}
+
+		x := share[size-1]
+		if _, ok := seen[x]; ok || x == 0 {
+			return nil, fmt.Errorf("%w: duplicate or zero point", ErrInvalidShares)
+		}
+
+		seen[x] = struct{}{}
+		xs[index] = x
+	}
+
+	secret := make([]byte, size-1)
+	ys := make([]byte, len(shares))
+
+	for pos := range secret {
+		for index, share := range shares {
+			ys[index] = share[pos]
+		}
+
+		secret[pos] = interpolateZero(xs, ys)
+	}
+
+	return secret, nil
+}
+
+// evaluate вычисляет значение многочлена с свободным членом intercept
+// и коэффициентами coeffs (от x^1) в точке x по схеме Горнера.
+func evaluate(intercept byte, coeffs []byte, x byte) byte {
+	var res byte
+
+	for index := len(coeffs) - 1; index >= 0; index-- {
+		res = gfMul(res, x) ^ coeffs[index]
+	}
+
+	return gfMul(res, x) ^ intercept
+}
+
+// interpolateZero вычисляет значение в точке 0 многочлена, проходящего через точки (xs, ys).
+func interpolateZero(xs, ys []byte) byte {
+	var res byte
+
+	for i := range xs {
+		basis := byte(1)
+
+		for j := range xs {
+			if i == j {
+				continue
+			}
+
+			// Базисный многочлен Лагранжа в точке 0: x_j / (x_j - x_i); вычитание в GF(2^8) - XOR.
+			basis = gfMul(basis, gfDiv(xs[j], xs[j]^xs[i]))
+		}
+
+		res ^= gfMul(ys[i], basis)
+	}
+
+	return res
+}
+
+// gfMul умножает элементы поля GF(2^8).
+func gfMul(left, right byte) byte {
+	var res byte
+
+	for right > 0 {
+		if right&1 == 1 {
+			res ^= left
+		}
+
+		carry := left & 0x80
+		left <<= 1
+
+		if carry != 0 {
+			left ^= gfReduce
+		}
+
+		right >>= 1
+	}
+
+	return res
+}
+
+// gfDiv делит элементы поля GF(2^8) (divisor не равен 0).
+func gfDiv(dividend, divisor byte) byte {
+	// Обратный элемент: a^254 = a^-1, так как мультипликативная группа имеет порядок 255.
+	inverse := byte(1)
+	base := divisor
+
+	for exp := 254; exp > 0; exp >>= 1 {
+		if exp&1 == 1 {
+			inverse = gfMul(inverse, base)
+		}
+
+		base = gfMul(base, base)
+	}
+
+	return gfMul(dividend, inverse)
+}
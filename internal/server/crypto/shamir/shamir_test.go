@@ -0,0 +1,70 @@
+package shamir_test
+
+import (
+	"testing"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/crypto/shamir"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+	===== Split / Combine =====
+*/
+
+func TestSplitCombine(t *testing.T) {
+	t.Parallel()
+
+	secret := []byte("master key of the goph-keeper server")
+
+	shares, err := shamir.Split(secret, 5, 3)
+	require.NoError(t, err)
+	require.Len(t, shares, 5)
+
+	for _, subset := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}} {
+		parts := make([][]byte, 0, len(subset))
+		for _, index := range subset {
+			parts = append(parts, shares[index])
+		}
+
+		combined, err := shamir.Combine(parts)
+		require.NoError(t, err)
+		assert.Equal(t, secret, combined, "shares %v", subset)
+	}
+
+	combined, err := shamir.Combine(shares[:2])
+	require.NoError(t, err)
+	assert.NotEqual(t, secret, combined, "below threshold")
+}
+
+func TestSplit_Errors(t *testing.T) {
+	t.Parallel()
+
+	_, err := shamir.Split(nil, 3, 2)
+	require.ErrorIs(t, err, shamir.ErrInvalidParams)
+
+	_, err = shamir.Split([]byte("key"), 3, 1)
+	require.ErrorIs(t, err, shamir.ErrInvalidParams)
+
+	_, err = shamir.Split([]byte("key"), 2, 3)
+	require.ErrorIs(t, err, shamir.ErrInvalidParams)
+
+	_, err = shamir.Split([]byte("key"), 256, 3)
+	require.ErrorIs(t, err, shamir.ErrInvalidParams)
+}
+
+func TestCombine_Errors(t *testing.T) {
+	t.Parallel()
+
+	shares, err := shamir.Split([]byte("key"), 3, 2)
+	require.NoError(t, err)
+
+	_, err = shamir.Combine(shares[:1])
+	require.ErrorIs(t, err, shamir.ErrInvalidShares)
+
+	_, err = shamir.Combine([][]byte{shares[0], shares[0]})
+	require.ErrorIs(t, err, shamir.ErrInvalidShares)
+
+	_, err = shamir.Combine([][]byte{shares[0], shares[1][:2]})
+	require.ErrorIs(t, err, shamir.ErrInvalidShares)
+}
@@ -38,6 +38,11 @@ func (h *Handler) ResponseError(writer http.ResponseWriter, code int, err error)
 
 // ResponceWithJSON формирует успешный ответ отправляя данные в формате JSON.
 func (h *Handler) ResponceWithJSON(writer http.ResponseWriter, data any) {
+	h.ResponceWithJSONStatus(writer, http.StatusOK, data)
+}
+
+// ResponceWithJSONStatus формирует ответ с указанным HTTP-кодом, отправляя данные в формате JSON.
+func (h *Handler) ResponceWithJSONStatus(writer http.ResponseWriter, code int, data any) {
 	res, err := json.Marshal(data)
 	if err != nil {
 		h.ResponseError(writer, http.StatusInternalServerError, err)
@@ -45,7 +50,8 @@ func (h *Handler) ResponceWithJSON(writer http.ResponseWriter, data any) {
 		return
 	}
 
-	writer.WriteHeader(http.StatusOK)
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(code)
 
 	_, err = writer.Write(res)
 	if err != nil {
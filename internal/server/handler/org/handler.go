@@ -0,0 +1,565 @@
+// Package org предоставляет функционал для обработчиков запросов для работы с организациями.
+package org
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/handler"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/middleware"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+)
+
+// Ошибки работы с организациями.
+var (
+	ErrEmptyName          = errors.New("name is empty")
+	ErrInvalidRole        = errors.New("invalid role")
+	ErrForbidden          = errors.New("role does not allow this operation")
+	ErrAlreadyMember      = errors.New("user is already a member or invited")
+	ErrNotInvited         = errors.New("no pending invitation")
+	ErrLastOwner          = errors.New("organization must keep an active owner")
+	ErrCollectionNotEmpty = errors.New("collection is not empty")
+)
+
+// Handler хранит данные необходимые для обработчиков.
+//
+// Права участников вложены: viewer читает записи коллекций, editor изменяет их,
+// admin управляет участниками и коллекциями, owner - ещё и владельцами.
+type Handler struct {
+	Orgs  storage.IOrgStorage
+	VStor storage.IStorage
+	handler.Handler
+}
+
+// NewHandler создаёт новый экземпляр Handler.
+func NewHandler(h handler.Handler, orgs storage.IOrgStorage, vStor storage.IStorage) *Handler {
+	return &Handler{
+		Handler: h,
+		Orgs:    orgs,
+		VStor:   vStor,
+	}
+}
+
+// CreateOrg создаёт организацию, пользователь становится её владельцем.
+func (h *Handler) CreateOrg(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetUserID(req.Context())
+
+	name, ok := h.readName(resp, req)
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	org := &entity.Organization{
+		CreatedAt: now,
+		ID:        uuid.New().String(),
+		Name:      name,
+	}
+	owner := &entity.Member{
+		CreatedAt: now,
+		OrgID:     org.ID,
+		UserID:    uid,
+		Role:      entity.OrgOwner,
+		Status:    entity.MemberActive,
+		InvitedBy: "",
+	}
+
+	if err := h.Orgs.CreateOrg(req.Context(), org, owner); err != nil {
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	h.ResponceWithJSONStatus(resp, http.StatusCreated, orgResp{
+		Org:    org,
+		Role:   owner.Role,
+		Status: owner.Status,
+	})
+}
+
+// ListOrgs выводит организации пользователя, в том числе непринятые приглашения.
+func (h *Handler) ListOrgs(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetUserID(req.Context())
+
+	members, err := h.Orgs.ListMemberships(req.Context(), uid)
+	if err != nil {
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	res := make([]orgResp, 0, len(members))
+
+	for _, member := range members {
+		org, err := h.Orgs.GetOrg(req.Context(), member.OrgID)
+		if err != nil {
+			h.ResponseError(resp, http.StatusInternalServerError, err)
+
+			return
+		}
+
+		res = append(res, orgResp{
+			Org:    org,
+			Role:   member.Role,
+			Status: member.Status,
+		})
+	}
+
+	h.ResponceWithJSON(resp, res)
+}
+
+// GetOrg выводит организацию.
+func (h *Handler) GetOrg(resp http.ResponseWriter, req *http.Request) {
+	member, ok := h.requireRole(resp, req, entity.OrgViewer)
+	if !ok {
+		return
+	}
+
+	org, err := h.Orgs.GetOrg(req.Context(), member.OrgID)
+	if err != nil {
+		h.responseLookupError(resp, err)
+
+		return
+	}
+
+	h.ResponceWithJSON(resp, orgResp{
+		Org:    org,
+		Role:   member.Role,
+		Status: member.Status,
+	})
+}
+
+// ListMembers выводит участников и приглашённых организации.
+func (h *Handler) ListMembers(resp http.ResponseWriter, req *http.Request) {
+	member, ok := h.requireRole(resp, req, entity.OrgViewer)
+	if !ok {
+		return
+	}
+
+	members, err := h.Orgs.ListMembers(req.Context(), member.OrgID)
+	if err != nil {
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	h.ResponceWithJSON(resp, members)
+}
+
+// InviteMember приглашает пользователя с указанным email в организацию.
+//
+// Участником пользователь становится после принятия приглашения (AcceptInvite).
+// Приглашать владельцев может только владелец.
+func (h *Handler) InviteMember(resp http.ResponseWriter, req *http.Request) {
+	caller, ok := h.requireRole(resp, req, entity.OrgAdmin)
+	if !ok {
+		return
+	}
+
+	var invReq inviteReq
+
+	if err := handler.GetDataFromBodyJSON(req, &invReq); err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	if !h.checkGrant(resp, caller, invReq.Role) {
+		return
+	}
+
+	user, err := h.Stor.FindUserByEmail(req.Context(), invReq.Email)
+	if err != nil {
+		h.responseLookupError(resp, err)
+
+		return
+	}
+
+	_, err = h.Orgs.GetMember(req.Context(), caller.OrgID, user.ID)
+	if err == nil {
+		h.ResponseError(resp, http.StatusConflict, ErrAlreadyMember)
+
+		return
+	}
+
+	if !errors.Is(err, storage.ErrEntityNotFound) {
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	member := &entity.Member{
+		CreatedAt: time.Now().UTC(),
+		OrgID:     caller.OrgID,
+		UserID:    user.ID,
+		Role:      invReq.Role,
+		Status:    entity.MemberInvited,
+		InvitedBy: caller.UserID,
+	}
+
+	if err := h.Orgs.PutMember(req.Context(), member); err != nil {
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	h.Log.Info("Organization member invited",
+		"org", member.OrgID,
+		"user", member.UserID,
+		"role", member.Role,
+	)
+
+	h.ResponceWithJSON(resp, member)
+}
+
+// AcceptInvite принимает приглашение пользователя в организацию.
+func (h *Handler) AcceptInvite(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetUserID(req.Context())
+
+	member, err := h.Orgs.GetMember(req.Context(), req.PathValue("orgId"), uid)
+	if err != nil {
+		h.responseLookupError(resp, err)
+
+		return
+	}
+
+	if member.Status != entity.MemberInvited {
+		h.ResponseError(resp, http.StatusConflict, ErrNotInvited)
+
+		return
+	}
+
+	member.Status = entity.MemberActive
+
+	if err := h.Orgs.PutMember(req.Context(), member); err != nil {
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	h.ResponceWithJSON(resp, member)
+}
+
+// UpdateMember меняет роль участника организации.
+//
+// Назначать и менять роль владельцев может только владелец;
+// у организации всегда остаётся хотя бы один активный владелец.
+func (h *Handler) UpdateMember(resp http.ResponseWriter, req *http.Request) {
+	caller, ok := h.requireRole(resp, req, entity.OrgAdmin)
+	if !ok {
+		return
+	}
+
+	var rReq roleReq
+
+	if err := handler.GetDataFromBodyJSON(req, &rReq); err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	if !h.checkGrant(resp, caller, rReq.Role) {
+		return
+	}
+
+	member, err := h.Orgs.GetMember(req.Context(), caller.OrgID, req.PathValue("userId"))
+	if err != nil {
+		h.responseLookupError(resp, err)
+
+		return
+	}
+
+	if !h.checkManage(resp, req, caller, member, rReq.Role) {
+		return
+	}
+
+	member.Role = rReq.Role
+
+	if err := h.Orgs.PutMember(req.Context(), member); err != nil {
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	h.ResponceWithJSON(resp, member)
+}
+
+// RemoveMember исключает пользователя из организации или отзывает приглашение.
+//
+// Пользователь может сам выйти из организации или отклонить приглашение,
+// исключать других может администратор.
+func (h *Handler) RemoveMember(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetUserID(req.Context())
+	orgID, userID := req.PathValue("orgId"), req.PathValue("userId")
+
+	caller, err := h.Orgs.GetMember(req.Context(), orgID, uid)
+	if err != nil {
+		h.responseLookupError(resp, err)
+
+		return
+	}
+
+	member := caller
+
+	if userID != uid {
+		if !h.checkRole(resp, caller, entity.OrgAdmin) {
+			return
+		}
+
+		member, err = h.Orgs.GetMember(req.Context(), orgID, userID)
+		if err != nil {
+			h.responseLookupError(resp, err)
+
+			return
+		}
+	}
+
+	if !h.checkManage(resp, req, caller, member, "") {
+		return
+	}
+
+	if err := h.Orgs.DeleteMember(req.Context(), orgID, userID); err != nil {
+		h.responseLookupError(resp, err)
+
+		return
+	}
+
+	resp.WriteHeader(http.StatusNoContent)
+}
+
+// ListCollections выводит коллекции организации.
+func (h *Handler) ListCollections(resp http.ResponseWriter, req *http.Request) {
+	member, ok := h.requireRole(resp, req, entity.OrgViewer)
+	if !ok {
+		return
+	}
+
+	colls, err := h.Orgs.ListCollections(req.Context(), member.OrgID)
+	if err != nil {
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	h.ResponceWithJSON(resp, colls)
+}
+
+// CreateCollection создаёт коллекцию организации.
+func (h *Handler) CreateCollection(resp http.ResponseWriter, req *http.Request) {
+	member, ok := h.requireRole(resp, req, entity.OrgAdmin)
+	if !ok {
+		return
+	}
+
+	name, ok := h.readName(resp, req)
+	if !ok {
+		return
+	}
+
+	coll := &entity.Collection{
+		CreatedAt: time.Now().UTC(),
+		ID:        uuid.New().String(),
+		OrgID:     member.OrgID,
+		Name:      name,
+	}
+
+	if err := h.Orgs.CreateCollection(req.Context(), coll); err != nil {
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	h.ResponceWithJSONStatus(resp, http.StatusCreated, coll)
+}
+
+// DeleteCollection удаляет пустую коллекцию организации вместе с её корзиной.
+func (h *Handler) DeleteCollection(resp http.ResponseWriter, req *http.Request) {
+	member, ok := h.requireRole(resp, req, entity.OrgAdmin)
+	if !ok {
+		return
+	}
+
+	coll, err := h.Orgs.GetCollection(req.Context(), member.OrgID, req.PathValue("collectionId"))
+	if err != nil {
+		h.responseLookupError(resp, err)
+
+		return
+	}
+
+	items, err := h.VStor.ListItems(req.Context(), coll.ID)
+	if err != nil {
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	if len(items) > 0 {
+		h.ResponseError(resp, http.StatusConflict, fmt.Errorf("%w: %d items", ErrCollectionNotEmpty, len(items)))
+
+		return
+	}
+
+	if _, err := h.VStor.EmptyTrash(req.Context(), coll.ID); err != nil {
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	if err := h.Orgs.DeleteCollection(req.Context(), coll.OrgID, coll.ID); err != nil {
+		h.responseLookupError(resp, err)
+
+		return
+	}
+
+	resp.WriteHeader(http.StatusNoContent)
+}
+
+// RequireCollection представляет middleware для запросов к записям коллекции организации.
+//
+// Проверяет роль пользователя (viewer - для запросов GET и HEAD, editor - для остальных)
+// и передаёт обработчику коллекцию как владельца записей (middleware.WithOwnerID).
+func (h *Handler) RequireCollection(next http.HandlerFunc) http.HandlerFunc {
+	return func(resp http.ResponseWriter, req *http.Request) {
+		need := entity.OrgEditor
+		if req.Method == http.MethodGet || req.Method == http.MethodHead {
+			need = entity.OrgViewer
+		}
+
+		member, ok := h.requireRole(resp, req, need)
+		if !ok {
+			return
+		}
+
+		coll, err := h.Orgs.GetCollection(req.Context(), member.OrgID, req.PathValue("collectionId"))
+		if err != nil {
+			h.responseLookupError(resp, err)
+
+			return
+		}
+
+		next(resp, req.WithContext(middleware.WithOwnerID(req.Context(), coll.ID)))
+	}
+}
+
+// requireRole получает участие пользователя в организации из пути запроса и проверяет,
+// что оно активно и роль включает права need. При ошибке формирует ответ и возвращает false.
+//
+// Тем, кто не участвует в организации, отвечает 404, чтобы не раскрывать её существование.
+func (h *Handler) requireRole(
+	resp http.ResponseWriter,
+	req *http.Request,
+	need entity.OrgRole,
+) (*entity.Member, bool) {
+	uid, _ := middleware.GetUserID(req.Context())
+
+	member, err := h.Orgs.GetMember(req.Context(), req.PathValue("orgId"), uid)
+	if err != nil {
+		h.responseLookupError(resp, err)
+
+		return nil, false
+	}
+
+	if !h.checkRole(resp, member, need) {
+		return nil, false
+	}
+
+	return member, true
+}
+
+// checkRole проверяет, что участие активно и роль включает права need.
+func (h *Handler) checkRole(resp http.ResponseWriter, member *entity.Member, need entity.OrgRole) bool {
+	if member.Status != entity.MemberActive || !member.Role.Allows(need) {
+		h.ResponseError(resp, http.StatusForbidden,
+			fmt.Errorf("%w: %s %s, need %s", ErrForbidden, member.Status, member.Role, need))
+
+		return false
+	}
+
+	return true
+}
+
+// checkGrant проверяет, что участник caller может назначить роль role.
+func (h *Handler) checkGrant(resp http.ResponseWriter, caller *entity.Member, role entity.OrgRole) bool {
+	if !role.Valid() {
+		h.ResponseError(resp, http.StatusBadRequest, fmt.Errorf("%w: %q", ErrInvalidRole, role))
+
+		return false
+	}
+
+	return h.checkRole(resp, caller, role)
+}
+
+// checkManage проверяет, что участник caller может сменить роль участника member
+// на role или исключить его (пустая role): владельцами управляют только владельцы,
+// и последний активный владелец не может лишиться роли.
+func (h *Handler) checkManage(
+	resp http.ResponseWriter,
+	req *http.Request,
+	caller, member *entity.Member,
+	role entity.OrgRole,
+) bool {
+	if member.Role != entity.OrgOwner {
+		return true
+	}
+
+	if caller.UserID != member.UserID && !h.checkRole(resp, caller, entity.OrgOwner) {
+		return false
+	}
+
+	if role == entity.OrgOwner || member.Status != entity.MemberActive {
+		return true
+	}
+
+	members, err := h.Orgs.ListMembers(req.Context(), member.OrgID)
+	if err != nil {
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+
+		return false
+	}
+
+	for _, other := range members {
+		if other.UserID != member.UserID && other.Role == entity.OrgOwner && other.Status == entity.MemberActive {
+			return true
+		}
+	}
+
+	h.ResponseError(resp, http.StatusConflict, ErrLastOwner)
+
+	return false
+}
+
+// readName читает название из тела запроса. При ошибке формирует ответ и возвращает false.
+func (h *Handler) readName(resp http.ResponseWriter, req *http.Request) (string, bool) {
+	var nReq nameReq
+
+	if err := handler.GetDataFromBodyJSON(req, &nReq); err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return "", false
+	}
+
+	name := strings.TrimSpace(nReq.Name)
+	if name == "" {
+		h.ResponseError(resp, http.StatusBadRequest, ErrEmptyName)
+
+		return "", false
+	}
+
+	return name, true
+}
+
+// responseLookupError формирует ответ при ошибке получения организации, участника или коллекции.
+func (h *Handler) responseLookupError(resp http.ResponseWriter, err error) {
+	if errors.Is(err, storage.ErrEntityNotFound) {
+		h.ResponseError(resp, http.StatusNotFound, err)
+
+		return
+	}
+
+	h.ResponseError(resp, http.StatusInternalServerError, err)
+}
@@ -0,0 +1,280 @@
+package org_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/handler"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/handler/org"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/middleware"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+	"github.com/mr-filatik/go-goph-keeper/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// orgFixture - обработчик с хранилищем в памяти, организацией и пользователями.
+type orgFixture struct {
+	handler *org.Handler
+	stor    *storage.MemoryStorage
+	users   map[string]*entity.User // имя -> пользователь
+	orgID   string
+}
+
+// newOrgFixture создаёт обработчик, пользователей owner, admin, editor, viewer и outsider
+// и организацию, в которой первые четыре участвуют с одноимёнными ролями.
+func newOrgFixture(t *testing.T) *orgFixture {
+	t.Helper()
+
+	ctx := context.Background()
+	stor := storage.NewMemoryStorage()
+	fix := &orgFixture{
+		handler: org.NewHandler(*handler.NewHandler(stor, testutil.NewMockLogger()), stor, stor),
+		stor:    stor,
+		users:   make(map[string]*entity.User),
+		orgID:   "",
+	}
+
+	for _, name := range []string{"owner", "admin", "editor", "viewer", "outsider"} {
+		user := entity.NewUser(name+"@example.com", "hash")
+		_, err := stor.AddNewUser(ctx, user)
+		require.NoError(t, err)
+
+		fix.users[name] = user
+	}
+
+	rr := fix.do(fix.handler.CreateOrg, "owner", http.MethodPost, map[string]any{"name": "Team"}, nil)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	var created struct {
+		Org entity.Organization `json:"org"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+
+	fix.orgID = created.Org.ID
+
+	for _, role := range []entity.OrgRole{entity.OrgAdmin, entity.OrgEditor, entity.OrgViewer} {
+		rr = fix.do(fix.handler.InviteMember, "owner", http.MethodPost,
+			map[string]any{"email": string(role) + "@example.com", "role": role}, nil)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+		rr = fix.do(fix.handler.AcceptInvite, string(role), http.MethodPost, nil, nil)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	return fix
+}
+
+// do выполняет запрос от имени пользователя name к организации фикстуры.
+func (f *orgFixture) do(
+	handle http.HandlerFunc,
+	name, method string,
+	body any,
+	pathValues map[string]string,
+) *httptest.ResponseRecorder {
+	buf, _ := json.Marshal(body)
+	req := httptest.NewRequest(method, "/orgs", bytes.NewReader(buf))
+	req.SetPathValue("orgId", f.orgID)
+
+	for key, value := range pathValues {
+		req.SetPathValue(key, value)
+	}
+
+	req = req.WithContext(middleware.WithUserID(req.Context(), f.users[name].ID))
+
+	rr := httptest.NewRecorder()
+	handle(rr, req)
+
+	return rr
+}
+
+/*
+	===== Handler members =====
+*/
+
+func TestOrg_Members(t *testing.T) {
+	t.Parallel()
+
+	fix := newOrgFixture(t)
+
+	rr := fix.do(fix.handler.ListOrgs, "viewer", http.MethodGet, nil, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"role":"viewer","status":"active"`)
+
+	rr = fix.do(fix.handler.ListMembers, "viewer", http.MethodGet, nil, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var members []entity.Member
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &members))
+	assert.Len(t, members, 4)
+
+	rr = fix.do(fix.handler.GetOrg, "outsider", http.MethodGet, nil, nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code, "organization is hidden from outsiders")
+
+	invite := map[string]any{"email": "outsider@example.com", "role": "viewer"}
+
+	rr = fix.do(fix.handler.InviteMember, "editor", http.MethodPost, invite, nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code, "editor cannot invite")
+
+	rr = fix.do(fix.handler.InviteMember, "admin", http.MethodPost,
+		map[string]any{"email": "outsider@example.com", "role": "owner"}, nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code, "admin cannot invite owners")
+
+	rr = fix.do(fix.handler.InviteMember, "admin", http.MethodPost,
+		map[string]any{"email": "outsider@example.com", "role": "root"}, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = fix.do(fix.handler.InviteMember, "admin", http.MethodPost, invite, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = fix.do(fix.handler.InviteMember, "admin", http.MethodPost, invite, nil)
+	assert.Equal(t, http.StatusConflict, rr.Code, "already invited")
+
+	rr = fix.do(fix.handler.ListMembers, "outsider", http.MethodGet, nil, nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code, "invitation is not accepted yet")
+
+	rr = fix.do(fix.handler.AcceptInvite, "outsider", http.MethodPost, nil, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	rr = fix.do(fix.handler.AcceptInvite, "outsider", http.MethodPost, nil, nil)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	outsider := map[string]string{"userId": fix.users["outsider"].ID}
+
+	rr = fix.do(fix.handler.UpdateMember, "admin", http.MethodPut, map[string]any{"role": "editor"}, outsider)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	member, err := fix.stor.GetMember(context.Background(), fix.orgID, fix.users["outsider"].ID)
+	require.NoError(t, err)
+	assert.Equal(t, entity.OrgEditor, member.Role)
+
+	rr = fix.do(fix.handler.RemoveMember, "editor", http.MethodDelete, nil, outsider)
+	assert.Equal(t, http.StatusForbidden, rr.Code, "editor cannot remove others")
+
+	rr = fix.do(fix.handler.RemoveMember, "outsider", http.MethodDelete, nil, outsider)
+	require.Equal(t, http.StatusNoContent, rr.Code, "member can leave")
+}
+
+func TestOrg_Owners(t *testing.T) {
+	t.Parallel()
+
+	fix := newOrgFixture(t)
+	owner := map[string]string{"userId": fix.users["owner"].ID}
+	admin := map[string]string{"userId": fix.users["admin"].ID}
+
+	rr := fix.do(fix.handler.UpdateMember, "admin", http.MethodPut, map[string]any{"role": "viewer"}, owner)
+	assert.Equal(t, http.StatusForbidden, rr.Code, "admin cannot demote owners")
+
+	rr = fix.do(fix.handler.RemoveMember, "admin", http.MethodDelete, nil, owner)
+	assert.Equal(t, http.StatusForbidden, rr.Code, "admin cannot remove owners")
+
+	rr = fix.do(fix.handler.UpdateMember, "owner", http.MethodPut, map[string]any{"role": "admin"}, owner)
+	assert.Equal(t, http.StatusConflict, rr.Code, "last owner cannot be demoted")
+
+	rr = fix.do(fix.handler.RemoveMember, "owner", http.MethodDelete, nil, owner)
+	assert.Equal(t, http.StatusConflict, rr.Code, "last owner cannot leave")
+
+	rr = fix.do(fix.handler.UpdateMember, "owner", http.MethodPut, map[string]any{"role": "owner"}, admin)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = fix.do(fix.handler.RemoveMember, "owner", http.MethodDelete, nil, owner)
+	assert.Equal(t, http.StatusNoContent, rr.Code, "owner can leave when another owner remains")
+}
+
+/*
+	===== Handler collections =====
+*/
+
+func TestOrg_Collections(t *testing.T) {
+	t.Parallel()
+
+	fix := newOrgFixture(t)
+
+	rr := fix.do(fix.handler.CreateCollection, "editor", http.MethodPost, map[string]any{"name": "Infra"}, nil)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	rr = fix.do(fix.handler.CreateCollection, "admin", http.MethodPost, map[string]any{"name": " "}, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = fix.do(fix.handler.CreateCollection, "admin", http.MethodPost, map[string]any{"name": "Infra"}, nil)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	var coll entity.Collection
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &coll))
+
+	rr = fix.do(fix.handler.ListCollections, "viewer", http.MethodGet, nil, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), coll.ID)
+
+	//nolint:exhaustruct // not all fields needed in test
+	itemID, err := fix.stor.CreateItem(context.Background(), &entity.VaultItem{OwnerID: coll.ID, Title: "DB"})
+	require.NoError(t, err)
+
+	path := map[string]string{"collectionId": coll.ID}
+
+	rr = fix.do(fix.handler.DeleteCollection, "admin", http.MethodDelete, nil, path)
+	assert.Equal(t, http.StatusConflict, rr.Code, "collection with items")
+
+	require.NoError(t, fix.stor.DeleteItem(context.Background(), coll.ID, itemID))
+
+	rr = fix.do(fix.handler.DeleteCollection, "admin", http.MethodDelete, nil, path)
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	trash, err := fix.stor.ListTrash(context.Background(), coll.ID)
+	require.NoError(t, err)
+	assert.Empty(t, trash, "collection trash is emptied")
+}
+
+/*
+	===== Handler.RequireCollection =====
+*/
+
+func TestOrg_RequireCollection(t *testing.T) {
+	t.Parallel()
+
+	fix := newOrgFixture(t)
+
+	rr := fix.do(fix.handler.CreateCollection, "owner", http.MethodPost, map[string]any{"name": "Infra"}, nil)
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	var coll entity.Collection
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &coll))
+
+	var gotOwner string
+
+	next := fix.handler.RequireCollection(func(resp http.ResponseWriter, req *http.Request) {
+		gotOwner, _ = middleware.GetOwnerID(req.Context())
+
+		resp.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name       string
+		user       string
+		method     string
+		collection string
+		code       int
+	}{
+		{"viewer reads", "viewer", http.MethodGet, coll.ID, http.StatusNoContent},
+		{"viewer writes", "viewer", http.MethodPost, coll.ID, http.StatusForbidden},
+		{"editor writes", "editor", http.MethodPost, coll.ID, http.StatusNoContent},
+		{"outsider", "outsider", http.MethodGet, coll.ID, http.StatusNotFound},
+		{"unknown collection", "viewer", http.MethodGet, "missing", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		gotOwner = ""
+
+		rr := fix.do(next, tt.user, tt.method, nil, map[string]string{"collectionId": tt.collection})
+		assert.Equal(t, tt.code, rr.Code, tt.name)
+
+		if tt.code == http.StatusNoContent {
+			assert.Equal(t, coll.ID, gotOwner, tt.name)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+// Package org предоставляет функционал для обработчиков запросов для работы с организациями.
+package org
+
+import "github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+
+// nameReq - запрос создания организации или коллекции.
+type nameReq struct {
+	Name string `json:"name"`
+}
+
+// inviteReq - запрос приглашения пользователя в организацию.
+type inviteReq struct {
+	Email string         `json:"email"`
+	Role  entity.OrgRole `json:"role"`
+}
+
+// roleReq - запрос смены роли участника организации.
+type roleReq struct {
+	Role entity.OrgRole `json:"role"`
+}
+
+// orgResp - организация с ролью и состоянием участия пользователя.
+type orgResp struct {
+	Org    *entity.Organization `json:"org"`
+	Role   entity.OrgRole       `json:"role"`
+	Status entity.MemberStatus  `json:"status"`
+}
@@ -0,0 +1,101 @@
+// Package sys предоставляет функционал для служебных обработчиков запросов сервера.
+package sys
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/backup"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/handler"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/seal"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage"
+)
+
+// BackupTimeout - время на передачу резервной копии, продлевающее общие таймауты сервера.
+const BackupTimeout = 30 * time.Minute
+
+// Handler хранит данные необходимые для обработчиков.
+type Handler struct {
+	barrier *seal.Barrier
+	handler.Handler
+
+	Backups          storage.IBackupStorage // хранилище для резервного копирования
+	BackupPassphrase string                 // пароль шифрования резервных копий (пусто - без шифрования)
+}
+
+// NewHandler создаёт новый экземпляр Handler.
+//
+// Параметры:
+//   - hand: общий обработчик;
+//   - barrier: доли мастер-ключа запечатанного сервера.
+func NewHandler(hand handler.Handler, barrier *seal.Barrier) *Handler {
+	return &Handler{
+		Handler: hand,
+		barrier: barrier,
+
+		Backups:          nil,
+		BackupPassphrase: "",
+	}
+}
+
+// SealStatus отдаёт состояние распечатывания сервера.
+func (h *Handler) SealStatus(resp http.ResponseWriter, _ *http.Request) {
+	h.ResponceWithJSON(resp, h.barrier.Status())
+}
+
+// Unseal принимает долю мастер-ключа и отдаёт состояние распечатывания.
+//
+// Доли принимаются без авторизации: пока сервер запечатан, проверить
+// пользователя нельзя, а без threshold долей доля бесполезна.
+func (h *Handler) Unseal(resp http.ResponseWriter, req *http.Request) {
+	var unReq unsealReq
+
+	if err := handler.GetDataFromBodyJSON(req, &unReq); err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	if unReq.Reset {
+		h.ResponceWithJSON(resp, h.barrier.Reset())
+
+		return
+	}
+
+	status, err := h.barrier.Submit(req.Context(), unReq.Share)
+
+	switch {
+	case err == nil:
+		h.ResponceWithJSON(resp, status)
+	case errors.Is(err, seal.ErrInvalidShare), errors.Is(err, seal.ErrWrongKey):
+		h.ResponseError(resp, http.StatusBadRequest, err)
+	default:
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+	}
+}
+
+// Backup отдаёт архив с согласованным снимком всех данных хранилища (см. пакет backup).
+//
+// Данные записей и ключи данных пользователей попадают в архив в том виде,
+// в котором они лежат в хранилище, то есть зашифрованными мастер-ключом сервера.
+func (h *Handler) Backup(resp http.ResponseWriter, req *http.Request) {
+	snap, err := h.Backups.Snapshot(req.Context())
+	if err != nil {
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	// Если ResponseWriter не поддерживает сроки, действуют общие таймауты сервера.
+	_ = http.NewResponseController(resp).SetWriteDeadline(time.Now().Add(BackupTimeout))
+
+	resp.Header().Set("Content-Type", "application/octet-stream")
+	resp.Header().Set("Content-Disposition",
+		`attachment; filename="goph-keeper-`+time.Now().UTC().Format("20060102-150405")+`.backup"`)
+	resp.WriteHeader(http.StatusOK)
+
+	if _, err := backup.Write(resp, snap, h.BackupPassphrase); err != nil {
+		h.Log.Error("Backup streaming error", err)
+	}
+}
@@ -0,0 +1,146 @@
+package sys_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/backup"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/handler"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/handler/sys"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/middleware"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/seal"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+	"github.com/mr-filatik/go-goph-keeper/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestHandler(t *testing.T, check string, onUnseal seal.UnsealFunc) *sys.Handler {
+	t.Helper()
+
+	barrier, err := seal.NewBarrier(check, onUnseal)
+	require.NoError(t, err)
+
+	return sys.NewHandler(*handler.NewHandler(nil, testutil.NewMockLogger()), barrier)
+}
+
+func unseal(h *sys.Handler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/sys/unseal", strings.NewReader(body))
+	recorder := httptest.NewRecorder()
+
+	h.Unseal(recorder, req)
+
+	return recorder
+}
+
+func decodeStatus(t *testing.T, recorder *httptest.ResponseRecorder) seal.Status {
+	t.Helper()
+
+	var status seal.Status
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &status))
+
+	return status
+}
+
+/*
+	===== Unseal =====
+*/
+
+func TestUnseal(t *testing.T) {
+	t.Parallel()
+
+	keys, err := seal.Init(3, 2)
+	require.NoError(t, err)
+
+	unsealed := false
+	sysHandler := newTestHandler(t, keys.Check, func(context.Context, []byte) error {
+		unsealed = true
+
+		return nil
+	})
+
+	recorder := httptest.NewRecorder()
+	sysHandler.SealStatus(recorder, httptest.NewRequest(http.MethodGet, "/sys/seal-status", http.NoBody))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, seal.Status{Sealed: true, Threshold: 2, Progress: 0}, decodeStatus(t, recorder))
+
+	recorder = unseal(sysHandler, `{"share":"`+keys.Shares[2]+`"}`)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, 1, decodeStatus(t, recorder).Progress)
+
+	recorder = unseal(sysHandler, `{"reset":true}`)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, 0, decodeStatus(t, recorder).Progress)
+
+	unseal(sysHandler, `{"share":"`+keys.Shares[2]+`"}`)
+
+	recorder = unseal(sysHandler, `{"share":"`+keys.Shares[0]+`"}`)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.False(t, decodeStatus(t, recorder).Sealed)
+	assert.True(t, unsealed)
+}
+
+func TestUnseal_Errors(t *testing.T) {
+	t.Parallel()
+
+	keys, err := seal.Init(2, 2)
+	require.NoError(t, err)
+
+	other, err := seal.Init(2, 2)
+	require.NoError(t, err)
+
+	sysHandler := newTestHandler(t, keys.Check, func(context.Context, []byte) error {
+		return assert.AnError
+	})
+
+	assert.Equal(t, http.StatusBadRequest, unseal(sysHandler, `{`).Code)
+	assert.Equal(t, http.StatusBadRequest, unseal(sysHandler, `{"share":"bad"}`).Code)
+
+	unseal(sysHandler, `{"share":"`+keys.Shares[0]+`"}`)
+	assert.Equal(t, http.StatusBadRequest, unseal(sysHandler, `{"share":"`+other.Shares[1]+`"}`).Code)
+
+	unseal(sysHandler, `{"share":"`+keys.Shares[0]+`"}`)
+	assert.Equal(t, http.StatusInternalServerError, unseal(sysHandler, `{"share":"`+keys.Shares[1]+`"}`).Code)
+}
+
+/*
+	===== Backup =====
+*/
+
+func TestBackup(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	stor := storage.NewMemoryStorage()
+
+	user := entity.NewUser("user@example.com", "hash")
+	_, err := stor.AddNewUser(ctx, user)
+	require.NoError(t, err)
+
+	sysHandler := sys.NewHandler(*handler.NewHandler(nil, testutil.NewMockLogger()), nil)
+	sysHandler.Backups = stor
+	sysHandler.BackupPassphrase = "passphrase"
+	backupFn := middleware.RequireAdminToken("admin-token", sysHandler.Backup)
+
+	recorder := httptest.NewRecorder()
+	backupFn(recorder, httptest.NewRequest(http.MethodGet, "/sys/backup", http.NoBody))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/sys/backup", http.NoBody)
+	req.Header.Set("Authorization", "Bearer admin-token")
+
+	recorder = httptest.NewRecorder()
+	backupFn(recorder, req)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Header().Get("Content-Disposition"), "attachment")
+
+	snap, header, err := backup.Read(recorder.Body, "passphrase")
+	require.NoError(t, err)
+	assert.Equal(t, backup.EncryptionScrypt, header.Encryption)
+	assert.Equal(t, []*entity.User{user}, snap.Users)
+}
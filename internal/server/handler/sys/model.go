@@ -0,0 +1,8 @@
+// Package sys предоставляет функционал для служебных обработчиков запросов сервера.
+package sys
+
+// unsealReq - доля мастер-ключа, переданная оператором.
+type unsealReq struct {
+	Share string `json:"share"` // доля мастер-ключа (base64)
+	Reset bool   `json:"reset"` // сбросить ранее переданные доли вместо передачи новой
+}
@@ -0,0 +1,312 @@
+// Package vault предоставляет функционал для обработчиков запросов для работы с хранилищем.
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/middleware"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+)
+
+// Ограничения вложений записей.
+const (
+	AttachmentNameMaxSize = 256 // наибольший размер имени вложения в байтах
+	ItemMaxAttachments    = 32  // наибольшее количество вложений записи
+
+	// AttachmentDefaultMime - тип содержимого вложения, если клиент его не указал.
+	AttachmentDefaultMime = "application/octet-stream"
+)
+
+// Ошибки работы с вложениями.
+var (
+	ErrEmptyAttachmentName   = errors.New("empty attachment name")
+	ErrAttachmentNameTooLong = errors.New("attachment name too long")
+	ErrTooManyAttachments    = errors.New("too many attachments")
+	ErrUnknownAttachment     = errors.New("unknown attachment")
+)
+
+// UploadAttachment добавляет к записи вложение из тела запроса.
+//
+// Тело передаётся как в UploadContent: как есть или как multipart/form-data с частью file.
+// Имя вложения задаётся параметром запроса name или именем файла части, тип содержимого -
+// заголовком Content-Type тела или части. Параметр запроса version - текущая версия записи
+// у клиента (пусто - без проверки). Размер вложения учитывается в размере записи,
+// поэтому ограничен квотой на размер записи. В ответе - запись с новым вложением.
+func (h *Handler) UploadAttachment(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetOwnerID(req.Context())
+
+	id := req.PathValue("id")
+
+	version, err := parseOptionalVersion(req.URL.Query().Get("version"))
+	if err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	item, err := h.VStor.GetItem(req.Context(), uid, id)
+	if err != nil {
+		h.responseLookupError(resp, err)
+
+		return
+	}
+
+	if !h.checkAttachmentItem(resp, req, item, version) {
+		return
+	}
+
+	maxSize, err := h.attachmentMaxSize(item)
+	if err == nil && maxSize > 0 && req.ContentLength > maxSize {
+		err = fmt.Errorf("%w: %d > %d", ErrContentTooLarge, req.ContentLength, maxSize)
+	}
+
+	if err != nil {
+		h.ResponseError(resp, http.StatusRequestEntityTooLarge, err)
+
+		return
+	}
+
+	extendDeadlines(resp)
+
+	src, err := contentSource(req)
+	if err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	att, err := newAttachment(req, src)
+	if err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	wantHash := strings.ToLower(req.Header.Get(HeaderContentSHA256))
+
+	info, err := h.Blobs.Put(req.Context(), src, maxSize, wantHash)
+	if err != nil {
+		h.responseBlobError(resp, err)
+
+		return
+	}
+
+	att.Hash = info.Hash
+	att.Size = info.Size
+
+	item.Attachments = append(slices.Clone(item.Attachments), *att)
+
+	updated, ok := h.saveAttachments(resp, req, item)
+	if !ok {
+		return
+	}
+
+	h.Log.Info("Vault item attachment uploaded", "id", id, "attachment", att.ID, "size", att.Size)
+
+	h.ResponceWithJSONStatus(resp, http.StatusCreated, updated)
+}
+
+// DownloadAttachment отдаёт содержимое вложения записи потоком.
+//
+// Как и в DownloadContent, поддерживаются запросы диапазонов и условные запросы по ETag.
+// Имя вложения передаётся в заголовке Content-Disposition.
+func (h *Handler) DownloadAttachment(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetOwnerID(req.Context())
+
+	id := req.PathValue("id")
+
+	item, err := h.VStor.GetItem(req.Context(), uid, id)
+	if err != nil {
+		h.responseLookupError(resp, err)
+
+		return
+	}
+
+	idx, err := findAttachment(item, req.PathValue("attachmentId"))
+	if err != nil {
+		h.ResponseError(resp, http.StatusNotFound, err)
+
+		return
+	}
+
+	att := item.Attachments[idx]
+
+	if disposition := mime.FormatMediaType("attachment", map[string]string{"filename": att.Name}); disposition != "" {
+		resp.Header().Set("Content-Disposition", disposition)
+	}
+
+	h.serveBlob(resp, req, att.Hash, att.CreatedAt)
+}
+
+// DeleteAttachment удаляет вложение записи.
+//
+// Параметр запроса version - текущая версия записи у клиента (пусто - без проверки).
+// Содержимое вложения удаляется фоновой задачей, когда на него не остаётся ссылок.
+// В ответе - запись без вложения.
+func (h *Handler) DeleteAttachment(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetOwnerID(req.Context())
+
+	id := req.PathValue("id")
+
+	version, err := parseOptionalVersion(req.URL.Query().Get("version"))
+	if err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	item, err := h.VStor.GetItem(req.Context(), uid, id)
+	if err != nil {
+		h.responseLookupError(resp, err)
+
+		return
+	}
+
+	idx, err := findAttachment(item, req.PathValue("attachmentId"))
+	if err != nil {
+		h.ResponseError(resp, http.StatusNotFound, err)
+
+		return
+	}
+
+	if version != 0 {
+		item.Version = version
+	}
+
+	// Пустой, но не nil список удаляет последнее вложение (nil сохранил бы текущие).
+	item.Attachments = slices.Delete(slices.Clone(item.Attachments), idx, idx+1)
+
+	updated, ok := h.saveAttachments(resp, req, item)
+	if !ok {
+		return
+	}
+
+	h.ResponceWithJSON(resp, updated)
+}
+
+// saveAttachments сохраняет запись с изменёнными вложениями и возвращает её
+// с сохранённой хранилищем версией.
+// При ошибке формирует ответ и возвращает false.
+func (h *Handler) saveAttachments(
+	resp http.ResponseWriter,
+	req *http.Request,
+	item *entity.VaultItem,
+) (*entity.VaultItem, bool) {
+	if err := h.VStor.UpdateItem(req.Context(), item); err != nil {
+		h.responseUpsertError(resp, req, item, err)
+
+		return nil, false
+	}
+
+	return item, true
+}
+
+// checkAttachmentItem проверяет, что к записи можно добавить вложение,
+// и формирует ответ об ошибке, если нельзя.
+func (h *Handler) checkAttachmentItem(
+	resp http.ResponseWriter,
+	req *http.Request,
+	item *entity.VaultItem,
+	version int64,
+) bool {
+	if version != 0 && version != item.Version {
+		//nolint:exhaustruct // для ответа о конфликте нужны только идентификаторы и версия
+		stale := &entity.VaultItem{ID: item.ID, OwnerID: item.OwnerID, Version: version}
+
+		h.responseUpsertError(resp, req, stale, fmt.Errorf("item: %w", storage.ErrVersionConflict))
+
+		return false
+	}
+
+	if len(item.Attachments) >= ItemMaxAttachments {
+		h.ResponseError(resp, http.StatusBadRequest,
+			fmt.Errorf("%w: %d >= %d", ErrTooManyAttachments, len(item.Attachments), ItemMaxAttachments))
+
+		return false
+	}
+
+	return true
+}
+
+// attachmentMaxSize возвращает наибольший размер содержимого нового вложения записи
+// (0 - без ограничения): ограничение хранилища содержимого и остаток квоты на размер записи.
+func (h *Handler) attachmentMaxSize(item *entity.VaultItem) (int64, error) {
+	maxSize := h.BlobMaxSize
+
+	if h.Quota.MaxItemBytes == 0 {
+		return maxSize, nil
+	}
+
+	left := h.Quota.MaxItemBytes - item.Size()
+	if left <= 0 {
+		return 0, fmt.Errorf("%w: item size %d, max %d", ErrContentTooLarge, item.Size(), h.Quota.MaxItemBytes)
+	}
+
+	if maxSize == 0 || left < maxSize {
+		maxSize = left
+	}
+
+	return maxSize, nil
+}
+
+// newAttachment создаёт вложение с именем и типом содержимого из запроса
+// или из части multipart/form-data src.
+func newAttachment(req *http.Request, src io.Reader) (*entity.Attachment, error) {
+	name := req.URL.Query().Get("name")
+	contentType := req.Header.Get("Content-Type")
+
+	if part, ok := src.(*multipart.Part); ok {
+		if fileName := part.FileName(); fileName != "" {
+			name = fileName
+		}
+
+		contentType = part.Header.Get("Content-Type")
+	}
+
+	name = strings.TrimSpace(name)
+
+	switch {
+	case name == "":
+		return nil, ErrEmptyAttachmentName
+	case len(name) > AttachmentNameMaxSize:
+		return nil, fmt.Errorf("%w: %d > %d bytes", ErrAttachmentNameTooLong, len(name), AttachmentNameMaxSize)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = AttachmentDefaultMime
+	}
+
+	att := &entity.Attachment{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Mime:      mediaType,
+		Hash:      "",
+		Size:      0,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	return att, nil
+}
+
+// findAttachment возвращает индекс вложения записи с идентификатором attID.
+func findAttachment(item *entity.VaultItem, attID string) (int, error) {
+	idx := slices.IndexFunc(item.Attachments, func(att entity.Attachment) bool {
+		return att.ID == attID
+	})
+	if idx < 0 {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownAttachment, attID)
+	}
+
+	return idx, nil
+}
@@ -0,0 +1,226 @@
+package vault_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/blob"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/handler"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/handler/vault"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+	"github.com/mr-filatik/go-goph-keeper/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const attachmentOwner = "user-1"
+
+// newAttachmentHandler создаёт обработчик с хранилищем содержимого во временном каталоге
+// и хранилищем записей в памяти с записью-логином, к которой добавляются вложения.
+func newAttachmentHandler(t *testing.T) (*vault.Handler, *storage.MemoryStorage, *entity.VaultItem) {
+	t.Helper()
+
+	blobs, err := blob.NewDiskStore(t.TempDir())
+	require.NoError(t, err)
+
+	stor := storage.NewMemoryStorage()
+
+	//nolint:exhaustruct // not all fields needed in test
+	id, err := stor.CreateItem(context.Background(),
+		&entity.VaultItem{OwnerID: attachmentOwner, Type: entity.ItemLogin, Title: "bank"})
+	require.NoError(t, err)
+
+	item, err := stor.GetItem(context.Background(), attachmentOwner, id)
+	require.NoError(t, err)
+
+	mainHandler := handler.NewHandler(stor, testutil.NewMockLogger())
+	vaultHandler := vault.NewHandler(*mainHandler, stor)
+	vaultHandler.Blobs = blobs
+	vaultHandler.BlobMaxSize = 1024
+
+	return vaultHandler, stor, item
+}
+
+// uploadAttachment отправляет содержимое вложения data как есть и возвращает ответ.
+func uploadAttachment(
+	t *testing.T,
+	vaultHandler *vault.Handler,
+	itemID, query, data string,
+) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/vault/items/"+itemID+"/attachments"+query, strings.NewReader(data))
+	req.Header.Set("Content-Type", "application/pdf")
+	req.SetPathValue("id", itemID)
+	req = withUser(req)
+
+	rr := httptest.NewRecorder()
+	vaultHandler.UploadAttachment(rr, req)
+
+	return rr
+}
+
+// attachmentRequest выполняет запрос к вложению записи.
+func attachmentRequest(handle http.HandlerFunc, method, itemID, attID, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/vault/items/"+itemID+"/attachments/"+attID+query, nil)
+	req.SetPathValue("id", itemID)
+	req.SetPathValue("attachmentId", attID)
+	req = withUser(req)
+
+	rr := httptest.NewRecorder()
+	handle(rr, req)
+
+	return rr
+}
+
+/*
+	===== Handler attachments =====
+*/
+
+func TestVault_UploadAttachment(t *testing.T) {
+	t.Parallel()
+
+	vaultHandler, stor, item := newAttachmentHandler(t)
+
+	data := "recovery codes"
+
+	rr := uploadAttachment(t, vaultHandler, item.ID, "?name=codes.pdf&version=1", data)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	var updated entity.VaultItem
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &updated))
+	require.Len(t, updated.Attachments, 1)
+
+	att := updated.Attachments[0]
+	assert.NotEmpty(t, att.ID)
+	assert.Equal(t, "codes.pdf", att.Name)
+	assert.Equal(t, "application/pdf", att.Mime)
+	assert.Equal(t, contentHash(data), att.Hash)
+	assert.Equal(t, int64(len(data)), att.Size)
+	assert.Equal(t, int64(2), updated.Version)
+
+	stored, err := stor.GetItem(context.Background(), attachmentOwner, item.ID)
+	require.NoError(t, err)
+	assert.Equal(t, updated.Attachments, stored.Attachments)
+	assert.Equal(t, item.Size()+att.Size+int64(len(att.ID)+len(att.Name)+len(att.Mime)), stored.Size(),
+		"attachments count against the item size")
+
+	rr = uploadAttachment(t, vaultHandler, item.ID, "?name=key.txt&version=1", "key")
+	assert.Equal(t, http.StatusConflict, rr.Code, "stale version")
+
+	rr = uploadAttachment(t, vaultHandler, item.ID, "", data)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "no name")
+
+	rr = uploadAttachment(t, vaultHandler, "missing", "?name=key.txt", "key")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestVault_UploadAttachment_Multipart(t *testing.T) {
+	t.Parallel()
+
+	vaultHandler, _, item := newAttachmentHandler(t)
+
+	var body bytes.Buffer
+
+	form := multipart.NewWriter(&body)
+
+	part, err := form.CreateFormFile(vault.ContentFormField, "id_ed25519")
+	require.NoError(t, err)
+
+	_, err = part.Write([]byte("private key"))
+	require.NoError(t, err)
+	require.NoError(t, form.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/vault/items/"+item.ID+"/attachments", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.SetPathValue("id", item.ID)
+	req = withUser(req)
+
+	rr := httptest.NewRecorder()
+	vaultHandler.UploadAttachment(rr, req)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	var updated entity.VaultItem
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &updated))
+	require.Len(t, updated.Attachments, 1)
+	assert.Equal(t, "id_ed25519", updated.Attachments[0].Name)
+	assert.Equal(t, "application/octet-stream", updated.Attachments[0].Mime)
+	assert.Equal(t, contentHash("private key"), updated.Attachments[0].Hash)
+}
+
+func TestVault_UploadAttachment_ItemSizeLimit(t *testing.T) {
+	t.Parallel()
+
+	vaultHandler, stor, item := newAttachmentHandler(t)
+	vaultHandler.Quota.MaxItemBytes = item.Size() + 16
+
+	rr := uploadAttachment(t, vaultHandler, item.ID, "?name=big.bin", strings.Repeat("x", 32))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code, rr.Body.String())
+
+	stor.SetQuota(vaultHandler.Quota)
+
+	rr = uploadAttachment(t, vaultHandler, item.ID, "?name=a.bin", "1234567")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code, "content fits, but not with the attachment name")
+
+	stored, err := stor.GetItem(context.Background(), attachmentOwner, item.ID)
+	require.NoError(t, err)
+	assert.Empty(t, stored.Attachments)
+}
+
+func TestVault_DownloadAttachment(t *testing.T) {
+	t.Parallel()
+
+	vaultHandler, _, item := newAttachmentHandler(t)
+
+	rr := uploadAttachment(t, vaultHandler, item.ID, "?name=codes.pdf", "recovery codes")
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	var updated entity.VaultItem
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &updated))
+
+	att := updated.Attachments[0]
+
+	rr = attachmentRequest(vaultHandler.DownloadAttachment, http.MethodGet, item.ID, att.ID, "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "recovery codes", rr.Body.String())
+	assert.Equal(t, `attachment; filename=codes.pdf`, rr.Header().Get("Content-Disposition"))
+	assert.Equal(t, att.Hash, rr.Header().Get(vault.HeaderContentSHA256))
+
+	rr = attachmentRequest(vaultHandler.DownloadAttachment, http.MethodGet, item.ID, "missing", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestVault_DeleteAttachment(t *testing.T) {
+	t.Parallel()
+
+	vaultHandler, stor, item := newAttachmentHandler(t)
+
+	rr := uploadAttachment(t, vaultHandler, item.ID, "?name=codes.pdf", "recovery codes")
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	var updated entity.VaultItem
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &updated))
+
+	attID := updated.Attachments[0].ID
+
+	rr = attachmentRequest(vaultHandler.DeleteAttachment, http.MethodDelete, item.ID, attID, "?version=1")
+	assert.Equal(t, http.StatusConflict, rr.Code, "stale version")
+
+	rr = attachmentRequest(vaultHandler.DeleteAttachment, http.MethodDelete, item.ID, attID, "?version=2")
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	stored, err := stor.GetItem(context.Background(), attachmentOwner, item.ID)
+	require.NoError(t, err)
+	assert.Empty(t, stored.Attachments)
+	assert.Equal(t, item.Size(), stored.Size())
+
+	rr = attachmentRequest(vaultHandler.DeleteAttachment, http.MethodDelete, item.ID, attID, "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
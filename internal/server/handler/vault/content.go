@@ -0,0 +1,279 @@
+// Package vault предоставляет функционал для обработчиков запросов для работы с хранилищем.
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/blob"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/middleware"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+)
+
+// Параметры передачи содержимого бинарных записей.
+const (
+	// HeaderContentSHA256 - заголовок с SHA-256 хешем содержимого (hex).
+	HeaderContentSHA256 = "X-Content-SHA256"
+
+	// ContentFormField - название части multipart/form-data с содержимым.
+	ContentFormField = "file"
+
+	// ContentTimeout - время на передачу содержимого вместо общих таймаутов сервера.
+	ContentTimeout = 30 * time.Minute
+)
+
+// Ошибки работы с содержимым бинарных записей.
+var (
+	ErrNotBinaryItem   = errors.New("item is not binary")
+	ErrNoContent       = errors.New("item has no content")
+	ErrNoContentPart   = errors.New("multipart form has no file part")
+	ErrContentTooLarge = errors.New("content too large")
+)
+
+// UploadContent сохраняет содержимое бинарной записи из тела запроса.
+//
+// Тело передаётся потоком: как есть (в том числе chunked) или как multipart/form-data
+// с частью file. Заголовок X-Content-SHA256 задаёт ожидаемый хеш содержимого,
+// при расхождении содержимое отклоняется. Параметр запроса version - текущая версия
+// записи у клиента (пусто - без проверки). В ответе - новая версия записи.
+func (h *Handler) UploadContent(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetOwnerID(req.Context())
+
+	id := req.PathValue("id")
+
+	version, err := parseOptionalVersion(req.URL.Query().Get("version"))
+	if err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	item, err := h.VStor.GetItem(req.Context(), uid, id)
+	if err != nil {
+		h.responseLookupError(resp, err)
+
+		return
+	}
+
+	if item.Type != entity.ItemBinary {
+		h.ResponseError(resp, http.StatusBadRequest, fmt.Errorf("%w: %s", ErrNotBinaryItem, item.Type))
+
+		return
+	}
+
+	if h.BlobMaxSize > 0 && req.ContentLength > h.BlobMaxSize {
+		h.ResponseError(resp, http.StatusRequestEntityTooLarge,
+			fmt.Errorf("%w: %d > %d", ErrContentTooLarge, req.ContentLength, h.BlobMaxSize))
+
+		return
+	}
+
+	extendDeadlines(resp)
+
+	src, err := contentSource(req)
+	if err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	wantHash := strings.ToLower(req.Header.Get(HeaderContentSHA256))
+
+	info, err := h.Blobs.Put(req.Context(), src, h.BlobMaxSize, wantHash)
+	if err != nil {
+		h.responseBlobError(resp, err)
+
+		return
+	}
+
+	content := &entity.Content{
+		Hash: info.Hash,
+		Size: info.Size,
+	}
+
+	updated, err := h.VStor.SetItemContent(req.Context(), uid, id, version, content)
+	if err != nil {
+		h.responseContentError(resp, req, uid, id, version, err)
+
+		return
+	}
+
+	h.Log.Info("Vault item content uploaded", "id", id, "hash", info.Hash, "size", info.Size)
+
+	h.ResponceWithJSON(resp, updated)
+}
+
+// DownloadContent отдаёт содержимое бинарной записи потоком.
+//
+// Поддерживаются запросы диапазонов (Range) и условные запросы по ETag,
+// равному хешу содержимого.
+func (h *Handler) DownloadContent(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetOwnerID(req.Context())
+
+	id := req.PathValue("id")
+
+	item, err := h.VStor.GetItem(req.Context(), uid, id)
+	if err != nil {
+		h.responseLookupError(resp, err)
+
+		return
+	}
+
+	if item.Content == nil {
+		h.ResponseError(resp, http.StatusNotFound, fmt.Errorf("%w: %s", ErrNoContent, id))
+
+		return
+	}
+
+	h.serveBlob(resp, req, item.Content.Hash, item.UpdatedAt)
+}
+
+// DeleteContent удаляет ссылку бинарной записи на содержимое.
+//
+// Параметр запроса version - текущая версия записи у клиента (пусто - без проверки).
+// Само содержимое удаляется фоновой задачей, когда на него не остаётся ссылок.
+func (h *Handler) DeleteContent(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetOwnerID(req.Context())
+
+	id := req.PathValue("id")
+
+	version, err := parseOptionalVersion(req.URL.Query().Get("version"))
+	if err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	updated, err := h.VStor.SetItemContent(req.Context(), uid, id, version, nil)
+	if err != nil {
+		h.responseContentError(resp, req, uid, id, version, err)
+
+		return
+	}
+
+	h.ResponceWithJSON(resp, updated)
+}
+
+// serveBlob отдаёт содержимое с хешем hash потоком, поддерживая запросы диапазонов
+// и условные запросы по ETag. Заголовки, заданные до вызова, сохраняются.
+func (h *Handler) serveBlob(resp http.ResponseWriter, req *http.Request, hash string, modTime time.Time) {
+	reader, info, err := h.Blobs.Open(req.Context(), hash)
+	if err != nil {
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	defer func() {
+		if closeErr := reader.Close(); closeErr != nil {
+			h.Log.Error("Content closing error", closeErr)
+		}
+	}()
+
+	extendDeadlines(resp)
+
+	resp.Header().Set("Content-Type", "application/octet-stream")
+	resp.Header().Set("ETag", strconv.Quote(info.Hash))
+	resp.Header().Set(HeaderContentSHA256, info.Hash)
+
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		http.ServeContent(resp, req, "", modTime, seeker)
+
+		return
+	}
+
+	resp.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	resp.WriteHeader(http.StatusOK)
+
+	if _, err := io.Copy(resp, reader); err != nil {
+		h.Log.Error("Content streaming error", err)
+	}
+}
+
+// responseBlobError формирует ответ при ошибке сохранения содержимого.
+func (h *Handler) responseBlobError(resp http.ResponseWriter, err error) {
+	var maxErr *http.MaxBytesError
+
+	switch {
+	case errors.Is(err, blob.ErrTooLarge), errors.As(err, &maxErr):
+		h.ResponseError(resp, http.StatusRequestEntityTooLarge, err)
+	case errors.Is(err, blob.ErrHashMismatch), errors.Is(err, blob.ErrInvalidHash):
+		h.ResponseError(resp, http.StatusBadRequest, err)
+	default:
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+	}
+}
+
+// responseContentError формирует ответ при ошибке замены содержимого записи.
+func (h *Handler) responseContentError(
+	resp http.ResponseWriter,
+	req *http.Request,
+	uid, id string,
+	version int64,
+	err error,
+) {
+	if errors.Is(err, storage.ErrEntityNotFound) {
+		h.ResponseError(resp, http.StatusNotFound, err)
+
+		return
+	}
+
+	//nolint:exhaustruct // для ответа о конфликте нужны только идентификаторы и версия
+	item := &entity.VaultItem{ID: id, OwnerID: uid, Version: version}
+
+	h.responseUpsertError(resp, req, item, err)
+}
+
+// contentSource возвращает поток содержимого из тела запроса.
+func contentSource(req *http.Request) (io.Reader, error) {
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		return req.Body, nil //nolint:nilerr // тело без типа или другого типа передаётся как есть
+	}
+
+	reader, err := req.MultipartReader()
+	if err != nil {
+		return nil, fmt.Errorf("multipart: %w", err)
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil, ErrNoContentPart
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("multipart: %w", err)
+		}
+
+		if part.FormName() == ContentFormField {
+			return part, nil
+		}
+	}
+}
+
+// extendDeadlines продлевает сроки чтения и записи соединения на время передачи содержимого.
+func extendDeadlines(resp http.ResponseWriter) {
+	ctrl := http.NewResponseController(resp)
+	deadline := time.Now().Add(ContentTimeout)
+
+	// Если ResponseWriter не поддерживает сроки, действуют общие таймауты сервера.
+	_ = ctrl.SetReadDeadline(deadline)
+	_ = ctrl.SetWriteDeadline(deadline)
+}
+
+// parseOptionalVersion разбирает необязательный номер версии записи (пусто - 0).
+func parseOptionalVersion(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	return parseVersion(value)
+}
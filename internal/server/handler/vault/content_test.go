@@ -0,0 +1,357 @@
+package vault_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/blob"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/handler"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/handler/vault"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+	"github.com/mr-filatik/go-goph-keeper/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newContentHandler создаёт обработчик с хранилищем содержимого во временном каталоге
+// и хранилищем записей, в котором запись "1" имеет тип itemType и содержимое content.
+func newContentHandler(
+	t *testing.T,
+	itemType entity.ItemType,
+	content *entity.Content,
+) (*vault.Handler, *blob.DiskStore, *[]*entity.Content) {
+	t.Helper()
+
+	blobs, err := blob.NewDiskStore(t.TempDir())
+	require.NoError(t, err)
+
+	stored := make([]*entity.Content, 0)
+
+	mockLogger := testutil.NewMockLogger()
+	mainHandler := handler.NewHandler(nil, mockLogger)
+	vaultHandler := vault.NewHandler(*mainHandler, &mockStorage{
+		GetItemFn: func(_ context.Context, ownerID, id string) (*entity.VaultItem, error) {
+			if id != "1" {
+				return nil, storage.ErrEntityNotFound
+			}
+
+			//nolint:exhaustruct // not all fields needed in test
+			item := &entity.VaultItem{
+				ID:        id,
+				OwnerID:   ownerID,
+				Type:      itemType,
+				Content:   content,
+				Version:   2,
+				UpdatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+			}
+
+			return item, nil
+		},
+		SetItemContentFn: func(
+			_ context.Context,
+			ownerID, itemID string,
+			version int64,
+			content *entity.Content,
+		) (*entity.VaultItem, error) {
+			if version != 0 && version != 2 {
+				return nil, storage.ErrVersionConflict
+			}
+
+			stored = append(stored, content)
+
+			//nolint:exhaustruct // not all fields needed in test
+			item := &entity.VaultItem{ID: itemID, OwnerID: ownerID, Content: content, Version: 3}
+
+			return item, nil
+		},
+	})
+	vaultHandler.Blobs = blobs
+	vaultHandler.BlobMaxSize = 1024
+
+	return vaultHandler, blobs, &stored
+}
+
+func contentHash(data string) string {
+	sum := sha256.Sum256([]byte(data))
+
+	return hex.EncodeToString(sum[:])
+}
+
+/*
+	===== Handler.UploadContent =====
+*/
+
+func TestVault_UploadContent_Raw(t *testing.T) {
+	t.Parallel()
+
+	vaultHandler, blobs, stored := newContentHandler(t, entity.ItemBinary, nil)
+
+	data := "binary payload"
+
+	req := httptest.NewRequest(http.MethodPut, "/vault/items/1/content?version=2", strings.NewReader(data))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set(vault.HeaderContentSHA256, strings.ToUpper(contentHash(data)))
+	req.SetPathValue("id", "1")
+	req = withUser(req)
+
+	rr := httptest.NewRecorder()
+	vaultHandler.UploadContent(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.Len(t, *stored, 1)
+	assert.Equal(t, &entity.Content{Hash: contentHash(data), Size: int64(len(data))}, (*stored)[0])
+	assert.Contains(t, rr.Body.String(), contentHash(data))
+
+	reader, _, err := blobs.Open(context.Background(), contentHash(data))
+	require.NoError(t, err)
+
+	defer func() { _ = reader.Close() }()
+
+	got, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, data, string(got))
+}
+
+func TestVault_UploadContent_Multipart(t *testing.T) {
+	t.Parallel()
+
+	vaultHandler, _, stored := newContentHandler(t, entity.ItemBinary, nil)
+
+	var body bytes.Buffer
+
+	form := multipart.NewWriter(&body)
+	require.NoError(t, form.WriteField("comment", "ignored"))
+
+	part, err := form.CreateFormFile(vault.ContentFormField, "photo.jpg")
+	require.NoError(t, err)
+
+	_, err = part.Write([]byte("jpeg bytes"))
+	require.NoError(t, err)
+	require.NoError(t, form.Close())
+
+	req := httptest.NewRequest(http.MethodPut, "/vault/items/1/content", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.SetPathValue("id", "1")
+	req = withUser(req)
+
+	rr := httptest.NewRecorder()
+	vaultHandler.UploadContent(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.Len(t, *stored, 1)
+	assert.Equal(t, contentHash("jpeg bytes"), (*stored)[0].Hash)
+}
+
+func TestVault_UploadContent_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		itemType entity.ItemType
+		target   string
+		body     io.Reader
+		hash     string
+		code     int
+	}{
+		{
+			name:     "hash mismatch",
+			itemType: entity.ItemBinary,
+			target:   "/vault/items/1/content",
+			body:     strings.NewReader("data"),
+			hash:     contentHash("other"),
+			code:     http.StatusBadRequest,
+		},
+		{
+			name:     "content length too large",
+			itemType: entity.ItemBinary,
+			target:   "/vault/items/1/content",
+			body:     strings.NewReader(strings.Repeat("x", 2048)),
+			hash:     "",
+			code:     http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:     "chunked body too large",
+			itemType: entity.ItemBinary,
+			target:   "/vault/items/1/content",
+			body:     io.MultiReader(strings.NewReader(strings.Repeat("x", 2048))),
+			hash:     "",
+			code:     http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:     "not binary",
+			itemType: entity.ItemText,
+			target:   "/vault/items/1/content",
+			body:     strings.NewReader("data"),
+			hash:     "",
+			code:     http.StatusBadRequest,
+		},
+		{
+			name:     "version conflict",
+			itemType: entity.ItemBinary,
+			target:   "/vault/items/1/content?version=1",
+			body:     strings.NewReader("data"),
+			hash:     "",
+			code:     http.StatusConflict,
+		},
+		{
+			name:     "bad version",
+			itemType: entity.ItemBinary,
+			target:   "/vault/items/1/content?version=x",
+			body:     strings.NewReader("data"),
+			hash:     "",
+			code:     http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			vaultHandler, _, stored := newContentHandler(t, tt.itemType, nil)
+
+			req := httptest.NewRequest(http.MethodPut, tt.target, tt.body)
+			if tt.hash != "" {
+				req.Header.Set(vault.HeaderContentSHA256, tt.hash)
+			}
+
+			req.SetPathValue("id", "1")
+			req = withUser(req)
+
+			rr := httptest.NewRecorder()
+			vaultHandler.UploadContent(rr, req)
+
+			assert.Equal(t, tt.code, rr.Code)
+
+			if tt.code != http.StatusConflict {
+				assert.Empty(t, *stored)
+			}
+		})
+	}
+}
+
+func TestVault_UploadContent_Quota(t *testing.T) {
+	t.Parallel()
+
+	blobs, err := blob.NewDiskStore(t.TempDir())
+	require.NoError(t, err)
+
+	mockLogger := testutil.NewMockLogger()
+	mainHandler := handler.NewHandler(nil, mockLogger)
+	vaultHandler := vault.NewHandler(*mainHandler, &mockStorage{
+		GetItemFn: func(_ context.Context, ownerID, id string) (*entity.VaultItem, error) {
+			//nolint:exhaustruct // not all fields needed in test
+			return &entity.VaultItem{ID: id, OwnerID: ownerID, Type: entity.ItemBinary, Version: 1}, nil
+		},
+		SetItemContentFn: func(context.Context, string, string, int64, *entity.Content) (*entity.VaultItem, error) {
+			return nil, fmt.Errorf("item: %w", &storage.QuotaError{
+				Limit: storage.QuotaBytes, Max: 100, Used: 90, Requested: 20,
+			})
+		},
+	})
+	vaultHandler.Blobs = blobs
+
+	req := httptest.NewRequest(http.MethodPut, "/vault/items/1/content", strings.NewReader(strings.Repeat("x", 20)))
+	req.SetPathValue("id", "1")
+	req = withUser(req)
+
+	rr := httptest.NewRecorder()
+	vaultHandler.UploadContent(rr, req)
+
+	require.Equal(t, http.StatusInsufficientStorage, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), `"limit":"bytes"`)
+}
+
+/*
+	===== Handler.DownloadContent =====
+*/
+
+func TestVault_DownloadContent(t *testing.T) {
+	t.Parallel()
+
+	data := "0123456789"
+	content := &entity.Content{Hash: contentHash(data), Size: int64(len(data))}
+
+	vaultHandler, blobs, _ := newContentHandler(t, entity.ItemBinary, content)
+
+	_, err := blobs.Put(context.Background(), strings.NewReader(data), 0, "")
+	require.NoError(t, err)
+
+	download := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/vault/items/1/content", http.NoBody)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+
+		req.SetPathValue("id", "1")
+		req = withUser(req)
+
+		rr := httptest.NewRecorder()
+		vaultHandler.DownloadContent(rr, req)
+
+		return rr
+	}
+
+	rr := download("", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, data, rr.Body.String())
+	assert.Equal(t, "application/octet-stream", rr.Header().Get("Content-Type"))
+	assert.Equal(t, content.Hash, rr.Header().Get(vault.HeaderContentSHA256))
+
+	rr = download("Range", "bytes=2-4")
+	require.Equal(t, http.StatusPartialContent, rr.Code)
+	assert.Equal(t, "234", rr.Body.String())
+
+	rr = download("If-None-Match", `"`+content.Hash+`"`)
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+}
+
+func TestVault_DownloadContent_NotFound(t *testing.T) {
+	t.Parallel()
+
+	vaultHandler, _, _ := newContentHandler(t, entity.ItemBinary, nil)
+
+	for _, id := range []string{"1", "404"} {
+		req := httptest.NewRequest(http.MethodGet, "/vault/items/"+id+"/content", http.NoBody)
+		req.SetPathValue("id", id)
+		req = withUser(req)
+
+		rr := httptest.NewRecorder()
+		vaultHandler.DownloadContent(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code, id)
+	}
+}
+
+/*
+	===== Handler.DeleteContent =====
+*/
+
+func TestVault_DeleteContent(t *testing.T) {
+	t.Parallel()
+
+	content := &entity.Content{Hash: contentHash("data"), Size: 4}
+
+	vaultHandler, _, stored := newContentHandler(t, entity.ItemBinary, content)
+
+	req := httptest.NewRequest(http.MethodDelete, "/vault/items/1/content", http.NoBody)
+	req.SetPathValue("id", "1")
+	req = withUser(req)
+
+	rr := httptest.NewRecorder()
+	vaultHandler.DeleteContent(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.Len(t, *stored, 1)
+	assert.Nil(t, (*stored)[0])
+}
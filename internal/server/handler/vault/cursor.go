@@ -0,0 +1,120 @@
+// Package vault предоставляет функционал для обработчиков запросов для работы с хранилищем.
+package vault
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+)
+
+// cursorPrefix - префикс версии формата курсора синхронизации.
+const cursorPrefix = "v1:"
+
+// Ошибки разбора курсоров.
+var (
+	// ErrInvalidCursor - курсор синхронизации повреждён или выдан не этим сервером.
+	ErrInvalidCursor = errors.New("invalid sync cursor")
+
+	// ErrInvalidPageCursor - курсор страницы записей повреждён или выдан для другой сортировки.
+	ErrInvalidPageCursor = errors.New("invalid page cursor")
+)
+
+// encodeCursor кодирует номер последнего полученного изменения в непрозрачный курсор.
+func encodeCursor(seq int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.FormatInt(seq, 10)))
+}
+
+// decodeCursor извлекает номер последнего полученного изменения из курсора.
+//
+// Пустой курсор означает синхронизацию с начала.
+func decodeCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+
+	value, ok := strings.CutPrefix(string(raw), cursorPrefix)
+	if !ok {
+		return 0, ErrInvalidCursor
+	}
+
+	seq, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seq < 0 {
+		return 0, ErrInvalidCursor
+	}
+
+	return seq, nil
+}
+
+// pageCursor - содержимое курсора страницы записей.
+//
+// Вместе с позицией хранится сортировка, для которой курсор выдан.
+type pageCursor struct {
+	UpdatedAt time.Time            `json:"u"`
+	SortBy    entity.ItemSortField `json:"s"`
+	Title     string               `json:"t,omitempty"`
+	ID        string               `json:"i"`
+	Desc      bool                 `json:"d,omitempty"`
+}
+
+// encodePageCursor кодирует позицию последней записи страницы в непрозрачный курсор.
+func encodePageCursor(query *entity.ItemQuery, item *entity.VaultItem) string {
+	cursor := pageCursor{
+		UpdatedAt: item.UpdatedAt,
+		SortBy:    query.SortBy,
+		Title:     "",
+		ID:        item.ID,
+		Desc:      query.Desc,
+	}
+
+	if query.SortBy == entity.SortByTitle {
+		cursor.Title = item.Title
+	}
+
+	raw, _ := json.Marshal(cursor) //nolint:errchkjson // структура всегда сериализуется
+
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodePageCursor извлекает позицию из курсора страницы записей.
+//
+// Курсор должен быть выдан для той же сортировки, что и query.
+// Пустой курсор означает первую страницу.
+func decodePageCursor(cursor string, query *entity.ItemQuery) (*entity.ItemCursor, error) {
+	if cursor == "" {
+		return nil, nil //nolint:nilnil // первая страница
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPageCursor, err)
+	}
+
+	var decoded pageCursor
+
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPageCursor, err)
+	}
+
+	if decoded.ID == "" || decoded.SortBy != query.SortBy || decoded.Desc != query.Desc {
+		return nil, ErrInvalidPageCursor
+	}
+
+	after := &entity.ItemCursor{
+		UpdatedAt: decoded.UpdatedAt,
+		Title:     decoded.Title,
+		ID:        decoded.ID,
+	}
+
+	return after, nil
+}
@@ -0,0 +1,43 @@
+// Package vault предоставляет функционал для обработчиков запросов для работы с хранилищем.
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+)
+
+// ErrInvalidExpiry - срок действия записи уже истёк.
+var ErrInvalidExpiry = errors.New("invalid expiry")
+
+// normalizeExpiry проверяет срок действия записи из запроса и приводит его к UTC.
+// Пустой срок (nil) означает бессрочную запись.
+func normalizeExpiry(expiresAt *time.Time, now time.Time) (*time.Time, error) {
+	if expiresAt == nil {
+		return nil, nil
+	}
+
+	if !expiresAt.After(now) {
+		return nil, fmt.Errorf("%w: %s is not after %s",
+			ErrInvalidExpiry, expiresAt.Format(time.RFC3339), now.Format(time.RFC3339))
+	}
+
+	utc := expiresAt.UTC()
+
+	return &utc, nil
+}
+
+// expireChanges заменяет в ленте изменений записи с истёкшим сроком, которые ещё
+// не удалила фоновая задача, на отметки об удалении по сроку: клиент удаляет
+// такие записи так же, как после удаления задачей.
+func expireChanges(items []*entity.VaultItem, now time.Time) []*entity.VaultItem {
+	for i, item := range items {
+		if item.IsExpired(now) {
+			items[i] = item.AsExpired()
+		}
+	}
+
+	return items
+}
@@ -0,0 +1,128 @@
+package vault_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/handler"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/handler/vault"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+	"github.com/mr-filatik/go-goph-keeper/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const expireOwner = "user-1"
+
+// newExpireHandler создаёт обработчик с хранилищем записей в памяти.
+func newExpireHandler() (*vault.Handler, *storage.MemoryStorage) {
+	stor := storage.NewMemoryStorage()
+	mainHandler := handler.NewHandler(stor, testutil.NewMockLogger())
+
+	return vault.NewHandler(*mainHandler, stor), stor
+}
+
+/*
+	===== Handler expiring items =====
+*/
+
+func TestVault_UpsertItem_Expiry(t *testing.T) {
+	t.Parallel()
+
+	vaultHandler, stor := newExpireHandler()
+
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	rr := shareRequest(vaultHandler.UpsertItem, expireOwner, http.MethodPost, "/vault/items",
+		map[string]any{"type": entity.ItemText, "title": "otp", "expiresAt": expiresAt}, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	items, err := stor.ListItems(context.Background(), expireOwner)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	require.NotNil(t, items[0].ExpiresAt)
+	assert.True(t, expiresAt.Equal(*items[0].ExpiresAt))
+
+	rr = shareRequest(vaultHandler.UpsertItem, expireOwner, http.MethodPost, "/vault/items",
+		map[string]any{"type": entity.ItemText, "title": "stale", "expiresAt": time.Now().Add(-time.Minute)}, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "expiry in the past")
+}
+
+func TestVault_Sync_Expired(t *testing.T) {
+	t.Parallel()
+
+	vaultHandler, stor := newExpireHandler()
+
+	past := time.Now().UTC().Add(-time.Minute)
+
+	//nolint:exhaustruct // not all fields needed in test
+	id, err := stor.CreateItem(context.Background(),
+		&entity.VaultItem{OwnerID: expireOwner, Type: entity.ItemText, Title: "otp", ExpiresAt: &past})
+	require.NoError(t, err)
+
+	code, page := doSync(t, vaultHandler, "")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, id, page.Items[0]["id"])
+	assert.Equal(t, true, page.Items[0]["deleted"], "expired item not reaped yet is sent as deleted")
+	assert.Equal(t, true, page.Items[0]["expired"])
+	assert.Empty(t, page.Items[0]["title"], "expired secrets are not sent")
+
+	_, err = stor.ExpireItems(context.Background(), time.Now())
+	require.NoError(t, err)
+
+	code, page = doSync(t, vaultHandler, "")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, true, page.Items[0]["expired"], "reaped item leaves an expired tombstone")
+}
+
+func TestVault_UpsertItem_ExpiredItem(t *testing.T) {
+	t.Parallel()
+
+	vaultHandler, stor := newExpireHandler()
+
+	past := time.Now().UTC().Add(-time.Minute)
+
+	//nolint:exhaustruct // not all fields needed in test
+	id, err := stor.CreateItem(context.Background(),
+		&entity.VaultItem{OwnerID: expireOwner, Type: entity.ItemText, Title: "otp", ExpiresAt: &past})
+	require.NoError(t, err)
+
+	rr := shareRequest(vaultHandler.UpsertItem, expireOwner, http.MethodPost, "/vault/items",
+		map[string]any{"id": id, "type": entity.ItemText, "title": "otp", "expiresAt": time.Now().Add(time.Hour)}, nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code, "expired item can not be extended before it is reaped")
+}
+
+func TestVault_SyncPush_Expiry(t *testing.T) {
+	t.Parallel()
+
+	vaultHandler, stor := newExpireHandler()
+
+	//nolint:exhaustruct // not all fields needed in test
+	id, err := stor.CreateItem(context.Background(),
+		&entity.VaultItem{OwnerID: expireOwner, Type: entity.ItemText, Title: "old"})
+	require.NoError(t, err)
+
+	past := time.Now().Add(-time.Minute).Format(time.RFC3339)
+
+	code, page := doSyncPush(t, vaultHandler, `{"changes":[
+		{"op":"upsert","id":"a","type":"text","title":"otp","expiresAt":"`+past+`"},
+		{"op":"upsert","id":"b","type":"text","title":"note"},
+		{"op":"delete","id":"`+id+`","expiresAt":"`+past+`"}
+	]}`)
+	require.Equal(t, http.StatusOK, code, "expired change does not fail the batch")
+	require.Len(t, page.Results, 3)
+	assert.Equal(t, "rejected", page.Results[0]["status"], "expiry in the past")
+	assert.Contains(t, page.Results[0]["error"], vault.ErrInvalidExpiry.Error())
+	assert.Equal(t, "applied", page.Results[1]["status"])
+	assert.Equal(t, "applied", page.Results[2]["status"], "deletes ignore the expiry")
+
+	items, err := stor.ListItems(context.Background(), expireOwner)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "note", items[0].Title)
+}
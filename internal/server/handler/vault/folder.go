@@ -0,0 +1,306 @@
+// Package vault предоставляет функционал для обработчиков запросов для работы с хранилищем.
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/handler"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/middleware"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+)
+
+// Ограничения размера запросов работы с папками.
+const (
+	FolderNameMaxSize = 256     // наибольший размер названия папки в байтах
+	FolderReqMaxSize  = 4 << 10 // наибольший размер тела запроса папки
+)
+
+// Ошибки работы с папками.
+var (
+	ErrEmptyFolderName   = errors.New("folder name is empty")
+	ErrFolderNameTooLong = errors.New("folder name too long")
+	ErrUnknownFolder     = errors.New("unknown folder")
+)
+
+// ListFolders выводит папки владельца в порядке ID.
+func (h *Handler) ListFolders(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetOwnerID(req.Context())
+
+	folders, err := h.Folders.ListFolders(req.Context(), uid)
+	if err != nil {
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	h.ResponceWithJSON(resp, folders)
+}
+
+// CreateFolder создаёт папку в корне или в родительской папке parentId.
+//
+// Если клиент не указал ID, его выдаёт сервер. Папка с уже занятым ID отклоняется с кодом 409.
+func (h *Handler) CreateFolder(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetOwnerID(req.Context())
+
+	folderReq, ok := h.readFolderReq(resp, req)
+	if !ok {
+		return
+	}
+
+	folder := newFolder(uid, folderReq.ID, folderReq)
+
+	if err := h.Folders.CreateFolder(req.Context(), folder); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrEntityAlreadyExists):
+			h.ResponseError(resp, http.StatusConflict, err)
+		case errors.Is(err, storage.ErrUnknownParent):
+			h.ResponseError(resp, http.StatusBadRequest, err)
+		default:
+			h.ResponseError(resp, http.StatusInternalServerError, err)
+		}
+
+		return
+	}
+
+	h.ResponceWithJSONStatus(resp, http.StatusCreated, folder)
+}
+
+// UpdateFolder переименовывает папку или переносит её в другую родительскую папку.
+//
+// Перенос папки в саму себя или во вложенную в неё папку отклоняется с кодом 400.
+// При расхождении версии с серверной возвращает 409 и текущую папку сервера.
+func (h *Handler) UpdateFolder(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetOwnerID(req.Context())
+
+	id := req.PathValue("id")
+
+	folderReq, ok := h.readFolderReq(resp, req)
+	if !ok {
+		return
+	}
+
+	folder := newFolder(uid, id, folderReq)
+
+	if err := h.Folders.UpdateFolder(req.Context(), folder); err != nil {
+		h.responseFolderUpdateError(resp, req, uid, id, err)
+
+		return
+	}
+
+	h.ResponceWithJSON(resp, folder)
+}
+
+// DeleteFolder удаляет пустую папку.
+//
+// Параметры запроса:
+//   - version: текущая версия папки у клиента (пусто - без проверки).
+//
+// Папка с записями или вложенными папками не удаляется: ответ 409.
+func (h *Handler) DeleteFolder(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetOwnerID(req.Context())
+
+	id := req.PathValue("id")
+
+	version, err := parseOptionalVersion(req.URL.Query().Get("version"))
+	if err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	if err = h.Folders.DeleteFolder(req.Context(), uid, id, version); err != nil {
+		h.responseFolderUpdateError(resp, req, uid, id, err)
+
+		return
+	}
+
+	resp.WriteHeader(http.StatusNoContent)
+}
+
+// MoveItems переносит записи в папку folderId (пусто - из папок в корень).
+//
+// Для каждой записи возвращается результат: applied (с новым состоянием записи),
+// conflict (с текущей записью сервера) или rejected, если записи нет.
+func (h *Handler) MoveItems(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetOwnerID(req.Context())
+
+	var moveReq moveItemsReq
+
+	limitBody(resp, req, FolderReqMaxSize)
+
+	if err := handler.GetDataFromBodyJSON(req, &moveReq); err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	if len(moveReq.Items) > SyncMaxBatch {
+		h.ResponseError(resp, http.StatusBadRequest,
+			fmt.Errorf("%w: %d > %d", ErrBatchTooLarge, len(moveReq.Items), SyncMaxBatch))
+
+		return
+	}
+
+	if err := h.checkItemFolder(req, uid, moveReq.FolderID); err != nil {
+		h.responseFolderError(resp, err)
+
+		return
+	}
+
+	moveResp := itemResultsResp{Results: make([]changeResp, 0, len(moveReq.Items))}
+
+	for _, ref := range moveReq.Items {
+		res, err := h.moveItem(req, uid, ref, moveReq.FolderID)
+		if err != nil {
+			h.ResponseError(resp, http.StatusInternalServerError, err)
+
+			return
+		}
+
+		moveResp.Results = append(moveResp.Results, *res)
+	}
+
+	h.ResponceWithJSON(resp, moveResp)
+}
+
+// moveItem переносит одну запись в папку folderID.
+func (h *Handler) moveItem(req *http.Request, uid string, ref itemRefReq, folderID string) (*changeResp, error) {
+	item, err := h.VStor.GetItem(req.Context(), uid, ref.ID)
+	if errors.Is(err, storage.ErrEntityNotFound) {
+		return &changeResp{Item: nil, ID: ref.ID, Status: entity.ChangeRejected, Error: err.Error()}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("get item: %w", err)
+	}
+
+	current := *item
+
+	if ref.Version != 0 {
+		item.Version = ref.Version
+	}
+
+	item.FolderID = folderID
+
+	err = h.VStor.UpdateItem(req.Context(), item)
+
+	switch {
+	case err == nil:
+		return appliedChange(item), nil
+	case errors.Is(err, storage.ErrVersionConflict):
+		return &changeResp{Item: &current, ID: item.ID, Status: entity.ChangeConflict, Error: err.Error()}, nil
+	case errors.Is(err, storage.ErrEntityNotFound):
+		return &changeResp{Item: nil, ID: item.ID, Status: entity.ChangeRejected, Error: err.Error()}, nil
+	default:
+		return nil, fmt.Errorf("update item: %w", err)
+	}
+}
+
+// readFolderReq читает и проверяет запрос создания или изменения папки.
+// При ошибке формирует ответ и возвращает false.
+func (h *Handler) readFolderReq(resp http.ResponseWriter, req *http.Request) (*folderReq, bool) {
+	var folderReq folderReq
+
+	limitBody(resp, req, FolderReqMaxSize)
+
+	if err := handler.GetDataFromBodyJSON(req, &folderReq); err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return nil, false
+	}
+
+	folderReq.Name = strings.TrimSpace(folderReq.Name)
+
+	if folderReq.Name == "" {
+		h.ResponseError(resp, http.StatusBadRequest, ErrEmptyFolderName)
+
+		return nil, false
+	}
+
+	if len(folderReq.Name) > FolderNameMaxSize {
+		h.ResponseError(resp, http.StatusBadRequest,
+			fmt.Errorf("%w: %d > %d", ErrFolderNameTooLong, len(folderReq.Name), FolderNameMaxSize))
+
+		return nil, false
+	}
+
+	return &folderReq, true
+}
+
+// checkItemFolder проверяет, что у владельца есть папка folderID (пусто - вне папок).
+// Без хранилища папок ссылки на папки не проверяются.
+func (h *Handler) checkItemFolder(req *http.Request, uid, folderID string) error {
+	if folderID == "" || h.Folders == nil {
+		return nil
+	}
+
+	_, err := h.Folders.GetFolder(req.Context(), uid, folderID)
+	if errors.Is(err, storage.ErrEntityNotFound) {
+		return fmt.Errorf("%w: %q", ErrUnknownFolder, folderID)
+	}
+
+	if err != nil {
+		return fmt.Errorf("get folder: %w", err)
+	}
+
+	return nil
+}
+
+// responseFolderError формирует ответ при ошибке проверки ссылки на папку.
+func (h *Handler) responseFolderError(resp http.ResponseWriter, err error) {
+	if errors.Is(err, ErrUnknownFolder) {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	h.ResponseError(resp, http.StatusInternalServerError, err)
+}
+
+// responseFolderUpdateError формирует ответ при ошибке изменения или удаления папки.
+func (h *Handler) responseFolderUpdateError(
+	resp http.ResponseWriter,
+	req *http.Request,
+	uid, id string,
+	err error,
+) {
+	switch {
+	case errors.Is(err, storage.ErrVersionConflict):
+		current, getErr := h.Folders.GetFolder(req.Context(), uid, id)
+		if getErr != nil {
+			h.ResponseError(resp, http.StatusConflict, err)
+
+			return
+		}
+
+		h.ResponceWithJSONStatus(resp, http.StatusConflict, folderConflictResp{
+			Folder: current,
+			Error:  err.Error(),
+		})
+	case errors.Is(err, storage.ErrEntityNotFound):
+		h.ResponseError(resp, http.StatusNotFound, err)
+	case errors.Is(err, storage.ErrFolderNotEmpty):
+		h.ResponseError(resp, http.StatusConflict, err)
+	case errors.Is(err, storage.ErrUnknownParent), errors.Is(err, storage.ErrFolderCycle):
+		h.ResponseError(resp, http.StatusBadRequest, err)
+	default:
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+	}
+}
+
+// newFolder создаёт папку владельца uid из запроса.
+func newFolder(uid, id string, folderReq *folderReq) *entity.Folder {
+	//nolint:exhaustruct // время изменения и номер заполняет хранилище
+	return &entity.Folder{
+		ID:       id,
+		OwnerID:  uid,
+		ParentID: folderReq.ParentID,
+		Name:     folderReq.Name,
+		Version:  folderReq.Version,
+		Deleted:  false,
+	}
+}
@@ -0,0 +1,319 @@
+package vault_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/handler"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/handler/vault"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+	"github.com/mr-filatik/go-goph-keeper/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const folderOwner = "folder-owner"
+
+// newFolderHandler создаёт обработчик с папками в хранилище в памяти.
+func newFolderHandler() (*vault.Handler, *storage.MemoryStorage) {
+	stor := storage.NewMemoryStorage()
+
+	mainHandler := handler.NewHandler(stor, testutil.NewMockLogger())
+	vaultHandler := vault.NewHandler(*mainHandler, stor)
+	vaultHandler.Folders = stor
+
+	return vaultHandler, stor
+}
+
+// createFolder создаёт папку через обработчик и возвращает её.
+func createFolder(t *testing.T, vaultHandler *vault.Handler, name, parentID string) *entity.Folder {
+	t.Helper()
+
+	rr := shareRequest(vaultHandler.CreateFolder, folderOwner, http.MethodPost, "/vault/folders",
+		map[string]any{"name": name, "parentId": parentID}, nil)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	var folder entity.Folder
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &folder))
+
+	return &folder
+}
+
+// updateFolder изменяет папку через обработчик.
+func updateFolder(vaultHandler *vault.Handler, id string, body any) *httptest.ResponseRecorder {
+	return shareRequest(vaultHandler.UpdateFolder, folderOwner, http.MethodPut, "/vault/folders/"+id,
+		body, map[string]string{"id": id})
+}
+
+// deleteFolder удаляет папку через обработчик.
+func deleteFolder(vaultHandler *vault.Handler, id, query string) *httptest.ResponseRecorder {
+	return shareRequest(vaultHandler.DeleteFolder, folderOwner, http.MethodDelete, "/vault/folders/"+id+query,
+		nil, map[string]string{"id": id})
+}
+
+/*
+	===== Handler folders =====
+*/
+
+func TestVault_Folders(t *testing.T) {
+	t.Parallel()
+
+	vaultHandler, _ := newFolderHandler()
+
+	work := createFolder(t, vaultHandler, "  Work ", "")
+	assert.Equal(t, "Work", work.Name, "name is trimmed")
+	assert.Equal(t, int64(1), work.Version)
+
+	nested := createFolder(t, vaultHandler, "Nested", work.ID)
+	assert.Equal(t, work.ID, nested.ParentID)
+
+	rr := shareRequest(vaultHandler.CreateFolder, folderOwner, http.MethodPost, "/vault/folders",
+		map[string]any{"name": " "}, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "empty name")
+
+	rr = shareRequest(vaultHandler.CreateFolder, folderOwner, http.MethodPost, "/vault/folders",
+		map[string]any{"name": "Orphan", "parentId": "missing"}, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "unknown parent")
+
+	rr = shareRequest(vaultHandler.CreateFolder, folderOwner, http.MethodPost, "/vault/folders",
+		map[string]any{"id": work.ID, "name": "Copy"}, nil)
+	assert.Equal(t, http.StatusConflict, rr.Code, "taken ID")
+
+	rr = shareRequest(vaultHandler.ListFolders, folderOwner, http.MethodGet, "/vault/folders", nil, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var folders []*entity.Folder
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &folders))
+	assert.Len(t, folders, 2)
+
+	rr = updateFolder(vaultHandler, work.ID, map[string]any{"name": "Work", "parentId": nested.ID})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "folder cannot move into its subfolder")
+
+	rr = updateFolder(vaultHandler, work.ID, map[string]any{"name": "Work", "parentId": work.ID})
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "folder cannot move into itself")
+
+	rr = updateFolder(vaultHandler, work.ID, map[string]any{"name": "Office", "version": 1})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = updateFolder(vaultHandler, work.ID, map[string]any{"name": "Stale", "version": 1})
+	require.Equal(t, http.StatusConflict, rr.Code)
+
+	var conflict struct {
+		Folder *entity.Folder `json:"folder"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &conflict))
+	require.NotNil(t, conflict.Folder)
+	assert.Equal(t, "Office", conflict.Folder.Name)
+	assert.Equal(t, int64(2), conflict.Folder.Version)
+
+	rr = updateFolder(vaultHandler, "missing", map[string]any{"name": "Missing"})
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = deleteFolder(vaultHandler, work.ID, "")
+	assert.Equal(t, http.StatusConflict, rr.Code, "folder has a subfolder")
+
+	rr = deleteFolder(vaultHandler, nested.ID, "?version=1")
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+
+	rr = deleteFolder(vaultHandler, nested.ID, "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestVault_Folders_Items(t *testing.T) {
+	t.Parallel()
+
+	vaultHandler, stor := newFolderHandler()
+	work := createFolder(t, vaultHandler, "Work", "")
+
+	rr := shareRequest(vaultHandler.UpsertItem, folderOwner, http.MethodPost, "/vault/items",
+		map[string]any{"type": entity.ItemText, "title": "Lost", "folderId": "missing"}, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "unknown folder")
+
+	rr = shareRequest(vaultHandler.UpsertItem, folderOwner, http.MethodPost, "/vault/items",
+		map[string]any{"type": entity.ItemText, "title": "Report", "folderId": work.ID}, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	rr = deleteFolder(vaultHandler, work.ID, "")
+	assert.Equal(t, http.StatusConflict, rr.Code, "folder has items")
+
+	//nolint:exhaustruct // not all fields needed in test
+	rootID, err := stor.CreateItem(context.Background(), &entity.VaultItem{OwnerID: folderOwner, Title: "Root"})
+	require.NoError(t, err)
+
+	rr = shareRequest(vaultHandler.ListItems, folderOwner, http.MethodGet, "/vault/items?folder=", nil, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var items []entity.VaultItem
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &items))
+	require.Len(t, items, 1)
+	assert.Equal(t, rootID, items[0].ID)
+
+	rr = shareRequest(vaultHandler.ListItems, folderOwner, http.MethodGet, "/vault/items?folder="+work.ID, nil, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &items))
+	require.Len(t, items, 1)
+	assert.Equal(t, "Report", items[0].Title)
+}
+
+func TestVault_MoveItems(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	vaultHandler, stor := newFolderHandler()
+	work := createFolder(t, vaultHandler, "Work", "")
+
+	//nolint:exhaustruct // not all fields needed in test
+	firstID, err := stor.CreateItem(ctx, &entity.VaultItem{OwnerID: folderOwner, Title: "First"})
+	require.NoError(t, err)
+
+	//nolint:exhaustruct // not all fields needed in test
+	secondID, err := stor.CreateItem(ctx, &entity.VaultItem{OwnerID: folderOwner, Title: "Second"})
+	require.NoError(t, err)
+
+	rr := shareRequest(vaultHandler.MoveItems, folderOwner, http.MethodPost, "/vault/items/move",
+		map[string]any{"folderId": "missing", "items": []map[string]any{{"id": firstID}}}, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "unknown folder")
+
+	rr = shareRequest(vaultHandler.MoveItems, folderOwner, http.MethodPost, "/vault/items/move",
+		map[string]any{"folderId": work.ID, "items": []map[string]any{
+			{"id": firstID, "version": 1},
+			{"id": secondID, "version": 5},
+			{"id": "missing"},
+		}}, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var moved pushPage
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &moved))
+	require.Len(t, moved.Results, 3)
+	assert.Equal(t, string(entity.ChangeApplied), moved.Results[0]["status"])
+	assert.InDelta(t, 2, moved.Results[0]["item"].(map[string]any)["version"], 0, "new version of the moved item")
+	assert.Equal(t, string(entity.ChangeConflict), moved.Results[1]["status"])
+	assert.Equal(t, string(entity.ChangeRejected), moved.Results[2]["status"])
+
+	first, err := stor.GetItem(ctx, folderOwner, firstID)
+	require.NoError(t, err)
+	assert.Equal(t, work.ID, first.FolderID)
+	assert.Equal(t, int64(2), first.Version)
+
+	second, err := stor.GetItem(ctx, folderOwner, secondID)
+	require.NoError(t, err)
+	assert.Empty(t, second.FolderID)
+
+	rr = shareRequest(vaultHandler.MoveItems, folderOwner, http.MethodPost, "/vault/items/move",
+		map[string]any{"items": []map[string]any{{"id": firstID}}}, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	first, err = stor.GetItem(ctx, folderOwner, firstID)
+	require.NoError(t, err)
+	assert.Empty(t, first.FolderID, "moved back to the root")
+}
+
+func TestVault_RestoreRevision_DeletedFolder(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	vaultHandler, stor := newFolderHandler()
+	work := createFolder(t, vaultHandler, "Work", "")
+
+	//nolint:exhaustruct // not all fields needed in test
+	item := &entity.VaultItem{OwnerID: folderOwner, Title: "Report", FolderID: work.ID}
+
+	itemID, err := stor.CreateItem(ctx, item)
+	require.NoError(t, err)
+
+	item.FolderID = ""
+	require.NoError(t, stor.UpdateItem(ctx, item))
+
+	rr := deleteFolder(vaultHandler, work.ID, "")
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	rr = shareRequest(vaultHandler.RestoreRevision, folderOwner, http.MethodPost,
+		"/vault/items/"+itemID+"/revisions/1/restore", nil, map[string]string{"id": itemID, "version": "1"})
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	restored, err := stor.GetItem(ctx, folderOwner, itemID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), restored.Version)
+	assert.Empty(t, restored.FolderID, "restored to the root")
+
+	var got entity.VaultItem
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+
+	got.OwnerID = folderOwner // владелец не передаётся в ответе
+	assert.Equal(t, restored, &got, "response matches the stored item")
+}
+
+/*
+	===== Handler sync with folders =====
+*/
+
+func TestVault_Sync_Folders(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	vaultHandler, stor := newFolderHandler()
+
+	//nolint:exhaustruct // not all fields needed in test
+	_, err := stor.CreateItem(ctx, &entity.VaultItem{OwnerID: folderOwner, Title: "First"})
+	require.NoError(t, err)
+
+	work := createFolder(t, vaultHandler, "Work", "")
+
+	//nolint:exhaustruct // not all fields needed in test
+	_, err = stor.CreateItem(ctx, &entity.VaultItem{OwnerID: folderOwner, Title: "Second", FolderID: work.ID})
+	require.NoError(t, err)
+
+	var page struct {
+		Items   []map[string]any `json:"items"`
+		Folders []*entity.Folder `json:"folders"`
+		Cursor  string           `json:"cursor"`
+		HasMore bool             `json:"hasMore"`
+	}
+
+	rr := shareRequest(vaultHandler.Sync, folderOwner, http.MethodGet, "/vault/sync?limit=2", nil, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	require.Len(t, page.Items, 1)
+	require.Len(t, page.Folders, 1)
+	assert.Equal(t, work.ID, page.Folders[0].ID)
+	assert.True(t, page.HasMore)
+	assert.Equal(t, cursorAt(t, 2), page.Cursor)
+
+	rr = shareRequest(vaultHandler.Sync, folderOwner, http.MethodGet, "/vault/sync?cursor="+page.Cursor, nil, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	require.Len(t, page.Items, 1)
+	assert.Empty(t, page.Folders)
+	assert.False(t, page.HasMore)
+	assert.Equal(t, cursorAt(t, 3), page.Cursor)
+}
+
+func TestVault_SyncPush_StopsBeforeFolderChange(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	vaultHandler, stor := newFolderHandler()
+
+	//nolint:exhaustruct // not all fields needed in test
+	_, err := stor.CreateItem(ctx, &entity.VaultItem{OwnerID: folderOwner, Title: "Synced"})
+	require.NoError(t, err)
+
+	createFolder(t, vaultHandler, "Created elsewhere", "")
+
+	rr := shareRequest(vaultHandler.SyncPush, folderOwner, http.MethodPost, "/vault/sync",
+		map[string]any{
+			"cursor":  cursorAt(t, 1),
+			"changes": []map[string]any{{"op": entity.ChangeUpsert, "type": entity.ItemText, "title": "New"}},
+		}, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var pushed pushPage
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &pushed))
+	require.Len(t, pushed.Results, 1)
+	assert.Equal(t, string(entity.ChangeApplied), pushed.Results[0]["status"])
+	assert.Equal(t, cursorAt(t, 1), pushed.Cursor, "client has not received the folder change yet")
+}
@@ -2,19 +2,59 @@
 package vault
 
 import (
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
+	"github.com/mr-filatik/go-goph-keeper/internal/server/blob"
 	"github.com/mr-filatik/go-goph-keeper/internal/server/handler"
 	"github.com/mr-filatik/go-goph-keeper/internal/server/middleware"
 	"github.com/mr-filatik/go-goph-keeper/internal/server/storage"
 	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/upload"
+)
+
+// Ограничения размера страницы и пакета синхронизации.
+const (
+	SyncDefaultLimit = 500
+	SyncMaxLimit     = 1000
+	SyncMaxBatch     = 1000
+
+	ItemsDefaultLimit = 100
+	ItemsMaxLimit     = 1000
+)
+
+// BodyOverhead - допустимый объём тела запроса сверх удвоенного размера данных
+// (JSON-разметка и экранирование строк).
+const BodyOverhead = 64 << 10
+
+// Ошибки разбора запросов синхронизации.
+var (
+	ErrInvalidLimit   = errors.New("invalid limit")
+	ErrBatchTooLarge  = errors.New("sync batch too large")
+	ErrInvalidVersion = errors.New("invalid version")
+	ErrInvalidQuery   = errors.New("invalid query")
 )
 
 // Handler хранит данные необходимые для обработчиков.
+//
+// Обработчики записей работают с записями владельца из контекста запроса
+// (middleware.GetOwnerID): самого пользователя или коллекции организации.
 type Handler struct {
 	VStor storage.IStorage
 	handler.Handler
+	Quota entity.Quota // квота пользователя, ограничивает размер тела запросов
+
+	Blobs       blob.IBlobStore // хранилище содержимого бинарных записей
+	BlobMaxSize int64           // наибольший размер содержимого (0 - без ограничения)
+	Uploads     *upload.Store   // сессии возобновляемой загрузки содержимого
+
+	Shares  storage.IShareStorage  // доступы к записям других пользователей
+	Folders storage.IFolderStorage // папки записей (nil - отключены)
 }
 
 // NewHandler создаёт новый экземпляр Handler.
@@ -22,26 +62,89 @@ func NewHandler(h handler.Handler, vStor storage.IStorage) *Handler {
 	return &Handler{
 		Handler: h,
 		VStor:   vStor,
+		Quota:   entity.Quota{MaxItems: 0, MaxBytes: 0, MaxItemBytes: 0},
+
+		Blobs:       nil,
+		BlobMaxSize: 0,
+		Uploads:     nil,
+
+		Shares:  nil,
+		Folders: nil,
 	}
 }
 
-// ListItems выводит все пароли пользователя.
+// ListItems выводит страницу записей пользователя.
+//
+// Параметры запроса:
+//   - type: тип записи;
+//   - title, titleMatch: образец названия без учёта регистра и способ сравнения
+//     (contains - по умолчанию, prefix);
+//   - metaKey, metaValue: ключ метаинформации и, при необходимости, его значение;
+//   - folder: папка записей (пустое значение - записи вне папок);
+//   - tag, tagMatch: метки (параметр повторяется) и способ их сравнения
+//     (any - хотя бы одна из меток, по умолчанию; all - все метки);
+//   - sort, order: поле сортировки (title - по умолчанию, updatedAt) и направление (asc, desc);
+//   - limit: размер страницы (по умолчанию ItemsDefaultLimit, не более ItemsMaxLimit);
+//   - cursor: курсор из предыдущего ответа (пусто - первая страница).
+//
+// Без параметров limit и cursor ответ, как и прежде, - массив всех подходящих записей.
+// С ними ответ - страница {items, cursor, hasMore}: пока hasMore равен true, следующую
+// страницу можно получить с тем же набором параметров и полученным курсором.
 func (h *Handler) ListItems(resp http.ResponseWriter, req *http.Request) {
-	uid, _ := middleware.GetUserID(req.Context())
+	uid, _ := middleware.GetOwnerID(req.Context())
+
+	values := req.URL.Query()
+
+	query, limit, err := parseItemQuery(values)
+	if err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	if !values.Has("limit") && !values.Has("cursor") {
+		items, err := h.VStor.QueryItems(req.Context(), uid, query)
+		if err != nil {
+			h.ResponseError(resp, http.StatusInternalServerError, err)
+
+			return
+		}
 
-	items, err := h.VStor.ListItems(req.Context(), uid)
+		h.ResponceWithJSON(resp, items)
+
+		return
+	}
+
+	// Запрашивается на одну запись больше, чтобы узнать, есть ли следующая страница.
+	query.Limit = limit + 1
+
+	items, err := h.VStor.QueryItems(req.Context(), uid, query)
 	if err != nil {
 		h.ResponseError(resp, http.StatusInternalServerError, err)
 
 		return
 	}
 
-	h.ResponceWithJSON(resp, items)
+	hasMore := len(items) > limit
+	if hasMore {
+		items = items[:limit]
+	}
+
+	cursor := ""
+	if hasMore {
+		cursor = encodePageCursor(query, items[len(items)-1])
+	}
+
+	h.ResponceWithJSON(resp, itemsResp{
+		Items:   items,
+		Cursor:  cursor,
+		HasMore: hasMore,
+	})
 }
 
 // GetItem выводит конкретный пароль пользователя.
 func (h *Handler) GetItem(resp http.ResponseWriter, req *http.Request) {
-	uid, _ := middleware.GetUserID(req.Context())
+	uid, _ := middleware.GetOwnerID(req.Context())
 
 	id := req.PathValue("id")
 
@@ -56,12 +159,40 @@ func (h *Handler) GetItem(resp http.ResponseWriter, req *http.Request) {
 }
 
 // UpsertItem производит обновление пароля.
+//
+// При расхождении версии с серверной возвращает 409 и текущую запись сервера,
+// чтобы клиент мог выполнить слияние изменений. Запись в несуществующую папку
+// или с недопустимыми метками отклоняется с кодом 400.
 func (h *Handler) UpsertItem(resp http.ResponseWriter, req *http.Request) {
-	uid, _ := middleware.GetUserID(req.Context())
+	uid, _ := middleware.GetOwnerID(req.Context())
 
 	var upReq upsertReq
 
+	limitBody(resp, req, h.Quota.MaxItemBytes)
+
 	if err := handler.GetDataFromBodyJSON(req, &upReq); err != nil {
+		h.responseBodyError(resp, err, storage.QuotaItemBytes, h.Quota.MaxItemBytes)
+
+		return
+	}
+
+	if err := h.checkItemFolder(req, uid, upReq.FolderID); err != nil {
+		h.responseFolderError(resp, err)
+
+		return
+	}
+
+	tags, err := normalizeTags(upReq.Tags)
+	if err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	now := time.Now().UTC()
+
+	expiresAt, err := normalizeExpiry(upReq.ExpiresAt, now)
+	if err != nil {
 		h.ResponseError(resp, http.StatusBadRequest, err)
 
 		return
@@ -74,15 +205,23 @@ func (h *Handler) UpsertItem(resp http.ResponseWriter, req *http.Request) {
 		Title:       upReq.Title,
 		Meta:        upReq.Meta,
 		Data:        upReq.Data,
+		Content:     nil,
+		FolderID:    upReq.FolderID,
+		Tags:        tags,
+		Attachments: nil,
+		ExpiresAt:   expiresAt,
 		Version:     upReq.Version,
-		UpdatedAt:   time.Now().UTC(),
+		UpdatedAt:   now,
 		Description: "",
 		Username:    "",
+		Seq:         0,
+		Deleted:     false,
+		Expired:     false,
 	}
 
 	upsertID, err := h.VStor.UpsertItem(req.Context(), item)
 	if err != nil {
-		h.ResponseError(resp, http.StatusConflict, err)
+		h.responseUpsertError(resp, req, item, err)
 
 		return
 	}
@@ -92,7 +231,7 @@ func (h *Handler) UpsertItem(resp http.ResponseWriter, req *http.Request) {
 
 // DeleteItem производит удаление пароля.
 func (h *Handler) DeleteItem(resp http.ResponseWriter, req *http.Request) {
-	uid, _ := middleware.GetUserID(req.Context())
+	uid, _ := middleware.GetOwnerID(req.Context())
 
 	id := req.PathValue("id")
 
@@ -105,21 +244,284 @@ func (h *Handler) DeleteItem(resp http.ResponseWriter, req *http.Request) {
 	resp.WriteHeader(http.StatusNoContent)
 }
 
-// SyncSince синхрогнизирует состояние.
-func (h *Handler) SyncSince(resp http.ResponseWriter, req *http.Request) {
-	uid, _ := middleware.GetUserID(req.Context())
+// Usage выводит объём данных пользователя и действующие ограничения.
+func (h *Handler) Usage(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetOwnerID(req.Context())
+
+	usage, err := h.VStor.GetUsage(req.Context(), uid)
+	if err != nil {
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	h.ResponceWithJSON(resp, usageResp{
+		Usage: usage,
+		Quota: h.Quota,
+	})
+}
+
+// Sync отдаёт изменения, которые клиент ещё не получил.
+//
+// Параметры запроса:
+//   - cursor: курсор из предыдущего ответа (пусто - с начала);
+//   - limit: размер страницы (по умолчанию SyncDefaultLimit, не более SyncMaxLimit).
+//
+// Изменения записей и папок идут в одной ленте владельца: страница содержит
+// первые limit изменений обоих видов. Ответ содержит новый курсор и признак hasMore:
+// пока он true, клиент должен повторять запрос с полученным курсором.
+func (h *Handler) Sync(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetOwnerID(req.Context())
+
+	query := req.URL.Query()
+
+	afterSeq, err := decodeCursor(query.Get("cursor"))
+	if err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	limit, err := parseLimit(query.Get("limit"), SyncDefaultLimit, SyncMaxLimit)
+	if err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	// Запрашивается на одно изменение больше, чтобы узнать, есть ли следующая страница.
+	items, err := h.VStor.ListChanges(req.Context(), uid, afterSeq, limit+1)
+	if err != nil {
+		h.ResponseError(resp, http.StatusInternalServerError, err)
 
-	sinceStr := req.URL.Query().Get("since")
+		return
+	}
 
-	since := time.Time{}
+	folders := []*entity.Folder{}
 
-	if sinceStr != "" {
-		if t, err := time.Parse(time.RFC3339, sinceStr); err == nil {
-			since = t
+	if h.Folders != nil {
+		folders, err = h.Folders.ListFolderChanges(req.Context(), uid, afterSeq, limit+1)
+		if err != nil {
+			h.ResponseError(resp, http.StatusInternalServerError, err)
+
+			return
 		}
 	}
 
-	items, err := h.VStor.ListChangedSince(req.Context(), uid, since)
+	items, folders, hasMore := pageChanges(expireChanges(items, time.Now()), folders, limit)
+
+	if len(items) > 0 {
+		afterSeq = items[len(items)-1].Seq
+	}
+
+	if len(folders) > 0 {
+		afterSeq = max(afterSeq, folders[len(folders)-1].Seq)
+	}
+
+	h.ResponceWithJSON(resp, syncResp{
+		Items:   items,
+		Folders: folders,
+		Cursor:  encodeCursor(afterSeq),
+		HasMore: hasMore,
+	})
+}
+
+// SyncPush применяет пакет изменений клиента (создание, обновление, удаление).
+//
+// Для каждого изменения возвращается результат: applied (с новым состоянием записи),
+// conflict (с текущей записью сервера) или rejected. Курсор в ответе продвигается
+// дальше курсора клиента только через изменения, состояние которых клиент получил
+// в этом ответе; чужие изменения клиент должен забрать через GET /vault/sync.
+func (h *Handler) SyncPush(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetOwnerID(req.Context())
+
+	var pushReq syncPushReq
+
+	limitBody(resp, req, h.Quota.MaxBytes)
+
+	if err := handler.GetDataFromBodyJSON(req, &pushReq); err != nil {
+		h.responseBodyError(resp, err, storage.QuotaBytes, h.Quota.MaxBytes)
+
+		return
+	}
+
+	if len(pushReq.Changes) > SyncMaxBatch {
+		h.ResponseError(resp, http.StatusBadRequest,
+			fmt.Errorf("%w: %d > %d", ErrBatchTooLarge, len(pushReq.Changes), SyncMaxBatch))
+
+		return
+	}
+
+	afterSeq, err := decodeCursor(pushReq.Cursor)
+	if err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	changes := make([]*entity.Change, 0, len(pushReq.Changes))
+	now := time.Now().UTC()
+
+	for _, ch := range pushReq.Changes {
+		// Изменение с недопустимыми метками или истёкшим сроком отклоняется хранилищем
+		// без применения, остальные изменения пакета применяются. Для удаления
+		// содержимое записи не проверяется.
+		tags, invalid := normalizeTags(ch.Tags)
+
+		expiresAt, err := normalizeExpiry(ch.ExpiresAt, now)
+		if invalid == nil {
+			invalid = err
+		}
+
+		if ch.Op == entity.ChangeDelete {
+			invalid = nil
+		}
+
+		changes = append(changes, &entity.Change{
+			Op: ch.Op,
+			Item: &entity.VaultItem{
+				ID:          ch.ID,
+				OwnerID:     uid,
+				Type:        ch.Type,
+				Title:       ch.Title,
+				Meta:        ch.Meta,
+				Data:        ch.Data,
+				Content:     nil,
+				FolderID:    ch.FolderID,
+				Tags:        tags,
+				Attachments: nil,
+				ExpiresAt:   expiresAt,
+				Version:     ch.Version,
+				UpdatedAt:   now,
+				Description: "",
+				Username:    "",
+				Seq:         0,
+				Deleted:     false,
+				Expired:     false,
+			},
+			Invalid: invalid,
+		})
+	}
+
+	results, err := h.VStor.ApplyChanges(req.Context(), uid, changes)
+	if err != nil {
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	cursorSeq, err := h.advanceCursor(req, uid, afterSeq, results)
+	if err != nil {
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	pushResp := syncPushResp{
+		Results: make([]changeResp, 0, len(results)),
+		Cursor:  encodeCursor(cursorSeq),
+	}
+
+	for _, res := range results {
+		pushResp.Results = append(pushResp.Results, changeResp{
+			Item:   res.Item,
+			ID:     res.ID,
+			Status: res.Status,
+			Error:  res.Error,
+		})
+	}
+
+	h.ResponceWithJSON(resp, pushResp)
+}
+
+// pageChanges оставляет первые по номеру limit изменений записей и папок
+// и сообщает, остались ли изменения после них. Изменения каждого вида упорядочены по номеру.
+func pageChanges(
+	items []*entity.VaultItem,
+	folders []*entity.Folder,
+	limit int,
+) ([]*entity.VaultItem, []*entity.Folder, bool) {
+	itemsTaken, foldersTaken := 0, 0
+
+	for itemsTaken+foldersTaken < limit {
+		hasItem, hasFolder := itemsTaken < len(items), foldersTaken < len(folders)
+
+		switch {
+		case hasItem && (!hasFolder || items[itemsTaken].Seq < folders[foldersTaken].Seq):
+			itemsTaken++
+		case hasFolder:
+			foldersTaken++
+		default:
+			return items, folders, false
+		}
+	}
+
+	hasMore := itemsTaken < len(items) || foldersTaken < len(folders)
+
+	return items[:itemsTaken], folders[:foldersTaken], hasMore
+}
+
+// advanceCursor продвигает курсор клиента через идущие подряд изменения,
+// состояние которых клиент получил в результатах пакета. Курсор не проходит
+// изменения папок: их клиент получает только через GET /vault/sync.
+func (h *Handler) advanceCursor(
+	req *http.Request,
+	uid string,
+	afterSeq int64,
+	results []*entity.ChangeResult,
+) (int64, error) {
+	known := make(map[int64]struct{}, len(results))
+
+	for _, res := range results {
+		if res.Item != nil && res.Item.Seq > afterSeq {
+			known[res.Item.Seq] = struct{}{}
+		}
+	}
+
+	if len(known) == 0 {
+		return afterSeq, nil
+	}
+
+	feed, err := h.VStor.ListChanges(req.Context(), uid, afterSeq, len(known))
+	if err != nil {
+		return 0, fmt.Errorf("list changes: %w", err)
+	}
+
+	folderSeq := int64(math.MaxInt64)
+
+	if h.Folders != nil {
+		folders, err := h.Folders.ListFolderChanges(req.Context(), uid, afterSeq, 1)
+		if err != nil {
+			return 0, fmt.Errorf("list folder changes: %w", err)
+		}
+
+		if len(folders) > 0 {
+			folderSeq = folders[0].Seq
+		}
+	}
+
+	for _, it := range feed {
+		if _, ok := known[it.Seq]; !ok || it.Seq > folderSeq {
+			break
+		}
+
+		afterSeq = it.Seq
+	}
+
+	return afterSeq, nil
+}
+
+// appliedChange возвращает результат применённого изменения записи item.
+// После UpdateItem запись содержит сохранённую хранилищем версию.
+func appliedChange(item *entity.VaultItem) *changeResp {
+	return &changeResp{Item: item, ID: item.ID, Status: entity.ChangeApplied, Error: ""}
+}
+
+// ListTrash выводит записи пользователя в корзине, начиная с последних удалённых.
+func (h *Handler) ListTrash(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetOwnerID(req.Context())
+
+	items, err := h.VStor.ListTrash(req.Context(), uid)
 	if err != nil {
 		h.ResponseError(resp, http.StatusInternalServerError, err)
 
@@ -128,3 +530,387 @@ func (h *Handler) SyncSince(resp http.ResponseWriter, req *http.Request) {
 
 	h.ResponceWithJSON(resp, items)
 }
+
+// RestoreTrash восстанавливает запись из корзины и выводит её новую версию.
+func (h *Handler) RestoreTrash(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetOwnerID(req.Context())
+
+	id := req.PathValue("id")
+
+	item, err := h.VStor.RestoreTrash(req.Context(), uid, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrEntityNotFound) {
+			h.ResponseError(resp, http.StatusNotFound, err)
+
+			return
+		}
+
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	h.ResponceWithJSON(resp, item)
+}
+
+// EmptyTrash безвозвратно удаляет все записи из корзины пользователя.
+func (h *Handler) EmptyTrash(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetOwnerID(req.Context())
+
+	count, err := h.VStor.EmptyTrash(req.Context(), uid)
+	if err != nil {
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	h.ResponceWithJSON(resp, map[string]any{"purged": count})
+}
+
+// ListRevisions выводит прежние версии записи, начиная с последней.
+func (h *Handler) ListRevisions(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetOwnerID(req.Context())
+
+	id := req.PathValue("id")
+
+	revs, err := h.VStor.ListRevisions(req.Context(), uid, id)
+	if err != nil {
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	h.ResponceWithJSON(resp, revs)
+}
+
+// GetRevision выводит прежнюю версию записи.
+func (h *Handler) GetRevision(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetOwnerID(req.Context())
+
+	id := req.PathValue("id")
+
+	version, err := parseVersion(req.PathValue("version"))
+	if err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	rev, err := h.VStor.GetRevision(req.Context(), uid, id, version)
+	if err != nil {
+		h.responseLookupError(resp, err)
+
+		return
+	}
+
+	h.ResponceWithJSON(resp, rev)
+}
+
+// RestoreRevision восстанавливает содержимое записи из прежней версии.
+//
+// Восстановление сохраняется как новая версия записи, поэтому текущее содержимое
+// тоже попадает в историю. Тело запроса {"version": n} необязательно: если
+// версия указана, она проверяется так же, как при обновлении записи.
+// Удалённую запись тоже можно восстановить, версию с истёкшим сроком - нельзя (400).
+// Если папки прежней версии больше нет, запись восстанавливается вне папок.
+// Содержимое и вложения восстанавливаются такими, какими были в прежней версии.
+func (h *Handler) RestoreRevision(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetOwnerID(req.Context())
+
+	id := req.PathValue("id")
+
+	version, err := parseVersion(req.PathValue("version"))
+	if err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	var restReq restoreReq
+
+	if req.ContentLength != 0 {
+		if err := handler.GetDataFromBodyJSON(req, &restReq); err != nil {
+			h.ResponseError(resp, http.StatusBadRequest, err)
+
+			return
+		}
+	}
+
+	rev, err := h.VStor.GetRevision(req.Context(), uid, id, version)
+	if err != nil {
+		h.responseLookupError(resp, err)
+
+		return
+	}
+
+	now := time.Now().UTC()
+
+	// Срок прежней версии мог истечь: такую версию нельзя восстановить, как нельзя
+	// сохранить запись с истёкшим сроком.
+	expiresAt, err := normalizeExpiry(rev.Item.ExpiresAt, now)
+	if err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	item := *rev.Item
+	item.OwnerID = uid
+	item.ExpiresAt = expiresAt
+	item.Version = restReq.Version
+	item.UpdatedAt = now
+	item.Seq = 0
+	item.Deleted = false
+
+	// Папку прежней версии могли удалить: тогда запись восстанавливается вне папок.
+	if err := h.checkItemFolder(req, uid, item.FolderID); err != nil {
+		if !errors.Is(err, ErrUnknownFolder) {
+			h.ResponseError(resp, http.StatusInternalServerError, err)
+
+			return
+		}
+
+		item.FolderID = ""
+	}
+
+	// Пустые содержимое и вложения хранилище сохранило бы от текущей версии:
+	// версия восстанавливается без них явно, чтобы ответ совпадал с сохранённой записью.
+	if item.Content == nil {
+		item.Content = &entity.Content{Hash: "", Size: 0}
+	}
+
+	if item.Attachments == nil {
+		item.Attachments = []entity.Attachment{}
+	}
+
+	if _, err := h.VStor.UpsertItem(req.Context(), &item); err != nil {
+		h.responseUpsertError(resp, req, &item, err)
+
+		return
+	}
+
+	item.Content = rev.Item.Content
+	item.Attachments = rev.Item.Attachments
+
+	h.ResponceWithJSON(resp, &item)
+}
+
+// responseLookupError формирует ответ при ошибке получения записи или её прежней версии.
+func (h *Handler) responseLookupError(resp http.ResponseWriter, err error) {
+	if errors.Is(err, storage.ErrEntityNotFound) {
+		h.ResponseError(resp, http.StatusNotFound, err)
+
+		return
+	}
+
+	h.ResponseError(resp, http.StatusInternalServerError, err)
+}
+
+// responseUpsertError формирует ответ при ошибке сохранения записи.
+func (h *Handler) responseUpsertError(
+	resp http.ResponseWriter,
+	req *http.Request,
+	item *entity.VaultItem,
+	err error,
+) {
+	switch {
+	case errors.Is(err, storage.ErrVersionConflict):
+		current, getErr := h.VStor.GetItem(req.Context(), item.OwnerID, item.ID)
+		if getErr != nil {
+			h.ResponseError(resp, http.StatusConflict, err)
+
+			return
+		}
+
+		h.Log.Info("Vault item version conflict",
+			"id", item.ID,
+			"version", item.Version,
+			"current", current.Version,
+		)
+
+		h.ResponceWithJSONStatus(resp, http.StatusConflict, conflictResp{
+			Item:  current,
+			Error: err.Error(),
+		})
+	case errors.Is(err, storage.ErrEntityAlreadyExists):
+		h.ResponseError(resp, http.StatusConflict, err)
+	case errors.Is(err, storage.ErrEntityNotFound):
+		// Запись удалена или её срок истёк (до удаления фоновой задачей её нельзя изменить).
+		h.ResponseError(resp, http.StatusNotFound, err)
+	case errors.Is(err, storage.ErrQuotaExceeded):
+		h.responseQuotaError(resp, err)
+	default:
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+	}
+}
+
+// responseQuotaError формирует ответ при превышении квоты пользователя:
+// 413 - для слишком большой записи, 507 - для исчерпанной квоты.
+func (h *Handler) responseQuotaError(resp http.ResponseWriter, err error) {
+	var quotaErr *storage.QuotaError
+	if !errors.As(err, &quotaErr) {
+		h.ResponseError(resp, http.StatusInsufficientStorage, err)
+
+		return
+	}
+
+	code := http.StatusInsufficientStorage
+	if quotaErr.Limit == storage.QuotaItemBytes {
+		code = http.StatusRequestEntityTooLarge
+	}
+
+	h.Log.Info("Vault quota exceeded",
+		"limit", quotaErr.Limit,
+		"max", quotaErr.Max,
+		"used", quotaErr.Used,
+		"requested", quotaErr.Requested,
+	)
+
+	h.ResponceWithJSONStatus(resp, code, quotaResp{
+		Error:     err.Error(),
+		Limit:     quotaErr.Limit,
+		Max:       quotaErr.Max,
+		Used:      quotaErr.Used,
+		Requested: quotaErr.Requested,
+	})
+}
+
+// responseBodyError формирует ответ при ошибке чтения тела запроса.
+//
+// Тело, превысившее ограничение limitBody, отклоняется с кодом 413
+// и описанием ограничения limit.
+func (h *Handler) responseBodyError(
+	resp http.ResponseWriter,
+	err error,
+	limit storage.QuotaLimit,
+	maxBytes int64,
+) {
+	var maxErr *http.MaxBytesError
+	if !errors.As(err, &maxErr) {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	h.ResponceWithJSONStatus(resp, http.StatusRequestEntityTooLarge, quotaResp{
+		Error:     err.Error(),
+		Limit:     limit,
+		Max:       maxBytes,
+		Used:      0,
+		Requested: 0,
+	})
+}
+
+// limitBody ограничивает размер тела запроса с данными размером до maxBytes (0 - без ограничения).
+func limitBody(resp http.ResponseWriter, req *http.Request, maxBytes int64) {
+	if maxBytes > 0 {
+		req.Body = http.MaxBytesReader(resp, req.Body, 2*maxBytes+BodyOverhead)
+	}
+}
+
+// parseVersion разбирает номер версии записи из пути запроса.
+func parseVersion(value string) (int64, error) {
+	version, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidVersion, value)
+	}
+
+	return version, nil
+}
+
+// parseLimit разбирает размер страницы: пустое значение заменяется на defaultLimit,
+// слишком большое ограничивается maxLimit.
+func parseLimit(value string, defaultLimit, maxLimit int) (int, error) {
+	if value == "" {
+		return defaultLimit, nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidLimit, value)
+	}
+
+	return min(limit, maxLimit), nil
+}
+
+// parseItemQuery разбирает параметры выборки записей и размер страницы.
+func parseItemQuery(values url.Values) (*entity.ItemQuery, int, error) {
+	query := &entity.ItemQuery{
+		After:      nil,
+		Type:       entity.ItemType(values.Get("type")),
+		Title:      values.Get("title"),
+		TitleMatch: entity.TitleContains,
+		MetaKey:    values.Get("metaKey"),
+		MetaValue:  values.Get("metaValue"),
+		FolderID:   nil,
+		Tags:       nil,
+		TagMatch:   entity.TagsAny,
+		SortBy:     entity.SortByTitle,
+		Desc:       false,
+		Limit:      0,
+	}
+
+	switch match := entity.TitleMatch(values.Get("titleMatch")); match {
+	case "", entity.TitleContains:
+	case entity.TitlePrefix:
+		query.TitleMatch = match
+	default:
+		return nil, 0, fmt.Errorf("%w: titleMatch %q", ErrInvalidQuery, match)
+	}
+
+	if query.MetaValue != "" && query.MetaKey == "" {
+		return nil, 0, fmt.Errorf("%w: metaValue without metaKey", ErrInvalidQuery)
+	}
+
+	if values.Has("folder") {
+		folderID := values.Get("folder")
+		query.FolderID = &folderID
+	}
+
+	if values.Has("tag") {
+		tags, err := normalizeTags(values["tag"])
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: %w", ErrInvalidQuery, err)
+		}
+
+		query.Tags = tags
+	}
+
+	switch match := entity.TagMatch(values.Get("tagMatch")); match {
+	case "", entity.TagsAny:
+	case entity.TagsAll:
+		query.TagMatch = match
+	default:
+		return nil, 0, fmt.Errorf("%w: tagMatch %q", ErrInvalidQuery, match)
+	}
+
+	switch sortBy := entity.ItemSortField(values.Get("sort")); sortBy {
+	case "", entity.SortByTitle:
+	case entity.SortByUpdatedAt:
+		query.SortBy = sortBy
+	default:
+		return nil, 0, fmt.Errorf("%w: sort %q", ErrInvalidQuery, sortBy)
+	}
+
+	switch order := values.Get("order"); order {
+	case "", "asc":
+	case "desc":
+		query.Desc = true
+	default:
+		return nil, 0, fmt.Errorf("%w: order %q", ErrInvalidQuery, order)
+	}
+
+	limit, err := parseLimit(values.Get("limit"), ItemsDefaultLimit, ItemsMaxLimit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	after, err := decodePageCursor(values.Get("cursor"), query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query.After = after
+
+	return query, limit, nil
+}
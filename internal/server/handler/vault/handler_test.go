@@ -3,9 +3,12 @@ package vault_test
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -64,13 +67,28 @@ func assertHTTP(t *testing.T, gotStatus int, gotBody string) {
 */
 
 type mockStorage struct {
-	CreateItemFn       func(ctx context.Context, it *entity.VaultItem) (string, error)
-	UpdateItemFn       func(ctx context.Context, it *entity.VaultItem) error
-	UpsertItemFn       func(ctx context.Context, it *entity.VaultItem) (string, error)
-	GetItemFn          func(ctx context.Context, ownerID, id string) (*entity.VaultItem, error)
-	ListItemsFn        func(ctx context.Context, ownerID string) ([]*entity.VaultItem, error)
-	DeleteItemFn       func(ctx context.Context, ownerID, id string) error
-	ListChangedSinceFn func(ctx context.Context, ownerID string, since time.Time) ([]*entity.VaultItem, error)
+	CreateItemFn        func(ctx context.Context, it *entity.VaultItem) (string, error)
+	UpdateItemFn        func(ctx context.Context, it *entity.VaultItem) error
+	UpsertItemFn        func(ctx context.Context, it *entity.VaultItem) (string, error)
+	GetItemFn           func(ctx context.Context, ownerID, id string) (*entity.VaultItem, error)
+	ListItemsFn         func(ctx context.Context, ownerID string) ([]*entity.VaultItem, error)
+	QueryItemsFn        func(ctx context.Context, ownerID string, query *entity.ItemQuery) ([]*entity.VaultItem, error)
+	ListTagsFn          func(ctx context.Context, ownerID string) ([]entity.TagCount, error)
+	DeleteItemFn        func(ctx context.Context, ownerID, id string) error
+	ListChangesFn       func(ctx context.Context, ownerID string, afterSeq int64, limit int) ([]*entity.VaultItem, error)
+	CompactTombstonesFn func(ctx context.Context, before time.Time) (int, error)
+	ApplyChangesFn      func(ctx context.Context, ownerID string, changes []*entity.Change) ([]*entity.ChangeResult, error)
+	ListRevisionsFn     func(ctx context.Context, ownerID, itemID string) ([]*entity.Revision, error)
+	GetRevisionFn       func(ctx context.Context, ownerID, itemID string, version int64) (*entity.Revision, error)
+	CompactRevisionsFn  func(ctx context.Context, before time.Time) (int, error)
+	ListTrashFn         func(ctx context.Context, ownerID string) ([]*entity.TrashItem, error)
+	RestoreTrashFn      func(ctx context.Context, ownerID, itemID string) (*entity.VaultItem, error)
+	EmptyTrashFn        func(ctx context.Context, ownerID string) (int, error)
+	PurgeTrashFn        func(ctx context.Context, before time.Time) (int, error)
+	ExpireItemsFn       func(ctx context.Context, now time.Time) (int, error)
+	GetUsageFn          func(ctx context.Context, ownerID string) (*entity.Usage, error)
+	SetItemContentFn    func(ctx context.Context, ownerID, itemID string, version int64, content *entity.Content) (*entity.VaultItem, error)
+	ContentHashesFn     func(ctx context.Context) ([]string, error)
 }
 
 func (m *mockStorage) CreateItem(ctx context.Context, it *entity.VaultItem) (string, error) {
@@ -93,16 +111,100 @@ func (m *mockStorage) ListItems(ctx context.Context, ownerID string) ([]*entity.
 	return m.ListItemsFn(ctx, ownerID)
 }
 
+func (m *mockStorage) QueryItems(
+	ctx context.Context,
+	ownerID string,
+	query *entity.ItemQuery,
+) ([]*entity.VaultItem, error) {
+	return m.QueryItemsFn(ctx, ownerID, query)
+}
+
+func (m *mockStorage) ListTags(ctx context.Context, ownerID string) ([]entity.TagCount, error) {
+	return m.ListTagsFn(ctx, ownerID)
+}
+
+func (m *mockStorage) GetUsage(ctx context.Context, ownerID string) (*entity.Usage, error) {
+	return m.GetUsageFn(ctx, ownerID)
+}
+
+func (m *mockStorage) SetItemContent(
+	ctx context.Context,
+	ownerID, itemID string,
+	version int64,
+	content *entity.Content,
+) (*entity.VaultItem, error) {
+	return m.SetItemContentFn(ctx, ownerID, itemID, version, content)
+}
+
+func (m *mockStorage) ContentHashes(ctx context.Context) ([]string, error) {
+	return m.ContentHashesFn(ctx)
+}
+
 func (m *mockStorage) DeleteItem(ctx context.Context, ownerID, id string) error {
 	return m.DeleteItemFn(ctx, ownerID, id)
 }
 
-func (m *mockStorage) ListChangedSince(
+func (m *mockStorage) ListChanges(
 	ctx context.Context,
 	ownerID string,
-	since time.Time,
+	afterSeq int64,
+	limit int,
 ) ([]*entity.VaultItem, error) {
-	return m.ListChangedSinceFn(ctx, ownerID, since)
+	return m.ListChangesFn(ctx, ownerID, afterSeq, limit)
+}
+
+func (m *mockStorage) ApplyChanges(
+	ctx context.Context,
+	ownerID string,
+	changes []*entity.Change,
+) ([]*entity.ChangeResult, error) {
+	return m.ApplyChangesFn(ctx, ownerID, changes)
+}
+
+func (m *mockStorage) CompactTombstones(ctx context.Context, before time.Time) (int, error) {
+	return m.CompactTombstonesFn(ctx, before)
+}
+
+func (m *mockStorage) ListRevisions(
+	ctx context.Context,
+	ownerID, itemID string,
+) ([]*entity.Revision, error) {
+	return m.ListRevisionsFn(ctx, ownerID, itemID)
+}
+
+func (m *mockStorage) GetRevision(
+	ctx context.Context,
+	ownerID, itemID string,
+	version int64,
+) (*entity.Revision, error) {
+	return m.GetRevisionFn(ctx, ownerID, itemID, version)
+}
+
+func (m *mockStorage) CompactRevisions(ctx context.Context, before time.Time) (int, error) {
+	return m.CompactRevisionsFn(ctx, before)
+}
+
+func (m *mockStorage) ListTrash(ctx context.Context, ownerID string) ([]*entity.TrashItem, error) {
+	return m.ListTrashFn(ctx, ownerID)
+}
+
+func (m *mockStorage) RestoreTrash(
+	ctx context.Context,
+	ownerID, itemID string,
+) (*entity.VaultItem, error) {
+	return m.RestoreTrashFn(ctx, ownerID, itemID)
+}
+
+func (m *mockStorage) EmptyTrash(ctx context.Context, ownerID string) (int, error) {
+	return m.EmptyTrashFn(ctx, ownerID)
+}
+
+func (m *mockStorage) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	return m.PurgeTrashFn(ctx, before)
+}
+
+func (m *mockStorage) ExpireItems(ctx context.Context, now time.Time) (int, error) {
+	return m.ExpireItemsFn(ctx, now)
 }
 
 /*
@@ -116,7 +218,15 @@ func TestVault_ListItems(t *testing.T) {
 	mainHandler := handler.NewHandler(nil, mockLogger)
 
 	mockStor := &mockStorage{
-		ListItemsFn: func(_ context.Context, ownerID string) ([]*entity.VaultItem, error) {
+		QueryItemsFn: func(
+			_ context.Context,
+			ownerID string,
+			query *entity.ItemQuery,
+		) ([]*entity.VaultItem, error) {
+			assert.Equal(t, entity.SortByTitle, query.SortBy)
+			assert.Zero(t, query.Limit, "without limit and cursor all items are listed")
+			assert.Nil(t, query.After)
+
 			return []*entity.VaultItem{
 				{ID: "1", OwnerID: ownerID, Title: "Email", Type: entity.ItemLogin},
 			}, nil
@@ -131,6 +241,11 @@ func TestVault_ListItems(t *testing.T) {
 	vaultHandler.ListItems(rr, req)
 
 	assertHTTP(t, rr.Code, rr.Body.String())
+
+	var items []entity.VaultItem
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &items), "bare array without paging parameters")
+	require.Len(t, items, 1)
+	assert.Equal(t, "Email", items[0].Title)
 }
 
 func TestVault_ListItems_StorageError(t *testing.T) {
@@ -140,7 +255,11 @@ func TestVault_ListItems_StorageError(t *testing.T) {
 	mainHandler := handler.NewHandler(nil, mockLogger)
 
 	mockStor := &mockStorage{
-		ListItemsFn: func(_ context.Context, _ string) ([]*entity.VaultItem, error) {
+		QueryItemsFn: func(
+			_ context.Context,
+			_ string,
+			_ *entity.ItemQuery,
+		) ([]*entity.VaultItem, error) {
 			return nil, assert.AnError
 		},
 	}
@@ -156,6 +275,133 @@ func TestVault_ListItems_StorageError(t *testing.T) {
 	assert.Equal(t, "Error\n", rr.Body.String())
 }
 
+type itemsPage struct {
+	Items   []entity.VaultItem `json:"items"`
+	Cursor  string             `json:"cursor"`
+	HasMore bool               `json:"hasMore"`
+}
+
+func doListItems(t *testing.T, vaultHandler *vault.Handler, query string) (int, itemsPage) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/vault/items?"+query, http.NoBody)
+	req = withUser(req)
+	rr := httptest.NewRecorder()
+
+	vaultHandler.ListItems(rr, req)
+
+	var page itemsPage
+
+	if rr.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	}
+
+	return rr.Code, page
+}
+
+// doListAll запрашивает записи без параметров страницы: ответ - массив записей.
+func doListAll(t *testing.T, vaultHandler *vault.Handler, query string) (int, []entity.VaultItem) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/vault/items?"+query, http.NoBody)
+	req = withUser(req)
+	rr := httptest.NewRecorder()
+
+	vaultHandler.ListItems(rr, req)
+
+	var items []entity.VaultItem
+
+	if rr.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &items))
+	}
+
+	return rr.Code, items
+}
+
+func TestVault_ListItems_FilterAndPaging(t *testing.T) {
+	t.Parallel()
+
+	mockLogger := testutil.NewMockLogger()
+	mainHandler := handler.NewHandler(nil, mockLogger)
+
+	stor := storage.NewMemoryStorage()
+	ctx := context.Background()
+
+	for _, title := range []string{"Mail work", "bank", "Mail home", "mail old", "Card"} {
+		//nolint:exhaustruct // not all fields needed in test
+		item := &entity.VaultItem{
+			OwnerID: "user-1",
+			Type:    entity.ItemLogin,
+			Title:   title,
+			Meta:    map[string]string{"site": strings.ToLower(title)},
+		}
+		if title == "Card" {
+			item.Type = entity.ItemCard
+		}
+
+		_, err := stor.CreateItem(ctx, item)
+		require.NoError(t, err)
+	}
+
+	vaultHandler := vault.NewHandler(*mainHandler, stor)
+
+	titles := make([]string, 0)
+	cursor := ""
+
+	for range 3 {
+		code, page := doListItems(t, vaultHandler,
+			"type=login&title=MAIL&titleMatch=prefix&order=desc&limit=2&cursor="+cursor)
+		require.Equal(t, http.StatusOK, code)
+
+		for _, item := range page.Items {
+			titles = append(titles, item.Title)
+		}
+
+		if !page.HasMore {
+			assert.Empty(t, page.Cursor)
+
+			break
+		}
+
+		cursor = page.Cursor
+	}
+
+	assert.Equal(t, []string{"mail old", "Mail work", "Mail home"}, titles)
+
+	code, items := doListAll(t, vaultHandler, "metaKey=site&metaValue=card")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, items, 1)
+	assert.Equal(t, "Card", items[0].Title)
+
+	code, page := doListItems(t, vaultHandler, "sort=updatedAt&limit=1")
+	require.Equal(t, http.StatusOK, code)
+	require.True(t, page.HasMore)
+
+	code, _ = doListItems(t, vaultHandler, "sort=title&cursor="+page.Cursor)
+	assert.Equal(t, http.StatusBadRequest, code, "cursor is bound to its sort order")
+}
+
+func TestVault_ListItems_BadRequest(t *testing.T) {
+	t.Parallel()
+
+	mockLogger := testutil.NewMockLogger()
+	mainHandler := handler.NewHandler(nil, mockLogger)
+	vaultHandler := vault.NewHandler(*mainHandler, &mockStorage{})
+
+	for _, query := range []string{
+		"sort=size",
+		"order=up",
+		"titleMatch=regexp",
+		"metaValue=x",
+		"limit=0",
+		"cursor=not*base64",
+		"cursor=" + base64.RawURLEncoding.EncodeToString([]byte("{}")),
+	} {
+		code, _ := doListItems(t, vaultHandler, query)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+}
+
 /*
 	===== Handler.GetItem =====
 */
@@ -306,6 +552,158 @@ func TestVault_UpsertItem_Conflict(t *testing.T) {
 	assert.Equal(t, "Error\n", rr.Body.String())
 }
 
+func TestVault_UpsertItem_VersionConflict(t *testing.T) {
+	t.Parallel()
+
+	mockLogger := testutil.NewMockLogger()
+	mainHandler := handler.NewHandler(nil, mockLogger)
+	vaultHandler := vault.NewHandler(*mainHandler, &mockStorage{
+		UpsertItemFn: func(_ context.Context, _ *entity.VaultItem) (string, error) {
+			return "", storage.ErrVersionConflict
+		},
+		GetItemFn: func(_ context.Context, ownerID, id string) (*entity.VaultItem, error) {
+			//nolint:exhaustruct // not all fields needed in test
+			item := &entity.VaultItem{ID: id, OwnerID: ownerID, Title: "Server", Version: 5}
+
+			return item, nil
+		},
+	})
+
+	body := map[string]any{"id": "1", "type": "login", "title": "Client", "version": 3}
+	req := httptest.NewRequest(http.MethodPost, "/vault/items", mustJSONBody(t, body))
+	req.Header.Set("Content-Type", "application/json")
+	req = withUser(req)
+
+	rr := httptest.NewRecorder()
+	vaultHandler.UpsertItem(rr, req)
+
+	require.Equal(t, http.StatusConflict, rr.Code)
+
+	var got struct {
+		Item  entity.VaultItem `json:"item"`
+		Error string           `json:"error"`
+	}
+
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, "1", got.Item.ID)
+	assert.Equal(t, "Server", got.Item.Title)
+	assert.Equal(t, int64(5), got.Item.Version)
+	assert.NotEmpty(t, got.Error)
+}
+
+func TestVault_UpsertItem_StorageError(t *testing.T) {
+	t.Parallel()
+
+	mockLogger := testutil.NewMockLogger()
+	mainHandler := handler.NewHandler(nil, mockLogger)
+	vaultHandler := vault.NewHandler(*mainHandler, &mockStorage{
+		UpsertItemFn: func(_ context.Context, _ *entity.VaultItem) (string, error) {
+			return "", assert.AnError
+		},
+	})
+
+	body := map[string]any{"id": "1", "type": "login", "title": "Email"}
+	req := httptest.NewRequest(http.MethodPost, "/vault/items", mustJSONBody(t, body))
+	req.Header.Set("Content-Type", "application/json")
+	req = withUser(req)
+
+	rr := httptest.NewRecorder()
+	vaultHandler.UpsertItem(rr, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Equal(t, "Error\n", rr.Body.String())
+}
+
+func TestVault_UpsertItem_QuotaExceeded(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		err  *storage.QuotaError
+		name string
+		code int
+	}{
+		{
+			name: "item too large",
+			err:  &storage.QuotaError{Limit: storage.QuotaItemBytes, Max: 10, Used: 0, Requested: 20},
+			code: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "too many items",
+			err:  &storage.QuotaError{Limit: storage.QuotaItems, Max: 5, Used: 5, Requested: 1},
+			code: http.StatusInsufficientStorage,
+		},
+		{
+			name: "too many bytes",
+			err:  &storage.QuotaError{Limit: storage.QuotaBytes, Max: 100, Used: 90, Requested: 20},
+			code: http.StatusInsufficientStorage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockLogger := testutil.NewMockLogger()
+			mainHandler := handler.NewHandler(nil, mockLogger)
+			vaultHandler := vault.NewHandler(*mainHandler, &mockStorage{
+				UpsertItemFn: func(_ context.Context, _ *entity.VaultItem) (string, error) {
+					return "", fmt.Errorf("wrapped: %w", tt.err)
+				},
+			})
+
+			body := map[string]any{"id": "1", "type": "login", "title": "Email"}
+			req := httptest.NewRequest(http.MethodPost, "/vault/items", mustJSONBody(t, body))
+			req = withUser(req)
+
+			rr := httptest.NewRecorder()
+			vaultHandler.UpsertItem(rr, req)
+
+			require.Equal(t, tt.code, rr.Code)
+
+			var got struct {
+				Error     string `json:"error"`
+				Limit     string `json:"limit"`
+				Max       int64  `json:"max"`
+				Used      int64  `json:"used"`
+				Requested int64  `json:"requested"`
+			}
+
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+			assert.Equal(t, string(tt.err.Limit), got.Limit)
+			assert.Equal(t, tt.err.Max, got.Max)
+			assert.Equal(t, tt.err.Used, got.Used)
+			assert.Equal(t, tt.err.Requested, got.Requested)
+			assert.NotEmpty(t, got.Error)
+		})
+	}
+}
+
+func TestVault_UpsertItem_BodyTooLarge(t *testing.T) {
+	t.Parallel()
+
+	mockLogger := testutil.NewMockLogger()
+	mainHandler := handler.NewHandler(nil, mockLogger)
+	vaultHandler := vault.NewHandler(*mainHandler, &mockStorage{
+		UpsertItemFn: func(_ context.Context, _ *entity.VaultItem) (string, error) {
+			t.Fatal("storage must not be called")
+
+			return "", nil
+		},
+	})
+	vaultHandler.Quota = entity.Quota{MaxItems: 0, MaxBytes: 0, MaxItemBytes: 16}
+
+	body := map[string]any{"id": "1", "type": "text", "data": strings.Repeat("x", vault.BodyOverhead)}
+	req := httptest.NewRequest(http.MethodPost, "/vault/items", mustJSONBody(t, body))
+	req = withUser(req)
+
+	rr := httptest.NewRecorder()
+	vaultHandler.UpsertItem(rr, req)
+
+	require.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"limit":"itemBytes"`)
+	assert.Contains(t, rr.Body.String(), `"max":16`)
+}
+
 /*
 	===== Handler.DeleteItem =====
 */
@@ -351,10 +749,83 @@ func TestVault_DeleteItem_Error(t *testing.T) {
 }
 
 /*
-	===== Handler.SyncSince =====
+	===== Handler.Sync =====
 */
 
-func TestVault_SyncSince_OK(t *testing.T) {
+type syncPage struct {
+	Items   []map[string]any `json:"items"`
+	Cursor  string           `json:"cursor"`
+	HasMore bool             `json:"hasMore"`
+}
+
+func doSync(t *testing.T, vaultHandler *vault.Handler, query string) (int, syncPage) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/vault/sync"+query, http.NoBody)
+	req = withUser(req)
+
+	rr := httptest.NewRecorder()
+	vaultHandler.Sync(rr, req)
+
+	var page syncPage
+
+	if rr.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	}
+
+	return rr.Code, page
+}
+
+func TestVault_Sync_Paging(t *testing.T) {
+	t.Parallel()
+
+	mockLogger := testutil.NewMockLogger()
+	mainHandler := handler.NewHandler(nil, mockLogger)
+
+	changes := []*entity.VaultItem{
+		{ID: "1", OwnerID: "user-1", Seq: 1},
+		{ID: "2", OwnerID: "user-1", Seq: 2},
+		{ID: "3", OwnerID: "user-1", Seq: 3},
+	}
+
+	vaultHandler := vault.NewHandler(*mainHandler, &mockStorage{
+		ListChangesFn: func(_ context.Context, ownerID string, afterSeq int64, limit int) ([]*entity.VaultItem, error) {
+			assert.Equal(t, "user-1", ownerID)
+
+			res := make([]*entity.VaultItem, 0)
+
+			for _, it := range changes {
+				if it.Seq > afterSeq && len(res) < limit {
+					res = append(res, it)
+				}
+			}
+
+			return res, nil
+		},
+	})
+
+	code, page := doSync(t, vaultHandler, "?limit=2")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, page.Items, 2)
+	assert.True(t, page.HasMore)
+	assert.NotEmpty(t, page.Cursor)
+
+	code, page = doSync(t, vaultHandler, "?limit=2&cursor="+page.Cursor)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "3", page.Items[0]["id"])
+	assert.False(t, page.HasMore)
+
+	cursor := page.Cursor
+
+	code, page = doSync(t, vaultHandler, "?cursor="+cursor)
+	require.Equal(t, http.StatusOK, code)
+	assert.Empty(t, page.Items)
+	assert.False(t, page.HasMore)
+	assert.Equal(t, cursor, page.Cursor)
+}
+
+func TestVault_Sync_Deleted(t *testing.T) {
 	t.Parallel()
 
 	mockLogger := testutil.NewMockLogger()
@@ -362,42 +833,572 @@ func TestVault_SyncSince_OK(t *testing.T) {
 	now := time.Now().UTC()
 
 	vaultHandler := vault.NewHandler(*mainHandler, &mockStorage{
-		ListChangedSinceFn: func(_ context.Context, ownerID string, since time.Time) ([]*entity.VaultItem, error) {
+		ListChangesFn: func(_ context.Context, ownerID string, _ int64, _ int) ([]*entity.VaultItem, error) {
+			tomb := &entity.Tombstone{ID: "1", OwnerID: ownerID, Version: 3, DeletedAt: now, Seq: 7}
+
+			return []*entity.VaultItem{tomb.AsItem()}, nil
+		},
+	})
+
+	code, page := doSync(t, vaultHandler, "")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "1", page.Items[0]["id"])
+	assert.Equal(t, true, page.Items[0]["deleted"])
+	assert.InDelta(t, 3, page.Items[0]["version"], 0)
+}
+
+func TestVault_Sync_BadRequest(t *testing.T) {
+	t.Parallel()
+
+	mockLogger := testutil.NewMockLogger()
+	mainHandler := handler.NewHandler(nil, mockLogger)
+
+	vaultHandler := vault.NewHandler(*mainHandler, &mockStorage{
+		ListChangesFn: func(_ context.Context, _ string, _ int64, _ int) ([]*entity.VaultItem, error) {
+			t.Fatal("storage must not be called")
+
+			return nil, nil
+		},
+	})
+
+	for _, query := range []string{
+		"?cursor=2024-01-01T00:00:00Z",
+		"?cursor=" + base64.RawURLEncoding.EncodeToString([]byte("v1:-1")),
+		"?limit=0",
+		"?limit=abc",
+	} {
+		code, _ := doSync(t, vaultHandler, query)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+}
+
+func TestVault_Sync_Error(t *testing.T) {
+	t.Parallel()
+
+	mockLogger := testutil.NewMockLogger()
+	mainHandler := handler.NewHandler(nil, mockLogger)
+
+	vaultHandler := vault.NewHandler(*mainHandler, &mockStorage{
+		ListChangesFn: func(_ context.Context, _ string, _ int64, _ int) ([]*entity.VaultItem, error) {
+			return nil, assert.AnError
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/vault/sync", http.NoBody)
+	req = withUser(req)
+
+	rr := httptest.NewRecorder()
+	vaultHandler.Sync(rr, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Equal(t, "Error\n", rr.Body.String())
+}
+
+/*
+	===== Handler.SyncPush =====
+*/
+
+type pushPage struct {
+	Results []map[string]any `json:"results"`
+	Cursor  string           `json:"cursor"`
+}
+
+func doSyncPush(t *testing.T, vaultHandler *vault.Handler, body string) (int, pushPage) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/vault/sync", bytes.NewBufferString(body))
+	req = withUser(req)
+
+	rr := httptest.NewRecorder()
+	vaultHandler.SyncPush(rr, req)
+
+	var page pushPage
+
+	if rr.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	}
+
+	return rr.Code, page
+}
+
+// cursorAt получает курсор, указывающий на изменение seq, через GET /vault/sync.
+func cursorAt(t *testing.T, seq int64) string {
+	t.Helper()
+
+	mainHandler := handler.NewHandler(nil, testutil.NewMockLogger())
+	vaultHandler := vault.NewHandler(*mainHandler, &mockStorage{
+		ListChangesFn: func(_ context.Context, _ string, _ int64, _ int) ([]*entity.VaultItem, error) {
+			return []*entity.VaultItem{{ID: "x", Seq: seq}}, nil
+		},
+	})
+
+	code, page := doSync(t, vaultHandler, "")
+	require.Equal(t, http.StatusOK, code)
+
+	return page.Cursor
+}
+
+func TestVault_SyncPush_Results(t *testing.T) {
+	t.Parallel()
+
+	mockLogger := testutil.NewMockLogger()
+	mainHandler := handler.NewHandler(nil, mockLogger)
+
+	vaultHandler := vault.NewHandler(*mainHandler, &mockStorage{
+		ApplyChangesFn: func(_ context.Context, ownerID string, changes []*entity.Change) ([]*entity.ChangeResult, error) {
 			assert.Equal(t, "user-1", ownerID)
-			assert.False(t, since.IsZero())
+			require.Len(t, changes, 3)
+			assert.Equal(t, entity.ChangeUpsert, changes[0].Op)
+			assert.Equal(t, "Note", changes[0].Item.Title)
+			assert.Equal(t, entity.ChangeDelete, changes[1].Op)
+			assert.Equal(t, int64(4), changes[1].Item.Version)
+
+			return []*entity.ChangeResult{
+				{ID: "a", Status: entity.ChangeApplied, Item: &entity.VaultItem{ID: "a", Version: 1, Seq: 11}},
+				{ID: "b", Status: entity.ChangeConflict, Error: "conflict", Item: &entity.VaultItem{ID: "b", Version: 5, Seq: 12}},
+				{ID: "c", Status: entity.ChangeRejected, Error: "invalid change"},
+			}, nil
+		},
+		ListChangesFn: func(_ context.Context, _ string, afterSeq int64, _ int) ([]*entity.VaultItem, error) {
+			assert.Equal(t, int64(10), afterSeq)
 
-			return []*entity.VaultItem{{ID: "1", OwnerID: ownerID, UpdatedAt: now}}, nil
+			return []*entity.VaultItem{{ID: "a", Seq: 11}, {ID: "b", Seq: 12}}, nil
 		},
 	})
 
-	path := "/vault/sync?since=" + now.Add(-time.Minute).Format(time.RFC3339)
-	req := httptest.NewRequest(http.MethodGet, path, http.NoBody)
+	body := `{"cursor":"` + cursorAt(t, 10) + `","changes":[
+		{"op":"upsert","id":"a","type":"text","title":"Note"},
+		{"op":"delete","id":"b","version":4},
+		{"op":"move","id":"c"}
+	]}`
+
+	code, page := doSyncPush(t, vaultHandler, body)
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, page.Results, 3)
+	assert.Equal(t, "applied", page.Results[0]["status"])
+	assert.Equal(t, "conflict", page.Results[1]["status"])
+	assert.NotNil(t, page.Results[1]["item"])
+	assert.Equal(t, "rejected", page.Results[2]["status"])
+	assert.Nil(t, page.Results[2]["item"])
+	assert.Equal(t, cursorAt(t, 12), page.Cursor)
+}
+
+func TestVault_SyncPush_ForeignChange(t *testing.T) {
+	t.Parallel()
+
+	mockLogger := testutil.NewMockLogger()
+	mainHandler := handler.NewHandler(nil, mockLogger)
+
+	vaultHandler := vault.NewHandler(*mainHandler, &mockStorage{
+		ApplyChangesFn: func(_ context.Context, _ string, _ []*entity.Change) ([]*entity.ChangeResult, error) {
+			return []*entity.ChangeResult{
+				{ID: "a", Status: entity.ChangeApplied, Item: &entity.VaultItem{ID: "a", Seq: 12}},
+			}, nil
+		},
+		ListChangesFn: func(_ context.Context, _ string, _ int64, _ int) ([]*entity.VaultItem, error) {
+			// Изменение 11 сделано другим устройством и ещё не получено клиентом.
+			return []*entity.VaultItem{{ID: "z", Seq: 11}}, nil
+		},
+	})
+
+	cursor := cursorAt(t, 10)
+	body := `{"cursor":"` + cursor + `","changes":[{"op":"upsert","id":"a"}]}`
+
+	code, page := doSyncPush(t, vaultHandler, body)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, cursor, page.Cursor)
+}
+
+func TestVault_SyncPush_BadRequest(t *testing.T) {
+	t.Parallel()
+
+	mockLogger := testutil.NewMockLogger()
+	mainHandler := handler.NewHandler(nil, mockLogger)
+
+	vaultHandler := vault.NewHandler(*mainHandler, &mockStorage{
+		ApplyChangesFn: func(_ context.Context, _ string, _ []*entity.Change) ([]*entity.ChangeResult, error) {
+			t.Fatal("storage must not be called")
+
+			return nil, nil
+		},
+	})
+
+	tooLarge := `{"changes":[` + strings.Repeat(`{"op":"delete","id":"a"},`, vault.SyncMaxBatch) +
+		`{"op":"delete","id":"a"}]}`
+
+	for _, body := range []string{
+		"{bad json",
+		`{"cursor":"bad","changes":[]}`,
+		tooLarge,
+	} {
+		code, _ := doSyncPush(t, vaultHandler, body)
+		assert.Equal(t, http.StatusBadRequest, code)
+	}
+}
+
+func TestVault_SyncPush_StorageError(t *testing.T) {
+	t.Parallel()
+
+	mockLogger := testutil.NewMockLogger()
+	mainHandler := handler.NewHandler(nil, mockLogger)
+
+	vaultHandler := vault.NewHandler(*mainHandler, &mockStorage{
+		ApplyChangesFn: func(_ context.Context, _ string, _ []*entity.Change) ([]*entity.ChangeResult, error) {
+			return nil, assert.AnError
+		},
+	})
+
+	code, _ := doSyncPush(t, vaultHandler, `{"changes":[{"op":"delete","id":"a"}]}`)
+	assert.Equal(t, http.StatusInternalServerError, code)
+}
+
+/*
+	===== Handler.ListRevisions / GetRevision / RestoreRevision =====
+*/
+
+func revisionAt(version int64, title string) *entity.Revision {
+	//nolint:exhaustruct // not all fields needed in test
+	item := &entity.VaultItem{
+		ID:      "1",
+		OwnerID: "user-1",
+		Type:    entity.ItemLogin,
+		Title:   title,
+		Version: version,
+	}
+
+	return &entity.Revision{CreatedAt: time.Now().UTC(), Item: item}
+}
+
+func doRevisionRequest(
+	t *testing.T,
+	handle http.HandlerFunc,
+	method, version string,
+	body string,
+) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, "/vault/items/1/revisions/"+version, strings.NewReader(body))
 	req = withUser(req)
+	req.SetPathValue("id", "1")
+	req.SetPathValue("version", version)
 
 	rr := httptest.NewRecorder()
-	vaultHandler.SyncSince(rr, req)
+	handle(rr, req)
+
+	return rr
+}
+
+func TestVault_ListRevisions(t *testing.T) {
+	t.Parallel()
+
+	mockLogger := testutil.NewMockLogger()
+	mainHandler := handler.NewHandler(nil, mockLogger)
+
+	vaultHandler := vault.NewHandler(*mainHandler, &mockStorage{
+		ListRevisionsFn: func(_ context.Context, ownerID, itemID string) ([]*entity.Revision, error) {
+			assert.Equal(t, "user-1", ownerID)
+			assert.Equal(t, "1", itemID)
+
+			return []*entity.Revision{revisionAt(2, "second"), revisionAt(1, "first")}, nil
+		},
+	})
+
+	rr := doRevisionRequest(t, vaultHandler.ListRevisions, http.MethodGet, "", "")
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var got []entity.Revision
+
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	require.Len(t, got, 2)
+	assert.Equal(t, int64(2), got[0].Item.Version)
+	assert.Equal(t, "first", got[1].Item.Title)
+}
+
+func TestVault_GetRevision(t *testing.T) {
+	t.Parallel()
+
+	mockLogger := testutil.NewMockLogger()
+	mainHandler := handler.NewHandler(nil, mockLogger)
 
+	vaultHandler := vault.NewHandler(*mainHandler, &mockStorage{
+		GetRevisionFn: func(_ context.Context, _, _ string, version int64) (*entity.Revision, error) {
+			if version != 2 {
+				return nil, storage.ErrEntityNotFound
+			}
+
+			return revisionAt(2, "second"), nil
+		},
+	})
+
+	rr := doRevisionRequest(t, vaultHandler.GetRevision, http.MethodGet, "2", "")
 	assertHTTP(t, rr.Code, rr.Body.String())
+
+	rr = doRevisionRequest(t, vaultHandler.GetRevision, http.MethodGet, "7", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = doRevisionRequest(t, vaultHandler.GetRevision, http.MethodGet, "abc", "")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestVault_RestoreRevision(t *testing.T) {
+	t.Parallel()
+
+	mockLogger := testutil.NewMockLogger()
+	mainHandler := handler.NewHandler(nil, mockLogger)
+
+	var saved *entity.VaultItem
+
+	vaultHandler := vault.NewHandler(*mainHandler, &mockStorage{
+		GetRevisionFn: func(_ context.Context, _, _ string, _ int64) (*entity.Revision, error) {
+			return revisionAt(2, "second"), nil
+		},
+		UpsertItemFn: func(_ context.Context, it *entity.VaultItem) (string, error) {
+			cp := *it
+			saved = &cp
+			it.Version = 6
+
+			return it.ID, nil
+		},
+	})
+
+	rr := doRevisionRequest(t, vaultHandler.RestoreRevision, http.MethodPost, "2", `{"version":5}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	require.NotNil(t, saved)
+	assert.Equal(t, "second", saved.Title)
+	assert.Equal(t, int64(5), saved.Version, "restore must be checked against the client version")
+	assert.Equal(t, &entity.Content{Hash: "", Size: 0}, saved.Content, "revision without content clears it")
+	assert.Equal(t, []entity.Attachment{}, saved.Attachments, "revision without attachments clears them")
+
+	var got entity.VaultItem
+
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, int64(6), got.Version, "response carries the stored version")
+	assert.Equal(t, "second", got.Title)
+	assert.Nil(t, got.Content)
+}
+
+func TestVault_RestoreRevision_Errors(t *testing.T) {
+	t.Parallel()
+
+	mockLogger := testutil.NewMockLogger()
+	mainHandler := handler.NewHandler(nil, mockLogger)
+
+	vaultHandler := vault.NewHandler(*mainHandler, &mockStorage{
+		GetRevisionFn: func(_ context.Context, _, _ string, version int64) (*entity.Revision, error) {
+			if version != 2 {
+				return nil, storage.ErrEntityNotFound
+			}
+
+			return revisionAt(2, "second"), nil
+		},
+		UpsertItemFn: func(_ context.Context, _ *entity.VaultItem) (string, error) {
+			return "", storage.ErrVersionConflict
+		},
+		GetItemFn: func(_ context.Context, ownerID, id string) (*entity.VaultItem, error) {
+			//nolint:exhaustruct // not all fields needed in test
+			item := &entity.VaultItem{ID: id, OwnerID: ownerID, Title: "Server", Version: 9}
+
+			return item, nil
+		},
+	})
+
+	rr := doRevisionRequest(t, vaultHandler.RestoreRevision, http.MethodPost, "2", `{"version":5}`)
+	assert.Equal(t, http.StatusConflict, rr.Code)
+
+	rr = doRevisionRequest(t, vaultHandler.RestoreRevision, http.MethodPost, "3", "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	rr = doRevisionRequest(t, vaultHandler.RestoreRevision, http.MethodPost, "0", "")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = doRevisionRequest(t, vaultHandler.RestoreRevision, http.MethodPost, "2", "{")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestVault_RestoreRevision_Expired(t *testing.T) {
+	t.Parallel()
+
+	mockLogger := testutil.NewMockLogger()
+	mainHandler := handler.NewHandler(nil, mockLogger)
+
+	past := time.Now().Add(-time.Minute)
+
+	vaultHandler := vault.NewHandler(*mainHandler, &mockStorage{
+		GetRevisionFn: func(_ context.Context, _, _ string, _ int64) (*entity.Revision, error) {
+			rev := revisionAt(2, "otp")
+			rev.Item.ExpiresAt = &past
+
+			return rev, nil
+		},
+		UpsertItemFn: func(_ context.Context, _ *entity.VaultItem) (string, error) {
+			t.Fatal("expired revision must not be restored")
+
+			return "", nil
+		},
+	})
+
+	rr := doRevisionRequest(t, vaultHandler.RestoreRevision, http.MethodPost, "2", "")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+/*
+	===== Handler.ListTrash / RestoreTrash / EmptyTrash =====
+*/
+
+func TestVault_ListTrash(t *testing.T) {
+	t.Parallel()
+
+	mockLogger := testutil.NewMockLogger()
+	mainHandler := handler.NewHandler(nil, mockLogger)
+
+	now := time.Now().UTC()
+
+	vaultHandler := vault.NewHandler(*mainHandler, &mockStorage{
+		ListTrashFn: func(_ context.Context, ownerID string) ([]*entity.TrashItem, error) {
+			assert.Equal(t, "user-1", ownerID)
+
+			//nolint:exhaustruct // not all fields needed in test
+			item := &entity.VaultItem{ID: "1", OwnerID: ownerID, Title: "Email", Version: 2}
+
+			return []*entity.TrashItem{{DeletedAt: now, Item: item}}, nil
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/vault/trash", http.NoBody)
+	req = withUser(req)
+
+	rr := httptest.NewRecorder()
+	vaultHandler.ListTrash(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var got []entity.TrashItem
+
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	require.Len(t, got, 1)
+	assert.Equal(t, "Email", got[0].Item.Title)
+	assert.WithinDuration(t, now, got[0].DeletedAt, time.Second)
+}
+
+func TestVault_RestoreTrash(t *testing.T) {
+	t.Parallel()
+
+	mockLogger := testutil.NewMockLogger()
+	mainHandler := handler.NewHandler(nil, mockLogger)
+
+	vaultHandler := vault.NewHandler(*mainHandler, &mockStorage{
+		RestoreTrashFn: func(_ context.Context, ownerID, itemID string) (*entity.VaultItem, error) {
+			switch itemID {
+			case "1":
+				//nolint:exhaustruct // not all fields needed in test
+				item := &entity.VaultItem{ID: itemID, OwnerID: ownerID, Title: "Email", Version: 4}
+
+				return item, nil
+			case "500":
+				return nil, assert.AnError
+			default:
+				return nil, storage.ErrEntityNotFound
+			}
+		},
+	})
+
+	restore := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/vault/trash/"+id+"/restore", http.NoBody)
+		req = withUser(req)
+		req.SetPathValue("id", id)
+
+		rr := httptest.NewRecorder()
+		vaultHandler.RestoreTrash(rr, req)
+
+		return rr
+	}
+
+	rr := restore("1")
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var got entity.VaultItem
+
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &got))
+	assert.Equal(t, int64(4), got.Version)
+
+	assert.Equal(t, http.StatusNotFound, restore("404").Code)
+	assert.Equal(t, http.StatusInternalServerError, restore("500").Code)
 }
 
-func TestVault_SyncSince_Error(t *testing.T) {
+func TestVault_EmptyTrash(t *testing.T) {
 	t.Parallel()
 
 	mockLogger := testutil.NewMockLogger()
 	mainHandler := handler.NewHandler(nil, mockLogger)
 
 	vaultHandler := vault.NewHandler(*mainHandler, &mockStorage{
-		ListChangedSinceFn: func(_ context.Context, _ string, _ time.Time) ([]*entity.VaultItem, error) {
+		EmptyTrashFn: func(_ context.Context, ownerID string) (int, error) {
+			assert.Equal(t, "user-1", ownerID)
+
+			return 3, nil
+		},
+	})
+
+	req := httptest.NewRequest(http.MethodDelete, "/vault/trash", http.NoBody)
+	req = withUser(req)
+
+	rr := httptest.NewRecorder()
+	vaultHandler.EmptyTrash(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"purged":3}`, rr.Body.String())
+}
+
+/*
+	===== Handler.Usage =====
+*/
+
+func TestVault_Usage(t *testing.T) {
+	t.Parallel()
+
+	mockLogger := testutil.NewMockLogger()
+	mainHandler := handler.NewHandler(nil, mockLogger)
+
+	vaultHandler := vault.NewHandler(*mainHandler, &mockStorage{
+		GetUsageFn: func(_ context.Context, ownerID string) (*entity.Usage, error) {
+			assert.Equal(t, "user-1", ownerID)
+
+			return &entity.Usage{Items: 2, Bytes: 40}, nil
+		},
+	})
+	vaultHandler.Quota = entity.Quota{MaxItems: 10, MaxBytes: 1000, MaxItemBytes: 100}
+
+	req := httptest.NewRequest(http.MethodGet, "/vault/usage", http.NoBody)
+	req = withUser(req)
+
+	rr := httptest.NewRecorder()
+	vaultHandler.Usage(rr, req)
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t,
+		`{"usage":{"items":2,"bytes":40},"quota":{"maxItems":10,"maxBytes":1000,"maxItemBytes":100}}`,
+		rr.Body.String())
+}
+
+func TestVault_Usage_Error(t *testing.T) {
+	t.Parallel()
+
+	mockLogger := testutil.NewMockLogger()
+	mainHandler := handler.NewHandler(nil, mockLogger)
+
+	vaultHandler := vault.NewHandler(*mainHandler, &mockStorage{
+		GetUsageFn: func(_ context.Context, _ string) (*entity.Usage, error) {
 			return nil, assert.AnError
 		},
 	})
 
-	req := httptest.NewRequest(http.MethodGet, "/vault/sync", http.NoBody)
+	req := httptest.NewRequest(http.MethodGet, "/vault/usage", http.NoBody)
 	req = withUser(req)
 
 	rr := httptest.NewRecorder()
-	vaultHandler.SyncSince(rr, req)
+	vaultHandler.Usage(rr, req)
 
 	assert.Equal(t, http.StatusInternalServerError, rr.Code)
-	assert.Equal(t, "Error\n", rr.Body.String())
 }
@@ -1,13 +1,160 @@
 // Package vault предоставляет функционал для обработчиков запросов для работы с хранилищем.
 package vault
 
-import "github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+import (
+	"time"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+)
 
 type upsertReq struct {
-	ID      string            `json:"id"`
-	Type    entity.ItemType   `json:"type"`
-	Title   string            `json:"title"`
-	Meta    map[string]string `json:"meta"`
-	Data    string            `json:"data"`
-	Version int64             `json:"version"`
+	ID        string            `json:"id"`
+	Type      entity.ItemType   `json:"type"`
+	Title     string            `json:"title"`
+	Meta      map[string]string `json:"meta"`
+	Data      string            `json:"data"`
+	FolderID  string            `json:"folderId"` // папка записи (пусто - вне папок)
+	Tags      []string          `json:"tags"`
+	ExpiresAt *time.Time        `json:"expiresAt"` // срок действия записи (пусто - бессрочная)
+	Version   int64             `json:"version"`
+}
+
+// itemsResp - страница записей пользователя.
+type itemsResp struct {
+	Items   []*entity.VaultItem `json:"items"`
+	Cursor  string              `json:"cursor,omitempty"` // курсор следующей страницы
+	HasMore bool                `json:"hasMore"`          // есть ли записи после этой страницы
+}
+
+// syncResp - страница ленты изменений.
+type syncResp struct {
+	Items   []*entity.VaultItem `json:"items"`
+	Folders []*entity.Folder    `json:"folders"`
+	Cursor  string              `json:"cursor"`  // курсор для следующего запроса
+	HasMore bool                `json:"hasMore"` // есть ли ещё изменения после этой страницы
+}
+
+// syncChangeReq - изменение записи в пакете синхронизации.
+//
+// Version - версия записи, от которой клиент сделал изменение.
+type syncChangeReq struct {
+	Op entity.ChangeOp `json:"op"`
+	upsertReq
+}
+
+// syncPushReq - пакет изменений клиента.
+type syncPushReq struct {
+	Cursor  string          `json:"cursor"` // курсор последней синхронизации клиента
+	Changes []syncChangeReq `json:"changes"`
+}
+
+// changeResp - результат применения изменения.
+type changeResp struct {
+	Item   *entity.VaultItem   `json:"item,omitempty"`
+	ID     string              `json:"id"`
+	Status entity.ChangeStatus `json:"status"`
+	Error  string              `json:"error,omitempty"`
+}
+
+// syncPushResp - результат применения пакета изменений.
+type syncPushResp struct {
+	Results []changeResp `json:"results"`
+	Cursor  string       `json:"cursor"`
+}
+
+// createUploadReq - запрос создания сессии загрузки содержимого.
+type createUploadReq struct {
+	Hash string `json:"hash"` // ожидаемый хеш содержимого (пусто - без проверки)
+	Size int64  `json:"size"` // размер содержимого в байтах
+}
+
+// restoreReq - запрос восстановления прежней версии записи.
+type restoreReq struct {
+	Version int64 `json:"version"` // текущая версия записи у клиента (0 - без проверки)
+}
+
+// conflictResp - ответ при конфликте версий, содержит текущую запись на сервере.
+type conflictResp struct {
+	Item  *entity.VaultItem `json:"item"`
+	Error string            `json:"error"`
+}
+
+// quotaResp - ответ при превышении квоты пользователя.
+type quotaResp struct {
+	Error     string             `json:"error"`
+	Limit     storage.QuotaLimit `json:"limit"`     // превышенное ограничение
+	Max       int64              `json:"max"`       // значение ограничения
+	Used      int64              `json:"used"`      // текущее использование
+	Requested int64              `json:"requested"` // запрошенное увеличение использования
+}
+
+// usageResp - объём данных пользователя и действующие ограничения.
+type usageResp struct {
+	Usage *entity.Usage `json:"usage"`
+	Quota entity.Quota  `json:"quota"`
+}
+
+// publicKeyReq - запрос сохранения открытого ключа пользователя.
+type publicKeyReq struct {
+	Algorithm string `json:"algorithm"` // алгоритм ключа, выбирается клиентами
+	Key       []byte `json:"key"`
+}
+
+// shareItemReq - запрос передачи доступа к записи.
+type shareItemReq struct {
+	Email      string                 `json:"email"` // email получателя
+	Permission entity.SharePermission `json:"permission"`
+	WrappedKey []byte                 `json:"wrappedKey"` // ключ записи, зашифрованный открытым ключом получателя
+}
+
+// sharedItemResp - запись другого пользователя с параметрами доступа к ней.
+type sharedItemResp struct {
+	Item       *entity.VaultItem      `json:"item"`
+	OwnerID    string                 `json:"ownerId"`
+	Permission entity.SharePermission `json:"permission"`
+	WrappedKey []byte                 `json:"wrappedKey"`
+}
+
+// folderReq - запрос создания или изменения папки.
+type folderReq struct {
+	ID       string `json:"id"` // ID новой папки (пусто - выдаётся сервером)
+	Name     string `json:"name"`
+	ParentID string `json:"parentId"` // родительская папка (пусто - корень)
+	Version  int64  `json:"version"`  // текущая версия папки у клиента (при изменении)
+}
+
+// folderConflictResp - ответ при конфликте версий, содержит текущую папку на сервере.
+type folderConflictResp struct {
+	Folder *entity.Folder `json:"folder"`
+	Error  string         `json:"error"`
+}
+
+// itemRefReq - ссылка на версию записи.
+type itemRefReq struct {
+	ID      string `json:"id"`
+	Version int64  `json:"version"` // текущая версия записи у клиента (0 - без проверки)
+}
+
+// moveItemsReq - запрос переноса записей в папку.
+type moveItemsReq struct {
+	FolderID string       `json:"folderId"` // папка назначения (пусто - вне папок)
+	Items    []itemRefReq `json:"items"`
+}
+
+// itemResultsResp - результаты изменения нескольких записей.
+type itemResultsResp struct {
+	Results []changeResp `json:"results"`
+}
+
+// renameTagReq - запрос переименования метки.
+type renameTagReq struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// mergeTagsReq - запрос объединения меток в одну.
+type mergeTagsReq struct {
+	Tags []string `json:"tags"` // объединяемые метки
+	Into string   `json:"into"` // итоговая метка
 }
@@ -0,0 +1,381 @@
+// Package vault предоставляет функционал для обработчиков запросов для работы с хранилищем.
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/handler"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/middleware"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+)
+
+// ShareKeyMaxSize - наибольший размер открытого ключа или зашифрованного ключа записи.
+const ShareKeyMaxSize = 16 << 10
+
+// Ошибки передачи доступа к записям.
+var (
+	ErrEmptyKey          = errors.New("key is empty")
+	ErrKeyTooLarge       = errors.New("key too large")
+	ErrInvalidPermission = errors.New("invalid permission")
+	ErrShareWithSelf     = errors.New("cannot share item with its owner")
+	ErrNoPublicKey       = errors.New("recipient has no public key")
+	ErrShareForbidden    = errors.New("share does not allow this operation")
+)
+
+// SetPublicKey сохраняет открытый ключ пользователя.
+//
+// Другие пользователи шифруют им ключи записей, к которым дают доступ.
+// Замена ключа не меняет уже выданные доступы: их ключи записей
+// зашифрованы прежним открытым ключом.
+func (h *Handler) SetPublicKey(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetUserID(req.Context())
+
+	var keyReq publicKeyReq
+
+	limitBody(resp, req, ShareKeyMaxSize)
+
+	if err := handler.GetDataFromBodyJSON(req, &keyReq); err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	if err := checkShareKey(keyReq.Key); err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	key := &entity.PublicKey{
+		CreatedAt: time.Now().UTC(),
+		UserID:    uid,
+		Algorithm: keyReq.Algorithm,
+		Key:       keyReq.Key,
+	}
+
+	if err := h.Shares.SetPublicKey(req.Context(), key); err != nil {
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	resp.WriteHeader(http.StatusNoContent)
+}
+
+// GetPublicKey выводит открытый ключ пользователя.
+//
+// Параметры запроса:
+//   - email: email пользователя (пусто - свой ключ).
+func (h *Handler) GetPublicKey(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetUserID(req.Context())
+
+	if email := req.URL.Query().Get("email"); email != "" {
+		user, err := h.Stor.FindUserByEmail(req.Context(), email)
+		if err != nil {
+			h.responseLookupError(resp, err)
+
+			return
+		}
+
+		uid = user.ID
+	}
+
+	key, err := h.Shares.GetPublicKey(req.Context(), uid)
+	if err != nil {
+		h.responseLookupError(resp, err)
+
+		return
+	}
+
+	h.ResponceWithJSON(resp, key)
+}
+
+// ListItemShares выводит доступы других пользователей к записи владельца.
+func (h *Handler) ListItemShares(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetUserID(req.Context())
+
+	id := req.PathValue("id")
+
+	if _, err := h.VStor.GetItem(req.Context(), uid, id); err != nil {
+		h.responseLookupError(resp, err)
+
+		return
+	}
+
+	shares, err := h.Shares.ListItemShares(req.Context(), uid, id)
+	if err != nil {
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	h.ResponceWithJSON(resp, shares)
+}
+
+// ShareItem даёт пользователю с указанным email доступ к записи владельца
+// или заменяет уже выданный доступ.
+//
+// Ключ записи передаётся зашифрованным открытым ключом получателя,
+// поэтому получатель должен заранее сохранить открытый ключ.
+func (h *Handler) ShareItem(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetUserID(req.Context())
+
+	id := req.PathValue("id")
+
+	var shareReq shareItemReq
+
+	limitBody(resp, req, ShareKeyMaxSize)
+
+	if err := handler.GetDataFromBodyJSON(req, &shareReq); err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	if err := checkShareReq(&shareReq); err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	if _, err := h.VStor.GetItem(req.Context(), uid, id); err != nil {
+		h.responseLookupError(resp, err)
+
+		return
+	}
+
+	recipient, err := h.Stor.FindUserByEmail(req.Context(), shareReq.Email)
+	if err != nil {
+		h.responseLookupError(resp, err)
+
+		return
+	}
+
+	if recipient.ID == uid {
+		h.ResponseError(resp, http.StatusBadRequest, ErrShareWithSelf)
+
+		return
+	}
+
+	if _, err := h.Shares.GetPublicKey(req.Context(), recipient.ID); err != nil {
+		if errors.Is(err, storage.ErrEntityNotFound) {
+			h.ResponseError(resp, http.StatusConflict, ErrNoPublicKey)
+
+			return
+		}
+
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	share := &entity.Share{
+		CreatedAt:   time.Now().UTC(),
+		OwnerID:     uid,
+		ItemID:      id,
+		RecipientID: recipient.ID,
+		Permission:  shareReq.Permission,
+		WrappedKey:  shareReq.WrappedKey,
+	}
+
+	if err := h.Shares.PutShare(req.Context(), share); err != nil {
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	h.Log.Info("Vault item shared",
+		"id", id,
+		"recipient", recipient.ID,
+		"permission", share.Permission,
+	)
+
+	h.ResponceWithJSON(resp, share)
+}
+
+// RevokeShare отзывает доступ пользователя к записи владельца.
+func (h *Handler) RevokeShare(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetUserID(req.Context())
+
+	id := req.PathValue("id")
+	recipientID := req.PathValue("userId")
+
+	if err := h.Shares.DeleteShare(req.Context(), uid, id, recipientID); err != nil {
+		h.responseLookupError(resp, err)
+
+		return
+	}
+
+	resp.WriteHeader(http.StatusNoContent)
+}
+
+// ListShared выводит записи других пользователей, доступные пользователю.
+//
+// Доступы к удалённым записям пропускаются: при восстановлении записи
+// из корзины они снова становятся действительными.
+func (h *Handler) ListShared(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetUserID(req.Context())
+
+	shares, err := h.Shares.ListSharedWith(req.Context(), uid)
+	if err != nil {
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	res := make([]sharedItemResp, 0, len(shares))
+
+	for _, share := range shares {
+		item, err := h.VStor.GetItem(req.Context(), share.OwnerID, share.ItemID)
+		if errors.Is(err, storage.ErrEntityNotFound) {
+			continue
+		}
+
+		if err != nil {
+			h.ResponseError(resp, http.StatusInternalServerError, err)
+
+			return
+		}
+
+		res = append(res, newSharedItemResp(share, item))
+	}
+
+	h.ResponceWithJSON(resp, res)
+}
+
+// GetSharedItem выводит запись другого пользователя, доступную пользователю.
+func (h *Handler) GetSharedItem(resp http.ResponseWriter, req *http.Request) {
+	share, ok := h.requireShare(resp, req, entity.ShareRead)
+	if !ok {
+		return
+	}
+
+	item, err := h.VStor.GetItem(req.Context(), share.OwnerID, share.ItemID)
+	if err != nil {
+		h.responseLookupError(resp, err)
+
+		return
+	}
+
+	h.ResponceWithJSON(resp, newSharedItemResp(share, item))
+}
+
+// UpdateSharedItem обновляет запись другого пользователя, доступную пользователю на запись.
+//
+// Изменение учитывается в квоте владельца, папка и метки записи остаются прежними.
+// При расхождении версии с серверной возвращает 409 и текущую запись сервера.
+func (h *Handler) UpdateSharedItem(resp http.ResponseWriter, req *http.Request) {
+	share, ok := h.requireShare(resp, req, entity.ShareWrite)
+	if !ok {
+		return
+	}
+
+	var upReq upsertReq
+
+	limitBody(resp, req, h.Quota.MaxItemBytes)
+
+	if err := handler.GetDataFromBodyJSON(req, &upReq); err != nil {
+		h.responseBodyError(resp, err, storage.QuotaItemBytes, h.Quota.MaxItemBytes)
+
+		return
+	}
+
+	current, err := h.VStor.GetItem(req.Context(), share.OwnerID, share.ItemID)
+	if err != nil {
+		h.responseLookupError(resp, err)
+
+		return
+	}
+
+	item := &entity.VaultItem{
+		ID:          share.ItemID,
+		OwnerID:     share.OwnerID,
+		Type:        upReq.Type,
+		Title:       upReq.Title,
+		Meta:        upReq.Meta,
+		Data:        upReq.Data,
+		Content:     nil,
+		FolderID:    current.FolderID,
+		Tags:        current.Tags,
+		Attachments: nil,
+		ExpiresAt:   current.ExpiresAt,
+		Version:     upReq.Version,
+		UpdatedAt:   time.Now().UTC(),
+		Description: "",
+		Username:    "",
+		Seq:         0,
+		Deleted:     false,
+		Expired:     false,
+	}
+
+	if err := h.VStor.UpdateItem(req.Context(), item); err != nil {
+		h.responseUpsertError(resp, req, item, err)
+
+		return
+	}
+
+	h.ResponceWithJSON(resp, map[string]any{"id": item.ID})
+}
+
+// requireShare получает доступ пользователя к записи из пути запроса и проверяет,
+// что он разрешает операцию need. При ошибке формирует ответ и возвращает false.
+//
+// На отсутствующий доступ отвечает 404, чтобы не раскрывать чужие записи.
+func (h *Handler) requireShare(
+	resp http.ResponseWriter,
+	req *http.Request,
+	need entity.SharePermission,
+) (*entity.Share, bool) {
+	uid, _ := middleware.GetUserID(req.Context())
+
+	share, err := h.Shares.GetShare(req.Context(), req.PathValue("ownerId"), req.PathValue("id"), uid)
+	if err != nil {
+		h.responseLookupError(resp, err)
+
+		return nil, false
+	}
+
+	if !share.Permission.Allows(need) {
+		h.ResponseError(resp, http.StatusForbidden, fmt.Errorf("%w: %s", ErrShareForbidden, share.Permission))
+
+		return nil, false
+	}
+
+	return share, true
+}
+
+// checkShareReq проверяет запрос передачи доступа к записи.
+func checkShareReq(shareReq *shareItemReq) error {
+	if shareReq.Permission != entity.ShareRead && shareReq.Permission != entity.ShareWrite {
+		return fmt.Errorf("%w: %q", ErrInvalidPermission, shareReq.Permission)
+	}
+
+	return checkShareKey(shareReq.WrappedKey)
+}
+
+// checkShareKey проверяет размер открытого ключа или зашифрованного ключа записи.
+func checkShareKey(key []byte) error {
+	if len(key) == 0 {
+		return ErrEmptyKey
+	}
+
+	if len(key) > ShareKeyMaxSize {
+		return fmt.Errorf("%w: %d > %d", ErrKeyTooLarge, len(key), ShareKeyMaxSize)
+	}
+
+	return nil
+}
+
+// newSharedItemResp формирует запись другого пользователя с параметрами доступа.
+func newSharedItemResp(share *entity.Share, item *entity.VaultItem) sharedItemResp {
+	return sharedItemResp{
+		Item:       item,
+		OwnerID:    share.OwnerID,
+		Permission: share.Permission,
+		WrappedKey: share.WrappedKey,
+	}
+}
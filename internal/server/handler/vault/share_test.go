@@ -0,0 +1,297 @@
+package vault_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/handler"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/handler/vault"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/middleware"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+	"github.com/mr-filatik/go-goph-keeper/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// shareFixture - обработчик с хранилищем в памяти, владельцем записи и получателем.
+type shareFixture struct {
+	handler   *vault.Handler
+	stor      *storage.MemoryStorage
+	owner     *entity.User
+	recipient *entity.User
+	itemID    string
+}
+
+// newShareFixture создаёт обработчик, пользователей owner@example.com и bob@example.com
+// с открытыми ключами и запись владельца.
+func newShareFixture(t *testing.T) *shareFixture {
+	t.Helper()
+
+	ctx := context.Background()
+	stor := storage.NewMemoryStorage()
+
+	owner := entity.NewUser("owner@example.com", "hash")
+	recipient := entity.NewUser("bob@example.com", "hash")
+
+	for _, user := range []*entity.User{owner, recipient} {
+		_, err := stor.AddNewUser(ctx, user)
+		require.NoError(t, err)
+
+		//nolint:exhaustruct // not all fields needed in test
+		require.NoError(t, stor.SetPublicKey(ctx, &entity.PublicKey{UserID: user.ID, Key: []byte("pub-" + user.ID)}))
+	}
+
+	//nolint:exhaustruct // not all fields needed in test
+	itemID, err := stor.CreateItem(ctx, &entity.VaultItem{OwnerID: owner.ID, Title: "Email", Data: "secret"})
+	require.NoError(t, err)
+
+	mainHandler := handler.NewHandler(stor, testutil.NewMockLogger())
+	vaultHandler := vault.NewHandler(*mainHandler, stor)
+	vaultHandler.Shares = stor
+
+	return &shareFixture{
+		handler:   vaultHandler,
+		stor:      stor,
+		owner:     owner,
+		recipient: recipient,
+		itemID:    itemID,
+	}
+}
+
+// shareRequest выполняет запрос от имени пользователя uid с параметрами пути pathValues.
+func shareRequest(
+	handle http.HandlerFunc,
+	uid, method, target string,
+	body any,
+	pathValues map[string]string,
+) *httptest.ResponseRecorder {
+	var req *http.Request
+	if body != nil {
+		buf, _ := json.Marshal(body)
+		req = httptest.NewRequest(method, target, bytes.NewReader(buf))
+	} else {
+		req = httptest.NewRequest(method, target, http.NoBody)
+	}
+
+	for key, value := range pathValues {
+		req.SetPathValue(key, value)
+	}
+
+	req = req.WithContext(middleware.WithUserID(req.Context(), uid))
+
+	rr := httptest.NewRecorder()
+	handle(rr, req)
+
+	return rr
+}
+
+// share выдаёт получателю доступ permission к записи владельца.
+func (f *shareFixture) share(permission entity.SharePermission) *httptest.ResponseRecorder {
+	return shareRequest(f.handler.ShareItem, f.owner.ID, http.MethodPut, "/vault/items/"+f.itemID+"/shares",
+		map[string]any{"email": f.recipient.Email, "permission": permission, "wrappedKey": []byte("wrapped")},
+		map[string]string{"id": f.itemID})
+}
+
+/*
+	===== Handler public keys =====
+*/
+
+func TestVault_PublicKey(t *testing.T) {
+	t.Parallel()
+
+	fix := newShareFixture(t)
+
+	rr := shareRequest(fix.handler.SetPublicKey, fix.owner.ID, http.MethodPut, "/vault/public-key",
+		map[string]any{"algorithm": "x25519", "key": []byte("new-key")}, nil)
+	require.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	rr = shareRequest(fix.handler.SetPublicKey, fix.owner.ID, http.MethodPut, "/vault/public-key",
+		map[string]any{"algorithm": "x25519"}, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "empty key")
+
+	rr = shareRequest(fix.handler.GetPublicKey, fix.recipient.ID, http.MethodGet,
+		"/vault/public-key?email=OWNER@example.com", nil, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var key entity.PublicKey
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &key))
+	assert.Equal(t, fix.owner.ID, key.UserID)
+	assert.Equal(t, "x25519", key.Algorithm)
+	assert.Equal(t, []byte("new-key"), key.Key)
+
+	rr = shareRequest(fix.handler.GetPublicKey, fix.owner.ID, http.MethodGet,
+		"/vault/public-key?email=nobody@example.com", nil, nil)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+/*
+	===== Handler.ShareItem =====
+*/
+
+func TestVault_ShareItem(t *testing.T) {
+	t.Parallel()
+
+	fix := newShareFixture(t)
+
+	rr := fix.share(entity.ShareRead)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var share entity.Share
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &share))
+	assert.Equal(t, fix.recipient.ID, share.RecipientID)
+	assert.Equal(t, entity.ShareRead, share.Permission)
+	assert.Equal(t, []byte("wrapped"), share.WrappedKey)
+
+	tests := []struct {
+		name string
+		uid  string
+		body map[string]any
+		id   string
+		code int
+	}{
+		{
+			name: "invalid permission",
+			uid:  fix.owner.ID,
+			body: map[string]any{"email": fix.recipient.Email, "permission": "admin", "wrappedKey": []byte("k")},
+			id:   fix.itemID,
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "empty wrapped key",
+			uid:  fix.owner.ID,
+			body: map[string]any{"email": fix.recipient.Email, "permission": "read"},
+			id:   fix.itemID,
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "share with self",
+			uid:  fix.owner.ID,
+			body: map[string]any{"email": fix.owner.Email, "permission": "read", "wrappedKey": []byte("k")},
+			id:   fix.itemID,
+			code: http.StatusBadRequest,
+		},
+		{
+			name: "unknown recipient",
+			uid:  fix.owner.ID,
+			body: map[string]any{"email": "nobody@example.com", "permission": "read", "wrappedKey": []byte("k")},
+			id:   fix.itemID,
+			code: http.StatusNotFound,
+		},
+		{
+			name: "foreign item",
+			uid:  fix.recipient.ID,
+			body: map[string]any{"email": fix.owner.Email, "permission": "read", "wrappedKey": []byte("k")},
+			id:   fix.itemID,
+			code: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rr := shareRequest(fix.handler.ShareItem, tt.uid, http.MethodPut, "/vault/items/"+tt.id+"/shares",
+				tt.body, map[string]string{"id": tt.id})
+			assert.Equal(t, tt.code, rr.Code, rr.Body.String())
+		})
+	}
+}
+
+func TestVault_ShareItem_NoPublicKey(t *testing.T) {
+	t.Parallel()
+
+	fix := newShareFixture(t)
+
+	carol := entity.NewUser("carol@example.com", "hash")
+	_, err := fix.stor.AddNewUser(context.Background(), carol)
+	require.NoError(t, err)
+
+	rr := shareRequest(fix.handler.ShareItem, fix.owner.ID, http.MethodPut, "/vault/items/"+fix.itemID+"/shares",
+		map[string]any{"email": carol.Email, "permission": "read", "wrappedKey": []byte("k")},
+		map[string]string{"id": fix.itemID})
+	assert.Equal(t, http.StatusConflict, rr.Code)
+}
+
+/*
+	===== Handler shared items =====
+*/
+
+func TestVault_SharedItems(t *testing.T) {
+	t.Parallel()
+
+	fix := newShareFixture(t)
+	path := map[string]string{"ownerId": fix.owner.ID, "id": fix.itemID}
+	update := map[string]any{"type": "password", "title": "Changed", "data": "new", "version": 1}
+
+	rr := shareRequest(fix.handler.GetSharedItem, fix.recipient.ID, http.MethodGet, "/vault/shared", nil, path)
+	assert.Equal(t, http.StatusNotFound, rr.Code, "no share yet")
+
+	require.Equal(t, http.StatusOK, fix.share(entity.ShareRead).Code)
+
+	rr = shareRequest(fix.handler.ListShared, fix.recipient.ID, http.MethodGet, "/vault/shared", nil, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var shared []struct {
+		Item       *entity.VaultItem      `json:"item"`
+		OwnerID    string                 `json:"ownerId"`
+		Permission entity.SharePermission `json:"permission"`
+		WrappedKey []byte                 `json:"wrappedKey"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &shared))
+	require.Len(t, shared, 1)
+	assert.Equal(t, "Email", shared[0].Item.Title)
+	assert.Equal(t, fix.owner.ID, shared[0].OwnerID)
+	assert.Equal(t, []byte("wrapped"), shared[0].WrappedKey)
+
+	rr = shareRequest(fix.handler.GetSharedItem, fix.recipient.ID, http.MethodGet, "/vault/shared", nil, path)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	rr = shareRequest(fix.handler.UpdateSharedItem, fix.recipient.ID, http.MethodPost, "/vault/shared", update, path)
+	assert.Equal(t, http.StatusForbidden, rr.Code, "read share does not allow writes")
+
+	require.Equal(t, http.StatusOK, fix.share(entity.ShareWrite).Code)
+
+	rr = shareRequest(fix.handler.UpdateSharedItem, fix.recipient.ID, http.MethodPost, "/vault/shared", update, path)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	item, err := fix.stor.GetItem(context.Background(), fix.owner.ID, fix.itemID)
+	require.NoError(t, err)
+	assert.Equal(t, "Changed", item.Title)
+
+	rr = shareRequest(fix.handler.UpdateSharedItem, fix.recipient.ID, http.MethodPost, "/vault/shared", update, path)
+	assert.Equal(t, http.StatusConflict, rr.Code, "stale version")
+
+	rr = shareRequest(fix.handler.ListItemShares, fix.owner.ID, http.MethodGet, "/vault/items/x/shares", nil,
+		map[string]string{"id": fix.itemID})
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"permission":"write"`)
+
+	rr = shareRequest(fix.handler.RevokeShare, fix.owner.ID, http.MethodDelete, "/vault/items/x/shares/y", nil,
+		map[string]string{"id": fix.itemID, "userId": fix.recipient.ID})
+	require.Equal(t, http.StatusNoContent, rr.Code)
+
+	rr = shareRequest(fix.handler.GetSharedItem, fix.recipient.ID, http.MethodGet, "/vault/shared", nil, path)
+	assert.Equal(t, http.StatusNotFound, rr.Code, "revoked share")
+
+	rr = shareRequest(fix.handler.RevokeShare, fix.owner.ID, http.MethodDelete, "/vault/items/x/shares/y", nil,
+		map[string]string{"id": fix.itemID, "userId": fix.recipient.ID})
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestVault_ListShared_SkipsDeletedItems(t *testing.T) {
+	t.Parallel()
+
+	fix := newShareFixture(t)
+
+	require.Equal(t, http.StatusOK, fix.share(entity.ShareRead).Code)
+	require.NoError(t, fix.stor.DeleteItem(context.Background(), fix.owner.ID, fix.itemID))
+
+	rr := shareRequest(fix.handler.ListShared, fix.recipient.ID, http.MethodGet, "/vault/shared", nil, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `[]`, rr.Body.String())
+}
@@ -0,0 +1,217 @@
+// Package vault предоставляет функционал для обработчиков запросов для работы с хранилищем.
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/handler"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/middleware"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+)
+
+// Ограничения меток записей.
+const (
+	TagMaxSize     = 64      // наибольший размер метки в байтах
+	ItemMaxTags    = 32      // наибольшее количество меток записи
+	TagsReqMaxSize = 4 << 10 // наибольший размер тела запроса меток
+)
+
+// Ошибки работы с метками.
+var (
+	ErrInvalidTag  = errors.New("invalid tag")
+	ErrTooManyTags = errors.New("too many tags")
+)
+
+// ListTags выводит метки записей владельца с количеством записей по каждой
+// в порядке названий меток.
+func (h *Handler) ListTags(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetOwnerID(req.Context())
+
+	tags, err := h.VStor.ListTags(req.Context(), uid)
+	if err != nil {
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	h.ResponceWithJSON(resp, tags)
+}
+
+// RenameTag переименовывает метку from в to во всех записях владельца.
+//
+// Если у записи уже есть метка to, метки объединяются. Для каждой изменённой записи
+// возвращается результат: applied (с новым состоянием записи), conflict (запись
+// изменилась во время переименования, с текущей записью сервера) или rejected.
+func (h *Handler) RenameTag(resp http.ResponseWriter, req *http.Request) {
+	var renameReq renameTagReq
+
+	limitBody(resp, req, TagsReqMaxSize)
+
+	if err := handler.GetDataFromBodyJSON(req, &renameReq); err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	h.retag(resp, req, []string{renameReq.From}, renameReq.To)
+}
+
+// MergeTags заменяет метки tags меткой into во всех записях владельца.
+//
+// Результаты для записей - как у RenameTag.
+func (h *Handler) MergeTags(resp http.ResponseWriter, req *http.Request) {
+	var mergeReq mergeTagsReq
+
+	limitBody(resp, req, TagsReqMaxSize)
+
+	if err := handler.GetDataFromBodyJSON(req, &mergeReq); err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	h.retag(resp, req, mergeReq.Tags, mergeReq.Into)
+}
+
+// retag заменяет метки from меткой into во всех записях владельца и формирует ответ.
+func (h *Handler) retag(resp http.ResponseWriter, req *http.Request, from []string, into string) {
+	uid, _ := middleware.GetOwnerID(req.Context())
+
+	from, target, err := parseRetag(from, into)
+	if err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	//nolint:exhaustruct // остальные условия выборки не нужны
+	items, err := h.VStor.QueryItems(req.Context(), uid, &entity.ItemQuery{Tags: from, TagMatch: entity.TagsAny})
+	if err != nil {
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	retagResp := itemResultsResp{Results: make([]changeResp, 0, len(items))}
+
+	for _, item := range items {
+		res, err := h.retagItem(req, item, from, target)
+		if err != nil {
+			h.ResponseError(resp, http.StatusInternalServerError, err)
+
+			return
+		}
+
+		retagResp.Results = append(retagResp.Results, *res)
+	}
+
+	h.Log.Info("Vault tags renamed", "from", from, "to", target, "items", len(items))
+
+	h.ResponceWithJSON(resp, retagResp)
+}
+
+// retagItem заменяет метки from меткой into в записи.
+// Если метки записи после замены нарушают ограничения, запись не изменяется.
+func (h *Handler) retagItem(
+	req *http.Request,
+	item *entity.VaultItem,
+	from []string,
+	into string,
+) (*changeResp, error) {
+	current := *item
+
+	next := make([]string, 0, len(item.Tags)+1)
+
+	for _, tag := range item.Tags {
+		if !slices.Contains(from, tag) {
+			next = append(next, tag)
+		}
+	}
+
+	tags, err := normalizeTags(append(next, into))
+	if err != nil {
+		return &changeResp{Item: nil, ID: item.ID, Status: entity.ChangeRejected, Error: err.Error()}, nil
+	}
+
+	item.Tags = tags
+
+	err = h.VStor.UpdateItem(req.Context(), item)
+
+	switch {
+	case err == nil:
+		return appliedChange(item), nil
+	case errors.Is(err, storage.ErrVersionConflict):
+		latest, getErr := h.VStor.GetItem(req.Context(), item.OwnerID, item.ID)
+		if getErr != nil {
+			latest = &current
+		}
+
+		return &changeResp{Item: latest, ID: item.ID, Status: entity.ChangeConflict, Error: err.Error()}, nil
+	case errors.Is(err, storage.ErrEntityNotFound), errors.Is(err, storage.ErrQuotaExceeded):
+		return &changeResp{Item: nil, ID: item.ID, Status: entity.ChangeRejected, Error: err.Error()}, nil
+	default:
+		return nil, fmt.Errorf("update item: %w", err)
+	}
+}
+
+// parseRetag проверяет метки запроса переименования или объединения.
+// Итоговая метка исключается из заменяемых.
+func parseRetag(from []string, into string) ([]string, string, error) {
+	target, err := normalizeTags([]string{into})
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(target) == 0 {
+		return nil, "", fmt.Errorf("%w: empty target tag", ErrInvalidTag)
+	}
+
+	from, err = normalizeTags(from)
+	if err != nil {
+		return nil, "", err
+	}
+
+	from = slices.DeleteFunc(from, func(tag string) bool { return tag == target[0] })
+	if len(from) == 0 {
+		return nil, "", fmt.Errorf("%w: no tags to replace", ErrInvalidTag)
+	}
+
+	return from, target[0], nil
+}
+
+// normalizeTags приводит метки к виду, в котором они хранятся: без пробелов по краям,
+// без пустых меток и повторов, по возрастанию. Пустой список заменяется на nil.
+func normalizeTags(tags []string) ([]string, error) {
+	res := make([]string, 0, len(tags))
+
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+
+		if len(tag) > TagMaxSize {
+			return nil, fmt.Errorf("%w: %d > %d bytes", ErrInvalidTag, len(tag), TagMaxSize)
+		}
+
+		res = append(res, tag)
+	}
+
+	slices.Sort(res)
+	res = slices.Compact(res)
+
+	if len(res) > ItemMaxTags {
+		return nil, fmt.Errorf("%w: %d > %d", ErrTooManyTags, len(res), ItemMaxTags)
+	}
+
+	if len(res) == 0 {
+		return nil, nil
+	}
+
+	return res, nil
+}
@@ -0,0 +1,247 @@
+package vault_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/handler"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/handler/vault"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+	"github.com/mr-filatik/go-goph-keeper/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const tagOwner = "tag-owner"
+
+// newTagHandler создаёт обработчик с хранилищем в памяти и записями владельца с метками.
+func newTagHandler(t *testing.T) (*vault.Handler, *storage.MemoryStorage) {
+	t.Helper()
+
+	stor := storage.NewMemoryStorage()
+
+	for title, tags := range map[string][]string{
+		"bank":  {"finance", "personal"},
+		"taxes": {"finance", "money"},
+		"wiki":  {"work"},
+	} {
+		//nolint:exhaustruct // not all fields needed in test
+		_, err := stor.CreateItem(context.Background(), &entity.VaultItem{OwnerID: tagOwner, Title: title, Tags: tags})
+		require.NoError(t, err)
+	}
+
+	mainHandler := handler.NewHandler(stor, testutil.NewMockLogger())
+
+	return vault.NewHandler(*mainHandler, stor), stor
+}
+
+// tagsOf возвращает метки записей владельца по названиям записей.
+func tagsOf(t *testing.T, stor *storage.MemoryStorage) map[string][]string {
+	t.Helper()
+
+	items, err := stor.ListItems(context.Background(), tagOwner)
+	require.NoError(t, err)
+
+	res := make(map[string][]string, len(items))
+	for _, item := range items {
+		res[item.Title] = item.Tags
+	}
+
+	return res
+}
+
+/*
+	===== Handler tags =====
+*/
+
+func TestVault_UpsertItem_Tags(t *testing.T) {
+	t.Parallel()
+
+	vaultHandler, stor := newTagHandler(t)
+
+	rr := shareRequest(vaultHandler.UpsertItem, tagOwner, http.MethodPost, "/vault/items",
+		map[string]any{"type": entity.ItemText, "title": "notes", "tags": []string{" work", "", "work", "ideas"}}, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, []string{"ideas", "work"}, tagsOf(t, stor)["notes"], "tags are trimmed, deduplicated and sorted")
+
+	longTag := strings.Repeat("x", vault.TagMaxSize+1)
+
+	rr = shareRequest(vaultHandler.UpsertItem, tagOwner, http.MethodPost, "/vault/items",
+		map[string]any{"type": entity.ItemText, "title": "long", "tags": []string{longTag}}, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "tag too long")
+
+	tooMany := make([]string, 0, vault.ItemMaxTags+1)
+	for i := range vault.ItemMaxTags + 1 {
+		tooMany = append(tooMany, strings.Repeat("t", i+1))
+	}
+
+	rr = shareRequest(vaultHandler.UpsertItem, tagOwner, http.MethodPost, "/vault/items",
+		map[string]any{"type": entity.ItemText, "title": "many", "tags": tooMany}, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "too many tags")
+}
+
+func TestVault_SyncPush_InvalidTags(t *testing.T) {
+	t.Parallel()
+
+	stor := storage.NewMemoryStorage()
+	mainHandler := handler.NewHandler(stor, testutil.NewMockLogger())
+	vaultHandler := vault.NewHandler(*mainHandler, stor)
+
+	longTag := strings.Repeat("x", vault.TagMaxSize+1)
+
+	body := `{"changes":[
+		{"op":"upsert","id":"a","type":"text","title":"first","tags":["work"]},
+		{"op":"upsert","id":"b","type":"text","title":"long","tags":["` + longTag + `"]},
+		{"op":"upsert","id":"c","type":"text","title":"second"}
+	]}`
+
+	code, page := doSyncPush(t, vaultHandler, body)
+	require.Equal(t, http.StatusOK, code, "invalid change does not fail the batch")
+	require.Len(t, page.Results, 3)
+	assert.Equal(t, "applied", page.Results[0]["status"])
+	assert.Equal(t, "rejected", page.Results[1]["status"])
+	assert.Contains(t, page.Results[1]["error"], vault.ErrInvalidTag.Error())
+	assert.Equal(t, "applied", page.Results[2]["status"])
+
+	items, err := stor.ListItems(context.Background(), "user-1")
+	require.NoError(t, err)
+
+	titles := make([]string, 0, len(items))
+	for _, item := range items {
+		titles = append(titles, item.Title)
+	}
+
+	assert.ElementsMatch(t, []string{"first", "second"}, titles)
+}
+
+func TestVault_ListItems_Tags(t *testing.T) {
+	t.Parallel()
+
+	vaultHandler, _ := newTagHandler(t)
+
+	titles := func(query string) []string {
+		rr := shareRequest(vaultHandler.ListItems, tagOwner, http.MethodGet, "/vault/items"+query, nil, nil)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+		var items []entity.VaultItem
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &items))
+
+		res := make([]string, 0, len(items))
+		for _, item := range items {
+			res = append(res, item.Title)
+		}
+
+		return res
+	}
+
+	assert.Equal(t, []string{"bank", "taxes", "wiki"}, titles("?tag=finance&tag=work"))
+	assert.Equal(t, []string{"bank"}, titles("?tag=finance&tag=personal&tagMatch=all"))
+
+	rr := shareRequest(vaultHandler.ListItems, tagOwner, http.MethodGet, "/vault/items?tagMatch=some", nil, nil)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestVault_ListTags(t *testing.T) {
+	t.Parallel()
+
+	vaultHandler, _ := newTagHandler(t)
+
+	rr := shareRequest(vaultHandler.ListTags, tagOwner, http.MethodGet, "/vault/tags", nil, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var tags []entity.TagCount
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &tags))
+	assert.Equal(t, []entity.TagCount{
+		{Name: "finance", Count: 2},
+		{Name: "money", Count: 1},
+		{Name: "personal", Count: 1},
+		{Name: "work", Count: 1},
+	}, tags)
+}
+
+func TestVault_RenameTag(t *testing.T) {
+	t.Parallel()
+
+	vaultHandler, stor := newTagHandler(t)
+
+	rr := shareRequest(vaultHandler.RenameTag, tagOwner, http.MethodPost, "/vault/tags/rename",
+		map[string]any{"from": "money", "to": "finance"}, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var renamed pushPage
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &renamed))
+	require.Len(t, renamed.Results, 1)
+	assert.Equal(t, string(entity.ChangeApplied), renamed.Results[0]["status"])
+	assert.InDelta(t, 2, renamed.Results[0]["item"].(map[string]any)["version"], 0, "new version of the retagged item")
+
+	assert.Equal(t, []string{"finance"}, tagsOf(t, stor)["taxes"], "renamed into an existing tag")
+
+	rr = shareRequest(vaultHandler.RenameTag, tagOwner, http.MethodPost, "/vault/tags/rename",
+		map[string]any{"from": "work", "to": "job"}, nil)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, []string{"job"}, tagsOf(t, stor)["wiki"])
+
+	for _, body := range []map[string]any{
+		{"from": "work", "to": " "},
+		{"from": "job", "to": "job"},
+		{"from": "", "to": "job"},
+	} {
+		rr = shareRequest(vaultHandler.RenameTag, tagOwner, http.MethodPost, "/vault/tags/rename", body, nil)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, body)
+	}
+}
+
+func TestVault_MergeTags(t *testing.T) {
+	t.Parallel()
+
+	vaultHandler, stor := newTagHandler(t)
+
+	rr := shareRequest(vaultHandler.MergeTags, tagOwner, http.MethodPost, "/vault/tags/merge",
+		map[string]any{"tags": []string{"personal", "money", "work"}, "into": "misc"}, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var merged pushPage
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &merged))
+	assert.Len(t, merged.Results, 3)
+
+	assert.Equal(t, map[string][]string{
+		"bank":  {"finance", "misc"},
+		"taxes": {"finance", "misc"},
+		"wiki":  {"misc"},
+	}, tagsOf(t, stor))
+
+	changes, err := stor.ListChanges(context.Background(), tagOwner, 3, 0)
+	require.NoError(t, err)
+	assert.Len(t, changes, 3, "retagged items are synced to other devices")
+}
+
+func TestVault_MergeTags_InvalidResult(t *testing.T) {
+	t.Parallel()
+
+	vaultHandler, stor := newTagHandler(t)
+
+	// Записи, сохранённые до ограничений на метки, могут их нарушать.
+	tooMany := make([]string, 0, vault.ItemMaxTags+1)
+	for i := range vault.ItemMaxTags + 1 {
+		tooMany = append(tooMany, "legacy-"+strings.Repeat("t", i+1))
+	}
+
+	//nolint:exhaustruct // not all fields needed in test
+	_, err := stor.CreateItem(context.Background(), &entity.VaultItem{OwnerID: tagOwner, Title: "old", Tags: tooMany})
+	require.NoError(t, err)
+
+	rr := shareRequest(vaultHandler.RenameTag, tagOwner, http.MethodPost, "/vault/tags/rename",
+		map[string]any{"from": tooMany[0], "to": "renamed"}, nil)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var renamed pushPage
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &renamed))
+	require.Len(t, renamed.Results, 1)
+	assert.Equal(t, "rejected", renamed.Results[0]["status"])
+	assert.Contains(t, renamed.Results[0]["error"], vault.ErrTooManyTags.Error())
+	assert.Equal(t, tooMany, tagsOf(t, stor)["old"], "item with invalid tags is left unchanged")
+}
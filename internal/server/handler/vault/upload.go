@@ -0,0 +1,257 @@
+// Package vault предоставляет функционал для обработчиков запросов для работы с хранилищем.
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/blob"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/handler"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/middleware"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/upload"
+)
+
+// Заголовки протокола возобновляемой загрузки содержимого.
+const (
+	// HeaderUploadOffset - количество принятых байт (в запросе - смещение части).
+	HeaderUploadOffset = "Upload-Offset"
+
+	// HeaderUploadLength - объявленный размер содержимого.
+	HeaderUploadLength = "Upload-Length"
+
+	// HeaderUploadExpires - время удаления сессии без новых записей (RFC 1123).
+	HeaderUploadExpires = "Upload-Expires"
+)
+
+// ErrInvalidUpload указывает на некорректные параметры загрузки.
+var ErrInvalidUpload = errors.New("invalid upload")
+
+// CreateUpload создаёт сессию возобновляемой загрузки содержимого бинарной записи.
+//
+// Тело запроса {"size": n, "hash": "..."}: размер содержимого и, при необходимости,
+// его ожидаемый SHA-256 хеш. В ответе 201 - сессия, заголовок Location указывает
+// адрес для передачи частей с тем же префиксом пути, что и запрос (/vault или
+// путь записей коллекции организации).
+func (h *Handler) CreateUpload(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetOwnerID(req.Context())
+
+	id := req.PathValue("id")
+
+	var createReq createUploadReq
+	if err := handler.GetDataFromBodyJSON(req, &createReq); err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	hash := strings.ToLower(createReq.Hash)
+
+	switch {
+	case createReq.Size < 0:
+		h.ResponseError(resp, http.StatusBadRequest,
+			fmt.Errorf("%w: size %d", ErrInvalidUpload, createReq.Size))
+
+		return
+	case hash != "" && !blob.ValidHash(hash):
+		h.ResponseError(resp, http.StatusBadRequest, fmt.Errorf("%w: %q", blob.ErrInvalidHash, hash))
+
+		return
+	case h.BlobMaxSize > 0 && createReq.Size > h.BlobMaxSize:
+		h.ResponseError(resp, http.StatusRequestEntityTooLarge,
+			fmt.Errorf("%w: %d > %d", ErrContentTooLarge, createReq.Size, h.BlobMaxSize))
+
+		return
+	}
+
+	item, err := h.VStor.GetItem(req.Context(), uid, id)
+	if err != nil {
+		h.responseLookupError(resp, err)
+
+		return
+	}
+
+	if item.Type != entity.ItemBinary {
+		h.ResponseError(resp, http.StatusBadRequest, fmt.Errorf("%w: %s", ErrNotBinaryItem, item.Type))
+
+		return
+	}
+
+	session, err := h.Uploads.Create(uid, id, createReq.Size, hash)
+	if err != nil {
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	h.Log.Info("Vault upload created", "id", id, "upload", session.ID, "size", session.Size)
+
+	setUploadHeaders(resp, session)
+	resp.Header().Set("Location", uploadLocation(req, session.ID))
+	h.ResponceWithJSONStatus(resp, http.StatusCreated, session)
+}
+
+// UploadStatus сообщает в заголовках, сколько байт содержимого уже принято.
+func (h *Handler) UploadStatus(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetOwnerID(req.Context())
+
+	session, err := h.Uploads.Get(uid, req.PathValue("id"))
+	if err != nil {
+		h.responseUploadError(resp, err)
+
+		return
+	}
+
+	setUploadHeaders(resp, session)
+	resp.WriteHeader(http.StatusOK)
+}
+
+// AppendUpload дописывает часть содержимого из тела запроса.
+//
+// Заголовок Upload-Offset должен совпадать с количеством уже принятых байт,
+// иначе возвращается 409 с текущим смещением. При обрыве передачи принятые
+// байты сохраняются, и загрузку можно продолжить с нового смещения.
+func (h *Handler) AppendUpload(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetOwnerID(req.Context())
+
+	offset, err := strconv.ParseInt(req.Header.Get(HeaderUploadOffset), 10, 64)
+	if err != nil || offset < 0 {
+		h.ResponseError(resp, http.StatusBadRequest,
+			fmt.Errorf("%w: offset %q", ErrInvalidUpload, req.Header.Get(HeaderUploadOffset)))
+
+		return
+	}
+
+	extendDeadlines(resp)
+
+	session, err := h.Uploads.Append(req.Context(), uid, req.PathValue("id"), offset, req.Body)
+	if session != nil {
+		setUploadHeaders(resp, session)
+	}
+
+	if err != nil {
+		h.responseUploadError(resp, err)
+
+		return
+	}
+
+	resp.WriteHeader(http.StatusNoContent)
+}
+
+// CompleteUpload завершает загрузку: проверяет принятое содержимое, сохраняет его
+// и заменяет им содержимое записи. Параметр запроса version - текущая версия
+// записи у клиента (пусто - без проверки). В ответе - новая версия записи.
+//
+// Если запись не удалось обновить (например, из-за конфликта версий), сессия
+// сохраняется, и завершение можно повторить без повторной передачи содержимого.
+func (h *Handler) CompleteUpload(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetOwnerID(req.Context())
+
+	version, err := parseOptionalVersion(req.URL.Query().Get("version"))
+	if err != nil {
+		h.ResponseError(resp, http.StatusBadRequest, err)
+
+		return
+	}
+
+	extendDeadlines(resp)
+
+	var (
+		updated *entity.VaultItem
+		blobErr error
+		storErr error
+	)
+
+	session, err := h.Uploads.Finish(uid, req.PathValue("id"),
+		func(session *upload.Session, src io.Reader) error {
+			info, err := h.Blobs.Put(req.Context(), src, h.BlobMaxSize, session.Hash)
+			if err != nil {
+				blobErr = err
+
+				return err
+			}
+
+			content := &entity.Content{
+				Hash: info.Hash,
+				Size: info.Size,
+			}
+
+			updated, storErr = h.VStor.SetItemContent(req.Context(), uid, session.ItemID, version, content)
+
+			return storErr
+		})
+
+	switch {
+	case blobErr != nil:
+		h.responseBlobError(resp, blobErr)
+	case storErr != nil:
+		h.responseContentError(resp, req, uid, session.ItemID, version, storErr)
+	case err != nil:
+		if session != nil {
+			setUploadHeaders(resp, session)
+		}
+
+		h.responseUploadError(resp, err)
+	default:
+		h.Log.Info("Vault upload completed", "id", session.ItemID, "upload", session.ID, "size", session.Size)
+
+		h.ResponceWithJSON(resp, updated)
+	}
+}
+
+// CancelUpload удаляет сессию загрузки вместе с принятым содержимым.
+func (h *Handler) CancelUpload(resp http.ResponseWriter, req *http.Request) {
+	uid, _ := middleware.GetOwnerID(req.Context())
+
+	if err := h.Uploads.Remove(uid, req.PathValue("id")); err != nil {
+		h.responseUploadError(resp, err)
+
+		return
+	}
+
+	resp.WriteHeader(http.StatusNoContent)
+}
+
+// responseUploadError формирует ответ при ошибке работы с сессией загрузки.
+func (h *Handler) responseUploadError(resp http.ResponseWriter, err error) {
+	var maxErr *http.MaxBytesError
+
+	switch {
+	case errors.Is(err, upload.ErrNotFound):
+		h.ResponseError(resp, http.StatusNotFound, err)
+	case errors.Is(err, upload.ErrOffsetMismatch),
+		errors.Is(err, upload.ErrIncomplete),
+		errors.Is(err, upload.ErrBusy):
+		h.ResponseError(resp, http.StatusConflict, err)
+	case errors.Is(err, upload.ErrTooLarge), errors.As(err, &maxErr):
+		h.ResponseError(resp, http.StatusRequestEntityTooLarge, err)
+	default:
+		h.ResponseError(resp, http.StatusInternalServerError, err)
+	}
+}
+
+// setUploadHeaders передаёт состояние сессии загрузки в заголовках ответа.
+func setUploadHeaders(resp http.ResponseWriter, session *upload.Session) {
+	resp.Header().Set("Cache-Control", "no-store")
+	resp.Header().Set(HeaderUploadOffset, strconv.FormatInt(session.Offset, 10))
+	resp.Header().Set(HeaderUploadLength, strconv.FormatInt(session.Size, 10))
+
+	if !session.ExpiresAt.IsZero() {
+		resp.Header().Set(HeaderUploadExpires, session.ExpiresAt.Format(http.TimeFormat))
+	}
+}
+
+// uploadLocation возвращает адрес сессии загрузки uploadID: префикс пути запроса
+// создания сессии (часть до /items/{id}/uploads) с добавлением /uploads/{uploadID}.
+func uploadLocation(req *http.Request, uploadID string) string {
+	prefix := req.URL.Path
+	if i := strings.LastIndex(prefix, "/items/"); i >= 0 {
+		prefix = prefix[:i]
+	}
+
+	return prefix + "/uploads/" + uploadID
+}
@@ -0,0 +1,234 @@
+package vault_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/handler/vault"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/upload"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newUploadHandler создаёт обработчик с сессиями загрузки во временном каталоге.
+func newUploadHandler(t *testing.T, itemType entity.ItemType) (*vault.Handler, *[]*entity.Content) {
+	t.Helper()
+
+	vaultHandler, _, stored := newContentHandler(t, itemType, nil)
+
+	uploads, err := upload.NewStore(t.TempDir(), time.Hour)
+	require.NoError(t, err)
+
+	vaultHandler.Uploads = uploads
+
+	return vaultHandler, stored
+}
+
+// uploadRequest выполняет запрос к сессии загрузки.
+func uploadRequest(
+	vaultHandler *vault.Handler,
+	handle func(*vault.Handler, http.ResponseWriter, *http.Request),
+	method, uploadID, target, body string,
+	offset int64,
+) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if offset >= 0 {
+		req.Header.Set(vault.HeaderUploadOffset, strconv.FormatInt(offset, 10))
+	}
+
+	req.SetPathValue("id", uploadID)
+	req = withUser(req)
+
+	rr := httptest.NewRecorder()
+	handle(vaultHandler, rr, req)
+
+	return rr
+}
+
+// createUpload создаёт сессию загрузки содержимого записи "1".
+func createUpload(t *testing.T, vaultHandler *vault.Handler, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/vault/items/1/uploads", strings.NewReader(body))
+	req.SetPathValue("id", "1")
+	req = withUser(req)
+
+	rr := httptest.NewRecorder()
+	vaultHandler.CreateUpload(rr, req)
+
+	return rr
+}
+
+// decodeSession разбирает сессию загрузки из ответа.
+func decodeSession(t *testing.T, rr *httptest.ResponseRecorder) upload.Session {
+	t.Helper()
+
+	var session upload.Session
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &session))
+
+	return session
+}
+
+/*
+	===== Handler.CreateUpload =====
+*/
+
+func TestVault_Upload_Resume(t *testing.T) {
+	t.Parallel()
+
+	vaultHandler, stored := newUploadHandler(t, entity.ItemBinary)
+
+	data := "0123456789"
+
+	rr := createUpload(t, vaultHandler, `{"size":10,"hash":"`+strings.ToUpper(contentHash(data))+`"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	session := decodeSession(t, rr)
+	assert.Equal(t, "/vault/uploads/"+session.ID, rr.Header().Get("Location"))
+	assert.Equal(t, "0", rr.Header().Get(vault.HeaderUploadOffset))
+	assert.Equal(t, "10", rr.Header().Get(vault.HeaderUploadLength))
+	assert.NotEmpty(t, rr.Header().Get(vault.HeaderUploadExpires))
+
+	rr = uploadRequest(vaultHandler, (*vault.Handler).AppendUpload,
+		http.MethodPatch, session.ID, "/vault/uploads/"+session.ID, data[:4], 0)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Equal(t, "4", rr.Header().Get(vault.HeaderUploadOffset))
+
+	// Повтор части, уже принятой до обрыва ответа.
+	rr = uploadRequest(vaultHandler, (*vault.Handler).AppendUpload,
+		http.MethodPatch, session.ID, "/vault/uploads/"+session.ID, data[:4], 0)
+	require.Equal(t, http.StatusConflict, rr.Code)
+	assert.Equal(t, "4", rr.Header().Get(vault.HeaderUploadOffset))
+
+	rr = uploadRequest(vaultHandler, (*vault.Handler).UploadStatus,
+		http.MethodHead, session.ID, "/vault/uploads/"+session.ID, "", -1)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "4", rr.Header().Get(vault.HeaderUploadOffset))
+
+	rr = uploadRequest(vaultHandler, (*vault.Handler).CompleteUpload,
+		http.MethodPost, session.ID, "/vault/uploads/"+session.ID+"/complete", "", -1)
+	require.Equal(t, http.StatusConflict, rr.Code, "incomplete upload")
+
+	rr = uploadRequest(vaultHandler, (*vault.Handler).AppendUpload,
+		http.MethodPatch, session.ID, "/vault/uploads/"+session.ID, data[4:], 4)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+	assert.Equal(t, "10", rr.Header().Get(vault.HeaderUploadOffset))
+
+	rr = uploadRequest(vaultHandler, (*vault.Handler).CompleteUpload,
+		http.MethodPost, session.ID, "/vault/uploads/"+session.ID+"/complete?version=1", "", -1)
+	require.Equal(t, http.StatusConflict, rr.Code, "version conflict")
+	assert.Empty(t, *stored)
+
+	// Сессия сохраняется после конфликта, и завершение можно повторить.
+	rr = uploadRequest(vaultHandler, (*vault.Handler).CompleteUpload,
+		http.MethodPost, session.ID, "/vault/uploads/"+session.ID+"/complete?version=2", "", -1)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	require.Len(t, *stored, 1)
+	assert.Equal(t, &entity.Content{Hash: contentHash(data), Size: int64(len(data))}, (*stored)[0])
+
+	rr = uploadRequest(vaultHandler, (*vault.Handler).UploadStatus,
+		http.MethodHead, session.ID, "/vault/uploads/"+session.ID, "", -1)
+	assert.Equal(t, http.StatusNotFound, rr.Code, "completed upload is removed")
+}
+
+func TestVault_CreateUpload_CollectionLocation(t *testing.T) {
+	t.Parallel()
+
+	vaultHandler, _ := newUploadHandler(t, entity.ItemBinary)
+
+	prefix := "/orgs/org-1/collections/coll-1/vault"
+
+	req := httptest.NewRequest(http.MethodPost, prefix+"/items/1/uploads", strings.NewReader(`{"size":10}`))
+	req.SetPathValue("id", "1")
+	req = withUser(req)
+
+	rr := httptest.NewRecorder()
+	vaultHandler.CreateUpload(rr, req)
+
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+
+	session := decodeSession(t, rr)
+	assert.Equal(t, prefix+"/uploads/"+session.ID, rr.Header().Get("Location"))
+}
+
+func TestVault_CreateUpload_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		itemType entity.ItemType
+		body     string
+		code     int
+	}{
+		{name: "bad json", itemType: entity.ItemBinary, body: `{`, code: http.StatusBadRequest},
+		{name: "negative size", itemType: entity.ItemBinary, body: `{"size":-1}`, code: http.StatusBadRequest},
+		{name: "bad hash", itemType: entity.ItemBinary, body: `{"size":1,"hash":"xyz"}`, code: http.StatusBadRequest},
+		{name: "too large", itemType: entity.ItemBinary, body: `{"size":2048}`, code: http.StatusRequestEntityTooLarge},
+		{name: "not binary", itemType: entity.ItemText, body: `{"size":1}`, code: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			vaultHandler, _ := newUploadHandler(t, tt.itemType)
+
+			rr := createUpload(t, vaultHandler, tt.body)
+			assert.Equal(t, tt.code, rr.Code)
+		})
+	}
+}
+
+/*
+	===== Handler.AppendUpload =====
+*/
+
+func TestVault_AppendUpload_Errors(t *testing.T) {
+	t.Parallel()
+
+	vaultHandler, _ := newUploadHandler(t, entity.ItemBinary)
+
+	rr := createUpload(t, vaultHandler, `{"size":4}`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	session := decodeSession(t, rr)
+	target := "/vault/uploads/" + session.ID
+
+	rr = uploadRequest(vaultHandler, (*vault.Handler).AppendUpload, http.MethodPatch, session.ID, target, "x", -1)
+	assert.Equal(t, http.StatusBadRequest, rr.Code, "no offset")
+
+	rr = uploadRequest(vaultHandler, (*vault.Handler).AppendUpload, http.MethodPatch, session.ID, target, "12345", 0)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code, "beyond declared size")
+	assert.Equal(t, "0", rr.Header().Get(vault.HeaderUploadOffset))
+
+	rr = uploadRequest(vaultHandler, (*vault.Handler).AppendUpload, http.MethodPatch, "404", "/vault/uploads/404", "x", 0)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+/*
+	===== Handler.CancelUpload =====
+*/
+
+func TestVault_CancelUpload(t *testing.T) {
+	t.Parallel()
+
+	vaultHandler, _ := newUploadHandler(t, entity.ItemBinary)
+
+	rr := createUpload(t, vaultHandler, `{"size":4}`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	session := decodeSession(t, rr)
+	target := "/vault/uploads/" + session.ID
+
+	rr = uploadRequest(vaultHandler, (*vault.Handler).CancelUpload, http.MethodDelete, session.ID, target, "", -1)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+
+	rr = uploadRequest(vaultHandler, (*vault.Handler).CancelUpload, http.MethodDelete, session.ID, target, "", -1)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
@@ -8,17 +8,24 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/mr-filatik/go-goph-keeper/internal/common/logger"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/blob"
 	"github.com/mr-filatik/go-goph-keeper/internal/server/crypto/jwt"
 	"github.com/mr-filatik/go-goph-keeper/internal/server/handler"
 	"github.com/mr-filatik/go-goph-keeper/internal/server/handler/auth"
 	"github.com/mr-filatik/go-goph-keeper/internal/server/handler/client"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/handler/org"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/handler/sys"
 	"github.com/mr-filatik/go-goph-keeper/internal/server/handler/vault"
 	"github.com/mr-filatik/go-goph-keeper/internal/server/middleware"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/seal"
 	"github.com/mr-filatik/go-goph-keeper/internal/server/storage"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/upload"
 )
 
 const (
@@ -35,13 +42,54 @@ type HTTPServer struct {
 	log       logger.Logger // логгер
 	stor      storage.IUserStorage
 	vStor     storage.IStorage
-	address   string // адрес сервера
+	address   string       // адрес сервера
+	quota     entity.Quota // квота пользователя
+
+	blobs       blob.IBlobStore // хранилище содержимого бинарных записей
+	blobMaxSize int64           // наибольший размер содержимого бинарной записи
+	uploads     *upload.Store   // сессии возобновляемой загрузки содержимого
+
+	shares  storage.IShareStorage  // доступы к записям других пользователей (nil - отключены)
+	orgs    storage.IOrgStorage    // организации и их коллекции (nil - отключены)
+	folders storage.IFolderStorage // папки записей (nil - отключены)
+
+	barrier *seal.Barrier           // доли мастер-ключа запечатанного сервера (nil - не запечатан)
+	app     atomic.Pointer[chi.Mux] // обработчики приложения распечатанного сервера
+
+	backups          storage.IBackupStorage // хранилище для резервного копирования (nil - отключено)
+	adminToken       string                 // токен административных запросов
+	backupPassphrase string                 // пароль шифрования резервных копий
 }
 
 // HTTPServerConfig - конфиг для создания HTTPServer.
 type HTTPServerConfig struct {
 	Address   string
 	Encryptor *jwt.Encryptor
+	Quota     entity.Quota
+
+	Blobs       blob.IBlobStore
+	BlobMaxSize int64
+	Uploads     *upload.Store
+
+	// Shares включает передачу доступа к записям другим пользователям.
+	Shares storage.IShareStorage
+
+	// Orgs включает организации с общими коллекциями записей.
+	Orgs storage.IOrgStorage
+
+	// Folders включает папки записей владельца и коллекций.
+	// В запечатанном режиме используется хранилище папок, переданное в Unseal.
+	Folders storage.IFolderStorage
+
+	// Barrier включает запечатанный режим: до вызова Unseal сервер обслуживает
+	// только запросы /sys/*, а на остальные отвечает 503.
+	Barrier *seal.Barrier
+
+	// Backups включает выдачу резервной копии по GET /sys/backup запросам
+	// с токеном AdminToken. Без хранилища или токена выдача отключена.
+	Backups          storage.IBackupStorage
+	AdminToken       string
+	BackupPassphrase string
 }
 
 // NewHTTPServer создаёт и инициализирует новый экзепляр *HTTPServer.
@@ -64,6 +112,22 @@ func NewHTTPServer(
 		stor:      stor,
 		vStor:     vStor,
 		log:       log,
+		quota:     conf.Quota,
+
+		blobs:       conf.Blobs,
+		blobMaxSize: conf.BlobMaxSize,
+		uploads:     conf.Uploads,
+
+		shares:  conf.Shares,
+		orgs:    conf.Orgs,
+		folders: conf.Folders,
+
+		barrier: conf.Barrier,
+		app:     atomic.Pointer[chi.Mux]{},
+
+		backups:          conf.Backups,
+		adminToken:       conf.AdminToken,
+		backupPassphrase: conf.BackupPassphrase,
 	}
 
 	log.Info("HTTPServer create is successful")
@@ -140,11 +204,76 @@ func (s *HTTPServer) Close() error {
 	return nil
 }
 
+// Unseal подключает обработчики приложения запечатанного сервера,
+// когда получены ключи и хранилище. Хранилище папок folders заменяет
+// HTTPServerConfig.Folders: его шифрование тоже зависит от мастер-ключа.
+func (s *HTTPServer) Unseal(
+	encryptor *jwt.Encryptor,
+	stor storage.IUserStorage,
+	vStor storage.IStorage,
+	folders storage.IFolderStorage,
+) {
+	s.app.Store(s.appRoutes(encryptor, stor, vStor, folders))
+}
+
 func (s *HTTPServer) registerRoutes() {
+	if s.barrier == nil {
+		routers := s.appRoutes(s.encryptor, s.stor, s.vStor, s.folders)
+		s.sysRoutes(routers)
+		s.server.Handler = routers
+
+		return
+	}
+
+	routers := chi.NewRouter()
+	s.sysRoutes(routers)
+	routers.Handle("/*", http.HandlerFunc(s.serveApp))
+
+	s.server.Handler = routers
+}
+
+// sysRoutes регистрирует служебные обработчики /sys/*.
+//
+// Резервная копия доступна и в запечатанном режиме: данные копируются
+// в том виде, в котором лежат в хранилище, и мастер-ключ для этого не нужен.
+func (s *HTTPServer) sysRoutes(routers *chi.Mux) {
+	sysHandler := sys.NewHandler(*handler.NewHandler(nil, s.log), s.barrier)
+	sysHandler.Backups = s.backups
+	sysHandler.BackupPassphrase = s.backupPassphrase
+
+	if s.barrier != nil {
+		routers.Get("/sys/seal-status", sysHandler.SealStatus)
+		routers.Post("/sys/unseal", sysHandler.Unseal)
+	}
+
+	if s.backups != nil && s.adminToken != "" {
+		routers.Get("/sys/backup", middleware.RequireAdminToken(s.adminToken, sysHandler.Backup))
+	}
+}
+
+// serveApp передаёт запрос обработчикам приложения или отвечает 503, пока сервер запечатан.
+func (s *HTTPServer) serveApp(resp http.ResponseWriter, req *http.Request) {
+	app := s.app.Load()
+	if app == nil {
+		http.Error(resp, "server is sealed", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	app.ServeHTTP(resp, req)
+}
+
+// appRoutes создаёт обработчики приложения.
+func (s *HTTPServer) appRoutes(
+	encryptor *jwt.Encryptor,
+	stor storage.IUserStorage,
+	vStor storage.IStorage,
+	folders storage.IFolderStorage,
+) *chi.Mux {
 	routers := chi.NewRouter()
-	mainHandler := handler.NewHandler(s.stor, s.log)
+	mainHandler := handler.NewHandler(stor, s.log)
 
-	authHandler := auth.NewHandler(*mainHandler, s.encryptor)
+	authHandler := auth.NewHandler(*mainHandler, encryptor)
 	routers.HandleFunc("/auth/register", authHandler.UserRegister)
 	routers.HandleFunc("/auth/login", authHandler.UserLogin)
 	routers.HandleFunc("/auth/logout", authHandler.UserLogout)
@@ -153,15 +282,139 @@ func (s *HTTPServer) registerRoutes() {
 	routers.HandleFunc("/client", clientHandler.ClientInfo)
 	routers.HandleFunc("/client/{os}", clientHandler.ClientDownload)
 
-	vaultHandler := vault.NewHandler(*mainHandler, s.vStor)
-	routers.Get("/vault/items", middleware.RequireAuth(s.encryptor, vaultHandler.ListItems))
-	routers.Get("/vault/items/{id}", middleware.RequireAuth(s.encryptor, vaultHandler.GetItem))
-	routers.Post("/vault/items", middleware.RequireAuth(s.encryptor, vaultHandler.UpsertItem))
+	vaultHandler := vault.NewHandler(*mainHandler, vStor)
+	vaultHandler.Quota = s.quota
+	vaultHandler.Blobs = s.blobs
+	vaultHandler.BlobMaxSize = s.blobMaxSize
+	vaultHandler.Uploads = s.uploads
+	vaultHandler.Shares = s.shares
+	vaultHandler.Folders = folders
+
+	requireAuth := func(next http.HandlerFunc) http.HandlerFunc {
+		return middleware.RequireAuth(encryptor, next)
+	}
+
+	vaultRoutes(routers, "/vault", requireAuth, vaultHandler)
+
+	if s.shares != nil {
+		shareRoutes(routers, encryptor, vaultHandler)
+	}
+
+	if s.orgs != nil {
+		orgHandler := org.NewHandler(*mainHandler, s.orgs, vStor)
+		orgRoutes(routers, encryptor, orgHandler)
+		vaultRoutes(routers, "/orgs/{orgId}/collections/{collectionId}/vault",
+			func(next http.HandlerFunc) http.HandlerFunc {
+				return middleware.RequireAuth(encryptor, orgHandler.RequireCollection(next))
+			},
+			vaultHandler,
+		)
+	}
+
+	return routers
+}
+
+// vaultRoutes регистрирует обработчики записей с префиксом пути prefix,
+// оборачивая каждый обработчик в wrap.
+//
+// Владельца записей задаёт wrap: по умолчанию это сам пользователь,
+// для коллекций организации - коллекция (см. middleware.GetOwnerID).
+func vaultRoutes(
+	routers chi.Router,
+	prefix string,
+	wrap func(next http.HandlerFunc) http.HandlerFunc,
+	vaultHandler *vault.Handler,
+) {
+	routers.Get(prefix+"/items", wrap(vaultHandler.ListItems))
+	routers.Get(prefix+"/items/{id}", wrap(vaultHandler.GetItem))
+	routers.Post(prefix+"/items", wrap(vaultHandler.UpsertItem))
+	routers.Delete(prefix+"/items/{id}", wrap(vaultHandler.DeleteItem))
+	routers.Put(prefix+"/items/{id}/content", wrap(vaultHandler.UploadContent))
+	routers.Get(prefix+"/items/{id}/content", wrap(vaultHandler.DownloadContent))
+	routers.Delete(prefix+"/items/{id}/content", wrap(vaultHandler.DeleteContent))
+	routers.Post(prefix+"/items/{id}/attachments", wrap(vaultHandler.UploadAttachment))
+	routers.Get(prefix+"/items/{id}/attachments/{attachmentId}", wrap(vaultHandler.DownloadAttachment))
+	routers.Delete(prefix+"/items/{id}/attachments/{attachmentId}", wrap(vaultHandler.DeleteAttachment))
+	routers.Post(prefix+"/items/{id}/uploads", wrap(vaultHandler.CreateUpload))
+	routers.Head(prefix+"/uploads/{id}", wrap(vaultHandler.UploadStatus))
+	routers.Patch(prefix+"/uploads/{id}", wrap(vaultHandler.AppendUpload))
+	routers.Delete(prefix+"/uploads/{id}", wrap(vaultHandler.CancelUpload))
+	routers.Post(prefix+"/uploads/{id}/complete", wrap(vaultHandler.CompleteUpload))
+	routers.Get(prefix+"/items/{id}/revisions", wrap(vaultHandler.ListRevisions))
+	routers.Get(prefix+"/items/{id}/revisions/{version}", wrap(vaultHandler.GetRevision))
+	routers.Post(prefix+"/items/{id}/revisions/{version}/restore", wrap(vaultHandler.RestoreRevision))
+	routers.Get(prefix+"/trash", wrap(vaultHandler.ListTrash))
+	routers.Delete(prefix+"/trash", wrap(vaultHandler.EmptyTrash))
+	routers.Post(prefix+"/trash/{id}/restore", wrap(vaultHandler.RestoreTrash))
+	routers.Get(prefix+"/usage", wrap(vaultHandler.Usage))
+	routers.Get(prefix+"/sync", wrap(vaultHandler.Sync))
+	routers.Post(prefix+"/sync", wrap(vaultHandler.SyncPush))
+	routers.Get(prefix+"/tags", wrap(vaultHandler.ListTags))
+	routers.Post(prefix+"/tags/rename", wrap(vaultHandler.RenameTag))
+	routers.Post(prefix+"/tags/merge", wrap(vaultHandler.MergeTags))
+
+	if vaultHandler.Folders != nil {
+		routers.Get(prefix+"/folders", wrap(vaultHandler.ListFolders))
+		routers.Post(prefix+"/folders", wrap(vaultHandler.CreateFolder))
+		routers.Put(prefix+"/folders/{id}", wrap(vaultHandler.UpdateFolder))
+		routers.Delete(prefix+"/folders/{id}", wrap(vaultHandler.DeleteFolder))
+		routers.Post(prefix+"/items/move", wrap(vaultHandler.MoveItems))
+	}
+}
+
+// orgRoutes регистрирует обработчики организаций, их участников и коллекций.
+func orgRoutes(routers *chi.Mux, encryptor *jwt.Encryptor, orgHandler *org.Handler) {
+	routers.Get("/orgs", middleware.RequireAuth(encryptor, orgHandler.ListOrgs))
+	routers.Post("/orgs", middleware.RequireAuth(encryptor, orgHandler.CreateOrg))
+	routers.Get("/orgs/{orgId}", middleware.RequireAuth(encryptor, orgHandler.GetOrg))
+	routers.Post("/orgs/{orgId}/accept", middleware.RequireAuth(encryptor, orgHandler.AcceptInvite))
+	routers.Get("/orgs/{orgId}/members", middleware.RequireAuth(encryptor, orgHandler.ListMembers))
+	routers.Post("/orgs/{orgId}/members", middleware.RequireAuth(encryptor, orgHandler.InviteMember))
+	routers.Put(
+		"/orgs/{orgId}/members/{userId}",
+		middleware.RequireAuth(encryptor, orgHandler.UpdateMember),
+	)
+	routers.Delete(
+		"/orgs/{orgId}/members/{userId}",
+		middleware.RequireAuth(encryptor, orgHandler.RemoveMember),
+	)
+	routers.Get(
+		"/orgs/{orgId}/collections",
+		middleware.RequireAuth(encryptor, orgHandler.ListCollections),
+	)
+	routers.Post(
+		"/orgs/{orgId}/collections",
+		middleware.RequireAuth(encryptor, orgHandler.CreateCollection),
+	)
 	routers.Delete(
-		"/vault/items/{id}",
-		middleware.RequireAuth(s.encryptor, vaultHandler.DeleteItem),
+		"/orgs/{orgId}/collections/{collectionId}",
+		middleware.RequireAuth(encryptor, orgHandler.DeleteCollection),
 	)
-	routers.Get("/vault/sync", middleware.RequireAuth(s.encryptor, vaultHandler.SyncSince))
+}
 
-	s.server.Handler = routers
+// shareRoutes регистрирует обработчики передачи доступа к записям.
+func shareRoutes(routers *chi.Mux, encryptor *jwt.Encryptor, vaultHandler *vault.Handler) {
+	routers.Put("/vault/public-key", middleware.RequireAuth(encryptor, vaultHandler.SetPublicKey))
+	routers.Get("/vault/public-key", middleware.RequireAuth(encryptor, vaultHandler.GetPublicKey))
+	routers.Get(
+		"/vault/items/{id}/shares",
+		middleware.RequireAuth(encryptor, vaultHandler.ListItemShares),
+	)
+	routers.Put(
+		"/vault/items/{id}/shares",
+		middleware.RequireAuth(encryptor, vaultHandler.ShareItem),
+	)
+	routers.Delete(
+		"/vault/items/{id}/shares/{userId}",
+		middleware.RequireAuth(encryptor, vaultHandler.RevokeShare),
+	)
+	routers.Get("/vault/shared", middleware.RequireAuth(encryptor, vaultHandler.ListShared))
+	routers.Get(
+		"/vault/shared/{ownerId}/{id}",
+		middleware.RequireAuth(encryptor, vaultHandler.GetSharedItem),
+	)
+	routers.Post(
+		"/vault/shared/{ownerId}/{id}",
+		middleware.RequireAuth(encryptor, vaultHandler.UpdateSharedItem),
+	)
 }
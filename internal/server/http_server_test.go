@@ -3,9 +3,17 @@ package server_test
 
 import (
 	"context"
+	"net"
+	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/mr-filatik/go-goph-keeper/internal/server"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/crypto/jwt"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/seal"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
 	"github.com/mr-filatik/go-goph-keeper/internal/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -19,6 +27,20 @@ func TestNewHTTPServer(t *testing.T) {
 	conf := &server.HTTPServerConfig{
 		Address:   "127.0.0.1:0",
 		Encryptor: nil,
+		Quota:     entity.Quota{MaxItems: 0, MaxBytes: 0, MaxItemBytes: 0},
+
+		Blobs:       nil,
+		BlobMaxSize: 0,
+		Uploads:     nil,
+		Barrier:     nil,
+
+		Shares:  nil,
+		Orgs:    nil,
+		Folders: nil,
+
+		Backups:          nil,
+		AdminToken:       "",
+		BackupPassphrase: "",
 	}
 	serv := server.NewHTTPServer(conf, nil, nil, mockLogger)
 
@@ -33,6 +55,20 @@ func TestHTTPServer_Start(t *testing.T) {
 	conf := &server.HTTPServerConfig{
 		Address:   "127.0.0.1:0",
 		Encryptor: nil,
+		Quota:     entity.Quota{MaxItems: 0, MaxBytes: 0, MaxItemBytes: 0},
+
+		Blobs:       nil,
+		BlobMaxSize: 0,
+		Uploads:     nil,
+		Barrier:     nil,
+
+		Shares:  nil,
+		Orgs:    nil,
+		Folders: nil,
+
+		Backups:          nil,
+		AdminToken:       "",
+		BackupPassphrase: "",
 	}
 	serv := server.NewHTTPServer(conf, nil, nil, mockLogger)
 
@@ -50,6 +86,20 @@ func TestHTTPServer_Shutdown(t *testing.T) {
 	conf := &server.HTTPServerConfig{
 		Address:   "127.0.0.1:0",
 		Encryptor: nil,
+		Quota:     entity.Quota{MaxItems: 0, MaxBytes: 0, MaxItemBytes: 0},
+
+		Blobs:       nil,
+		BlobMaxSize: 0,
+		Uploads:     nil,
+		Barrier:     nil,
+
+		Shares:  nil,
+		Orgs:    nil,
+		Folders: nil,
+
+		Backups:          nil,
+		AdminToken:       "",
+		BackupPassphrase: "",
 	}
 	serv := server.NewHTTPServer(conf, nil, nil, mockLogger)
 
@@ -71,6 +121,20 @@ func TestHTTPServer_Close(t *testing.T) {
 	conf := &server.HTTPServerConfig{
 		Address:   "127.0.0.1:0",
 		Encryptor: nil,
+		Quota:     entity.Quota{MaxItems: 0, MaxBytes: 0, MaxItemBytes: 0},
+
+		Blobs:       nil,
+		BlobMaxSize: 0,
+		Uploads:     nil,
+		Barrier:     nil,
+
+		Shares:  nil,
+		Orgs:    nil,
+		Folders: nil,
+
+		Backups:          nil,
+		AdminToken:       "",
+		BackupPassphrase: "",
 	}
 	serv := server.NewHTTPServer(conf, nil, nil, mockLogger)
 
@@ -87,3 +151,78 @@ func TestHTTPServer_Close(t *testing.T) {
 
 	require.NoError(t, err)
 }
+
+func TestHTTPServer_Sealed(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	address := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	keys, err := seal.Init(2, 2)
+	require.NoError(t, err)
+
+	var serv *server.HTTPServer
+
+	barrier, err := seal.NewBarrier(keys.Check, func(context.Context, []byte) error {
+		stor := storage.NewMemoryStorage()
+		serv.Unseal(jwt.NewEncryptor("secret"), stor, stor, stor)
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	conf := &server.HTTPServerConfig{
+		Address:   address,
+		Encryptor: nil,
+		Quota:     entity.Quota{MaxItems: 0, MaxBytes: 0, MaxItemBytes: 0},
+
+		Blobs:       nil,
+		BlobMaxSize: 0,
+		Uploads:     nil,
+		Barrier:     barrier,
+
+		Backups:          storage.NewMemoryStorage(),
+		AdminToken:       "admin-token",
+		BackupPassphrase: "",
+	}
+	serv = server.NewHTTPServer(conf, nil, nil, testutil.NewMockLogger())
+
+	ctx := context.Background()
+	require.NoError(t, serv.Start(ctx))
+
+	defer func() { _ = serv.Close() }()
+
+	baseURL := "http://" + address
+
+	request := func(method, path, body string) int {
+		var resp *http.Response
+
+		require.Eventually(t, func() bool {
+			req, reqErr := http.NewRequestWithContext(ctx, method, baseURL+path, strings.NewReader(body))
+			require.NoError(t, reqErr)
+
+			var doErr error
+
+			resp, doErr = http.DefaultClient.Do(req)
+
+			return doErr == nil
+		}, time.Second, 10*time.Millisecond)
+
+		_ = resp.Body.Close()
+
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, request(http.MethodGet, "/vault/items", ""))
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/sys/seal-status", ""))
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/sys/backup", ""))
+
+	for _, share := range keys.Shares {
+		assert.Equal(t, http.StatusOK, request(http.MethodPost, "/sys/unseal", `{"share":"`+share+`"}`))
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/vault/items", ""))
+}
@@ -0,0 +1,126 @@
+// Package job предоставляет функционал для периодического выполнения фоновых задач сервера.
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/common/logger"
+)
+
+// ErrAlreadyStarted - задача уже запущена.
+var ErrAlreadyStarted = errors.New("job already started")
+
+// Action - действие, выполняемое задачей на каждом срабатывании.
+type Action func(ctx context.Context) error
+
+// Periodic выполняет действие с заданным интервалом до остановки.
+//
+// Первое выполнение происходит сразу после запуска.
+type Periodic struct {
+	action   Action
+	log      logger.Logger
+	cancel   context.CancelFunc
+	done     chan struct{}
+	name     string
+	interval time.Duration
+	mu       sync.Mutex
+}
+
+// NewPeriodic создаёт и инициализирует новый экзепляр *Periodic.
+//
+// Параметры:
+//   - name: название задачи для журнала;
+//   - interval: интервал между запусками;
+//   - action: выполняемое действие;
+//   - log: логгер.
+func NewPeriodic(name string, interval time.Duration, action Action, log logger.Logger) *Periodic {
+	return &Periodic{
+		action:   action,
+		log:      log,
+		cancel:   nil,
+		done:     nil,
+		name:     name,
+		interval: interval,
+		mu:       sync.Mutex{},
+	}
+}
+
+// Start запускает выполнение задачи в отдельной горутине.
+func (p *Periodic) Start(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.done != nil {
+		return fmt.Errorf("%s: %w", p.name, ErrAlreadyStarted)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	p.cancel = cancel
+	p.done = make(chan struct{})
+
+	p.log.Info("Job starting...", "name", p.name, "interval", p.interval.String())
+
+	go p.run(runCtx, p.done)
+
+	return nil
+}
+
+// Shutdown останавливает задачу и ожидает завершения текущего выполнения.
+func (p *Periodic) Shutdown(ctx context.Context) error {
+	done := p.stop()
+	if done == nil {
+		return nil
+	}
+
+	select {
+	case <-done:
+		p.log.Info("Job stopped", "name", p.name)
+
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%s shutdown: %w", p.name, ctx.Err())
+	}
+}
+
+// Close останавливает задачу без ожидания завершения текущего выполнения.
+func (p *Periodic) Close() error {
+	p.stop()
+
+	return nil
+}
+
+// stop отменяет контекст задачи и возвращает канал её завершения.
+func (p *Periodic) stop() chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cancel != nil {
+		p.cancel()
+	}
+
+	return p.done
+}
+
+// run выполняет действие до отмены контекста.
+func (p *Periodic) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.action(ctx); err != nil && ctx.Err() == nil {
+			p.log.Error("Job action error", err, "name", p.name)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package job_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/common/logger"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/job"
+	"github.com/mr-filatik/go-goph-keeper/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+	===== Periodic =====
+*/
+
+func TestPeriodic_StartShutdown(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+
+	mockLogger := testutil.NewMockLogger()
+	periodic := job.NewPeriodic("test", time.Millisecond, func(_ context.Context) error {
+		calls.Add(1)
+
+		return nil
+	}, mockLogger)
+
+	require.NoError(t, periodic.Start(context.Background()))
+	require.ErrorIs(t, periodic.Start(context.Background()), job.ErrAlreadyStarted)
+
+	assert.Eventually(t, func() bool { return calls.Load() >= 3 }, time.Second, time.Millisecond)
+
+	require.NoError(t, periodic.Shutdown(context.Background()))
+
+	stopped := calls.Load()
+
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, stopped, calls.Load())
+}
+
+func TestPeriodic_ActionError(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+
+	mockLogger := testutil.NewMockLogger()
+	periodic := job.NewPeriodic("test", time.Hour, func(_ context.Context) error {
+		calls.Add(1)
+
+		return assert.AnError
+	}, mockLogger)
+
+	require.NoError(t, periodic.Start(context.Background()))
+	assert.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+	require.NoError(t, periodic.Shutdown(context.Background()))
+
+	errLogs := 0
+
+	for _, log := range mockLogger.Logs {
+		if log.Level == logger.LevelError {
+			assert.ErrorIs(t, log.Err, assert.AnError)
+
+			errLogs++
+		}
+	}
+
+	assert.Equal(t, 1, errLogs)
+}
+
+func TestPeriodic_ShutdownNotStarted(t *testing.T) {
+	t.Parallel()
+
+	periodic := job.NewPeriodic("test", time.Hour, func(_ context.Context) error {
+		return nil
+	}, testutil.NewMockLogger())
+
+	require.NoError(t, periodic.Shutdown(context.Background()))
+	require.NoError(t, periodic.Close())
+}
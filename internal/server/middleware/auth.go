@@ -3,6 +3,7 @@ package middleware
 
 import (
 	"context"
+	"crypto/subtle"
 	"net/http"
 
 	"github.com/mr-filatik/go-goph-keeper/internal/server/crypto/jwt"
@@ -10,7 +11,10 @@ import (
 
 type ctxKey string
 
-const userIDKey ctxKey = "uid"
+const (
+	userIDKey  ctxKey = "uid"
+	ownerIDKey ctxKey = "owner"
+)
 
 // WithUserID добавляет в контекст идентификатор пользователя.
 func WithUserID(ctx context.Context, uid string) context.Context {
@@ -24,6 +28,22 @@ func GetUserID(ctx context.Context) (string, bool) {
 	return value, ok
 }
 
+// WithOwnerID добавляет в контекст владельца записей, с которыми работает запрос,
+// если он отличается от пользователя (например, коллекция организации).
+func WithOwnerID(ctx context.Context, ownerID string) context.Context {
+	return context.WithValue(ctx, ownerIDKey, ownerID)
+}
+
+// GetOwnerID получает из контекста владельца записей, с которыми работает запрос:
+// заданного WithOwnerID, а если он не задан - самого пользователя.
+func GetOwnerID(ctx context.Context) (string, bool) {
+	if value, ok := ctx.Value(ownerIDKey).(string); ok {
+		return value, true
+	}
+
+	return GetUserID(ctx)
+}
+
 // RequireAuth представляет middleware для авторизации пользователей.
 func RequireAuth(enc *jwt.Encryptor, next http.HandlerFunc) http.HandlerFunc {
 	return func(resp http.ResponseWriter, req *http.Request) {
@@ -44,3 +64,20 @@ func RequireAuth(enc *jwt.Encryptor, next http.HandlerFunc) http.HandlerFunc {
 		next(resp, req.WithContext(WithUserID(req.Context(), uid)))
 	}
 }
+
+// RequireAdminToken представляет middleware для административных запросов:
+// запрос должен передавать токен администратора в заголовке Authorization (Bearer).
+func RequireAdminToken(token string, next http.HandlerFunc) http.HandlerFunc {
+	want := []byte("Bearer " + token)
+
+	return func(resp http.ResponseWriter, req *http.Request) {
+		got := []byte(req.Header.Get("Authorization"))
+		if token == "" || subtle.ConstantTimeCompare(got, want) != 1 {
+			http.Error(resp, "invalid admin token", http.StatusUnauthorized)
+
+			return
+		}
+
+		next(resp, req)
+	}
+}
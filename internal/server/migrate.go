@@ -0,0 +1,135 @@
+// Package server предоставляет функционал для запуска приложения сервера.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jackc/pgx/v5"
+	root "github.com/mr-filatik/go-goph-keeper"
+	"github.com/mr-filatik/go-goph-keeper/internal/common/logger"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/config"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/migration"
+)
+
+// Подкоманды миграций.
+const (
+	commandMigrate = "migrate"
+
+	migrateUp     = "up"
+	migrateDown   = "down"
+	migrateStatus = "status"
+)
+
+var (
+	errUnknownMigrateAction = errors.New("unknown migrate action, expected up|down|status")
+	errDatabaseNotSet       = errors.New("database connection string is not set")
+)
+
+// runMigrateCommand выполняет подкоманду `server migrate up|down|status [flags]`.
+//
+// Параметры:
+//   - ctx: контекст выполнения;
+//   - args: аргументы после названия подкоманды;
+//   - log: логгер.
+func runMigrateCommand(ctx context.Context, args []string, log logger.Logger) error {
+	if len(args) == 0 {
+		return errUnknownMigrateAction
+	}
+
+	action := args[0]
+	appConfig := config.InitializeFromArgs(args[1:])
+
+	if appConfig.Database == "" {
+		return errDatabaseNotSet
+	}
+
+	migrator, closeFn, err := openMigrator(ctx, appConfig.Database)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	switch action {
+	case migrateUp:
+		applied, upErr := migrator.Up(ctx)
+		if upErr != nil {
+			return fmt.Errorf("migrate up: %w", upErr)
+		}
+
+		log.Info("Migrations applied", "versions", applied)
+	case migrateDown:
+		version, downErr := migrator.Down(ctx)
+		if downErr != nil {
+			return fmt.Errorf("migrate down: %w", downErr)
+		}
+
+		log.Info("Migration reverted", "version", version)
+	case migrateStatus:
+		st, stErr := migrator.Status(ctx)
+		if stErr != nil {
+			return fmt.Errorf("migrate status: %w", stErr)
+		}
+
+		printMigrateStatus(os.Stdout, st)
+	default:
+		return fmt.Errorf("%s: %w", action, errUnknownMigrateAction)
+	}
+
+	return nil
+}
+
+// migrateDatabase применяет ожидающие миграции при запуске сервера.
+// Запуск прерывается, если схема повреждена или новее версии приложения.
+func migrateDatabase(ctx context.Context, dsn string, log logger.Logger) error {
+	migrator, closeFn, err := openMigrator(ctx, dsn)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		return fmt.Errorf("migrate up: %w", err)
+	}
+
+	if len(applied) > 0 {
+		log.Info("Database migrations applied", "versions", applied)
+	}
+
+	return nil
+}
+
+// openMigrator подключается к базе данных и создаёт *migration.Migrator
+// со встроенными в приложение миграциями.
+func openMigrator(ctx context.Context, dsn string) (*migration.Migrator, func(), error) {
+	migrations, err := migration.Load(root.EmbedMigrations, root.DirMigrations)
+	if err != nil {
+		return nil, nil, fmt.Errorf("load migrations: %w", err)
+	}
+
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return nil, nil, fmt.Errorf("connect database: %w", err)
+	}
+
+	closeFn := func() {
+		_ = conn.Close(context.WithoutCancel(ctx))
+	}
+
+	return migration.NewMigrator(conn, migrations), closeFn, nil
+}
+
+// printMigrateStatus выводит состояние схемы базы данных.
+func printMigrateStatus(out io.Writer, st *migration.Status) {
+	_, _ = fmt.Fprintf(out, "current version: %d\n", st.Version)
+	_, _ = fmt.Fprintf(out, "latest version:  %d\n", st.Latest)
+	_, _ = fmt.Fprintf(out, "dirty:           %t\n", st.Dirty)
+
+	for _, mig := range st.Pending {
+		_, _ = fmt.Fprintf(out, "pending:         %04d_%s\n", mig.Version, mig.Name)
+	}
+}
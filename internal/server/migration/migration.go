@@ -0,0 +1,128 @@
+// Package migration предоставляет функционал для применения миграций базы данных.
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// Возможные ошибки при загрузке миграций.
+var (
+	ErrInvalidFileName   = errors.New("invalid migration file name")
+	ErrDuplicateVersion  = errors.New("duplicate migration version")
+	ErrMissingUpScript   = errors.New("missing up migration script")
+	ErrMissingDownScript = errors.New("missing down migration script")
+)
+
+// Направления миграций в названиях файлов.
+const (
+	directionUp   = "up"
+	directionDown = "down"
+)
+
+// fileNamePattern описывает формат названия файла миграции: 0001_name.up.sql.
+var fileNamePattern = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration описывает одну миграцию базы данных.
+type Migration struct {
+	Name    string // название миграции
+	UpSQL   string // скрипт применения
+	DownSQL string // скрипт отката
+	Version int64  // номер версии схемы
+}
+
+// Load загружает миграции из указанного каталога файловой системы.
+// Возвращает миграции, упорядоченные по возрастанию версии.
+//
+// Параметры:
+//   - fsys: файловая система;
+//   - dir: каталог с миграциями.
+func Load(fsys fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("read dir %s: %w", dir, err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		if err := loadFile(fsys, dir, entry.Name(), byVersion); err != nil {
+			return nil, err
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+
+	for _, mig := range byVersion {
+		if mig.UpSQL == "" {
+			return nil, fmt.Errorf("version %d: %w", mig.Version, ErrMissingUpScript)
+		}
+
+		migrations = append(migrations, mig)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// loadFile загружает один файл миграции и добавляет его в набор по версиям.
+func loadFile(fsys fs.FS, dir, name string, byVersion map[int64]*Migration) error {
+	parts := fileNamePattern.FindStringSubmatch(name)
+	if parts == nil {
+		return fmt.Errorf("%s: %w", name, ErrInvalidFileName)
+	}
+
+	version, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || version <= 0 {
+		return fmt.Errorf("%s: %w", name, ErrInvalidFileName)
+	}
+
+	data, err := fs.ReadFile(fsys, path.Join(dir, name))
+	if err != nil {
+		return fmt.Errorf("read file %s: %w", name, err)
+	}
+
+	mig, ok := byVersion[version]
+	if !ok {
+		mig = &Migration{
+			Name:    parts[2],
+			UpSQL:   "",
+			DownSQL: "",
+			Version: version,
+		}
+		byVersion[version] = mig
+	}
+
+	if mig.Name != parts[2] {
+		return fmt.Errorf("%s: %w", name, ErrDuplicateVersion)
+	}
+
+	switch parts[3] {
+	case directionUp:
+		if mig.UpSQL != "" {
+			return fmt.Errorf("%s: %w", name, ErrDuplicateVersion)
+		}
+
+		mig.UpSQL = string(data)
+	case directionDown:
+		if mig.DownSQL != "" {
+			return fmt.Errorf("%s: %w", name, ErrDuplicateVersion)
+		}
+
+		mig.DownSQL = string(data)
+	}
+
+	return nil
+}
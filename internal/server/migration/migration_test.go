@@ -0,0 +1,120 @@
+package migration_test
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	root "github.com/mr-filatik/go-goph-keeper"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/migration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mapFile(data string) *fstest.MapFile {
+	return &fstest.MapFile{
+		Data:    []byte(data),
+		Mode:    fs.ModePerm,
+		ModTime: time.Now(),
+		Sys:     nil,
+	}
+}
+
+/*
+	===== Load =====
+*/
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	testFS := fstest.MapFS{
+		"migrations/0002_items.up.sql":   mapFile("CREATE TABLE items ();"),
+		"migrations/0002_items.down.sql": mapFile("DROP TABLE items;"),
+		"migrations/0001_users.up.sql":   mapFile("CREATE TABLE users ();"),
+		"migrations/0001_users.down.sql": mapFile("DROP TABLE users;"),
+	}
+
+	migrations, err := migration.Load(testFS, "migrations")
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+
+	assert.Equal(t, int64(1), migrations[0].Version)
+	assert.Equal(t, "users", migrations[0].Name)
+	assert.Equal(t, "CREATE TABLE users ();", migrations[0].UpSQL)
+	assert.Equal(t, "DROP TABLE users;", migrations[0].DownSQL)
+
+	assert.Equal(t, int64(2), migrations[1].Version)
+	assert.Equal(t, "items", migrations[1].Name)
+}
+
+type testLoadError struct {
+	files fstest.MapFS
+	err   error
+	name  string
+}
+
+func createTestsForLoadError() []testLoadError {
+	tests := []testLoadError{
+		{
+			name: "invalid file name",
+			files: fstest.MapFS{
+				"migrations/init.sql": mapFile("SELECT 1;"),
+			},
+			err: migration.ErrInvalidFileName,
+		},
+		{
+			name: "zero version",
+			files: fstest.MapFS{
+				"migrations/0000_init.up.sql": mapFile("SELECT 1;"),
+			},
+			err: migration.ErrInvalidFileName,
+		},
+		{
+			name: "missing up script",
+			files: fstest.MapFS{
+				"migrations/0001_init.down.sql": mapFile("SELECT 1;"),
+			},
+			err: migration.ErrMissingUpScript,
+		},
+		{
+			name: "duplicate version",
+			files: fstest.MapFS{
+				"migrations/0001_init.up.sql":  mapFile("SELECT 1;"),
+				"migrations/0001_other.up.sql": mapFile("SELECT 2;"),
+			},
+			err: migration.ErrDuplicateVersion,
+		},
+	}
+
+	return tests
+}
+
+func TestLoad_Error(t *testing.T) {
+	t.Parallel()
+
+	tests := createTestsForLoadError()
+
+	for index := range tests {
+		internalTest := tests[index]
+		t.Run(internalTest.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := migration.Load(internalTest.files, "migrations")
+			require.ErrorIs(t, err, internalTest.err)
+		})
+	}
+}
+
+func TestLoad_Embedded(t *testing.T) {
+	t.Parallel()
+
+	migrations, err := migration.Load(root.EmbedMigrations, root.DirMigrations)
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	for _, mig := range migrations {
+		assert.NotEmpty(t, mig.UpSQL, "version %d", mig.Version)
+		assert.NotEmpty(t, mig.DownSQL, "version %d", mig.Version)
+	}
+}
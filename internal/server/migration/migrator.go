@@ -0,0 +1,278 @@
+// Package migration предоставляет функционал для применения миграций базы данных.
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Возможные ошибки при применении миграций.
+var (
+	ErrSchemaDirty = errors.New("database schema is dirty")
+	ErrSchemaNewer = errors.New("database schema is newer than application")
+	ErrNoApplied   = errors.New("no applied migrations")
+)
+
+// lockKey - ключ advisory-блокировки, исключающей одновременный запуск миграций.
+const lockKey int64 = 7_305_411_902
+
+// schemaTable - скрипт создания таблицы с применёнными версиями схемы.
+const schemaTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    BIGINT PRIMARY KEY,
+	dirty      BOOLEAN NOT NULL DEFAULT false,
+	applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`
+
+// Status описывает состояние схемы базы данных.
+type Status struct {
+	Pending []*Migration // миграции, ожидающие применения
+	Version int64        // текущая версия схемы (0 - миграции не применялись)
+	Latest  int64        // последняя версия, известная приложению
+	Dirty   bool         // признак незавершённой миграции
+}
+
+// Migrator применяет миграции к базе данных PostgreSQL.
+type Migrator struct {
+	conn       *pgx.Conn
+	migrations []*Migration
+}
+
+// NewMigrator создаёт и инициализирует новый экзепляр *Migrator.
+//
+// Параметры:
+//   - conn: соединение с базой данных;
+//   - migrations: миграции, упорядоченные по возрастанию версии.
+func NewMigrator(conn *pgx.Conn, migrations []*Migration) *Migrator {
+	return &Migrator{
+		conn:       conn,
+		migrations: migrations,
+	}
+}
+
+// Status выводит текущее состояние схемы базы данных.
+func (m *Migrator) Status(ctx context.Context) (*Status, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	return m.status(ctx)
+}
+
+// Check проверяет, что схема базы данных не повреждена и не новее приложения.
+func (m *Migrator) Check(ctx context.Context) error {
+	st, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	return checkStatus(st)
+}
+
+// Up применяет все ожидающие миграции. Каждая миграция выполняется в отдельной транзакции.
+// Возвращает версии применённых миграций.
+func (m *Migrator) Up(ctx context.Context) ([]int64, error) {
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	st, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkStatus(st); err != nil {
+		return nil, err
+	}
+
+	applied := make([]int64, 0, len(st.Pending))
+
+	for _, mig := range st.Pending {
+		if err := m.apply(ctx, mig); err != nil {
+			return applied, err
+		}
+
+		applied = append(applied, mig.Version)
+	}
+
+	return applied, nil
+}
+
+// Down откатывает последнюю применённую миграцию в транзакции.
+// Возвращает версию отменённой миграции.
+func (m *Migrator) Down(ctx context.Context) (int64, error) {
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	st, err := m.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := checkStatus(st); err != nil {
+		return 0, err
+	}
+
+	if st.Version == 0 {
+		return 0, ErrNoApplied
+	}
+
+	mig := m.find(st.Version)
+	if mig == nil || mig.DownSQL == "" {
+		return 0, fmt.Errorf("version %d: %w", st.Version, ErrMissingDownScript)
+	}
+
+	if err := m.revert(ctx, mig); err != nil {
+		return 0, err
+	}
+
+	return mig.Version, nil
+}
+
+// apply применяет одну миграцию.
+//
+// Перед выполнением версия помечается как грязная вне транзакции: если процесс
+// прервётся во время миграции, пометка останется и следующий запуск будет остановлен.
+func (m *Migrator) apply(ctx context.Context, mig *Migration) error {
+	_, err := m.conn.Exec(ctx,
+		`INSERT INTO schema_migrations (version, dirty) VALUES ($1, true)`, mig.Version)
+	if err != nil {
+		return fmt.Errorf("mark version %d: %w", mig.Version, err)
+	}
+
+	txErr := pgx.BeginFunc(ctx, m.conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, mig.UpSQL); err != nil {
+			return fmt.Errorf("exec: %w", err)
+		}
+
+		_, err := tx.Exec(ctx,
+			`UPDATE schema_migrations SET dirty = false, applied_at = now() WHERE version = $1`,
+			mig.Version)
+		if err != nil {
+			return fmt.Errorf("unmark: %w", err)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		// Транзакция откатилась целиком, схема осталась прежней.
+		_, _ = m.conn.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, mig.Version)
+
+		return fmt.Errorf("apply version %d (%s): %w", mig.Version, mig.Name, txErr)
+	}
+
+	return nil
+}
+
+// revert откатывает одну миграцию.
+func (m *Migrator) revert(ctx context.Context, mig *Migration) error {
+	_, err := m.conn.Exec(ctx,
+		`UPDATE schema_migrations SET dirty = true WHERE version = $1`, mig.Version)
+	if err != nil {
+		return fmt.Errorf("mark version %d: %w", mig.Version, err)
+	}
+
+	txErr := pgx.BeginFunc(ctx, m.conn, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, mig.DownSQL); err != nil {
+			return fmt.Errorf("exec: %w", err)
+		}
+
+		if _, err := tx.Exec(ctx,
+			`DELETE FROM schema_migrations WHERE version = $1`, mig.Version); err != nil {
+			return fmt.Errorf("delete version: %w", err)
+		}
+
+		return nil
+	})
+	if txErr != nil {
+		_, _ = m.conn.Exec(ctx,
+			`UPDATE schema_migrations SET dirty = false WHERE version = $1`, mig.Version)
+
+		return fmt.Errorf("revert version %d (%s): %w", mig.Version, mig.Name, txErr)
+	}
+
+	return nil
+}
+
+// status считывает состояние схемы из базы данных.
+func (m *Migrator) status(ctx context.Context) (*Status, error) {
+	st := &Status{
+		Pending: make([]*Migration, 0),
+		Version: 0,
+		Latest:  0,
+		Dirty:   false,
+	}
+
+	if len(m.migrations) > 0 {
+		st.Latest = m.migrations[len(m.migrations)-1].Version
+	}
+
+	err := m.conn.QueryRow(ctx,
+		`SELECT COALESCE(MAX(version), 0), COALESCE(BOOL_OR(dirty), false) FROM schema_migrations`,
+	).Scan(&st.Version, &st.Dirty)
+	if err != nil {
+		return nil, fmt.Errorf("read schema version: %w", err)
+	}
+
+	for _, mig := range m.migrations {
+		if mig.Version > st.Version {
+			st.Pending = append(st.Pending, mig)
+		}
+	}
+
+	return st, nil
+}
+
+// ensureTable создаёт таблицу версий схемы, если она отсутствует.
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	if _, err := m.conn.Exec(ctx, schemaTable); err != nil {
+		return fmt.Errorf("create schema table: %w", err)
+	}
+
+	return nil
+}
+
+// lock захватывает advisory-блокировку и возвращает функцию её освобождения.
+func (m *Migrator) lock(ctx context.Context) (func(), error) {
+	if _, err := m.conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return nil, fmt.Errorf("acquire lock: %w", err)
+	}
+
+	unlock := func() {
+		_, _ = m.conn.Exec(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, lockKey)
+	}
+
+	return unlock, nil
+}
+
+// find ищет миграцию по версии.
+func (m *Migrator) find(version int64) *Migration {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return mig
+		}
+	}
+
+	return nil
+}
+
+// checkStatus проверяет, можно ли работать со схемой в указанном состоянии.
+func checkStatus(st *Status) error {
+	if st.Dirty {
+		return fmt.Errorf("version %d: %w", st.Version, ErrSchemaDirty)
+	}
+
+	if st.Version > st.Latest {
+		return fmt.Errorf("version %d > %d: %w", st.Version, st.Latest, ErrSchemaNewer)
+	}
+
+	return nil
+}
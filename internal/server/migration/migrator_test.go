@@ -0,0 +1,140 @@
+package migration_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/migration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// envTestDatabase - переменная окружения со строкой подключения к тестовой базе данных.
+const envTestDatabase = "TEST_DATABASE_DSN"
+
+// newTestConn подключается к тестовой базе данных и переключается в отдельную схему.
+func newTestConn(t *testing.T) *pgx.Conn {
+	t.Helper()
+
+	dsn := os.Getenv(envTestDatabase)
+	if dsn == "" {
+		t.Skipf("%s is not set", envTestDatabase)
+	}
+
+	ctx := context.Background()
+
+	conn, err := pgx.Connect(ctx, dsn)
+	require.NoError(t, err)
+
+	schema := "migration_test_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+
+	_, err = conn.Exec(ctx, "CREATE SCHEMA "+schema+"; SET search_path TO "+schema)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_, _ = conn.Exec(ctx, "DROP SCHEMA "+schema+" CASCADE")
+		_ = conn.Close(ctx)
+	})
+
+	return conn
+}
+
+func testMigrations() []*migration.Migration {
+	return []*migration.Migration{
+		{
+			Version: 1,
+			Name:    "first",
+			UpSQL:   "CREATE TABLE first (id INT);",
+			DownSQL: "DROP TABLE first;",
+		},
+		{
+			Version: 2,
+			Name:    "second",
+			UpSQL:   "CREATE TABLE second (id INT);",
+			DownSQL: "DROP TABLE second;",
+		},
+	}
+}
+
+/*
+	===== Migrator =====
+*/
+
+func TestMigrator_UpDownStatus(t *testing.T) {
+	t.Parallel()
+
+	conn := newTestConn(t)
+	ctx := context.Background()
+	migrator := migration.NewMigrator(conn, testMigrations())
+
+	st, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), st.Version)
+	assert.Equal(t, int64(2), st.Latest)
+	assert.Len(t, st.Pending, 2)
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, applied)
+
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	version, err := migrator.Down(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), version)
+
+	st, err = migrator.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), st.Version)
+	assert.False(t, st.Dirty)
+	assert.Len(t, st.Pending, 1)
+}
+
+func TestMigrator_UpFailedRollsBack(t *testing.T) {
+	t.Parallel()
+
+	conn := newTestConn(t)
+	ctx := context.Background()
+
+	migrations := testMigrations()
+	migrations[1].UpSQL = "CREATE TABLE second (id INT); SELECT broken;"
+
+	migrator := migration.NewMigrator(conn, migrations)
+
+	applied, err := migrator.Up(ctx)
+	require.Error(t, err)
+	assert.Equal(t, []int64{1}, applied)
+
+	st, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), st.Version)
+	assert.False(t, st.Dirty)
+}
+
+func TestMigrator_Check(t *testing.T) {
+	t.Parallel()
+
+	conn := newTestConn(t)
+	ctx := context.Background()
+
+	_, err := migration.NewMigrator(conn, testMigrations()).Up(ctx)
+	require.NoError(t, err)
+
+	older := migration.NewMigrator(conn, testMigrations()[:1])
+	require.ErrorIs(t, older.Check(ctx), migration.ErrSchemaNewer)
+
+	_, err = older.Up(ctx)
+	require.ErrorIs(t, err, migration.ErrSchemaNewer)
+
+	_, err = conn.Exec(ctx, "UPDATE schema_migrations SET dirty = true WHERE version = 2")
+	require.NoError(t, err)
+
+	current := migration.NewMigrator(conn, testMigrations())
+	require.ErrorIs(t, current.Check(ctx), migration.ErrSchemaDirty)
+}
@@ -0,0 +1,64 @@
+// Package server предоставляет функционал для запуска приложения сервера.
+package server
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/seal"
+)
+
+// Подкоманды запечатанного режима.
+const (
+	commandSeal = "seal"
+
+	sealInit = "init"
+
+	defaultSealShares    = 5 // количество долей мастер-ключа по умолчанию
+	defaultSealThreshold = 3 // количество долей для распечатывания по умолчанию
+)
+
+var errUnknownSealAction = errors.New("unknown seal action, expected init")
+
+// runSealCommand выполняет подкоманду `server seal init [-shares N] [-threshold T]`:
+// создаёт мастер-ключ, делит его на доли и выводит доли и контрольное значение.
+//
+// Параметры:
+//   - args: аргументы после названия подкоманды;
+//   - out: вывод для долей и контрольного значения.
+func runSealCommand(args []string, out io.Writer) error {
+	if len(args) == 0 || args[0] != sealInit {
+		return errUnknownSealAction
+	}
+
+	flagSet := flag.NewFlagSet(commandSeal+" "+sealInit, flag.ContinueOnError)
+	shares := flagSet.Int("shares", defaultSealShares, "number of master key shares")
+	threshold := flagSet.Int("threshold", defaultSealThreshold, "number of shares required to unseal")
+
+	if err := flagSet.Parse(args[1:]); err != nil {
+		return fmt.Errorf("parse flags: %w", err)
+	}
+
+	res, err := seal.Init(*shares, *threshold)
+	if err != nil {
+		return fmt.Errorf("seal init: %w", err)
+	}
+
+	printSealInit(out, res, *threshold)
+
+	return nil
+}
+
+// printSealInit выводит доли мастер-ключа и контрольное значение.
+func printSealInit(out io.Writer, res *seal.InitResult, threshold int) {
+	for index, share := range res.Shares {
+		_, _ = fmt.Fprintf(out, "unseal share %d: %s\n", index+1, share)
+	}
+
+	_, _ = fmt.Fprintf(out, "unseal check:   %s\n\n", res.Check)
+	_, _ = fmt.Fprintf(out, "Give each share to a different operator: any %d of them unseal the server.\n", threshold)
+	_, _ = fmt.Fprintln(out, "Start the server with UNSEAL_CHECK set to the check value and submit")
+	_, _ = fmt.Fprintln(out, "the shares to POST /sys/unseal. The master key itself is not stored anywhere.")
+}
@@ -0,0 +1,240 @@
+// Package seal предоставляет запечатанный режим сервера: сервер запускается
+// без мастер-ключа и получает его, когда операторы передадут достаточное
+// количество долей ключа (схема Шамира).
+package seal
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/crypto/shamir"
+)
+
+const (
+	// MasterKeySize - размер мастер-ключа в байтах.
+	MasterKeySize = 32
+
+	// labelCheck - метка контрольного значения мастер-ключа.
+	labelCheck = "goph-keeper unseal check"
+)
+
+// Назначения ключей, получаемых из мастер-ключа (см. DeriveKey).
+const (
+	PurposeHashKey = "goph-keeper hash key" // ключ шифрования данных хранилища
+	PurposeJWTKey  = "goph-keeper jwt key"  // ключ подписи JWT
+)
+
+// Возможные ошибки при распечатывании сервера.
+var (
+	ErrInvalidCheck = errors.New("invalid unseal check value")
+	ErrInvalidShare = errors.New("invalid unseal share")
+	ErrWrongKey     = errors.New("unseal shares do not match the master key")
+)
+
+// Status описывает состояние распечатывания сервера.
+type Status struct {
+	Sealed    bool `json:"sealed"`    // сервер запечатан
+	Threshold int  `json:"threshold"` // количество долей, необходимое для распечатывания
+	Progress  int  `json:"progress"`  // количество уже переданных долей
+}
+
+// UnsealFunc получает восстановленный мастер-ключ и подготавливает сервер к работе.
+// Ошибка оставляет сервер запечатанным.
+type UnsealFunc func(ctx context.Context, masterKey []byte) error
+
+// Barrier хранит переданные доли мастер-ключа, пока сервер запечатан.
+//
+// Когда передано threshold долей, мастер-ключ восстанавливается, сверяется
+// с контрольным значением и передаётся в onUnseal. Доли и ключ в Barrier
+// не сохраняются.
+type Barrier struct {
+	onUnseal  UnsealFunc
+	check     []byte   // HMAC мастер-ключа с меткой labelCheck
+	shares    [][]byte // переданные доли
+	threshold int
+	sealed    bool
+	mu        sync.Mutex
+}
+
+// NewBarrier создаёт запечатанный Barrier.
+//
+// Параметры:
+//   - check: контрольное значение, выданное Init;
+//   - onUnseal: функция, получающая восстановленный мастер-ключ.
+func NewBarrier(check string, onUnseal UnsealFunc) (*Barrier, error) {
+	threshold, sum, err := parseCheck(check)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Barrier{
+		onUnseal:  onUnseal,
+		check:     sum,
+		shares:    nil,
+		threshold: threshold,
+		sealed:    true,
+		mu:        sync.Mutex{},
+	}, nil
+}
+
+// Status возвращает текущее состояние распечатывания.
+func (b *Barrier) Status() Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.status()
+}
+
+// Sealed сообщает, что сервер ещё запечатан.
+func (b *Barrier) Sealed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.sealed
+}
+
+// Submit принимает долю мастер-ключа (base64). Повторно переданная доля не учитывается.
+//
+// Когда долей достаточно, сервер распечатывается. Если восстановленный ключ
+// не совпал с контрольным значением (ErrWrongKey) или onUnseal завершился ошибкой,
+// переданные доли сбрасываются. Для распечатанного сервера доля игнорируется.
+func (b *Barrier) Submit(ctx context.Context, share string) (Status, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.sealed {
+		return b.status(), nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(share))
+	if err != nil || len(raw) != MasterKeySize+1 {
+		return b.status(), ErrInvalidShare
+	}
+
+	for _, known := range b.shares {
+		if hmac.Equal(known, raw) {
+			return b.status(), nil
+		}
+	}
+
+	b.shares = append(b.shares, raw)
+	if len(b.shares) < b.threshold {
+		return b.status(), nil
+	}
+
+	shares := b.shares
+	b.shares = nil
+
+	masterKey, err := shamir.Combine(shares)
+	if err != nil {
+		return b.status(), fmt.Errorf("%w: %w", ErrInvalidShare, err)
+	}
+
+	if !hmac.Equal(checkSum(masterKey), b.check) {
+		return b.status(), ErrWrongKey
+	}
+
+	if err := b.onUnseal(ctx, masterKey); err != nil {
+		return b.status(), fmt.Errorf("unseal: %w", err)
+	}
+
+	b.sealed = false
+
+	return b.status(), nil
+}
+
+// Reset сбрасывает переданные доли.
+func (b *Barrier) Reset() Status {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.shares = nil
+
+	return b.status()
+}
+
+// status возвращает состояние (вызывается под блокировкой).
+func (b *Barrier) status() Status {
+	return Status{
+		Sealed:    b.sealed,
+		Threshold: b.threshold,
+		Progress:  len(b.shares),
+	}
+}
+
+// InitResult описывает новый мастер-ключ, разделённый на доли.
+type InitResult struct {
+	Shares []string // доли мастер-ключа (base64) для раздачи операторам
+	Check  string   // контрольное значение для конфигурации сервера
+}
+
+// Init создаёт случайный мастер-ключ и делит его на parts долей,
+// любые threshold из которых распечатывают сервер. Сам ключ не возвращается.
+func Init(parts, threshold int) (*InitResult, error) {
+	masterKey := make([]byte, MasterKeySize)
+
+	if _, err := rand.Read(masterKey); err != nil {
+		return nil, fmt.Errorf("generate master key: %w", err)
+	}
+
+	shares, err := shamir.Split(masterKey, parts, threshold)
+	if err != nil {
+		return nil, fmt.Errorf("split master key: %w", err)
+	}
+
+	res := &InitResult{
+		Shares: make([]string, 0, len(shares)),
+		Check:  strconv.Itoa(threshold) + ":" + hex.EncodeToString(checkSum(masterKey)),
+	}
+
+	for _, share := range shares {
+		res.Shares = append(res.Shares, base64.StdEncoding.EncodeToString(share))
+	}
+
+	return res, nil
+}
+
+// DeriveKey получает из мастер-ключа ключ для назначения purpose (hex).
+func DeriveKey(masterKey []byte, purpose string) string {
+	mac := hmac.New(sha256.New, masterKey)
+	mac.Write([]byte(purpose))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// parseCheck разбирает контрольное значение вида "<threshold>:<hex>".
+func parseCheck(check string) (int, []byte, error) {
+	thresholdStr, sumHex, ok := strings.Cut(check, ":")
+	if !ok {
+		return 0, nil, ErrInvalidCheck
+	}
+
+	threshold, err := strconv.Atoi(thresholdStr)
+	if err != nil || threshold < shamir.MinThreshold || threshold > shamir.MaxParts {
+		return 0, nil, fmt.Errorf("%w: threshold %q", ErrInvalidCheck, thresholdStr)
+	}
+
+	sum, err := hex.DecodeString(sumHex)
+	if err != nil || len(sum) != sha256.Size {
+		return 0, nil, fmt.Errorf("%w: checksum", ErrInvalidCheck)
+	}
+
+	return threshold, sum, nil
+}
+
+// checkSum вычисляет контрольное значение мастер-ключа.
+func checkSum(masterKey []byte) []byte {
+	mac := hmac.New(sha256.New, masterKey)
+	mac.Write([]byte(labelCheck))
+
+	return mac.Sum(nil)
+}
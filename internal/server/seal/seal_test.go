@@ -0,0 +1,118 @@
+package seal_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/seal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+	===== Barrier.Submit =====
+*/
+
+func TestBarrier_Unseal(t *testing.T) {
+	t.Parallel()
+
+	keys, err := seal.Init(5, 3)
+	require.NoError(t, err)
+	require.Len(t, keys.Shares, 5)
+
+	var unsealedKey []byte
+
+	barrier, err := seal.NewBarrier(keys.Check, func(_ context.Context, masterKey []byte) error {
+		unsealedKey = masterKey
+
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, seal.Status{Sealed: true, Threshold: 3, Progress: 0}, barrier.Status())
+
+	ctx := context.Background()
+
+	status, err := barrier.Submit(ctx, keys.Shares[4])
+	require.NoError(t, err)
+	assert.Equal(t, 1, status.Progress)
+
+	status, err = barrier.Submit(ctx, keys.Shares[4])
+	require.NoError(t, err)
+	assert.Equal(t, 1, status.Progress, "duplicate share is ignored")
+
+	_, err = barrier.Submit(ctx, "not-a-share")
+	require.ErrorIs(t, err, seal.ErrInvalidShare)
+
+	_, err = barrier.Submit(ctx, keys.Shares[1])
+	require.NoError(t, err)
+	assert.True(t, barrier.Sealed())
+
+	status, err = barrier.Submit(ctx, keys.Shares[2])
+	require.NoError(t, err)
+	assert.Equal(t, seal.Status{Sealed: false, Threshold: 3, Progress: 0}, status)
+	assert.False(t, barrier.Sealed())
+	require.Len(t, unsealedKey, seal.MasterKeySize)
+	assert.NotEqual(t,
+		seal.DeriveKey(unsealedKey, seal.PurposeHashKey),
+		seal.DeriveKey(unsealedKey, seal.PurposeJWTKey))
+}
+
+func TestBarrier_WrongShares(t *testing.T) {
+	t.Parallel()
+
+	keys, err := seal.Init(3, 2)
+	require.NoError(t, err)
+
+	other, err := seal.Init(3, 2)
+	require.NoError(t, err)
+
+	calls := 0
+
+	barrier, err := seal.NewBarrier(keys.Check, func(context.Context, []byte) error {
+		calls++
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	_, err = barrier.Submit(ctx, keys.Shares[0])
+	require.NoError(t, err)
+
+	status, err := barrier.Submit(ctx, other.Shares[1])
+	require.ErrorIs(t, err, seal.ErrWrongKey)
+	assert.Equal(t, seal.Status{Sealed: true, Threshold: 2, Progress: 0}, status, "shares are reset")
+	assert.Zero(t, calls)
+
+	_, err = barrier.Submit(ctx, keys.Shares[0])
+	require.NoError(t, err)
+	assert.Equal(t, 0, barrier.Reset().Progress)
+
+	_, err = seal.NewBarrier("broken", nil)
+	require.ErrorIs(t, err, seal.ErrInvalidCheck)
+
+	_, err = seal.NewBarrier("1:00", nil)
+	require.ErrorIs(t, err, seal.ErrInvalidCheck)
+}
+
+func TestBarrier_UnsealError(t *testing.T) {
+	t.Parallel()
+
+	keys, err := seal.Init(2, 2)
+	require.NoError(t, err)
+
+	barrier, err := seal.NewBarrier(keys.Check, func(context.Context, []byte) error {
+		return assert.AnError
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	_, err = barrier.Submit(ctx, keys.Shares[0])
+	require.NoError(t, err)
+
+	_, err = barrier.Submit(ctx, keys.Shares[1])
+	require.ErrorIs(t, err, assert.AnError)
+	assert.True(t, barrier.Sealed())
+}
@@ -3,15 +3,24 @@ package server
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/mr-filatik/go-goph-keeper/internal/common"
 	"github.com/mr-filatik/go-goph-keeper/internal/common/logger"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/blob"
 	"github.com/mr-filatik/go-goph-keeper/internal/server/config"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/crypto/envelope"
 	"github.com/mr-filatik/go-goph-keeper/internal/server/crypto/jwt"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/job"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/seal"
 	"github.com/mr-filatik/go-goph-keeper/internal/server/storage"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/upload"
 )
 
 //nolint:gochecknoglobals // подстановка линкерных флагов через -ldflags
@@ -21,6 +30,37 @@ var (
 	buildCommit  = "N/A" // Коммит сборки приложения.
 )
 
+const (
+	// tombstoneCompactInterval - интервал удаления устаревших отметок об удалении.
+	tombstoneCompactInterval = time.Hour
+
+	// revisionCompactInterval - интервал удаления устаревших прежних версий записей.
+	revisionCompactInterval = time.Hour
+
+	// trashPurgeInterval - интервал безвозвратного удаления записей из корзины.
+	trashPurgeInterval = time.Hour
+
+	// itemExpireInterval - интервал удаления записей с истёкшим сроком.
+	// До удаления такие записи уже не выдаются хранилищем.
+	itemExpireInterval = time.Minute
+
+	// blobSweepInterval - интервал удаления содержимого, на которое не ссылаются записи.
+	blobSweepInterval = time.Hour
+
+	// blobSweepDelay - время, в течение которого сохранённое содержимое не удаляется,
+	// даже если на него ещё не ссылается ни одна запись.
+	blobSweepDelay = time.Hour
+
+	// uploadExpireInterval - интервал удаления брошенных сессий загрузки содержимого.
+	uploadExpireInterval = 10 * time.Minute
+
+	// cacheStatsInterval - интервал вывода статистики кэша хранилища в журнал.
+	cacheStatsInterval = 10 * time.Minute
+
+	// shutdownTimeout - время ожидания остановки фоновых задач.
+	shutdownTimeout = 10 * time.Second
+)
+
 // IServer - интерфейс для всех серверов приложения.
 type IServer interface {
 	// Запуск сервера.
@@ -30,8 +70,36 @@ type IServer interface {
 	common.IShutdowner
 }
 
+// IAppStorage - интерфейс для хранилища, используемого приложением сервера.
+type IAppStorage interface {
+	storage.IUserStorage
+	storage.IStorage
+	storage.IKeyStorage
+	storage.IBackupStorage
+	storage.IShareStorage
+	storage.IOrgStorage
+	storage.IFolderStorage
+
+	// Метод для освобождения ресурсов хранилища.
+	io.Closer
+}
+
 // Run запускает приложение сервера.
+//
+// Поддерживаемые подкоманды:
+//   - migrate up|down|status: управление миграциями базы данных;
+//   - seal init: создание мастер-ключа запечатанного режима и его долей;
+//   - backup <file>: сохранение резервной копии данных хранилища в файл;
+//   - restore <file>: загрузка резервной копии в пустое хранилище.
 func Run() {
+	exitCode := 0
+
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
 	log, logErr := logger.NewZapSugarLogger(logger.LevelDebug, os.Stdout)
 	if logErr != nil {
 		panic(logErr)
@@ -50,6 +118,41 @@ func Run() {
 		syscall.SIGQUIT)
 	defer exitFn()
 
+	if len(os.Args) > 1 && os.Args[1] == commandMigrate {
+		if err := runMigrateCommand(exitCtx, os.Args[2:], log); err != nil {
+			log.Error("Migrate command error", err)
+
+			exitCode = 1
+		}
+
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == commandSeal {
+		if err := runSealCommand(os.Args[2:], os.Stdout); err != nil {
+			log.Error("Seal command error", err)
+
+			exitCode = 1
+		}
+
+		return
+	}
+
+	if len(os.Args) > 1 && (os.Args[1] == commandBackup || os.Args[1] == commandRestore) {
+		run := runBackupCommand
+		if os.Args[1] == commandRestore {
+			run = runRestoreCommand
+		}
+
+		if err := run(exitCtx, os.Args[2:], log); err != nil {
+			log.Error("Backup command error", err)
+
+			exitCode = 1
+		}
+
+		return
+	}
+
 	appConfig := config.Initialize()
 
 	log.Info("Application starting...",
@@ -58,18 +161,137 @@ func Run() {
 		"Build Commit", buildCommit,
 	)
 
-	encr := jwt.NewEncryptor(appConfig.CryptoJWTKey)
+	stor, storErr := createStorage(exitCtx, appConfig, log)
+	if storErr != nil {
+		log.Error("Storage creating error", storErr)
 
-	stor := storage.NewMemoryStorage()
+		exitCode = 1
+
+		return
+	}
+
+	defer func() {
+		if closeErr := stor.Close(); closeErr != nil {
+			log.Error("Storage closing error", closeErr)
+		}
+	}()
+
+	if appConfig.TombstoneRetention > 0 {
+		compactJob := newTombstoneCompactJob(stor, appConfig.TombstoneRetention, log)
+		if err := compactJob.Start(exitCtx); err != nil {
+			log.Error("Tombstone compaction starting error", err)
+		}
+
+		defer shutdownJob(compactJob, log)
+	}
+
+	if appConfig.RevisionMaxAge > 0 {
+		revisionJob := newRevisionCompactJob(stor, appConfig.RevisionMaxAge, log)
+		if err := revisionJob.Start(exitCtx); err != nil {
+			log.Error("Revision compaction starting error", err)
+		}
+
+		defer shutdownJob(revisionJob, log)
+	}
+
+	if appConfig.TrashRetention > 0 {
+		trashJob := newTrashPurgeJob(stor, appConfig.TrashRetention, log)
+		if err := trashJob.Start(exitCtx); err != nil {
+			log.Error("Trash purge starting error", err)
+		}
+
+		defer shutdownJob(trashJob, log)
+	}
+
+	expireJob := newItemExpireJob(stor, log)
+	if err := expireJob.Start(exitCtx); err != nil {
+		log.Error("Item expiration starting error", err)
+	}
+
+	defer shutdownJob(expireJob, log)
+
+	blobs, blobErr := createBlobStore(exitCtx, appConfig, log)
+	if blobErr != nil {
+		log.Error("Blob store creating error", blobErr)
+
+		exitCode = 1
 
-	var server IServer
+		return
+	}
+
+	blobJob := newBlobSweepJob(stor, blobs, log)
+	if err := blobJob.Start(exitCtx); err != nil {
+		log.Error("Blob sweep starting error", err)
+	}
+
+	defer shutdownJob(blobJob, log)
+
+	uploads, uploadErr := upload.NewStore(appConfig.UploadDir, appConfig.UploadTTL)
+	if uploadErr != nil {
+		log.Error("Upload store creating error", uploadErr)
+
+		exitCode = 1
+
+		return
+	}
+
+	if appConfig.UploadTTL > 0 {
+		uploadJob := newUploadExpireJob(uploads, log)
+		if err := uploadJob.Start(exitCtx); err != nil {
+			log.Error("Upload expiration starting error", err)
+		}
+
+		defer shutdownJob(uploadJob, log)
+	}
 
 	httpConfig := &HTTPServerConfig{
 		Address:   appConfig.ServerAddress,
-		Encryptor: encr,
+		Encryptor: nil,
+		Quota:     storageQuota(appConfig),
+
+		Blobs:       blobs,
+		BlobMaxSize: appConfig.BlobMaxSize,
+		Uploads:     uploads,
+		Barrier:     nil,
+
+		// Доступы и организации не проходят через EncryptedStorage: в доступах
+		// нет открытых данных записей (ключ записи зашифрован клиентом для
+		// получателя), а названия организаций и коллекций видны всем участникам.
+		// Названия папок шифрует encryptStorage. Резервная копия снимается
+		// с хранилища как есть, вместе с зашифрованными значениями.
+		Shares:  stor,
+		Orgs:    stor,
+		Folders: stor,
+
+		Backups:          stor,
+		AdminToken:       appConfig.AdminToken,
+		BackupPassphrase: appConfig.BackupPassphrase,
+	}
+
+	var vaultBase storage.IEncryptableStorage = stor
+
+	if appConfig.CacheSize > 0 {
+		cache := storage.NewCachedStorage(stor, appConfig.CacheSize, appConfig.CacheTTL)
+		vaultBase = cache
+
+		log.Info("Storage cache enabled", "size", appConfig.CacheSize, "ttl", appConfig.CacheTTL)
+
+		cacheJob := newCacheStatsJob(cache, log)
+		if err := cacheJob.Start(exitCtx); err != nil {
+			log.Error("Cache stats starting error", err)
+		}
+
+		defer shutdownJob(cacheJob, log)
 	}
 
-	server = NewHTTPServer(httpConfig, stor, stor, log)
+	server, serverErr := createHTTPServer(exitCtx, appConfig, httpConfig, vaultBase, log)
+	if serverErr != nil {
+		log.Error("Server creating error", serverErr)
+
+		exitCode = 1
+
+		return
+	}
 
 	startErr := server.Start(exitCtx)
 	if startErr != nil {
@@ -82,3 +304,375 @@ func Run() {
 
 	log.Info("Application shutdown starting...")
 }
+
+// newTombstoneCompactJob создаёт задачу, удаляющую отметки об удалении старше retention.
+func newTombstoneCompactJob(
+	stor storage.IStorage,
+	retention time.Duration,
+	log logger.Logger,
+) *job.Periodic {
+	return job.NewPeriodic("tombstone-compact", tombstoneCompactInterval,
+		func(ctx context.Context) error {
+			count, err := stor.CompactTombstones(ctx, time.Now().UTC().Add(-retention))
+			if err != nil {
+				return fmt.Errorf("compact tombstones: %w", err)
+			}
+
+			if count > 0 {
+				log.Info("Tombstones compacted", "count", count)
+			}
+
+			return nil
+		}, log)
+}
+
+// newRevisionCompactJob создаёт задачу, удаляющую прежние версии записей старше maxAge.
+func newRevisionCompactJob(
+	stor storage.IStorage,
+	maxAge time.Duration,
+	log logger.Logger,
+) *job.Periodic {
+	return job.NewPeriodic("revision-compact", revisionCompactInterval,
+		func(ctx context.Context) error {
+			count, err := stor.CompactRevisions(ctx, time.Now().UTC().Add(-maxAge))
+			if err != nil {
+				return fmt.Errorf("compact revisions: %w", err)
+			}
+
+			if count > 0 {
+				log.Info("Revisions compacted", "count", count)
+			}
+
+			return nil
+		}, log)
+}
+
+// newTrashPurgeJob создаёт задачу, безвозвратно удаляющую записи из корзины старше retention.
+func newTrashPurgeJob(
+	stor storage.IStorage,
+	retention time.Duration,
+	log logger.Logger,
+) *job.Periodic {
+	return job.NewPeriodic("trash-purge", trashPurgeInterval,
+		func(ctx context.Context) error {
+			count, err := stor.PurgeTrash(ctx, time.Now().UTC().Add(-retention))
+			if err != nil {
+				return fmt.Errorf("purge trash: %w", err)
+			}
+
+			if count > 0 {
+				log.Info("Trash purged", "count", count)
+			}
+
+			return nil
+		}, log)
+}
+
+// newItemExpireJob создаёт задачу, безвозвратно удаляющую записи с истёкшим сроком.
+func newItemExpireJob(stor storage.IStorage, log logger.Logger) *job.Periodic {
+	return job.NewPeriodic("item-expire", itemExpireInterval,
+		func(ctx context.Context) error {
+			count, err := stor.ExpireItems(ctx, time.Now().UTC())
+			if err != nil {
+				return fmt.Errorf("expire items: %w", err)
+			}
+
+			if count > 0 {
+				log.Info("Items expired", "count", count)
+			}
+
+			return nil
+		}, log)
+}
+
+// newBlobSweepJob создаёт задачу, удаляющую содержимое, на которое не ссылаются записи,
+// их прежние версии и записи в корзине.
+func newBlobSweepJob(
+	stor storage.IStorage,
+	blobs blob.IBlobStore,
+	log logger.Logger,
+) *job.Periodic {
+	return job.NewPeriodic("blob-sweep", blobSweepInterval,
+		func(ctx context.Context) error {
+			// Граница фиксируется до чтения ссылок: содержимое, сохранённое позже,
+			// могло ещё не попасть в запись.
+			before := time.Now().Add(-blobSweepDelay)
+
+			hashes, err := stor.ContentHashes(ctx)
+			if err != nil {
+				return fmt.Errorf("content hashes: %w", err)
+			}
+
+			referenced := make(map[string]struct{}, len(hashes))
+			for _, hash := range hashes {
+				referenced[hash] = struct{}{}
+			}
+
+			count, err := blobs.Sweep(ctx, before, func(hash string) bool {
+				_, ok := referenced[hash]
+
+				return ok
+			})
+			if err != nil {
+				return fmt.Errorf("sweep blobs: %w", err)
+			}
+
+			if count > 0 {
+				log.Info("Blobs swept", "count", count)
+			}
+
+			return nil
+		}, log)
+}
+
+// newUploadExpireJob создаёт задачу, удаляющую истёкшие сессии загрузки содержимого.
+func newUploadExpireJob(uploads *upload.Store, log logger.Logger) *job.Periodic {
+	return job.NewPeriodic("upload-expire", uploadExpireInterval,
+		func(ctx context.Context) error {
+			count, err := uploads.Expire(ctx, time.Now())
+			if err != nil {
+				return fmt.Errorf("expire uploads: %w", err)
+			}
+
+			if count > 0 {
+				log.Info("Uploads expired", "count", count)
+			}
+
+			return nil
+		}, log)
+}
+
+// newCacheStatsJob создаёт задачу, выводящую статистику кэша хранилища в журнал.
+func newCacheStatsJob(cache *storage.CachedStorage, log logger.Logger) *job.Periodic {
+	return job.NewPeriodic("cache-stats", cacheStatsInterval,
+		func(context.Context) error {
+			stats := cache.Stats()
+
+			log.Info("Storage cache stats",
+				"hits", stats.Hits,
+				"misses", stats.Misses,
+				"evictions", stats.Evictions,
+				"entries", stats.Entries,
+				"size", stats.Size,
+			)
+
+			return nil
+		}, log)
+}
+
+// shutdownJob мягко останавливает фоновую задачу.
+func shutdownJob(periodic *job.Periodic, log logger.Logger) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := periodic.Shutdown(ctx); err != nil {
+		log.Error("Job shutdown error", err)
+	}
+}
+
+// limitedStorage - хранилище с настраиваемыми ограничениями на данные пользователей.
+type limitedStorage interface {
+	SetRevisionLimit(limit int)
+	SetQuota(quota entity.Quota)
+}
+
+// configureStorage передаёт хранилищу ограничения из конфигурации приложения.
+func configureStorage(stor limitedStorage, appConfig *config.Config) {
+	stor.SetRevisionLimit(appConfig.RevisionLimit)
+	stor.SetQuota(storageQuota(appConfig))
+}
+
+// storageQuota возвращает квоту пользователя из конфигурации приложения.
+func storageQuota(appConfig *config.Config) entity.Quota {
+	return entity.Quota{
+		MaxItems:     appConfig.QuotaMaxItems,
+		MaxBytes:     appConfig.QuotaMaxBytes,
+		MaxItemBytes: appConfig.QuotaMaxItemBytes,
+	}
+}
+
+// createHTTPServer создаёт HTTP-сервер приложения.
+//
+// Если задано контрольное значение мастер-ключа, сервер создаётся запечатанным:
+// ключ подписи JWT и ключ шифрования данных получаются из мастер-ключа,
+// когда операторы передадут достаточное количество его долей.
+// Иначе используются ключи из конфигурации.
+func createHTTPServer(
+	ctx context.Context,
+	appConfig *config.Config,
+	httpConfig *HTTPServerConfig,
+	stor storage.IEncryptableStorage,
+	log logger.Logger,
+) (IServer, error) {
+	if appConfig.UnsealCheck == "" {
+		vault, folders, err := encryptStorage(ctx, stor, httpConfig.Folders,
+			appConfig.HashKey, appConfig.HashKeyPrevious, log)
+		if err != nil {
+			return nil, err
+		}
+
+		httpConfig.Encryptor = jwt.NewEncryptor(appConfig.CryptoJWTKey)
+		httpConfig.Folders = folders
+
+		return NewHTTPServer(httpConfig, vault, vault, log), nil
+	}
+
+	var httpServer *HTTPServer
+
+	barrier, err := seal.NewBarrier(appConfig.UnsealCheck, func(ctx context.Context, masterKey []byte) error {
+		hashKey := seal.DeriveKey(masterKey, seal.PurposeHashKey)
+
+		vault, folders, err := encryptStorage(ctx, stor, httpConfig.Folders, hashKey, appConfig.HashKeyPrevious, log)
+		if err != nil {
+			return err
+		}
+
+		httpServer.Unseal(jwt.NewEncryptor(seal.DeriveKey(masterKey, seal.PurposeJWTKey)), vault, vault, folders)
+		log.Info("Server unsealed")
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("seal: %w", err)
+	}
+
+	log.Info("Server is sealed, waiting for unseal shares", "threshold", barrier.Status().Threshold)
+
+	httpConfig.Barrier = barrier
+	httpServer = NewHTTPServer(httpConfig, nil, nil, log)
+
+	return httpServer, nil
+}
+
+// vaultStorage - хранилище пользователей и записей, с которым работают обработчики запросов.
+type vaultStorage interface {
+	storage.IUserStorage
+	storage.IStorage
+}
+
+// encryptStorage включает шифрование данных хранилища и названий папок folders,
+// если задан ключ хэширования (мастер-ключ), и перешифровывает им ключи данных,
+// зашифрованные прежним ключом. Без ключа хэширования хранилища используются как есть.
+func encryptStorage(
+	ctx context.Context,
+	stor storage.IEncryptableStorage,
+	folders storage.IFolderStorage,
+	hashKey, previousHashKey string,
+	log logger.Logger,
+) (vaultStorage, storage.IFolderStorage, error) {
+	if hashKey == "" {
+		log.Info("Storage encryption disabled")
+
+		return stor, folders, nil
+	}
+
+	keyring, err := envelope.NewKeyring(hashKey, previousHashKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("keyring: %w", err)
+	}
+
+	encStor := storage.NewEncryptedStorage(stor, keyring)
+
+	count, err := encStor.RotateMasterKey(ctx)
+	if err != nil {
+		return nil, nil, err //nolint:wrapcheck // ошибка уже содержит контекст
+	}
+
+	log.Info("Storage encryption enabled", "master key", keyring.CurrentID(), "rewrapped keys", count)
+
+	if folders != nil {
+		folders = storage.NewEncryptedFolderStorage(folders, encStor)
+	}
+
+	return encStor, folders, nil
+}
+
+// createBlobStore создаёт хранилище содержимого бинарных записей в зависимости
+// от конфигурации приложения: при указанном адресе S3-совместимого хранилища
+// используется его корзина, иначе - каталог на локальном диске.
+func createBlobStore(
+	ctx context.Context,
+	appConfig *config.Config,
+	log logger.Logger,
+) (blob.IBlobStore, error) {
+	if appConfig.BlobS3Endpoint != "" {
+		log.Info("Blob store creating...",
+			"type", "s3",
+			"endpoint", appConfig.BlobS3Endpoint,
+			"bucket", appConfig.BlobS3Bucket,
+		)
+
+		blobs, err := blob.NewS3Store(ctx, &blob.S3Config{
+			Client:    nil,
+			Endpoint:  appConfig.BlobS3Endpoint,
+			Bucket:    appConfig.BlobS3Bucket,
+			Region:    appConfig.BlobS3Region,
+			AccessKey: appConfig.BlobS3AccessKey,
+			SecretKey: appConfig.BlobS3SecretKey,
+			Prefix:    appConfig.BlobS3Prefix,
+			PartSize:  appConfig.BlobS3PartSize,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("s3 blob store: %w", err)
+		}
+
+		return blobs, nil
+	}
+
+	log.Info("Blob store creating...", "type", "disk", "path", appConfig.BlobDir)
+
+	blobs, err := blob.NewDiskStore(appConfig.BlobDir)
+	if err != nil {
+		return nil, fmt.Errorf("disk blob store: %w", err)
+	}
+
+	return blobs, nil
+}
+
+// createStorage создаёт хранилище в зависимости от конфигурации приложения:
+//   - при указанной строке подключения к базе данных используется PostgreSQL
+//     (с применением ожидающих миграций);
+//   - при указанном файле хранилища используется встроенное хранилище bbolt;
+//   - иначе - хранилище в памяти.
+func createStorage(
+	ctx context.Context,
+	appConfig *config.Config,
+	log logger.Logger,
+) (IAppStorage, error) {
+	if appConfig.Database != "" {
+		log.Info("Storage creating...", "type", "postgres")
+
+		if err := migrateDatabase(ctx, appConfig.Database, log); err != nil {
+			return nil, err
+		}
+
+		stor, err := storage.NewPostgresStorage(ctx, appConfig.Database)
+		if err != nil {
+			return nil, fmt.Errorf("postgres storage: %w", err)
+		}
+
+		configureStorage(stor, appConfig)
+
+		return stor, nil
+	}
+
+	if appConfig.StorageFile != "" {
+		log.Info("Storage creating...", "type", "bolt", "path", appConfig.StorageFile)
+
+		stor, err := storage.NewBoltStorage(appConfig.StorageFile)
+		if err != nil {
+			return nil, fmt.Errorf("bolt storage: %w", err)
+		}
+
+		configureStorage(stor, appConfig)
+
+		return stor, nil
+	}
+
+	log.Info("Storage creating...", "type", "memory")
+
+	stor := storage.NewMemoryStorage()
+	configureStorage(stor, appConfig)
+
+	return stor, nil
+}
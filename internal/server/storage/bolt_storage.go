@@ -0,0 +1,2826 @@
+// Package storage предоставляет функциональность хранилища.
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Названия корневых бакетов файла данных.
+var (
+	boltBucketUsers  = []byte("users")           // email (lower) -> user
+	boltBucketTokens = []byte("tokens")          // userID -> token
+	boltBucketItems  = []byte("items")           // ownerID -> (itemID -> item)
+	boltBucketTombs  = []byte("tombs")           // ownerID -> (itemID -> tombstone)
+	boltBucketRevs   = []byte("revs")            // ownerID -> (itemID -> (version -> revision))
+	boltBucketTrash  = []byte("trash")           // ownerID -> (itemID -> trash item)
+	boltBucketKeys   = []byte("keys")            // ownerID -> data key
+	boltBucketPubs   = []byte("pubkeys")         // userID -> public key
+	boltBucketShares = []byte("shares")          // ownerID \x00 itemID \x00 recipientID -> share
+	boltBucketShared = []byte("shared")          // recipientID \x00 ownerID \x00 itemID -> (пусто)
+	boltBucketOrgs   = []byte("orgs")            // orgID -> organization
+	boltBucketMember = []byte("members")         // orgID \x00 userID -> member
+	boltBucketMemOf  = []byte("memberships")     // userID \x00 orgID -> (пусто)
+	boltBucketColls  = []byte("collections")     // collectionID -> collection
+	boltBucketOrgCol = []byte("org_collections") // orgID \x00 collectionID -> (пусто)
+	boltBucketFolds  = []byte("folders")         // ownerID -> (folderID -> folder)
+)
+
+// boltLegacyUsage - бакет сохранённых объёмов данных пользователей прежних версий.
+//
+// Удаляется при открытии файла данных: объём данных подсчитывается по действующим записям.
+var boltLegacyUsage = []byte("usage")
+
+const (
+	// boltFileMode - права доступа к файлу данных.
+	boltFileMode = 0o600
+
+	// boltOpenTimeout - время ожидания блокировки файла данных другим процессом.
+	boltOpenTimeout = 5 * time.Second
+
+	// boltShareKeyParts - количество частей в ключах доступов к записям.
+	boltShareKeyParts = 3
+)
+
+// BoltStorage описывает встроенное хранилище в одном файле на диске (bbolt).
+//
+// Каждая запись выполняется в отдельной транзакции, которая фиксируется
+// с вызовом fsync, поэтому данные переживают аварийное завершение процесса.
+type BoltStorage struct {
+	db *bolt.DB
+
+	revisionLimit int
+	quota         entity.Quota
+}
+
+// NewBoltStorage создаёт и инициализирует новый экзепляр *BoltStorage.
+//
+// Параметры:
+//   - path: путь до файла данных (создаётся при отсутствии).
+func NewBoltStorage(path string) (*BoltStorage, error) {
+	//nolint:exhaustruct // остальные опции по умолчанию
+	opts := &bolt.Options{
+		Timeout: boltOpenTimeout,
+	}
+
+	db, err := bolt.Open(path, boltFileMode, opts)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+
+	db.NoSync = false
+
+	initErr := db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{
+			boltBucketUsers, boltBucketTokens, boltBucketItems, boltBucketTombs, boltBucketRevs,
+			boltBucketTrash, boltBucketKeys, boltBucketPubs, boltBucketShares, boltBucketShared,
+			boltBucketOrgs, boltBucketMember, boltBucketMemOf, boltBucketColls, boltBucketOrgCol,
+			boltBucketFolds,
+		} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return fmt.Errorf("bucket %s: %w", name, err)
+			}
+		}
+
+		if tx.Bucket(boltLegacyUsage) != nil {
+			if err := tx.DeleteBucket(boltLegacyUsage); err != nil {
+				return fmt.Errorf("bucket %s: %w", boltLegacyUsage, err)
+			}
+		}
+
+		return nil
+	})
+	if initErr != nil {
+		_ = db.Close()
+
+		return nil, fmt.Errorf("init buckets: %w", initErr)
+	}
+
+	stor := &BoltStorage{
+		db:            db,
+		revisionLimit: DefaultRevisionLimit,
+		quota:         entity.Quota{MaxItems: 0, MaxBytes: 0, MaxItemBytes: 0},
+	}
+
+	return stor, nil
+}
+
+// SetRevisionLimit задаёт количество хранимых прежних версий записи (0 - не хранить).
+//
+// Должен вызываться до начала работы с хранилищем.
+func (b *BoltStorage) SetRevisionLimit(limit int) {
+	b.revisionLimit = max(limit, 0)
+}
+
+// SetQuota задаёт ограничения на данные каждого пользователя.
+//
+// Должен вызываться до начала работы с хранилищем.
+func (b *BoltStorage) SetQuota(quota entity.Quota) {
+	b.quota = quota
+}
+
+// GetUsage получает объём данных пользователя.
+func (b *BoltStorage) GetUsage(_ context.Context, ownerID string) (*entity.Usage, error) {
+	var usage entity.Usage
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var err error
+
+		usage, err = b.wrapTx(tx).usage(ownerID)
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("usage: %w", err)
+	}
+
+	return &usage, nil
+}
+
+// Close закрывает файл данных.
+func (b *BoltStorage) Close() error {
+	if err := b.db.Close(); err != nil {
+		return fmt.Errorf("close bolt: %w", err)
+	}
+
+	return nil
+}
+
+// AddNewUser создаёт нового пользователя.
+func (b *BoltStorage) AddNewUser(_ context.Context, user *entity.User) (string, error) {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucketUsers)
+		key := []byte(strings.ToLower(user.Email))
+
+		if bucket.Get(key) != nil {
+			return ErrEntityAlreadyExists
+		}
+
+		return boltPut(bucket, key, user)
+	})
+	if err != nil {
+		return "", fmt.Errorf("user: %w", err)
+	}
+
+	return user.ID, nil
+}
+
+// FindUserByEmail производит поиск пользователя по Email.
+func (b *BoltStorage) FindUserByEmail(_ context.Context, email string) (*entity.User, error) {
+	user := &entity.User{
+		ID:           "",
+		Email:        "",
+		PasswordHash: "",
+	}
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return boltGet(tx.Bucket(boltBucketUsers), []byte(strings.ToLower(email)), user)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("user: %w", err)
+	}
+
+	return user, nil
+}
+
+// AddNewToken регистрирует новый токен.
+func (b *BoltStorage) AddNewToken(
+	_ context.Context,
+	userID string,
+	token *entity.Token,
+) (string, error) {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucketTokens)
+
+		if bucket.Get([]byte(userID)) != nil {
+			return ErrEntityAlreadyExists
+		}
+
+		return boltPut(bucket, []byte(userID), token)
+	})
+	if err != nil {
+		return "", fmt.Errorf("token: %w", err)
+	}
+
+	return "", nil
+}
+
+// IsTokenByUserID производит поиск токена для пользователя по UserID.
+func (b *BoltStorage) IsTokenByUserID(_ context.Context, userID string) bool {
+	exists := false
+
+	_ = b.db.View(func(tx *bolt.Tx) error {
+		exists = tx.Bucket(boltBucketTokens).Get([]byte(userID)) != nil
+
+		return nil
+	})
+
+	return exists
+}
+
+// DeleteToken удаляет токен.
+func (b *BoltStorage) DeleteToken(_ context.Context, userID string) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucketTokens).Delete([]byte(userID))
+	})
+	if err != nil {
+		return fmt.Errorf("token: %w", err)
+	}
+
+	return nil
+}
+
+// GetDataKey получает ключ данных владельца.
+func (b *BoltStorage) GetDataKey(_ context.Context, ownerID string) (*entity.DataKey, error) {
+	var key entity.DataKey
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return boltGet(tx.Bucket(boltBucketKeys), []byte(ownerID), &key)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("data key: %w", err)
+	}
+
+	return &key, nil
+}
+
+// AddDataKey сохраняет ключ данных владельца.
+func (b *BoltStorage) AddDataKey(_ context.Context, key *entity.DataKey) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucketKeys)
+
+		if bucket.Get([]byte(key.OwnerID)) != nil {
+			return ErrEntityAlreadyExists
+		}
+
+		return boltPut(bucket, []byte(key.OwnerID), key)
+	})
+	if err != nil {
+		return fmt.Errorf("data key: %w", err)
+	}
+
+	return nil
+}
+
+// RewrapDataKeys заменяет ключи данных результатом rewrap в одной транзакции.
+func (b *BoltStorage) RewrapDataKeys(
+	_ context.Context,
+	rewrap func(key *entity.DataKey) (*entity.DataKey, error),
+) (int, error) {
+	count := 0
+
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucketKeys)
+		replaced := make(map[string]*entity.DataKey)
+
+		err := bucket.ForEach(func(ownerID, data []byte) error {
+			var key entity.DataKey
+			if err := json.Unmarshal(data, &key); err != nil {
+				return fmt.Errorf("unmarshal: %w", err)
+			}
+
+			next, err := rewrap(&key)
+			if err != nil {
+				return fmt.Errorf("%s: %w", ownerID, err)
+			}
+
+			if next != nil {
+				key.MasterKeyID = next.MasterKeyID
+				key.Wrapped = next.Wrapped
+				replaced[string(ownerID)] = &key
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		// Бакет нельзя изменять во время обхода, поэтому ключи заменяются после него.
+		for ownerID, key := range replaced {
+			if err := boltPut(bucket, []byte(ownerID), key); err != nil {
+				return err
+			}
+		}
+
+		count = len(replaced)
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("data key: %w", err)
+	}
+
+	return count, nil
+}
+
+// SetPublicKey сохраняет открытый ключ пользователя.
+func (b *BoltStorage) SetPublicKey(_ context.Context, key *entity.PublicKey) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		return boltPut(tx.Bucket(boltBucketPubs), []byte(key.UserID), key)
+	})
+	if err != nil {
+		return fmt.Errorf("public key: %w", err)
+	}
+
+	return nil
+}
+
+// GetPublicKey получает открытый ключ пользователя.
+func (b *BoltStorage) GetPublicKey(_ context.Context, userID string) (*entity.PublicKey, error) {
+	//nolint:exhaustruct // поля заполняются при чтении
+	key := &entity.PublicKey{}
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return boltGet(tx.Bucket(boltBucketPubs), []byte(userID), key)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("public key: %w", err)
+	}
+
+	return key, nil
+}
+
+// PutShare создаёт или заменяет доступ получателя к записи.
+func (b *BoltStorage) PutShare(_ context.Context, share *entity.Share) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		return boltPutShare(tx, share)
+	})
+	if err != nil {
+		return fmt.Errorf("share: %w", err)
+	}
+
+	return nil
+}
+
+// GetShare получает доступ получателя к записи владельца.
+func (b *BoltStorage) GetShare(
+	_ context.Context,
+	ownerID, itemID, recipientID string,
+) (*entity.Share, error) {
+	//nolint:exhaustruct // поля заполняются при чтении
+	share := &entity.Share{}
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return boltGet(tx.Bucket(boltBucketShares), boltCompositeKey(ownerID, itemID, recipientID), share)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("share: %w", err)
+	}
+
+	return share, nil
+}
+
+// ListItemShares получает доступы к записи владельца.
+func (b *BoltStorage) ListItemShares(_ context.Context, ownerID, itemID string) ([]*entity.Share, error) {
+	res := make([]*entity.Share, 0)
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucketShares)
+
+		return boltForEachPrefix(bucket, boltCompositeKey(ownerID, itemID, ""), func(_, value []byte) error {
+			share, err := boltDecodeShare(value)
+			if err != nil {
+				return err
+			}
+
+			res = append(res, share)
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("shares: %w", err)
+	}
+
+	return res, nil
+}
+
+// ListSharedWith получает доступы получателя к чужим записям.
+func (b *BoltStorage) ListSharedWith(_ context.Context, recipientID string) ([]*entity.Share, error) {
+	res := make([]*entity.Share, 0)
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		shares := tx.Bucket(boltBucketShares)
+		prefix := boltCompositeKey(recipientID, "")
+
+		return boltForEachPrefix(tx.Bucket(boltBucketShared), prefix, func(key, _ []byte) error {
+			parts := strings.SplitN(string(key), "\x00", boltShareKeyParts)
+			if len(parts) != boltShareKeyParts {
+				return fmt.Errorf("bad index key %q", key)
+			}
+
+			//nolint:exhaustruct // поля заполняются при чтении
+			share := &entity.Share{}
+			if err := boltGet(shares, boltCompositeKey(parts[1], parts[2], recipientID), share); err != nil {
+				return err
+			}
+
+			res = append(res, share)
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("shares: %w", err)
+	}
+
+	return res, nil
+}
+
+// DeleteShare удаляет доступ получателя к записи владельца.
+func (b *BoltStorage) DeleteShare(_ context.Context, ownerID, itemID, recipientID string) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucketShares)
+		key := boltCompositeKey(ownerID, itemID, recipientID)
+
+		if bucket.Get(key) == nil {
+			return ErrEntityNotFound
+		}
+
+		if err := bucket.Delete(key); err != nil {
+			return fmt.Errorf("delete: %w", err)
+		}
+
+		if err := tx.Bucket(boltBucketShared).Delete(boltCompositeKey(recipientID, ownerID, itemID)); err != nil {
+			return fmt.Errorf("delete index: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("share: %w", err)
+	}
+
+	return nil
+}
+
+// CreateOrg создаёт организацию вместе с участием её создателя.
+func (b *BoltStorage) CreateOrg(_ context.Context, org *entity.Organization, owner *entity.Member) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucketOrgs)
+
+		if bucket.Get([]byte(org.ID)) != nil {
+			return ErrEntityAlreadyExists
+		}
+
+		if err := boltPut(bucket, []byte(org.ID), org); err != nil {
+			return err
+		}
+
+		return boltPutMember(tx, owner)
+	})
+	if err != nil {
+		return fmt.Errorf("org: %w", err)
+	}
+
+	return nil
+}
+
+// GetOrg получает организацию.
+func (b *BoltStorage) GetOrg(_ context.Context, orgID string) (*entity.Organization, error) {
+	//nolint:exhaustruct // поля заполняются при чтении
+	org := &entity.Organization{}
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return boltGet(tx.Bucket(boltBucketOrgs), []byte(orgID), org)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("org: %w", err)
+	}
+
+	return org, nil
+}
+
+// PutMember создаёт или заменяет участие пользователя в организации.
+func (b *BoltStorage) PutMember(_ context.Context, member *entity.Member) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		return boltPutMember(tx, member)
+	})
+	if err != nil {
+		return fmt.Errorf("member: %w", err)
+	}
+
+	return nil
+}
+
+// GetMember получает участие пользователя в организации.
+func (b *BoltStorage) GetMember(_ context.Context, orgID, userID string) (*entity.Member, error) {
+	//nolint:exhaustruct // поля заполняются при чтении
+	member := &entity.Member{}
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return boltGet(tx.Bucket(boltBucketMember), boltCompositeKey(orgID, userID), member)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("member: %w", err)
+	}
+
+	return member, nil
+}
+
+// ListMembers получает участников и приглашённых организации.
+func (b *BoltStorage) ListMembers(_ context.Context, orgID string) ([]*entity.Member, error) {
+	res := make([]*entity.Member, 0)
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		prefix := boltCompositeKey(orgID, "")
+
+		return boltForEachPrefix(tx.Bucket(boltBucketMember), prefix, func(_, value []byte) error {
+			//nolint:exhaustruct // поля заполняются при чтении
+			member := &entity.Member{}
+			if err := json.Unmarshal(value, member); err != nil {
+				return fmt.Errorf("unmarshal: %w", err)
+			}
+
+			res = append(res, member)
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("members: %w", err)
+	}
+
+	return res, nil
+}
+
+// ListMemberships получает участие пользователя в организациях.
+func (b *BoltStorage) ListMemberships(_ context.Context, userID string) ([]*entity.Member, error) {
+	res := make([]*entity.Member, 0)
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		members := tx.Bucket(boltBucketMember)
+		prefix := boltCompositeKey(userID, "")
+
+		return boltForEachPrefix(tx.Bucket(boltBucketMemOf), prefix, func(key, _ []byte) error {
+			orgID := string(bytes.TrimPrefix(key, prefix))
+
+			//nolint:exhaustruct // поля заполняются при чтении
+			member := &entity.Member{}
+			if err := boltGet(members, boltCompositeKey(orgID, userID), member); err != nil {
+				return err
+			}
+
+			res = append(res, member)
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("members: %w", err)
+	}
+
+	return res, nil
+}
+
+// DeleteMember удаляет участие пользователя в организации.
+func (b *BoltStorage) DeleteMember(_ context.Context, orgID, userID string) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucketMember)
+		key := boltCompositeKey(orgID, userID)
+
+		if bucket.Get(key) == nil {
+			return ErrEntityNotFound
+		}
+
+		if err := bucket.Delete(key); err != nil {
+			return fmt.Errorf("delete: %w", err)
+		}
+
+		if err := tx.Bucket(boltBucketMemOf).Delete(boltCompositeKey(userID, orgID)); err != nil {
+			return fmt.Errorf("delete index: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("member: %w", err)
+	}
+
+	return nil
+}
+
+// CreateCollection создаёт коллекцию организации.
+func (b *BoltStorage) CreateCollection(_ context.Context, coll *entity.Collection) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(boltBucketColls).Get([]byte(coll.ID)) != nil {
+			return ErrEntityAlreadyExists
+		}
+
+		return boltPutCollection(tx, coll)
+	})
+	if err != nil {
+		return fmt.Errorf("collection: %w", err)
+	}
+
+	return nil
+}
+
+// GetCollection получает коллекцию организации.
+func (b *BoltStorage) GetCollection(_ context.Context, orgID, collectionID string) (*entity.Collection, error) {
+	var coll *entity.Collection
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var err error
+
+		coll, err = boltGetCollection(tx, orgID, collectionID)
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("collection: %w", err)
+	}
+
+	return coll, nil
+}
+
+// ListCollections получает коллекции организации.
+func (b *BoltStorage) ListCollections(_ context.Context, orgID string) ([]*entity.Collection, error) {
+	res := make([]*entity.Collection, 0)
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		colls := tx.Bucket(boltBucketColls)
+		prefix := boltCompositeKey(orgID, "")
+
+		return boltForEachPrefix(tx.Bucket(boltBucketOrgCol), prefix, func(key, _ []byte) error {
+			//nolint:exhaustruct // поля заполняются при чтении
+			coll := &entity.Collection{}
+			if err := boltGet(colls, bytes.TrimPrefix(key, prefix), coll); err != nil {
+				return err
+			}
+
+			res = append(res, coll)
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("collections: %w", err)
+	}
+
+	return res, nil
+}
+
+// DeleteCollection удаляет коллекцию организации.
+func (b *BoltStorage) DeleteCollection(_ context.Context, orgID, collectionID string) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		if _, err := boltGetCollection(tx, orgID, collectionID); err != nil {
+			return err
+		}
+
+		if err := tx.Bucket(boltBucketColls).Delete([]byte(collectionID)); err != nil {
+			return fmt.Errorf("delete: %w", err)
+		}
+
+		if err := tx.Bucket(boltBucketOrgCol).Delete(boltCompositeKey(orgID, collectionID)); err != nil {
+			return fmt.Errorf("delete index: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("collection: %w", err)
+	}
+
+	return nil
+}
+
+// CreateFolder создаёт папку записей.
+//
+// Номер изменения выдаётся последовательностью бакета записей владельца.
+func (b *BoltStorage) CreateFolder(_ context.Context, folder *entity.Folder) error {
+	if folder.ID == "" {
+		folder.ID = uuid.New().String()
+	}
+
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		_, err := boltGetFolder(tx, folder.OwnerID, folder.ID)
+
+		switch {
+		case err == nil:
+			return ErrEntityAlreadyExists
+		case !errors.Is(err, ErrEntityNotFound):
+			return err
+		}
+
+		if err := boltCheckFolderParent(tx, folder.OwnerID, folder.ID, folder.ParentID); err != nil {
+			return err
+		}
+
+		cp := *folder
+		cp.Version = 1
+		cp.UpdatedAt = time.Now().UTC()
+		cp.Deleted = false
+
+		if err := boltPutFolder(tx, &cp); err != nil {
+			return err
+		}
+
+		*folder = cp
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("folder: %w", err)
+	}
+
+	return nil
+}
+
+// UpdateFolder переименовывает или перемещает папку записей.
+func (b *BoltStorage) UpdateFolder(_ context.Context, folder *entity.Folder) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		current, err := boltGetFolder(tx, folder.OwnerID, folder.ID)
+		if err != nil {
+			return err
+		}
+
+		if err := checkFolderVersion(current, folder.Version); err != nil {
+			return err
+		}
+
+		if err := boltCheckFolderParent(tx, folder.OwnerID, folder.ID, folder.ParentID); err != nil {
+			return err
+		}
+
+		cp := *folder
+		cp.Version = current.Version + 1
+		cp.UpdatedAt = time.Now().UTC()
+		cp.Deleted = false
+
+		if err := boltPutFolder(tx, &cp); err != nil {
+			return err
+		}
+
+		*folder = cp
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("folder: %w", err)
+	}
+
+	return nil
+}
+
+// GetFolder получает папку записей.
+func (b *BoltStorage) GetFolder(_ context.Context, ownerID, folderID string) (*entity.Folder, error) {
+	var folder *entity.Folder
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var err error
+
+		folder, err = boltGetFolder(tx, ownerID, folderID)
+		if err == nil && folder.Deleted {
+			return ErrEntityNotFound
+		}
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("folder: %w", err)
+	}
+
+	return folder, nil
+}
+
+// ListFolders получает папки записей владельца.
+func (b *BoltStorage) ListFolders(_ context.Context, ownerID string) ([]*entity.Folder, error) {
+	return b.listFolders(ownerID, func(folder *entity.Folder) bool { return !folder.Deleted })
+}
+
+// DeleteFolder удаляет папку записей, оставляя отметку об удалении.
+func (b *BoltStorage) DeleteFolder(_ context.Context, ownerID, folderID string, version int64) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		current, err := boltGetFolder(tx, ownerID, folderID)
+		if err != nil {
+			return err
+		}
+
+		if err := checkFolderVersion(current, version); err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+
+		if err := boltCheckFolderEmpty(tx, ownerID, folderID, now); err != nil {
+			return err
+		}
+
+		return boltPutFolder(tx, deletedFolder(current, 0, now))
+	})
+	if err != nil {
+		return fmt.Errorf("folder: %w", err)
+	}
+
+	return nil
+}
+
+// ListFolderChanges получает изменения папок владельца после указанного номера.
+func (b *BoltStorage) ListFolderChanges(
+	_ context.Context,
+	ownerID string,
+	afterSeq int64,
+	limit int,
+) ([]*entity.Folder, error) {
+	res, err := b.listFolders(ownerID, func(folder *entity.Folder) bool { return folder.Seq > afterSeq })
+	if err != nil {
+		return nil, err
+	}
+
+	return limitFolderChanges(res, limit), nil
+}
+
+// listFolders получает упорядоченные по ID папки владельца, подходящие под match.
+func (b *BoltStorage) listFolders(ownerID string, match func(*entity.Folder) bool) ([]*entity.Folder, error) {
+	res := make([]*entity.Folder, 0)
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		folders, err := boltOwnerFolders(tx, ownerID)
+		if err != nil {
+			return err
+		}
+
+		for _, folder := range folders {
+			if match(folder) {
+				res = append(res, folder)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sortFolders(res), nil
+}
+
+// Snapshot получает снимок всех данных хранилища в одной транзакции на чтение.
+func (b *BoltStorage) Snapshot(_ context.Context) (*entity.Snapshot, error) {
+	builder := newSnapshotBuilder()
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return boltSnapshot(tx, builder)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("snapshot: %w", err)
+	}
+
+	return builder.build(), nil
+}
+
+// Restore загружает снимок в пустое хранилище в одной транзакции.
+func (b *BoltStorage) Restore(_ context.Context, snap *entity.Snapshot) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{
+			boltBucketUsers, boltBucketTokens, boltBucketKeys, boltBucketItems, boltBucketPubs, boltBucketShares,
+			boltBucketOrgs, boltBucketMember, boltBucketColls, boltBucketFolds,
+		} {
+			if key, _ := tx.Bucket(name).Cursor().First(); key != nil {
+				return ErrStorageNotEmpty
+			}
+		}
+
+		for _, user := range snap.Users {
+			if err := boltPut(tx.Bucket(boltBucketUsers), []byte(strings.ToLower(user.Email)), user); err != nil {
+				return err
+			}
+		}
+
+		for _, userID := range snap.Tokens {
+			if err := boltPut(tx.Bucket(boltBucketTokens), []byte(userID), &entity.Token{}); err != nil {
+				return err
+			}
+		}
+
+		for _, key := range snap.Keys {
+			if err := boltPut(tx.Bucket(boltBucketKeys), []byte(key.OwnerID), key); err != nil {
+				return err
+			}
+		}
+
+		for _, key := range snap.PublicKeys {
+			if err := boltPut(tx.Bucket(boltBucketPubs), []byte(key.UserID), key); err != nil {
+				return err
+			}
+		}
+
+		for _, share := range snap.Shares {
+			if err := boltPutShare(tx, share); err != nil {
+				return err
+			}
+		}
+
+		for _, org := range snap.Orgs {
+			if err := boltPut(tx.Bucket(boltBucketOrgs), []byte(org.ID), org); err != nil {
+				return err
+			}
+		}
+
+		for _, member := range snap.Members {
+			if err := boltPutMember(tx, member); err != nil {
+				return err
+			}
+		}
+
+		for _, coll := range snap.Collections {
+			if err := boltPutCollection(tx, coll); err != nil {
+				return err
+			}
+		}
+
+		for _, vault := range snap.Vaults {
+			if err := boltRestoreVault(tx, vault); err != nil {
+				return fmt.Errorf("%s: %w", vault.OwnerID, err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+
+	return nil
+}
+
+// CreateItem создаёт новую запись с паролем.
+func (b *BoltStorage) CreateItem(_ context.Context, item *entity.VaultItem) (string, error) {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		_, err := b.wrapTx(tx).createItem(item)
+
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("item: %w", err)
+	}
+
+	return item.ID, nil
+}
+
+// UpdateItem обновляет текущую запись с паролем.
+func (b *BoltStorage) UpdateItem(_ context.Context, item *entity.VaultItem) error {
+	var stored *entity.VaultItem
+
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		var err error
+
+		stored, err = b.wrapTx(tx).updateItem(item)
+
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("item: %w", err)
+	}
+
+	setStoredState(item, stored)
+
+	return nil
+}
+
+// UpsertItem обновляет или обновляет текущую запись с паролем для синхронизации.
+func (b *BoltStorage) UpsertItem(_ context.Context, item *entity.VaultItem) (string, error) {
+	var stored *entity.VaultItem
+
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		var err error
+
+		stored, err = b.wrapTx(tx).upsert(item)
+
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("item: %w", err)
+	}
+
+	setStoredState(item, stored)
+
+	return item.ID, nil
+}
+
+// GetItem получает текущую запись с паролем по ID.
+func (b *BoltStorage) GetItem(
+	_ context.Context,
+	ownerID, itemID string,
+) (*entity.VaultItem, error) {
+	//nolint:exhaustruct // поля заполняются при чтении
+	item := &entity.VaultItem{}
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucketItems).Bucket([]byte(ownerID))
+		if bucket == nil {
+			return ErrEntityNotFound
+		}
+
+		if err := boltGet(bucket, []byte(itemID), item); err != nil {
+			return err
+		}
+
+		if item.IsExpired(time.Now()) {
+			return ErrEntityNotFound
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("item: %w", err)
+	}
+
+	item.OwnerID = ownerID
+
+	return item, nil
+}
+
+// ListItems получает список записей с паролями по пользователю.
+func (b *BoltStorage) ListItems(_ context.Context, ownerID string) ([]*entity.VaultItem, error) {
+	now := time.Now()
+
+	return b.listItems(ownerID, func(item *entity.VaultItem) bool { return !item.IsExpired(now) })
+}
+
+// QueryItems получает записи пользователя по условиям выборки.
+//
+// Отбор и сортировка выполняются в памяти после чтения записей пользователя.
+func (b *BoltStorage) QueryItems(
+	_ context.Context,
+	ownerID string,
+	query *entity.ItemQuery,
+) ([]*entity.VaultItem, error) {
+	now := time.Now()
+
+	items, err := b.listItems(ownerID, func(item *entity.VaultItem) bool {
+		return !item.IsExpired(now) && matchItem(item, query)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return queryItems(items, query), nil
+}
+
+// ListTags получает метки записей пользователя с количеством записей.
+//
+// Записи подсчитываются в памяти после чтения записей пользователя.
+func (b *BoltStorage) ListTags(_ context.Context, ownerID string) ([]entity.TagCount, error) {
+	now := time.Now()
+
+	items, err := b.listItems(ownerID, func(item *entity.VaultItem) bool {
+		return !item.IsExpired(now) && len(item.Tags) > 0
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return countTags(items), nil
+}
+
+// SetItemContent заменяет содержимое бинарной записи.
+func (b *BoltStorage) SetItemContent(
+	_ context.Context,
+	ownerID, itemID string,
+	version int64,
+	content *entity.Content,
+) (*entity.VaultItem, error) {
+	var updated *entity.VaultItem
+
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucketItems).Bucket([]byte(ownerID))
+		if bucket == nil {
+			return ErrEntityNotFound
+		}
+
+		//nolint:exhaustruct // поля заполняются при чтении
+		current := &entity.VaultItem{}
+		if err := boltGet(bucket, []byte(itemID), current); err != nil {
+			return err
+		}
+
+		current.OwnerID = ownerID
+
+		var err error
+
+		updated, err = b.wrapTx(tx).updateItem(contentUpdate(current, version, content))
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("item: %w", err)
+	}
+
+	return updated, nil
+}
+
+// ContentHashes получает хеши содержимого, на которое ссылаются данные хранилища.
+func (b *BoltStorage) ContentHashes(_ context.Context) ([]string, error) {
+	hashes := make(map[string]struct{})
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		err := boltWalk(tx.Bucket(boltBucketItems), func(value []byte) error {
+			//nolint:exhaustruct // поля заполняются при чтении
+			item := &entity.VaultItem{}
+			if err := json.Unmarshal(value, item); err != nil {
+				return fmt.Errorf("unmarshal: %w", err)
+			}
+
+			collectContentHash(hashes, item)
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		err = boltWalk(tx.Bucket(boltBucketRevs), func(value []byte) error {
+			rev, err := boltDecodeRevision(value, "")
+			if err != nil {
+				return err
+			}
+
+			collectContentHash(hashes, rev.Item)
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		return boltWalk(tx.Bucket(boltBucketTrash), func(value []byte) error {
+			trashed, err := boltDecodeTrashItem(value, "")
+			if err != nil {
+				return err
+			}
+
+			collectContentHash(hashes, trashed.Item)
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("content hashes: %w", err)
+	}
+
+	return sortedHashes(hashes), nil
+}
+
+// DeleteItem удаляет текущую запись с паролем по ID.
+func (b *BoltStorage) DeleteItem(_ context.Context, ownerID, itemID string) error {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		_, err := b.wrapTx(tx).remove(ownerID, itemID, 0)
+
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("item: %w", err)
+	}
+
+	return nil
+}
+
+// ListTrash получает записи пользователя в корзине.
+func (b *BoltStorage) ListTrash(_ context.Context, ownerID string) ([]*entity.TrashItem, error) {
+	res := make([]*entity.TrashItem, 0)
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucketTrash).Bucket([]byte(ownerID))
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(_, value []byte) error {
+			trashed, err := boltDecodeTrashItem(value, ownerID)
+			if err != nil {
+				return err
+			}
+
+			res = append(res, trashed)
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("trash: %w", err)
+	}
+
+	return sortTrash(res), nil
+}
+
+// RestoreTrash восстанавливает запись из корзины.
+func (b *BoltStorage) RestoreTrash(
+	_ context.Context,
+	ownerID, itemID string,
+) (*entity.VaultItem, error) {
+	var restored *entity.VaultItem
+
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucketTrash).Bucket([]byte(ownerID))
+		if bucket == nil {
+			return ErrEntityNotFound
+		}
+
+		value := bucket.Get([]byte(itemID))
+		if value == nil {
+			return ErrEntityNotFound
+		}
+
+		trashed, err := boltDecodeTrashItem(value, ownerID)
+		if err != nil {
+			return err
+		}
+
+		if err := checkNotExpired(trashed.Item.ExpiresAt, time.Now()); err != nil {
+			return err
+		}
+
+		restored, err = b.wrapTx(tx).createItem(restoredItem(trashed, ownerID))
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("trash: %w", err)
+	}
+
+	return restored, nil
+}
+
+// EmptyTrash безвозвратно удаляет записи из корзины пользователя.
+func (b *BoltStorage) EmptyTrash(_ context.Context, ownerID string) (int, error) {
+	count := 0
+
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		var err error
+
+		count, err = boltPurgeTrash(tx, []byte(ownerID), func(*entity.TrashItem) bool {
+			return true
+		})
+
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("trash: %w", err)
+	}
+
+	return count, nil
+}
+
+// PurgeTrash безвозвратно удаляет записи, помещённые в корзину раньше before.
+func (b *BoltStorage) PurgeTrash(_ context.Context, before time.Time) (int, error) {
+	count := 0
+
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		var err error
+
+		count, err = boltPurgeAllTrash(tx, func(trashed *entity.TrashItem) bool {
+			return trashed.DeletedAt.Before(before)
+		})
+
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("trash: %w", err)
+	}
+
+	return count, nil
+}
+
+// ExpireItems удаляет записи с истёкшим сроком.
+func (b *BoltStorage) ExpireItems(_ context.Context, now time.Time) (int, error) {
+	count := 0
+
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		expired, err := boltExpiredItems(tx, now)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range expired {
+			if err := b.wrapTx(tx).expire(item); err != nil {
+				return err
+			}
+		}
+
+		purged, err := boltPurgeAllTrash(tx, func(trashed *entity.TrashItem) bool {
+			return trashed.Item.IsExpired(now)
+		})
+		if err != nil {
+			return fmt.Errorf("trash: %w", err)
+		}
+
+		count = len(expired) + purged
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("expire: %w", err)
+	}
+
+	return count, nil
+}
+
+// ListRevisions получает прежние версии записи, начиная с последней.
+func (b *BoltStorage) ListRevisions(
+	_ context.Context,
+	ownerID, itemID string,
+) ([]*entity.Revision, error) {
+	res := make([]*entity.Revision, 0)
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := boltRevisionBucket(tx, ownerID, itemID)
+		if bucket == nil {
+			return nil
+		}
+
+		cursor := bucket.Cursor()
+
+		for key, value := cursor.Last(); key != nil; key, value = cursor.Prev() {
+			rev, err := boltDecodeRevision(value, ownerID)
+			if err != nil {
+				return err
+			}
+
+			res = append(res, rev)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("revisions: %w", err)
+	}
+
+	return res, nil
+}
+
+// GetRevision получает прежнюю версию записи по номеру версии.
+func (b *BoltStorage) GetRevision(
+	_ context.Context,
+	ownerID, itemID string,
+	version int64,
+) (*entity.Revision, error) {
+	var rev *entity.Revision
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := boltRevisionBucket(tx, ownerID, itemID)
+		if bucket == nil {
+			return ErrEntityNotFound
+		}
+
+		value := bucket.Get(boltVersionKey(version))
+		if value == nil {
+			return ErrEntityNotFound
+		}
+
+		var err error
+
+		rev, err = boltDecodeRevision(value, ownerID)
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("revision: %w", err)
+	}
+
+	return rev, nil
+}
+
+// CompactRevisions удаляет прежние версии, заменённые раньше before.
+func (b *BoltStorage) CompactRevisions(_ context.Context, before time.Time) (int, error) {
+	count := 0
+
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		root := tx.Bucket(boltBucketRevs)
+
+		return root.ForEachBucket(func(ownerID []byte) error {
+			owner := root.Bucket(ownerID)
+
+			return owner.ForEachBucket(func(itemID []byte) error {
+				bucket := owner.Bucket(itemID)
+				expired := make([][]byte, 0)
+
+				err := bucket.ForEach(func(key, value []byte) error {
+					rev, err := boltDecodeRevision(value, string(ownerID))
+					if err != nil {
+						return err
+					}
+
+					if rev.CreatedAt.Before(before) {
+						expired = append(expired, key)
+					}
+
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+
+				for _, key := range expired {
+					if err := bucket.Delete(key); err != nil {
+						return fmt.Errorf("delete: %w", err)
+					}
+
+					count++
+				}
+
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return count, fmt.Errorf("revisions: %w", err)
+	}
+
+	return count, nil
+}
+
+// ApplyChanges применяет пакет изменений пользователя в одной транзакции.
+func (b *BoltStorage) ApplyChanges(
+	_ context.Context,
+	ownerID string,
+	changes []*entity.Change,
+) ([]*entity.ChangeResult, error) {
+	var results []*entity.ChangeResult
+
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		var err error
+
+		results, err = applyChanges(ownerID, changes, b.wrapTx(tx))
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("changes: %w", err)
+	}
+
+	return results, nil
+}
+
+// ListChanges получает изменения пользователя после указанного номера.
+//
+// Номера изменений выдаются последовательностью бакета записей пользователя.
+func (b *BoltStorage) ListChanges(
+	_ context.Context,
+	ownerID string,
+	afterSeq int64,
+	limit int,
+) ([]*entity.VaultItem, error) {
+	res, err := b.listItems(ownerID, func(it *entity.VaultItem) bool {
+		return it.Seq > afterSeq
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.forEachTombstone(ownerID, func(tomb *entity.Tombstone) error {
+		if tomb.Seq > afterSeq {
+			res = append(res, tomb.AsItem())
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("tombstones: %w", err)
+	}
+
+	return limitChanges(res, limit), nil
+}
+
+// CompactTombstones удаляет отметки об удалении записей и папок, созданные раньше before.
+func (b *BoltStorage) CompactTombstones(_ context.Context, before time.Time) (int, error) {
+	count := 0
+
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		root := tx.Bucket(boltBucketTombs)
+
+		err := root.ForEachBucket(func(ownerID []byte) error {
+			bucket := root.Bucket(ownerID)
+			expired := make([][]byte, 0)
+
+			err := bucket.ForEach(func(key, value []byte) error {
+				//nolint:exhaustruct // поля заполняются при чтении
+				tomb := &entity.Tombstone{}
+				if err := json.Unmarshal(value, tomb); err != nil {
+					return fmt.Errorf("unmarshal: %w", err)
+				}
+
+				if tomb.DeletedAt.Before(before) {
+					expired = append(expired, key)
+				}
+
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			for _, key := range expired {
+				if err := bucket.Delete(key); err != nil {
+					return fmt.Errorf("delete: %w", err)
+				}
+
+				count++
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		purged, err := boltCompactFolders(tx, before)
+		count += purged
+
+		return err
+	})
+	if err != nil {
+		return count, fmt.Errorf("tombstones: %w", err)
+	}
+
+	return count, nil
+}
+
+// boltCompactFolders удаляет отметки об удалении папок, созданные раньше before.
+// Возвращает количество удалённых отметок.
+func boltCompactFolders(tx *bolt.Tx, before time.Time) (int, error) {
+	count := 0
+
+	err := boltForEachOwner(tx.Bucket(boltBucketFolds), func(ownerID string, bucket *bolt.Bucket) error {
+		expired := make([][]byte, 0)
+
+		err := bucket.ForEach(func(key, value []byte) error {
+			folder, err := boltDecodeFolder(value, ownerID)
+			if err != nil {
+				return err
+			}
+
+			if folder.Deleted && folder.UpdatedAt.Before(before) {
+				expired = append(expired, key)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range expired {
+			if err := bucket.Delete(key); err != nil {
+				return fmt.Errorf("delete: %w", err)
+			}
+
+			count++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return count, fmt.Errorf("folders: %w", err)
+	}
+
+	return count, nil
+}
+
+// forEachTombstone перебирает отметки об удалении пользователя.
+func (b *BoltStorage) forEachTombstone(ownerID string, fn func(*entity.Tombstone) error) error {
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucketTombs).Bucket([]byte(ownerID))
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(_, value []byte) error {
+			//nolint:exhaustruct // поля заполняются при чтении
+			tomb := &entity.Tombstone{}
+			if err := json.Unmarshal(value, tomb); err != nil {
+				return fmt.Errorf("unmarshal: %w", err)
+			}
+
+			tomb.OwnerID = ownerID
+
+			return fn(tomb)
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("view: %w", err)
+	}
+
+	return nil
+}
+
+// boltTakeTombstone проверяет отметку об удалении перед повторным созданием записи
+// и удаляет её. Возвращает версию, которую должна получить новая запись.
+func boltTakeTombstone(tx *bolt.Tx, item *entity.VaultItem) (int64, error) {
+	bucket := tx.Bucket(boltBucketTombs).Bucket([]byte(item.OwnerID))
+	if bucket == nil {
+		return 1, nil
+	}
+
+	//nolint:exhaustruct // поля заполняются при чтении
+	tomb := &entity.Tombstone{}
+	if err := boltGet(bucket, []byte(item.ID), tomb); err != nil {
+		if errors.Is(err, ErrEntityNotFound) {
+			return 1, nil
+		}
+
+		return 0, err
+	}
+
+	if item.Version != 0 && item.Version != tomb.Version {
+		return 0, ErrVersionConflict
+	}
+
+	if err := bucket.Delete([]byte(item.ID)); err != nil {
+		return 0, fmt.Errorf("delete tombstone: %w", err)
+	}
+
+	return tomb.Version + 1, nil
+}
+
+// boltPurgeAllTrash удаляет из корзин всех пользователей подходящие записи
+// и их прежние версии.
+func boltPurgeAllTrash(tx *bolt.Tx, match func(*entity.TrashItem) bool) (int, error) {
+	owners := make([][]byte, 0)
+
+	err := tx.Bucket(boltBucketTrash).ForEachBucket(func(ownerID []byte) error {
+		owners = append(owners, ownerID)
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("owners: %w", err)
+	}
+
+	count := 0
+
+	for _, ownerID := range owners {
+		purged, err := boltPurgeTrash(tx, ownerID, match)
+		if err != nil {
+			return 0, err
+		}
+
+		count += purged
+	}
+
+	return count, nil
+}
+
+// boltExpiredItems возвращает записи всех пользователей, срок которых истёк к моменту now.
+func boltExpiredItems(tx *bolt.Tx, now time.Time) ([]*entity.VaultItem, error) {
+	items := tx.Bucket(boltBucketItems)
+	res := make([]*entity.VaultItem, 0)
+
+	err := items.ForEachBucket(func(ownerID []byte) error {
+		return items.Bucket(ownerID).ForEach(func(_, value []byte) error {
+			//nolint:exhaustruct // поля заполняются при чтении
+			item := &entity.VaultItem{}
+			if err := json.Unmarshal(value, item); err != nil {
+				return fmt.Errorf("unmarshal: %w", err)
+			}
+
+			item.OwnerID = string(ownerID)
+
+			if item.IsExpired(now) {
+				res = append(res, item)
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("items: %w", err)
+	}
+
+	return res, nil
+}
+
+// boltPurgeTrash удаляет из корзины пользователя подходящие записи и их прежние версии.
+func boltPurgeTrash(tx *bolt.Tx, ownerID []byte, match func(*entity.TrashItem) bool) (int, error) {
+	bucket := tx.Bucket(boltBucketTrash).Bucket(ownerID)
+	if bucket == nil {
+		return 0, nil
+	}
+
+	purged := make([][]byte, 0)
+
+	err := bucket.ForEach(func(key, value []byte) error {
+		trashed, err := boltDecodeTrashItem(value, string(ownerID))
+		if err != nil {
+			return err
+		}
+
+		if match(trashed) {
+			purged = append(purged, key)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	revs := tx.Bucket(boltBucketRevs).Bucket(ownerID)
+
+	for _, key := range purged {
+		if err := bucket.Delete(key); err != nil {
+			return 0, fmt.Errorf("delete: %w", err)
+		}
+
+		if err := boltDeleteItemShares(tx, string(ownerID), string(key)); err != nil {
+			return 0, err
+		}
+
+		if revs == nil {
+			continue
+		}
+
+		err := revs.DeleteBucket(key)
+		if err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+			return 0, fmt.Errorf("delete revisions: %w", err)
+		}
+	}
+
+	return len(purged), nil
+}
+
+// boltSnapshot считывает все данные файла в снимок.
+func boltSnapshot(tx *bolt.Tx, builder *snapshotBuilder) error {
+	snap := builder.snap
+
+	err := tx.Bucket(boltBucketUsers).ForEach(func(_, value []byte) error {
+		//nolint:exhaustruct // поля заполняются при чтении
+		user := &entity.User{}
+		if err := json.Unmarshal(value, user); err != nil {
+			return fmt.Errorf("unmarshal: %w", err)
+		}
+
+		snap.Users = append(snap.Users, user)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("users: %w", err)
+	}
+
+	_ = tx.Bucket(boltBucketTokens).ForEach(func(userID, _ []byte) error {
+		snap.Tokens = append(snap.Tokens, string(userID))
+
+		return nil
+	})
+
+	err = tx.Bucket(boltBucketKeys).ForEach(func(_, value []byte) error {
+		//nolint:exhaustruct // поля заполняются при чтении
+		key := &entity.DataKey{}
+		if err := json.Unmarshal(value, key); err != nil {
+			return fmt.Errorf("unmarshal: %w", err)
+		}
+
+		snap.Keys = append(snap.Keys, key)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("keys: %w", err)
+	}
+
+	err = tx.Bucket(boltBucketPubs).ForEach(func(_, value []byte) error {
+		//nolint:exhaustruct // поля заполняются при чтении
+		key := &entity.PublicKey{}
+		if err := json.Unmarshal(value, key); err != nil {
+			return fmt.Errorf("unmarshal: %w", err)
+		}
+
+		snap.PublicKeys = append(snap.PublicKeys, key)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("public keys: %w", err)
+	}
+
+	err = tx.Bucket(boltBucketShares).ForEach(func(_, value []byte) error {
+		share, err := boltDecodeShare(value)
+		if err != nil {
+			return err
+		}
+
+		snap.Shares = append(snap.Shares, share)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("shares: %w", err)
+	}
+
+	if err := boltSnapshotOrgs(tx, snap); err != nil {
+		return err
+	}
+
+	return boltSnapshotVaults(tx, builder)
+}
+
+// boltSnapshotOrgs считывает организации, их участников и коллекции в снимок.
+func boltSnapshotOrgs(tx *bolt.Tx, snap *entity.Snapshot) error {
+	err := tx.Bucket(boltBucketOrgs).ForEach(func(_, value []byte) error {
+		//nolint:exhaustruct // поля заполняются при чтении
+		org := &entity.Organization{}
+		if err := json.Unmarshal(value, org); err != nil {
+			return fmt.Errorf("unmarshal: %w", err)
+		}
+
+		snap.Orgs = append(snap.Orgs, org)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("orgs: %w", err)
+	}
+
+	err = tx.Bucket(boltBucketMember).ForEach(func(_, value []byte) error {
+		//nolint:exhaustruct // поля заполняются при чтении
+		member := &entity.Member{}
+		if err := json.Unmarshal(value, member); err != nil {
+			return fmt.Errorf("unmarshal: %w", err)
+		}
+
+		snap.Members = append(snap.Members, member)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("members: %w", err)
+	}
+
+	err = tx.Bucket(boltBucketColls).ForEach(func(_, value []byte) error {
+		//nolint:exhaustruct // поля заполняются при чтении
+		coll := &entity.Collection{}
+		if err := json.Unmarshal(value, coll); err != nil {
+			return fmt.Errorf("unmarshal: %w", err)
+		}
+
+		snap.Collections = append(snap.Collections, coll)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("collections: %w", err)
+	}
+
+	return nil
+}
+
+// boltSnapshotVaults считывает данные пользователей в снимок.
+func boltSnapshotVaults(tx *bolt.Tx, builder *snapshotBuilder) error {
+	err := boltForEachOwner(tx.Bucket(boltBucketItems), func(ownerID string, bucket *bolt.Bucket) error {
+		vault := builder.vault(ownerID)
+		vault.Seq = int64(bucket.Sequence()) //nolint:gosec // последовательность не достигает math.MaxInt64
+
+		return bucket.ForEach(func(_, value []byte) error {
+			//nolint:exhaustruct // поля заполняются при чтении
+			item := &entity.VaultItem{}
+			if err := json.Unmarshal(value, item); err != nil {
+				return fmt.Errorf("unmarshal: %w", err)
+			}
+
+			item.OwnerID = ownerID
+			vault.Items = append(vault.Items, item)
+
+			return nil
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("items: %w", err)
+	}
+
+	err = boltForEachOwner(tx.Bucket(boltBucketTombs), func(ownerID string, bucket *bolt.Bucket) error {
+		vault := builder.vault(ownerID)
+
+		return bucket.ForEach(func(_, value []byte) error {
+			//nolint:exhaustruct // поля заполняются при чтении
+			tomb := &entity.Tombstone{}
+			if err := json.Unmarshal(value, tomb); err != nil {
+				return fmt.Errorf("unmarshal: %w", err)
+			}
+
+			tomb.OwnerID = ownerID
+			vault.Tombstones = append(vault.Tombstones, tomb)
+
+			return nil
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("tombstones: %w", err)
+	}
+
+	err = boltForEachOwner(tx.Bucket(boltBucketRevs), func(ownerID string, bucket *bolt.Bucket) error {
+		vault := builder.vault(ownerID)
+
+		return boltWalk(bucket, func(value []byte) error {
+			rev, err := boltDecodeRevision(value, ownerID)
+			if err != nil {
+				return err
+			}
+
+			vault.Revisions = append(vault.Revisions, rev)
+
+			return nil
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("revisions: %w", err)
+	}
+
+	err = boltForEachOwner(tx.Bucket(boltBucketTrash), func(ownerID string, bucket *bolt.Bucket) error {
+		vault := builder.vault(ownerID)
+
+		return bucket.ForEach(func(_, value []byte) error {
+			trashed, err := boltDecodeTrashItem(value, ownerID)
+			if err != nil {
+				return err
+			}
+
+			vault.Trash = append(vault.Trash, trashed)
+
+			return nil
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("trash: %w", err)
+	}
+
+	err = boltForEachOwner(tx.Bucket(boltBucketFolds), func(ownerID string, bucket *bolt.Bucket) error {
+		vault := builder.vault(ownerID)
+
+		return bucket.ForEach(func(_, value []byte) error {
+			folder, err := boltDecodeFolder(value, ownerID)
+			if err != nil {
+				return err
+			}
+
+			vault.Folders = append(vault.Folders, folder)
+
+			return nil
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("folders: %w", err)
+	}
+
+	return nil
+}
+
+// boltRestoreVault сохраняет данные пользователя из снимка.
+func boltRestoreVault(tx *bolt.Tx, vault *entity.VaultSnapshot) error {
+	seq, err := restoredVault(vault)
+	if err != nil {
+		return err
+	}
+
+	owner := []byte(vault.OwnerID)
+
+	items, err := tx.Bucket(boltBucketItems).CreateBucket(owner)
+	if err != nil {
+		return fmt.Errorf("owner bucket: %w", err)
+	}
+
+	if err := items.SetSequence(uint64(seq)); err != nil { //nolint:gosec // номера неотрицательны
+		return fmt.Errorf("set seq: %w", err)
+	}
+
+	for _, item := range vault.Items {
+		if err := boltPut(items, []byte(item.ID), item); err != nil {
+			return err
+		}
+	}
+
+	tombs, err := tx.Bucket(boltBucketTombs).CreateBucketIfNotExists(owner)
+	if err != nil {
+		return fmt.Errorf("owner bucket: %w", err)
+	}
+
+	for _, tomb := range vault.Tombstones {
+		if err := boltPut(tombs, []byte(tomb.ID), tomb); err != nil {
+			return err
+		}
+	}
+
+	revs, err := tx.Bucket(boltBucketRevs).CreateBucketIfNotExists(owner)
+	if err != nil {
+		return fmt.Errorf("owner bucket: %w", err)
+	}
+
+	for _, rev := range vault.Revisions {
+		bucket, err := revs.CreateBucketIfNotExists([]byte(rev.Item.ID))
+		if err != nil {
+			return fmt.Errorf("item bucket: %w", err)
+		}
+
+		if err := boltPut(bucket, boltVersionKey(rev.Item.Version), rev); err != nil {
+			return err
+		}
+	}
+
+	trash, err := tx.Bucket(boltBucketTrash).CreateBucketIfNotExists(owner)
+	if err != nil {
+		return fmt.Errorf("owner bucket: %w", err)
+	}
+
+	for _, trashed := range vault.Trash {
+		if err := boltPut(trash, []byte(trashed.Item.ID), trashed); err != nil {
+			return err
+		}
+	}
+
+	folders, err := tx.Bucket(boltBucketFolds).CreateBucketIfNotExists(owner)
+	if err != nil {
+		return fmt.Errorf("owner bucket: %w", err)
+	}
+
+	for _, folder := range vault.Folders {
+		if err := boltPut(folders, []byte(folder.ID), folder); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// boltForEachOwner перебирает вложенные бакеты пользователей корневого бакета.
+func boltForEachOwner(root *bolt.Bucket, fn func(ownerID string, bucket *bolt.Bucket) error) error {
+	return root.ForEach(func(key, value []byte) error {
+		if value != nil {
+			return nil
+		}
+
+		return fn(string(key), root.Bucket(key))
+	})
+}
+
+// boltTx выполняет операции с записями в рамках открытой транзакции на запись.
+type boltTx struct {
+	tx            *bolt.Tx
+	revisionLimit int
+	quota         entity.Quota
+}
+
+// wrapTx оборачивает транзакцию для операций с записями.
+func (b *BoltStorage) wrapTx(tx *bolt.Tx) *boltTx {
+	return &boltTx{
+		tx:            tx,
+		revisionLimit: b.revisionLimit,
+		quota:         b.quota,
+	}
+}
+
+// createItem создаёт запись.
+func (t *boltTx) createItem(item *entity.VaultItem) (*entity.VaultItem, error) {
+	if item.ID == "" {
+		item.ID = uuid.New().String()
+	}
+
+	bucket, err := t.tx.Bucket(boltBucketItems).CreateBucketIfNotExists([]byte(item.OwnerID))
+	if err != nil {
+		return nil, fmt.Errorf("owner bucket: %w", err)
+	}
+
+	if bucket.Get([]byte(item.ID)) != nil {
+		return nil, ErrEntityAlreadyExists
+	}
+
+	usage, err := t.usage(item.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkQuota(t.quota, usage, 1, item.UsageSize(), item.Size()); err != nil {
+		return nil, err
+	}
+
+	version, err := boltTakeTombstone(t.tx, item)
+	if err != nil {
+		return nil, err
+	}
+
+	if trash := t.tx.Bucket(boltBucketTrash).Bucket([]byte(item.OwnerID)); trash != nil {
+		if err := trash.Delete([]byte(item.ID)); err != nil {
+			return nil, fmt.Errorf("delete trash: %w", err)
+		}
+	}
+
+	seq, err := boltNextSeq(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	cl := *item
+	cl.Content = resolveContent(item.Content, nil)
+	cl.Attachments = resolveAttachments(item.Attachments, nil)
+	cl.Version = version
+	cl.UpdatedAt = time.Now().UTC()
+	cl.Seq = seq
+
+	if err := boltPut(bucket, []byte(cl.ID), &cl); err != nil {
+		return nil, err
+	}
+
+	item.Content = cl.Content
+	item.Attachments = cl.Attachments
+	item.Version = cl.Version
+	item.UpdatedAt = cl.UpdatedAt
+	item.Seq = cl.Seq
+
+	return &cl, nil
+}
+
+// updateItem обновляет запись.
+func (t *boltTx) updateItem(item *entity.VaultItem) (*entity.VaultItem, error) {
+	bucket := t.tx.Bucket(boltBucketItems).Bucket([]byte(item.OwnerID))
+	if bucket == nil {
+		return nil, ErrEntityNotFound
+	}
+
+	//nolint:exhaustruct // поля заполняются при чтении
+	old := &entity.VaultItem{}
+	if err := boltGet(bucket, []byte(item.ID), old); err != nil {
+		return nil, err
+	}
+
+	if err := checkNotExpired(old.ExpiresAt, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if item.Version != 0 && item.Version != old.Version {
+		return nil, ErrVersionConflict
+	}
+
+	usage, err := t.usage(item.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+
+	newIt := updatedItem(item, old.Content, old.Attachments)
+	delta := newIt.UsageSize() - old.UsageSize()
+
+	if err := checkQuota(t.quota, usage, 0, delta, newIt.Size()); err != nil {
+		return nil, err
+	}
+
+	old.OwnerID = item.OwnerID
+	if err := t.keepRevision(old); err != nil {
+		return nil, err
+	}
+
+	seq, err := boltNextSeq(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	newIt.Version = old.Version + 1
+	newIt.UpdatedAt = time.Now().UTC()
+	newIt.Seq = seq
+
+	if err := boltPut(bucket, []byte(newIt.ID), newIt); err != nil {
+		return nil, err
+	}
+
+	return newIt, nil
+}
+
+// upsert обновляет запись или создаёт её при отсутствии.
+func (t *boltTx) upsert(item *entity.VaultItem) (*entity.VaultItem, error) {
+	if item.ID == "" {
+		return t.createItem(item)
+	}
+
+	res, err := t.updateItem(item)
+	if errors.Is(err, ErrEntityNotFound) && !errors.Is(err, errItemExpired) {
+		return t.createItem(item)
+	}
+
+	return res, err
+}
+
+// remove удаляет запись и оставляет отметку об удалении.
+// Запись с истёкшим сроком считается уже удалённой: её удаляет фоновая задача.
+func (t *boltTx) remove(ownerID, itemID string, version int64) (*entity.VaultItem, error) {
+	bucket := t.tx.Bucket(boltBucketItems).Bucket([]byte(ownerID))
+	if bucket == nil {
+		return nil, nil //nolint:nilnil // записи не было, удалять нечего
+	}
+
+	//nolint:exhaustruct // поля заполняются при чтении
+	old := &entity.VaultItem{}
+	if err := boltGet(bucket, []byte(itemID), old); err != nil {
+		if errors.Is(err, ErrEntityNotFound) {
+			return t.tombstone(ownerID, itemID)
+		}
+
+		return nil, err
+	}
+
+	if old.IsExpired(time.Now()) {
+		return nil, nil //nolint:nilnil // запись уже удалена по сроку
+	}
+
+	if version != 0 && version != old.Version {
+		return nil, ErrVersionConflict
+	}
+
+	old.OwnerID = ownerID
+	if err := t.keepRevision(old); err != nil {
+		return nil, err
+	}
+
+	if err := bucket.Delete([]byte(itemID)); err != nil {
+		return nil, fmt.Errorf("delete: %w", err)
+	}
+
+	tombs, err := t.tx.Bucket(boltBucketTombs).CreateBucketIfNotExists([]byte(ownerID))
+	if err != nil {
+		return nil, fmt.Errorf("owner bucket: %w", err)
+	}
+
+	seq, err := boltNextSeq(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	tomb := &entity.Tombstone{
+		ID:        itemID,
+		OwnerID:   ownerID,
+		Version:   old.Version + 1,
+		DeletedAt: time.Now().UTC(),
+		Seq:       seq,
+		Expired:   false,
+	}
+
+	if err := boltPut(tombs, []byte(itemID), tomb); err != nil {
+		return nil, err
+	}
+
+	trash, err := t.tx.Bucket(boltBucketTrash).CreateBucketIfNotExists([]byte(ownerID))
+	if err != nil {
+		return nil, fmt.Errorf("owner bucket: %w", err)
+	}
+
+	trashed := &entity.TrashItem{
+		DeletedAt: tomb.DeletedAt,
+		Item:      old,
+	}
+
+	if err := boltPut(trash, []byte(itemID), trashed); err != nil {
+		return nil, err
+	}
+
+	return tomb.AsItem(), nil
+}
+
+// expire удаляет запись с истёкшим сроком и её прежние версии, оставляя отметку
+// об удалении с признаком Expired.
+func (t *boltTx) expire(item *entity.VaultItem) error {
+	bucket := t.tx.Bucket(boltBucketItems).Bucket([]byte(item.OwnerID))
+
+	if err := bucket.Delete([]byte(item.ID)); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	if revs := t.tx.Bucket(boltBucketRevs).Bucket([]byte(item.OwnerID)); revs != nil {
+		err := revs.DeleteBucket([]byte(item.ID))
+		if err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+			return fmt.Errorf("delete revisions: %w", err)
+		}
+	}
+
+	if err := boltDeleteItemShares(t.tx, item.OwnerID, item.ID); err != nil {
+		return err
+	}
+
+	tombs, err := t.tx.Bucket(boltBucketTombs).CreateBucketIfNotExists([]byte(item.OwnerID))
+	if err != nil {
+		return fmt.Errorf("owner bucket: %w", err)
+	}
+
+	seq, err := boltNextSeq(bucket)
+	if err != nil {
+		return err
+	}
+
+	tomb := &entity.Tombstone{
+		ID:        item.ID,
+		OwnerID:   item.OwnerID,
+		Version:   item.Version + 1,
+		DeletedAt: time.Now().UTC(),
+		Seq:       seq,
+		Expired:   true,
+	}
+
+	return boltPut(tombs, []byte(item.ID), tomb)
+}
+
+// usage возвращает объём данных пользователя, подсчитанный по записям.
+// Записи с истёкшим сроком не учитываются, даже если фоновая задача ещё их не удалила.
+func (t *boltTx) usage(ownerID string) (entity.Usage, error) {
+	usage := entity.Usage{Items: 0, Bytes: 0}
+
+	bucket := t.tx.Bucket(boltBucketItems).Bucket([]byte(ownerID))
+	if bucket == nil {
+		return usage, nil
+	}
+
+	now := time.Now()
+
+	err := bucket.ForEach(func(_, value []byte) error {
+		//nolint:exhaustruct // поля заполняются при чтении
+		item := &entity.VaultItem{}
+		if err := json.Unmarshal(value, item); err != nil {
+			return fmt.Errorf("unmarshal: %w", err)
+		}
+
+		if item.IsExpired(now) {
+			return nil
+		}
+
+		usage.Items++
+		usage.Bytes += item.UsageSize()
+
+		return nil
+	})
+	if err != nil {
+		return usage, fmt.Errorf("count usage: %w", err)
+	}
+
+	return usage, nil
+}
+
+// keepRevision сохраняет заменяемую версию записи и удаляет лишние старые версии.
+func (t *boltTx) keepRevision(old *entity.VaultItem) error {
+	if t.revisionLimit == 0 {
+		return nil
+	}
+
+	owner, err := t.tx.Bucket(boltBucketRevs).CreateBucketIfNotExists([]byte(old.OwnerID))
+	if err != nil {
+		return fmt.Errorf("owner bucket: %w", err)
+	}
+
+	bucket, err := owner.CreateBucketIfNotExists([]byte(old.ID))
+	if err != nil {
+		return fmt.Errorf("item bucket: %w", err)
+	}
+
+	rev := &entity.Revision{
+		CreatedAt: time.Now().UTC(),
+		Item:      old,
+	}
+
+	if err := boltPut(bucket, boltVersionKey(old.Version), rev); err != nil {
+		return err
+	}
+
+	// Ключи упорядочены по версии, поэтому лишние версии находятся в начале бакета.
+	keys := make([][]byte, 0, t.revisionLimit+1)
+
+	cursor := bucket.Cursor()
+	for key, _ := cursor.First(); key != nil; key, _ = cursor.Next() {
+		keys = append(keys, key)
+	}
+
+	for len(keys) > t.revisionLimit {
+		if err := bucket.Delete(keys[0]); err != nil {
+			return fmt.Errorf("delete revision: %w", err)
+		}
+
+		keys = keys[1:]
+	}
+
+	return nil
+}
+
+// current возвращает запись или отметку об удалении.
+func (t *boltTx) current(ownerID, itemID string) (*entity.VaultItem, error) {
+	if bucket := t.tx.Bucket(boltBucketItems).Bucket([]byte(ownerID)); bucket != nil {
+		//nolint:exhaustruct // поля заполняются при чтении
+		item := &entity.VaultItem{}
+
+		err := boltGet(bucket, []byte(itemID), item)
+		if err == nil {
+			item.OwnerID = ownerID
+
+			return item, nil
+		}
+
+		if !errors.Is(err, ErrEntityNotFound) {
+			return nil, err
+		}
+	}
+
+	tomb, err := t.tombstone(ownerID, itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	if tomb == nil {
+		return nil, ErrEntityNotFound
+	}
+
+	return tomb, nil
+}
+
+// tombstone возвращает отметку об удалении записи (nil, если её нет).
+func (t *boltTx) tombstone(ownerID, itemID string) (*entity.VaultItem, error) {
+	bucket := t.tx.Bucket(boltBucketTombs).Bucket([]byte(ownerID))
+	if bucket == nil {
+		return nil, nil //nolint:nilnil // отметки нет
+	}
+
+	//nolint:exhaustruct // поля заполняются при чтении
+	tomb := &entity.Tombstone{}
+	if err := boltGet(bucket, []byte(itemID), tomb); err != nil {
+		if errors.Is(err, ErrEntityNotFound) {
+			return nil, nil //nolint:nilnil // отметки нет
+		}
+
+		return nil, err
+	}
+
+	tomb.OwnerID = ownerID
+
+	return tomb.AsItem(), nil
+}
+
+// listItems выбирает записи пользователя, удовлетворяющие условию.
+func (b *BoltStorage) listItems(
+	ownerID string,
+	match func(*entity.VaultItem) bool,
+) ([]*entity.VaultItem, error) {
+	res := make([]*entity.VaultItem, 0)
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucketItems).Bucket([]byte(ownerID))
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(_, value []byte) error {
+			//nolint:exhaustruct // поля заполняются при чтении
+			item := &entity.VaultItem{}
+			if err := json.Unmarshal(value, item); err != nil {
+				return fmt.Errorf("unmarshal: %w", err)
+			}
+
+			item.OwnerID = ownerID
+
+			if match(item) {
+				res = append(res, item)
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("items: %w", err)
+	}
+
+	return res, nil
+}
+
+// boltWalk перебирает значения бакета и всех вложенных в него бакетов.
+func boltWalk(bucket *bolt.Bucket, fn func(value []byte) error) error {
+	return bucket.ForEach(func(key, value []byte) error {
+		if value == nil {
+			return boltWalk(bucket.Bucket(key), fn)
+		}
+
+		return fn(value)
+	})
+}
+
+// boltRevisionBucket возвращает бакет прежних версий записи (nil, если их нет).
+func boltRevisionBucket(tx *bolt.Tx, ownerID, itemID string) *bolt.Bucket {
+	owner := tx.Bucket(boltBucketRevs).Bucket([]byte(ownerID))
+	if owner == nil {
+		return nil
+	}
+
+	return owner.Bucket([]byte(itemID))
+}
+
+// boltDecodeRevision считывает прежнюю версию записи из JSON.
+func boltDecodeRevision(value []byte, ownerID string) (*entity.Revision, error) {
+	//nolint:exhaustruct // поля заполняются при чтении
+	rev := &entity.Revision{}
+	if err := json.Unmarshal(value, rev); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+
+	if rev.Item != nil {
+		rev.Item.OwnerID = ownerID
+	}
+
+	return rev, nil
+}
+
+// boltDecodeTrashItem считывает запись корзины из JSON.
+func boltDecodeTrashItem(value []byte, ownerID string) (*entity.TrashItem, error) {
+	//nolint:exhaustruct // поля заполняются при чтении
+	trashed := &entity.TrashItem{}
+	if err := json.Unmarshal(value, trashed); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+
+	if trashed.Item != nil {
+		trashed.Item.OwnerID = ownerID
+	}
+
+	return trashed, nil
+}
+
+// boltPutShare сохраняет доступ к записи и индекс по получателю.
+func boltPutShare(tx *bolt.Tx, share *entity.Share) error {
+	key := boltCompositeKey(share.OwnerID, share.ItemID, share.RecipientID)
+	if err := boltPut(tx.Bucket(boltBucketShares), key, share); err != nil {
+		return err
+	}
+
+	index := boltCompositeKey(share.RecipientID, share.OwnerID, share.ItemID)
+	if err := tx.Bucket(boltBucketShared).Put(index, []byte{}); err != nil {
+		return fmt.Errorf("put index: %w", err)
+	}
+
+	return nil
+}
+
+// boltPutMember сохраняет участие в организации и индекс по пользователю.
+func boltPutMember(tx *bolt.Tx, member *entity.Member) error {
+	if err := boltPut(tx.Bucket(boltBucketMember), boltCompositeKey(member.OrgID, member.UserID), member); err != nil {
+		return err
+	}
+
+	if err := tx.Bucket(boltBucketMemOf).Put(boltCompositeKey(member.UserID, member.OrgID), []byte{}); err != nil {
+		return fmt.Errorf("put index: %w", err)
+	}
+
+	return nil
+}
+
+// boltPutCollection сохраняет коллекцию и индекс по организации.
+func boltPutCollection(tx *bolt.Tx, coll *entity.Collection) error {
+	if err := boltPut(tx.Bucket(boltBucketColls), []byte(coll.ID), coll); err != nil {
+		return err
+	}
+
+	if err := tx.Bucket(boltBucketOrgCol).Put(boltCompositeKey(coll.OrgID, coll.ID), []byte{}); err != nil {
+		return fmt.Errorf("put index: %w", err)
+	}
+
+	return nil
+}
+
+// boltGetCollection считывает коллекцию, проверяя, что она принадлежит организации.
+func boltGetCollection(tx *bolt.Tx, orgID, collectionID string) (*entity.Collection, error) {
+	//nolint:exhaustruct // поля заполняются при чтении
+	coll := &entity.Collection{}
+	if err := boltGet(tx.Bucket(boltBucketColls), []byte(collectionID), coll); err != nil {
+		return nil, err
+	}
+
+	if coll.OrgID != orgID {
+		return nil, ErrEntityNotFound
+	}
+
+	return coll, nil
+}
+
+// boltPutFolder выдаёт папке следующий номер изменения владельца и сохраняет её.
+func boltPutFolder(tx *bolt.Tx, folder *entity.Folder) error {
+	owner := []byte(folder.OwnerID)
+
+	items, err := tx.Bucket(boltBucketItems).CreateBucketIfNotExists(owner)
+	if err != nil {
+		return fmt.Errorf("owner bucket: %w", err)
+	}
+
+	if folder.Seq, err = boltNextSeq(items); err != nil {
+		return err
+	}
+
+	folders, err := tx.Bucket(boltBucketFolds).CreateBucketIfNotExists(owner)
+	if err != nil {
+		return fmt.Errorf("owner bucket: %w", err)
+	}
+
+	return boltPut(folders, []byte(folder.ID), folder)
+}
+
+// boltGetFolder считывает папку владельца, в том числе удалённую.
+func boltGetFolder(tx *bolt.Tx, ownerID, folderID string) (*entity.Folder, error) {
+	bucket := tx.Bucket(boltBucketFolds).Bucket([]byte(ownerID))
+	if bucket == nil {
+		return nil, ErrEntityNotFound
+	}
+
+	value := bucket.Get([]byte(folderID))
+	if value == nil {
+		return nil, ErrEntityNotFound
+	}
+
+	return boltDecodeFolder(value, ownerID)
+}
+
+// boltCheckFolderEmpty проверяет, что в папке владельца нет записей и вложенных папок.
+func boltCheckFolderEmpty(tx *bolt.Tx, ownerID, folderID string, now time.Time) error {
+	folders, err := boltOwnerFolders(tx, ownerID)
+	if err != nil {
+		return err
+	}
+
+	items := make([]*entity.VaultItem, 0)
+
+	if bucket := tx.Bucket(boltBucketItems).Bucket([]byte(ownerID)); bucket != nil {
+		err := bucket.ForEach(func(_, value []byte) error {
+			//nolint:exhaustruct // поля заполняются при чтении
+			item := &entity.VaultItem{}
+			if err := json.Unmarshal(value, item); err != nil {
+				return fmt.Errorf("unmarshal: %w", err)
+			}
+
+			items = append(items, item)
+
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("items: %w", err)
+		}
+	}
+
+	return checkFolderEmpty(folderID, folders, items, now)
+}
+
+// boltCheckFolderParent проверяет внутри транзакции, что папку folderID можно
+// поместить в папку parentID (см. checkFolderParent).
+func boltCheckFolderParent(tx *bolt.Tx, ownerID, folderID, parentID string) error {
+	if parentID == "" {
+		return nil
+	}
+
+	folders, err := boltOwnerFolders(tx, ownerID)
+	if err != nil {
+		return err
+	}
+
+	return checkFolderParent(folderID, parentID, folders)
+}
+
+// boltOwnerFolders возвращает все папки владельца, включая удалённые.
+func boltOwnerFolders(tx *bolt.Tx, ownerID string) ([]*entity.Folder, error) {
+	folders := make([]*entity.Folder, 0)
+
+	bucket := tx.Bucket(boltBucketFolds).Bucket([]byte(ownerID))
+	if bucket == nil {
+		return folders, nil
+	}
+
+	err := bucket.ForEach(func(_, value []byte) error {
+		folder, err := boltDecodeFolder(value, ownerID)
+		if err != nil {
+			return err
+		}
+
+		folders = append(folders, folder)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("folders: %w", err)
+	}
+
+	return folders, nil
+}
+
+// boltDecodeFolder считывает папку из JSON и проставляет владельца.
+func boltDecodeFolder(value []byte, ownerID string) (*entity.Folder, error) {
+	//nolint:exhaustruct // поля заполняются при чтении
+	folder := &entity.Folder{}
+	if err := json.Unmarshal(value, folder); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+
+	folder.OwnerID = ownerID
+
+	return folder, nil
+}
+
+// boltDeleteItemShares удаляет доступы к безвозвратно удалённой записи вместе с индексом получателей.
+func boltDeleteItemShares(tx *bolt.Tx, ownerID, itemID string) error {
+	bucket := tx.Bucket(boltBucketShares)
+	shares := make([]*entity.Share, 0)
+
+	err := boltForEachPrefix(bucket, boltCompositeKey(ownerID, itemID, ""), func(_, value []byte) error {
+		share, err := boltDecodeShare(value)
+		if err != nil {
+			return err
+		}
+
+		shares = append(shares, share)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("shares: %w", err)
+	}
+
+	for _, share := range shares {
+		if err := bucket.Delete(boltCompositeKey(ownerID, itemID, share.RecipientID)); err != nil {
+			return fmt.Errorf("delete share: %w", err)
+		}
+
+		if err := tx.Bucket(boltBucketShared).Delete(boltCompositeKey(share.RecipientID, ownerID, itemID)); err != nil {
+			return fmt.Errorf("delete share index: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// boltDecodeShare считывает доступ к записи из JSON.
+func boltDecodeShare(value []byte) (*entity.Share, error) {
+	//nolint:exhaustruct // поля заполняются при чтении
+	share := &entity.Share{}
+	if err := json.Unmarshal(value, share); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+
+	return share, nil
+}
+
+// boltCompositeKey склеивает части ключа через нулевой байт.
+//
+// Пустая последняя часть даёт префикс для перебора всех ключей с заданным началом.
+func boltCompositeKey(parts ...string) []byte {
+	return []byte(strings.Join(parts, "\x00"))
+}
+
+// boltForEachPrefix перебирает пары бакета, ключи которых начинаются с prefix.
+func boltForEachPrefix(bucket *bolt.Bucket, prefix []byte, fn func(key, value []byte) error) error {
+	cursor := bucket.Cursor()
+
+	for key, value := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, value = cursor.Next() {
+		if err := fn(key, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// boltVersionKey кодирует номер версии в ключ, упорядоченный по возрастанию.
+func boltVersionKey(version int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(version)) //nolint:gosec // версии неотрицательны
+}
+
+// boltNextSeq выдаёт следующий номер изменения из последовательности бакета.
+func boltNextSeq(bucket *bolt.Bucket) (int64, error) {
+	seq, err := bucket.NextSequence()
+	if err != nil {
+		return 0, fmt.Errorf("next seq: %w", err)
+	}
+
+	//nolint:gosec // последовательность не достигает math.MaxInt64
+	return int64(seq), nil
+}
+
+// boltPut сохраняет значение в бакет в формате JSON.
+func boltPut(bucket *bolt.Bucket, key []byte, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+
+	if err := bucket.Put(key, data); err != nil {
+		return fmt.Errorf("put: %w", err)
+	}
+
+	return nil
+}
+
+// boltGet считывает значение из бакета в формате JSON.
+func boltGet(bucket *bolt.Bucket, key []byte, value any) error {
+	data := bucket.Get(key)
+	if data == nil {
+		return ErrEntityNotFound
+	}
+
+	if err := json.Unmarshal(data, value); err != nil {
+		return fmt.Errorf("unmarshal: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,873 @@
+package storage_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/storagetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBoltStorage(t *testing.T, path string) *storage.BoltStorage {
+	t.Helper()
+
+	stor, err := storage.NewBoltStorage(path)
+	require.NoError(t, err)
+
+	return stor
+}
+
+/*
+	===== BoltStorage users =====
+*/
+
+func TestBoltStorage_Users(t *testing.T) {
+	t.Parallel()
+
+	stor := newTestBoltStorage(t, filepath.Join(t.TempDir(), "data.db"))
+	defer func() { _ = stor.Close() }()
+
+	ctx := context.Background()
+	user := entity.NewUser("User@Example.com", "hash")
+
+	userID, err := stor.AddNewUser(ctx, user)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, userID)
+
+	_, err = stor.AddNewUser(ctx, entity.NewUser("user@example.com", "other"))
+	require.ErrorIs(t, err, storage.ErrEntityAlreadyExists)
+
+	found, err := stor.FindUserByEmail(ctx, "USER@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, found.ID)
+
+	_, err = stor.FindUserByEmail(ctx, "missing@example.com")
+	require.ErrorIs(t, err, storage.ErrEntityNotFound)
+}
+
+/*
+	===== BoltStorage tokens =====
+*/
+
+func TestBoltStorage_Tokens(t *testing.T) {
+	t.Parallel()
+
+	stor := newTestBoltStorage(t, filepath.Join(t.TempDir(), "data.db"))
+	defer func() { _ = stor.Close() }()
+
+	ctx := context.Background()
+
+	assert.False(t, stor.IsTokenByUserID(ctx, "user-1"))
+
+	_, err := stor.AddNewToken(ctx, "user-1", &entity.Token{})
+	require.NoError(t, err)
+	assert.True(t, stor.IsTokenByUserID(ctx, "user-1"))
+
+	_, err = stor.AddNewToken(ctx, "user-1", &entity.Token{})
+	require.ErrorIs(t, err, storage.ErrEntityAlreadyExists)
+
+	require.NoError(t, stor.DeleteToken(ctx, "user-1"))
+	assert.False(t, stor.IsTokenByUserID(ctx, "user-1"))
+}
+
+/*
+	===== BoltStorage items =====
+*/
+
+func TestBoltStorage_Items(t *testing.T) {
+	t.Parallel()
+
+	stor := newTestBoltStorage(t, filepath.Join(t.TempDir(), "data.db"))
+	defer func() { _ = stor.Close() }()
+
+	ctx := context.Background()
+
+	//nolint:exhaustruct // not all fields needed in test
+	item := &entity.VaultItem{
+		OwnerID: "user-1",
+		Type:    entity.ItemLogin,
+		Title:   "Email",
+		Meta:    map[string]string{"site": "example.com"},
+	}
+
+	itemID, err := stor.CreateItem(ctx, item)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), item.Version)
+
+	_, err = stor.CreateItem(ctx, item)
+	require.ErrorIs(t, err, storage.ErrEntityAlreadyExists)
+
+	item.Title = "Email (new)"
+	require.NoError(t, stor.UpdateItem(ctx, item))
+
+	got, err := stor.GetItem(ctx, "user-1", itemID)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", got.OwnerID)
+	assert.Equal(t, "Email (new)", got.Title)
+	assert.Equal(t, int64(2), got.Version)
+
+	_, err = stor.GetItem(ctx, "user-2", itemID)
+	require.ErrorIs(t, err, storage.ErrEntityNotFound)
+
+	//nolint:exhaustruct // not all fields needed in test
+	missing := &entity.VaultItem{ID: "missing", OwnerID: "user-1"}
+	require.ErrorIs(t, stor.UpdateItem(ctx, missing), storage.ErrEntityNotFound)
+
+	changed, err := stor.ListChanges(ctx, "user-1", 0, 0)
+	require.NoError(t, err)
+	assert.Len(t, changed, 1)
+
+	require.NoError(t, stor.DeleteItem(ctx, "user-1", itemID))
+
+	list, err := stor.ListItems(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Empty(t, list)
+}
+
+func TestBoltStorage_Persistence(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "data.db")
+	ctx := context.Background()
+
+	stor := newTestBoltStorage(t, path)
+
+	_, err := stor.AddNewUser(ctx, entity.NewUser("user@example.com", "hash"))
+	require.NoError(t, err)
+
+	//nolint:exhaustruct // not all fields needed in test
+	itemID, err := stor.UpsertItem(ctx, &entity.VaultItem{OwnerID: "user-1", Title: "Note"})
+	require.NoError(t, err)
+	require.NoError(t, stor.Close())
+
+	reopened := newTestBoltStorage(t, path)
+	defer func() { _ = reopened.Close() }()
+
+	_, err = reopened.FindUserByEmail(ctx, "user@example.com")
+	require.NoError(t, err)
+
+	got, err := reopened.GetItem(ctx, "user-1", itemID)
+	require.NoError(t, err)
+	assert.Equal(t, "Note", got.Title)
+}
+
+func TestBoltStorage_UpdateItem_VersionConflict(t *testing.T) {
+	t.Parallel()
+
+	stor := newTestBoltStorage(t, filepath.Join(t.TempDir(), "data.db"))
+	defer func() { _ = stor.Close() }()
+
+	ctx := context.Background()
+
+	//nolint:exhaustruct // not all fields needed in test
+	item := &entity.VaultItem{OwnerID: "user-1", Title: "Email"}
+
+	itemID, err := stor.CreateItem(ctx, item)
+	require.NoError(t, err)
+
+	//nolint:exhaustruct // not all fields needed in test
+	first := &entity.VaultItem{ID: itemID, OwnerID: "user-1", Title: "First", Version: 1}
+	require.NoError(t, stor.UpdateItem(ctx, first))
+
+	//nolint:exhaustruct // not all fields needed in test
+	second := &entity.VaultItem{ID: itemID, OwnerID: "user-1", Title: "Second", Version: 1}
+	require.ErrorIs(t, stor.UpdateItem(ctx, second), storage.ErrVersionConflict)
+
+	_, err = stor.UpsertItem(ctx, second)
+	require.ErrorIs(t, err, storage.ErrVersionConflict)
+
+	got, err := stor.GetItem(ctx, "user-1", itemID)
+	require.NoError(t, err)
+	assert.Equal(t, "First", got.Title)
+	assert.Equal(t, int64(2), got.Version)
+}
+
+/*
+	===== BoltStorage.DeleteItem =====
+*/
+
+func TestBoltStorage_DeleteItem_Tombstone(t *testing.T) {
+	t.Parallel()
+
+	stor := newTestBoltStorage(t, filepath.Join(t.TempDir(), "data.db"))
+	defer func() { _ = stor.Close() }()
+
+	ctx := context.Background()
+
+	//nolint:exhaustruct // not all fields needed in test
+	item := &entity.VaultItem{OwnerID: "user-1", Title: "Email"}
+
+	itemID, err := stor.CreateItem(ctx, item)
+	require.NoError(t, err)
+	require.NoError(t, stor.DeleteItem(ctx, "user-1", itemID))
+
+	changed, err := stor.ListChanges(ctx, "user-1", 0, 0)
+	require.NoError(t, err)
+	require.Len(t, changed, 1)
+	assert.Equal(t, itemID, changed[0].ID)
+	assert.True(t, changed[0].Deleted)
+	assert.Equal(t, int64(2), changed[0].Version)
+
+	//nolint:exhaustruct // not all fields needed in test
+	stale := &entity.VaultItem{ID: itemID, OwnerID: "user-1", Title: "Stale", Version: 1}
+	_, err = stor.UpsertItem(ctx, stale)
+	require.ErrorIs(t, err, storage.ErrVersionConflict)
+
+	//nolint:exhaustruct // not all fields needed in test
+	restored := &entity.VaultItem{ID: itemID, OwnerID: "user-1", Title: "Restored", Version: 2}
+	_, err = stor.UpsertItem(ctx, restored)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), restored.Version)
+
+	changed, err = stor.ListChanges(ctx, "user-1", 0, 0)
+	require.NoError(t, err)
+	require.Len(t, changed, 1)
+	assert.False(t, changed[0].Deleted)
+}
+
+func TestBoltStorage_CompactTombstones(t *testing.T) {
+	t.Parallel()
+
+	stor := newTestBoltStorage(t, filepath.Join(t.TempDir(), "data.db"))
+	defer func() { _ = stor.Close() }()
+
+	ctx := context.Background()
+
+	//nolint:exhaustruct // not all fields needed in test
+	item := &entity.VaultItem{OwnerID: "user-1", Title: "Email"}
+
+	itemID, err := stor.CreateItem(ctx, item)
+	require.NoError(t, err)
+	require.NoError(t, stor.DeleteItem(ctx, "user-1", itemID))
+
+	count, err := stor.CompactTombstones(ctx, time.Now().UTC().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	count, err = stor.CompactTombstones(ctx, time.Now().UTC().Add(time.Second))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, count, 1)
+
+	changed, err := stor.ListChanges(ctx, "user-1", 0, 0)
+	require.NoError(t, err)
+	assert.Empty(t, changed)
+}
+
+/*
+	===== BoltStorage.ListChanges =====
+*/
+
+func TestBoltStorage_ListChanges_Paging(t *testing.T) {
+	t.Parallel()
+
+	stor := newTestBoltStorage(t, filepath.Join(t.TempDir(), "data.db"))
+	defer func() { _ = stor.Close() }()
+
+	ctx := context.Background()
+	ids := make([]string, 0, 3)
+
+	for _, title := range []string{"A", "B", "C"} {
+		//nolint:exhaustruct // not all fields needed in test
+		itemID, err := stor.CreateItem(ctx, &entity.VaultItem{OwnerID: "user-1", Title: title})
+		require.NoError(t, err)
+
+		ids = append(ids, itemID)
+	}
+
+	//nolint:exhaustruct // not all fields needed in test
+	require.NoError(t, stor.UpdateItem(ctx, &entity.VaultItem{ID: ids[0], OwnerID: "user-1"}))
+	require.NoError(t, stor.DeleteItem(ctx, "user-1", ids[1]))
+
+	page, err := stor.ListChanges(ctx, "user-1", 0, 2)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, ids[2], page[0].ID)
+	assert.Equal(t, ids[0], page[1].ID)
+	assert.Less(t, page[0].Seq, page[1].Seq)
+
+	page, err = stor.ListChanges(ctx, "user-1", page[1].Seq, 2)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, ids[1], page[0].ID)
+	assert.True(t, page[0].Deleted)
+
+	page, err = stor.ListChanges(ctx, "user-1", page[0].Seq, 0)
+	require.NoError(t, err)
+	assert.Empty(t, page)
+}
+
+/*
+	===== BoltStorage.ApplyChanges =====
+*/
+
+func TestBoltStorage_ApplyChanges(t *testing.T) {
+	t.Parallel()
+
+	stor := newTestBoltStorage(t, filepath.Join(t.TempDir(), "data.db"))
+	defer func() { _ = stor.Close() }()
+
+	ctx := context.Background()
+
+	//nolint:exhaustruct // not all fields needed in test
+	existing := &entity.VaultItem{OwnerID: "user-1", Title: "Existing"}
+	existingID, err := stor.CreateItem(ctx, existing)
+	require.NoError(t, err)
+
+	//nolint:exhaustruct // not all fields needed in test
+	removed := &entity.VaultItem{OwnerID: "user-1", Title: "Removed"}
+	removedID, err := stor.CreateItem(ctx, removed)
+	require.NoError(t, err)
+
+	//nolint:exhaustruct // not all fields needed in test
+	changes := []*entity.Change{
+		{Op: entity.ChangeUpsert, Item: &entity.VaultItem{ID: "new-item", Title: "New"}},
+		{Op: entity.ChangeUpsert, Item: &entity.VaultItem{ID: existingID, Title: "Stale", Version: 7}},
+		{Op: entity.ChangeDelete, Item: &entity.VaultItem{ID: removedID, Version: 1}},
+		{Op: entity.ChangeDelete, Item: &entity.VaultItem{}},
+		{Op: "move", Item: &entity.VaultItem{ID: existingID}},
+	}
+
+	results, err := stor.ApplyChanges(ctx, "user-1", changes)
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+
+	assert.Equal(t, entity.ChangeApplied, results[0].Status)
+	assert.Equal(t, "new-item", results[0].ID)
+	assert.Equal(t, int64(1), results[0].Item.Version)
+
+	assert.Equal(t, entity.ChangeConflict, results[1].Status)
+	assert.Equal(t, "Existing", results[1].Item.Title)
+	assert.Equal(t, int64(1), results[1].Item.Version)
+
+	assert.Equal(t, entity.ChangeApplied, results[2].Status)
+	assert.True(t, results[2].Item.Deleted)
+	assert.Equal(t, int64(2), results[2].Item.Version)
+
+	assert.Equal(t, entity.ChangeRejected, results[3].Status)
+	assert.Equal(t, entity.ChangeRejected, results[4].Status)
+
+	got, err := stor.GetItem(ctx, "user-1", "new-item")
+	require.NoError(t, err)
+	assert.Equal(t, "New", got.Title)
+
+	_, err = stor.GetItem(ctx, "user-1", removedID)
+	require.ErrorIs(t, err, storage.ErrEntityNotFound)
+}
+
+func TestBoltStorage_Revisions(t *testing.T) {
+	t.Parallel()
+
+	stor := newTestBoltStorage(t, filepath.Join(t.TempDir(), "data.db"))
+	defer func() { _ = stor.Close() }()
+
+	stor.SetRevisionLimit(2)
+
+	ctx := context.Background()
+
+	//nolint:exhaustruct // not all fields needed in test
+	item := &entity.VaultItem{OwnerID: "user-1", Title: "v1"}
+
+	itemID, err := stor.CreateItem(ctx, item)
+	require.NoError(t, err)
+
+	for _, title := range []string{"v2", "v3", "v4"} {
+		//nolint:exhaustruct // not all fields needed in test
+		update := &entity.VaultItem{ID: itemID, OwnerID: "user-1", Title: title}
+		require.NoError(t, stor.UpdateItem(ctx, update))
+	}
+
+	revs, err := stor.ListRevisions(ctx, "user-1", itemID)
+	require.NoError(t, err)
+	require.Len(t, revs, 2)
+	assert.Equal(t, "v3", revs[0].Item.Title)
+	assert.Equal(t, "v2", revs[1].Item.Title)
+	assert.Greater(t, revs[0].Item.Version, revs[1].Item.Version)
+
+	rev, err := stor.GetRevision(ctx, "user-1", itemID, revs[1].Item.Version)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", rev.Item.Title)
+
+	_, err = stor.GetRevision(ctx, "user-1", itemID, 100)
+	require.ErrorIs(t, err, storage.ErrEntityNotFound)
+
+	require.NoError(t, stor.DeleteItem(ctx, "user-1", itemID))
+
+	revs, err = stor.ListRevisions(ctx, "user-1", itemID)
+	require.NoError(t, err)
+	require.Len(t, revs, 2)
+	assert.Equal(t, "v4", revs[0].Item.Title, "deleted content must stay in history")
+
+	count, err := stor.CompactRevisions(ctx, time.Now().UTC().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	count, err = stor.CompactRevisions(ctx, time.Now().UTC().Add(time.Second))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, count, 2)
+
+	revs, err = stor.ListRevisions(ctx, "user-1", itemID)
+	require.NoError(t, err)
+	assert.Empty(t, revs)
+}
+
+func TestBoltStorage_Trash(t *testing.T) {
+	t.Parallel()
+
+	stor := newTestBoltStorage(t, filepath.Join(t.TempDir(), "data.db"))
+	defer func() { _ = stor.Close() }()
+
+	ctx := context.Background()
+
+	//nolint:exhaustruct // not all fields needed in test
+	first := &entity.VaultItem{OwnerID: "user-1", Title: "first"}
+	//nolint:exhaustruct // not all fields needed in test
+	second := &entity.VaultItem{OwnerID: "user-1", Title: "second"}
+
+	firstID, err := stor.CreateItem(ctx, first)
+	require.NoError(t, err)
+	secondID, err := stor.CreateItem(ctx, second)
+	require.NoError(t, err)
+
+	require.NoError(t, stor.DeleteItem(ctx, "user-1", firstID))
+	require.NoError(t, stor.DeleteItem(ctx, "user-1", secondID))
+
+	trash, err := stor.ListTrash(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, trash, 2)
+	assert.Equal(t, "second", trash[0].Item.Title, "last deleted item goes first")
+
+	restored, err := stor.RestoreTrash(ctx, "user-1", firstID)
+	require.NoError(t, err)
+	assert.Equal(t, "first", restored.Title)
+	assert.Equal(t, int64(3), restored.Version, "restore is a new version after the tombstone")
+
+	got, err := stor.GetItem(ctx, "user-1", firstID)
+	require.NoError(t, err)
+	assert.Equal(t, "first", got.Title)
+
+	_, err = stor.RestoreTrash(ctx, "user-1", firstID)
+	require.ErrorIs(t, err, storage.ErrEntityNotFound)
+
+	count, err := stor.PurgeTrash(ctx, time.Now().UTC().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	count, err = stor.EmptyTrash(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	trash, err = stor.ListTrash(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Empty(t, trash)
+
+	revs, err := stor.ListRevisions(ctx, "user-1", secondID)
+	require.NoError(t, err)
+	assert.Empty(t, revs, "purged item must not keep its history")
+}
+
+func TestBoltStorage_QueryItems(t *testing.T) {
+	t.Parallel()
+
+	stor := newTestBoltStorage(t, filepath.Join(t.TempDir(), "data.db"))
+	defer func() { _ = stor.Close() }()
+
+	ctx := context.Background()
+
+	for _, title := range []string{"b-mail", "A-Mail", "c_note", "mail", "C-card"} {
+		//nolint:exhaustruct // not all fields needed in test
+		item := &entity.VaultItem{
+			OwnerID: "user-1",
+			Type:    entity.ItemLogin,
+			Title:   title,
+			Meta:    map[string]string{"kind": title[:1]},
+		}
+		if strings.HasSuffix(title, "card") {
+			item.Type = entity.ItemCard
+		}
+
+		_, err := stor.CreateItem(ctx, item)
+		require.NoError(t, err)
+	}
+
+	titlesOf := func(items []*entity.VaultItem) []string {
+		res := make([]string, 0, len(items))
+		for _, item := range items {
+			res = append(res, item.Title)
+		}
+
+		return res
+	}
+
+	//nolint:exhaustruct // not all fields needed in test
+	query := &entity.ItemQuery{Title: "mail", Limit: 2}
+
+	page, err := stor.QueryItems(ctx, "user-1", query)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"A-Mail", "b-mail"}, titlesOf(page))
+
+	query.After = entity.CursorOf(page[1])
+
+	page, err = stor.QueryItems(ctx, "user-1", query)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mail"}, titlesOf(page))
+
+	//nolint:exhaustruct // not all fields needed in test
+	query = &entity.ItemQuery{Title: "c", TitleMatch: entity.TitlePrefix, Desc: true}
+
+	page, err = stor.QueryItems(ctx, "user-1", query)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c_note", "C-card"}, titlesOf(page))
+
+	//nolint:exhaustruct // not all fields needed in test
+	query = &entity.ItemQuery{Title: "_", TitleMatch: entity.TitlePrefix}
+
+	page, err = stor.QueryItems(ctx, "user-1", query)
+	require.NoError(t, err)
+	assert.Empty(t, page, "pattern characters must be matched literally")
+
+	//nolint:exhaustruct // not all fields needed in test
+	query = &entity.ItemQuery{Type: entity.ItemCard}
+
+	page, err = stor.QueryItems(ctx, "user-1", query)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"C-card"}, titlesOf(page))
+
+	//nolint:exhaustruct // not all fields needed in test
+	query = &entity.ItemQuery{MetaKey: "kind", MetaValue: "m"}
+
+	page, err = stor.QueryItems(ctx, "user-1", query)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mail"}, titlesOf(page))
+
+	//nolint:exhaustruct // not all fields needed in test
+	query = &entity.ItemQuery{SortBy: entity.SortByUpdatedAt, Desc: true, Limit: 1}
+
+	page, err = stor.QueryItems(ctx, "user-1", query)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"C-card"}, titlesOf(page))
+}
+
+func TestBoltStorage_TagQueries(t *testing.T) {
+	t.Parallel()
+
+	stor := newTestBoltStorage(t, filepath.Join(t.TempDir(), "data.db"))
+	defer func() { _ = stor.Close() }()
+
+	checkTagQueries(t, stor)
+}
+
+func TestBoltStorage_Attachments(t *testing.T) {
+	t.Parallel()
+
+	stor := newTestBoltStorage(t, filepath.Join(t.TempDir(), "data.db"))
+	defer func() { _ = stor.Close() }()
+
+	checkAttachments(t, stor)
+}
+
+func TestBoltStorage_ExpiringItems(t *testing.T) {
+	t.Parallel()
+
+	stor := newTestBoltStorage(t, filepath.Join(t.TempDir(), "data.db"))
+	defer func() { _ = stor.Close() }()
+
+	checkExpiringItems(t, stor)
+}
+
+func TestBoltStorage_Quota(t *testing.T) {
+	t.Parallel()
+
+	stor := newTestBoltStorage(t, filepath.Join(t.TempDir(), "data.db"))
+	defer func() { _ = stor.Close() }()
+
+	stor.SetQuota(entity.Quota{MaxItems: 2, MaxBytes: 20, MaxItemBytes: 12})
+
+	ctx := context.Background()
+
+	//nolint:exhaustruct // not all fields needed in test
+	first := &entity.VaultItem{OwnerID: "user-1", Title: "aaaa"}
+	//nolint:exhaustruct // not all fields needed in test
+	second := &entity.VaultItem{OwnerID: "user-1", Title: "bbbbbbbb"}
+
+	firstID, err := stor.CreateItem(ctx, first)
+	require.NoError(t, err)
+	secondID, err := stor.CreateItem(ctx, second)
+	require.NoError(t, err)
+
+	usage, err := stor.GetUsage(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, entity.Usage{Items: 2, Bytes: 12}, *usage)
+
+	var quotaErr *storage.QuotaError
+
+	//nolint:exhaustruct // not all fields needed in test
+	_, err = stor.CreateItem(ctx, &entity.VaultItem{OwnerID: "user-1", Title: "c"})
+	require.ErrorAs(t, err, &quotaErr)
+	assert.Equal(t, storage.QuotaItems, quotaErr.Limit)
+
+	//nolint:exhaustruct // not all fields needed in test
+	err = stor.UpdateItem(ctx, &entity.VaultItem{ID: firstID, OwnerID: "user-1", Title: "aaaaaaaaaaaaa"})
+	require.ErrorAs(t, err, &quotaErr)
+	assert.Equal(t, storage.QuotaItemBytes, quotaErr.Limit)
+
+	//nolint:exhaustruct // not all fields needed in test
+	err = stor.UpdateItem(ctx, &entity.VaultItem{ID: firstID, OwnerID: "user-1", Title: "aaaaaaaaaaaa"})
+	require.NoError(t, err)
+
+	//nolint:exhaustruct // not all fields needed in test
+	err = stor.UpdateItem(ctx, &entity.VaultItem{ID: secondID, OwnerID: "user-1", Title: "bbbbbbbbb"})
+	require.ErrorIs(t, err, storage.ErrQuotaExceeded)
+	require.ErrorAs(t, err, &quotaErr)
+	assert.Equal(t, storage.QuotaBytes, quotaErr.Limit)
+	assert.Equal(t, int64(20), quotaErr.Used)
+
+	require.NoError(t, stor.DeleteItem(ctx, "user-1", firstID))
+
+	usage, err = stor.GetUsage(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, entity.Usage{Items: 1, Bytes: 8}, *usage, "trash is not counted")
+
+	results, err := stor.ApplyChanges(ctx, "user-1", []*entity.Change{
+		//nolint:exhaustruct // not all fields needed in test
+		{Op: entity.ChangeUpsert, Item: &entity.VaultItem{OwnerID: "user-1", Data: "0123456789abcdef"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, entity.ChangeRejected, results[0].Status)
+}
+
+func TestBoltStorage_ContentQuota(t *testing.T) {
+	t.Parallel()
+
+	stor := newTestBoltStorage(t, filepath.Join(t.TempDir(), "data.db"))
+	defer func() { _ = stor.Close() }()
+
+	checkContentQuota(t, stor)
+}
+
+func TestBoltStorage_Content(t *testing.T) {
+	t.Parallel()
+
+	stor := newTestBoltStorage(t, filepath.Join(t.TempDir(), "data.db"))
+	defer func() { _ = stor.Close() }()
+
+	ctx := context.Background()
+
+	//nolint:exhaustruct // not all fields needed in test
+	item := &entity.VaultItem{OwnerID: "user-1", Type: entity.ItemBinary, Title: "photo"}
+
+	itemID, err := stor.CreateItem(ctx, item)
+	require.NoError(t, err)
+
+	content := &entity.Content{Hash: strings.Repeat("ab", 32), Size: 42}
+
+	updated, err := stor.SetItemContent(ctx, "user-1", itemID, 1, content)
+	require.NoError(t, err)
+	assert.Equal(t, content, updated.Content)
+	assert.Equal(t, int64(2), updated.Version)
+
+	_, err = stor.SetItemContent(ctx, "user-1", itemID, 1, nil)
+	require.ErrorIs(t, err, storage.ErrVersionConflict)
+
+	_, err = stor.SetItemContent(ctx, "user-1", "missing", 0, content)
+	require.ErrorIs(t, err, storage.ErrEntityNotFound)
+
+	//nolint:exhaustruct // not all fields needed in test
+	err = stor.UpdateItem(ctx, &entity.VaultItem{ID: itemID, OwnerID: "user-1", Type: entity.ItemBinary, Title: "renamed"})
+	require.NoError(t, err)
+
+	got, err := stor.GetItem(ctx, "user-1", itemID)
+	require.NoError(t, err)
+	assert.Equal(t, "renamed", got.Title)
+	assert.Equal(t, content, got.Content, "update without content keeps it")
+
+	cleared, err := stor.SetItemContent(ctx, "user-1", itemID, 0, nil)
+	require.NoError(t, err)
+	assert.Nil(t, cleared.Content)
+
+	hashes, err := stor.ContentHashes(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, hashes, content.Hash, "previous versions keep content referenced")
+
+	revs, err := stor.ListRevisions(ctx, "user-1", itemID)
+	require.NoError(t, err)
+	require.NotEmpty(t, revs)
+	assert.Equal(t, content, revs[0].Item.Content)
+}
+
+/*
+	===== BoltStorage.DataKeys =====
+*/
+
+func TestBoltStorage_DataKeys(t *testing.T) {
+	t.Parallel()
+
+	stor := newTestBoltStorage(t, filepath.Join(t.TempDir(), "data.db"))
+	defer func() { _ = stor.Close() }()
+
+	ctx := context.Background()
+	ownerID := "user-1"
+
+	_, err := stor.GetDataKey(ctx, ownerID)
+	require.ErrorIs(t, err, storage.ErrEntityNotFound)
+
+	key := &entity.DataKey{
+		CreatedAt:   time.Now().UTC().Truncate(time.Microsecond),
+		OwnerID:     ownerID,
+		MasterKeyID: "old",
+		Wrapped:     []byte("wrapped-old"),
+	}
+	require.NoError(t, stor.AddDataKey(ctx, key))
+	require.ErrorIs(t, stor.AddDataKey(ctx, key), storage.ErrEntityAlreadyExists)
+
+	count, err := stor.RewrapDataKeys(ctx, func(key *entity.DataKey) (*entity.DataKey, error) {
+		if key.OwnerID != ownerID {
+			return nil, nil //nolint:nilnil // ключ другого владельца не меняется
+		}
+
+		//nolint:exhaustruct // остальные поля сохраняются хранилищем
+		return &entity.DataKey{MasterKeyID: "new", Wrapped: []byte("wrapped-new")}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	got, err := stor.GetDataKey(ctx, ownerID)
+	require.NoError(t, err)
+	assert.Equal(t, ownerID, got.OwnerID)
+	assert.Equal(t, "new", got.MasterKeyID)
+	assert.Equal(t, []byte("wrapped-new"), got.Wrapped)
+	assert.True(t, key.CreatedAt.Equal(got.CreatedAt))
+
+	_, err = stor.RewrapDataKeys(ctx, func(*entity.DataKey) (*entity.DataKey, error) {
+		return nil, assert.AnError
+	})
+	require.ErrorIs(t, err, assert.AnError)
+}
+
+/*
+	===== BoltStorage.Shares =====
+*/
+
+func TestBoltStorage_Shares(t *testing.T) {
+	t.Parallel()
+
+	stor := newTestBoltStorage(t, filepath.Join(t.TempDir(), "data.db"))
+	defer func() { _ = stor.Close() }()
+
+	checkShareStorage(t, stor)
+}
+
+func TestBoltStorage_PurgedItemShares(t *testing.T) {
+	t.Parallel()
+
+	stor := newTestBoltStorage(t, filepath.Join(t.TempDir(), "data.db"))
+	defer func() { _ = stor.Close() }()
+
+	checkPurgedItemShares(t, stor)
+}
+
+/*
+	===== BoltStorage.Orgs =====
+*/
+
+func TestBoltStorage_Orgs(t *testing.T) {
+	t.Parallel()
+
+	stor := newTestBoltStorage(t, filepath.Join(t.TempDir(), "data.db"))
+	defer func() { _ = stor.Close() }()
+
+	checkOrgStorage(t, stor)
+}
+
+/*
+	===== BoltStorage.Folders =====
+*/
+
+func TestBoltStorage_Folders(t *testing.T) {
+	t.Parallel()
+
+	stor := newTestBoltStorage(t, filepath.Join(t.TempDir(), "data.db"))
+	defer func() { _ = stor.Close() }()
+
+	checkFolderStorage(t, stor)
+}
+
+/*
+	===== BoltStorage.Snapshot / Restore =====
+*/
+
+func TestBoltStorage_SnapshotRestore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	source := newTestBoltStorage(t, filepath.Join(dir, "source.db"))
+	defer func() { _ = source.Close() }()
+
+	userID := fillBackupStorage(t, source)
+
+	snap, err := source.Snapshot(ctx)
+	require.NoError(t, err)
+	require.Len(t, snap.Vaults, 1)
+	assert.Equal(t, int64(5), snap.Vaults[0].Seq)
+	assert.Len(t, snap.Vaults[0].Folders, 1)
+	assert.Len(t, snap.Vaults[0].Revisions, 2)
+
+	// Снимок переносится между хранилищами разных видов.
+	memory := storage.NewMemoryStorage()
+	require.NoError(t, memory.Restore(ctx, snap))
+
+	memorySnap, err := memory.Snapshot(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, snap, memorySnap)
+
+	target := newTestBoltStorage(t, filepath.Join(dir, "target.db"))
+	defer func() { _ = target.Close() }()
+
+	require.NoError(t, target.Restore(ctx, memorySnap))
+
+	restoredSnap, err := target.Snapshot(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, snap, restoredSnap)
+
+	require.ErrorIs(t, target.Restore(ctx, snap), storage.ErrStorageNotEmpty)
+
+	checkRestoredStorage(t, target, userID)
+}
+
+func TestBoltStorage_RestoreNotEmpty(t *testing.T) {
+	t.Parallel()
+
+	storagetest.RunRestore(t, func(t *testing.T) storage.IBackupStorage {
+		t.Helper()
+
+		stor := newTestBoltStorage(t, filepath.Join(t.TempDir(), "data.db"))
+		t.Cleanup(func() { _ = stor.Close() })
+
+		return stor
+	})
+}
+
+/*
+	===== BoltStorage conformance =====
+*/
+
+func TestBoltStorage_Conformance(t *testing.T) {
+	t.Parallel()
+
+	storagetest.Run(t, func(t *testing.T) storagetest.Storage {
+		t.Helper()
+
+		stor := newTestBoltStorage(t, filepath.Join(t.TempDir(), "data.db"))
+		t.Cleanup(func() { _ = stor.Close() })
+
+		return stor
+	})
+}
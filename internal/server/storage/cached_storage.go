@@ -0,0 +1,542 @@
+// Package storage предоставляет функциональность хранилища.
+package storage
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+)
+
+// CacheStats описывает статистику кэша хранилища.
+type CacheStats struct {
+	Hits      int64 `json:"hits"`      // запросы, обслуженные кэшем
+	Misses    int64 `json:"misses"`    // запросы, переданные хранилищу
+	Evictions int64 `json:"evictions"` // записи кэша, вытесненные из-за размера
+	Entries   int   `json:"entries"`   // текущее количество записей кэша
+	Size      int   `json:"size"`      // текущий размер кэша в записях хранилища
+}
+
+// cacheKey - ключ записи кэша: список записей пользователя (пустой itemID) или одна запись.
+type cacheKey struct {
+	ownerID string
+	itemID  string
+}
+
+// cacheEntry - запись кэша.
+type cacheEntry struct {
+	expiresAt time.Time           // момент устаревания (нулевой - без ограничения)
+	item      *entity.VaultItem   // запись (для ключа записи)
+	items     []*entity.VaultItem // список записей (для ключа списка)
+	key       cacheKey
+	size      int // размер в записях хранилища
+}
+
+// CachedStorage кэширует в памяти списки записей пользователей и отдельные записи,
+// прочитанные из хранилища, и передаёт хранилищу все остальные вызовы.
+//
+// Размер кэша ограничен количеством записей хранилища в нём: при превышении
+// вытесняются давно не использованные записи кэша. Запись кэша устаревает
+// через ttl. Любое изменение записей пользователя через CachedStorage
+// сбрасывает все его записи кэша.
+//
+// Изменения, сделанные в обход CachedStorage (например, другим экземпляром
+// сервера с той же базой данных), становятся видны не позже, чем через ttl.
+// CachedStorage может быть базовым хранилищем EncryptedStorage: тогда кэш
+// хранит записи в зашифрованном виде.
+type CachedStorage struct {
+	base    IEncryptableStorage
+	entries map[cacheKey]*list.Element
+	owners  map[string]map[cacheKey]struct{} // ownerID -> ключи его записей кэша
+	gens    map[string]uint64                // ownerID -> счётчик изменений его записей
+	reads   map[string]int                   // ownerID -> чтения из хранилища для сохранения в кэш
+	lru     *list.List                       // записи кэша, начиная с последней использованной
+	stats   CacheStats
+	maxSize int
+	ttl     time.Duration
+	mu      sync.Mutex
+}
+
+// NewCachedStorage создаёт и инициализирует новый экзепляр *CachedStorage.
+//
+// Параметры:
+//   - base: кэшируемое хранилище;
+//   - maxSize: наибольшее количество записей хранилища в кэше;
+//   - ttl: время жизни записи кэша (0 - до вытеснения или изменения).
+func NewCachedStorage(base IEncryptableStorage, maxSize int, ttl time.Duration) *CachedStorage {
+	return &CachedStorage{
+		base:    base,
+		entries: make(map[cacheKey]*list.Element),
+		owners:  make(map[string]map[cacheKey]struct{}),
+		gens:    make(map[string]uint64),
+		reads:   make(map[string]int),
+		lru:     list.New(),
+		stats:   CacheStats{Hits: 0, Misses: 0, Evictions: 0, Entries: 0, Size: 0},
+		maxSize: maxSize,
+		ttl:     ttl,
+		mu:      sync.Mutex{},
+	}
+}
+
+// Stats возвращает статистику кэша.
+func (c *CachedStorage) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.lru.Len()
+
+	return stats
+}
+
+// AddNewUser создаёт нового пользователя.
+func (c *CachedStorage) AddNewUser(ctx context.Context, user *entity.User) (string, error) {
+	return c.base.AddNewUser(ctx, user) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// FindUserByEmail производит поиск пользователя по Email.
+func (c *CachedStorage) FindUserByEmail(ctx context.Context, email string) (*entity.User, error) {
+	return c.base.FindUserByEmail(ctx, email) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// AddNewToken регистрирует новый токен.
+func (c *CachedStorage) AddNewToken(
+	ctx context.Context,
+	userID string,
+	token *entity.Token,
+) (string, error) {
+	return c.base.AddNewToken(ctx, userID, token) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// IsTokenByUserID производит поиск токена для пользователя по UserID.
+func (c *CachedStorage) IsTokenByUserID(ctx context.Context, userID string) bool {
+	return c.base.IsTokenByUserID(ctx, userID)
+}
+
+// DeleteToken удаляет токен.
+func (c *CachedStorage) DeleteToken(ctx context.Context, userID string) error {
+	return c.base.DeleteToken(ctx, userID) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// GetDataKey получает ключ данных владельца.
+func (c *CachedStorage) GetDataKey(ctx context.Context, ownerID string) (*entity.DataKey, error) {
+	return c.base.GetDataKey(ctx, ownerID) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// AddDataKey сохраняет ключ данных владельца.
+func (c *CachedStorage) AddDataKey(ctx context.Context, key *entity.DataKey) error {
+	return c.base.AddDataKey(ctx, key) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// RewrapDataKeys заменяет зашифрованные ключи данных.
+func (c *CachedStorage) RewrapDataKeys(
+	ctx context.Context,
+	rewrap func(key *entity.DataKey) (*entity.DataKey, error),
+) (int, error) {
+	return c.base.RewrapDataKeys(ctx, rewrap) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// CreateItem создаёт новую запись с паролем.
+func (c *CachedStorage) CreateItem(ctx context.Context, item *entity.VaultItem) (string, error) {
+	defer c.invalidate(item.OwnerID)
+
+	return c.base.CreateItem(ctx, item) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// UpdateItem обновляет запись с паролем.
+func (c *CachedStorage) UpdateItem(ctx context.Context, item *entity.VaultItem) error {
+	defer c.invalidate(item.OwnerID)
+
+	return c.base.UpdateItem(ctx, item) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// UpsertItem обновляет запись с паролем или создаёт её при отсутствии.
+func (c *CachedStorage) UpsertItem(ctx context.Context, item *entity.VaultItem) (string, error) {
+	defer c.invalidate(item.OwnerID)
+
+	return c.base.UpsertItem(ctx, item) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// GetItem получает текущую запись с паролем по ID.
+//
+// Запись ищется в кэше отдельно и в кэшированном списке записей пользователя.
+func (c *CachedStorage) GetItem(ctx context.Context, ownerID, id string) (*entity.VaultItem, error) {
+	if cached := c.cachedItem(ownerID, id); cached != nil {
+		if cached.IsExpired(time.Now()) {
+			return nil, fmt.Errorf("item: %w", ErrEntityNotFound)
+		}
+
+		return cached, nil
+	}
+
+	gen := c.beginRead(ownerID)
+	defer c.endRead(ownerID)
+
+	item, err := c.base.GetItem(ctx, ownerID, id)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // ошибка хранилища передаётся как есть
+	}
+
+	//nolint:exhaustruct // expiresAt заполняется при сохранении
+	c.store(gen, &cacheEntry{
+		item:  cloneItem(item),
+		items: nil,
+		key:   cacheKey{ownerID: ownerID, itemID: id},
+		size:  1,
+	})
+
+	return item, nil
+}
+
+// ListItems получает список записей с паролями по пользователю.
+func (c *CachedStorage) ListItems(ctx context.Context, ownerID string) ([]*entity.VaultItem, error) {
+	if cached := c.cachedList(ownerID); cached != nil {
+		return liveItems(cached, time.Now()), nil
+	}
+
+	gen := c.beginRead(ownerID)
+	defer c.endRead(ownerID)
+
+	items, err := c.base.ListItems(ctx, ownerID)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // ошибка хранилища передаётся как есть
+	}
+
+	//nolint:exhaustruct // expiresAt заполняется при сохранении
+	c.store(gen, &cacheEntry{
+		item:  nil,
+		items: cloneItems(items),
+		key:   cacheKey{ownerID: ownerID, itemID: ""},
+		size:  len(items) + 1,
+	})
+
+	return items, nil
+}
+
+// QueryItems получает записи пользователя по условиям выборки.
+//
+// Если список записей пользователя есть в кэше, выборка выполняется по нему.
+// Иначе выборка выполняется хранилищем (с его индексами) и не кэшируется.
+func (c *CachedStorage) QueryItems(
+	ctx context.Context,
+	ownerID string,
+	query *entity.ItemQuery,
+) ([]*entity.VaultItem, error) {
+	if cached := c.cachedList(ownerID); cached != nil {
+		return queryItems(liveItems(cached, time.Now()), query), nil
+	}
+
+	return c.base.QueryItems(ctx, ownerID, query) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// ListTags получает метки записей пользователя с количеством записей.
+func (c *CachedStorage) ListTags(ctx context.Context, ownerID string) ([]entity.TagCount, error) {
+	return c.base.ListTags(ctx, ownerID) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// SetItemContent заменяет содержимое бинарной записи.
+func (c *CachedStorage) SetItemContent(
+	ctx context.Context,
+	ownerID, itemID string,
+	version int64,
+	content *entity.Content,
+) (*entity.VaultItem, error) {
+	defer c.invalidate(ownerID)
+
+	//nolint:wrapcheck // ошибка хранилища передаётся как есть
+	return c.base.SetItemContent(ctx, ownerID, itemID, version, content)
+}
+
+// ContentHashes возвращает хеши содержимого, на которое ссылаются записи.
+func (c *CachedStorage) ContentHashes(ctx context.Context) ([]string, error) {
+	return c.base.ContentHashes(ctx) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// DeleteItem удаляет запись и помещает её в корзину.
+func (c *CachedStorage) DeleteItem(ctx context.Context, ownerID, id string) error {
+	defer c.invalidate(ownerID)
+
+	return c.base.DeleteItem(ctx, ownerID, id) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// ListTrash получает записи пользователя в корзине.
+func (c *CachedStorage) ListTrash(ctx context.Context, ownerID string) ([]*entity.TrashItem, error) {
+	return c.base.ListTrash(ctx, ownerID) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// RestoreTrash восстанавливает запись из корзины.
+func (c *CachedStorage) RestoreTrash(ctx context.Context, ownerID, itemID string) (*entity.VaultItem, error) {
+	defer c.invalidate(ownerID)
+
+	return c.base.RestoreTrash(ctx, ownerID, itemID) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// EmptyTrash безвозвратно удаляет записи из корзины пользователя.
+func (c *CachedStorage) EmptyTrash(ctx context.Context, ownerID string) (int, error) {
+	return c.base.EmptyTrash(ctx, ownerID) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// PurgeTrash безвозвратно удаляет записи, помещённые в корзину раньше before.
+func (c *CachedStorage) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	return c.base.PurgeTrash(ctx, before) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// ExpireItems удаляет записи с истёкшим сроком.
+//
+// Кэш не сбрасывается: записи с истёкшим сроком и так не выдаются из кэша.
+func (c *CachedStorage) ExpireItems(ctx context.Context, now time.Time) (int, error) {
+	return c.base.ExpireItems(ctx, now) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// GetUsage получает объём данных пользователя.
+func (c *CachedStorage) GetUsage(ctx context.Context, ownerID string) (*entity.Usage, error) {
+	return c.base.GetUsage(ctx, ownerID) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// ListChanges получает изменения пользователя после afterSeq.
+func (c *CachedStorage) ListChanges(
+	ctx context.Context,
+	ownerID string,
+	afterSeq int64,
+	limit int,
+) ([]*entity.VaultItem, error) {
+	return c.base.ListChanges(ctx, ownerID, afterSeq, limit) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// ListRevisions получает прежние версии записи.
+func (c *CachedStorage) ListRevisions(
+	ctx context.Context,
+	ownerID, itemID string,
+) ([]*entity.Revision, error) {
+	return c.base.ListRevisions(ctx, ownerID, itemID) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// GetRevision получает прежнюю версию записи по номеру версии.
+func (c *CachedStorage) GetRevision(
+	ctx context.Context,
+	ownerID, itemID string,
+	version int64,
+) (*entity.Revision, error) {
+	return c.base.GetRevision(ctx, ownerID, itemID, version) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// CompactRevisions удаляет прежние версии, заменённые раньше before.
+func (c *CachedStorage) CompactRevisions(ctx context.Context, before time.Time) (int, error) {
+	return c.base.CompactRevisions(ctx, before) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// ApplyChanges применяет пакет изменений пользователя.
+func (c *CachedStorage) ApplyChanges(
+	ctx context.Context,
+	ownerID string,
+	changes []*entity.Change,
+) ([]*entity.ChangeResult, error) {
+	defer c.invalidate(ownerID)
+
+	return c.base.ApplyChanges(ctx, ownerID, changes) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// CompactTombstones удаляет отметки об удалении, созданные раньше before.
+func (c *CachedStorage) CompactTombstones(ctx context.Context, before time.Time) (int, error) {
+	return c.base.CompactTombstones(ctx, before) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// cachedItem ищет запись в кэше отдельно и в кэшированном списке записей пользователя.
+// Возвращает копию записи (nil - промах).
+func (c *CachedStorage) cachedItem(ownerID, itemID string) *entity.VaultItem {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry := c.get(cacheKey{ownerID: ownerID, itemID: itemID}); entry != nil {
+		c.stats.Hits++
+
+		return cloneItem(entry.item)
+	}
+
+	if entry := c.get(cacheKey{ownerID: ownerID, itemID: ""}); entry != nil {
+		for _, item := range entry.items {
+			if item.ID == itemID {
+				c.stats.Hits++
+
+				return cloneItem(item)
+			}
+		}
+	}
+
+	c.stats.Misses++
+
+	return nil
+}
+
+// cachedList ищет в кэше список записей пользователя.
+// Возвращает копию списка (nil - промах).
+func (c *CachedStorage) cachedList(ownerID string) []*entity.VaultItem {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry := c.get(cacheKey{ownerID: ownerID, itemID: ""}); entry != nil {
+		c.stats.Hits++
+
+		return cloneItems(entry.items)
+	}
+
+	c.stats.Misses++
+
+	return nil
+}
+
+// beginRead отмечает начало чтения из хранилища для сохранения в кэш и возвращает
+// счётчик изменений пользователя для store. Пока чтение не завершено вызовом endRead,
+// счётчик пользователя не удаляется.
+func (c *CachedStorage) beginRead(ownerID string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.reads[ownerID]++
+
+	return c.gens[ownerID]
+}
+
+// endRead отмечает завершение чтения, начатого beginRead.
+func (c *CachedStorage) endRead(ownerID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.reads[ownerID]--
+	if c.reads[ownerID] == 0 {
+		delete(c.reads, ownerID)
+	}
+
+	c.prune(ownerID)
+}
+
+// prune удаляет счётчик изменений пользователя, если у него нет записей кэша
+// и незавершённых чтений: счётчик нужен только для сравнения в store (вызывается под блокировкой).
+func (c *CachedStorage) prune(ownerID string) {
+	if _, ok := c.owners[ownerID]; ok {
+		return
+	}
+
+	if _, ok := c.reads[ownerID]; ok {
+		return
+	}
+
+	delete(c.gens, ownerID)
+}
+
+// get возвращает действующую запись кэша или nil (вызывается под блокировкой).
+// Устаревшая запись удаляется.
+func (c *CachedStorage) get(key cacheKey) *cacheEntry {
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+
+	entry, _ := elem.Value.(*cacheEntry)
+	if !entry.expiresAt.IsZero() && !time.Now().Before(entry.expiresAt) {
+		c.remove(elem)
+
+		return nil
+	}
+
+	c.lru.MoveToFront(elem)
+
+	return entry
+}
+
+// store сохраняет прочитанное из хранилища в кэш, если с начала чтения (beginRead)
+// записи пользователя не изменялись: иначе прочитанное могло устареть.
+func (c *CachedStorage) store(gen uint64, entry *cacheEntry) {
+	if entry.size > c.maxSize {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.gens[entry.key.ownerID] != gen {
+		return
+	}
+
+	if elem, ok := c.entries[entry.key]; ok {
+		c.remove(elem)
+	}
+
+	if c.ttl > 0 {
+		entry.expiresAt = time.Now().Add(c.ttl)
+	}
+
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	c.stats.Size += entry.size
+
+	if _, ok := c.owners[entry.key.ownerID]; !ok {
+		c.owners[entry.key.ownerID] = make(map[cacheKey]struct{})
+	}
+
+	c.owners[entry.key.ownerID][entry.key] = struct{}{}
+
+	for c.stats.Size > c.maxSize {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+// invalidate сбрасывает записи кэша пользователя после изменения его записей.
+func (c *CachedStorage) invalidate(ownerID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gens[ownerID]++
+
+	for key := range c.owners[ownerID] {
+		c.remove(c.entries[key])
+	}
+
+	c.prune(ownerID)
+}
+
+// remove удаляет запись кэша (вызывается под блокировкой).
+func (c *CachedStorage) remove(elem *list.Element) {
+	entry, _ := c.lru.Remove(elem).(*cacheEntry)
+
+	delete(c.entries, entry.key)
+	delete(c.owners[entry.key.ownerID], entry.key)
+
+	if len(c.owners[entry.key.ownerID]) == 0 {
+		delete(c.owners, entry.key.ownerID)
+		c.prune(entry.key.ownerID)
+	}
+
+	c.stats.Size -= entry.size
+}
+
+// cloneItem копирует запись, чтобы изменения копии не затрагивали кэш.
+func cloneItem(item *entity.VaultItem) *entity.VaultItem {
+	res := *item
+	res.Meta = maps.Clone(item.Meta)
+	res.Tags = slices.Clone(item.Tags)
+	res.Attachments = slices.Clone(item.Attachments)
+
+	if item.Content != nil {
+		content := *item.Content
+		res.Content = &content
+	}
+
+	return &res
+}
+
+// cloneItems копирует список записей (см. cloneItem).
+func cloneItems(items []*entity.VaultItem) []*entity.VaultItem {
+	res := make([]*entity.VaultItem, 0, len(items))
+
+	for _, item := range items {
+		res = append(res, cloneItem(item))
+	}
+
+	return res
+}
@@ -0,0 +1,271 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/storagetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+	===== CachedStorage reads =====
+*/
+
+func TestCachedStorage_ReadThrough(t *testing.T) {
+	t.Parallel()
+
+	base := storage.NewMemoryStorage()
+	stor := storage.NewCachedStorage(base, 100, 0)
+	ctx := context.Background()
+
+	//nolint:exhaustruct // not all fields needed in test
+	item := &entity.VaultItem{OwnerID: "user-1", Title: "Email", Meta: map[string]string{"site": "a"}}
+
+	itemID, err := stor.CreateItem(ctx, item)
+	require.NoError(t, err)
+
+	list, err := stor.ListItems(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+
+	list[0].Meta["site"] = "changed by caller"
+
+	got, err := stor.GetItem(ctx, "user-1", itemID)
+	require.NoError(t, err)
+	assert.Equal(t, "a", got.Meta["site"], "cached items are copies")
+
+	query, err := stor.QueryItems(ctx, "user-1", &entity.ItemQuery{Title: "mail"}) //nolint:exhaustruct // test query
+	require.NoError(t, err)
+	assert.Len(t, query, 1)
+
+	assert.Equal(t, storage.CacheStats{Hits: 2, Misses: 1, Evictions: 0, Entries: 1, Size: 2}, stor.Stats())
+
+	// Изменение в обход кэша не видно, пока не истечёт время жизни записи кэша.
+	//nolint:exhaustruct // not all fields needed in test
+	require.NoError(t, base.UpdateItem(ctx, &entity.VaultItem{ID: itemID, OwnerID: "user-1", Title: "Bypass"}))
+
+	got, err = stor.GetItem(ctx, "user-1", itemID)
+	require.NoError(t, err)
+	assert.Equal(t, "Email", got.Title)
+
+	_, err = stor.GetItem(ctx, "user-1", "missing")
+	require.ErrorIs(t, err, storage.ErrEntityNotFound)
+}
+
+func TestCachedStorage_QueryItems_Miss(t *testing.T) {
+	t.Parallel()
+
+	base := storage.NewMemoryStorage()
+	stor := storage.NewCachedStorage(base, 100, 0)
+	ctx := context.Background()
+
+	for _, title := range []string{"Email", "Bank"} {
+		//nolint:exhaustruct // not all fields needed in test
+		_, err := base.CreateItem(ctx, &entity.VaultItem{OwnerID: "user-1", Title: title})
+		require.NoError(t, err)
+	}
+
+	query, err := stor.QueryItems(ctx, "user-1", &entity.ItemQuery{Title: "mail"}) //nolint:exhaustruct // test query
+	require.NoError(t, err)
+	require.Len(t, query, 1)
+	assert.Equal(t, "Email", query[0].Title)
+
+	assert.Equal(t, storage.CacheStats{Hits: 0, Misses: 1, Evictions: 0, Entries: 0, Size: 0}, stor.Stats(),
+		"query without a cached list is done by the storage and does not load the whole list")
+}
+
+func TestCachedStorage_Invalidate(t *testing.T) {
+	t.Parallel()
+
+	stor := storage.NewCachedStorage(storage.NewMemoryStorage(), 100, 0)
+	ctx := context.Background()
+
+	//nolint:exhaustruct // not all fields needed in test
+	item := &entity.VaultItem{OwnerID: "user-1", Title: "Email"}
+
+	itemID, err := stor.CreateItem(ctx, item)
+	require.NoError(t, err)
+
+	//nolint:exhaustruct // not all fields needed in test
+	other := &entity.VaultItem{OwnerID: "user-2", Title: "Other"}
+
+	_, err = stor.CreateItem(ctx, other)
+	require.NoError(t, err)
+
+	_, err = stor.ListItems(ctx, "user-1")
+	require.NoError(t, err)
+
+	_, err = stor.ListItems(ctx, "user-2")
+	require.NoError(t, err)
+
+	//nolint:exhaustruct // not all fields needed in test
+	require.NoError(t, stor.UpdateItem(ctx, &entity.VaultItem{ID: itemID, OwnerID: "user-1", Title: "Updated"}))
+	assert.Equal(t, 1, stor.Stats().Entries, "only the owner's entries are dropped")
+
+	got, err := stor.GetItem(ctx, "user-1", itemID)
+	require.NoError(t, err)
+	assert.Equal(t, "Updated", got.Title)
+
+	require.NoError(t, stor.DeleteItem(ctx, "user-1", itemID))
+
+	_, err = stor.GetItem(ctx, "user-1", itemID)
+	require.ErrorIs(t, err, storage.ErrEntityNotFound)
+
+	_, err = stor.RestoreTrash(ctx, "user-1", itemID)
+	require.NoError(t, err)
+
+	list, err := stor.ListItems(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Len(t, list, 1)
+
+	results, err := stor.ApplyChanges(ctx, "user-1", []*entity.Change{
+		{Item: &entity.VaultItem{ID: itemID}, Op: entity.ChangeDelete}, //nolint:exhaustruct // delete needs only ID
+	})
+	require.NoError(t, err)
+	require.Equal(t, entity.ChangeApplied, results[0].Status)
+
+	list, err = stor.ListItems(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Empty(t, list)
+}
+
+// pausedStorage приостанавливает GetItem после чтения записи из хранилища,
+// пока не будет закрыт resume.
+type pausedStorage struct {
+	*storage.MemoryStorage
+
+	read   chan struct{}
+	resume chan struct{}
+}
+
+func (s *pausedStorage) GetItem(ctx context.Context, ownerID, id string) (*entity.VaultItem, error) {
+	item, err := s.MemoryStorage.GetItem(ctx, ownerID, id)
+
+	close(s.read)
+	<-s.resume
+
+	return item, err //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+func TestCachedStorage_InvalidateDuringRead(t *testing.T) {
+	t.Parallel()
+
+	base := &pausedStorage{
+		MemoryStorage: storage.NewMemoryStorage(),
+		read:          make(chan struct{}),
+		resume:        make(chan struct{}),
+	}
+	stor := storage.NewCachedStorage(base, 100, 0)
+	ctx := context.Background()
+
+	//nolint:exhaustruct // not all fields needed in test
+	itemID, err := base.CreateItem(ctx, &entity.VaultItem{OwnerID: "user-1", Title: "Email"})
+	require.NoError(t, err)
+
+	done := make(chan *entity.VaultItem)
+
+	go func() {
+		item, _ := stor.GetItem(ctx, "user-1", itemID)
+		done <- item
+	}()
+
+	<-base.read
+
+	// Изменение во время чтения: у пользователя ещё нет записей кэша.
+	//nolint:exhaustruct // not all fields needed in test
+	require.NoError(t, stor.UpdateItem(ctx, &entity.VaultItem{ID: itemID, OwnerID: "user-1", Title: "Updated"}))
+
+	close(base.resume)
+	require.Equal(t, "Email", (<-done).Title)
+
+	assert.Zero(t, stor.Stats().Entries, "item read before the update is not cached")
+
+	base.read = make(chan struct{})
+
+	got, err := stor.GetItem(ctx, "user-1", itemID)
+	require.NoError(t, err)
+	assert.Equal(t, "Updated", got.Title)
+}
+
+/*
+	===== CachedStorage bounds =====
+*/
+
+func TestCachedStorage_Bounds(t *testing.T) {
+	t.Parallel()
+
+	stor := storage.NewCachedStorage(storage.NewMemoryStorage(), 3, 500*time.Millisecond)
+	ctx := context.Background()
+	ids := make([]string, 0, 3)
+
+	for _, title := range []string{"First", "Second", "Third"} {
+		//nolint:exhaustruct // not all fields needed in test
+		itemID, err := stor.CreateItem(ctx, &entity.VaultItem{OwnerID: "user-1", Title: title})
+		require.NoError(t, err)
+
+		ids = append(ids, itemID)
+	}
+
+	_, err := stor.ListItems(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Zero(t, stor.Stats().Entries, "list larger than the cache is not cached")
+
+	for _, itemID := range ids {
+		_, err := stor.GetItem(ctx, "user-1", itemID)
+		require.NoError(t, err)
+	}
+
+	_, err = stor.GetItem(ctx, "user-1", ids[0])
+	require.NoError(t, err)
+
+	stats := stor.Stats()
+	assert.Equal(t, 3, stats.Size)
+	assert.Zero(t, stats.Evictions)
+	assert.Equal(t, int64(1), stats.Hits)
+
+	_, err = stor.ListItems(ctx, "user-2")
+	require.NoError(t, err)
+
+	stats = stor.Stats()
+	assert.Equal(t, 3, stats.Size)
+	assert.Equal(t, int64(1), stats.Evictions, "least recently used entry is evicted")
+
+	_, err = stor.GetItem(ctx, "user-1", ids[0])
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stor.Stats().Hits)
+
+	time.Sleep(600 * time.Millisecond)
+
+	_, err = stor.GetItem(ctx, "user-1", ids[0])
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stor.Stats().Hits, "expired entry is read again")
+}
+
+/*
+	===== CachedStorage conformance =====
+*/
+
+func TestCachedStorage_Conformance(t *testing.T) {
+	t.Parallel()
+
+	storagetest.Run(t, func(*testing.T) storagetest.Storage {
+		return storage.NewCachedStorage(storage.NewMemoryStorage(), 1000, time.Minute)
+	})
+}
+
+func TestCachedStorage_EncryptedConformance(t *testing.T) {
+	t.Parallel()
+
+	storagetest.Run(t, func(t *testing.T) storagetest.Storage {
+		t.Helper()
+
+		cache := storage.NewCachedStorage(storage.NewMemoryStorage(), 1000, time.Minute)
+
+		return storage.NewEncryptedStorage(cache, newTestKeyring(t, "master"))
+	})
+}
@@ -0,0 +1,919 @@
+// Package storage предоставляет функциональность хранилища.
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/crypto/envelope"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+)
+
+const (
+	// usersKeyOwner - владелец ключа данных, которым шифруются Email пользователей.
+	// Email нужен для поиска пользователя до того, как известен его ID,
+	// поэтому он шифруется общим ключом, а не ключом пользователя.
+	usersKeyOwner = "*users"
+
+	// sealedMetaKey - ключ метаинформации, под которым хранится вся
+	// зашифрованная метаинформация записи.
+	sealedMetaKey = "enc"
+)
+
+// ErrNoDataKey - данные зашифрованы, но ключа данных владельца нет в хранилище.
+var ErrNoDataKey = errors.New("data key not found")
+
+// IEncryptableStorage - хранилище, данные которого шифрует EncryptedStorage.
+type IEncryptableStorage interface {
+	IUserStorage
+	IStorage
+	IKeyStorage
+}
+
+// EncryptedStorage шифрует данные пользователей перед сохранением в хранилище
+// и расшифровывает их при чтении.
+//
+// Шифруются Email пользователя и название, описание, имя пользователя,
+// метаинформация и метки записей (Data записи шифрует клиент). Данные каждого
+// пользователя шифруются его ключом данных, который создаётся при первой
+// записи и хранится в хранилище зашифрованным мастер-ключом сервера.
+//
+// Email шифруется детерминированно (после приведения к нижнему регистру),
+// чтобы хранилище могло искать пользователя по нему. Названия папок теми же
+// ключами данных шифрует EncryptedFolderStorage.
+//
+// Значения, сохранённые до включения шифрования, читаются как есть и шифруются
+// при следующем изменении. Выборка записей (QueryItems) выполняется по
+// расшифрованным записям, а квота учитывает размер зашифрованных значений.
+type EncryptedStorage struct {
+	base    IEncryptableStorage
+	keyring *envelope.Keyring
+	ciphers map[string]*envelope.Cipher // ownerID -> шифратор ключа данных
+	mu      sync.Mutex
+}
+
+// NewEncryptedStorage создаёт и инициализирует новый экзепляр *EncryptedStorage.
+//
+// Параметры:
+//   - base: хранилище зашифрованных данных и ключей данных;
+//   - keyring: мастер-ключи сервера.
+func NewEncryptedStorage(base IEncryptableStorage, keyring *envelope.Keyring) *EncryptedStorage {
+	return &EncryptedStorage{
+		base:    base,
+		keyring: keyring,
+		ciphers: make(map[string]*envelope.Cipher),
+		mu:      sync.Mutex{},
+	}
+}
+
+// RotateMasterKey перешифровывает текущим мастер-ключом ключи данных,
+// зашифрованные прежними мастер-ключами. Сами данные не перешифровываются.
+//
+// Возвращает количество перешифрованных ключей.
+func (s *EncryptedStorage) RotateMasterKey(ctx context.Context) (int, error) {
+	count, err := s.base.RewrapDataKeys(ctx, func(key *entity.DataKey) (*entity.DataKey, error) {
+		if key.MasterKeyID == s.keyring.CurrentID() {
+			return nil, nil //nolint:nilnil // ключ не требует замены
+		}
+
+		dataKey, err := s.keyring.Unwrap(key.Wrapped, key.MasterKeyID)
+		if err != nil {
+			return nil, err //nolint:wrapcheck // оборачивается хранилищем
+		}
+
+		wrapped, masterID, err := s.keyring.Wrap(dataKey)
+		if err != nil {
+			return nil, err //nolint:wrapcheck // оборачивается хранилищем
+		}
+
+		return &entity.DataKey{
+			CreatedAt:   key.CreatedAt,
+			OwnerID:     key.OwnerID,
+			MasterKeyID: masterID,
+			Wrapped:     wrapped,
+		}, nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("rotate master key: %w", err)
+	}
+
+	return count, nil
+}
+
+// AddNewUser создаёт нового пользователя.
+func (s *EncryptedStorage) AddNewUser(ctx context.Context, user *entity.User) (string, error) {
+	// Пользователь, сохранённый до включения шифрования, не найдётся по зашифрованному Email.
+	if _, err := s.base.FindUserByEmail(ctx, user.Email); err == nil {
+		return "", fmt.Errorf("user: %w", ErrEntityAlreadyExists)
+	}
+
+	ciph, err := s.cipher(ctx, usersKeyOwner, true)
+	if err != nil {
+		return "", err
+	}
+
+	sealed := *user
+	sealed.Email = sealEmail(ciph, user.Email)
+
+	return s.base.AddNewUser(ctx, &sealed) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// FindUserByEmail производит поиск пользователя по Email.
+func (s *EncryptedStorage) FindUserByEmail(ctx context.Context, email string) (*entity.User, error) {
+	ciph, err := s.cipher(ctx, usersKeyOwner, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if ciph != nil {
+		user, err := s.base.FindUserByEmail(ctx, sealEmail(ciph, email))
+		if err == nil {
+			user.Email, err = ciph.Open(user.Email, usersKeyOwner)
+			if err != nil {
+				return nil, fmt.Errorf("user: %w", err)
+			}
+
+			return user, nil
+		}
+
+		if !errors.Is(err, ErrEntityNotFound) {
+			return nil, err //nolint:wrapcheck // ошибка хранилища передаётся как есть
+		}
+	}
+
+	return s.base.FindUserByEmail(ctx, email) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// AddNewToken регистрирует новый токен.
+func (s *EncryptedStorage) AddNewToken(
+	ctx context.Context,
+	userID string,
+	token *entity.Token,
+) (string, error) {
+	return s.base.AddNewToken(ctx, userID, token) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// IsTokenByUserID производит поиск токена для пользователя по UserID.
+func (s *EncryptedStorage) IsTokenByUserID(ctx context.Context, userID string) bool {
+	return s.base.IsTokenByUserID(ctx, userID)
+}
+
+// DeleteToken удаляет токен.
+func (s *EncryptedStorage) DeleteToken(ctx context.Context, userID string) error {
+	return s.base.DeleteToken(ctx, userID) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// CreateItem создаёт новую запись с паролем.
+func (s *EncryptedStorage) CreateItem(ctx context.Context, item *entity.VaultItem) (string, error) {
+	sealed, err := s.sealItem(ctx, item)
+	if err != nil {
+		return "", err
+	}
+
+	id, err := s.base.CreateItem(ctx, sealed)
+	if err != nil {
+		return "", err //nolint:wrapcheck // ошибка хранилища передаётся как есть
+	}
+
+	setStoredState(item, sealed)
+
+	return id, nil
+}
+
+// UpdateItem обновляет текущую запись с паролем.
+func (s *EncryptedStorage) UpdateItem(ctx context.Context, item *entity.VaultItem) error {
+	sealed, err := s.sealItem(ctx, item)
+	if err != nil {
+		return err
+	}
+
+	if err := s.base.UpdateItem(ctx, sealed); err != nil {
+		return err //nolint:wrapcheck // ошибка хранилища передаётся как есть
+	}
+
+	setStoredState(item, sealed)
+
+	return nil
+}
+
+// UpsertItem обновляет или создаёт запись с паролем для синхронизации.
+func (s *EncryptedStorage) UpsertItem(ctx context.Context, item *entity.VaultItem) (string, error) {
+	sealed, err := s.sealItem(ctx, item)
+	if err != nil {
+		return "", err
+	}
+
+	id, err := s.base.UpsertItem(ctx, sealed)
+	if err != nil {
+		return "", err //nolint:wrapcheck // ошибка хранилища передаётся как есть
+	}
+
+	setStoredState(item, sealed)
+
+	return id, nil
+}
+
+// GetItem получает текущую запись с паролем по ID.
+func (s *EncryptedStorage) GetItem(ctx context.Context, ownerID, id string) (*entity.VaultItem, error) {
+	item, err := s.base.GetItem(ctx, ownerID, id)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // ошибка хранилища передаётся как есть
+	}
+
+	return s.openItem(ctx, item)
+}
+
+// ListItems получает список записей с паролями по пользователю.
+func (s *EncryptedStorage) ListItems(ctx context.Context, ownerID string) ([]*entity.VaultItem, error) {
+	items, err := s.base.ListItems(ctx, ownerID)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // ошибка хранилища передаётся как есть
+	}
+
+	return s.openItems(ctx, items)
+}
+
+// QueryItems получает записи пользователя по условиям выборки.
+//
+// Хранилище не может сравнивать зашифрованные названия и метаинформацию,
+// поэтому выборка выполняется по расшифрованным записям пользователя.
+func (s *EncryptedStorage) QueryItems(
+	ctx context.Context,
+	ownerID string,
+	query *entity.ItemQuery,
+) ([]*entity.VaultItem, error) {
+	items, err := s.ListItems(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return queryItems(items, query), nil
+}
+
+// ListTags получает метки записей пользователя с количеством записей.
+//
+// Метки хранятся зашифрованными, поэтому записи подсчитываются после расшифровки.
+func (s *EncryptedStorage) ListTags(ctx context.Context, ownerID string) ([]entity.TagCount, error) {
+	items, err := s.ListItems(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return countTags(items), nil
+}
+
+// SetItemContent заменяет содержимое бинарной записи.
+func (s *EncryptedStorage) SetItemContent(
+	ctx context.Context,
+	ownerID, itemID string,
+	version int64,
+	content *entity.Content,
+) (*entity.VaultItem, error) {
+	item, err := s.base.SetItemContent(ctx, ownerID, itemID, version, content)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // ошибка хранилища передаётся как есть
+	}
+
+	return s.openItem(ctx, item)
+}
+
+// ContentHashes получает хеши содержимого, на которое ссылаются данные хранилища.
+func (s *EncryptedStorage) ContentHashes(ctx context.Context) ([]string, error) {
+	return s.base.ContentHashes(ctx) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// DeleteItem удаляет запись и помещает её в корзину.
+func (s *EncryptedStorage) DeleteItem(ctx context.Context, ownerID, id string) error {
+	return s.base.DeleteItem(ctx, ownerID, id) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// ListTrash получает записи пользователя в корзине.
+func (s *EncryptedStorage) ListTrash(ctx context.Context, ownerID string) ([]*entity.TrashItem, error) {
+	trash, err := s.base.ListTrash(ctx, ownerID)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // ошибка хранилища передаётся как есть
+	}
+
+	for _, trashed := range trash {
+		if trashed.Item, err = s.openItem(ctx, trashed.Item); err != nil {
+			return nil, err
+		}
+	}
+
+	return trash, nil
+}
+
+// RestoreTrash восстанавливает запись из корзины.
+func (s *EncryptedStorage) RestoreTrash(ctx context.Context, ownerID, itemID string) (*entity.VaultItem, error) {
+	item, err := s.base.RestoreTrash(ctx, ownerID, itemID)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // ошибка хранилища передаётся как есть
+	}
+
+	return s.openItem(ctx, item)
+}
+
+// EmptyTrash безвозвратно удаляет записи из корзины пользователя.
+func (s *EncryptedStorage) EmptyTrash(ctx context.Context, ownerID string) (int, error) {
+	return s.base.EmptyTrash(ctx, ownerID) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// PurgeTrash безвозвратно удаляет записи, помещённые в корзину раньше before.
+func (s *EncryptedStorage) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	return s.base.PurgeTrash(ctx, before) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// ExpireItems удаляет записи с истёкшим сроком. Срок записи не шифруется.
+func (s *EncryptedStorage) ExpireItems(ctx context.Context, now time.Time) (int, error) {
+	return s.base.ExpireItems(ctx, now) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// GetUsage получает объём данных пользователя.
+func (s *EncryptedStorage) GetUsage(ctx context.Context, ownerID string) (*entity.Usage, error) {
+	return s.base.GetUsage(ctx, ownerID) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// ListChanges получает изменения пользователя после afterSeq.
+func (s *EncryptedStorage) ListChanges(
+	ctx context.Context,
+	ownerID string,
+	afterSeq int64,
+	limit int,
+) ([]*entity.VaultItem, error) {
+	items, err := s.base.ListChanges(ctx, ownerID, afterSeq, limit)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // ошибка хранилища передаётся как есть
+	}
+
+	return s.openItems(ctx, items)
+}
+
+// ListRevisions получает прежние версии записи.
+func (s *EncryptedStorage) ListRevisions(
+	ctx context.Context,
+	ownerID, itemID string,
+) ([]*entity.Revision, error) {
+	revs, err := s.base.ListRevisions(ctx, ownerID, itemID)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // ошибка хранилища передаётся как есть
+	}
+
+	for _, rev := range revs {
+		if rev.Item, err = s.openItem(ctx, rev.Item); err != nil {
+			return nil, err
+		}
+	}
+
+	return revs, nil
+}
+
+// GetRevision получает прежнюю версию записи по номеру версии.
+func (s *EncryptedStorage) GetRevision(
+	ctx context.Context,
+	ownerID, itemID string,
+	version int64,
+) (*entity.Revision, error) {
+	rev, err := s.base.GetRevision(ctx, ownerID, itemID, version)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // ошибка хранилища передаётся как есть
+	}
+
+	if rev.Item, err = s.openItem(ctx, rev.Item); err != nil {
+		return nil, err
+	}
+
+	return rev, nil
+}
+
+// CompactRevisions удаляет прежние версии, заменённые раньше before.
+func (s *EncryptedStorage) CompactRevisions(ctx context.Context, before time.Time) (int, error) {
+	return s.base.CompactRevisions(ctx, before) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// ApplyChanges применяет пакет изменений пользователя.
+func (s *EncryptedStorage) ApplyChanges(
+	ctx context.Context,
+	ownerID string,
+	changes []*entity.Change,
+) ([]*entity.ChangeResult, error) {
+	sealed := make([]*entity.Change, 0, len(changes))
+
+	for _, change := range changes {
+		item := *change.Item
+		item.OwnerID = ownerID
+
+		sealedItem, err := s.sealItem(ctx, &item)
+		if err != nil {
+			return nil, err
+		}
+
+		sealed = append(sealed, &entity.Change{Item: sealedItem, Op: change.Op, Invalid: change.Invalid})
+	}
+
+	results, err := s.base.ApplyChanges(ctx, ownerID, sealed)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // ошибка хранилища передаётся как есть
+	}
+
+	for _, result := range results {
+		if result.Item == nil {
+			continue
+		}
+
+		if result.Item, err = s.openItem(ctx, result.Item); err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// CompactTombstones удаляет отметки об удалении, созданные раньше before.
+func (s *EncryptedStorage) CompactTombstones(ctx context.Context, before time.Time) (int, error) {
+	return s.base.CompactTombstones(ctx, before) //nolint:wrapcheck // ошибка хранилища передаётся как есть
+}
+
+// cipher возвращает шифратор ключа данных владельца.
+//
+// Если ключа нет, при create он создаётся, иначе возвращается nil:
+// данные владельца ещё не шифровались.
+func (s *EncryptedStorage) cipher(ctx context.Context, ownerID string, create bool) (*envelope.Cipher, error) {
+	s.mu.Lock()
+	ciph, ok := s.ciphers[ownerID]
+	s.mu.Unlock()
+
+	if ok {
+		return ciph, nil
+	}
+
+	key, err := s.base.GetDataKey(ctx, ownerID)
+	if errors.Is(err, ErrEntityNotFound) {
+		if !create {
+			return nil, nil //nolint:nilnil // данные владельца не зашифрованы
+		}
+
+		key, err = s.addDataKey(ctx, ownerID)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("data key: %w", err)
+	}
+
+	dataKey, err := s.keyring.Unwrap(key.Wrapped, key.MasterKeyID)
+	if err != nil {
+		return nil, fmt.Errorf("data key: %w", err)
+	}
+
+	ciph, err = envelope.NewCipher(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("data key: %w", err)
+	}
+
+	s.mu.Lock()
+	s.ciphers[ownerID] = ciph
+	s.mu.Unlock()
+
+	return ciph, nil
+}
+
+// addDataKey создаёт и сохраняет ключ данных владельца. Если ключ
+// одновременно создан другим запросом, возвращается сохранённый ключ.
+func (s *EncryptedStorage) addDataKey(ctx context.Context, ownerID string) (*entity.DataKey, error) {
+	dataKey, err := envelope.NewDataKey()
+	if err != nil {
+		return nil, err //nolint:wrapcheck // оборачивается вызывающим
+	}
+
+	wrapped, masterID, err := s.keyring.Wrap(dataKey)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // оборачивается вызывающим
+	}
+
+	key := &entity.DataKey{
+		CreatedAt:   time.Now().UTC().Truncate(time.Microsecond),
+		OwnerID:     ownerID,
+		MasterKeyID: masterID,
+		Wrapped:     wrapped,
+	}
+
+	err = s.base.AddDataKey(ctx, key)
+	if errors.Is(err, ErrEntityAlreadyExists) {
+		return s.base.GetDataKey(ctx, ownerID) //nolint:wrapcheck // оборачивается вызывающим
+	}
+
+	if err != nil {
+		return nil, err //nolint:wrapcheck // оборачивается вызывающим
+	}
+
+	return key, nil
+}
+
+// sealItem возвращает копию записи с зашифрованными полями.
+func (s *EncryptedStorage) sealItem(ctx context.Context, item *entity.VaultItem) (*entity.VaultItem, error) {
+	ciph, err := s.cipher(ctx, item.OwnerID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// ID записи входит в дополнительные данные шифрования,
+	// поэтому новой записи он выдаётся до сохранения.
+	if item.ID == "" {
+		item.ID = uuid.New().String()
+	}
+
+	sealed := *item
+
+	fields := []struct {
+		dst  *string
+		name string
+	}{
+		{dst: &sealed.Title, name: "title"},
+		{dst: &sealed.Description, name: "desc"},
+		{dst: &sealed.Username, name: "username"},
+	}
+
+	for _, field := range fields {
+		if *field.dst, err = ciph.Seal(*field.dst, fieldAAD(item.OwnerID, item.ID, field.name)); err != nil {
+			return nil, fmt.Errorf("item %s: %w", field.name, err)
+		}
+	}
+
+	if len(item.Meta) > 0 {
+		meta, err := json.Marshal(item.Meta)
+		if err != nil {
+			return nil, fmt.Errorf("item meta: %w", err)
+		}
+
+		value, err := ciph.Seal(string(meta), fieldAAD(item.OwnerID, item.ID, "meta"))
+		if err != nil {
+			return nil, fmt.Errorf("item meta: %w", err)
+		}
+
+		sealed.Meta = map[string]string{sealedMetaKey: value}
+	}
+
+	if len(item.Tags) > 0 {
+		tags, err := json.Marshal(item.Tags)
+		if err != nil {
+			return nil, fmt.Errorf("item tags: %w", err)
+		}
+
+		value, err := ciph.Seal(string(tags), fieldAAD(item.OwnerID, item.ID, "tags"))
+		if err != nil {
+			return nil, fmt.Errorf("item tags: %w", err)
+		}
+
+		sealed.Tags = []string{value}
+	}
+
+	if sealed.Attachments, err = sealAttachments(ciph, item.OwnerID, item.ID, item.Attachments); err != nil {
+		return nil, err
+	}
+
+	return &sealed, nil
+}
+
+// openItem расшифровывает поля записи, полученной из хранилища.
+func (s *EncryptedStorage) openItem(ctx context.Context, item *entity.VaultItem) (*entity.VaultItem, error) {
+	if !isSealedItem(item) {
+		return item, nil
+	}
+
+	ciph, err := s.cipher(ctx, item.OwnerID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if ciph == nil {
+		return nil, fmt.Errorf("item %s: %w", item.ID, ErrNoDataKey)
+	}
+
+	opened := *item
+
+	fields := []struct {
+		dst  *string
+		name string
+	}{
+		{dst: &opened.Title, name: "title"},
+		{dst: &opened.Description, name: "desc"},
+		{dst: &opened.Username, name: "username"},
+	}
+
+	for _, field := range fields {
+		if *field.dst, err = ciph.Open(*field.dst, fieldAAD(item.OwnerID, item.ID, field.name)); err != nil {
+			return nil, fmt.Errorf("item %s %s: %w", item.ID, field.name, err)
+		}
+	}
+
+	if value, ok := item.Meta[sealedMetaKey]; ok && len(item.Meta) == 1 && envelope.IsSealed(value) {
+		meta, err := ciph.Open(value, fieldAAD(item.OwnerID, item.ID, "meta"))
+		if err != nil {
+			return nil, fmt.Errorf("item %s meta: %w", item.ID, err)
+		}
+
+		opened.Meta = nil
+		if err := json.Unmarshal([]byte(meta), &opened.Meta); err != nil {
+			return nil, fmt.Errorf("item %s meta: %w", item.ID, err)
+		}
+	}
+
+	if isSealedTags(item.Tags) {
+		tags, err := ciph.Open(item.Tags[0], fieldAAD(item.OwnerID, item.ID, "tags"))
+		if err != nil {
+			return nil, fmt.Errorf("item %s tags: %w", item.ID, err)
+		}
+
+		opened.Tags = nil
+		if err := json.Unmarshal([]byte(tags), &opened.Tags); err != nil {
+			return nil, fmt.Errorf("item %s tags: %w", item.ID, err)
+		}
+	}
+
+	if opened.Attachments, err = openAttachments(ciph, item.OwnerID, item.ID, item.Attachments); err != nil {
+		return nil, fmt.Errorf("item %s: %w", item.ID, err)
+	}
+
+	return &opened, nil
+}
+
+// openItems расшифровывает поля записей, полученных из хранилища.
+func (s *EncryptedStorage) openItems(ctx context.Context, items []*entity.VaultItem) ([]*entity.VaultItem, error) {
+	res := make([]*entity.VaultItem, 0, len(items))
+
+	for _, item := range items {
+		opened, err := s.openItem(ctx, item)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, opened)
+	}
+
+	return res, nil
+}
+
+// sealAttachments возвращает копию вложений с зашифрованными именами и типами содержимого.
+// Хеш и размер содержимого остаются открытыми: по ним учитывается квота и удаляется
+// содержимое без ссылок.
+func sealAttachments(
+	ciph *envelope.Cipher,
+	ownerID, itemID string,
+	atts []entity.Attachment,
+) ([]entity.Attachment, error) {
+	if atts == nil {
+		return nil, nil
+	}
+
+	res := make([]entity.Attachment, 0, len(atts))
+
+	for _, att := range atts {
+		var err error
+
+		if att.Name, err = ciph.Seal(att.Name, attachmentAAD(ownerID, itemID, att.ID, "name")); err != nil {
+			return nil, fmt.Errorf("attachment %s name: %w", att.ID, err)
+		}
+
+		if att.Mime, err = ciph.Seal(att.Mime, attachmentAAD(ownerID, itemID, att.ID, "mime")); err != nil {
+			return nil, fmt.Errorf("attachment %s mime: %w", att.ID, err)
+		}
+
+		res = append(res, att)
+	}
+
+	return res, nil
+}
+
+// openAttachments возвращает копию вложений с расшифрованными именами и типами содержимого.
+func openAttachments(
+	ciph *envelope.Cipher,
+	ownerID, itemID string,
+	atts []entity.Attachment,
+) ([]entity.Attachment, error) {
+	if atts == nil {
+		return nil, nil
+	}
+
+	res := make([]entity.Attachment, 0, len(atts))
+
+	for _, att := range atts {
+		var err error
+
+		if envelope.IsSealed(att.Name) {
+			if att.Name, err = ciph.Open(att.Name, attachmentAAD(ownerID, itemID, att.ID, "name")); err != nil {
+				return nil, fmt.Errorf("attachment %s name: %w", att.ID, err)
+			}
+		}
+
+		if envelope.IsSealed(att.Mime) {
+			if att.Mime, err = ciph.Open(att.Mime, attachmentAAD(ownerID, itemID, att.ID, "mime")); err != nil {
+				return nil, fmt.Errorf("attachment %s mime: %w", att.ID, err)
+			}
+		}
+
+		res = append(res, att)
+	}
+
+	return res, nil
+}
+
+// isSealedItem сообщает, что в записи есть зашифрованные поля.
+func isSealedItem(item *entity.VaultItem) bool {
+	return envelope.IsSealed(item.Title) || envelope.IsSealed(item.Description) ||
+		envelope.IsSealed(item.Username) || envelope.IsSealed(item.Meta[sealedMetaKey]) ||
+		isSealedTags(item.Tags)
+}
+
+// isSealedTags сообщает, что метки записи зашифрованы одним значением.
+func isSealedTags(tags []string) bool {
+	return len(tags) == 1 && envelope.IsSealed(tags[0])
+}
+
+// fieldAAD возвращает дополнительные данные шифрования поля записи,
+// привязывающие шифротекст к владельцу, записи и полю: зашифрованное значение
+// не расшифруется, если перенести его в другую запись или другое поле.
+func fieldAAD(ownerID, itemID, field string) string {
+	return ownerID + "/" + itemID + "/" + field
+}
+
+// attachmentAAD возвращает дополнительные данные шифрования поля вложения записи,
+// дополнительно привязывающие шифротекст к вложению.
+func attachmentAAD(ownerID, itemID, attachmentID, field string) string {
+	return fieldAAD(ownerID, itemID, "attachment/"+attachmentID+"/"+field)
+}
+
+// EncryptedFolderStorage шифрует названия папок перед сохранением в хранилище
+// и расшифровывает их при чтении ключами данных владельцев EncryptedStorage.
+//
+// Названия, сохранённые до включения шифрования, читаются как есть
+// и шифруются при следующем изменении папки.
+type EncryptedFolderStorage struct {
+	base  IFolderStorage
+	items *EncryptedStorage
+}
+
+// NewEncryptedFolderStorage создаёт и инициализирует новый экзепляр *EncryptedFolderStorage.
+//
+// Параметры:
+//   - base: хранилище папок с зашифрованными названиями;
+//   - items: хранилище записей, ключами данных которого шифруются названия.
+func NewEncryptedFolderStorage(base IFolderStorage, items *EncryptedStorage) *EncryptedFolderStorage {
+	return &EncryptedFolderStorage{
+		base:  base,
+		items: items,
+	}
+}
+
+// CreateFolder создаёт папку записей.
+func (s *EncryptedFolderStorage) CreateFolder(ctx context.Context, folder *entity.Folder) error {
+	sealed, err := s.sealFolder(ctx, folder)
+	if err != nil {
+		return err
+	}
+
+	if err := s.base.CreateFolder(ctx, sealed); err != nil {
+		return err //nolint:wrapcheck // ошибка хранилища передаётся как есть
+	}
+
+	sealed.Name = folder.Name
+	*folder = *sealed
+
+	return nil
+}
+
+// UpdateFolder переименовывает или перемещает папку записей.
+func (s *EncryptedFolderStorage) UpdateFolder(ctx context.Context, folder *entity.Folder) error {
+	sealed, err := s.sealFolder(ctx, folder)
+	if err != nil {
+		return err
+	}
+
+	if err := s.base.UpdateFolder(ctx, sealed); err != nil {
+		return err //nolint:wrapcheck // ошибка хранилища передаётся как есть
+	}
+
+	sealed.Name = folder.Name
+	*folder = *sealed
+
+	return nil
+}
+
+// GetFolder получает папку записей.
+func (s *EncryptedFolderStorage) GetFolder(ctx context.Context, ownerID, folderID string) (*entity.Folder, error) {
+	folder, err := s.base.GetFolder(ctx, ownerID, folderID)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // ошибка хранилища передаётся как есть
+	}
+
+	return s.openFolder(ctx, folder)
+}
+
+// ListFolders получает папки владельца.
+func (s *EncryptedFolderStorage) ListFolders(ctx context.Context, ownerID string) ([]*entity.Folder, error) {
+	folders, err := s.base.ListFolders(ctx, ownerID)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // ошибка хранилища передаётся как есть
+	}
+
+	return s.openFolders(ctx, folders)
+}
+
+// DeleteFolder удаляет папку записей.
+func (s *EncryptedFolderStorage) DeleteFolder(ctx context.Context, ownerID, folderID string, version int64) error {
+	//nolint:wrapcheck // ошибка хранилища передаётся как есть
+	return s.base.DeleteFolder(ctx, ownerID, folderID, version)
+}
+
+// ListFolderChanges получает изменения папок владельца после afterSeq.
+func (s *EncryptedFolderStorage) ListFolderChanges(
+	ctx context.Context,
+	ownerID string,
+	afterSeq int64,
+	limit int,
+) ([]*entity.Folder, error) {
+	folders, err := s.base.ListFolderChanges(ctx, ownerID, afterSeq, limit)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // ошибка хранилища передаётся как есть
+	}
+
+	return s.openFolders(ctx, folders)
+}
+
+// sealFolder возвращает копию папки с зашифрованным названием.
+func (s *EncryptedFolderStorage) sealFolder(ctx context.Context, folder *entity.Folder) (*entity.Folder, error) {
+	ciph, err := s.items.cipher(ctx, folder.OwnerID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// ID папки входит в дополнительные данные шифрования,
+	// поэтому новой папке он выдаётся до сохранения.
+	if folder.ID == "" {
+		folder.ID = uuid.New().String()
+	}
+
+	sealed := *folder
+
+	if sealed.Name, err = ciph.Seal(folder.Name, folderAAD(folder.OwnerID, folder.ID)); err != nil {
+		return nil, fmt.Errorf("folder name: %w", err)
+	}
+
+	return &sealed, nil
+}
+
+// openFolder расшифровывает название папки, полученной из хранилища.
+// Название удалённой папки пусто и не расшифровывается.
+func (s *EncryptedFolderStorage) openFolder(ctx context.Context, folder *entity.Folder) (*entity.Folder, error) {
+	if !envelope.IsSealed(folder.Name) {
+		return folder, nil
+	}
+
+	ciph, err := s.items.cipher(ctx, folder.OwnerID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if ciph == nil {
+		return nil, fmt.Errorf("folder %s: %w", folder.ID, ErrNoDataKey)
+	}
+
+	opened := *folder
+
+	if opened.Name, err = ciph.Open(folder.Name, folderAAD(folder.OwnerID, folder.ID)); err != nil {
+		return nil, fmt.Errorf("folder %s name: %w", folder.ID, err)
+	}
+
+	return &opened, nil
+}
+
+// openFolders расшифровывает названия папок, полученных из хранилища.
+func (s *EncryptedFolderStorage) openFolders(ctx context.Context, folders []*entity.Folder) ([]*entity.Folder, error) {
+	res := make([]*entity.Folder, 0, len(folders))
+
+	for _, folder := range folders {
+		opened, err := s.openFolder(ctx, folder)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, opened)
+	}
+
+	return res, nil
+}
+
+// folderAAD возвращает дополнительные данные шифрования названия папки,
+// привязывающие шифротекст к владельцу и папке.
+func folderAAD(ownerID, folderID string) string {
+	return ownerID + "/folders/" + folderID + "/name"
+}
+
+// sealEmail детерминированно шифрует Email пользователя для поиска по нему.
+func sealEmail(ciph *envelope.Cipher, email string) string {
+	return ciph.SealDeterministic(strings.ToLower(email), usersKeyOwner)
+}
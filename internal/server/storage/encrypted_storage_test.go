@@ -0,0 +1,386 @@
+package storage_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/crypto/envelope"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/storagetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKeyring(t *testing.T, current string, previous ...string) *envelope.Keyring {
+	t.Helper()
+
+	keyring, err := envelope.NewKeyring(current, previous...)
+	require.NoError(t, err)
+
+	return keyring
+}
+
+/*
+	===== EncryptedStorage items =====
+*/
+
+func TestEncryptedStorage_Items(t *testing.T) {
+	t.Parallel()
+
+	base := storage.NewMemoryStorage()
+	stor := storage.NewEncryptedStorage(base, newTestKeyring(t, "master"))
+	ctx := context.Background()
+
+	//nolint:exhaustruct // not all fields needed in test
+	item := &entity.VaultItem{
+		OwnerID:     "user-1",
+		Type:        entity.ItemLogin,
+		Title:       "Bank",
+		Description: "main account",
+		Username:    "john",
+		Meta:        map[string]string{"site": "bank.example.com"},
+		Tags:        []string{"finance", "personal"},
+		Data:        "client-ciphertext",
+		Attachments: []entity.Attachment{
+			{ID: "att-1", Name: "codes.pdf", Mime: "application/pdf", Hash: strings.Repeat("ab", 32), Size: 7},
+		},
+	}
+
+	itemID, err := stor.CreateItem(ctx, item)
+	require.NoError(t, err)
+
+	raw, err := base.GetItem(ctx, "user-1", itemID)
+	require.NoError(t, err)
+	assert.True(t, envelope.IsSealed(raw.Title))
+	assert.True(t, envelope.IsSealed(raw.Description))
+	assert.True(t, envelope.IsSealed(raw.Username))
+	assert.NotContains(t, raw.Meta, "site")
+	require.Len(t, raw.Tags, 1)
+	assert.True(t, envelope.IsSealed(raw.Tags[0]))
+	assert.Equal(t, "client-ciphertext", raw.Data)
+	require.Len(t, raw.Attachments, 1)
+	assert.True(t, envelope.IsSealed(raw.Attachments[0].Name))
+	assert.True(t, envelope.IsSealed(raw.Attachments[0].Mime))
+	assert.Equal(t, item.Attachments[0].Hash, raw.Attachments[0].Hash, "content hash stays open for blob cleanup")
+
+	got, err := stor.GetItem(ctx, "user-1", itemID)
+	require.NoError(t, err)
+	assert.Equal(t, "Bank", got.Title)
+	assert.Equal(t, "main account", got.Description)
+	assert.Equal(t, "john", got.Username)
+	assert.Equal(t, map[string]string{"site": "bank.example.com"}, got.Meta)
+	assert.Equal(t, []string{"finance", "personal"}, got.Tags)
+	assert.Equal(t, item.Attachments, got.Attachments)
+
+	//nolint:exhaustruct // not all fields needed in test
+	other := &entity.VaultItem{OwnerID: "user-1", Type: entity.ItemText, Title: "Notes"}
+	_, err = stor.CreateItem(ctx, other)
+	require.NoError(t, err)
+
+	//nolint:exhaustruct // not all fields needed in test
+	found, err := stor.QueryItems(ctx, "user-1", &entity.ItemQuery{Title: "ba", TitleMatch: entity.TitlePrefix})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, itemID, found[0].ID)
+
+	//nolint:exhaustruct // not all fields needed in test
+	found, err = stor.QueryItems(ctx, "user-1", &entity.ItemQuery{MetaKey: "site"})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, itemID, found[0].ID)
+
+	//nolint:exhaustruct // not all fields needed in test
+	found, err = stor.QueryItems(ctx, "user-1", &entity.ItemQuery{Tags: []string{"finance"}})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, itemID, found[0].ID)
+
+	got.Title = "Bank (old)"
+	require.NoError(t, stor.UpdateItem(ctx, got))
+
+	revs, err := stor.ListRevisions(ctx, "user-1", itemID)
+	require.NoError(t, err)
+	require.Len(t, revs, 1)
+	assert.Equal(t, "Bank", revs[0].Item.Title)
+
+	require.NoError(t, stor.DeleteItem(ctx, "user-1", itemID))
+
+	trash, err := stor.ListTrash(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, trash, 1)
+	assert.Equal(t, "Bank (old)", trash[0].Item.Title)
+
+	restored, err := stor.RestoreTrash(ctx, "user-1", itemID)
+	require.NoError(t, err)
+	assert.Equal(t, "Bank (old)", restored.Title)
+
+	changes, err := stor.ListChanges(ctx, "user-1", 0, 0)
+	require.NoError(t, err)
+
+	for _, change := range changes {
+		assert.False(t, envelope.IsSealed(change.Title))
+	}
+
+	results, err := stor.ApplyChanges(ctx, "user-1", []*entity.Change{
+		//nolint:exhaustruct // not all fields needed in test
+		{Op: entity.ChangeUpsert, Item: &entity.VaultItem{Title: "Synced"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, entity.ChangeApplied, results[0].Status)
+	assert.Equal(t, "Synced", results[0].Item.Title)
+
+	raw, err = base.GetItem(ctx, "user-1", results[0].ID)
+	require.NoError(t, err)
+	assert.True(t, envelope.IsSealed(raw.Title))
+}
+
+func TestEncryptedStorage_PlaintextItems(t *testing.T) {
+	t.Parallel()
+
+	base := storage.NewMemoryStorage()
+	ctx := context.Background()
+
+	//nolint:exhaustruct // not all fields needed in test
+	legacy := &entity.VaultItem{OwnerID: "user-1", Title: "Legacy"}
+
+	itemID, err := base.CreateItem(ctx, legacy)
+	require.NoError(t, err)
+
+	stor := storage.NewEncryptedStorage(base, newTestKeyring(t, "master"))
+
+	got, err := stor.GetItem(ctx, "user-1", itemID)
+	require.NoError(t, err)
+	assert.Equal(t, "Legacy", got.Title, "data stored before encryption is readable")
+
+	require.NoError(t, stor.UpdateItem(ctx, got))
+
+	raw, err := base.GetItem(ctx, "user-1", itemID)
+	require.NoError(t, err)
+	assert.True(t, envelope.IsSealed(raw.Title), "data is encrypted on the next change")
+}
+
+func TestEncryptedStorage_MovedCiphertext(t *testing.T) {
+	t.Parallel()
+
+	base := storage.NewMemoryStorage()
+	stor := storage.NewEncryptedStorage(base, newTestKeyring(t, "master"))
+	ctx := context.Background()
+
+	//nolint:exhaustruct // not all fields needed in test
+	bank := &entity.VaultItem{
+		OwnerID: "user-1",
+		Type:    entity.ItemText,
+		Title:   "Bank",
+		Attachments: []entity.Attachment{
+			{ID: "att-1", Name: "codes.pdf", Mime: "application/pdf"},
+			{ID: "att-2", Name: "card.png", Mime: "image/png"},
+		},
+	}
+	bankID, err := stor.CreateItem(ctx, bank)
+	require.NoError(t, err)
+	assert.Equal(t, bankID, bank.ID, "item ID is assigned before encryption")
+
+	//nolint:exhaustruct // not all fields needed in test
+	notes := &entity.VaultItem{OwnerID: "user-1", Type: entity.ItemText, Title: "Notes"}
+	notesID, err := stor.CreateItem(ctx, notes)
+	require.NoError(t, err)
+
+	rawBank, err := base.GetItem(ctx, "user-1", bankID)
+	require.NoError(t, err)
+	rawNotes, err := base.GetItem(ctx, "user-1", notesID)
+	require.NoError(t, err)
+
+	rawNotes.Title = rawBank.Title
+	require.NoError(t, base.UpdateItem(ctx, rawNotes))
+
+	_, err = stor.GetItem(ctx, "user-1", notesID)
+	require.Error(t, err, "title of another item does not decrypt")
+
+	rawBank.Attachments[0].Name, rawBank.Attachments[1].Name = rawBank.Attachments[1].Name, rawBank.Attachments[0].Name
+	require.NoError(t, base.UpdateItem(ctx, rawBank))
+
+	_, err = stor.GetItem(ctx, "user-1", bankID)
+	require.Error(t, err, "name of another attachment does not decrypt")
+}
+
+/*
+	===== EncryptedFolderStorage =====
+*/
+
+func TestEncryptedFolderStorage_Folders(t *testing.T) {
+	t.Parallel()
+
+	base := storage.NewMemoryStorage()
+	items := storage.NewEncryptedStorage(base, newTestKeyring(t, "master"))
+
+	checkFolderStorage(t, struct {
+		*storage.EncryptedStorage
+		*storage.EncryptedFolderStorage
+	}{items, storage.NewEncryptedFolderStorage(base, items)})
+}
+
+func TestEncryptedFolderStorage_Names(t *testing.T) {
+	t.Parallel()
+
+	base := storage.NewMemoryStorage()
+	ctx := context.Background()
+
+	//nolint:exhaustruct // not all fields needed in test
+	legacy := &entity.Folder{OwnerID: "user-1", Name: "Legacy"}
+	require.NoError(t, base.CreateFolder(ctx, legacy))
+
+	stor := storage.NewEncryptedFolderStorage(base, storage.NewEncryptedStorage(base, newTestKeyring(t, "master")))
+
+	//nolint:exhaustruct // not all fields needed in test
+	bank := &entity.Folder{OwnerID: "user-1", Name: "Bank"}
+	require.NoError(t, stor.CreateFolder(ctx, bank))
+	assert.Equal(t, "Bank", bank.Name)
+	assert.Equal(t, int64(1), bank.Version)
+
+	raw, err := base.GetFolder(ctx, "user-1", bank.ID)
+	require.NoError(t, err)
+	assert.True(t, envelope.IsSealed(raw.Name))
+
+	folders, err := stor.ListFolders(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, folders, 2)
+
+	names := []string{folders[0].Name, folders[1].Name}
+	assert.ElementsMatch(t, []string{"Bank", "Legacy"}, names, "names stored before encryption are readable")
+
+	legacy.Name = "Renamed"
+	require.NoError(t, stor.UpdateFolder(ctx, legacy))
+	assert.Equal(t, "Renamed", legacy.Name)
+
+	raw, err = base.GetFolder(ctx, "user-1", legacy.ID)
+	require.NoError(t, err)
+	assert.True(t, envelope.IsSealed(raw.Name), "name is encrypted on the next change")
+
+	changes, err := stor.ListFolderChanges(ctx, "user-1", 0, 0)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+
+	for _, change := range changes {
+		assert.False(t, envelope.IsSealed(change.Name))
+	}
+
+	sealedBank, err := base.GetFolder(ctx, "user-1", bank.ID)
+	require.NoError(t, err)
+
+	raw.Name = sealedBank.Name
+	require.NoError(t, base.UpdateFolder(ctx, raw))
+
+	_, err = stor.GetFolder(ctx, "user-1", legacy.ID)
+	require.Error(t, err, "name of another folder does not decrypt")
+}
+
+/*
+	===== EncryptedStorage users =====
+*/
+
+func TestEncryptedStorage_Users(t *testing.T) {
+	t.Parallel()
+
+	base := storage.NewMemoryStorage()
+	ctx := context.Background()
+
+	legacy := entity.NewUser("legacy@example.com", "hash")
+	_, err := base.AddNewUser(ctx, legacy)
+	require.NoError(t, err)
+
+	stor := storage.NewEncryptedStorage(base, newTestKeyring(t, "master"))
+
+	user := entity.NewUser("User@Example.com", "hash")
+	_, err = stor.AddNewUser(ctx, user)
+	require.NoError(t, err)
+
+	_, err = base.FindUserByEmail(ctx, "user@example.com")
+	require.ErrorIs(t, err, storage.ErrEntityNotFound, "email is not stored in plaintext")
+
+	found, err := stor.FindUserByEmail(ctx, "USER@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, found.ID)
+	assert.Equal(t, "user@example.com", found.Email)
+
+	_, err = stor.AddNewUser(ctx, entity.NewUser("user@example.com", "other"))
+	require.ErrorIs(t, err, storage.ErrEntityAlreadyExists)
+
+	_, err = stor.AddNewUser(ctx, entity.NewUser("legacy@example.com", "other"))
+	require.ErrorIs(t, err, storage.ErrEntityAlreadyExists)
+
+	found, err = stor.FindUserByEmail(ctx, "legacy@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, legacy.ID, found.ID)
+
+	_, err = stor.FindUserByEmail(ctx, "missing@example.com")
+	require.ErrorIs(t, err, storage.ErrEntityNotFound)
+}
+
+/*
+	===== EncryptedStorage.RotateMasterKey =====
+*/
+
+func TestEncryptedStorage_RotateMasterKey(t *testing.T) {
+	t.Parallel()
+
+	base := storage.NewMemoryStorage()
+	ctx := context.Background()
+
+	oldStor := storage.NewEncryptedStorage(base, newTestKeyring(t, "old-master"))
+
+	_, err := oldStor.AddNewUser(ctx, entity.NewUser("user@example.com", "hash"))
+	require.NoError(t, err)
+
+	//nolint:exhaustruct // not all fields needed in test
+	itemID, err := oldStor.CreateItem(ctx, &entity.VaultItem{OwnerID: "user-1", Title: "Bank"})
+	require.NoError(t, err)
+
+	before, err := base.GetItem(ctx, "user-1", itemID)
+	require.NoError(t, err)
+
+	_, err = storage.NewEncryptedStorage(base, newTestKeyring(t, "new-master")).GetItem(ctx, "user-1", itemID)
+	require.ErrorIs(t, err, envelope.ErrUnknownMasterKey)
+
+	rotating := storage.NewEncryptedStorage(base, newTestKeyring(t, "new-master", "old-master"))
+
+	count, err := rotating.RotateMasterKey(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count, "user data key and email key")
+
+	count, err = rotating.RotateMasterKey(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+
+	after, err := base.GetItem(ctx, "user-1", itemID)
+	require.NoError(t, err)
+	assert.Equal(t, before.Title, after.Title, "rows are not re-encrypted")
+
+	newStor := storage.NewEncryptedStorage(base, newTestKeyring(t, "new-master"))
+
+	got, err := newStor.GetItem(ctx, "user-1", itemID)
+	require.NoError(t, err)
+	assert.Equal(t, "Bank", got.Title)
+
+	_, err = newStor.FindUserByEmail(ctx, "user@example.com")
+	require.NoError(t, err)
+
+	_, err = storage.NewEncryptedStorage(base, newTestKeyring(t, "old-master")).GetItem(ctx, "user-1", itemID)
+	require.ErrorIs(t, err, envelope.ErrUnknownMasterKey)
+}
+
+/*
+	===== EncryptedStorage conformance =====
+*/
+
+func TestEncryptedStorage_Conformance(t *testing.T) {
+	t.Parallel()
+
+	storagetest.Run(t, func(t *testing.T) storagetest.Storage {
+		t.Helper()
+
+		return storage.NewEncryptedStorage(storage.NewMemoryStorage(), newTestKeyring(t, "master"))
+	})
+}
@@ -9,9 +9,9 @@ import (
 
 // User описывает пользователя на сервере.
 type User struct {
-	ID           string
-	Email        string
-	PasswordHash string // password hash
+	ID           string `json:"id"`
+	Email        string `json:"email"`
+	PasswordHash string `json:"passwordHash"` // password hash
 }
 
 // NewUser создаёт нового пользователя с уникальным ID.
@@ -28,6 +28,158 @@ func NewUser(email, passHash string) *User {
 // Token описывает токены для пользователей.
 type Token struct{}
 
+// DataKey описывает ключ данных, которым шифруются данные пользователя в хранилище.
+//
+// Ключ хранится зашифрованным мастер-ключом сервера (см. пакет envelope).
+type DataKey struct {
+	CreatedAt   time.Time `json:"createdAt"`
+	OwnerID     string    `json:"ownerId"`     // владелец ключа
+	MasterKeyID string    `json:"masterKeyId"` // идентификатор мастер-ключа, которым зашифрован ключ
+	Wrapped     []byte    `json:"wrapped"`     // зашифрованный ключ данных
+}
+
+// PublicKey описывает открытый ключ пользователя, которым другие пользователи
+// шифруют для него ключи записей при передаче доступа (см. Share).
+//
+// Сервер не разбирает ключ: алгоритм и формат ключа определяет клиент.
+type PublicKey struct {
+	CreatedAt time.Time `json:"createdAt"`
+	UserID    string    `json:"userId"`
+	Algorithm string    `json:"algorithm"` // алгоритм ключа (например, x25519)
+	Key       []byte    `json:"key"`       // открытый ключ
+}
+
+// SharePermission описывает права получателя на переданную ему запись.
+type SharePermission string
+
+const (
+	// ShareRead - чтение записи.
+	ShareRead SharePermission = "read"
+
+	// ShareWrite - чтение и изменение записи.
+	ShareWrite SharePermission = "write"
+)
+
+// Allows сообщает, включают ли права permission права need.
+func (p SharePermission) Allows(need SharePermission) bool {
+	return p == need || p == ShareWrite && need == ShareRead
+}
+
+// Share описывает доступ пользователя (получателя) к записи другого пользователя.
+//
+// Data записи шифрует клиент ключом записи. Передавая доступ, владелец шифрует
+// ключ записи открытым ключом получателя (WrappedKey), поэтому сервер
+// не может расшифровать данные записи.
+type Share struct {
+	CreatedAt   time.Time       `json:"createdAt"`
+	OwnerID     string          `json:"ownerId"`     // владелец записи
+	ItemID      string          `json:"itemId"`      // запись
+	RecipientID string          `json:"recipientId"` // получатель доступа
+	Permission  SharePermission `json:"permission"`  // права получателя
+	WrappedKey  []byte          `json:"wrappedKey"`  // ключ записи, зашифрованный для получателя
+}
+
+// Organization описывает организацию: группу пользователей с общими коллекциями записей.
+type Organization struct {
+	CreatedAt time.Time `json:"createdAt"`
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+}
+
+// OrgRole описывает роль участника организации.
+type OrgRole string
+
+const (
+	// OrgOwner - владелец: все права администратора и управление владельцами.
+	OrgOwner OrgRole = "owner"
+
+	// OrgAdmin - администратор: управление участниками и коллекциями.
+	OrgAdmin OrgRole = "admin"
+
+	// OrgEditor - редактор: чтение и изменение записей коллекций.
+	OrgEditor OrgRole = "editor"
+
+	// OrgViewer - читатель: чтение записей коллекций.
+	OrgViewer OrgRole = "viewer"
+)
+
+// orgRoleRanks - уровни ролей организации: роль включает права всех ролей ниже неё.
+var orgRoleRanks = map[OrgRole]int{
+	OrgViewer: 1,
+	OrgEditor: 2,
+	OrgAdmin:  3,
+	OrgOwner:  4,
+}
+
+// Valid сообщает, является ли r известной ролью.
+func (r OrgRole) Valid() bool {
+	_, ok := orgRoleRanks[r]
+
+	return ok
+}
+
+// Allows сообщает, включает ли роль r права роли need.
+func (r OrgRole) Allows(need OrgRole) bool {
+	return r.Valid() && need.Valid() && orgRoleRanks[r] >= orgRoleRanks[need]
+}
+
+// MemberStatus описывает состояние участия пользователя в организации.
+type MemberStatus string
+
+const (
+	// MemberInvited - пользователь приглашён, но ещё не принял приглашение.
+	MemberInvited MemberStatus = "invited"
+
+	// MemberActive - пользователь участвует в организации.
+	MemberActive MemberStatus = "active"
+)
+
+// Member описывает участие пользователя в организации.
+type Member struct {
+	CreatedAt time.Time    `json:"createdAt"`
+	OrgID     string       `json:"orgId"`
+	UserID    string       `json:"userId"`
+	Role      OrgRole      `json:"role"`
+	Status    MemberStatus `json:"status"`
+	InvitedBy string       `json:"invitedBy"` // кто пригласил (пусто - создатель организации)
+}
+
+// Collection описывает коллекцию записей организации.
+//
+// Записи коллекции хранятся в хранилище записей с владельцем, равным ID коллекции,
+// поэтому у каждой коллекции своя лента изменений, квота и ключ данных.
+type Collection struct {
+	CreatedAt time.Time `json:"createdAt"`
+	ID        string    `json:"id"`
+	OrgID     string    `json:"orgId"`
+	Name      string    `json:"name"`
+}
+
+// Snapshot описывает снимок всех данных хранилища для резервного копирования
+// и переноса данных между хранилищами.
+type Snapshot struct {
+	Users       []*User          `json:"users"`
+	Tokens      []string         `json:"tokens"` // ID пользователей с зарегистрированным токеном
+	Keys        []*DataKey       `json:"keys"`
+	PublicKeys  []*PublicKey     `json:"publicKeys"`
+	Shares      []*Share         `json:"shares"`
+	Orgs        []*Organization  `json:"orgs"`
+	Members     []*Member        `json:"members"`
+	Collections []*Collection    `json:"collections"`
+	Vaults      []*VaultSnapshot `json:"vaults"`
+}
+
+// VaultSnapshot описывает данные одного пользователя в снимке хранилища.
+type VaultSnapshot struct {
+	OwnerID    string       `json:"ownerId"`
+	Seq        int64        `json:"seq"` // последний выданный номер изменения
+	Items      []*VaultItem `json:"items"`
+	Tombstones []*Tombstone `json:"tombstones"`
+	Revisions  []*Revision  `json:"revisions"`
+	Trash      []*TrashItem `json:"trash"`
+	Folders    []*Folder    `json:"folders"` // папки, включая отметки об удалении
+}
+
 // ItemType описывает тип хранимой информации.
 type ItemType string
 
@@ -54,7 +206,304 @@ type VaultItem struct {
 	Description string            `json:"desc"`
 	Meta        map[string]string `json:"meta"` // произвольная метаинфа
 	Username    string            `json:"username"`
-	Data        string            `json:"data"` // шифротекст (opaque)
+	Data        string            `json:"data"`                  // шифротекст (opaque)
+	Content     *Content          `json:"content,omitempty"`     // содержимое бинарной записи (nil - нет)
+	FolderID    string            `json:"folderId,omitempty"`    // папка записи (пусто - вне папок)
+	Tags        []string          `json:"tags,omitempty"`        // метки записи без повторов (по возрастанию)
+	Attachments []Attachment      `json:"attachments,omitempty"` // вложения записи (по времени добавления)
+	ExpiresAt   *time.Time        `json:"expiresAt,omitempty"`   // момент истечения срока записи (nil - бессрочная)
 	Version     int64             `json:"version"`
 	UpdatedAt   time.Time         `json:"updatedAt"`
+	Seq         int64             `json:"seq"`               // номер изменения в ленте пользователя
+	Deleted     bool              `json:"deleted,omitempty"` // отметка об удалении (для синхронизации)
+	Expired     bool              `json:"expired,omitempty"` // запись удалена по истечении срока (с Deleted)
+}
+
+// IsExpired сообщает, что срок записи истёк к моменту now.
+//
+// Записи с истёкшим сроком не выдаются хранилищем и удаляются фоновой задачей
+// вместе с прежними версиями, минуя корзину.
+func (it *VaultItem) IsExpired(now time.Time) bool {
+	return it.ExpiresAt != nil && !it.ExpiresAt.After(now)
+}
+
+// AsExpired представляет запись с истёкшим сроком в виде отметки об удалении
+// для синхронизации, чтобы устройства пользователя удалили её локальные копии.
+func (it *VaultItem) AsExpired() *VaultItem {
+	tomb := &Tombstone{
+		DeletedAt: *it.ExpiresAt,
+		ID:        it.ID,
+		OwnerID:   it.OwnerID,
+		Version:   it.Version,
+		Seq:       it.Seq,
+		Expired:   true,
+	}
+
+	return tomb.AsItem()
+}
+
+// Size возвращает размер содержимого записи в байтах, учитываемый в квоте пользователя.
+func (it *VaultItem) Size() int64 {
+	size := len(it.Type) + len(it.Title) + len(it.Description) + len(it.Username) + len(it.Data)
+
+	for key, value := range it.Meta {
+		size += len(key) + len(value)
+	}
+
+	for _, tag := range it.Tags {
+		size += len(tag)
+	}
+
+	total := int64(size)
+
+	for _, att := range it.Attachments {
+		total += att.Size + int64(len(att.ID)+len(att.Name)+len(att.Mime))
+	}
+
+	return total
+}
+
+// UsageSize возвращает размер записи вместе с содержимым бинарной записи.
+//
+// Размер содержимого учитывается в суммарном объёме данных пользователя, но не в
+// размере одной записи: содержимое ограничивается отдельным лимитом хранилища содержимого.
+func (it *VaultItem) UsageSize() int64 {
+	size := it.Size()
+	if it.Content != nil {
+		size += it.Content.Size
+	}
+
+	return size
+}
+
+// Attachment описывает вложение записи: файл, прикреплённый к записи любого типа.
+//
+// Содержимое вложения хранится отдельно от записи, как содержимое бинарной записи,
+// и шифруется клиентом. Размер вложений учитывается в размере записи и квоте
+// пользователя. Вложения удаляются вместе с записью, а их содержимое - фоновой
+// задачей, когда на него не остаётся ссылок.
+//
+// При обновлении записи nil в VaultItem.Attachments сохраняет текущие вложения,
+// а пустой список - удаляет их.
+type Attachment struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`      // имя файла
+	Mime      string    `json:"mime"`      // тип содержимого
+	Hash      string    `json:"hash"`      // SHA-256 содержимого (hex)
+	Size      int64     `json:"size"`      // размер содержимого в байтах
+	CreatedAt time.Time `json:"createdAt"` // момент добавления вложения
+}
+
+// Folder описывает папку записей пользователя.
+//
+// Папки образуют дерево: ParentID ссылается на папку того же владельца.
+// Изменения папок нумеруются той же последовательностью, что и изменения записей,
+// поэтому входят в ленту синхронизации. Ссылка записи на удалённую папку
+// равносильна отсутствию папки.
+type Folder struct {
+	ID        string    `json:"id"`
+	OwnerID   string    `json:"-"`
+	ParentID  string    `json:"parentId,omitempty"` // родительская папка (пусто - корень)
+	Name      string    `json:"name"`
+	Version   int64     `json:"version"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Seq       int64     `json:"seq"`               // номер изменения в ленте владельца
+	Deleted   bool      `json:"deleted,omitempty"` // отметка об удалении (для синхронизации)
+}
+
+// Content описывает содержимое бинарной записи, хранимое отдельно от записи.
+//
+// Содержимое адресуется SHA-256 хешем, поэтому одинаковое содержимое хранится один раз.
+// При обновлении записи nil в VaultItem.Content сохраняет текущее содержимое,
+// а Content с пустым Hash - удаляет ссылку на него.
+type Content struct {
+	Hash string `json:"hash"` // SHA-256 содержимого (hex)
+	Size int64  `json:"size"` // размер содержимого в байтах
+}
+
+// Quota описывает ограничения на данные пользователя (0 - без ограничения).
+type Quota struct {
+	MaxItems     int64 `json:"maxItems"`     // количество записей
+	MaxBytes     int64 `json:"maxBytes"`     // суммарный размер записей вместе с содержимым
+	MaxItemBytes int64 `json:"maxItemBytes"` // размер одной записи
+}
+
+// Usage описывает объём данных пользователя.
+type Usage struct {
+	Items int64 `json:"items"` // количество записей
+	Bytes int64 `json:"bytes"` // суммарный размер записей (VaultItem.Size)
+}
+
+// ItemSortField описывает поле сортировки записей.
+type ItemSortField string
+
+const (
+	// SortByTitle - по названию (побайтово).
+	SortByTitle ItemSortField = "title"
+
+	// SortByUpdatedAt - по времени последнего изменения.
+	SortByUpdatedAt ItemSortField = "updatedAt"
+)
+
+// TitleMatch описывает способ сравнения названия записи с образцом.
+type TitleMatch string
+
+const (
+	// TitleContains - название содержит образец.
+	TitleContains TitleMatch = "contains"
+
+	// TitlePrefix - название начинается с образца.
+	TitlePrefix TitleMatch = "prefix"
+)
+
+// TagMatch описывает способ сравнения меток записи с метками выборки.
+type TagMatch string
+
+const (
+	// TagsAny - у записи есть хотя бы одна из меток.
+	TagsAny TagMatch = "any"
+
+	// TagsAll - у записи есть все метки.
+	TagsAll TagMatch = "all"
+)
+
+// TagCount описывает метку и количество записей с ней.
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// ItemQuery описывает условия выборки записей пользователя.
+//
+// Записи упорядочиваются по полю SortBy, при равенстве - по ID,
+// поэтому порядок однозначен и страницы не пересекаются.
+type ItemQuery struct {
+	After      *ItemCursor   // позиция, после которой начинается страница (nil - с начала)
+	Type       ItemType      // тип записи (пусто - любой)
+	Title      string        // образец названия без учёта регистра (пусто - любое)
+	TitleMatch TitleMatch    // способ сравнения названия (пусто - TitleContains)
+	MetaKey    string        // ключ метаинформации, который должен быть у записи (пусто - любой)
+	MetaValue  string        // значение ключа MetaKey (пусто - любое)
+	FolderID   *string       // папка записи (nil - любая, пусто - вне папок)
+	Tags       []string      // метки записи (пусто - любые)
+	TagMatch   TagMatch      // способ сравнения меток (пусто - TagsAny)
+	SortBy     ItemSortField // поле сортировки (пусто - SortByTitle)
+	Desc       bool          // сортировка по убыванию
+	Limit      int           // размер страницы (0 - без ограничения)
+}
+
+// ItemCursor описывает позицию записи в упорядоченной выборке.
+//
+// Используются поле сортировки выборки и ID записи.
+type ItemCursor struct {
+	UpdatedAt time.Time
+	Title     string
+	ID        string
+}
+
+// CursorOf возвращает позицию записи для продолжения выборки после неё.
+func CursorOf(item *VaultItem) *ItemCursor {
+	return &ItemCursor{
+		UpdatedAt: item.UpdatedAt,
+		Title:     item.Title,
+		ID:        item.ID,
+	}
+}
+
+// Tombstone описывает отметку об удалённой записи.
+//
+// Отметки хранятся ограниченное время, чтобы синхронизация могла сообщить
+// другим устройствам пользователя об удалении записи.
+type Tombstone struct {
+	DeletedAt time.Time `json:"deletedAt"`
+	ID        string    `json:"id"`
+	OwnerID   string    `json:"-"`
+	Version   int64     `json:"version"`
+	Seq       int64     `json:"seq"`
+	Expired   bool      `json:"expired,omitempty"` // запись удалена по истечении срока
+}
+
+// AsItem представляет отметку об удалении в виде записи с признаком Deleted.
+func (t *Tombstone) AsItem() *VaultItem {
+	return &VaultItem{
+		ID:          t.ID,
+		OwnerID:     t.OwnerID,
+		Type:        "",
+		Title:       "",
+		Description: "",
+		Meta:        nil,
+		Username:    "",
+		Data:        "",
+		Content:     nil,
+		FolderID:    "",
+		Tags:        nil,
+		Attachments: nil,
+		ExpiresAt:   nil,
+		Version:     t.Version,
+		UpdatedAt:   t.DeletedAt,
+		Seq:         t.Seq,
+		Deleted:     true,
+		Expired:     t.Expired,
+	}
+}
+
+// Revision описывает прежнюю версию записи, сохранённую при её изменении или удалении.
+type Revision struct {
+	CreatedAt time.Time  `json:"createdAt"` // момент, когда версия была заменена
+	Item      *VaultItem `json:"item"`      // содержимое записи в этой версии
+}
+
+// TrashItem описывает запись в корзине.
+//
+// Запись попадает в корзину при удалении и может быть восстановлена,
+// пока не будет удалена из корзины безвозвратно.
+type TrashItem struct {
+	DeletedAt time.Time  `json:"deletedAt"`
+	Item      *VaultItem `json:"item"` // содержимое записи на момент удаления
+}
+
+// ChangeOp описывает вид изменения в пакете синхронизации.
+type ChangeOp string
+
+const (
+	// ChangeUpsert - создание или обновление записи.
+	ChangeUpsert ChangeOp = "upsert"
+
+	// ChangeDelete - удаление записи.
+	ChangeDelete ChangeOp = "delete"
+)
+
+// Change описывает изменение записи, отправленное клиентом в пакете синхронизации.
+//
+// Item.Version - версия, от которой клиент сделал изменение (0 - без проверки).
+// Для удаления достаточно заполнить ID и Version. Изменение, не прошедшее проверку
+// до передачи в хранилище, отклоняется с причиной Invalid без применения.
+type Change struct {
+	Item    *VaultItem
+	Op      ChangeOp
+	Invalid error // причина отклонения изменения (nil - изменение корректно)
+}
+
+// ChangeStatus описывает результат применения изменения.
+type ChangeStatus string
+
+const (
+	// ChangeApplied - изменение применено.
+	ChangeApplied ChangeStatus = "applied"
+
+	// ChangeConflict - версия клиента устарела, изменение не применено.
+	ChangeConflict ChangeStatus = "conflict"
+
+	// ChangeRejected - изменение некорректно и не применено.
+	ChangeRejected ChangeStatus = "rejected"
+)
+
+// ChangeResult описывает результат применения одного изменения из пакета.
+type ChangeResult struct {
+	// Состояние записи на сервере: после применения или текущее при конфликте.
+	// Для удалённой записи содержит отметку об удалении (Deleted).
+	Item   *VaultItem
+	ID     string
+	Error  string
+	Status ChangeStatus
 }
@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -13,12 +14,42 @@ import (
 	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
 )
 
+// shareKey - ключ доступа к записи в MemoryStorage.
+type shareKey struct {
+	ownerID     string
+	itemID      string
+	recipientID string
+}
+
+// memberKey - ключ участия пользователя в организации в MemoryStorage.
+type memberKey struct {
+	orgID  string
+	userID string
+}
+
 // MemoryStorage описывает хранилище.
 type MemoryStorage struct {
 	mu     sync.RWMutex
 	users  map[string]*entity.User  // email -> user
 	tokens map[string]*entity.Token // userID -> token
 	items  map[string]map[string]*entity.VaultItem
+	tombs  map[string]map[string]*entity.Tombstone  // ownerID -> itemID -> tombstone
+	seqs   map[string]int64                         // ownerID -> последний номер изменения
+	revs   map[string]map[string][]*entity.Revision // ownerID -> itemID -> версии (от старых к новым)
+	trash  map[string]map[string]*entity.TrashItem  // ownerID -> itemID -> запись в корзине
+	keys   map[string]*entity.DataKey               // ownerID -> ключ данных
+
+	pubKeys map[string]*entity.PublicKey // userID -> открытый ключ
+	shares  map[shareKey]*entity.Share   // доступы к записям
+
+	orgs        map[string]*entity.Organization // orgID -> организация
+	members     map[memberKey]*entity.Member    // участие пользователей в организациях
+	collections map[string]*entity.Collection   // collectionID -> коллекция
+
+	folders map[string]map[string]*entity.Folder // ownerID -> folderID -> папка (с удалёнными)
+
+	revisionLimit int
+	quota         entity.Quota
 }
 
 // NewMemoryStorage создаёт и инициализирует новый экзепляр *MemoryStorage.
@@ -28,9 +59,57 @@ func NewMemoryStorage() *MemoryStorage {
 		users:  make(map[string]*entity.User),
 		tokens: make(map[string]*entity.Token),
 		items:  make(map[string]map[string]*entity.VaultItem),
+		tombs:  make(map[string]map[string]*entity.Tombstone),
+		seqs:   make(map[string]int64),
+		revs:   make(map[string]map[string][]*entity.Revision),
+		trash:  make(map[string]map[string]*entity.TrashItem),
+		keys:   make(map[string]*entity.DataKey),
+
+		pubKeys: make(map[string]*entity.PublicKey),
+		shares:  make(map[shareKey]*entity.Share),
+
+		orgs:        make(map[string]*entity.Organization),
+		members:     make(map[memberKey]*entity.Member),
+		collections: make(map[string]*entity.Collection),
+
+		folders: make(map[string]map[string]*entity.Folder),
+
+		revisionLimit: DefaultRevisionLimit,
+		quota:         entity.Quota{MaxItems: 0, MaxBytes: 0, MaxItemBytes: 0},
 	}
 }
 
+// SetRevisionLimit задаёт количество хранимых прежних версий записи (0 - не хранить).
+func (m *MemoryStorage) SetRevisionLimit(limit int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.revisionLimit = max(limit, 0)
+}
+
+// SetQuota задаёт ограничения на данные каждого пользователя.
+func (m *MemoryStorage) SetQuota(quota entity.Quota) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.quota = quota
+}
+
+// GetUsage получает объём данных пользователя.
+func (m *MemoryStorage) GetUsage(_ context.Context, ownerID string) (*entity.Usage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	usage := m.usage(ownerID)
+
+	return &usage, nil
+}
+
+// Close освобождает ресурсы хранилища.
+func (m *MemoryStorage) Close() error {
+	return nil
+}
+
 // AddNewUser создаёт нового пользователя.
 func (m *MemoryStorage) AddNewUser(_ context.Context, user *entity.User) (string, error) {
 	m.mu.Lock()
@@ -64,8 +143,8 @@ func (m *MemoryStorage) AddNewToken(
 	userID string,
 	token *entity.Token,
 ) (string, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
 	if _, ok := m.tokens[userID]; ok {
 		return "", fmt.Errorf("token: %w", ErrEntityAlreadyExists)
@@ -88,159 +167,1383 @@ func (m *MemoryStorage) IsTokenByUserID(_ context.Context, userID string) bool {
 
 // DeleteToken удаляет токен.
 func (m *MemoryStorage) DeleteToken(_ context.Context, userID string) error {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
 	delete(m.tokens, userID)
 
 	return nil
 }
 
-// CreateItem создаёт новую запись с паролем.
-func (m *MemoryStorage) CreateItem(_ context.Context, item *entity.VaultItem) (string, error) {
+// GetDataKey получает ключ данных владельца.
+func (m *MemoryStorage) GetDataKey(_ context.Context, ownerID string) (*entity.DataKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key, ok := m.keys[ownerID]
+	if !ok {
+		return nil, fmt.Errorf("data key: %w", ErrEntityNotFound)
+	}
+
+	return copyDataKey(key), nil
+}
+
+// AddDataKey сохраняет ключ данных владельца.
+func (m *MemoryStorage) AddDataKey(_ context.Context, key *entity.DataKey) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if _, ok := m.items[item.OwnerID]; !ok {
-		m.items[item.OwnerID] = make(map[string]*entity.VaultItem)
+	if _, ok := m.keys[key.OwnerID]; ok {
+		return fmt.Errorf("data key: %w", ErrEntityAlreadyExists)
 	}
 
-	if item.ID == "" {
-		item.ID = uuid.New().String()
+	m.keys[key.OwnerID] = copyDataKey(key)
+
+	return nil
+}
+
+// RewrapDataKeys заменяет ключи данных результатом rewrap.
+//
+// Ключи заменяются только после успешной обработки всех ключей.
+func (m *MemoryStorage) RewrapDataKeys(
+	_ context.Context,
+	rewrap func(key *entity.DataKey) (*entity.DataKey, error),
+) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	replaced := make(map[string]*entity.DataKey)
+
+	for ownerID, key := range m.keys {
+		next, err := rewrap(copyDataKey(key))
+		if err != nil {
+			return 0, fmt.Errorf("data key %s: %w", ownerID, err)
+		}
+
+		if next != nil {
+			res := copyDataKey(key)
+			res.MasterKeyID = next.MasterKeyID
+			res.Wrapped = append([]byte(nil), next.Wrapped...)
+			replaced[ownerID] = res
+		}
 	}
 
-	if _, ok := m.items[item.OwnerID][item.ID]; ok {
-		return "", fmt.Errorf("item: %w", ErrEntityAlreadyExists)
+	for ownerID, key := range replaced {
+		m.keys[ownerID] = key
 	}
 
-	item.Version = 1
-	item.UpdatedAt = time.Now().UTC()
-	cl := *item
-	m.items[item.OwnerID][item.ID] = &cl
+	return len(replaced), nil
+}
 
-	return item.ID, nil
+// SetPublicKey сохраняет открытый ключ пользователя.
+func (m *MemoryStorage) SetPublicKey(_ context.Context, key *entity.PublicKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.pubKeys[key.UserID] = copyPublicKey(key)
+
+	return nil
 }
 
-// UpdateItem обновляет текущую запись с паролем.
-func (m *MemoryStorage) UpdateItem(_ context.Context, item *entity.VaultItem) error {
+// GetPublicKey получает открытый ключ пользователя.
+func (m *MemoryStorage) GetPublicKey(_ context.Context, userID string) (*entity.PublicKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key, ok := m.pubKeys[userID]
+	if !ok {
+		return nil, fmt.Errorf("public key: %w", ErrEntityNotFound)
+	}
+
+	return copyPublicKey(key), nil
+}
+
+// PutShare создаёт или заменяет доступ получателя к записи.
+func (m *MemoryStorage) PutShare(_ context.Context, share *entity.Share) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	userItems := m.items[item.OwnerID]
-	if userItems == nil {
-		return fmt.Errorf("item: %w", ErrEntityNotFound)
+	m.shares[shareKey{ownerID: share.OwnerID, itemID: share.ItemID, recipientID: share.RecipientID}] = copyShare(share)
+
+	return nil
+}
+
+// GetShare получает доступ получателя к записи владельца.
+func (m *MemoryStorage) GetShare(
+	_ context.Context,
+	ownerID, itemID, recipientID string,
+) (*entity.Share, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	share, ok := m.shares[shareKey{ownerID: ownerID, itemID: itemID, recipientID: recipientID}]
+	if !ok {
+		return nil, fmt.Errorf("share: %w", ErrEntityNotFound)
 	}
 
-	old := userItems[item.ID]
-	if old == nil {
-		return fmt.Errorf("item: %w", ErrEntityNotFound)
+	return copyShare(share), nil
+}
+
+// ListItemShares получает доступы к записи владельца.
+func (m *MemoryStorage) ListItemShares(_ context.Context, ownerID, itemID string) ([]*entity.Share, error) {
+	return m.filterShares(func(key shareKey) bool {
+		return key.ownerID == ownerID && key.itemID == itemID
+	}), nil
+}
+
+// ListSharedWith получает доступы получателя к чужим записям.
+func (m *MemoryStorage) ListSharedWith(_ context.Context, recipientID string) ([]*entity.Share, error) {
+	return m.filterShares(func(key shareKey) bool {
+		return key.recipientID == recipientID
+	}), nil
+}
+
+// DeleteShare удаляет доступ получателя к записи владельца.
+func (m *MemoryStorage) DeleteShare(_ context.Context, ownerID, itemID, recipientID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := shareKey{ownerID: ownerID, itemID: itemID, recipientID: recipientID}
+	if _, ok := m.shares[key]; !ok {
+		return fmt.Errorf("share: %w", ErrEntityNotFound)
 	}
-	// оптимистичная проверка версии:
-	// if it.Version != 0 && it.Version != old.Version { return ErrConflict }
-	newIt := *item
-	newIt.Version = old.Version + 1
-	newIt.UpdatedAt = time.Now().UTC()
-	userItems[item.ID] = &newIt
+
+	delete(m.shares, key)
 
 	return nil
 }
 
-// UpsertItem обновляет или обновляет текущую запись с паролем для синхронизации.
-func (m *MemoryStorage) UpsertItem(ctx context.Context, item *entity.VaultItem) (string, error) {
-	if item.ID == "" {
-		return m.CreateItem(ctx, item)
+// deleteItemShares удаляет доступы к безвозвратно удалённой записи (вызывается под блокировкой).
+func (m *MemoryStorage) deleteItemShares(ownerID, itemID string) {
+	for key := range m.shares {
+		if key.ownerID == ownerID && key.itemID == itemID {
+			delete(m.shares, key)
+		}
 	}
+}
+
+// filterShares возвращает упорядоченные копии доступов, ключи которых подходят под match.
+func (m *MemoryStorage) filterShares(match func(key shareKey) bool) []*entity.Share {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 
-	if err := m.UpdateItem(ctx, item); err != nil {
-		if errors.Is(err, ErrEntityNotFound) {
-			return m.CreateItem(ctx, item)
+	res := make([]*entity.Share, 0)
+
+	for key, share := range m.shares {
+		if match(key) {
+			res = append(res, copyShare(share))
 		}
+	}
 
-		return "", err
+	sortShares(res)
+
+	return res
+}
+
+// CreateOrg создаёт организацию вместе с участием её создателя.
+func (m *MemoryStorage) CreateOrg(_ context.Context, org *entity.Organization, owner *entity.Member) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.orgs[org.ID]; ok {
+		return fmt.Errorf("org: %w", ErrEntityAlreadyExists)
 	}
 
-	return item.ID, nil
+	orgCopy, ownerCopy := *org, *owner
+	m.orgs[org.ID] = &orgCopy
+	m.members[memberKey{orgID: owner.OrgID, userID: owner.UserID}] = &ownerCopy
+
+	return nil
 }
 
-// GetItem получает текущую запись с паролем по ID.
-func (m *MemoryStorage) GetItem(
-	_ context.Context,
-	ownerID, userID string,
-) (*entity.VaultItem, error) {
+// GetOrg получает организацию.
+func (m *MemoryStorage) GetOrg(_ context.Context, orgID string) (*entity.Organization, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	userItems := m.items[ownerID]
-	if userItems == nil {
-		return nil, fmt.Errorf("item: %w", ErrEntityNotFound)
+	org, ok := m.orgs[orgID]
+	if !ok {
+		return nil, fmt.Errorf("org: %w", ErrEntityNotFound)
 	}
 
-	it := userItems[userID]
-	if it == nil {
-		return nil, fmt.Errorf("item: %w", ErrEntityNotFound)
-	}
+	res := *org
 
-	cp := *it
+	return &res, nil
+}
 
-	return &cp, nil
+// PutMember создаёт или заменяет участие пользователя в организации.
+func (m *MemoryStorage) PutMember(_ context.Context, member *entity.Member) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cp := *member
+	m.members[memberKey{orgID: member.OrgID, userID: member.UserID}] = &cp
+
+	return nil
 }
 
-// ListItems получает список записей с паролями по пользователю.
-func (m *MemoryStorage) ListItems(_ context.Context, ownerID string) ([]*entity.VaultItem, error) {
+// GetMember получает участие пользователя в организации.
+func (m *MemoryStorage) GetMember(_ context.Context, orgID, userID string) (*entity.Member, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	userItems := m.items[ownerID]
-	if userItems == nil {
-		return []*entity.VaultItem{}, nil
+	member, ok := m.members[memberKey{orgID: orgID, userID: userID}]
+	if !ok {
+		return nil, fmt.Errorf("member: %w", ErrEntityNotFound)
 	}
 
-	res := make([]*entity.VaultItem, 0, len(userItems))
+	res := *member
 
-	for _, v := range userItems {
-		cp := *v
-		res = append(res, &cp)
+	return &res, nil
+}
+
+// ListMembers получает участников и приглашённых организации.
+func (m *MemoryStorage) ListMembers(_ context.Context, orgID string) ([]*entity.Member, error) {
+	return m.filterMembers(func(key memberKey) bool { return key.orgID == orgID }), nil
+}
+
+// ListMemberships получает участие пользователя в организациях.
+func (m *MemoryStorage) ListMemberships(_ context.Context, userID string) ([]*entity.Member, error) {
+	return m.filterMembers(func(key memberKey) bool { return key.userID == userID }), nil
+}
+
+// DeleteMember удаляет участие пользователя в организации.
+func (m *MemoryStorage) DeleteMember(_ context.Context, orgID, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := memberKey{orgID: orgID, userID: userID}
+	if _, ok := m.members[key]; !ok {
+		return fmt.Errorf("member: %w", ErrEntityNotFound)
 	}
 
-	return res, nil
+	delete(m.members, key)
+
+	return nil
 }
 
-// DeleteItem удаляет текущую запись с паролем по ID.
-func (m *MemoryStorage) DeleteItem(_ context.Context, ownerID, userID string) error {
+// CreateCollection создаёт коллекцию организации.
+func (m *MemoryStorage) CreateCollection(_ context.Context, coll *entity.Collection) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if m.items[ownerID] == nil {
-		return nil
+	if _, ok := m.collections[coll.ID]; ok {
+		return fmt.Errorf("collection: %w", ErrEntityAlreadyExists)
 	}
 
-	delete(m.items[ownerID], userID)
+	cp := *coll
+	m.collections[coll.ID] = &cp
 
 	return nil
 }
 
-// ListChangedSince обновить данные.
-func (m *MemoryStorage) ListChangedSince(
-	_ context.Context,
-	ownerID string,
-	since time.Time,
-) ([]*entity.VaultItem, error) {
+// GetCollection получает коллекцию организации.
+func (m *MemoryStorage) GetCollection(_ context.Context, orgID, collectionID string) (*entity.Collection, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
-	userItems := m.items[ownerID]
-	if userItems == nil {
-		return []*entity.VaultItem{}, nil
+	coll, ok := m.collections[collectionID]
+	if !ok || coll.OrgID != orgID {
+		return nil, fmt.Errorf("collection: %w", ErrEntityNotFound)
 	}
 
-	var res []*entity.VaultItem
+	res := *coll
 
-	for _, it := range userItems {
-		if it.UpdatedAt.After(since) {
-			cp := *it
+	return &res, nil
+}
+
+// ListCollections получает коллекции организации.
+func (m *MemoryStorage) ListCollections(_ context.Context, orgID string) ([]*entity.Collection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	res := make([]*entity.Collection, 0)
+
+	for _, coll := range m.collections {
+		if coll.OrgID == orgID {
+			cp := *coll
 			res = append(res, &cp)
 		}
 	}
 
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+
 	return res, nil
 }
+
+// DeleteCollection удаляет коллекцию организации.
+func (m *MemoryStorage) DeleteCollection(_ context.Context, orgID, collectionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	coll, ok := m.collections[collectionID]
+	if !ok || coll.OrgID != orgID {
+		return fmt.Errorf("collection: %w", ErrEntityNotFound)
+	}
+
+	delete(m.collections, collectionID)
+
+	return nil
+}
+
+// filterMembers возвращает упорядоченные копии участия, ключи которого подходят под match.
+func (m *MemoryStorage) filterMembers(match func(key memberKey) bool) []*entity.Member {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	res := make([]*entity.Member, 0)
+
+	for key, member := range m.members {
+		if match(key) {
+			cp := *member
+			res = append(res, &cp)
+		}
+	}
+
+	sortMembers(res)
+
+	return res
+}
+
+// CreateFolder создаёт папку записей.
+func (m *MemoryStorage) CreateFolder(_ context.Context, folder *entity.Folder) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if folder.ID == "" {
+		folder.ID = uuid.New().String()
+	}
+
+	if _, ok := m.folders[folder.OwnerID][folder.ID]; ok {
+		return fmt.Errorf("folder: %w", ErrEntityAlreadyExists)
+	}
+
+	if err := checkFolderParent(folder.ID, folder.ParentID, m.ownerFolders(folder.OwnerID)); err != nil {
+		return fmt.Errorf("folder: %w", err)
+	}
+
+	if _, ok := m.folders[folder.OwnerID]; !ok {
+		m.folders[folder.OwnerID] = make(map[string]*entity.Folder)
+	}
+
+	folder.Version = 1
+	folder.UpdatedAt = time.Now().UTC()
+	folder.Seq = m.nextSeq(folder.OwnerID)
+	folder.Deleted = false
+
+	cp := *folder
+	m.folders[folder.OwnerID][folder.ID] = &cp
+
+	return nil
+}
+
+// UpdateFolder переименовывает или перемещает папку записей.
+func (m *MemoryStorage) UpdateFolder(_ context.Context, folder *entity.Folder) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.folders[folder.OwnerID][folder.ID]
+	if !ok {
+		return fmt.Errorf("folder: %w", ErrEntityNotFound)
+	}
+
+	if err := checkFolderVersion(current, folder.Version); err != nil {
+		return fmt.Errorf("folder: %w", err)
+	}
+
+	if err := checkFolderParent(folder.ID, folder.ParentID, m.ownerFolders(folder.OwnerID)); err != nil {
+		return fmt.Errorf("folder: %w", err)
+	}
+
+	folder.Version = current.Version + 1
+	folder.UpdatedAt = time.Now().UTC()
+	folder.Seq = m.nextSeq(folder.OwnerID)
+	folder.Deleted = false
+
+	cp := *folder
+	m.folders[folder.OwnerID][folder.ID] = &cp
+
+	return nil
+}
+
+// GetFolder получает папку записей.
+func (m *MemoryStorage) GetFolder(_ context.Context, ownerID, folderID string) (*entity.Folder, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	folder, ok := m.folders[ownerID][folderID]
+	if !ok || folder.Deleted {
+		return nil, fmt.Errorf("folder: %w", ErrEntityNotFound)
+	}
+
+	res := *folder
+
+	return &res, nil
+}
+
+// ListFolders получает папки записей владельца.
+func (m *MemoryStorage) ListFolders(_ context.Context, ownerID string) ([]*entity.Folder, error) {
+	return m.filterFolders(ownerID, func(folder *entity.Folder) bool { return !folder.Deleted }), nil
+}
+
+// DeleteFolder удаляет папку записей, оставляя отметку об удалении.
+func (m *MemoryStorage) DeleteFolder(_ context.Context, ownerID, folderID string, version int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.folders[ownerID][folderID]
+	if !ok {
+		return fmt.Errorf("folder: %w", ErrEntityNotFound)
+	}
+
+	if err := checkFolderVersion(current, version); err != nil {
+		return fmt.Errorf("folder: %w", err)
+	}
+
+	now := time.Now().UTC()
+
+	if err := checkFolderEmpty(folderID, m.ownerFolders(ownerID), mapValues(m.items[ownerID]), now); err != nil {
+		return fmt.Errorf("folder: %w", err)
+	}
+
+	m.folders[ownerID][folderID] = deletedFolder(current, m.nextSeq(ownerID), now)
+
+	return nil
+}
+
+// ownerFolders возвращает все папки владельца, включая удалённые (вызывается под блокировкой).
+func (m *MemoryStorage) ownerFolders(ownerID string) []*entity.Folder {
+	folders := make([]*entity.Folder, 0, len(m.folders[ownerID]))
+	for _, folder := range m.folders[ownerID] {
+		folders = append(folders, folder)
+	}
+
+	return folders
+}
+
+// ListFolderChanges получает изменения папок владельца после указанного номера.
+func (m *MemoryStorage) ListFolderChanges(
+	_ context.Context,
+	ownerID string,
+	afterSeq int64,
+	limit int,
+) ([]*entity.Folder, error) {
+	res := m.filterFolders(ownerID, func(folder *entity.Folder) bool { return folder.Seq > afterSeq })
+
+	return limitFolderChanges(res, limit), nil
+}
+
+// filterFolders возвращает упорядоченные по ID копии папок владельца, подходящих под match.
+func (m *MemoryStorage) filterFolders(ownerID string, match func(folder *entity.Folder) bool) []*entity.Folder {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	res := make([]*entity.Folder, 0)
+
+	for _, folder := range m.folders[ownerID] {
+		if match(folder) {
+			cp := *folder
+			res = append(res, &cp)
+		}
+	}
+
+	return sortFolders(res)
+}
+
+// Snapshot получает снимок всех данных хранилища под одной блокировкой.
+func (m *MemoryStorage) Snapshot(_ context.Context) (*entity.Snapshot, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	builder := newSnapshotBuilder()
+	snap := builder.snap
+
+	for _, user := range m.users {
+		cp := *user
+		snap.Users = append(snap.Users, &cp)
+	}
+
+	for userID := range m.tokens {
+		snap.Tokens = append(snap.Tokens, userID)
+	}
+
+	for _, key := range m.keys {
+		snap.Keys = append(snap.Keys, copyDataKey(key))
+	}
+
+	for _, key := range m.pubKeys {
+		snap.PublicKeys = append(snap.PublicKeys, copyPublicKey(key))
+	}
+
+	for _, share := range m.shares {
+		snap.Shares = append(snap.Shares, copyShare(share))
+	}
+
+	for _, org := range m.orgs {
+		cp := *org
+		snap.Orgs = append(snap.Orgs, &cp)
+	}
+
+	for _, member := range m.members {
+		cp := *member
+		snap.Members = append(snap.Members, &cp)
+	}
+
+	for _, coll := range m.collections {
+		cp := *coll
+		snap.Collections = append(snap.Collections, &cp)
+	}
+
+	for ownerID, seq := range m.seqs {
+		builder.vault(ownerID).Seq = seq
+	}
+
+	for ownerID, userItems := range m.items {
+		vault := builder.vault(ownerID)
+
+		for _, item := range userItems {
+			cp := *item
+			vault.Items = append(vault.Items, &cp)
+		}
+	}
+
+	for ownerID, userTombs := range m.tombs {
+		vault := builder.vault(ownerID)
+
+		for _, tomb := range userTombs {
+			cp := *tomb
+			vault.Tombstones = append(vault.Tombstones, &cp)
+		}
+	}
+
+	for ownerID, userRevs := range m.revs {
+		vault := builder.vault(ownerID)
+
+		for _, revs := range userRevs {
+			for _, rev := range revs {
+				vault.Revisions = append(vault.Revisions, copyRevision(rev))
+			}
+		}
+	}
+
+	for ownerID, userTrash := range m.trash {
+		vault := builder.vault(ownerID)
+
+		for _, trashed := range userTrash {
+			vault.Trash = append(vault.Trash, copyTrashItem(trashed))
+		}
+	}
+
+	for ownerID, userFolders := range m.folders {
+		vault := builder.vault(ownerID)
+
+		for _, folder := range userFolders {
+			cp := *folder
+			vault.Folders = append(vault.Folders, &cp)
+		}
+	}
+
+	return builder.build(), nil
+}
+
+// Restore загружает снимок в пустое хранилище.
+func (m *MemoryStorage) Restore(_ context.Context, snap *entity.Snapshot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.users) > 0 || len(m.tokens) > 0 || len(m.keys) > 0 || len(m.seqs) > 0 || len(m.pubKeys) > 0 ||
+		len(m.shares) > 0 || len(m.orgs) > 0 || len(m.members) > 0 || len(m.collections) > 0 || len(m.folders) > 0 {
+		return fmt.Errorf("restore: %w", ErrStorageNotEmpty)
+	}
+
+	seqs := make(map[string]int64, len(snap.Vaults))
+
+	for _, vault := range snap.Vaults {
+		seq, err := restoredVault(vault)
+		if err != nil {
+			return fmt.Errorf("restore: %w", err)
+		}
+
+		seqs[vault.OwnerID] = seq
+	}
+
+	for _, user := range snap.Users {
+		cp := *user
+		m.users[strings.ToLower(user.Email)] = &cp
+	}
+
+	for _, userID := range snap.Tokens {
+		m.tokens[userID] = &entity.Token{}
+	}
+
+	for _, key := range snap.Keys {
+		m.keys[key.OwnerID] = copyDataKey(key)
+	}
+
+	for _, key := range snap.PublicKeys {
+		m.pubKeys[key.UserID] = copyPublicKey(key)
+	}
+
+	for _, share := range snap.Shares {
+		key := shareKey{ownerID: share.OwnerID, itemID: share.ItemID, recipientID: share.RecipientID}
+		m.shares[key] = copyShare(share)
+	}
+
+	for _, org := range snap.Orgs {
+		cp := *org
+		m.orgs[org.ID] = &cp
+	}
+
+	for _, member := range snap.Members {
+		cp := *member
+		m.members[memberKey{orgID: member.OrgID, userID: member.UserID}] = &cp
+	}
+
+	for _, coll := range snap.Collections {
+		cp := *coll
+		m.collections[coll.ID] = &cp
+	}
+
+	for _, vault := range snap.Vaults {
+		m.restoreVault(vault, seqs[vault.OwnerID])
+	}
+
+	return nil
+}
+
+// restoreVault загружает данные пользователя из снимка (вызывается под блокировкой).
+func (m *MemoryStorage) restoreVault(vault *entity.VaultSnapshot, seq int64) {
+	ownerID := vault.OwnerID
+	m.seqs[ownerID] = seq
+	m.items[ownerID] = make(map[string]*entity.VaultItem, len(vault.Items))
+	m.tombs[ownerID] = make(map[string]*entity.Tombstone, len(vault.Tombstones))
+	m.revs[ownerID] = make(map[string][]*entity.Revision)
+	m.trash[ownerID] = make(map[string]*entity.TrashItem, len(vault.Trash))
+	m.folders[ownerID] = make(map[string]*entity.Folder, len(vault.Folders))
+
+	for _, item := range vault.Items {
+		cp := *item
+		m.items[ownerID][item.ID] = &cp
+	}
+
+	for _, tomb := range vault.Tombstones {
+		cp := *tomb
+		m.tombs[ownerID][tomb.ID] = &cp
+	}
+
+	for _, rev := range vault.Revisions {
+		m.revs[ownerID][rev.Item.ID] = append(m.revs[ownerID][rev.Item.ID], copyRevision(rev))
+	}
+
+	// Прежние версии хранятся от старых к новым.
+	for _, revs := range m.revs[ownerID] {
+		sort.Slice(revs, func(i, j int) bool { return revs[i].Item.Version < revs[j].Item.Version })
+	}
+
+	for _, trashed := range vault.Trash {
+		m.trash[ownerID][trashed.Item.ID] = copyTrashItem(trashed)
+	}
+
+	for _, folder := range vault.Folders {
+		cp := *folder
+		m.folders[ownerID][folder.ID] = &cp
+	}
+}
+
+// CreateItem создаёт новую запись с паролем.
+func (m *MemoryStorage) CreateItem(_ context.Context, item *entity.VaultItem) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.createItem(item); err != nil {
+		return "", err
+	}
+
+	return item.ID, nil
+}
+
+// UpdateItem обновляет текущую запись с паролем.
+func (m *MemoryStorage) UpdateItem(_ context.Context, item *entity.VaultItem) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, err := m.updateItem(item)
+	if err != nil {
+		return err
+	}
+
+	setStoredState(item, stored)
+
+	return nil
+}
+
+// UpsertItem обновляет или обновляет текущую запись с паролем для синхронизации.
+func (m *MemoryStorage) UpsertItem(_ context.Context, item *entity.VaultItem) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, err := m.upsert(item)
+	if err != nil {
+		return "", err
+	}
+
+	setStoredState(item, stored)
+
+	return item.ID, nil
+}
+
+// GetItem получает текущую запись с паролем по ID.
+func (m *MemoryStorage) GetItem(
+	_ context.Context,
+	ownerID, userID string,
+) (*entity.VaultItem, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	userItems := m.items[ownerID]
+	if userItems == nil {
+		return nil, fmt.Errorf("item: %w", ErrEntityNotFound)
+	}
+
+	it := userItems[userID]
+	if it == nil || it.IsExpired(time.Now()) {
+		return nil, fmt.Errorf("item: %w", ErrEntityNotFound)
+	}
+
+	cp := *it
+
+	return &cp, nil
+}
+
+// ListItems получает список записей с паролями по пользователю.
+func (m *MemoryStorage) ListItems(_ context.Context, ownerID string) ([]*entity.VaultItem, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	userItems := m.items[ownerID]
+	if userItems == nil {
+		return []*entity.VaultItem{}, nil
+	}
+
+	res := make([]*entity.VaultItem, 0, len(userItems))
+
+	for _, v := range liveItems(mapValues(userItems), time.Now()) {
+		cp := *v
+		res = append(res, &cp)
+	}
+
+	return res, nil
+}
+
+// ListTags получает метки записей пользователя с количеством записей.
+func (m *MemoryStorage) ListTags(_ context.Context, ownerID string) ([]entity.TagCount, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return countTags(liveItems(mapValues(m.items[ownerID]), time.Now())), nil
+}
+
+// QueryItems получает записи пользователя по условиям выборки.
+func (m *MemoryStorage) QueryItems(
+	_ context.Context,
+	ownerID string,
+	query *entity.ItemQuery,
+) ([]*entity.VaultItem, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	res := make([]*entity.VaultItem, 0)
+
+	for _, item := range queryItems(liveItems(mapValues(m.items[ownerID]), time.Now()), query) {
+		cp := *item
+		res = append(res, &cp)
+	}
+
+	return res, nil
+}
+
+// SetItemContent заменяет содержимое бинарной записи.
+func (m *MemoryStorage) SetItemContent(
+	_ context.Context,
+	ownerID, itemID string,
+	version int64,
+	content *entity.Content,
+) (*entity.VaultItem, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current := m.items[ownerID][itemID]
+	if current == nil {
+		return nil, fmt.Errorf("item: %w", ErrEntityNotFound)
+	}
+
+	return m.updateItem(contentUpdate(current, version, content))
+}
+
+// ContentHashes получает хеши содержимого, на которое ссылаются данные хранилища.
+func (m *MemoryStorage) ContentHashes(_ context.Context) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	hashes := make(map[string]struct{})
+
+	for _, userItems := range m.items {
+		for _, item := range userItems {
+			collectContentHash(hashes, item)
+		}
+	}
+
+	for _, userRevs := range m.revs {
+		for _, revs := range userRevs {
+			for _, rev := range revs {
+				collectContentHash(hashes, rev.Item)
+			}
+		}
+	}
+
+	for _, userTrash := range m.trash {
+		for _, trashed := range userTrash {
+			collectContentHash(hashes, trashed.Item)
+		}
+	}
+
+	return sortedHashes(hashes), nil
+}
+
+// DeleteItem удаляет текущую запись с паролем по ID.
+func (m *MemoryStorage) DeleteItem(_ context.Context, ownerID, itemID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := m.remove(ownerID, itemID, 0)
+
+	return err
+}
+
+// ListTrash получает записи пользователя в корзине.
+func (m *MemoryStorage) ListTrash(_ context.Context, ownerID string) ([]*entity.TrashItem, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	res := make([]*entity.TrashItem, 0, len(m.trash[ownerID]))
+
+	for _, trashed := range m.trash[ownerID] {
+		res = append(res, copyTrashItem(trashed))
+	}
+
+	return sortTrash(res), nil
+}
+
+// RestoreTrash восстанавливает запись из корзины.
+func (m *MemoryStorage) RestoreTrash(
+	_ context.Context,
+	ownerID, itemID string,
+) (*entity.VaultItem, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	trashed, ok := m.trash[ownerID][itemID]
+	if !ok {
+		return nil, fmt.Errorf("trash: %w", ErrEntityNotFound)
+	}
+
+	if err := checkNotExpired(trashed.Item.ExpiresAt, time.Now()); err != nil {
+		return nil, fmt.Errorf("trash: %w", err)
+	}
+
+	return m.createItem(restoredItem(trashed, ownerID))
+}
+
+// EmptyTrash безвозвратно удаляет записи из корзины пользователя.
+func (m *MemoryStorage) EmptyTrash(_ context.Context, ownerID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.purgeTrash(ownerID, func(*entity.TrashItem) bool { return true }), nil
+}
+
+// PurgeTrash безвозвратно удаляет записи, помещённые в корзину раньше before.
+func (m *MemoryStorage) PurgeTrash(_ context.Context, before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := 0
+
+	for ownerID := range m.trash {
+		count += m.purgeTrash(ownerID, func(trashed *entity.TrashItem) bool {
+			return trashed.DeletedAt.Before(before)
+		})
+	}
+
+	return count, nil
+}
+
+// ExpireItems удаляет записи с истёкшим сроком.
+func (m *MemoryStorage) ExpireItems(_ context.Context, now time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := 0
+
+	for _, userItems := range m.items {
+		for _, item := range userItems {
+			if item.IsExpired(now) {
+				m.expire(item)
+
+				count++
+			}
+		}
+	}
+
+	for ownerID := range m.trash {
+		count += m.purgeTrash(ownerID, func(trashed *entity.TrashItem) bool {
+			return trashed.Item.IsExpired(now)
+		})
+	}
+
+	return count, nil
+}
+
+// expire удаляет запись с истёкшим сроком и её прежние версии, оставляя отметку
+// об удалении с признаком Expired (вызывается под блокировкой).
+func (m *MemoryStorage) expire(item *entity.VaultItem) {
+	delete(m.items[item.OwnerID], item.ID)
+	delete(m.revs[item.OwnerID], item.ID)
+	m.deleteItemShares(item.OwnerID, item.ID)
+
+	if _, ok := m.tombs[item.OwnerID]; !ok {
+		m.tombs[item.OwnerID] = make(map[string]*entity.Tombstone)
+	}
+
+	m.tombs[item.OwnerID][item.ID] = &entity.Tombstone{
+		ID:        item.ID,
+		OwnerID:   item.OwnerID,
+		Version:   item.Version + 1,
+		DeletedAt: time.Now().UTC(),
+		Seq:       m.nextSeq(item.OwnerID),
+		Expired:   true,
+	}
+}
+
+// purgeTrash удаляет из корзины пользователя подходящие записи
+// и их прежние версии (вызывается под блокировкой).
+func (m *MemoryStorage) purgeTrash(ownerID string, match func(*entity.TrashItem) bool) int {
+	userTrash := m.trash[ownerID]
+	count := 0
+
+	for itemID, trashed := range userTrash {
+		if !match(trashed) {
+			continue
+		}
+
+		delete(userTrash, itemID)
+		delete(m.revs[ownerID], itemID)
+		m.deleteItemShares(ownerID, itemID)
+
+		count++
+	}
+
+	if len(userTrash) == 0 {
+		delete(m.trash, ownerID)
+	}
+
+	if len(m.revs[ownerID]) == 0 {
+		delete(m.revs, ownerID)
+	}
+
+	return count
+}
+
+// ListRevisions получает прежние версии записи, начиная с последней.
+func (m *MemoryStorage) ListRevisions(
+	_ context.Context,
+	ownerID, itemID string,
+) ([]*entity.Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	revs := m.revs[ownerID][itemID]
+	res := make([]*entity.Revision, 0, len(revs))
+
+	for i := len(revs) - 1; i >= 0; i-- {
+		res = append(res, copyRevision(revs[i]))
+	}
+
+	return res, nil
+}
+
+// GetRevision получает прежнюю версию записи по номеру версии.
+func (m *MemoryStorage) GetRevision(
+	_ context.Context,
+	ownerID, itemID string,
+	version int64,
+) (*entity.Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, rev := range m.revs[ownerID][itemID] {
+		if rev.Item.Version == version {
+			return copyRevision(rev), nil
+		}
+	}
+
+	return nil, fmt.Errorf("revision: %w", ErrEntityNotFound)
+}
+
+// CompactRevisions удаляет прежние версии, заменённые раньше before.
+func (m *MemoryStorage) CompactRevisions(_ context.Context, before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := 0
+
+	for ownerID, userRevs := range m.revs {
+		for itemID, revs := range userRevs {
+			kept := revs[:0]
+
+			for _, rev := range revs {
+				if rev.CreatedAt.Before(before) {
+					count++
+
+					continue
+				}
+
+				kept = append(kept, rev)
+			}
+
+			if len(kept) == 0 {
+				delete(userRevs, itemID)
+			} else {
+				userRevs[itemID] = kept
+			}
+		}
+
+		if len(userRevs) == 0 {
+			delete(m.revs, ownerID)
+		}
+	}
+
+	return count, nil
+}
+
+// ApplyChanges применяет пакет изменений пользователя под одной блокировкой.
+func (m *MemoryStorage) ApplyChanges(
+	_ context.Context,
+	ownerID string,
+	changes []*entity.Change,
+) ([]*entity.ChangeResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return applyChanges(ownerID, changes, m)
+}
+
+// createItem создаёт запись (вызывается под блокировкой).
+func (m *MemoryStorage) createItem(item *entity.VaultItem) (*entity.VaultItem, error) {
+	if _, ok := m.items[item.OwnerID]; !ok {
+		m.items[item.OwnerID] = make(map[string]*entity.VaultItem)
+	}
+
+	if item.ID == "" {
+		item.ID = uuid.New().String()
+	}
+
+	if _, ok := m.items[item.OwnerID][item.ID]; ok {
+		return nil, fmt.Errorf("item: %w", ErrEntityAlreadyExists)
+	}
+
+	if err := checkQuota(m.quota, m.usage(item.OwnerID), 1, item.UsageSize(), item.Size()); err != nil {
+		return nil, fmt.Errorf("item: %w", err)
+	}
+
+	version := int64(1)
+
+	if tomb, ok := m.tombs[item.OwnerID][item.ID]; ok {
+		if item.Version != 0 && item.Version != tomb.Version {
+			return nil, fmt.Errorf("item: %w", ErrVersionConflict)
+		}
+
+		version = tomb.Version + 1
+		delete(m.tombs[item.OwnerID], item.ID)
+	}
+
+	delete(m.trash[item.OwnerID], item.ID)
+
+	item.Content = resolveContent(item.Content, nil)
+	item.Attachments = resolveAttachments(item.Attachments, nil)
+	item.Version = version
+	item.UpdatedAt = time.Now().UTC()
+	item.Seq = m.nextSeq(item.OwnerID)
+	cl := *item
+	m.items[item.OwnerID][item.ID] = &cl
+
+	res := cl
+
+	return &res, nil
+}
+
+// updateItem обновляет запись (вызывается под блокировкой).
+func (m *MemoryStorage) updateItem(item *entity.VaultItem) (*entity.VaultItem, error) {
+	userItems := m.items[item.OwnerID]
+	if userItems == nil {
+		return nil, fmt.Errorf("item: %w", ErrEntityNotFound)
+	}
+
+	old := userItems[item.ID]
+	if old == nil {
+		return nil, fmt.Errorf("item: %w", ErrEntityNotFound)
+	}
+
+	if err := checkNotExpired(old.ExpiresAt, time.Now()); err != nil {
+		return nil, fmt.Errorf("item: %w", err)
+	}
+
+	if item.Version != 0 && item.Version != old.Version {
+		return nil, fmt.Errorf("item: %w", ErrVersionConflict)
+	}
+
+	newIt := updatedItem(item, old.Content, old.Attachments)
+
+	delta := newIt.UsageSize() - old.UsageSize()
+	if err := checkQuota(m.quota, m.usage(item.OwnerID), 0, delta, newIt.Size()); err != nil {
+		return nil, fmt.Errorf("item: %w", err)
+	}
+
+	m.keepRevision(old)
+
+	newIt.Version = old.Version + 1
+	newIt.UpdatedAt = time.Now().UTC()
+	newIt.Seq = m.nextSeq(item.OwnerID)
+	userItems[item.ID] = newIt
+
+	res := *newIt
+
+	return &res, nil
+}
+
+// upsert обновляет запись или создаёт её при отсутствии (вызывается под блокировкой).
+func (m *MemoryStorage) upsert(item *entity.VaultItem) (*entity.VaultItem, error) {
+	if item.ID == "" {
+		return m.createItem(item)
+	}
+
+	res, err := m.updateItem(item)
+	if errors.Is(err, ErrEntityNotFound) && !errors.Is(err, errItemExpired) {
+		return m.createItem(item)
+	}
+
+	return res, err
+}
+
+// remove удаляет запись и оставляет отметку об удалении (вызывается под блокировкой).
+// Запись с истёкшим сроком считается уже удалённой: её удаляет фоновая задача.
+func (m *MemoryStorage) remove(ownerID, itemID string, version int64) (*entity.VaultItem, error) {
+	old := m.items[ownerID][itemID]
+	if old != nil && old.IsExpired(time.Now()) {
+		return nil, nil //nolint:nilnil // запись уже удалена по сроку
+	}
+
+	if old == nil {
+		if tomb, ok := m.tombs[ownerID][itemID]; ok {
+			return tomb.AsItem(), nil
+		}
+
+		return nil, nil //nolint:nilnil // записи не было, удалять нечего
+	}
+
+	if version != 0 && version != old.Version {
+		return nil, fmt.Errorf("item: %w", ErrVersionConflict)
+	}
+
+	m.keepRevision(old)
+	delete(m.items[ownerID], itemID)
+
+	if _, ok := m.tombs[ownerID]; !ok {
+		m.tombs[ownerID] = make(map[string]*entity.Tombstone)
+	}
+
+	tomb := &entity.Tombstone{
+		ID:        itemID,
+		OwnerID:   ownerID,
+		Version:   old.Version + 1,
+		DeletedAt: time.Now().UTC(),
+		Seq:       m.nextSeq(ownerID),
+		Expired:   false,
+	}
+	m.tombs[ownerID][itemID] = tomb
+
+	if _, ok := m.trash[ownerID]; !ok {
+		m.trash[ownerID] = make(map[string]*entity.TrashItem)
+	}
+
+	m.trash[ownerID][itemID] = &entity.TrashItem{
+		DeletedAt: tomb.DeletedAt,
+		Item:      old,
+	}
+
+	return tomb.AsItem(), nil
+}
+
+// usage подсчитывает объём данных пользователя без записей с истёкшим сроком
+// (вызывается под блокировкой).
+func (m *MemoryStorage) usage(ownerID string) entity.Usage {
+	usage := entity.Usage{Items: 0, Bytes: 0}
+	now := time.Now()
+
+	for _, item := range m.items[ownerID] {
+		if item.IsExpired(now) {
+			continue
+		}
+
+		usage.Items++
+		usage.Bytes += item.UsageSize()
+	}
+
+	return usage
+}
+
+// keepRevision сохраняет заменяемую версию записи (вызывается под блокировкой).
+func (m *MemoryStorage) keepRevision(old *entity.VaultItem) {
+	if m.revisionLimit == 0 {
+		return
+	}
+
+	if _, ok := m.revs[old.OwnerID]; !ok {
+		m.revs[old.OwnerID] = make(map[string][]*entity.Revision)
+	}
+
+	cp := *old
+	revs := append(m.revs[old.OwnerID][old.ID], &entity.Revision{
+		CreatedAt: time.Now().UTC(),
+		Item:      &cp,
+	})
+
+	if len(revs) > m.revisionLimit {
+		revs = revs[len(revs)-m.revisionLimit:]
+	}
+
+	m.revs[old.OwnerID][old.ID] = revs
+}
+
+// current возвращает запись или отметку об удалении (вызывается под блокировкой).
+func (m *MemoryStorage) current(ownerID, itemID string) (*entity.VaultItem, error) {
+	if it := m.items[ownerID][itemID]; it != nil {
+		cp := *it
+
+		return &cp, nil
+	}
+
+	if tomb, ok := m.tombs[ownerID][itemID]; ok {
+		return tomb.AsItem(), nil
+	}
+
+	return nil, fmt.Errorf("item: %w", ErrEntityNotFound)
+}
+
+// ListChanges получает изменения пользователя после указанного номера.
+func (m *MemoryStorage) ListChanges(
+	_ context.Context,
+	ownerID string,
+	afterSeq int64,
+	limit int,
+) ([]*entity.VaultItem, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	res := make([]*entity.VaultItem, 0)
+
+	for _, it := range m.items[ownerID] {
+		if it.Seq > afterSeq {
+			cp := *it
+			res = append(res, &cp)
+		}
+	}
+
+	for _, tomb := range m.tombs[ownerID] {
+		if tomb.Seq > afterSeq {
+			res = append(res, tomb.AsItem())
+		}
+	}
+
+	return limitChanges(res, limit), nil
+}
+
+// nextSeq выдаёт следующий номер изменения пользователя (вызывается под блокировкой).
+func (m *MemoryStorage) nextSeq(ownerID string) int64 {
+	m.seqs[ownerID]++
+
+	return m.seqs[ownerID]
+}
+
+// CompactTombstones удаляет отметки об удалении записей и папок, созданные раньше before.
+func (m *MemoryStorage) CompactTombstones(_ context.Context, before time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := 0
+
+	for ownerID, userTombs := range m.tombs {
+		for itemID, tomb := range userTombs {
+			if tomb.DeletedAt.Before(before) {
+				delete(userTombs, itemID)
+
+				count++
+			}
+		}
+
+		if len(userTombs) == 0 {
+			delete(m.tombs, ownerID)
+		}
+	}
+
+	for _, userFolders := range m.folders {
+		for folderID, folder := range userFolders {
+			if folder.Deleted && folder.UpdatedAt.Before(before) {
+				delete(userFolders, folderID)
+
+				count++
+			}
+		}
+	}
+
+	return count, nil
+}
+
+// copyRevision создаёт копию прежней версии записи.
+func copyRevision(rev *entity.Revision) *entity.Revision {
+	item := *rev.Item
+
+	return &entity.Revision{
+		CreatedAt: rev.CreatedAt,
+		Item:      &item,
+	}
+}
+
+// copyTrashItem создаёт копию записи в корзине.
+func copyTrashItem(trashed *entity.TrashItem) *entity.TrashItem {
+	item := *trashed.Item
+
+	return &entity.TrashItem{
+		DeletedAt: trashed.DeletedAt,
+		Item:      &item,
+	}
+}
+
+// copyDataKey создаёт копию ключа данных.
+func copyDataKey(key *entity.DataKey) *entity.DataKey {
+	res := *key
+	res.Wrapped = append([]byte(nil), key.Wrapped...)
+
+	return &res
+}
+
+// mapValues возвращает значения словаря записей.
+func mapValues(items map[string]*entity.VaultItem) []*entity.VaultItem {
+	res := make([]*entity.VaultItem, 0, len(items))
+
+	for _, item := range items {
+		res = append(res, item)
+	}
+
+	return res
+}
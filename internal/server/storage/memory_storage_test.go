@@ -0,0 +1,1425 @@
+package storage_test
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/storagetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+	===== MemoryStorage.UpdateItem =====
+*/
+
+func TestMemoryStorage_UpdateItem_VersionConflict(t *testing.T) {
+	t.Parallel()
+
+	stor := storage.NewMemoryStorage()
+	ctx := context.Background()
+
+	//nolint:exhaustruct // not all fields needed in test
+	item := &entity.VaultItem{OwnerID: "user-1", Title: "Email"}
+
+	itemID, err := stor.CreateItem(ctx, item)
+	require.NoError(t, err)
+
+	//nolint:exhaustruct // not all fields needed in test
+	first := &entity.VaultItem{ID: itemID, OwnerID: "user-1", Title: "First", Version: 1}
+	require.NoError(t, stor.UpdateItem(ctx, first))
+
+	//nolint:exhaustruct // not all fields needed in test
+	second := &entity.VaultItem{ID: itemID, OwnerID: "user-1", Title: "Second", Version: 1}
+	require.ErrorIs(t, stor.UpdateItem(ctx, second), storage.ErrVersionConflict)
+
+	_, err = stor.UpsertItem(ctx, second)
+	require.ErrorIs(t, err, storage.ErrVersionConflict)
+
+	//nolint:exhaustruct // not all fields needed in test
+	force := &entity.VaultItem{ID: itemID, OwnerID: "user-1", Title: "Force"}
+	require.NoError(t, stor.UpdateItem(ctx, force))
+
+	got, err := stor.GetItem(ctx, "user-1", itemID)
+	require.NoError(t, err)
+	assert.Equal(t, "Force", got.Title)
+	assert.Equal(t, int64(3), got.Version)
+}
+
+/*
+	===== MemoryStorage.DeleteItem =====
+*/
+
+func TestMemoryStorage_DeleteItem_Tombstone(t *testing.T) {
+	t.Parallel()
+
+	stor := storage.NewMemoryStorage()
+
+	ctx := context.Background()
+
+	//nolint:exhaustruct // not all fields needed in test
+	item := &entity.VaultItem{OwnerID: "user-1", Title: "Email"}
+
+	itemID, err := stor.CreateItem(ctx, item)
+	require.NoError(t, err)
+	require.NoError(t, stor.DeleteItem(ctx, "user-1", itemID))
+
+	changed, err := stor.ListChanges(ctx, "user-1", 0, 0)
+	require.NoError(t, err)
+	require.Len(t, changed, 1)
+	assert.Equal(t, itemID, changed[0].ID)
+	assert.True(t, changed[0].Deleted)
+	assert.Equal(t, int64(2), changed[0].Version)
+
+	//nolint:exhaustruct // not all fields needed in test
+	stale := &entity.VaultItem{ID: itemID, OwnerID: "user-1", Title: "Stale", Version: 1}
+	_, err = stor.UpsertItem(ctx, stale)
+	require.ErrorIs(t, err, storage.ErrVersionConflict)
+
+	//nolint:exhaustruct // not all fields needed in test
+	restored := &entity.VaultItem{ID: itemID, OwnerID: "user-1", Title: "Restored", Version: 2}
+	_, err = stor.UpsertItem(ctx, restored)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), restored.Version)
+
+	changed, err = stor.ListChanges(ctx, "user-1", 0, 0)
+	require.NoError(t, err)
+	require.Len(t, changed, 1)
+	assert.False(t, changed[0].Deleted)
+}
+
+func TestMemoryStorage_CompactTombstones(t *testing.T) {
+	t.Parallel()
+
+	stor := storage.NewMemoryStorage()
+
+	ctx := context.Background()
+
+	//nolint:exhaustruct // not all fields needed in test
+	item := &entity.VaultItem{OwnerID: "user-1", Title: "Email"}
+
+	itemID, err := stor.CreateItem(ctx, item)
+	require.NoError(t, err)
+	require.NoError(t, stor.DeleteItem(ctx, "user-1", itemID))
+
+	count, err := stor.CompactTombstones(ctx, time.Now().UTC().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	count, err = stor.CompactTombstones(ctx, time.Now().UTC().Add(time.Second))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, count, 1)
+
+	changed, err := stor.ListChanges(ctx, "user-1", 0, 0)
+	require.NoError(t, err)
+	assert.Empty(t, changed)
+}
+
+/*
+	===== MemoryStorage.ListChanges =====
+*/
+
+func TestMemoryStorage_ListChanges_Paging(t *testing.T) {
+	t.Parallel()
+
+	stor := storage.NewMemoryStorage()
+
+	ctx := context.Background()
+	ids := make([]string, 0, 3)
+
+	for _, title := range []string{"A", "B", "C"} {
+		//nolint:exhaustruct // not all fields needed in test
+		itemID, err := stor.CreateItem(ctx, &entity.VaultItem{OwnerID: "user-1", Title: title})
+		require.NoError(t, err)
+
+		ids = append(ids, itemID)
+	}
+
+	//nolint:exhaustruct // not all fields needed in test
+	require.NoError(t, stor.UpdateItem(ctx, &entity.VaultItem{ID: ids[0], OwnerID: "user-1"}))
+	require.NoError(t, stor.DeleteItem(ctx, "user-1", ids[1]))
+
+	page, err := stor.ListChanges(ctx, "user-1", 0, 2)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, ids[2], page[0].ID)
+	assert.Equal(t, ids[0], page[1].ID)
+	assert.Less(t, page[0].Seq, page[1].Seq)
+
+	page, err = stor.ListChanges(ctx, "user-1", page[1].Seq, 2)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, ids[1], page[0].ID)
+	assert.True(t, page[0].Deleted)
+
+	page, err = stor.ListChanges(ctx, "user-1", page[0].Seq, 0)
+	require.NoError(t, err)
+	assert.Empty(t, page)
+}
+
+/*
+	===== MemoryStorage.ApplyChanges =====
+*/
+
+func TestMemoryStorage_ApplyChanges(t *testing.T) {
+	t.Parallel()
+
+	stor := storage.NewMemoryStorage()
+
+	ctx := context.Background()
+
+	//nolint:exhaustruct // not all fields needed in test
+	existing := &entity.VaultItem{OwnerID: "user-1", Title: "Existing"}
+	existingID, err := stor.CreateItem(ctx, existing)
+	require.NoError(t, err)
+
+	//nolint:exhaustruct // not all fields needed in test
+	removed := &entity.VaultItem{OwnerID: "user-1", Title: "Removed"}
+	removedID, err := stor.CreateItem(ctx, removed)
+	require.NoError(t, err)
+
+	//nolint:exhaustruct // not all fields needed in test
+	changes := []*entity.Change{
+		{Op: entity.ChangeUpsert, Item: &entity.VaultItem{ID: "new-item", Title: "New"}},
+		{Op: entity.ChangeUpsert, Item: &entity.VaultItem{ID: existingID, Title: "Stale", Version: 7}},
+		{Op: entity.ChangeDelete, Item: &entity.VaultItem{ID: removedID, Version: 1}},
+		{Op: entity.ChangeDelete, Item: &entity.VaultItem{}},
+		{Op: "move", Item: &entity.VaultItem{ID: existingID}},
+	}
+
+	results, err := stor.ApplyChanges(ctx, "user-1", changes)
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+
+	assert.Equal(t, entity.ChangeApplied, results[0].Status)
+	assert.Equal(t, "new-item", results[0].ID)
+	assert.Equal(t, int64(1), results[0].Item.Version)
+
+	assert.Equal(t, entity.ChangeConflict, results[1].Status)
+	assert.Equal(t, "Existing", results[1].Item.Title)
+	assert.Equal(t, int64(1), results[1].Item.Version)
+
+	assert.Equal(t, entity.ChangeApplied, results[2].Status)
+	assert.True(t, results[2].Item.Deleted)
+	assert.Equal(t, int64(2), results[2].Item.Version)
+
+	assert.Equal(t, entity.ChangeRejected, results[3].Status)
+	assert.Equal(t, entity.ChangeRejected, results[4].Status)
+
+	got, err := stor.GetItem(ctx, "user-1", "new-item")
+	require.NoError(t, err)
+	assert.Equal(t, "New", got.Title)
+
+	_, err = stor.GetItem(ctx, "user-1", removedID)
+	require.ErrorIs(t, err, storage.ErrEntityNotFound)
+}
+
+func TestMemoryStorage_Revisions(t *testing.T) {
+	t.Parallel()
+
+	stor := storage.NewMemoryStorage()
+
+	stor.SetRevisionLimit(2)
+
+	ctx := context.Background()
+
+	//nolint:exhaustruct // not all fields needed in test
+	item := &entity.VaultItem{OwnerID: "user-1", Title: "v1"}
+
+	itemID, err := stor.CreateItem(ctx, item)
+	require.NoError(t, err)
+
+	for _, title := range []string{"v2", "v3", "v4"} {
+		//nolint:exhaustruct // not all fields needed in test
+		update := &entity.VaultItem{ID: itemID, OwnerID: "user-1", Title: title}
+		require.NoError(t, stor.UpdateItem(ctx, update))
+	}
+
+	revs, err := stor.ListRevisions(ctx, "user-1", itemID)
+	require.NoError(t, err)
+	require.Len(t, revs, 2)
+	assert.Equal(t, "v3", revs[0].Item.Title)
+	assert.Equal(t, "v2", revs[1].Item.Title)
+	assert.Greater(t, revs[0].Item.Version, revs[1].Item.Version)
+
+	rev, err := stor.GetRevision(ctx, "user-1", itemID, revs[1].Item.Version)
+	require.NoError(t, err)
+	assert.Equal(t, "v2", rev.Item.Title)
+
+	_, err = stor.GetRevision(ctx, "user-1", itemID, 100)
+	require.ErrorIs(t, err, storage.ErrEntityNotFound)
+
+	require.NoError(t, stor.DeleteItem(ctx, "user-1", itemID))
+
+	revs, err = stor.ListRevisions(ctx, "user-1", itemID)
+	require.NoError(t, err)
+	require.Len(t, revs, 2)
+	assert.Equal(t, "v4", revs[0].Item.Title, "deleted content must stay in history")
+
+	count, err := stor.CompactRevisions(ctx, time.Now().UTC().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	count, err = stor.CompactRevisions(ctx, time.Now().UTC().Add(time.Second))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, count, 2)
+
+	revs, err = stor.ListRevisions(ctx, "user-1", itemID)
+	require.NoError(t, err)
+	assert.Empty(t, revs)
+}
+
+func TestMemoryStorage_Trash(t *testing.T) {
+	t.Parallel()
+
+	stor := storage.NewMemoryStorage()
+
+	ctx := context.Background()
+
+	//nolint:exhaustruct // not all fields needed in test
+	first := &entity.VaultItem{OwnerID: "user-1", Title: "first"}
+	//nolint:exhaustruct // not all fields needed in test
+	second := &entity.VaultItem{OwnerID: "user-1", Title: "second"}
+
+	firstID, err := stor.CreateItem(ctx, first)
+	require.NoError(t, err)
+	secondID, err := stor.CreateItem(ctx, second)
+	require.NoError(t, err)
+
+	require.NoError(t, stor.DeleteItem(ctx, "user-1", firstID))
+	require.NoError(t, stor.DeleteItem(ctx, "user-1", secondID))
+
+	trash, err := stor.ListTrash(ctx, "user-1")
+	require.NoError(t, err)
+	require.Len(t, trash, 2)
+	assert.Equal(t, "second", trash[0].Item.Title, "last deleted item goes first")
+
+	restored, err := stor.RestoreTrash(ctx, "user-1", firstID)
+	require.NoError(t, err)
+	assert.Equal(t, "first", restored.Title)
+	assert.Equal(t, int64(3), restored.Version, "restore is a new version after the tombstone")
+
+	got, err := stor.GetItem(ctx, "user-1", firstID)
+	require.NoError(t, err)
+	assert.Equal(t, "first", got.Title)
+
+	_, err = stor.RestoreTrash(ctx, "user-1", firstID)
+	require.ErrorIs(t, err, storage.ErrEntityNotFound)
+
+	count, err := stor.PurgeTrash(ctx, time.Now().UTC().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	count, err = stor.EmptyTrash(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	trash, err = stor.ListTrash(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Empty(t, trash)
+
+	revs, err := stor.ListRevisions(ctx, "user-1", secondID)
+	require.NoError(t, err)
+	assert.Empty(t, revs, "purged item must not keep its history")
+}
+
+func TestMemoryStorage_QueryItems(t *testing.T) {
+	t.Parallel()
+
+	stor := storage.NewMemoryStorage()
+
+	ctx := context.Background()
+
+	for _, title := range []string{"b-mail", "A-Mail", "c_note", "mail", "C-card"} {
+		//nolint:exhaustruct // not all fields needed in test
+		item := &entity.VaultItem{
+			OwnerID: "user-1",
+			Type:    entity.ItemLogin,
+			Title:   title,
+			Meta:    map[string]string{"kind": title[:1]},
+		}
+		if strings.HasSuffix(title, "card") {
+			item.Type = entity.ItemCard
+		}
+
+		_, err := stor.CreateItem(ctx, item)
+		require.NoError(t, err)
+	}
+
+	titlesOf := func(items []*entity.VaultItem) []string {
+		res := make([]string, 0, len(items))
+		for _, item := range items {
+			res = append(res, item.Title)
+		}
+
+		return res
+	}
+
+	//nolint:exhaustruct // not all fields needed in test
+	query := &entity.ItemQuery{Title: "mail", Limit: 2}
+
+	page, err := stor.QueryItems(ctx, "user-1", query)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"A-Mail", "b-mail"}, titlesOf(page))
+
+	query.After = entity.CursorOf(page[1])
+
+	page, err = stor.QueryItems(ctx, "user-1", query)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mail"}, titlesOf(page))
+
+	//nolint:exhaustruct // not all fields needed in test
+	query = &entity.ItemQuery{Title: "c", TitleMatch: entity.TitlePrefix, Desc: true}
+
+	page, err = stor.QueryItems(ctx, "user-1", query)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c_note", "C-card"}, titlesOf(page))
+
+	//nolint:exhaustruct // not all fields needed in test
+	query = &entity.ItemQuery{Title: "_", TitleMatch: entity.TitlePrefix}
+
+	page, err = stor.QueryItems(ctx, "user-1", query)
+	require.NoError(t, err)
+	assert.Empty(t, page, "pattern characters must be matched literally")
+
+	//nolint:exhaustruct // not all fields needed in test
+	query = &entity.ItemQuery{Type: entity.ItemCard}
+
+	page, err = stor.QueryItems(ctx, "user-1", query)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"C-card"}, titlesOf(page))
+
+	//nolint:exhaustruct // not all fields needed in test
+	query = &entity.ItemQuery{MetaKey: "kind", MetaValue: "m"}
+
+	page, err = stor.QueryItems(ctx, "user-1", query)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mail"}, titlesOf(page))
+
+	//nolint:exhaustruct // not all fields needed in test
+	query = &entity.ItemQuery{SortBy: entity.SortByUpdatedAt, Desc: true, Limit: 1}
+
+	page, err = stor.QueryItems(ctx, "user-1", query)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"C-card"}, titlesOf(page))
+}
+
+// checkTagQueries проверяет выборку записей по меткам.
+//
+// Владелец создаётся для проверки, поэтому хранилище может быть общим.
+func checkTagQueries(t *testing.T, stor storage.IStorage) {
+	t.Helper()
+
+	ctx := context.Background()
+	owner := uuid.New().String()
+
+	for title, tags := range map[string][]string{
+		"bank":  {"finance", "personal"},
+		"taxes": {"finance"},
+		"wiki":  {"work"},
+		"plain": nil,
+	} {
+		//nolint:exhaustruct // not all fields needed in test
+		item := &entity.VaultItem{OwnerID: owner, Type: entity.ItemText, Title: title, Tags: tags}
+		_, err := stor.CreateItem(ctx, item)
+		require.NoError(t, err)
+	}
+
+	titlesOf := func(query *entity.ItemQuery) []string {
+		items, err := stor.QueryItems(ctx, owner, query)
+		require.NoError(t, err)
+
+		res := make([]string, 0, len(items))
+		for _, item := range items {
+			res = append(res, item.Title)
+		}
+
+		return res
+	}
+
+	//nolint:exhaustruct // not all fields needed in test
+	assert.Equal(t, []string{"bank", "taxes", "wiki"},
+		titlesOf(&entity.ItemQuery{Tags: []string{"finance", "work"}}), "any of the tags")
+
+	//nolint:exhaustruct // not all fields needed in test
+	assert.Equal(t, []string{"bank"},
+		titlesOf(&entity.ItemQuery{Tags: []string{"finance", "personal"}, TagMatch: entity.TagsAll}), "all of the tags")
+
+	//nolint:exhaustruct // not all fields needed in test
+	assert.Empty(t, titlesOf(&entity.ItemQuery{Tags: []string{"Finance"}}), "tags are case-sensitive")
+
+	//nolint:exhaustruct // not all fields needed in test
+	items, err := stor.QueryItems(ctx, owner, &entity.ItemQuery{Title: "bank"})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, []string{"finance", "personal"}, items[0].Tags)
+}
+
+func TestMemoryStorage_TagQueries(t *testing.T) {
+	t.Parallel()
+
+	checkTagQueries(t, storage.NewMemoryStorage())
+}
+
+// checkAttachments проверяет хранение вложений записи: обновление без вложений
+// их сохраняет, пустой список удаляет, а содержимое вложений остаётся
+// в ссылках хранилища, пока на него ссылаются версии записи или корзина.
+//
+// Владелец создаётся для проверки, поэтому хранилище может быть общим.
+func checkAttachments(t *testing.T, stor storage.IStorage) {
+	t.Helper()
+
+	ctx := context.Background()
+	owner := uuid.New().String()
+
+	att := entity.Attachment{
+		ID:        uuid.New().String(),
+		Name:      "codes.pdf",
+		Mime:      "application/pdf",
+		Hash:      strings.Repeat("cd", 32),
+		Size:      42,
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+
+	//nolint:exhaustruct // not all fields needed in test
+	item := &entity.VaultItem{
+		OwnerID: owner, Type: entity.ItemLogin, Title: "bank", Attachments: []entity.Attachment{att},
+	}
+
+	itemID, err := stor.CreateItem(ctx, item)
+	require.NoError(t, err)
+
+	usage, err := stor.GetUsage(ctx, owner)
+	require.NoError(t, err)
+	assert.Equal(t, item.Size(), usage.Bytes, "attachments count against the item size")
+
+	//nolint:exhaustruct // not all fields needed in test
+	err = stor.UpdateItem(ctx, &entity.VaultItem{ID: itemID, OwnerID: owner, Type: entity.ItemLogin, Title: "renamed"})
+	require.NoError(t, err)
+
+	got, err := stor.GetItem(ctx, owner, itemID)
+	require.NoError(t, err)
+	assert.Equal(t, []entity.Attachment{att}, got.Attachments, "update without attachments keeps them")
+
+	got.Attachments = []entity.Attachment{}
+	require.NoError(t, stor.UpdateItem(ctx, got))
+
+	got, err = stor.GetItem(ctx, owner, itemID)
+	require.NoError(t, err)
+	assert.Empty(t, got.Attachments)
+
+	hashes, err := stor.ContentHashes(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, hashes, att.Hash, "previous versions keep attachments referenced")
+}
+
+func TestMemoryStorage_Attachments(t *testing.T) {
+	t.Parallel()
+
+	checkAttachments(t, storage.NewMemoryStorage())
+}
+
+// checkExpiringItems проверяет записи со сроком действия: запись с истёкшим сроком
+// скрыта до удаления, ExpireItems удаляет её, оставляя отметку с признаком Expired,
+// и удаляет записи с истёкшим сроком из корзины.
+func checkExpiringItems(t *testing.T, stor storage.IStorage) {
+	t.Helper()
+
+	ctx := context.Background()
+	owner := uuid.New().String()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+
+	//nolint:exhaustruct // not all fields needed in test
+	expiredID, err := stor.CreateItem(ctx,
+		&entity.VaultItem{OwnerID: owner, Type: entity.ItemLogin, Title: "otp", ExpiresAt: &past})
+	require.NoError(t, err)
+
+	//nolint:exhaustruct // not all fields needed in test
+	liveID, err := stor.CreateItem(ctx,
+		&entity.VaultItem{OwnerID: owner, Type: entity.ItemLogin, Title: "token", ExpiresAt: &future})
+	require.NoError(t, err)
+
+	//nolint:exhaustruct // not all fields needed in test
+	trashedID, err := stor.CreateItem(ctx,
+		&entity.VaultItem{OwnerID: owner, Type: entity.ItemLogin, Title: "temp", ExpiresAt: &future})
+	require.NoError(t, err)
+	require.NoError(t, stor.DeleteItem(ctx, owner, trashedID))
+
+	_, err = stor.GetItem(ctx, owner, expiredID)
+	require.ErrorIs(t, err, storage.ErrEntityNotFound, "expired item is hidden before it is reaped")
+
+	live, err := stor.GetItem(ctx, owner, liveID)
+	require.NoError(t, err)
+	require.NotNil(t, live.ExpiresAt)
+	assert.True(t, future.Equal(*live.ExpiresAt))
+
+	items, err := stor.ListItems(ctx, owner)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, liveID, items[0].ID)
+
+	count, err := stor.ExpireItems(ctx, now)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, count, 1)
+
+	changes, err := stor.ListChanges(ctx, owner, 0, 100)
+	require.NoError(t, err)
+
+	idx := slices.IndexFunc(changes, func(ch *entity.VaultItem) bool { return ch.ID == expiredID })
+	require.GreaterOrEqual(t, idx, 0)
+	assert.True(t, changes[idx].Deleted)
+	assert.True(t, changes[idx].Expired, "clients learn the item expired")
+
+	trash, err := stor.ListTrash(ctx, owner)
+	require.NoError(t, err)
+	assert.Len(t, trash, 1, "trashed item has not expired yet")
+
+	_, err = stor.ExpireItems(ctx, future.Add(time.Second))
+	require.NoError(t, err)
+
+	trash, err = stor.ListTrash(ctx, owner)
+	require.NoError(t, err)
+	assert.Empty(t, trash, "expired items are purged from the trash")
+
+	_, err = stor.GetItem(ctx, owner, liveID)
+	require.ErrorIs(t, err, storage.ErrEntityNotFound)
+}
+
+func TestMemoryStorage_ExpiringItems(t *testing.T) {
+	t.Parallel()
+
+	checkExpiringItems(t, storage.NewMemoryStorage())
+}
+
+func TestMemoryStorage_ExpiringItems_Usage(t *testing.T) {
+	t.Parallel()
+
+	stor := storage.NewMemoryStorage()
+	ctx := context.Background()
+	past := time.Now().UTC().Add(-time.Minute)
+
+	//nolint:exhaustruct // not all fields needed in test
+	_, err := stor.CreateItem(ctx, &entity.VaultItem{OwnerID: "user-1", Title: "otp", ExpiresAt: &past})
+	require.NoError(t, err)
+
+	usage, err := stor.GetUsage(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, &entity.Usage{Items: 0, Bytes: 0}, usage, "expired items do not count against the quota")
+}
+
+type quotaTestStorage interface {
+	storage.IStorage
+	SetQuota(quota entity.Quota)
+}
+
+// checkContentQuota проверяет учёт содержимого бинарной записи в квоте пользователя.
+func checkContentQuota(t *testing.T, stor quotaTestStorage) {
+	t.Helper()
+
+	stor.SetQuota(entity.Quota{MaxItems: 0, MaxBytes: 100, MaxItemBytes: 20})
+
+	ctx := context.Background()
+	owner := uuid.New().String()
+
+	//nolint:exhaustruct // not all fields needed in test
+	itemID, err := stor.CreateItem(ctx, &entity.VaultItem{OwnerID: owner, Type: entity.ItemBinary, Title: "photo"})
+	require.NoError(t, err)
+
+	content := &entity.Content{Hash: strings.Repeat("ab", 32), Size: 80}
+
+	_, err = stor.SetItemContent(ctx, owner, itemID, 0, content)
+	require.NoError(t, err, "content is not limited by the item size")
+
+	usage, err := stor.GetUsage(ctx, owner)
+	require.NoError(t, err)
+	assert.Equal(t, &entity.Usage{Items: 1, Bytes: 91}, usage)
+
+	var quotaErr *storage.QuotaError
+
+	_, err = stor.SetItemContent(ctx, owner, itemID, 0, &entity.Content{Hash: strings.Repeat("cd", 32), Size: 90})
+	require.ErrorIs(t, err, storage.ErrQuotaExceeded)
+	require.ErrorAs(t, err, &quotaErr)
+	assert.Equal(t, storage.QuotaBytes, quotaErr.Limit)
+
+	_, err = stor.SetItemContent(ctx, owner, itemID, 0, nil)
+	require.NoError(t, err)
+
+	usage, err = stor.GetUsage(ctx, owner)
+	require.NoError(t, err)
+	assert.Equal(t, &entity.Usage{Items: 1, Bytes: 11}, usage)
+}
+
+func TestMemoryStorage_ContentQuota(t *testing.T) {
+	t.Parallel()
+
+	checkContentQuota(t, storage.NewMemoryStorage())
+}
+
+func TestMemoryStorage_Quota(t *testing.T) {
+	t.Parallel()
+
+	stor := storage.NewMemoryStorage()
+
+	stor.SetQuota(entity.Quota{MaxItems: 2, MaxBytes: 20, MaxItemBytes: 12})
+
+	ctx := context.Background()
+
+	//nolint:exhaustruct // not all fields needed in test
+	first := &entity.VaultItem{OwnerID: "user-1", Title: "aaaa"}
+	//nolint:exhaustruct // not all fields needed in test
+	second := &entity.VaultItem{OwnerID: "user-1", Title: "bbbbbbbb"}
+
+	firstID, err := stor.CreateItem(ctx, first)
+	require.NoError(t, err)
+	secondID, err := stor.CreateItem(ctx, second)
+	require.NoError(t, err)
+
+	usage, err := stor.GetUsage(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, entity.Usage{Items: 2, Bytes: 12}, *usage)
+
+	var quotaErr *storage.QuotaError
+
+	//nolint:exhaustruct // not all fields needed in test
+	_, err = stor.CreateItem(ctx, &entity.VaultItem{OwnerID: "user-1", Title: "c"})
+	require.ErrorAs(t, err, &quotaErr)
+	assert.Equal(t, storage.QuotaItems, quotaErr.Limit)
+
+	//nolint:exhaustruct // not all fields needed in test
+	err = stor.UpdateItem(ctx, &entity.VaultItem{ID: firstID, OwnerID: "user-1", Title: "aaaaaaaaaaaaa"})
+	require.ErrorAs(t, err, &quotaErr)
+	assert.Equal(t, storage.QuotaItemBytes, quotaErr.Limit)
+
+	//nolint:exhaustruct // not all fields needed in test
+	err = stor.UpdateItem(ctx, &entity.VaultItem{ID: firstID, OwnerID: "user-1", Title: "aaaaaaaaaaaa"})
+	require.NoError(t, err)
+
+	//nolint:exhaustruct // not all fields needed in test
+	err = stor.UpdateItem(ctx, &entity.VaultItem{ID: secondID, OwnerID: "user-1", Title: "bbbbbbbbb"})
+	require.ErrorIs(t, err, storage.ErrQuotaExceeded)
+	require.ErrorAs(t, err, &quotaErr)
+	assert.Equal(t, storage.QuotaBytes, quotaErr.Limit)
+	assert.Equal(t, int64(20), quotaErr.Used)
+
+	require.NoError(t, stor.DeleteItem(ctx, "user-1", firstID))
+
+	usage, err = stor.GetUsage(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, entity.Usage{Items: 1, Bytes: 8}, *usage, "trash is not counted")
+
+	results, err := stor.ApplyChanges(ctx, "user-1", []*entity.Change{
+		//nolint:exhaustruct // not all fields needed in test
+		{Op: entity.ChangeUpsert, Item: &entity.VaultItem{OwnerID: "user-1", Data: "0123456789abcdef"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, entity.ChangeRejected, results[0].Status)
+}
+
+func TestMemoryStorage_Content(t *testing.T) {
+	t.Parallel()
+
+	stor := storage.NewMemoryStorage()
+
+	ctx := context.Background()
+
+	//nolint:exhaustruct // not all fields needed in test
+	item := &entity.VaultItem{OwnerID: "user-1", Type: entity.ItemBinary, Title: "photo"}
+
+	itemID, err := stor.CreateItem(ctx, item)
+	require.NoError(t, err)
+
+	content := &entity.Content{Hash: strings.Repeat("ab", 32), Size: 42}
+
+	updated, err := stor.SetItemContent(ctx, "user-1", itemID, 1, content)
+	require.NoError(t, err)
+	assert.Equal(t, content, updated.Content)
+	assert.Equal(t, int64(2), updated.Version)
+
+	_, err = stor.SetItemContent(ctx, "user-1", itemID, 1, nil)
+	require.ErrorIs(t, err, storage.ErrVersionConflict)
+
+	_, err = stor.SetItemContent(ctx, "user-1", "missing", 0, content)
+	require.ErrorIs(t, err, storage.ErrEntityNotFound)
+
+	//nolint:exhaustruct // not all fields needed in test
+	err = stor.UpdateItem(ctx, &entity.VaultItem{ID: itemID, OwnerID: "user-1", Type: entity.ItemBinary, Title: "renamed"})
+	require.NoError(t, err)
+
+	got, err := stor.GetItem(ctx, "user-1", itemID)
+	require.NoError(t, err)
+	assert.Equal(t, "renamed", got.Title)
+	assert.Equal(t, content, got.Content, "update without content keeps it")
+
+	cleared, err := stor.SetItemContent(ctx, "user-1", itemID, 0, nil)
+	require.NoError(t, err)
+	assert.Nil(t, cleared.Content)
+
+	hashes, err := stor.ContentHashes(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, hashes, content.Hash, "previous versions keep content referenced")
+
+	revs, err := stor.ListRevisions(ctx, "user-1", itemID)
+	require.NoError(t, err)
+	require.NotEmpty(t, revs)
+	assert.Equal(t, content, revs[0].Item.Content)
+}
+
+/*
+	===== MemoryStorage.DataKeys =====
+*/
+
+func TestMemoryStorage_DataKeys(t *testing.T) {
+	t.Parallel()
+
+	stor := storage.NewMemoryStorage()
+	ctx := context.Background()
+	ownerID := "user-1"
+
+	_, err := stor.GetDataKey(ctx, ownerID)
+	require.ErrorIs(t, err, storage.ErrEntityNotFound)
+
+	key := &entity.DataKey{
+		CreatedAt:   time.Now().UTC().Truncate(time.Microsecond),
+		OwnerID:     ownerID,
+		MasterKeyID: "old",
+		Wrapped:     []byte("wrapped-old"),
+	}
+	require.NoError(t, stor.AddDataKey(ctx, key))
+	require.ErrorIs(t, stor.AddDataKey(ctx, key), storage.ErrEntityAlreadyExists)
+
+	count, err := stor.RewrapDataKeys(ctx, func(key *entity.DataKey) (*entity.DataKey, error) {
+		if key.OwnerID != ownerID {
+			return nil, nil //nolint:nilnil // ключ другого владельца не меняется
+		}
+
+		//nolint:exhaustruct // остальные поля сохраняются хранилищем
+		return &entity.DataKey{MasterKeyID: "new", Wrapped: []byte("wrapped-new")}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	got, err := stor.GetDataKey(ctx, ownerID)
+	require.NoError(t, err)
+	assert.Equal(t, ownerID, got.OwnerID)
+	assert.Equal(t, "new", got.MasterKeyID)
+	assert.Equal(t, []byte("wrapped-new"), got.Wrapped)
+	assert.True(t, key.CreatedAt.Equal(got.CreatedAt))
+
+	_, err = stor.RewrapDataKeys(ctx, func(*entity.DataKey) (*entity.DataKey, error) {
+		return nil, assert.AnError
+	})
+	require.ErrorIs(t, err, assert.AnError)
+}
+
+/*
+	===== MemoryStorage.Shares =====
+*/
+
+// checkShareStorage проверяет открытые ключи и доступы к записям хранилища.
+//
+// ID пользователей и записей уникальны, поэтому хранилище может быть общим.
+func checkShareStorage(t *testing.T, stor storage.IShareStorage) {
+	t.Helper()
+
+	ctx := context.Background()
+	owner, recipient, other := uuid.New().String(), uuid.New().String(), uuid.New().String()
+
+	_, err := stor.GetPublicKey(ctx, recipient)
+	require.ErrorIs(t, err, storage.ErrEntityNotFound)
+
+	for _, keyData := range []string{"first", "second"} {
+		require.NoError(t, stor.SetPublicKey(ctx, &entity.PublicKey{
+			CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+			UserID:    recipient,
+			Algorithm: "x25519",
+			Key:       []byte(keyData),
+		}))
+	}
+
+	key, err := stor.GetPublicKey(ctx, recipient)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("second"), key.Key, "public key is replaced")
+
+	newShare := func(itemID, recipientID string, permission entity.SharePermission) *entity.Share {
+		return &entity.Share{
+			CreatedAt:   time.Now().UTC().Truncate(time.Microsecond),
+			OwnerID:     owner,
+			ItemID:      itemID,
+			RecipientID: recipientID,
+			Permission:  permission,
+			WrappedKey:  []byte(itemID + recipientID),
+		}
+	}
+
+	require.NoError(t, stor.PutShare(ctx, newShare("item-b", recipient, entity.ShareRead)))
+	require.NoError(t, stor.PutShare(ctx, newShare("item-a", recipient, entity.ShareRead)))
+	require.NoError(t, stor.PutShare(ctx, newShare("item-a", other, entity.ShareRead)))
+	require.NoError(t, stor.PutShare(ctx, newShare("item-a", recipient, entity.ShareWrite)))
+
+	share, err := stor.GetShare(ctx, owner, "item-a", recipient)
+	require.NoError(t, err)
+	assert.Equal(t, entity.ShareWrite, share.Permission, "share is replaced")
+
+	_, err = stor.GetShare(ctx, owner, "item-a", owner)
+	require.ErrorIs(t, err, storage.ErrEntityNotFound)
+
+	itemShares, err := stor.ListItemShares(ctx, owner, "item-a")
+	require.NoError(t, err)
+	assert.Len(t, itemShares, 2)
+
+	shared, err := stor.ListSharedWith(ctx, recipient)
+	require.NoError(t, err)
+	require.Len(t, shared, 2)
+	assert.Equal(t, "item-a", shared[0].ItemID)
+	assert.Equal(t, "item-b", shared[1].ItemID)
+
+	require.NoError(t, stor.DeleteShare(ctx, owner, "item-a", recipient))
+	require.ErrorIs(t, stor.DeleteShare(ctx, owner, "item-a", recipient), storage.ErrEntityNotFound)
+
+	shared, err = stor.ListSharedWith(ctx, recipient)
+	require.NoError(t, err)
+	require.Len(t, shared, 1)
+	assert.Equal(t, "item-b", shared[0].ItemID)
+
+	itemShares, err = stor.ListItemShares(ctx, owner, "item-a")
+	require.NoError(t, err)
+	require.Len(t, itemShares, 1)
+	assert.Equal(t, other, itemShares[0].RecipientID)
+}
+
+func TestMemoryStorage_Shares(t *testing.T) {
+	t.Parallel()
+
+	checkShareStorage(t, storage.NewMemoryStorage())
+}
+
+// purgeShareTestStorage - хранилище для проверки удаления доступов к удалённым записям.
+type purgeShareTestStorage interface {
+	storage.IStorage
+	storage.IShareStorage
+}
+
+// checkPurgedItemShares проверяет, что доступы к записи удаляются вместе с ней
+// при очистке корзины и удалении по сроку и остаются, пока запись в корзине.
+func checkPurgedItemShares(t *testing.T, stor purgeShareTestStorage) {
+	t.Helper()
+
+	ctx := context.Background()
+	owner, recipient := uuid.New().String(), uuid.New().String()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+
+	create := func(title string, expiresAt *time.Time) string {
+		//nolint:exhaustruct // not all fields needed in test
+		id, err := stor.CreateItem(ctx,
+			&entity.VaultItem{OwnerID: owner, Type: entity.ItemText, Title: title, ExpiresAt: expiresAt})
+		require.NoError(t, err)
+
+		require.NoError(t, stor.PutShare(ctx, &entity.Share{
+			CreatedAt:   now,
+			OwnerID:     owner,
+			ItemID:      id,
+			RecipientID: recipient,
+			Permission:  entity.ShareRead,
+			WrappedKey:  []byte(id),
+		}))
+
+		return id
+	}
+
+	keptID := create("kept", nil)
+	trashedID := create("trashed", nil)
+	create("expired", &past)
+	expiringID := create("expiring", &future)
+
+	require.NoError(t, stor.DeleteItem(ctx, owner, trashedID))
+	require.NoError(t, stor.DeleteItem(ctx, owner, expiringID))
+
+	shared, err := stor.ListSharedWith(ctx, recipient)
+	require.NoError(t, err)
+	assert.Len(t, shared, 4, "shares of trashed items are kept for restore")
+
+	_, err = stor.ExpireItems(ctx, now)
+	require.NoError(t, err)
+
+	shared, err = stor.ListSharedWith(ctx, recipient)
+	require.NoError(t, err)
+	assert.Len(t, shared, 3, "shares of expired items are deleted")
+
+	_, err = stor.ExpireItems(ctx, future.Add(time.Second))
+	require.NoError(t, err)
+
+	shared, err = stor.ListSharedWith(ctx, recipient)
+	require.NoError(t, err)
+	assert.Len(t, shared, 2, "shares of items expired in the trash are deleted")
+
+	_, err = stor.EmptyTrash(ctx, owner)
+	require.NoError(t, err)
+
+	shared, err = stor.ListSharedWith(ctx, recipient)
+	require.NoError(t, err)
+	require.Len(t, shared, 1, "shares of purged items are deleted")
+	assert.Equal(t, keptID, shared[0].ItemID)
+
+	itemShares, err := stor.ListItemShares(ctx, owner, trashedID)
+	require.NoError(t, err)
+	assert.Empty(t, itemShares)
+}
+
+func TestMemoryStorage_PurgedItemShares(t *testing.T) {
+	t.Parallel()
+
+	checkPurgedItemShares(t, storage.NewMemoryStorage())
+}
+
+/*
+	===== MemoryStorage.Orgs =====
+*/
+
+// checkOrgStorage проверяет организации, их участников и коллекции хранилища.
+//
+// ID организаций, пользователей и коллекций уникальны, поэтому хранилище может быть общим.
+func checkOrgStorage(t *testing.T, stor storage.IOrgStorage) {
+	t.Helper()
+
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	orgID, otherOrgID := uuid.New().String(), uuid.New().String()
+	owner, invited := uuid.New().String(), uuid.New().String()
+
+	newMember := func(orgID, userID string, role entity.OrgRole, status entity.MemberStatus) *entity.Member {
+		return &entity.Member{CreatedAt: now, OrgID: orgID, UserID: userID, Role: role, Status: status, InvitedBy: ""}
+	}
+
+	org := &entity.Organization{CreatedAt: now, ID: orgID, Name: "Team"}
+	require.NoError(t, stor.CreateOrg(ctx, org, newMember(orgID, owner, entity.OrgOwner, entity.MemberActive)))
+	require.ErrorIs(t,
+		stor.CreateOrg(ctx, org, newMember(orgID, owner, entity.OrgOwner, entity.MemberActive)),
+		storage.ErrEntityAlreadyExists)
+
+	other := &entity.Organization{CreatedAt: now, ID: otherOrgID, Name: "Other"}
+	require.NoError(t, stor.CreateOrg(ctx, other, newMember(otherOrgID, owner, entity.OrgViewer, entity.MemberActive)))
+
+	got, err := stor.GetOrg(ctx, orgID)
+	require.NoError(t, err)
+	assert.Equal(t, org, got)
+
+	_, err = stor.GetOrg(ctx, uuid.New().String())
+	require.ErrorIs(t, err, storage.ErrEntityNotFound)
+
+	require.NoError(t, stor.PutMember(ctx, newMember(orgID, invited, entity.OrgEditor, entity.MemberInvited)))
+	require.NoError(t, stor.PutMember(ctx, newMember(orgID, invited, entity.OrgEditor, entity.MemberActive)))
+
+	member, err := stor.GetMember(ctx, orgID, invited)
+	require.NoError(t, err)
+	assert.Equal(t, entity.MemberActive, member.Status, "member is replaced")
+
+	members, err := stor.ListMembers(ctx, orgID)
+	require.NoError(t, err)
+	assert.Len(t, members, 2)
+
+	memberships, err := stor.ListMemberships(ctx, owner)
+	require.NoError(t, err)
+	require.Len(t, memberships, 2)
+
+	require.NoError(t, stor.DeleteMember(ctx, orgID, invited))
+	require.ErrorIs(t, stor.DeleteMember(ctx, orgID, invited), storage.ErrEntityNotFound)
+
+	_, err = stor.GetMember(ctx, orgID, invited)
+	require.ErrorIs(t, err, storage.ErrEntityNotFound)
+
+	memberships, err = stor.ListMemberships(ctx, invited)
+	require.NoError(t, err)
+	assert.Empty(t, memberships)
+
+	coll := &entity.Collection{CreatedAt: now, ID: uuid.New().String(), OrgID: orgID, Name: "Infra"}
+	require.NoError(t, stor.CreateCollection(ctx, coll))
+	require.ErrorIs(t, stor.CreateCollection(ctx, coll), storage.ErrEntityAlreadyExists)
+
+	gotColl, err := stor.GetCollection(ctx, orgID, coll.ID)
+	require.NoError(t, err)
+	assert.Equal(t, coll, gotColl)
+
+	_, err = stor.GetCollection(ctx, otherOrgID, coll.ID)
+	require.ErrorIs(t, err, storage.ErrEntityNotFound, "collection of another organization")
+
+	colls, err := stor.ListCollections(ctx, orgID)
+	require.NoError(t, err)
+	assert.Len(t, colls, 1)
+
+	colls, err = stor.ListCollections(ctx, otherOrgID)
+	require.NoError(t, err)
+	assert.Empty(t, colls)
+
+	require.ErrorIs(t, stor.DeleteCollection(ctx, otherOrgID, coll.ID), storage.ErrEntityNotFound)
+	require.NoError(t, stor.DeleteCollection(ctx, orgID, coll.ID))
+
+	colls, err = stor.ListCollections(ctx, orgID)
+	require.NoError(t, err)
+	assert.Empty(t, colls)
+}
+
+func TestMemoryStorage_Orgs(t *testing.T) {
+	t.Parallel()
+
+	checkOrgStorage(t, storage.NewMemoryStorage())
+}
+
+/*
+	===== MemoryStorage.Folders =====
+*/
+
+// folderTestStorage - хранилище для проверки папок записей.
+type folderTestStorage interface {
+	storage.IStorage
+	storage.IFolderStorage
+}
+
+// checkFolderStorage проверяет папки записей хранилища и их место в ленте изменений владельца.
+//
+// Владелец создаётся для проверки, поэтому хранилище может быть общим.
+func checkFolderStorage(t *testing.T, stor folderTestStorage) {
+	t.Helper()
+
+	ctx := context.Background()
+	owner := uuid.New().String()
+
+	//nolint:exhaustruct // not all fields needed in test
+	work := &entity.Folder{OwnerID: owner, Name: "Work"}
+	require.NoError(t, stor.CreateFolder(ctx, work))
+	require.NotEmpty(t, work.ID)
+	assert.Equal(t, int64(1), work.Version)
+	assert.Equal(t, int64(1), work.Seq)
+
+	dup := *work
+	require.ErrorIs(t, stor.CreateFolder(ctx, &dup), storage.ErrEntityAlreadyExists)
+
+	//nolint:exhaustruct // not all fields needed in test
+	item := &entity.VaultItem{OwnerID: owner, Type: entity.ItemText, Title: "in folder", FolderID: work.ID}
+	_, err := stor.CreateItem(ctx, item)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), item.Seq, "items and folders share the change sequence")
+
+	//nolint:exhaustruct // not all fields needed in test
+	root := &entity.VaultItem{OwnerID: owner, Type: entity.ItemText, Title: "root"}
+	_, err = stor.CreateItem(ctx, root)
+	require.NoError(t, err)
+
+	//nolint:exhaustruct // not all fields needed in test
+	nested := &entity.Folder{OwnerID: owner, ParentID: work.ID, Name: "Nested"}
+	require.NoError(t, stor.CreateFolder(ctx, nested))
+
+	folders, err := stor.ListFolders(ctx, owner)
+	require.NoError(t, err)
+	require.Len(t, folders, 2)
+
+	inWork := work.ID
+	//nolint:exhaustruct // not all fields needed in test
+	items, err := stor.QueryItems(ctx, owner, &entity.ItemQuery{FolderID: &inWork})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, item.ID, items[0].ID)
+	assert.Equal(t, work.ID, items[0].FolderID)
+
+	inRoot := ""
+	//nolint:exhaustruct // not all fields needed in test
+	items, err = stor.QueryItems(ctx, owner, &entity.ItemQuery{FolderID: &inRoot})
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, root.ID, items[0].ID)
+
+	stale := *nested
+	nested.Name = "Renamed"
+	nested.ParentID = ""
+	require.NoError(t, stor.UpdateFolder(ctx, nested))
+	assert.Equal(t, int64(2), nested.Version)
+
+	stale.Name = "Stale"
+	require.ErrorIs(t, stor.UpdateFolder(ctx, &stale), storage.ErrVersionConflict)
+
+	got, err := stor.GetFolder(ctx, owner, nested.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Renamed", got.Name)
+	assert.Empty(t, got.ParentID)
+
+	require.ErrorIs(t, stor.DeleteFolder(ctx, owner, nested.ID, 1), storage.ErrVersionConflict)
+	require.NoError(t, stor.DeleteFolder(ctx, owner, nested.ID, 2))
+
+	_, err = stor.GetFolder(ctx, owner, nested.ID)
+	require.ErrorIs(t, err, storage.ErrEntityNotFound)
+	require.ErrorIs(t, stor.DeleteFolder(ctx, owner, nested.ID, 0), storage.ErrEntityNotFound)
+
+	//nolint:exhaustruct // not all fields needed in test
+	missing := &entity.Folder{ID: uuid.New().String(), OwnerID: owner, Name: "Missing"}
+	require.ErrorIs(t, stor.UpdateFolder(ctx, missing), storage.ErrEntityNotFound)
+
+	changes, err := stor.ListFolderChanges(ctx, owner, item.Seq, 0)
+	require.NoError(t, err)
+	require.Len(t, changes, 1, "nested folder changes collapse into its tombstone")
+	assert.Equal(t, nested.ID, changes[0].ID)
+	assert.True(t, changes[0].Deleted)
+
+	changes, err = stor.ListFolderChanges(ctx, owner, 0, 1)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, work.ID, changes[0].ID)
+
+	_, err = stor.CompactTombstones(ctx, time.Now().UTC().Add(time.Second))
+	require.NoError(t, err)
+
+	changes, err = stor.ListFolderChanges(ctx, owner, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, changes, 1, "folder tombstone is compacted")
+	assert.Equal(t, work.ID, changes[0].ID)
+
+	require.ErrorIs(t, stor.DeleteFolder(ctx, owner, work.ID, 0), storage.ErrFolderNotEmpty, "folder with items")
+
+	//nolint:exhaustruct // not all fields needed in test
+	child := &entity.Folder{OwnerID: owner, ParentID: work.ID, Name: "Child"}
+	require.NoError(t, stor.CreateFolder(ctx, child))
+
+	moved := *work
+	moved.Version = 0
+	moved.ParentID = child.ID
+	require.ErrorIs(t, stor.UpdateFolder(ctx, &moved), storage.ErrFolderCycle, "into its subfolder")
+
+	moved.ParentID = work.ID
+	require.ErrorIs(t, stor.UpdateFolder(ctx, &moved), storage.ErrFolderCycle, "into itself")
+
+	moved.ParentID = nested.ID
+	require.ErrorIs(t, stor.UpdateFolder(ctx, &moved), storage.ErrUnknownParent, "into a deleted folder")
+
+	//nolint:exhaustruct // not all fields needed in test
+	orphan := &entity.Folder{OwnerID: owner, ParentID: uuid.New().String(), Name: "Orphan"}
+	require.ErrorIs(t, stor.CreateFolder(ctx, orphan), storage.ErrUnknownParent)
+
+	require.NoError(t, stor.DeleteItem(ctx, owner, item.ID))
+	require.ErrorIs(t, stor.DeleteFolder(ctx, owner, work.ID, 0), storage.ErrFolderNotEmpty, "folder with subfolders")
+
+	require.NoError(t, stor.DeleteFolder(ctx, owner, child.ID, 0))
+	require.NoError(t, stor.DeleteFolder(ctx, owner, work.ID, 0), "deleted items and subfolders do not count")
+
+	folders, err = stor.ListFolders(ctx, uuid.New().String())
+	require.NoError(t, err)
+	assert.Empty(t, folders, "folders of another owner")
+}
+
+func TestMemoryStorage_Folders(t *testing.T) {
+	t.Parallel()
+
+	checkFolderStorage(t, storage.NewMemoryStorage())
+}
+
+/*
+	===== MemoryStorage.Snapshot / Restore =====
+*/
+
+// backupTestStorage - хранилище для проверки резервного копирования.
+type backupTestStorage interface {
+	storage.IUserStorage
+	storage.IStorage
+	storage.IKeyStorage
+	storage.IBackupStorage
+	storage.IShareStorage
+	storage.IOrgStorage
+	storage.IFolderStorage
+}
+
+// fillBackupStorage наполняет хранилище данными всех видов и возвращает ID пользователя.
+func fillBackupStorage(t *testing.T, stor backupTestStorage) string {
+	t.Helper()
+
+	ctx := context.Background()
+	user := entity.NewUser("Backup@Example.com", "hash")
+
+	_, err := stor.AddNewUser(ctx, user)
+	require.NoError(t, err)
+	_, err = stor.AddNewToken(ctx, user.ID, &entity.Token{})
+	require.NoError(t, err)
+
+	require.NoError(t, stor.AddDataKey(ctx, &entity.DataKey{
+		CreatedAt:   time.Now().UTC().Truncate(time.Microsecond),
+		OwnerID:     user.ID,
+		MasterKeyID: "master",
+		Wrapped:     []byte("wrapped"),
+	}))
+
+	require.NoError(t, stor.SetPublicKey(ctx, &entity.PublicKey{
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+		UserID:    user.ID,
+		Algorithm: "x25519",
+		Key:       []byte("public"),
+	}))
+
+	//nolint:exhaustruct // not all fields needed in test
+	require.NoError(t, stor.CreateFolder(ctx, &entity.Folder{ID: "personal", OwnerID: user.ID, Name: "Personal"}))
+
+	//nolint:exhaustruct // not all fields needed in test
+	kept := &entity.VaultItem{
+		OwnerID:  user.ID,
+		Type:     entity.ItemLogin,
+		Title:    "kept",
+		Meta:     map[string]string{"url": "https://example.com"},
+		Content:  &entity.Content{Hash: strings.Repeat("ab", 32), Size: 10},
+		FolderID: "personal",
+	}
+	_, err = stor.CreateItem(ctx, kept)
+	require.NoError(t, err)
+
+	kept.Title = "kept v2"
+	require.NoError(t, stor.UpdateItem(ctx, kept))
+
+	require.NoError(t, stor.PutShare(ctx, &entity.Share{
+		CreatedAt:   time.Now().UTC().Truncate(time.Microsecond),
+		OwnerID:     user.ID,
+		ItemID:      kept.ID,
+		RecipientID: "recipient",
+		Permission:  entity.ShareRead,
+		WrappedKey:  []byte("item key"),
+	}))
+
+	//nolint:exhaustruct // not all fields needed in test
+	deleted := &entity.VaultItem{OwnerID: user.ID, Type: entity.ItemText, Title: "deleted"}
+	deletedID, err := stor.CreateItem(ctx, deleted)
+	require.NoError(t, err)
+	require.NoError(t, stor.DeleteItem(ctx, user.ID, deletedID))
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	require.NoError(t, stor.CreateOrg(ctx,
+		&entity.Organization{CreatedAt: now, ID: "org", Name: "Team"},
+		&entity.Member{
+			CreatedAt: now,
+			OrgID:     "org",
+			UserID:    user.ID,
+			Role:      entity.OrgOwner,
+			Status:    entity.MemberActive,
+			InvitedBy: "",
+		},
+	))
+	require.NoError(t, stor.CreateCollection(ctx,
+		&entity.Collection{CreatedAt: now, ID: "infra", OrgID: "org", Name: "Infra"}))
+
+	return user.ID
+}
+
+// checkRestoredStorage проверяет, что восстановленное хранилище продолжает работу с данными снимка.
+func checkRestoredStorage(t *testing.T, stor backupTestStorage, userID string) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	user, err := stor.FindUserByEmail(ctx, "backup@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, userID, user.ID)
+	assert.True(t, stor.IsTokenByUserID(ctx, userID))
+
+	_, err = stor.GetPublicKey(ctx, userID)
+	require.NoError(t, err)
+
+	shared, err := stor.ListSharedWith(ctx, "recipient")
+	require.NoError(t, err)
+	require.Len(t, shared, 1)
+	assert.Equal(t, userID, shared[0].OwnerID)
+
+	memberships, err := stor.ListMemberships(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, memberships, 1)
+	assert.Equal(t, entity.OrgOwner, memberships[0].Role)
+
+	_, err = stor.GetCollection(ctx, "org", "infra")
+	require.NoError(t, err)
+
+	_, err = stor.GetFolder(ctx, userID, "personal")
+	require.NoError(t, err)
+
+	inFolder := "personal"
+	//nolint:exhaustruct // not all fields needed in test
+	items, err := stor.QueryItems(ctx, userID, &entity.ItemQuery{FolderID: &inFolder})
+	require.NoError(t, err)
+	assert.Len(t, items, 1)
+
+	usage, err := stor.GetUsage(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), usage.Items)
+
+	//nolint:exhaustruct // not all fields needed in test
+	next := &entity.VaultItem{OwnerID: userID, Title: "next"}
+	_, err = stor.CreateItem(ctx, next)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), next.Seq, "change sequence continues after the snapshot")
+
+	trash, err := stor.ListTrash(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, trash, 1)
+
+	restored, err := stor.RestoreTrash(ctx, userID, trash[0].Item.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), restored.Version, "restore is a new version after the tombstone")
+}
+
+func TestMemoryStorage_SnapshotRestore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	source := storage.NewMemoryStorage()
+	userID := fillBackupStorage(t, source)
+
+	snap, err := source.Snapshot(ctx)
+	require.NoError(t, err)
+	require.Len(t, snap.Users, 1)
+	assert.Equal(t, []string{userID}, snap.Tokens)
+	require.Len(t, snap.Keys, 1)
+	require.Len(t, snap.PublicKeys, 1)
+	require.Len(t, snap.Shares, 1)
+	require.Len(t, snap.Orgs, 1)
+	require.Len(t, snap.Members, 1)
+	require.Len(t, snap.Collections, 1)
+	require.Len(t, snap.Vaults, 1)
+
+	vault := snap.Vaults[0]
+	assert.Equal(t, int64(5), vault.Seq)
+	assert.Len(t, vault.Folders, 1)
+	assert.Len(t, vault.Items, 1)
+	assert.Len(t, vault.Tombstones, 1)
+	assert.Len(t, vault.Revisions, 2)
+	assert.Len(t, vault.Trash, 1)
+
+	target := storage.NewMemoryStorage()
+	require.NoError(t, target.Restore(ctx, snap))
+
+	restoredSnap, err := target.Snapshot(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, snap, restoredSnap)
+
+	require.ErrorIs(t, target.Restore(ctx, snap), storage.ErrStorageNotEmpty)
+
+	checkRestoredStorage(t, target, userID)
+}
+
+func TestMemoryStorage_RestoreNotEmpty(t *testing.T) {
+	t.Parallel()
+
+	storagetest.RunRestore(t, func(*testing.T) storage.IBackupStorage {
+		return storage.NewMemoryStorage()
+	})
+}
+
+/*
+	===== MemoryStorage conformance =====
+*/
+
+func TestMemoryStorage_Conformance(t *testing.T) {
+	t.Parallel()
+
+	storagetest.Run(t, func(*testing.T) storagetest.Storage {
+		return storage.NewMemoryStorage()
+	})
+}
@@ -0,0 +1,2639 @@
+// Package storage предоставляет функциональность хранилища.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+)
+
+// pgCodeUniqueViolation - код ошибки PostgreSQL при нарушении уникальности.
+const pgCodeUniqueViolation = "23505"
+
+// pgRevisionColumns - список колонок прежней версии записи для выборки.
+const pgRevisionColumns = `id, owner_id, type, title, description, meta, username, data,
+	content_hash, content_size, folder_id, tags, attachments, expires_at, version, updated_at, created_at`
+
+// pgTrashColumns - список колонок записи в корзине для выборки.
+const pgTrashColumns = `id, owner_id, type, title, description, meta, username, data,
+	content_hash, content_size, folder_id, tags, attachments, expires_at, version, updated_at, deleted_at`
+
+// pgItemColumns - список колонок записи для выборки.
+const pgItemColumns = `id, owner_id, type, title, description, meta, username, data,
+	content_hash, content_size, folder_id, tags, attachments, expires_at, version, updated_at, seq`
+
+// pgShareColumns - список колонок доступа к записи для выборки.
+const pgShareColumns = `owner_id, item_id, recipient_id, permission, wrapped_key, created_at`
+
+// pgMemberColumns - список колонок участия в организации для выборки.
+const pgMemberColumns = `org_id, user_id, role, status, invited_by, created_at`
+
+// pgCollectionColumns - список колонок коллекции для выборки.
+const pgCollectionColumns = `id, org_id, name, created_at`
+
+// pgFolderColumns - список колонок папки для выборки.
+const pgFolderColumns = `owner_id, id, parent_id, name, version, updated_at, seq, deleted`
+
+// pgUsageQuery - запрос объёма данных пользователя (записи с истёкшим сроком не учитываются).
+const pgUsageQuery = `SELECT count(*), coalesce(sum(size), 0) FROM vault_items
+	WHERE owner_id = $1 AND (expires_at IS NULL OR expires_at > $2)`
+
+// PostgresStorage описывает хранилище на основе PostgreSQL.
+//
+// Схема базы данных должна быть подготовлена миграциями (пакет migration).
+type PostgresStorage struct {
+	pool *pgxpool.Pool
+
+	revisionLimit int
+	quota         entity.Quota
+}
+
+// NewPostgresStorage создаёт и инициализирует новый экзепляр *PostgresStorage.
+//
+// Параметры:
+//   - ctx: контекст подключения;
+//   - dsn: строка подключения к базе данных.
+func NewPostgresStorage(ctx context.Context, dsn string) (*PostgresStorage, error) {
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("create pool: %w", err)
+	}
+
+	if pingErr := pool.Ping(ctx); pingErr != nil {
+		pool.Close()
+
+		return nil, fmt.Errorf("ping database: %w", pingErr)
+	}
+
+	stor := &PostgresStorage{
+		pool:          pool,
+		revisionLimit: DefaultRevisionLimit,
+		quota:         entity.Quota{MaxItems: 0, MaxBytes: 0, MaxItemBytes: 0},
+	}
+
+	return stor, nil
+}
+
+// SetRevisionLimit задаёт количество хранимых прежних версий записи (0 - не хранить).
+//
+// Должен вызываться до начала работы с хранилищем.
+func (p *PostgresStorage) SetRevisionLimit(limit int) {
+	p.revisionLimit = max(limit, 0)
+}
+
+// SetQuota задаёт ограничения на данные каждого пользователя.
+//
+// Должен вызываться до начала работы с хранилищем.
+func (p *PostgresStorage) SetQuota(quota entity.Quota) {
+	p.quota = quota
+}
+
+// GetUsage получает объём данных пользователя.
+func (p *PostgresStorage) GetUsage(ctx context.Context, ownerID string) (*entity.Usage, error) {
+	usage := &entity.Usage{Items: 0, Bytes: 0}
+
+	err := p.pool.QueryRow(ctx, pgUsageQuery, ownerID, nowPostgres()).Scan(&usage.Items, &usage.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("usage: %w", err)
+	}
+
+	return usage, nil
+}
+
+// Close закрывает все соединения с базой данных.
+func (p *PostgresStorage) Close() error {
+	p.pool.Close()
+
+	return nil
+}
+
+// AddNewUser создаёт нового пользователя.
+func (p *PostgresStorage) AddNewUser(ctx context.Context, user *entity.User) (string, error) {
+	_, err := p.pool.Exec(ctx,
+		`INSERT INTO users (id, email, password_hash) VALUES ($1, $2, $3)`,
+		user.ID, user.Email, user.PasswordHash,
+	)
+	if err != nil {
+		return "", fmt.Errorf("user: %w", mapPostgresError(err))
+	}
+
+	return user.ID, nil
+}
+
+// FindUserByEmail производит поиск пользователя по Email.
+func (p *PostgresStorage) FindUserByEmail(ctx context.Context, email string) (*entity.User, error) {
+	user := &entity.User{
+		ID:           "",
+		Email:        "",
+		PasswordHash: "",
+	}
+
+	err := p.pool.QueryRow(ctx,
+		`SELECT id, email, password_hash FROM users WHERE lower(email) = lower($1)`,
+		email,
+	).Scan(&user.ID, &user.Email, &user.PasswordHash)
+	if err != nil {
+		return nil, fmt.Errorf("user: %w", mapPostgresError(err))
+	}
+
+	return user, nil
+}
+
+// AddNewToken регистрирует новый токен.
+func (p *PostgresStorage) AddNewToken(
+	ctx context.Context,
+	userID string,
+	_ *entity.Token,
+) (string, error) {
+	_, err := p.pool.Exec(ctx, `INSERT INTO tokens (user_id) VALUES ($1)`, userID)
+	if err != nil {
+		return "", fmt.Errorf("token: %w", mapPostgresError(err))
+	}
+
+	return "", nil
+}
+
+// IsTokenByUserID производит поиск токена для пользователя по UserID.
+func (p *PostgresStorage) IsTokenByUserID(ctx context.Context, userID string) bool {
+	var exists bool
+
+	err := p.pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM tokens WHERE user_id = $1)`,
+		userID,
+	).Scan(&exists)
+	if err != nil {
+		return false
+	}
+
+	return exists
+}
+
+// DeleteToken удаляет токен.
+func (p *PostgresStorage) DeleteToken(ctx context.Context, userID string) error {
+	if _, err := p.pool.Exec(ctx, `DELETE FROM tokens WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("token: %w", err)
+	}
+
+	return nil
+}
+
+// GetDataKey получает ключ данных владельца.
+func (p *PostgresStorage) GetDataKey(ctx context.Context, ownerID string) (*entity.DataKey, error) {
+	key := &entity.DataKey{
+		CreatedAt:   time.Time{},
+		OwnerID:     "",
+		MasterKeyID: "",
+		Wrapped:     nil,
+	}
+
+	err := p.pool.QueryRow(ctx,
+		`SELECT owner_id, master_key_id, wrapped, created_at FROM data_keys WHERE owner_id = $1`,
+		ownerID,
+	).Scan(&key.OwnerID, &key.MasterKeyID, &key.Wrapped, &key.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("data key: %w", mapPostgresError(err))
+	}
+
+	return key, nil
+}
+
+// AddDataKey сохраняет ключ данных владельца.
+func (p *PostgresStorage) AddDataKey(ctx context.Context, key *entity.DataKey) error {
+	_, err := p.pool.Exec(ctx,
+		`INSERT INTO data_keys (owner_id, master_key_id, wrapped, created_at) VALUES ($1, $2, $3, $4)`,
+		key.OwnerID, key.MasterKeyID, key.Wrapped, key.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("data key: %w", mapPostgresError(err))
+	}
+
+	return nil
+}
+
+// RewrapDataKeys заменяет ключи данных результатом rewrap в одной транзакции.
+//
+// Ключи блокируются на время транзакции, чтобы параллельная смена
+// мастер-ключа не потеряла изменения.
+func (p *PostgresStorage) RewrapDataKeys(
+	ctx context.Context,
+	rewrap func(key *entity.DataKey) (*entity.DataKey, error),
+) (int, error) {
+	count := 0
+
+	err := pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx,
+			`SELECT owner_id, master_key_id, wrapped, created_at FROM data_keys ORDER BY owner_id FOR UPDATE`)
+		if err != nil {
+			return err
+		}
+
+		keys, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*entity.DataKey, error) {
+			key := &entity.DataKey{CreatedAt: time.Time{}, OwnerID: "", MasterKeyID: "", Wrapped: nil}
+			err := row.Scan(&key.OwnerID, &key.MasterKeyID, &key.Wrapped, &key.CreatedAt)
+
+			return key, err
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			next, err := rewrap(key)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key.OwnerID, err)
+			}
+
+			if next == nil {
+				continue
+			}
+
+			_, err = tx.Exec(ctx,
+				`UPDATE data_keys SET master_key_id = $2, wrapped = $3 WHERE owner_id = $1`,
+				key.OwnerID, next.MasterKeyID, next.Wrapped,
+			)
+			if err != nil {
+				return err
+			}
+
+			count++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("data key: %w", err)
+	}
+
+	return count, nil
+}
+
+// SetPublicKey сохраняет открытый ключ пользователя.
+func (p *PostgresStorage) SetPublicKey(ctx context.Context, key *entity.PublicKey) error {
+	_, err := p.pool.Exec(ctx,
+		`INSERT INTO public_keys (user_id, algorithm, key, created_at) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (user_id) DO UPDATE
+			SET algorithm = EXCLUDED.algorithm, key = EXCLUDED.key, created_at = EXCLUDED.created_at`,
+		key.UserID, key.Algorithm, key.Key, key.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("public key: %w", mapPostgresError(err))
+	}
+
+	return nil
+}
+
+// GetPublicKey получает открытый ключ пользователя.
+func (p *PostgresStorage) GetPublicKey(ctx context.Context, userID string) (*entity.PublicKey, error) {
+	key := &entity.PublicKey{CreatedAt: time.Time{}, UserID: "", Algorithm: "", Key: nil}
+
+	err := p.pool.QueryRow(ctx,
+		`SELECT user_id, algorithm, key, created_at FROM public_keys WHERE user_id = $1`,
+		userID,
+	).Scan(&key.UserID, &key.Algorithm, &key.Key, &key.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("public key: %w", mapPostgresError(err))
+	}
+
+	key.CreatedAt = key.CreatedAt.UTC()
+
+	return key, nil
+}
+
+// PutShare создаёт или заменяет доступ получателя к записи.
+func (p *PostgresStorage) PutShare(ctx context.Context, share *entity.Share) error {
+	_, err := p.pool.Exec(ctx,
+		`INSERT INTO item_shares (owner_id, item_id, recipient_id, permission, wrapped_key, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (owner_id, item_id, recipient_id) DO UPDATE
+			SET permission = EXCLUDED.permission, wrapped_key = EXCLUDED.wrapped_key, created_at = EXCLUDED.created_at`,
+		share.OwnerID, share.ItemID, share.RecipientID, string(share.Permission), share.WrappedKey, share.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("share: %w", mapPostgresError(err))
+	}
+
+	return nil
+}
+
+// GetShare получает доступ получателя к записи владельца.
+func (p *PostgresStorage) GetShare(
+	ctx context.Context,
+	ownerID, itemID, recipientID string,
+) (*entity.Share, error) {
+	rows, err := p.pool.Query(ctx,
+		`SELECT `+pgShareColumns+` FROM item_shares WHERE owner_id = $1 AND item_id = $2 AND recipient_id = $3`,
+		ownerID, itemID, recipientID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("share: %w", err)
+	}
+
+	share, err := pgx.CollectExactlyOneRow(rows, scanPostgresShare)
+	if err != nil {
+		return nil, fmt.Errorf("share: %w", mapPostgresError(err))
+	}
+
+	return share, nil
+}
+
+// ListItemShares получает доступы к записи владельца.
+func (p *PostgresStorage) ListItemShares(ctx context.Context, ownerID, itemID string) ([]*entity.Share, error) {
+	rows, err := p.pool.Query(ctx,
+		`SELECT `+pgShareColumns+` FROM item_shares WHERE owner_id = $1 AND item_id = $2 ORDER BY recipient_id`,
+		ownerID, itemID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("shares: %w", err)
+	}
+
+	shares, err := pgx.CollectRows(rows, scanPostgresShare)
+	if err != nil {
+		return nil, fmt.Errorf("shares: %w", err)
+	}
+
+	return shares, nil
+}
+
+// ListSharedWith получает доступы получателя к чужим записям.
+func (p *PostgresStorage) ListSharedWith(ctx context.Context, recipientID string) ([]*entity.Share, error) {
+	rows, err := p.pool.Query(ctx,
+		`SELECT `+pgShareColumns+` FROM item_shares WHERE recipient_id = $1 ORDER BY owner_id, item_id`,
+		recipientID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("shares: %w", err)
+	}
+
+	shares, err := pgx.CollectRows(rows, scanPostgresShare)
+	if err != nil {
+		return nil, fmt.Errorf("shares: %w", err)
+	}
+
+	return shares, nil
+}
+
+// DeleteShare удаляет доступ получателя к записи владельца.
+func (p *PostgresStorage) DeleteShare(ctx context.Context, ownerID, itemID, recipientID string) error {
+	tag, err := p.pool.Exec(ctx,
+		`DELETE FROM item_shares WHERE owner_id = $1 AND item_id = $2 AND recipient_id = $3`,
+		ownerID, itemID, recipientID,
+	)
+	if err != nil {
+		return fmt.Errorf("share: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("share: %w", ErrEntityNotFound)
+	}
+
+	return nil
+}
+
+// CreateOrg создаёт организацию вместе с участием её создателя.
+func (p *PostgresStorage) CreateOrg(ctx context.Context, org *entity.Organization, owner *entity.Member) error {
+	err := pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx,
+			`INSERT INTO organizations (id, name, created_at) VALUES ($1, $2, $3)`,
+			org.ID, org.Name, org.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx,
+			`INSERT INTO org_members (`+pgMemberColumns+`) VALUES ($1, $2, $3, $4, $5, $6)`,
+			owner.OrgID, owner.UserID, string(owner.Role), string(owner.Status), owner.InvitedBy, owner.CreatedAt,
+		)
+
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("org: %w", mapPostgresError(err))
+	}
+
+	return nil
+}
+
+// GetOrg получает организацию.
+func (p *PostgresStorage) GetOrg(ctx context.Context, orgID string) (*entity.Organization, error) {
+	org := &entity.Organization{CreatedAt: time.Time{}, ID: "", Name: ""}
+
+	err := p.pool.QueryRow(ctx,
+		`SELECT id, name, created_at FROM organizations WHERE id = $1`,
+		orgID,
+	).Scan(&org.ID, &org.Name, &org.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("org: %w", mapPostgresError(err))
+	}
+
+	org.CreatedAt = org.CreatedAt.UTC()
+
+	return org, nil
+}
+
+// PutMember создаёт или заменяет участие пользователя в организации.
+func (p *PostgresStorage) PutMember(ctx context.Context, member *entity.Member) error {
+	_, err := p.pool.Exec(ctx,
+		`INSERT INTO org_members (`+pgMemberColumns+`) VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (org_id, user_id) DO UPDATE
+			SET role = EXCLUDED.role, status = EXCLUDED.status,
+				invited_by = EXCLUDED.invited_by, created_at = EXCLUDED.created_at`,
+		member.OrgID, member.UserID, string(member.Role), string(member.Status), member.InvitedBy, member.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("member: %w", mapPostgresError(err))
+	}
+
+	return nil
+}
+
+// GetMember получает участие пользователя в организации.
+func (p *PostgresStorage) GetMember(ctx context.Context, orgID, userID string) (*entity.Member, error) {
+	rows, err := p.pool.Query(ctx,
+		`SELECT `+pgMemberColumns+` FROM org_members WHERE org_id = $1 AND user_id = $2`,
+		orgID, userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("member: %w", err)
+	}
+
+	member, err := pgx.CollectExactlyOneRow(rows, scanPostgresMember)
+	if err != nil {
+		return nil, fmt.Errorf("member: %w", mapPostgresError(err))
+	}
+
+	return member, nil
+}
+
+// ListMembers получает участников и приглашённых организации.
+func (p *PostgresStorage) ListMembers(ctx context.Context, orgID string) ([]*entity.Member, error) {
+	rows, err := p.pool.Query(ctx,
+		`SELECT `+pgMemberColumns+` FROM org_members WHERE org_id = $1 ORDER BY user_id`,
+		orgID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("members: %w", err)
+	}
+
+	members, err := pgx.CollectRows(rows, scanPostgresMember)
+	if err != nil {
+		return nil, fmt.Errorf("members: %w", err)
+	}
+
+	return members, nil
+}
+
+// ListMemberships получает участие пользователя в организациях.
+func (p *PostgresStorage) ListMemberships(ctx context.Context, userID string) ([]*entity.Member, error) {
+	rows, err := p.pool.Query(ctx,
+		`SELECT `+pgMemberColumns+` FROM org_members WHERE user_id = $1 ORDER BY org_id`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("members: %w", err)
+	}
+
+	members, err := pgx.CollectRows(rows, scanPostgresMember)
+	if err != nil {
+		return nil, fmt.Errorf("members: %w", err)
+	}
+
+	return members, nil
+}
+
+// DeleteMember удаляет участие пользователя в организации.
+func (p *PostgresStorage) DeleteMember(ctx context.Context, orgID, userID string) error {
+	tag, err := p.pool.Exec(ctx,
+		`DELETE FROM org_members WHERE org_id = $1 AND user_id = $2`,
+		orgID, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("member: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("member: %w", ErrEntityNotFound)
+	}
+
+	return nil
+}
+
+// CreateCollection создаёт коллекцию организации.
+func (p *PostgresStorage) CreateCollection(ctx context.Context, coll *entity.Collection) error {
+	_, err := p.pool.Exec(ctx,
+		`INSERT INTO org_collections (`+pgCollectionColumns+`) VALUES ($1, $2, $3, $4)`,
+		coll.ID, coll.OrgID, coll.Name, coll.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("collection: %w", mapPostgresError(err))
+	}
+
+	return nil
+}
+
+// GetCollection получает коллекцию организации.
+func (p *PostgresStorage) GetCollection(
+	ctx context.Context,
+	orgID, collectionID string,
+) (*entity.Collection, error) {
+	rows, err := p.pool.Query(ctx,
+		`SELECT `+pgCollectionColumns+` FROM org_collections WHERE org_id = $1 AND id = $2`,
+		orgID, collectionID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("collection: %w", err)
+	}
+
+	coll, err := pgx.CollectExactlyOneRow(rows, scanPostgresCollection)
+	if err != nil {
+		return nil, fmt.Errorf("collection: %w", mapPostgresError(err))
+	}
+
+	return coll, nil
+}
+
+// ListCollections получает коллекции организации.
+func (p *PostgresStorage) ListCollections(ctx context.Context, orgID string) ([]*entity.Collection, error) {
+	rows, err := p.pool.Query(ctx,
+		`SELECT `+pgCollectionColumns+` FROM org_collections WHERE org_id = $1 ORDER BY id`,
+		orgID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("collections: %w", err)
+	}
+
+	colls, err := pgx.CollectRows(rows, scanPostgresCollection)
+	if err != nil {
+		return nil, fmt.Errorf("collections: %w", err)
+	}
+
+	return colls, nil
+}
+
+// DeleteCollection удаляет коллекцию организации.
+func (p *PostgresStorage) DeleteCollection(ctx context.Context, orgID, collectionID string) error {
+	tag, err := p.pool.Exec(ctx,
+		`DELETE FROM org_collections WHERE org_id = $1 AND id = $2`,
+		orgID, collectionID,
+	)
+	if err != nil {
+		return fmt.Errorf("collection: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("collection: %w", ErrEntityNotFound)
+	}
+
+	return nil
+}
+
+// CreateFolder создаёт папку записей.
+func (p *PostgresStorage) CreateFolder(ctx context.Context, folder *entity.Folder) error {
+	if folder.ID == "" {
+		folder.ID = uuid.New().String()
+	}
+
+	cp := *folder
+	cp.Version = 1
+	cp.UpdatedAt = nowPostgres()
+	cp.Deleted = false
+
+	err := pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		if err := checkPostgresFolderParent(ctx, tx, cp.OwnerID, cp.ID, cp.ParentID); err != nil {
+			return err
+		}
+
+		return putPostgresFolder(ctx, tx, &cp, false)
+	})
+	if err != nil {
+		return fmt.Errorf("folder: %w", err)
+	}
+
+	*folder = cp
+
+	return nil
+}
+
+// UpdateFolder переименовывает или перемещает папку записей.
+func (p *PostgresStorage) UpdateFolder(ctx context.Context, folder *entity.Folder) error {
+	cp := *folder
+
+	err := pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		current, err := lockPostgresFolder(ctx, tx, folder.OwnerID, folder.ID, folder.Version)
+		if err != nil {
+			return err
+		}
+
+		if err := checkPostgresFolderParent(ctx, tx, cp.OwnerID, cp.ID, cp.ParentID); err != nil {
+			return err
+		}
+
+		cp.Version = current.Version + 1
+		cp.UpdatedAt = nowPostgres()
+		cp.Deleted = false
+
+		return putPostgresFolder(ctx, tx, &cp, true)
+	})
+	if err != nil {
+		return fmt.Errorf("folder: %w", err)
+	}
+
+	*folder = cp
+
+	return nil
+}
+
+// GetFolder получает папку записей.
+func (p *PostgresStorage) GetFolder(ctx context.Context, ownerID, folderID string) (*entity.Folder, error) {
+	rows, err := p.pool.Query(ctx,
+		`SELECT `+pgFolderColumns+` FROM vault_folders WHERE owner_id = $1 AND id = $2 AND NOT deleted`,
+		ownerID, folderID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("folder: %w", err)
+	}
+
+	folder, err := pgx.CollectExactlyOneRow(rows, scanPostgresFolder)
+	if err != nil {
+		return nil, fmt.Errorf("folder: %w", mapPostgresError(err))
+	}
+
+	return folder, nil
+}
+
+// ListFolders получает папки записей владельца.
+func (p *PostgresStorage) ListFolders(ctx context.Context, ownerID string) ([]*entity.Folder, error) {
+	rows, err := p.pool.Query(ctx,
+		`SELECT `+pgFolderColumns+` FROM vault_folders WHERE owner_id = $1 AND NOT deleted ORDER BY id`,
+		ownerID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("folders: %w", err)
+	}
+
+	folders, err := pgx.CollectRows(rows, scanPostgresFolder)
+	if err != nil {
+		return nil, fmt.Errorf("folders: %w", err)
+	}
+
+	return folders, nil
+}
+
+// DeleteFolder удаляет папку записей, оставляя отметку об удалении.
+func (p *PostgresStorage) DeleteFolder(ctx context.Context, ownerID, folderID string, version int64) error {
+	err := pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		current, err := lockPostgresFolder(ctx, tx, ownerID, folderID, version)
+		if err != nil {
+			return err
+		}
+
+		if err := checkPostgresFolderEmpty(ctx, tx, ownerID, folderID); err != nil {
+			return err
+		}
+
+		return putPostgresFolder(ctx, tx, deletedFolder(current, 0, nowPostgres()), true)
+	})
+	if err != nil {
+		return fmt.Errorf("folder: %w", err)
+	}
+
+	return nil
+}
+
+// ListFolderChanges получает изменения папок владельца после указанного номера.
+func (p *PostgresStorage) ListFolderChanges(
+	ctx context.Context,
+	ownerID string,
+	afterSeq int64,
+	limit int,
+) ([]*entity.Folder, error) {
+	rows, err := p.pool.Query(ctx,
+		`SELECT `+pgFolderColumns+` FROM vault_folders
+		WHERE owner_id = $1 AND seq > $2
+		ORDER BY seq
+		LIMIT NULLIF($3, 0)`,
+		ownerID, afterSeq, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("folder changes: %w", err)
+	}
+
+	folders, err := pgx.CollectRows(rows, scanPostgresFolder)
+	if err != nil {
+		return nil, fmt.Errorf("folder changes: %w", err)
+	}
+
+	return folders, nil
+}
+
+// Snapshot получает снимок всех данных хранилища в одной транзакции
+// с уровнем изоляции REPEATABLE READ.
+func (p *PostgresStorage) Snapshot(ctx context.Context) (*entity.Snapshot, error) {
+	builder := newSnapshotBuilder()
+
+	//nolint:exhaustruct // остальные опции по умолчанию
+	opts := pgx.TxOptions{
+		IsoLevel:   pgx.RepeatableRead,
+		AccessMode: pgx.ReadOnly,
+	}
+
+	err := pgx.BeginTxFunc(ctx, p.pool, opts, func(tx pgx.Tx) error {
+		return postgresSnapshot(ctx, tx, builder)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("snapshot: %w", err)
+	}
+
+	return builder.build(), nil
+}
+
+// Restore загружает снимок в пустое хранилище в одной транзакции.
+func (p *PostgresStorage) Restore(ctx context.Context, snap *entity.Snapshot) error {
+	err := pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		var notEmpty bool
+
+		err := tx.QueryRow(ctx,
+			`SELECT EXISTS (SELECT 1 FROM users) OR EXISTS (SELECT 1 FROM tokens)
+				OR EXISTS (SELECT 1 FROM data_keys) OR EXISTS (SELECT 1 FROM vault_change_seq)
+				OR EXISTS (SELECT 1 FROM public_keys) OR EXISTS (SELECT 1 FROM item_shares)
+				OR EXISTS (SELECT 1 FROM organizations) OR EXISTS (SELECT 1 FROM org_members)
+				OR EXISTS (SELECT 1 FROM org_collections) OR EXISTS (SELECT 1 FROM vault_folders)`,
+		).Scan(&notEmpty)
+		if err != nil {
+			return err
+		}
+
+		if notEmpty {
+			return ErrStorageNotEmpty
+		}
+
+		batch := &pgx.Batch{}
+
+		for _, user := range snap.Users {
+			batch.Queue(`INSERT INTO users (id, email, password_hash) VALUES ($1, $2, $3)`,
+				user.ID, user.Email, user.PasswordHash)
+		}
+
+		for _, userID := range snap.Tokens {
+			batch.Queue(`INSERT INTO tokens (user_id) VALUES ($1)`, userID)
+		}
+
+		for _, key := range snap.Keys {
+			batch.Queue(
+				`INSERT INTO data_keys (owner_id, master_key_id, wrapped, created_at) VALUES ($1, $2, $3, $4)`,
+				key.OwnerID, key.MasterKeyID, key.Wrapped, key.CreatedAt)
+		}
+
+		for _, key := range snap.PublicKeys {
+			batch.Queue(`INSERT INTO public_keys (user_id, algorithm, key, created_at) VALUES ($1, $2, $3, $4)`,
+				key.UserID, key.Algorithm, key.Key, key.CreatedAt)
+		}
+
+		for _, share := range snap.Shares {
+			batch.Queue(
+				`INSERT INTO item_shares (owner_id, item_id, recipient_id, permission, wrapped_key, created_at)
+					VALUES ($1, $2, $3, $4, $5, $6)`,
+				share.OwnerID, share.ItemID, share.RecipientID, string(share.Permission), share.WrappedKey,
+				share.CreatedAt)
+		}
+
+		queuePostgresOrgs(batch, snap)
+
+		for _, vault := range snap.Vaults {
+			if err := queuePostgresVault(batch, vault); err != nil {
+				return fmt.Errorf("%s: %w", vault.OwnerID, err)
+			}
+		}
+
+		return mapPostgresError(tx.SendBatch(ctx, batch).Close())
+	})
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+
+	return nil
+}
+
+// CreateItem создаёт новую запись с паролем.
+func (p *PostgresStorage) CreateItem(ctx context.Context, item *entity.VaultItem) (string, error) {
+	err := pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		_, err := p.wrapTx(ctx, tx).createItem(item)
+
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("item: %w", err)
+	}
+
+	return item.ID, nil
+}
+
+// UpdateItem обновляет текущую запись с паролем.
+func (p *PostgresStorage) UpdateItem(ctx context.Context, item *entity.VaultItem) error {
+	var stored *entity.VaultItem
+
+	err := pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		var err error
+
+		stored, err = p.wrapTx(ctx, tx).updateItem(item)
+
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("item: %w", err)
+	}
+
+	setStoredState(item, stored)
+
+	return nil
+}
+
+// UpsertItem обновляет или обновляет текущую запись с паролем для синхронизации.
+func (p *PostgresStorage) UpsertItem(ctx context.Context, item *entity.VaultItem) (string, error) {
+	var stored *entity.VaultItem
+
+	err := pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		var err error
+
+		stored, err = p.wrapTx(ctx, tx).upsert(item)
+
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("item: %w", err)
+	}
+
+	setStoredState(item, stored)
+
+	return item.ID, nil
+}
+
+// GetItem получает текущую запись с паролем по ID.
+func (p *PostgresStorage) GetItem(
+	ctx context.Context,
+	ownerID, itemID string,
+) (*entity.VaultItem, error) {
+	row := p.pool.QueryRow(ctx,
+		`SELECT `+pgItemColumns+` FROM vault_items
+		WHERE owner_id = $1 AND id = $2 AND (expires_at IS NULL OR expires_at > $3)`,
+		ownerID, itemID, nowPostgres(),
+	)
+
+	item, err := scanPostgresItem(row)
+	if err != nil {
+		return nil, fmt.Errorf("item: %w", mapPostgresError(err))
+	}
+
+	return item, nil
+}
+
+// ListItems получает список записей с паролями по пользователю.
+func (p *PostgresStorage) ListItems(
+	ctx context.Context,
+	ownerID string,
+) ([]*entity.VaultItem, error) {
+	rows, err := p.pool.Query(ctx,
+		`SELECT `+pgItemColumns+` FROM vault_items
+		WHERE owner_id = $1 AND (expires_at IS NULL OR expires_at > $2)
+		ORDER BY id`,
+		ownerID, nowPostgres(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("items: %w", err)
+	}
+
+	return collectPostgresItems(rows)
+}
+
+// ListTags получает метки записей пользователя с количеством записей.
+//
+// Метки раскрываются и группируются запросом; порядок названий побайтовый, как в Go.
+func (p *PostgresStorage) ListTags(ctx context.Context, ownerID string) ([]entity.TagCount, error) {
+	rows, err := p.pool.Query(ctx,
+		`SELECT tag, count(*) FROM vault_items, unnest(tags) AS tag
+		WHERE owner_id = $1 AND (expires_at IS NULL OR expires_at > $2)
+		GROUP BY tag
+		ORDER BY tag COLLATE "C"`,
+		ownerID, nowPostgres(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("tags: %w", err)
+	}
+
+	tags, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (entity.TagCount, error) {
+		tag := entity.TagCount{Name: "", Count: 0}
+		err := row.Scan(&tag.Name, &tag.Count)
+
+		return tag, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("tags: %w", err)
+	}
+
+	return tags, nil
+}
+
+// QueryItems получает записи пользователя по условиям выборки.
+//
+// Фильтры, сортировка и позиция страницы переводятся в условия запроса,
+// которые используют индексы из миграций.
+func (p *PostgresStorage) QueryItems(
+	ctx context.Context,
+	ownerID string,
+	query *entity.ItemQuery,
+) ([]*entity.VaultItem, error) {
+	sql, args := buildPostgresItemQuery(ownerID, query)
+
+	rows, err := p.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, fmt.Errorf("items: %w", err)
+	}
+
+	return collectPostgresItems(rows)
+}
+
+// SetItemContent заменяет содержимое бинарной записи.
+func (p *PostgresStorage) SetItemContent(
+	ctx context.Context,
+	ownerID, itemID string,
+	version int64,
+	content *entity.Content,
+) (*entity.VaultItem, error) {
+	var updated *entity.VaultItem
+
+	err := pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		row := tx.QueryRow(ctx,
+			`SELECT `+pgItemColumns+` FROM vault_items
+			WHERE owner_id = $1 AND id = $2
+			FOR UPDATE`,
+			ownerID, itemID,
+		)
+
+		current, err := scanPostgresItem(row)
+		if err != nil {
+			return mapPostgresError(err)
+		}
+
+		updated, err = p.wrapTx(ctx, tx).updateItem(contentUpdate(current, version, content))
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("item: %w", err)
+	}
+
+	return updated, nil
+}
+
+// ContentHashes получает хеши содержимого, на которое ссылаются данные хранилища.
+func (p *PostgresStorage) ContentHashes(ctx context.Context) ([]string, error) {
+	rows, err := p.pool.Query(ctx,
+		`SELECT content_hash FROM vault_items WHERE content_hash <> ''
+		UNION
+		SELECT content_hash FROM vault_revisions WHERE content_hash <> ''
+		UNION
+		SELECT content_hash FROM vault_trash WHERE content_hash <> ''
+		UNION
+		SELECT jsonb_path_query(attachments, '$[*].hash') #>> '{}' FROM vault_items
+		UNION
+		SELECT jsonb_path_query(attachments, '$[*].hash') #>> '{}' FROM vault_revisions
+		UNION
+		SELECT jsonb_path_query(attachments, '$[*].hash') #>> '{}' FROM vault_trash
+		ORDER BY 1`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("content hashes: %w", err)
+	}
+
+	hashes, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("content hashes: %w", err)
+	}
+
+	return hashes, nil
+}
+
+// DeleteItem удаляет текущую запись с паролем по ID.
+func (p *PostgresStorage) DeleteItem(ctx context.Context, ownerID, itemID string) error {
+	err := pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		_, err := p.wrapTx(ctx, tx).remove(ownerID, itemID, 0)
+
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("item: %w", err)
+	}
+
+	return nil
+}
+
+// ListTrash получает записи пользователя в корзине.
+func (p *PostgresStorage) ListTrash(
+	ctx context.Context,
+	ownerID string,
+) ([]*entity.TrashItem, error) {
+	rows, err := p.pool.Query(ctx,
+		`SELECT `+pgTrashColumns+` FROM vault_trash
+		WHERE owner_id = $1
+		ORDER BY deleted_at DESC`,
+		ownerID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("trash: %w", err)
+	}
+
+	res, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*entity.TrashItem, error) {
+		return scanPostgresTrashItem(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("trash: %w", err)
+	}
+
+	return res, nil
+}
+
+// RestoreTrash восстанавливает запись из корзины.
+func (p *PostgresStorage) RestoreTrash(
+	ctx context.Context,
+	ownerID, itemID string,
+) (*entity.VaultItem, error) {
+	var restored *entity.VaultItem
+
+	err := pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		row := tx.QueryRow(ctx,
+			`SELECT `+pgTrashColumns+` FROM vault_trash
+			WHERE owner_id = $1 AND id = $2
+			FOR UPDATE`,
+			ownerID, itemID,
+		)
+
+		trashed, err := scanPostgresTrashItem(row)
+		if err != nil {
+			return mapPostgresError(err)
+		}
+
+		if err := checkNotExpired(trashed.Item.ExpiresAt, time.Now()); err != nil {
+			return err
+		}
+
+		restored, err = p.wrapTx(ctx, tx).createItem(restoredItem(trashed, ownerID))
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("trash: %w", err)
+	}
+
+	return restored, nil
+}
+
+// EmptyTrash безвозвратно удаляет записи из корзины пользователя.
+func (p *PostgresStorage) EmptyTrash(ctx context.Context, ownerID string) (int, error) {
+	var count int
+
+	err := p.pool.QueryRow(ctx,
+		`WITH purged AS (
+			DELETE FROM vault_trash WHERE owner_id = $1 RETURNING owner_id, id
+		), revs AS (
+			DELETE FROM vault_revisions r USING purged
+			WHERE r.owner_id = purged.owner_id AND r.id = purged.id
+		), shares AS (
+			DELETE FROM item_shares s USING purged
+			WHERE s.owner_id = purged.owner_id AND s.item_id = purged.id
+		)
+		SELECT count(*) FROM purged`,
+		ownerID,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("trash: %w", err)
+	}
+
+	return count, nil
+}
+
+// PurgeTrash безвозвратно удаляет записи, помещённые в корзину раньше before.
+func (p *PostgresStorage) PurgeTrash(ctx context.Context, before time.Time) (int, error) {
+	var count int
+
+	err := p.pool.QueryRow(ctx,
+		`WITH purged AS (
+			DELETE FROM vault_trash WHERE deleted_at < $1 RETURNING owner_id, id
+		), revs AS (
+			DELETE FROM vault_revisions r USING purged
+			WHERE r.owner_id = purged.owner_id AND r.id = purged.id
+		), shares AS (
+			DELETE FROM item_shares s USING purged
+			WHERE s.owner_id = purged.owner_id AND s.item_id = purged.id
+		)
+		SELECT count(*) FROM purged`,
+		before,
+	).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("trash: %w", err)
+	}
+
+	return count, nil
+}
+
+// ExpireItems удаляет записи с истёкшим сроком.
+//
+// Каждая запись удаляется в отдельной транзакции, чтобы не блокировать
+// записи всех пользователей сразу.
+func (p *PostgresStorage) ExpireItems(ctx context.Context, now time.Time) (int, error) {
+	rows, err := p.pool.Query(ctx,
+		`SELECT owner_id, id FROM vault_items WHERE expires_at <= $1`,
+		now,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("expired items: %w", err)
+	}
+
+	type itemRef struct {
+		ownerID string
+		itemID  string
+	}
+
+	refs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (itemRef, error) {
+		var ref itemRef
+		err := row.Scan(&ref.ownerID, &ref.itemID)
+
+		return ref, err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("expired items: %w", err)
+	}
+
+	count := 0
+
+	for _, ref := range refs {
+		var expired bool
+
+		err := pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+			var err error
+
+			expired, err = p.wrapTx(ctx, tx).expire(ref.ownerID, ref.itemID, now)
+
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("expire item: %w", err)
+		}
+
+		if expired {
+			count++
+		}
+	}
+
+	var purged int
+
+	err = p.pool.QueryRow(ctx,
+		`WITH purged AS (
+			DELETE FROM vault_trash WHERE expires_at <= $1 RETURNING owner_id, id
+		), revs AS (
+			DELETE FROM vault_revisions r USING purged
+			WHERE r.owner_id = purged.owner_id AND r.id = purged.id
+		), shares AS (
+			DELETE FROM item_shares s USING purged
+			WHERE s.owner_id = purged.owner_id AND s.item_id = purged.id
+		)
+		SELECT count(*) FROM purged`,
+		now,
+	).Scan(&purged)
+	if err != nil {
+		return count, fmt.Errorf("trash: %w", err)
+	}
+
+	return count + purged, nil
+}
+
+// ListRevisions получает прежние версии записи, начиная с последней.
+func (p *PostgresStorage) ListRevisions(
+	ctx context.Context,
+	ownerID, itemID string,
+) ([]*entity.Revision, error) {
+	rows, err := p.pool.Query(ctx,
+		`SELECT `+pgRevisionColumns+` FROM vault_revisions
+		WHERE owner_id = $1 AND id = $2
+		ORDER BY version DESC`,
+		ownerID, itemID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("revisions: %w", err)
+	}
+
+	res, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*entity.Revision, error) {
+		return scanPostgresRevision(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("revisions: %w", err)
+	}
+
+	return res, nil
+}
+
+// GetRevision получает прежнюю версию записи по номеру версии.
+func (p *PostgresStorage) GetRevision(
+	ctx context.Context,
+	ownerID, itemID string,
+	version int64,
+) (*entity.Revision, error) {
+	row := p.pool.QueryRow(ctx,
+		`SELECT `+pgRevisionColumns+` FROM vault_revisions
+		WHERE owner_id = $1 AND id = $2 AND version = $3`,
+		ownerID, itemID, version,
+	)
+
+	rev, err := scanPostgresRevision(row)
+	if err != nil {
+		return nil, fmt.Errorf("revision: %w", mapPostgresError(err))
+	}
+
+	return rev, nil
+}
+
+// CompactRevisions удаляет прежние версии, заменённые раньше before.
+func (p *PostgresStorage) CompactRevisions(ctx context.Context, before time.Time) (int, error) {
+	tag, err := p.pool.Exec(ctx, `DELETE FROM vault_revisions WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("revisions: %w", err)
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+// ApplyChanges применяет пакет изменений пользователя в одной транзакции.
+//
+// Каждое изменение выполняется в отдельной точке сохранения, чтобы отклонённое
+// изменение не прерывало транзакцию всего пакета.
+func (p *PostgresStorage) ApplyChanges(
+	ctx context.Context,
+	ownerID string,
+	changes []*entity.Change,
+) ([]*entity.ChangeResult, error) {
+	var results []*entity.ChangeResult
+
+	err := pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		var err error
+
+		results, err = applyChanges(ownerID, changes, &pgSavepointTx{pgTx: *p.wrapTx(ctx, tx)})
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("changes: %w", err)
+	}
+
+	return results, nil
+}
+
+// ListChanges получает изменения пользователя после указанного номера.
+func (p *PostgresStorage) ListChanges(
+	ctx context.Context,
+	ownerID string,
+	afterSeq int64,
+	limit int,
+) ([]*entity.VaultItem, error) {
+	rows, err := p.pool.Query(ctx,
+		`SELECT `+pgItemColumns+`, false AS deleted, false AS expired FROM vault_items
+		WHERE owner_id = $1 AND seq > $2
+		UNION ALL
+		SELECT id, owner_id, '', '', '', NULL, '', '', '', 0, '', NULL, NULL, NULL,
+			version, deleted_at, seq, true, expired
+		FROM vault_tombstones
+		WHERE owner_id = $1 AND seq > $2
+		ORDER BY seq
+		LIMIT NULLIF($3, 0)`,
+		ownerID, afterSeq, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("changes: %w", err)
+	}
+
+	res, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*entity.VaultItem, error) {
+		//nolint:exhaustruct // поля заполняются при сканировании
+		item := &entity.VaultItem{}
+
+		var (
+			contentHash string
+			contentSize int64
+		)
+
+		err := row.Scan(
+			&item.ID, &item.OwnerID, &item.Type, &item.Title, &item.Description,
+			&item.Meta, &item.Username, &item.Data, &contentHash, &contentSize, &item.FolderID, &item.Tags,
+			&item.Attachments, &item.ExpiresAt, &item.Version, &item.UpdatedAt, &item.Seq, &item.Deleted, &item.Expired,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan: %w", err)
+		}
+
+		item.Content = scannedContent(contentHash, contentSize)
+		item.UpdatedAt = item.UpdatedAt.UTC()
+		item.ExpiresAt = utcPostgresTime(item.ExpiresAt)
+
+		return item, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("changes: %w", err)
+	}
+
+	return res, nil
+}
+
+// CompactTombstones удаляет отметки об удалении записей и папок, созданные раньше before.
+func (p *PostgresStorage) CompactTombstones(ctx context.Context, before time.Time) (int, error) {
+	tag, err := p.pool.Exec(ctx, `DELETE FROM vault_tombstones WHERE deleted_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("tombstones: %w", err)
+	}
+
+	folderTag, err := p.pool.Exec(ctx, `DELETE FROM vault_folders WHERE deleted AND updated_at < $1`, before)
+	if err != nil {
+		return int(tag.RowsAffected()), fmt.Errorf("folders: %w", err)
+	}
+
+	return int(tag.RowsAffected() + folderTag.RowsAffected()), nil
+}
+
+// postgresSnapshot считывает все данные базы в снимок.
+func postgresSnapshot(ctx context.Context, tx pgx.Tx, builder *snapshotBuilder) error {
+	snap := builder.snap
+
+	rows, err := tx.Query(ctx, `SELECT id, email, password_hash FROM users`)
+	if err != nil {
+		return fmt.Errorf("users: %w", err)
+	}
+
+	snap.Users, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (*entity.User, error) {
+		user := &entity.User{ID: "", Email: "", PasswordHash: ""}
+		err := row.Scan(&user.ID, &user.Email, &user.PasswordHash)
+
+		return user, err
+	})
+	if err != nil {
+		return fmt.Errorf("users: %w", err)
+	}
+
+	rows, err = tx.Query(ctx, `SELECT user_id FROM tokens`)
+	if err != nil {
+		return fmt.Errorf("tokens: %w", err)
+	}
+
+	snap.Tokens, err = pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return fmt.Errorf("tokens: %w", err)
+	}
+
+	rows, err = tx.Query(ctx, `SELECT owner_id, master_key_id, wrapped, created_at FROM data_keys`)
+	if err != nil {
+		return fmt.Errorf("keys: %w", err)
+	}
+
+	snap.Keys, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (*entity.DataKey, error) {
+		key := &entity.DataKey{CreatedAt: time.Time{}, OwnerID: "", MasterKeyID: "", Wrapped: nil}
+		err := row.Scan(&key.OwnerID, &key.MasterKeyID, &key.Wrapped, &key.CreatedAt)
+		key.CreatedAt = key.CreatedAt.UTC()
+
+		return key, err
+	})
+	if err != nil {
+		return fmt.Errorf("keys: %w", err)
+	}
+
+	rows, err = tx.Query(ctx, `SELECT user_id, algorithm, key, created_at FROM public_keys`)
+	if err != nil {
+		return fmt.Errorf("public keys: %w", err)
+	}
+
+	snap.PublicKeys, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (*entity.PublicKey, error) {
+		key := &entity.PublicKey{CreatedAt: time.Time{}, UserID: "", Algorithm: "", Key: nil}
+		err := row.Scan(&key.UserID, &key.Algorithm, &key.Key, &key.CreatedAt)
+		key.CreatedAt = key.CreatedAt.UTC()
+
+		return key, err
+	})
+	if err != nil {
+		return fmt.Errorf("public keys: %w", err)
+	}
+
+	rows, err = tx.Query(ctx, `SELECT `+pgShareColumns+` FROM item_shares`)
+	if err != nil {
+		return fmt.Errorf("shares: %w", err)
+	}
+
+	snap.Shares, err = pgx.CollectRows(rows, scanPostgresShare)
+	if err != nil {
+		return fmt.Errorf("shares: %w", err)
+	}
+
+	if err := postgresSnapshotOrgs(ctx, tx, snap); err != nil {
+		return err
+	}
+
+	return postgresSnapshotVaults(ctx, tx, builder)
+}
+
+// postgresSnapshotOrgs считывает организации, их участников и коллекции в снимок.
+func postgresSnapshotOrgs(ctx context.Context, tx pgx.Tx, snap *entity.Snapshot) error {
+	rows, err := tx.Query(ctx, `SELECT id, name, created_at FROM organizations`)
+	if err != nil {
+		return fmt.Errorf("orgs: %w", err)
+	}
+
+	snap.Orgs, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (*entity.Organization, error) {
+		org := &entity.Organization{CreatedAt: time.Time{}, ID: "", Name: ""}
+		err := row.Scan(&org.ID, &org.Name, &org.CreatedAt)
+		org.CreatedAt = org.CreatedAt.UTC()
+
+		return org, err
+	})
+	if err != nil {
+		return fmt.Errorf("orgs: %w", err)
+	}
+
+	rows, err = tx.Query(ctx, `SELECT `+pgMemberColumns+` FROM org_members`)
+	if err != nil {
+		return fmt.Errorf("members: %w", err)
+	}
+
+	snap.Members, err = pgx.CollectRows(rows, scanPostgresMember)
+	if err != nil {
+		return fmt.Errorf("members: %w", err)
+	}
+
+	rows, err = tx.Query(ctx, `SELECT `+pgCollectionColumns+` FROM org_collections`)
+	if err != nil {
+		return fmt.Errorf("collections: %w", err)
+	}
+
+	snap.Collections, err = pgx.CollectRows(rows, scanPostgresCollection)
+	if err != nil {
+		return fmt.Errorf("collections: %w", err)
+	}
+
+	return nil
+}
+
+// queuePostgresOrgs добавляет в пакет вставку организаций, их участников и коллекций из снимка.
+func queuePostgresOrgs(batch *pgx.Batch, snap *entity.Snapshot) {
+	for _, org := range snap.Orgs {
+		batch.Queue(`INSERT INTO organizations (id, name, created_at) VALUES ($1, $2, $3)`,
+			org.ID, org.Name, org.CreatedAt)
+	}
+
+	for _, member := range snap.Members {
+		batch.Queue(`INSERT INTO org_members (`+pgMemberColumns+`) VALUES ($1, $2, $3, $4, $5, $6)`,
+			member.OrgID, member.UserID, string(member.Role), string(member.Status), member.InvitedBy,
+			member.CreatedAt)
+	}
+
+	for _, coll := range snap.Collections {
+		batch.Queue(`INSERT INTO org_collections (`+pgCollectionColumns+`) VALUES ($1, $2, $3, $4)`,
+			coll.ID, coll.OrgID, coll.Name, coll.CreatedAt)
+	}
+}
+
+// postgresSnapshotVaults считывает данные пользователей в снимок.
+func postgresSnapshotVaults(ctx context.Context, tx pgx.Tx, builder *snapshotBuilder) error {
+	var (
+		ownerID string
+		seq     int64
+	)
+
+	rows, err := tx.Query(ctx, `SELECT owner_id, seq FROM vault_change_seq`)
+	if err != nil {
+		return fmt.Errorf("seq: %w", err)
+	}
+
+	_, err = pgx.ForEachRow(rows, []any{&ownerID, &seq}, func() error {
+		builder.vault(ownerID).Seq = seq
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("seq: %w", err)
+	}
+
+	rows, err = tx.Query(ctx, `SELECT `+pgItemColumns+` FROM vault_items`)
+	if err != nil {
+		return fmt.Errorf("items: %w", err)
+	}
+
+	items, err := collectPostgresItems(rows)
+	if err != nil {
+		return fmt.Errorf("items: %w", err)
+	}
+
+	for _, item := range items {
+		vault := builder.vault(item.OwnerID)
+		vault.Items = append(vault.Items, item)
+	}
+
+	rows, err = tx.Query(ctx, `SELECT owner_id, id, version, deleted_at, seq, expired FROM vault_tombstones`)
+	if err != nil {
+		return fmt.Errorf("tombstones: %w", err)
+	}
+
+	tombs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*entity.Tombstone, error) {
+		//nolint:exhaustruct // поля заполняются при сканировании
+		tomb := &entity.Tombstone{}
+		err := row.Scan(&tomb.OwnerID, &tomb.ID, &tomb.Version, &tomb.DeletedAt, &tomb.Seq, &tomb.Expired)
+		tomb.DeletedAt = tomb.DeletedAt.UTC()
+
+		return tomb, err
+	})
+	if err != nil {
+		return fmt.Errorf("tombstones: %w", err)
+	}
+
+	for _, tomb := range tombs {
+		vault := builder.vault(tomb.OwnerID)
+		vault.Tombstones = append(vault.Tombstones, tomb)
+	}
+
+	rows, err = tx.Query(ctx, `SELECT `+pgRevisionColumns+` FROM vault_revisions`)
+	if err != nil {
+		return fmt.Errorf("revisions: %w", err)
+	}
+
+	revs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*entity.Revision, error) {
+		return scanPostgresRevision(row)
+	})
+	if err != nil {
+		return fmt.Errorf("revisions: %w", err)
+	}
+
+	for _, rev := range revs {
+		vault := builder.vault(rev.Item.OwnerID)
+		vault.Revisions = append(vault.Revisions, rev)
+	}
+
+	rows, err = tx.Query(ctx, `SELECT `+pgTrashColumns+` FROM vault_trash`)
+	if err != nil {
+		return fmt.Errorf("trash: %w", err)
+	}
+
+	trash, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*entity.TrashItem, error) {
+		return scanPostgresTrashItem(row)
+	})
+	if err != nil {
+		return fmt.Errorf("trash: %w", err)
+	}
+
+	for _, trashed := range trash {
+		vault := builder.vault(trashed.Item.OwnerID)
+		vault.Trash = append(vault.Trash, trashed)
+	}
+
+	rows, err = tx.Query(ctx, `SELECT `+pgFolderColumns+` FROM vault_folders`)
+	if err != nil {
+		return fmt.Errorf("folders: %w", err)
+	}
+
+	folders, err := pgx.CollectRows(rows, scanPostgresFolder)
+	if err != nil {
+		return fmt.Errorf("folders: %w", err)
+	}
+
+	for _, folder := range folders {
+		vault := builder.vault(folder.OwnerID)
+		vault.Folders = append(vault.Folders, folder)
+	}
+
+	return nil
+}
+
+// queuePostgresVault добавляет в пакет запросы, сохраняющие данные пользователя из снимка.
+func queuePostgresVault(batch *pgx.Batch, vault *entity.VaultSnapshot) error {
+	seq, err := restoredVault(vault)
+	if err != nil {
+		return err
+	}
+
+	batch.Queue(`INSERT INTO vault_change_seq (owner_id, seq) VALUES ($1, $2)`, vault.OwnerID, seq)
+
+	for _, item := range vault.Items {
+		contentHash, contentSize := postgresContent(item.Content)
+
+		batch.Queue(
+			`INSERT INTO vault_items (`+pgItemColumns+`, size)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)`,
+			item.ID, item.OwnerID, item.Type, item.Title, item.Description,
+			item.Meta, item.Username, item.Data, contentHash, contentSize, item.FolderID, item.Tags,
+			item.Attachments, item.ExpiresAt, item.Version, item.UpdatedAt, item.Seq, item.UsageSize(),
+		)
+	}
+
+	for _, tomb := range vault.Tombstones {
+		batch.Queue(
+			`INSERT INTO vault_tombstones (owner_id, id, version, deleted_at, seq, expired)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			tomb.OwnerID, tomb.ID, tomb.Version, tomb.DeletedAt, tomb.Seq, tomb.Expired,
+		)
+	}
+
+	for _, rev := range vault.Revisions {
+		queuePostgresStamped(batch, "vault_revisions", pgRevisionColumns, rev.Item, rev.CreatedAt)
+	}
+
+	for _, trashed := range vault.Trash {
+		queuePostgresStamped(batch, "vault_trash", pgTrashColumns, trashed.Item, trashed.DeletedAt)
+	}
+
+	for _, folder := range vault.Folders {
+		batch.Queue(`INSERT INTO vault_folders (`+pgFolderColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			folder.OwnerID, folder.ID, folder.ParentID, folder.Name, folder.Version, folder.UpdatedAt,
+			folder.Seq, folder.Deleted)
+	}
+
+	return nil
+}
+
+// queuePostgresStamped добавляет в пакет запрос, сохраняющий содержимое записи
+// с отметкой времени в колонки pgRevisionColumns или pgTrashColumns таблицы table.
+func queuePostgresStamped(batch *pgx.Batch, table, columns string, item *entity.VaultItem, stamp time.Time) {
+	contentHash, contentSize := postgresContent(item.Content)
+
+	batch.Queue(
+		`INSERT INTO `+table+` (`+columns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`,
+		item.ID, item.OwnerID, item.Type, item.Title, item.Description,
+		item.Meta, item.Username, item.Data, contentHash, contentSize, item.FolderID, item.Tags,
+		item.Attachments, item.ExpiresAt, item.Version, item.UpdatedAt, stamp,
+	)
+}
+
+// pgTx выполняет операции с записями в рамках открытой транзакции.
+type pgTx struct {
+	ctx context.Context //nolint:containedctx // контекст живёт не дольше транзакции
+	tx  pgx.Tx
+
+	revisionLimit int
+	quota         entity.Quota
+}
+
+// wrapTx оборачивает транзакцию для операций с записями.
+func (p *PostgresStorage) wrapTx(ctx context.Context, tx pgx.Tx) *pgTx {
+	return &pgTx{
+		ctx:           ctx,
+		tx:            tx,
+		revisionLimit: p.revisionLimit,
+		quota:         p.quota,
+	}
+}
+
+// createItem создаёт запись, учитывая отметку об удалении с тем же ID.
+func (t *pgTx) createItem(item *entity.VaultItem) (*entity.VaultItem, error) {
+	if item.ID == "" {
+		item.ID = uuid.New().String()
+	}
+
+	version := int64(1)
+
+	var tombVersion int64
+
+	err := t.tx.QueryRow(t.ctx,
+		`DELETE FROM vault_tombstones WHERE owner_id = $1 AND id = $2 RETURNING version`,
+		item.OwnerID, item.ID,
+	).Scan(&tombVersion)
+
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+	case err != nil:
+		return nil, err
+	case item.Version != 0 && item.Version != tombVersion:
+		return nil, ErrVersionConflict
+	default:
+		version = tombVersion + 1
+	}
+
+	_, err = t.tx.Exec(t.ctx,
+		`DELETE FROM vault_trash WHERE owner_id = $1 AND id = $2`,
+		item.OwnerID, item.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := sharePostgresFolder(t.ctx, t.tx, item.OwnerID, item.FolderID); err != nil {
+		return nil, err
+	}
+
+	// Номер изменения выдаётся под блокировкой строки счётчика пользователя,
+	// поэтому проверка квоты после него не гоняется с другими записями.
+	seq, err := nextPostgresSeq(t.ctx, t.tx, item.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := t.usage(item.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+
+	size := item.UsageSize()
+	if err := checkQuota(t.quota, usage, 1, size, item.Size()); err != nil {
+		return nil, err
+	}
+
+	updatedAt := nowPostgres()
+	content := resolveContent(item.Content, nil)
+	contentHash, contentSize := postgresContent(content)
+	attachments := resolveAttachments(item.Attachments, nil)
+
+	_, err = t.tx.Exec(t.ctx,
+		`INSERT INTO vault_items (`+pgItemColumns+`, size)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)`,
+		item.ID, item.OwnerID, item.Type, item.Title, item.Description,
+		item.Meta, item.Username, item.Data, contentHash, contentSize, item.FolderID, item.Tags,
+		attachments, item.ExpiresAt, version, updatedAt, seq, size,
+	)
+	if err != nil {
+		return nil, mapPostgresError(err)
+	}
+
+	item.Content = content
+	item.Attachments = attachments
+	item.Version = version
+	item.UpdatedAt = updatedAt
+	item.Seq = seq
+
+	res := *item
+
+	return &res, nil
+}
+
+// updateItem обновляет запись с проверкой версии.
+func (t *pgTx) updateItem(item *entity.VaultItem) (*entity.VaultItem, error) {
+	var (
+		version, oldSize, oldContentSize int64
+		oldContentHash                   string
+		oldAttachments                   []entity.Attachment
+		expiresAt                        *time.Time
+	)
+
+	err := t.tx.QueryRow(t.ctx,
+		`SELECT version, size, content_hash, content_size, attachments, expires_at FROM vault_items
+		WHERE owner_id = $1 AND id = $2 FOR UPDATE`,
+		item.OwnerID, item.ID,
+	).Scan(&version, &oldSize, &oldContentHash, &oldContentSize, &oldAttachments, &expiresAt)
+	if err != nil {
+		return nil, mapPostgresError(err)
+	}
+
+	if err := checkNotExpired(expiresAt, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if item.Version != 0 && item.Version != version {
+		return nil, ErrVersionConflict
+	}
+
+	if err := sharePostgresFolder(t.ctx, t.tx, item.OwnerID, item.FolderID); err != nil {
+		return nil, err
+	}
+
+	if err := t.keepRevision(item.OwnerID, item.ID); err != nil {
+		return nil, err
+	}
+
+	seq, err := nextPostgresSeq(t.ctx, t.tx, item.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := t.usage(item.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := updatedItem(item, scannedContent(oldContentHash, oldContentSize), oldAttachments)
+
+	size := res.UsageSize()
+	if err := checkQuota(t.quota, usage, 0, size-oldSize, res.Size()); err != nil {
+		return nil, err
+	}
+
+	res.Version = version + 1
+	res.UpdatedAt = nowPostgres()
+	res.Seq = seq
+
+	contentHash, contentSize := postgresContent(res.Content)
+
+	_, err = t.tx.Exec(t.ctx,
+		`UPDATE vault_items
+		SET type = $3, title = $4, description = $5, meta = $6, username = $7, data = $8,
+			content_hash = $9, content_size = $10, folder_id = $11, tags = $12, attachments = $13,
+			expires_at = $14, version = $15, updated_at = $16, seq = $17, size = $18
+		WHERE owner_id = $1 AND id = $2`,
+		res.OwnerID, res.ID, res.Type, res.Title, res.Description,
+		res.Meta, res.Username, res.Data, contentHash, contentSize, res.FolderID, res.Tags,
+		res.Attachments, res.ExpiresAt, res.Version, res.UpdatedAt, res.Seq, size,
+	)
+	if err != nil {
+		return nil, mapPostgresError(err)
+	}
+
+	return res, nil
+}
+
+// upsert обновляет запись или создаёт её при отсутствии.
+func (t *pgTx) upsert(item *entity.VaultItem) (*entity.VaultItem, error) {
+	if item.ID == "" {
+		return t.createItem(item)
+	}
+
+	res, err := t.updateItem(item)
+	if errors.Is(err, ErrEntityNotFound) && !errors.Is(err, errItemExpired) {
+		return t.createItem(item)
+	}
+
+	return res, err
+}
+
+// remove удаляет запись и оставляет отметку об удалении.
+// Запись с истёкшим сроком считается уже удалённой: её удаляет фоновая задача.
+func (t *pgTx) remove(ownerID, itemID string, version int64) (*entity.VaultItem, error) {
+	var (
+		current   int64
+		expiresAt *time.Time
+	)
+
+	err := t.tx.QueryRow(t.ctx,
+		`SELECT version, expires_at FROM vault_items WHERE owner_id = $1 AND id = $2 FOR UPDATE`,
+		ownerID, itemID,
+	).Scan(&current, &expiresAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return t.tombstone(ownerID, itemID)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if checkNotExpired(expiresAt, time.Now()) != nil {
+		return nil, nil //nolint:nilnil // запись уже удалена по сроку
+	}
+
+	if version != 0 && version != current {
+		return nil, ErrVersionConflict
+	}
+
+	if err := t.keepRevision(ownerID, itemID); err != nil {
+		return nil, err
+	}
+
+	seq, err := nextPostgresSeq(t.ctx, t.tx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	tomb := &entity.Tombstone{
+		ID:        itemID,
+		OwnerID:   ownerID,
+		Version:   current + 1,
+		DeletedAt: nowPostgres(),
+		Seq:       seq,
+		Expired:   false,
+	}
+
+	_, err = t.tx.Exec(t.ctx,
+		`INSERT INTO vault_trash (`+pgTrashColumns+`)
+		SELECT id, owner_id, type, title, description, meta, username, data,
+			content_hash, content_size, folder_id, tags, attachments, expires_at, version, updated_at, $3
+		FROM vault_items WHERE owner_id = $1 AND id = $2
+		ON CONFLICT (owner_id, id) DO UPDATE
+		SET type = EXCLUDED.type, title = EXCLUDED.title, description = EXCLUDED.description,
+			meta = EXCLUDED.meta, username = EXCLUDED.username, data = EXCLUDED.data,
+			content_hash = EXCLUDED.content_hash, content_size = EXCLUDED.content_size,
+			folder_id = EXCLUDED.folder_id, tags = EXCLUDED.tags, attachments = EXCLUDED.attachments,
+			expires_at = EXCLUDED.expires_at,
+			version = EXCLUDED.version, updated_at = EXCLUDED.updated_at, deleted_at = EXCLUDED.deleted_at`,
+		ownerID, itemID, tomb.DeletedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = t.tx.Exec(t.ctx,
+		`DELETE FROM vault_items WHERE owner_id = $1 AND id = $2`,
+		ownerID, itemID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.putTombstone(tomb); err != nil {
+		return nil, err
+	}
+
+	return tomb.AsItem(), nil
+}
+
+// expire удаляет запись с истёкшим сроком и её прежние версии, оставляя отметку
+// об удалении с признаком Expired. Возвращает false, если запись уже удалена
+// или её срок продлён.
+func (t *pgTx) expire(ownerID, itemID string, now time.Time) (bool, error) {
+	var version int64
+
+	err := t.tx.QueryRow(t.ctx,
+		`DELETE FROM vault_items WHERE owner_id = $1 AND id = $2 AND expires_at <= $3 RETURNING version`,
+		ownerID, itemID, now,
+	).Scan(&version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("delete: %w", err)
+	}
+
+	_, err = t.tx.Exec(t.ctx,
+		`DELETE FROM vault_revisions WHERE owner_id = $1 AND id = $2`,
+		ownerID, itemID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("delete revisions: %w", err)
+	}
+
+	_, err = t.tx.Exec(t.ctx,
+		`DELETE FROM item_shares WHERE owner_id = $1 AND item_id = $2`,
+		ownerID, itemID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("delete shares: %w", err)
+	}
+
+	seq, err := nextPostgresSeq(t.ctx, t.tx, ownerID)
+	if err != nil {
+		return false, err
+	}
+
+	tomb := &entity.Tombstone{
+		ID:        itemID,
+		OwnerID:   ownerID,
+		Version:   version + 1,
+		DeletedAt: nowPostgres(),
+		Seq:       seq,
+		Expired:   true,
+	}
+
+	if err := t.putTombstone(tomb); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// putTombstone сохраняет отметку об удалении, заменяя прежнюю отметку записи.
+func (t *pgTx) putTombstone(tomb *entity.Tombstone) error {
+	_, err := t.tx.Exec(t.ctx,
+		`INSERT INTO vault_tombstones (owner_id, id, version, deleted_at, seq, expired)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (owner_id, id) DO UPDATE
+		SET version = EXCLUDED.version, deleted_at = EXCLUDED.deleted_at, seq = EXCLUDED.seq,
+			expired = EXCLUDED.expired`,
+		tomb.OwnerID, tomb.ID, tomb.Version, tomb.DeletedAt, tomb.Seq, tomb.Expired,
+	)
+	if err != nil {
+		return fmt.Errorf("tombstone: %w", err)
+	}
+
+	return nil
+}
+
+// keepRevision сохраняет текущую версию записи перед её заменой
+// и удаляет лишние старые версии.
+func (t *pgTx) keepRevision(ownerID, itemID string) error {
+	if t.revisionLimit == 0 {
+		return nil
+	}
+
+	_, err := t.tx.Exec(t.ctx,
+		`INSERT INTO vault_revisions (`+pgRevisionColumns+`)
+		SELECT id, owner_id, type, title, description, meta, username, data,
+			content_hash, content_size, folder_id, tags, attachments, expires_at, version, updated_at, $3
+		FROM vault_items WHERE owner_id = $1 AND id = $2
+		ON CONFLICT (owner_id, id, version) DO NOTHING`,
+		ownerID, itemID, nowPostgres(),
+	)
+	if err != nil {
+		return fmt.Errorf("keep revision: %w", err)
+	}
+
+	_, err = t.tx.Exec(t.ctx,
+		`DELETE FROM vault_revisions
+		WHERE owner_id = $1 AND id = $2 AND version NOT IN (
+			SELECT version FROM vault_revisions
+			WHERE owner_id = $1 AND id = $2
+			ORDER BY version DESC
+			LIMIT $3
+		)`,
+		ownerID, itemID, t.revisionLimit,
+	)
+	if err != nil {
+		return fmt.Errorf("trim revisions: %w", err)
+	}
+
+	return nil
+}
+
+// usage возвращает объём данных пользователя.
+func (t *pgTx) usage(ownerID string) (entity.Usage, error) {
+	usage := entity.Usage{Items: 0, Bytes: 0}
+
+	err := t.tx.QueryRow(t.ctx, pgUsageQuery, ownerID, nowPostgres()).Scan(&usage.Items, &usage.Bytes)
+	if err != nil {
+		return usage, fmt.Errorf("usage: %w", err)
+	}
+
+	return usage, nil
+}
+
+// current возвращает запись или отметку об удалении.
+func (t *pgTx) current(ownerID, itemID string) (*entity.VaultItem, error) {
+	row := t.tx.QueryRow(t.ctx,
+		`SELECT `+pgItemColumns+` FROM vault_items WHERE owner_id = $1 AND id = $2`,
+		ownerID, itemID,
+	)
+
+	item, err := scanPostgresItem(row)
+	if err == nil {
+		return item, nil
+	}
+
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	tomb, err := t.tombstone(ownerID, itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	if tomb == nil {
+		return nil, ErrEntityNotFound
+	}
+
+	return tomb, nil
+}
+
+// tombstone возвращает отметку об удалении записи (nil, если её нет).
+func (t *pgTx) tombstone(ownerID, itemID string) (*entity.VaultItem, error) {
+	tomb := &entity.Tombstone{
+		DeletedAt: time.Time{},
+		ID:        itemID,
+		OwnerID:   ownerID,
+		Version:   0,
+		Seq:       0,
+		Expired:   false,
+	}
+
+	err := t.tx.QueryRow(t.ctx,
+		`SELECT version, deleted_at, seq, expired FROM vault_tombstones WHERE owner_id = $1 AND id = $2`,
+		ownerID, itemID,
+	).Scan(&tomb.Version, &tomb.DeletedAt, &tomb.Seq, &tomb.Expired)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil //nolint:nilnil // отметки нет
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("tombstone: %w", err)
+	}
+
+	tomb.DeletedAt = tomb.DeletedAt.UTC()
+
+	return tomb.AsItem(), nil
+}
+
+// pgSavepointTx выполняет каждую изменяющую операцию в отдельной точке сохранения.
+type pgSavepointTx struct {
+	pgTx
+}
+
+// upsert обновляет или создаёт запись в точке сохранения.
+func (t *pgSavepointTx) upsert(item *entity.VaultItem) (*entity.VaultItem, error) {
+	return t.savepoint(func(sp *pgTx) (*entity.VaultItem, error) {
+		return sp.upsert(item)
+	})
+}
+
+// remove удаляет запись в точке сохранения.
+func (t *pgSavepointTx) remove(ownerID, itemID string, version int64) (*entity.VaultItem, error) {
+	return t.savepoint(func(sp *pgTx) (*entity.VaultItem, error) {
+		return sp.remove(ownerID, itemID, version)
+	})
+}
+
+// savepoint выполняет fn во вложенной транзакции (точке сохранения)
+// и откатывает её изменения при ошибке.
+func (t *pgSavepointTx) savepoint(
+	fn func(sp *pgTx) (*entity.VaultItem, error),
+) (*entity.VaultItem, error) {
+	var res *entity.VaultItem
+
+	err := pgx.BeginFunc(t.ctx, t.tx, func(sp pgx.Tx) error {
+		var err error
+
+		inner := t.pgTx
+		inner.tx = sp
+
+		res, err = fn(&inner)
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("savepoint: %w", err)
+	}
+
+	return res, nil
+}
+
+// buildPostgresItemQuery формирует запрос выборки записей по условиям query.
+//
+// Названия и ID сравниваются побайтово (COLLATE "C"), как в остальных хранилищах.
+func buildPostgresItemQuery(ownerID string, query *entity.ItemQuery) (string, []any) {
+	args := []any{ownerID}
+	arg := func(value any) string {
+		args = append(args, value)
+
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	where := []string{"owner_id = $1", "(expires_at IS NULL OR expires_at > " + arg(nowPostgres()) + ")"}
+
+	if query.Type != "" {
+		where = append(where, "type = "+arg(string(query.Type)))
+	}
+
+	if query.Title != "" {
+		if query.TitleMatch == entity.TitlePrefix {
+			where = append(where,
+				"lower(title) LIKE lower("+arg(escapePostgresLike(query.Title)+"%")+")")
+		} else {
+			where = append(where, "strpos(lower(title), lower("+arg(query.Title)+")) > 0")
+		}
+	}
+
+	if query.MetaKey != "" {
+		if query.MetaValue != "" {
+			where = append(where,
+				"meta @> jsonb_build_object("+arg(query.MetaKey)+"::text, "+arg(query.MetaValue)+"::text)")
+		} else {
+			where = append(where, "meta ? "+arg(query.MetaKey))
+		}
+	}
+
+	if query.FolderID != nil {
+		where = append(where, "folder_id = "+arg(*query.FolderID))
+	}
+
+	if len(query.Tags) > 0 {
+		if query.TagMatch == entity.TagsAll {
+			where = append(where, "tags @> "+arg(query.Tags)+"::text[]")
+		} else {
+			where = append(where, "tags && "+arg(query.Tags)+"::text[]")
+		}
+	}
+
+	sortColumn := `title COLLATE "C"`
+	if query.SortBy == entity.SortByUpdatedAt {
+		sortColumn = "updated_at"
+	}
+
+	direction, compare := "ASC", ">"
+	if query.Desc {
+		direction, compare = "DESC", "<"
+	}
+
+	if after := query.After; after != nil {
+		var sortValue string
+		if query.SortBy == entity.SortByUpdatedAt {
+			sortValue = arg(after.UpdatedAt)
+		} else {
+			sortValue = arg(after.Title) + `::text COLLATE "C"`
+		}
+
+		where = append(where, "("+sortColumn+`, id COLLATE "C") `+compare+
+			" ("+sortValue+", "+arg(after.ID)+`::text COLLATE "C")`)
+	}
+
+	sql := `SELECT ` + pgItemColumns + ` FROM vault_items
+		WHERE ` + strings.Join(where, " AND ") + `
+		ORDER BY ` + sortColumn + " " + direction + `, id COLLATE "C" ` + direction + `
+		LIMIT NULLIF(` + arg(query.Limit) + `, 0)`
+
+	return sql, args
+}
+
+// escapePostgresLike экранирует служебные символы шаблона LIKE.
+func escapePostgresLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+// scanPostgresItem считывает запись из строки результата запроса.
+func scanPostgresItem(row pgx.Row) (*entity.VaultItem, error) {
+	//nolint:exhaustruct // поля заполняются при сканировании
+	item := &entity.VaultItem{}
+
+	var (
+		contentHash string
+		contentSize int64
+	)
+
+	err := row.Scan(
+		&item.ID, &item.OwnerID, &item.Type, &item.Title, &item.Description,
+		&item.Meta, &item.Username, &item.Data, &contentHash, &contentSize, &item.FolderID, &item.Tags,
+		&item.Attachments, &item.ExpiresAt, &item.Version, &item.UpdatedAt, &item.Seq,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("scan: %w", err)
+	}
+
+	item.Content = scannedContent(contentHash, contentSize)
+	item.UpdatedAt = item.UpdatedAt.UTC()
+	item.ExpiresAt = utcPostgresTime(item.ExpiresAt)
+
+	return item, nil
+}
+
+// scanPostgresRevision считывает прежнюю версию записи из строки результата запроса.
+func scanPostgresRevision(row pgx.Row) (*entity.Revision, error) {
+	item, createdAt, err := scanPostgresStamped(row)
+	if err != nil {
+		return nil, err
+	}
+
+	rev := &entity.Revision{
+		CreatedAt: createdAt,
+		Item:      item,
+	}
+
+	return rev, nil
+}
+
+// scanPostgresTrashItem считывает запись в корзине из строки результата запроса.
+func scanPostgresTrashItem(row pgx.Row) (*entity.TrashItem, error) {
+	item, deletedAt, err := scanPostgresStamped(row)
+	if err != nil {
+		return nil, err
+	}
+
+	trashed := &entity.TrashItem{
+		DeletedAt: deletedAt,
+		Item:      item,
+	}
+
+	return trashed, nil
+}
+
+// scanPostgresStamped считывает содержимое записи и отметку времени,
+// выбранные колонками pgRevisionColumns или pgTrashColumns.
+func scanPostgresStamped(row pgx.Row) (*entity.VaultItem, time.Time, error) {
+	//nolint:exhaustruct // поля заполняются при сканировании
+	item := &entity.VaultItem{}
+
+	var (
+		stamp       time.Time
+		contentHash string
+		contentSize int64
+	)
+
+	err := row.Scan(
+		&item.ID, &item.OwnerID, &item.Type, &item.Title, &item.Description,
+		&item.Meta, &item.Username, &item.Data, &contentHash, &contentSize, &item.FolderID, &item.Tags,
+		&item.Attachments, &item.ExpiresAt, &item.Version, &item.UpdatedAt, &stamp,
+	)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("scan: %w", err)
+	}
+
+	item.Content = scannedContent(contentHash, contentSize)
+	item.UpdatedAt = item.UpdatedAt.UTC()
+	item.ExpiresAt = utcPostgresTime(item.ExpiresAt)
+
+	return item, stamp.UTC(), nil
+}
+
+// postgresContent возвращает значения колонок содержимого записи.
+func postgresContent(content *entity.Content) (string, int64) {
+	if content == nil {
+		return "", 0
+	}
+
+	return content.Hash, content.Size
+}
+
+// scannedContent собирает содержимое записи из значений колонок (nil, если его нет).
+func scannedContent(hash string, size int64) *entity.Content {
+	if hash == "" {
+		return nil
+	}
+
+	content := &entity.Content{
+		Hash: hash,
+		Size: size,
+	}
+
+	return content
+}
+
+// collectPostgresItems считывает все записи из результата запроса.
+func collectPostgresItems(rows pgx.Rows) ([]*entity.VaultItem, error) {
+	defer rows.Close()
+
+	res := make([]*entity.VaultItem, 0)
+
+	for rows.Next() {
+		item, err := scanPostgresItem(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows: %w", err)
+	}
+
+	return res, nil
+}
+
+// nextPostgresSeq выдаёт следующий номер изменения пользователя.
+//
+// Строка счётчика блокируется до конца транзакции, поэтому номера одного
+// пользователя выдаются строго по порядку фиксации изменений.
+func nextPostgresSeq(ctx context.Context, tx pgx.Tx, ownerID string) (int64, error) {
+	var seq int64
+
+	err := tx.QueryRow(ctx,
+		`INSERT INTO vault_change_seq (owner_id, seq) VALUES ($1, 1)
+		ON CONFLICT (owner_id) DO UPDATE SET seq = vault_change_seq.seq + 1
+		RETURNING seq`,
+		ownerID,
+	).Scan(&seq)
+	if err != nil {
+		return 0, fmt.Errorf("next seq: %w", err)
+	}
+
+	return seq, nil
+}
+
+// lockPostgresFolder блокирует папку до конца транзакции и проверяет её версию
+// перед изменением (0 - без проверки).
+func lockPostgresFolder(
+	ctx context.Context,
+	tx pgx.Tx,
+	ownerID, folderID string,
+	version int64,
+) (*entity.Folder, error) {
+	rows, err := tx.Query(ctx,
+		`SELECT `+pgFolderColumns+` FROM vault_folders WHERE owner_id = $1 AND id = $2 FOR UPDATE`,
+		ownerID, folderID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := pgx.CollectExactlyOneRow(rows, scanPostgresFolder)
+	if err != nil {
+		return nil, mapPostgresError(err)
+	}
+
+	if err := checkFolderVersion(current, version); err != nil {
+		return nil, err
+	}
+
+	return current, nil
+}
+
+// sharePostgresFolder блокирует папку folderID от удаления до конца транзакции,
+// чтобы DeleteFolder не пропустил переносимую в неё запись или папку.
+// Пустой folderID (корень) и отсутствующая папка не блокируются.
+func sharePostgresFolder(ctx context.Context, tx pgx.Tx, ownerID, folderID string) error {
+	if folderID == "" {
+		return nil
+	}
+
+	_, err := tx.Exec(ctx,
+		`SELECT 1 FROM vault_folders WHERE owner_id = $1 AND id = $2 FOR SHARE`,
+		ownerID, folderID,
+	)
+	if err != nil {
+		return fmt.Errorf("lock folder: %w", err)
+	}
+
+	return nil
+}
+
+// checkPostgresFolderParent проверяет внутри транзакции, что папку folderID можно
+// поместить в папку parentID (см. checkFolderParent), и блокирует родительскую папку
+// от удаления.
+//
+// Строка счётчика изменений владельца блокируется до проверки (после папок, в том же
+// порядке, что и при изменении записей): одновременные переносы папок выполняются
+// по очереди, и проверка видит иерархию с уже зафиксированными переносами.
+func checkPostgresFolderParent(ctx context.Context, tx pgx.Tx, ownerID, folderID, parentID string) error {
+	if parentID == "" {
+		return nil
+	}
+
+	if err := sharePostgresFolder(ctx, tx, ownerID, parentID); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(ctx, `SELECT 1 FROM vault_change_seq WHERE owner_id = $1 FOR UPDATE`, ownerID)
+	if err != nil {
+		return fmt.Errorf("lock seq: %w", err)
+	}
+
+	var found, cycle bool
+
+	// UNION (без ALL) завершает обход и на уже испорченной иерархии с циклом.
+	err = tx.QueryRow(ctx,
+		`WITH RECURSIVE ancestors (id, parent_id) AS (
+			SELECT id, parent_id FROM vault_folders WHERE owner_id = $1 AND id = $2 AND NOT deleted
+			UNION
+			SELECT f.id, f.parent_id FROM vault_folders f
+			JOIN ancestors a ON f.owner_id = $1 AND f.id = a.parent_id AND NOT f.deleted
+		)
+		SELECT EXISTS (SELECT 1 FROM ancestors), EXISTS (SELECT 1 FROM ancestors WHERE id = $3)`,
+		ownerID, parentID, folderID,
+	).Scan(&found, &cycle)
+	if err != nil {
+		return fmt.Errorf("check parent: %w", err)
+	}
+
+	switch {
+	case !found:
+		return fmt.Errorf("%w: %q", ErrUnknownParent, parentID)
+	case cycle:
+		return fmt.Errorf("%w: %q", ErrFolderCycle, folderID)
+	default:
+		return nil
+	}
+}
+
+// checkPostgresFolderEmpty проверяет, что в папке нет действующих записей
+// и не удалённых вложенных папок.
+func checkPostgresFolderEmpty(ctx context.Context, tx pgx.Tx, ownerID, folderID string) error {
+	var hasFolders, hasItems bool
+
+	err := tx.QueryRow(ctx,
+		`SELECT
+			EXISTS (SELECT 1 FROM vault_folders WHERE owner_id = $1 AND parent_id = $2 AND NOT deleted),
+			EXISTS (SELECT 1 FROM vault_items
+				WHERE owner_id = $1 AND folder_id = $2 AND (expires_at IS NULL OR expires_at > $3))`,
+		ownerID, folderID, time.Now(),
+	).Scan(&hasFolders, &hasItems)
+	if err != nil {
+		return fmt.Errorf("check folder: %w", err)
+	}
+
+	switch {
+	case hasFolders:
+		return fmt.Errorf("%w: has subfolders", ErrFolderNotEmpty)
+	case hasItems:
+		return fmt.Errorf("%w: has items", ErrFolderNotEmpty)
+	default:
+		return nil
+	}
+}
+
+// putPostgresFolder выдаёт папке следующий номер изменения владельца и сохраняет её.
+// При replace заменяет прежнее состояние папки, иначе при его наличии
+// возвращает ErrEntityAlreadyExists.
+func putPostgresFolder(ctx context.Context, tx pgx.Tx, folder *entity.Folder, replace bool) error {
+	seq, err := nextPostgresSeq(ctx, tx, folder.OwnerID)
+	if err != nil {
+		return err
+	}
+
+	folder.Seq = seq
+
+	conflict := `DO NOTHING`
+	if replace {
+		conflict = `DO UPDATE SET parent_id = EXCLUDED.parent_id, name = EXCLUDED.name,
+			version = EXCLUDED.version, updated_at = EXCLUDED.updated_at,
+			seq = EXCLUDED.seq, deleted = EXCLUDED.deleted`
+	}
+
+	tag, err := tx.Exec(ctx,
+		`INSERT INTO vault_folders (`+pgFolderColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (owner_id, id) `+conflict,
+		folder.OwnerID, folder.ID, folder.ParentID, folder.Name, folder.Version, folder.UpdatedAt,
+		folder.Seq, folder.Deleted,
+	)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrEntityAlreadyExists
+	}
+
+	return nil
+}
+
+// scanPostgresFolder считывает папку записей из строки результата.
+func scanPostgresFolder(row pgx.CollectableRow) (*entity.Folder, error) {
+	//nolint:exhaustruct // поля заполняются при сканировании
+	folder := &entity.Folder{}
+	err := row.Scan(
+		&folder.OwnerID, &folder.ID, &folder.ParentID, &folder.Name,
+		&folder.Version, &folder.UpdatedAt, &folder.Seq, &folder.Deleted,
+	)
+	folder.UpdatedAt = folder.UpdatedAt.UTC()
+
+	return folder, err
+}
+
+// scanPostgresShare считывает доступ к записи из строки результата.
+func scanPostgresShare(row pgx.CollectableRow) (*entity.Share, error) {
+	share := &entity.Share{
+		CreatedAt:   time.Time{},
+		OwnerID:     "",
+		ItemID:      "",
+		RecipientID: "",
+		Permission:  "",
+		WrappedKey:  nil,
+	}
+
+	var permission string
+
+	err := row.Scan(&share.OwnerID, &share.ItemID, &share.RecipientID, &permission, &share.WrappedKey, &share.CreatedAt)
+	share.Permission = entity.SharePermission(permission)
+	share.CreatedAt = share.CreatedAt.UTC()
+
+	return share, err
+}
+
+// scanPostgresMember считывает участие в организации из строки результата.
+func scanPostgresMember(row pgx.CollectableRow) (*entity.Member, error) {
+	member := &entity.Member{
+		CreatedAt: time.Time{},
+		OrgID:     "",
+		UserID:    "",
+		Role:      "",
+		Status:    "",
+		InvitedBy: "",
+	}
+
+	var role, status string
+
+	err := row.Scan(&member.OrgID, &member.UserID, &role, &status, &member.InvitedBy, &member.CreatedAt)
+	member.Role = entity.OrgRole(role)
+	member.Status = entity.MemberStatus(status)
+	member.CreatedAt = member.CreatedAt.UTC()
+
+	return member, err
+}
+
+// scanPostgresCollection считывает коллекцию организации из строки результата.
+func scanPostgresCollection(row pgx.CollectableRow) (*entity.Collection, error) {
+	coll := &entity.Collection{CreatedAt: time.Time{}, ID: "", OrgID: "", Name: ""}
+	err := row.Scan(&coll.ID, &coll.OrgID, &coll.Name, &coll.CreatedAt)
+	coll.CreatedAt = coll.CreatedAt.UTC()
+
+	return coll, err
+}
+
+// mapPostgresError приводит ошибки PostgreSQL к ошибкам хранилища.
+func mapPostgresError(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrEntityNotFound
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgCodeUniqueViolation {
+		return ErrEntityAlreadyExists
+	}
+
+	return err
+}
+
+// nowPostgres возвращает текущее время с точностью, которую хранит PostgreSQL.
+func nowPostgres() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// utcPostgresTime приводит необязательный момент времени из базы к UTC.
+func utcPostgresTime(moment *time.Time) *time.Time {
+	if moment == nil {
+		return nil
+	}
+
+	utc := moment.UTC()
+
+	return &utc
+}
@@ -4,17 +4,110 @@ package storage
 import (
 	"context"
 	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
 )
 
+// DefaultRevisionLimit - количество хранимых прежних версий записи по умолчанию.
+const DefaultRevisionLimit = 10
+
 // Возможные ошибки при работе с хранилищем.
 var (
 	ErrEntityAlreadyExists = errors.New("entity already exists")
 	ErrEntityNotFound      = errors.New("entity not found")
+	ErrVersionConflict     = errors.New("entity version conflict")
+	ErrInvalidChange       = errors.New("invalid change")
+	ErrQuotaExceeded       = errors.New("quota exceeded")
+	ErrStorageNotEmpty     = errors.New("storage is not empty")
+	ErrInvalidSnapshot     = errors.New("invalid snapshot")
+	ErrFolderNotEmpty      = errors.New("folder is not empty")
+	ErrUnknownParent       = errors.New("unknown parent folder")
+	ErrFolderCycle         = errors.New("folder cannot be moved into itself")
+)
+
+// errItemExpired - срок записи истёк, но фоновая задача её ещё не удалила.
+// Операции записи считают такую запись отсутствующей (ErrEntityNotFound),
+// но не создают на её месте новую.
+var errItemExpired = fmt.Errorf("%w: item expired", ErrEntityNotFound)
+
+// QuotaLimit описывает вид ограничения квоты.
+type QuotaLimit string
+
+const (
+	// QuotaItems - количество записей пользователя.
+	QuotaItems QuotaLimit = "items"
+
+	// QuotaBytes - суммарный размер записей пользователя.
+	QuotaBytes QuotaLimit = "bytes"
+
+	// QuotaItemBytes - размер одной записи.
+	QuotaItemBytes QuotaLimit = "itemBytes"
 )
 
+// QuotaError описывает превышение квоты пользователя.
+//
+// errors.Is(err, ErrQuotaExceeded) выполняется для любой QuotaError.
+type QuotaError struct {
+	Limit     QuotaLimit // превышенное ограничение
+	Max       int64      // значение ограничения
+	Used      int64      // текущее использование (для QuotaItemBytes - 0)
+	Requested int64      // запрошенное увеличение использования
+}
+
+// Error возвращает описание ошибки.
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s: %s limit %d, used %d, requested %d",
+		ErrQuotaExceeded, e.Limit, e.Max, e.Used, e.Requested)
+}
+
+// Is сообщает, что ошибка является ErrQuotaExceeded.
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// checkQuota проверяет, что изменение использования помещается в квоту.
+//
+// Параметры:
+//   - usage: использование до изменения;
+//   - addItems: прирост количества записей (0 или 1);
+//   - addBytes: прирост суммарного размера с содержимым (может быть отрицательным);
+//   - itemSize: размер новой версии записи без содержимого.
+func checkQuota(quota entity.Quota, usage entity.Usage, addItems, addBytes, itemSize int64) error {
+	if quota.MaxItemBytes > 0 && itemSize > quota.MaxItemBytes {
+		return &QuotaError{
+			Limit:     QuotaItemBytes,
+			Max:       quota.MaxItemBytes,
+			Used:      0,
+			Requested: itemSize,
+		}
+	}
+
+	if quota.MaxItems > 0 && addItems > 0 && usage.Items+addItems > quota.MaxItems {
+		return &QuotaError{
+			Limit:     QuotaItems,
+			Max:       quota.MaxItems,
+			Used:      usage.Items,
+			Requested: addItems,
+		}
+	}
+
+	if quota.MaxBytes > 0 && addBytes > 0 && usage.Bytes+addBytes > quota.MaxBytes {
+		return &QuotaError{
+			Limit:     QuotaBytes,
+			Max:       quota.MaxBytes,
+			Used:      usage.Bytes,
+			Requested: addBytes,
+		}
+	}
+
+	return nil
+}
+
 // IUserStorage - интерфейс для всех хранилищ с пользователями.
 type IUserStorage interface {
 	AddNewUser(ctx context.Context, user *entity.User) (string, error)
@@ -25,19 +118,910 @@ type IUserStorage interface {
 	DeleteToken(ctx context.Context, userID string) error
 }
 
+// IKeyStorage - интерфейс для хранилищ ключей данных пользователей.
+type IKeyStorage interface {
+	// GetDataKey возвращает ключ данных владельца.
+	// Если ключа нет, возвращается ErrEntityNotFound.
+	GetDataKey(ctx context.Context, ownerID string) (*entity.DataKey, error)
+
+	// AddDataKey сохраняет ключ данных владельца.
+	// Если ключ уже есть, возвращается ErrEntityAlreadyExists.
+	AddDataKey(ctx context.Context, key *entity.DataKey) error
+
+	// RewrapDataKeys заменяет зашифрованный ключ (MasterKeyID и Wrapped) каждого
+	// ключа данных результатом rewrap (nil - оставить ключ без изменений) в одной
+	// транзакции, если хранилище их поддерживает. Ошибка rewrap отменяет замену.
+	// Возвращает количество заменённых ключей.
+	RewrapDataKeys(
+		ctx context.Context,
+		rewrap func(key *entity.DataKey) (*entity.DataKey, error),
+	) (int, error)
+}
+
+// IShareStorage - интерфейс для хранилищ открытых ключей пользователей
+// и доступа пользователей к чужим записям.
+type IShareStorage interface {
+	// SetPublicKey сохраняет открытый ключ пользователя, заменяя прежний.
+	SetPublicKey(ctx context.Context, key *entity.PublicKey) error
+
+	// GetPublicKey возвращает открытый ключ пользователя.
+	// Если ключа нет, возвращается ErrEntityNotFound.
+	GetPublicKey(ctx context.Context, userID string) (*entity.PublicKey, error)
+
+	// PutShare создаёт доступ получателя к записи или заменяет прежний.
+	PutShare(ctx context.Context, share *entity.Share) error
+
+	// GetShare возвращает доступ получателя к записи владельца.
+	// Если доступа нет, возвращается ErrEntityNotFound.
+	GetShare(ctx context.Context, ownerID, itemID, recipientID string) (*entity.Share, error)
+
+	// ListItemShares возвращает доступы к записи владельца в порядке ID получателей.
+	ListItemShares(ctx context.Context, ownerID, itemID string) ([]*entity.Share, error)
+
+	// ListSharedWith возвращает доступы получателя к чужим записям
+	// в порядке ID владельцев и записей.
+	ListSharedWith(ctx context.Context, recipientID string) ([]*entity.Share, error)
+
+	// DeleteShare удаляет доступ получателя к записи владельца.
+	// Если доступа нет, возвращается ErrEntityNotFound.
+	DeleteShare(ctx context.Context, ownerID, itemID, recipientID string) error
+}
+
+// IOrgStorage - интерфейс для хранилищ организаций, их участников и коллекций.
+//
+// Записи коллекций хранятся в IStorage с владельцем, равным ID коллекции.
+type IOrgStorage interface {
+	// CreateOrg создаёт организацию вместе с участием её создателя.
+	// Если организация с таким ID уже есть, возвращается ErrEntityAlreadyExists.
+	CreateOrg(ctx context.Context, org *entity.Organization, owner *entity.Member) error
+
+	// GetOrg возвращает организацию.
+	// Если организации нет, возвращается ErrEntityNotFound.
+	GetOrg(ctx context.Context, orgID string) (*entity.Organization, error)
+
+	// PutMember создаёт участие пользователя в организации или заменяет прежнее.
+	PutMember(ctx context.Context, member *entity.Member) error
+
+	// GetMember возвращает участие пользователя в организации.
+	// Если пользователь не участвует и не приглашён, возвращается ErrEntityNotFound.
+	GetMember(ctx context.Context, orgID, userID string) (*entity.Member, error)
+
+	// ListMembers возвращает участников и приглашённых организации в порядке ID пользователей.
+	ListMembers(ctx context.Context, orgID string) ([]*entity.Member, error)
+
+	// ListMemberships возвращает участие пользователя в организациях в порядке ID организаций.
+	ListMemberships(ctx context.Context, userID string) ([]*entity.Member, error)
+
+	// DeleteMember удаляет участие пользователя в организации.
+	// Если пользователь не участвует и не приглашён, возвращается ErrEntityNotFound.
+	DeleteMember(ctx context.Context, orgID, userID string) error
+
+	// CreateCollection создаёт коллекцию организации.
+	// Если коллекция с таким ID уже есть, возвращается ErrEntityAlreadyExists.
+	CreateCollection(ctx context.Context, coll *entity.Collection) error
+
+	// GetCollection возвращает коллекцию организации.
+	// Если коллекции нет, возвращается ErrEntityNotFound.
+	GetCollection(ctx context.Context, orgID, collectionID string) (*entity.Collection, error)
+
+	// ListCollections возвращает коллекции организации в порядке ID.
+	ListCollections(ctx context.Context, orgID string) ([]*entity.Collection, error)
+
+	// DeleteCollection удаляет коллекцию организации (но не её записи).
+	// Если коллекции нет, возвращается ErrEntityNotFound.
+	DeleteCollection(ctx context.Context, orgID, collectionID string) error
+}
+
+// IFolderStorage - интерфейс для хранилищ папок записей.
+//
+// Изменения папок получают номера из последовательности изменений владельца
+// (см. IStorage.ListChanges), поэтому клиент забирает их тем же курсором, что и записи.
+// Удалённые папки остаются отметками с признаком Deleted до CompactTombstones.
+type IFolderStorage interface {
+	// CreateFolder создаёт папку с версией 1 и следующим номером изменения, пустой ID заменяется новым.
+	// Если папка с таким ID уже есть (в том числе удалённая), возвращается ErrEntityAlreadyExists.
+	// Если родительской папки нет, возвращается ErrUnknownParent.
+	CreateFolder(ctx context.Context, folder *entity.Folder) error
+
+	// UpdateFolder переименовывает или перемещает папку. Если folder.Version не равен 0,
+	// он должен совпадать с текущей версией папки, иначе возвращается ErrVersionConflict.
+	// Если папки нет, возвращается ErrEntityNotFound, если нет родительской папки - ErrUnknownParent.
+	// Перенос папки в саму себя или во вложенную в неё папку отклоняется с ErrFolderCycle:
+	// проверка иерархии и изменение выполняются одной операцией хранилища.
+	UpdateFolder(ctx context.Context, folder *entity.Folder) error
+
+	// GetFolder возвращает папку. Если папки нет, возвращается ErrEntityNotFound.
+	GetFolder(ctx context.Context, ownerID, folderID string) (*entity.Folder, error)
+
+	// ListFolders возвращает папки владельца в порядке ID.
+	ListFolders(ctx context.Context, ownerID string) ([]*entity.Folder, error)
+
+	// DeleteFolder удаляет папку и оставляет вместо неё отметку об удалении.
+	// Если version не равен 0, он должен совпадать с текущей версией папки,
+	// иначе возвращается ErrVersionConflict. Если в папке есть действующие записи
+	// или вложенные папки, возвращается ErrFolderNotEmpty: проверка и удаление
+	// выполняются одной операцией хранилища.
+	DeleteFolder(ctx context.Context, ownerID, folderID string, version int64) error
+
+	// ListFolderChanges возвращает изменения папок владельца с номером больше afterSeq
+	// в порядке возрастания номера, не более limit папок (0 - без ограничения).
+	// Удалённые папки возвращаются с признаком Deleted.
+	ListFolderChanges(
+		ctx context.Context,
+		ownerID string,
+		afterSeq int64,
+		limit int,
+	) ([]*entity.Folder, error)
+}
+
+// IBackupStorage - интерфейс для хранилищ, поддерживающих резервное копирование.
+type IBackupStorage interface {
+	// Snapshot возвращает согласованный снимок всех данных хранилища: пользователей,
+	// токенов, ключей данных, открытых ключей и доступов к записям, организаций,
+	// папок и записей с отметками об удалении, прежними версиями и корзиной.
+	// Данные считываются в одной транзакции или под одной блокировкой.
+	Snapshot(ctx context.Context) (*entity.Snapshot, error)
+
+	// Restore загружает снимок в пустое хранилище в одной транзакции, если хранилище
+	// их поддерживает. Записи сохраняют версии, номера изменений и время изменения.
+	// Если в хранилище уже есть данные, возвращается ErrStorageNotEmpty.
+	Restore(ctx context.Context, snap *entity.Snapshot) error
+}
+
 // IStorage - интерфейс для всех хранилищ приложения.
 type IStorage interface {
+	// CreateItem создаёт запись. Если для записи есть отметка об удалении, it.Version
+	// должен быть равен 0 или версии отметки, иначе возвращается ErrVersionConflict.
 	CreateItem(ctx context.Context, it *entity.VaultItem) (string, error)
+
+	// UpdateItem обновляет запись. Если it.Version не равен 0, он должен совпадать
+	// с текущей версией записи в хранилище, иначе возвращается ErrVersionConflict.
+	// После обновления it.Version, it.UpdatedAt и it.Seq содержат сохранённые значения.
 	UpdateItem(ctx context.Context, it *entity.VaultItem) error
-	UpsertItem(ctx context.Context, it *entity.VaultItem) (string, error) // удобно для sync
+
+	// UpsertItem обновляет запись или создаёт её при отсутствии (удобно для sync).
+	// Как и в UpdateItem, it.Version, it.UpdatedAt и it.Seq получают сохранённые значения.
+	UpsertItem(ctx context.Context, it *entity.VaultItem) (string, error)
+
+	// GetItem возвращает запись. Записи с истёкшим сроком не выдаются (ErrEntityNotFound),
+	// как и в ListItems и QueryItems.
 	GetItem(ctx context.Context, ownerID, id string) (*entity.VaultItem, error)
 	ListItems(ctx context.Context, ownerID string) ([]*entity.VaultItem, error)
+
+	// QueryItems возвращает записи пользователя, удовлетворяющие условиям query,
+	// в порядке query.SortBy, начиная после позиции query.After,
+	// не более query.Limit записей (0 - без ограничения).
+	QueryItems(
+		ctx context.Context,
+		ownerID string,
+		query *entity.ItemQuery,
+	) ([]*entity.VaultItem, error)
+
+	// ListTags возвращает метки записей пользователя с количеством записей по каждой
+	// в порядке названий меток. Записи с истёкшим сроком не учитываются.
+	ListTags(ctx context.Context, ownerID string) ([]entity.TagCount, error)
+
+	// SetItemContent заменяет содержимое бинарной записи (nil - удаляет ссылку
+	// на содержимое) и возвращает новую версию записи. Если version не равен 0,
+	// он должен совпадать с текущей версией записи, иначе возвращается ErrVersionConflict.
+	//
+	// Прочие операции изменения записи сохраняют её текущее содержимое,
+	// если в записи не указано новое (см. entity.Content).
+	SetItemContent(
+		ctx context.Context,
+		ownerID, itemID string,
+		version int64,
+		content *entity.Content,
+	) (*entity.VaultItem, error)
+
+	// ContentHashes возвращает хеши содержимого, на которое ссылаются записи,
+	// их прежние версии и записи в корзине всех пользователей.
+	ContentHashes(ctx context.Context) ([]string, error)
+
+	// DeleteItem удаляет запись, оставляет вместо неё отметку об удалении
+	// и помещает содержимое записи в корзину.
 	DeleteItem(ctx context.Context, ownerID, id string) error
 
-	// ListChangedSince нужен для синхронизации «что изменилось после T»
-	ListChangedSince(
+	// ListTrash возвращает записи пользователя в корзине, начиная с последних удалённых.
+	ListTrash(ctx context.Context, ownerID string) ([]*entity.TrashItem, error)
+
+	// RestoreTrash восстанавливает запись из корзины как новую версию и возвращает её.
+	// Если записи нет в корзине, возвращается ErrEntityNotFound.
+	RestoreTrash(ctx context.Context, ownerID, itemID string) (*entity.VaultItem, error)
+
+	// EmptyTrash безвозвратно удаляет записи из корзины пользователя вместе с их
+	// прежними версиями и доступами к ним. Возвращает количество удалённых записей.
+	EmptyTrash(ctx context.Context, ownerID string) (int, error)
+
+	// PurgeTrash безвозвратно удаляет записи, помещённые в корзину раньше before,
+	// вместе с их прежними версиями и доступами к ним. Возвращает количество
+	// удалённых записей.
+	PurgeTrash(ctx context.Context, before time.Time) (int, error)
+
+	// ExpireItems безвозвратно удаляет записи всех пользователей, срок которых истёк
+	// к моменту now, вместе с их прежними версиями и доступами к ним, минуя корзину. Вместо записей
+	// остаются отметки об удалении с признаком Expired. Записи с истёкшим сроком
+	// удаляются и из корзины. Возвращает количество удалённых записей.
+	ExpireItems(ctx context.Context, now time.Time) (int, error)
+
+	// GetUsage возвращает объём данных пользователя, учитываемый в квоте.
+	//
+	// Создание и обновление записей, превышающее квоту хранилища,
+	// завершается ошибкой *QuotaError (ErrQuotaExceeded).
+	GetUsage(ctx context.Context, ownerID string) (*entity.Usage, error)
+
+	// ListChanges возвращает изменения пользователя с номером (Seq) больше afterSeq
+	// в порядке возрастания номера, не более limit записей (0 - без ограничения).
+	//
+	// Каждая запись в хранилище получает номер из монотонно возрастающей
+	// последовательности пользователя. Удалённые записи возвращаются с признаком Deleted.
+	ListChanges(
 		ctx context.Context,
 		ownerID string,
-		since time.Time,
+		afterSeq int64,
+		limit int,
 	) ([]*entity.VaultItem, error)
+
+	// ListRevisions возвращает сохранённые прежние версии записи, начиная с последней.
+	// Прежние версии сохраняются при обновлении и удалении записи.
+	ListRevisions(ctx context.Context, ownerID, itemID string) ([]*entity.Revision, error)
+
+	// GetRevision возвращает прежнюю версию записи с указанным номером версии.
+	GetRevision(
+		ctx context.Context,
+		ownerID, itemID string,
+		version int64,
+	) (*entity.Revision, error)
+
+	// CompactRevisions удаляет прежние версии, заменённые раньше before.
+	// Возвращает количество удалённых версий.
+	CompactRevisions(ctx context.Context, before time.Time) (int, error)
+
+	// ApplyChanges применяет пакет изменений пользователя и возвращает результат
+	// для каждого изменения в том же порядке.
+	//
+	// Изменения применяются в одной транзакции, если хранилище их поддерживает.
+	// Конфликт версий или некорректное изменение не прерывают пакет, а отражаются
+	// в результате; прочие ошибки отменяют весь пакет.
+	ApplyChanges(
+		ctx context.Context,
+		ownerID string,
+		changes []*entity.Change,
+	) ([]*entity.ChangeResult, error)
+
+	// CompactTombstones удаляет отметки об удалении записей и папок, созданные
+	// раньше before. Возвращает количество удалённых отметок.
+	CompactTombstones(ctx context.Context, before time.Time) (int, error)
+}
+
+// queryItems отбирает, упорядочивает и ограничивает записи по условиям query.
+//
+// Используется хранилищами, которые не умеют выполнять выборку на своей стороне.
+func queryItems(items []*entity.VaultItem, query *entity.ItemQuery) []*entity.VaultItem {
+	res := make([]*entity.VaultItem, 0, len(items))
+
+	for _, item := range items {
+		if !matchItem(item, query) {
+			continue
+		}
+
+		if query.After != nil && compareItemCursors(entity.CursorOf(item), query.After, query) <= 0 {
+			continue
+		}
+
+		res = append(res, item)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return compareItemCursors(entity.CursorOf(res[i]), entity.CursorOf(res[j]), query) < 0
+	})
+
+	if query.Limit > 0 && len(res) > query.Limit {
+		return res[:query.Limit]
+	}
+
+	return res
+}
+
+// countTags подсчитывает записи по меткам и упорядочивает метки по названию.
+//
+// Используется хранилищами, которые не умеют группировать записи на своей стороне.
+func countTags(items []*entity.VaultItem) []entity.TagCount {
+	counts := make(map[string]int64)
+
+	for _, item := range items {
+		for _, tag := range item.Tags {
+			counts[tag]++
+		}
+	}
+
+	tags := make([]entity.TagCount, 0, len(counts))
+	for name, count := range counts {
+		tags = append(tags, entity.TagCount{Name: name, Count: count})
+	}
+
+	slices.SortFunc(tags, func(left, right entity.TagCount) int {
+		return strings.Compare(left.Name, right.Name)
+	})
+
+	return tags
+}
+
+// matchItem проверяет, что запись удовлетворяет фильтрам query.
+func matchItem(item *entity.VaultItem, query *entity.ItemQuery) bool {
+	if query.Type != "" && item.Type != query.Type {
+		return false
+	}
+
+	if query.Title != "" {
+		title := strings.ToLower(item.Title)
+		pattern := strings.ToLower(query.Title)
+
+		if query.TitleMatch == entity.TitlePrefix {
+			if !strings.HasPrefix(title, pattern) {
+				return false
+			}
+		} else if !strings.Contains(title, pattern) {
+			return false
+		}
+	}
+
+	if query.MetaKey != "" {
+		value, ok := item.Meta[query.MetaKey]
+		if !ok || (query.MetaValue != "" && value != query.MetaValue) {
+			return false
+		}
+	}
+
+	if query.FolderID != nil && item.FolderID != *query.FolderID {
+		return false
+	}
+
+	return matchTags(item.Tags, query)
+}
+
+// matchTags проверяет, что метки записи подходят под метки выборки query.
+func matchTags(tags []string, query *entity.ItemQuery) bool {
+	if len(query.Tags) == 0 {
+		return true
+	}
+
+	for _, tag := range query.Tags {
+		has := slices.Contains(tags, tag)
+
+		if has && query.TagMatch != entity.TagsAll {
+			return true
+		}
+
+		if !has && query.TagMatch == entity.TagsAll {
+			return false
+		}
+	}
+
+	return query.TagMatch == entity.TagsAll
+}
+
+// compareItemCursors сравнивает позиции записей в порядке выборки query.
+func compareItemCursors(left, right *entity.ItemCursor, query *entity.ItemQuery) int {
+	var res int
+
+	switch query.SortBy {
+	case entity.SortByUpdatedAt:
+		res = left.UpdatedAt.Compare(right.UpdatedAt)
+	case entity.SortByTitle:
+		res = strings.Compare(left.Title, right.Title)
+	default:
+		res = strings.Compare(left.Title, right.Title)
+	}
+
+	if res == 0 {
+		res = strings.Compare(left.ID, right.ID)
+	}
+
+	if query.Desc {
+		return -res
+	}
+
+	return res
+}
+
+// limitChanges упорядочивает изменения по номеру и оставляет не более limit первых (0 - все).
+func limitChanges(changes []*entity.VaultItem, limit int) []*entity.VaultItem {
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Seq < changes[j].Seq
+	})
+
+	if limit > 0 && len(changes) > limit {
+		return changes[:limit]
+	}
+
+	return changes
+}
+
+// limitFolderChanges упорядочивает изменения папок по номеру
+// и оставляет не более limit первых (0 - все).
+func limitFolderChanges(folders []*entity.Folder, limit int) []*entity.Folder {
+	sort.Slice(folders, func(i, j int) bool {
+		return folders[i].Seq < folders[j].Seq
+	})
+
+	if limit > 0 && len(folders) > limit {
+		return folders[:limit]
+	}
+
+	return folders
+}
+
+// sortFolders упорядочивает папки по ID.
+func sortFolders(folders []*entity.Folder) []*entity.Folder {
+	sort.Slice(folders, func(i, j int) bool {
+		return folders[i].ID < folders[j].ID
+	})
+
+	return folders
+}
+
+// checkFolderVersion проверяет версию папки перед изменением (0 - без проверки).
+func checkFolderVersion(current *entity.Folder, version int64) error {
+	if current.Deleted {
+		return ErrEntityNotFound
+	}
+
+	if version != 0 && version != current.Version {
+		return ErrVersionConflict
+	}
+
+	return nil
+}
+
+// checkFolderParent проверяет, что папку folderID можно поместить в папку parentID
+// (пусто - в корень): среди не удалённых folders есть parentID, и это не folderID
+// и не вложенная в неё папка.
+func checkFolderParent(folderID, parentID string, folders []*entity.Folder) error {
+	if parentID == "" {
+		return nil
+	}
+
+	parents := make(map[string]string, len(folders))
+
+	for _, folder := range folders {
+		if !folder.Deleted {
+			parents[folder.ID] = folder.ParentID
+		}
+	}
+
+	if _, ok := parents[parentID]; !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownParent, parentID)
+	}
+
+	// Число шагов ограничено числом папок на случай уже испорченной иерархии.
+	for id, steps := parentID, 0; id != "" && steps <= len(parents); id, steps = parents[id], steps+1 {
+		if id == folderID {
+			return fmt.Errorf("%w: %q", ErrFolderCycle, folderID)
+		}
+	}
+
+	return nil
+}
+
+// checkFolderEmpty возвращает ErrFolderNotEmpty, если среди folders есть не удалённая
+// вложенная папка folderID или среди items - действующая запись из неё.
+func checkFolderEmpty(folderID string, folders []*entity.Folder, items []*entity.VaultItem, now time.Time) error {
+	for _, folder := range folders {
+		if !folder.Deleted && folder.ParentID == folderID {
+			return fmt.Errorf("%w: has subfolder %q", ErrFolderNotEmpty, folder.ID)
+		}
+	}
+
+	for _, item := range items {
+		if item.FolderID == folderID && !item.IsExpired(now) {
+			return fmt.Errorf("%w: has items", ErrFolderNotEmpty)
+		}
+	}
+
+	return nil
+}
+
+// deletedFolder возвращает отметку об удалении папки с новой версией и номером изменения.
+func deletedFolder(current *entity.Folder, seq int64, deletedAt time.Time) *entity.Folder {
+	return &entity.Folder{
+		ID:        current.ID,
+		OwnerID:   current.OwnerID,
+		ParentID:  "",
+		Name:      "",
+		Version:   current.Version + 1,
+		UpdatedAt: deletedAt,
+		Seq:       seq,
+		Deleted:   true,
+	}
+}
+
+// sortTrash упорядочивает записи корзины от последних удалённых к более ранним.
+func sortTrash(items []*entity.TrashItem) []*entity.TrashItem {
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].DeletedAt.After(items[j].DeletedAt)
+	})
+
+	return items
+}
+
+// restoredItem подготавливает запись из корзины к повторному созданию.
+//
+// Версия сбрасывается, чтобы запись получила версию после отметки об удалении.
+func restoredItem(trashed *entity.TrashItem, ownerID string) *entity.VaultItem {
+	item := *trashed.Item
+	item.OwnerID = ownerID
+	item.Version = 0
+	item.Seq = 0
+	item.Deleted = false
+
+	return &item
+}
+
+// resolveContent возвращает содержимое записи после изменения:
+// next == nil сохраняет текущее содержимое, пустой next.Hash удаляет его.
+func resolveContent(next, current *entity.Content) *entity.Content {
+	if next == nil {
+		return current
+	}
+
+	if next.Hash == "" {
+		return nil
+	}
+
+	content := *next
+
+	return &content
+}
+
+// resolveAttachments возвращает вложения записи после обновления:
+// nil сохраняет текущие вложения, пустой список - удаляет их.
+func resolveAttachments(next, current []entity.Attachment) []entity.Attachment {
+	if next == nil {
+		return current
+	}
+
+	if len(next) == 0 {
+		return nil
+	}
+
+	return slices.Clone(next)
+}
+
+// updatedItem возвращает запись после обновления с учётом сохраняемых содержимого и вложений.
+func updatedItem(item *entity.VaultItem, content *entity.Content, atts []entity.Attachment) *entity.VaultItem {
+	next := *item
+	next.Content = resolveContent(item.Content, content)
+	next.Attachments = resolveAttachments(item.Attachments, atts)
+
+	return &next
+}
+
+// liveItems убирает из списка записи, срок которых истёк к моменту now.
+func liveItems(items []*entity.VaultItem, now time.Time) []*entity.VaultItem {
+	return slices.DeleteFunc(items, func(item *entity.VaultItem) bool {
+		return item.IsExpired(now)
+	})
+}
+
+// setStoredState переносит в item версию, время изменения и номер изменения,
+// с которыми хранилище сохранило запись stored.
+func setStoredState(item, stored *entity.VaultItem) {
+	item.Version = stored.Version
+	item.UpdatedAt = stored.UpdatedAt
+	item.Seq = stored.Seq
+}
+
+// checkNotExpired возвращает errItemExpired, если срок записи expiresAt истёк к моменту now.
+func checkNotExpired(expiresAt *time.Time, now time.Time) error {
+	if expiresAt != nil && !expiresAt.After(now) {
+		return errItemExpired
+	}
+
+	return nil
+}
+
+// contentUpdate подготавливает обновление записи, заменяющее только её содержимое.
+func contentUpdate(current *entity.VaultItem, version int64, content *entity.Content) *entity.VaultItem {
+	item := *current
+	item.Version = version
+	item.Content = &entity.Content{Hash: "", Size: 0}
+
+	if content != nil {
+		item.Content = content
+	}
+
+	return &item
+}
+
+// collectContentHash добавляет хеши содержимого и вложений записи в набор.
+func collectContentHash(hashes map[string]struct{}, item *entity.VaultItem) {
+	if item == nil {
+		return
+	}
+
+	if item.Content != nil {
+		hashes[item.Content.Hash] = struct{}{}
+	}
+
+	for _, att := range item.Attachments {
+		hashes[att.Hash] = struct{}{}
+	}
+}
+
+// sortedHashes возвращает хеши набора в порядке возрастания.
+func sortedHashes(hashes map[string]struct{}) []string {
+	res := make([]string, 0, len(hashes))
+
+	for hash := range hashes {
+		res = append(res, hash)
+	}
+
+	sort.Strings(res)
+
+	return res
+}
+
+// snapshotBuilder собирает данные пользователей для снимка хранилища.
+type snapshotBuilder struct {
+	snap   *entity.Snapshot
+	vaults map[string]*entity.VaultSnapshot // ownerID -> данные пользователя
+}
+
+// newSnapshotBuilder создаёт пустой снимок хранилища.
+func newSnapshotBuilder() *snapshotBuilder {
+	return &snapshotBuilder{
+		snap: &entity.Snapshot{
+			Users:  make([]*entity.User, 0),
+			Tokens: make([]string, 0),
+			Keys:   make([]*entity.DataKey, 0),
+
+			PublicKeys: make([]*entity.PublicKey, 0),
+			Shares:     make([]*entity.Share, 0),
+
+			Orgs:        make([]*entity.Organization, 0),
+			Members:     make([]*entity.Member, 0),
+			Collections: make([]*entity.Collection, 0),
+
+			Vaults: make([]*entity.VaultSnapshot, 0),
+		},
+		vaults: make(map[string]*entity.VaultSnapshot),
+	}
+}
+
+// vault возвращает данные пользователя в снимке, добавляя их при отсутствии.
+func (s *snapshotBuilder) vault(ownerID string) *entity.VaultSnapshot {
+	vault, ok := s.vaults[ownerID]
+	if !ok {
+		vault = &entity.VaultSnapshot{
+			OwnerID:    ownerID,
+			Seq:        0,
+			Items:      make([]*entity.VaultItem, 0),
+			Tombstones: make([]*entity.Tombstone, 0),
+			Revisions:  make([]*entity.Revision, 0),
+			Trash:      make([]*entity.TrashItem, 0),
+			Folders:    make([]*entity.Folder, 0),
+		}
+		s.vaults[ownerID] = vault
+		s.snap.Vaults = append(s.snap.Vaults, vault)
+	}
+
+	return vault
+}
+
+// build упорядочивает данные снимка, чтобы снимки одинаковых данных совпадали.
+func (s *snapshotBuilder) build() *entity.Snapshot {
+	snap := s.snap
+
+	sort.Slice(snap.Users, func(i, j int) bool { return snap.Users[i].ID < snap.Users[j].ID })
+	sort.Strings(snap.Tokens)
+	sort.Slice(snap.Keys, func(i, j int) bool { return snap.Keys[i].OwnerID < snap.Keys[j].OwnerID })
+	sort.Slice(snap.PublicKeys, func(i, j int) bool { return snap.PublicKeys[i].UserID < snap.PublicKeys[j].UserID })
+	sortShares(snap.Shares)
+	sort.Slice(snap.Orgs, func(i, j int) bool { return snap.Orgs[i].ID < snap.Orgs[j].ID })
+	sortMembers(snap.Members)
+	sort.Slice(snap.Collections, func(i, j int) bool {
+		left, right := snap.Collections[i], snap.Collections[j]
+		if left.OrgID != right.OrgID {
+			return left.OrgID < right.OrgID
+		}
+
+		return left.ID < right.ID
+	})
+	sort.Slice(snap.Vaults, func(i, j int) bool { return snap.Vaults[i].OwnerID < snap.Vaults[j].OwnerID })
+
+	for _, vault := range snap.Vaults {
+		sort.Slice(vault.Items, func(i, j int) bool { return vault.Items[i].ID < vault.Items[j].ID })
+		sort.Slice(vault.Tombstones, func(i, j int) bool {
+			return vault.Tombstones[i].ID < vault.Tombstones[j].ID
+		})
+		sort.Slice(vault.Revisions, func(i, j int) bool {
+			left, right := vault.Revisions[i].Item, vault.Revisions[j].Item
+			if left.ID != right.ID {
+				return left.ID < right.ID
+			}
+
+			return left.Version < right.Version
+		})
+		sort.Slice(vault.Trash, func(i, j int) bool { return vault.Trash[i].Item.ID < vault.Trash[j].Item.ID })
+		sortFolders(vault.Folders)
+	}
+
+	return snap
+}
+
+// restoredVault проверяет данные пользователя из снимка и проставляет в них владельца.
+// Возвращает номер изменения, с которого продолжится последовательность пользователя.
+func restoredVault(vault *entity.VaultSnapshot) (int64, error) {
+	if vault.OwnerID == "" {
+		return 0, fmt.Errorf("%w: vault without owner", ErrInvalidSnapshot)
+	}
+
+	seq := vault.Seq
+
+	for _, item := range vault.Items {
+		if item.ID == "" {
+			return 0, fmt.Errorf("%w: item without id", ErrInvalidSnapshot)
+		}
+
+		item.OwnerID = vault.OwnerID
+		item.Deleted = false
+		seq = max(seq, item.Seq)
+	}
+
+	for _, tomb := range vault.Tombstones {
+		tomb.OwnerID = vault.OwnerID
+		seq = max(seq, tomb.Seq)
+	}
+
+	for _, rev := range vault.Revisions {
+		if rev.Item == nil {
+			return 0, fmt.Errorf("%w: empty revision", ErrInvalidSnapshot)
+		}
+
+		rev.Item.OwnerID = vault.OwnerID
+	}
+
+	for _, trashed := range vault.Trash {
+		if trashed.Item == nil {
+			return 0, fmt.Errorf("%w: empty trash item", ErrInvalidSnapshot)
+		}
+
+		trashed.Item.OwnerID = vault.OwnerID
+	}
+
+	for _, folder := range vault.Folders {
+		if folder.ID == "" {
+			return 0, fmt.Errorf("%w: folder without id", ErrInvalidSnapshot)
+		}
+
+		folder.OwnerID = vault.OwnerID
+		seq = max(seq, folder.Seq)
+	}
+
+	return seq, nil
+}
+
+// changeApplier выполняет операции пакета синхронизации в рамках одной транзакции хранилища.
+type changeApplier interface {
+	// upsert создаёт или обновляет запись и возвращает её новое состояние.
+	upsert(item *entity.VaultItem) (*entity.VaultItem, error)
+
+	// remove удаляет запись и возвращает отметку об удалении (nil, если записи не было).
+	remove(ownerID, itemID string, version int64) (*entity.VaultItem, error)
+
+	// current возвращает текущее состояние записи или отметку об удалении.
+	current(ownerID, itemID string) (*entity.VaultItem, error)
+}
+
+// applyChanges применяет пакет изменений через applier и формирует результаты.
+func applyChanges(
+	ownerID string,
+	changes []*entity.Change,
+	applier changeApplier,
+) ([]*entity.ChangeResult, error) {
+	results := make([]*entity.ChangeResult, 0, len(changes))
+
+	for _, change := range changes {
+		item := *change.Item
+		item.OwnerID = ownerID
+
+		var (
+			state *entity.VaultItem
+			err   error
+		)
+
+		switch {
+		case change.Invalid != nil:
+			err = fmt.Errorf("%w: %w", ErrInvalidChange, change.Invalid)
+		case change.Op == entity.ChangeUpsert:
+			state, err = applier.upsert(&item)
+		case change.Op == entity.ChangeDelete:
+			if item.ID == "" {
+				err = fmt.Errorf("%w: empty id", ErrInvalidChange)
+
+				break
+			}
+
+			state, err = applier.remove(ownerID, item.ID, item.Version)
+		default:
+			err = fmt.Errorf("%w: unknown operation %q", ErrInvalidChange, change.Op)
+		}
+
+		result := &entity.ChangeResult{
+			Item:   state,
+			ID:     item.ID,
+			Error:  "",
+			Status: entity.ChangeApplied,
+		}
+
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrVersionConflict):
+			current, curErr := applier.current(ownerID, item.ID)
+			if curErr != nil {
+				return nil, curErr
+			}
+
+			result.Item = current
+			result.Error = err.Error()
+			result.Status = entity.ChangeConflict
+		case errors.Is(err, ErrInvalidChange),
+			errors.Is(err, ErrEntityAlreadyExists),
+			errors.Is(err, ErrEntityNotFound),
+			errors.Is(err, ErrQuotaExceeded):
+			result.Error = err.Error()
+			result.Status = entity.ChangeRejected
+		default:
+			return nil, fmt.Errorf("change %s: %w", item.ID, err)
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// sortShares упорядочивает доступы к записям по владельцу, записи и получателю.
+func sortShares(shares []*entity.Share) {
+	sort.Slice(shares, func(i, j int) bool {
+		left, right := shares[i], shares[j]
+		if left.OwnerID != right.OwnerID {
+			return left.OwnerID < right.OwnerID
+		}
+
+		if left.ItemID != right.ItemID {
+			return left.ItemID < right.ItemID
+		}
+
+		return left.RecipientID < right.RecipientID
+	})
+}
+
+// copyShare копирует доступ к записи.
+func copyShare(share *entity.Share) *entity.Share {
+	res := *share
+	res.WrappedKey = append([]byte(nil), share.WrappedKey...)
+
+	return &res
+}
+
+// copyPublicKey копирует открытый ключ пользователя.
+func copyPublicKey(key *entity.PublicKey) *entity.PublicKey {
+	res := *key
+	res.Key = append([]byte(nil), key.Key...)
+
+	return &res
+}
+
+// sortMembers упорядочивает участие пользователей по ID организаций и пользователей.
+func sortMembers(members []*entity.Member) {
+	sort.Slice(members, func(i, j int) bool {
+		left, right := members[i], members[j]
+		if left.OrgID != right.OrgID {
+			return left.OrgID < right.OrgID
+		}
+
+		return left.UserID < right.UserID
+	})
 }
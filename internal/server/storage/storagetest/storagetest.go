@@ -0,0 +1,749 @@
+// Package storagetest предоставляет набор тестов соответствия для реализаций хранилища.
+//
+// Набор проверяет общий для всех хранилищ контракт IUserStorage и IStorage:
+// ошибки ErrEntityNotFound, ErrEntityAlreadyExists и ErrVersionConflict,
+// версии записей, ленту изменений ListChanges, подсчёт меток ListTags, записи с истёкшим сроком и их учёт в квоте,
+// изоляцию данных пользователей и одновременный доступ. Новое хранилище подключается вызовом Run из его тестов.
+// RunRestore проверяет отказ восстановления из снимка в хранилище с любыми данными.
+package storagetest
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage"
+	"github.com/mr-filatik/go-goph-keeper/internal/server/storage/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// concurrency - количество одновременных операций в тестах одновременного доступа.
+const concurrency = 16
+
+// Storage - хранилище, проверяемое набором тестов.
+type Storage interface {
+	storage.IUserStorage
+	storage.IStorage
+}
+
+// Factory создаёт хранилище для одного теста набора.
+//
+// Хранилище может быть общим для нескольких тестов (например, одна база данных):
+// тесты используют уникальные ID пользователей и email и не зависят от чужих данных.
+// Закрытие хранилища регистрируется фабрикой через t.Cleanup.
+type Factory func(t *testing.T) Storage
+
+// Run запускает набор тестов соответствия для хранилища, создаваемого newStorage.
+func Run(t *testing.T, newStorage Factory) {
+	t.Helper()
+
+	tests := []struct {
+		run  func(t *testing.T, stor Storage)
+		name string
+	}{
+		{run: testUsers, name: "Users"},
+		{run: testTokens, name: "Tokens"},
+		{run: testItemErrors, name: "ItemErrors"},
+		{run: testVersioning, name: "Versioning"},
+		{run: testListChanges, name: "ListChanges"},
+		{run: testApplyChanges, name: "ApplyChanges"},
+		{run: testExpiredWrites, name: "ExpiredWrites"},
+		{run: testExpiredUsage, name: "ExpiredUsage"},
+		{run: testTags, name: "Tags"},
+		{run: testOwnerIsolation, name: "OwnerIsolation"},
+		{run: testConcurrentCreate, name: "ConcurrentCreate"},
+		{run: testConcurrentUpdate, name: "ConcurrentUpdate"},
+		{run: testConcurrentTokens, name: "ConcurrentTokens"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			test.run(t, newStorage(t))
+		})
+	}
+}
+
+// newOwner возвращает уникальный ID пользователя.
+func newOwner() string {
+	return uuid.New().String()
+}
+
+// newEmail возвращает уникальный email.
+func newEmail() string {
+	return uuid.New().String() + "@example.com"
+}
+
+// newItem возвращает новую запись пользователя с уникальным ID.
+func newItem(ownerID, title string) *entity.VaultItem {
+	//nolint:exhaustruct // остальные поля заполняет хранилище
+	return &entity.VaultItem{
+		ID:      uuid.New().String(),
+		OwnerID: ownerID,
+		Type:    entity.ItemLogin,
+		Title:   title,
+		Meta:    map[string]string{"site": "example.com"},
+	}
+}
+
+// createItem создаёт запись и возвращает её состояние в хранилище.
+func createItem(t *testing.T, stor Storage, ownerID, title string) *entity.VaultItem {
+	t.Helper()
+
+	ctx := context.Background()
+
+	itemID, err := stor.CreateItem(ctx, newItem(ownerID, title))
+	require.NoError(t, err)
+
+	got, err := stor.GetItem(ctx, ownerID, itemID)
+	require.NoError(t, err)
+
+	return got
+}
+
+/*
+	===== Users =====
+*/
+
+func testUsers(t *testing.T, stor Storage) {
+	t.Helper()
+
+	ctx := context.Background()
+	email := newEmail()
+	user := entity.NewUser("User-"+email, "hash")
+
+	userID, err := stor.AddNewUser(ctx, user)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, userID)
+
+	_, err = stor.AddNewUser(ctx, entity.NewUser("user-"+email, "other"))
+	require.ErrorIs(t, err, storage.ErrEntityAlreadyExists, "email is case-insensitive")
+
+	found, err := stor.FindUserByEmail(ctx, "USER-"+email)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, found.ID)
+	assert.Equal(t, "hash", found.PasswordHash)
+
+	_, err = stor.FindUserByEmail(ctx, newEmail())
+	require.ErrorIs(t, err, storage.ErrEntityNotFound)
+}
+
+/*
+	===== Tokens =====
+*/
+
+func testTokens(t *testing.T, stor Storage) {
+	t.Helper()
+
+	ctx := context.Background()
+	userID := newOwner()
+
+	assert.False(t, stor.IsTokenByUserID(ctx, userID))
+
+	_, err := stor.AddNewToken(ctx, userID, &entity.Token{})
+	require.NoError(t, err)
+	assert.True(t, stor.IsTokenByUserID(ctx, userID))
+
+	_, err = stor.AddNewToken(ctx, userID, &entity.Token{})
+	require.ErrorIs(t, err, storage.ErrEntityAlreadyExists)
+
+	require.NoError(t, stor.DeleteToken(ctx, userID))
+	assert.False(t, stor.IsTokenByUserID(ctx, userID))
+	require.NoError(t, stor.DeleteToken(ctx, userID), "deleting a missing token is not an error")
+}
+
+/*
+	===== Item errors =====
+*/
+
+func testItemErrors(t *testing.T, stor Storage) {
+	t.Helper()
+
+	ctx := context.Background()
+	ownerID := newOwner()
+	item := createItem(t, stor, ownerID, "Email")
+
+	_, err := stor.CreateItem(ctx, item)
+	require.ErrorIs(t, err, storage.ErrEntityAlreadyExists)
+
+	_, err = stor.GetItem(ctx, ownerID, uuid.New().String())
+	require.ErrorIs(t, err, storage.ErrEntityNotFound)
+
+	missing := newItem(ownerID, "Missing")
+	require.ErrorIs(t, stor.UpdateItem(ctx, missing), storage.ErrEntityNotFound)
+
+	_, err = stor.RestoreTrash(ctx, ownerID, missing.ID)
+	require.ErrorIs(t, err, storage.ErrEntityNotFound)
+
+	_, err = stor.GetRevision(ctx, ownerID, item.ID, item.Version+1)
+	require.ErrorIs(t, err, storage.ErrEntityNotFound)
+
+	require.NoError(t, stor.DeleteItem(ctx, ownerID, missing.ID), "deleting a missing item is not an error")
+
+	list, err := stor.ListItems(ctx, ownerID)
+	require.NoError(t, err)
+	assert.Len(t, list, 1)
+}
+
+/*
+	===== Versioning =====
+*/
+
+func testVersioning(t *testing.T, stor Storage) {
+	t.Helper()
+
+	ctx := context.Background()
+	ownerID := newOwner()
+	item := createItem(t, stor, ownerID, "Email")
+	assert.Equal(t, int64(1), item.Version)
+	assert.Equal(t, ownerID, item.OwnerID)
+	assert.False(t, item.UpdatedAt.IsZero())
+
+	first := *item
+	first.Title = "First"
+	require.NoError(t, stor.UpdateItem(ctx, &first))
+	assert.Equal(t, int64(2), first.Version, "update reports the stored version")
+	assert.Greater(t, first.Seq, item.Seq)
+
+	stale := *item
+	stale.Title = "Stale"
+	require.ErrorIs(t, stor.UpdateItem(ctx, &stale), storage.ErrVersionConflict)
+
+	_, err := stor.UpsertItem(ctx, &stale)
+	require.ErrorIs(t, err, storage.ErrVersionConflict)
+
+	force := *item
+	force.Title = "Force"
+	force.Version = 0
+	require.NoError(t, stor.UpdateItem(ctx, &force), "version 0 skips the check")
+
+	got, err := stor.GetItem(ctx, ownerID, item.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Force", got.Title)
+	assert.Equal(t, int64(3), got.Version)
+	assert.Equal(t, got.Version, force.Version)
+	assert.Equal(t, got.Seq, force.Seq)
+
+	revisions, err := stor.ListRevisions(ctx, ownerID, item.ID)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, int64(2), revisions[0].Item.Version, "latest revision first")
+
+	require.NoError(t, stor.DeleteItem(ctx, ownerID, item.ID))
+
+	_, err = stor.GetItem(ctx, ownerID, item.ID)
+	require.ErrorIs(t, err, storage.ErrEntityNotFound)
+
+	recreate := newItem(ownerID, "Recreated")
+	recreate.ID = item.ID
+	recreate.Version = 3
+
+	_, err = stor.CreateItem(ctx, recreate)
+	require.ErrorIs(t, err, storage.ErrVersionConflict, "stale version of the tombstone")
+
+	recreate.Version = 4
+	_, err = stor.CreateItem(ctx, recreate)
+	require.NoError(t, err)
+
+	got, err = stor.GetItem(ctx, ownerID, item.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Recreated", got.Title)
+	assert.Equal(t, int64(5), got.Version, "version continues after the tombstone")
+}
+
+/*
+	===== ListChanges =====
+*/
+
+func testListChanges(t *testing.T, stor Storage) {
+	t.Helper()
+
+	ctx := context.Background()
+	ownerID := newOwner()
+
+	empty, err := stor.ListChanges(ctx, ownerID, 0, 0)
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+
+	first := createItem(t, stor, ownerID, "First")
+	second := createItem(t, stor, ownerID, "Second")
+	third := createItem(t, stor, ownerID, "Third")
+
+	updated := *first
+	updated.Title = "First (new)"
+	require.NoError(t, stor.UpdateItem(ctx, &updated))
+	require.NoError(t, stor.DeleteItem(ctx, ownerID, second.ID))
+
+	changes, err := stor.ListChanges(ctx, ownerID, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, changes, 3, "one change per item")
+	assert.Equal(t, third.ID, changes[0].ID)
+	assert.Equal(t, first.ID, changes[1].ID)
+	assert.Equal(t, "First (new)", changes[1].Title)
+	assert.Equal(t, second.ID, changes[2].ID)
+	assert.True(t, changes[2].Deleted)
+	assert.Equal(t, int64(2), changes[2].Version)
+
+	for i := 1; i < len(changes); i++ {
+		assert.Greater(t, changes[i].Seq, changes[i-1].Seq, "changes are ordered by seq")
+	}
+
+	after, err := stor.ListChanges(ctx, ownerID, changes[0].Seq, 0)
+	require.NoError(t, err)
+	assert.Equal(t, changes[1:], after)
+
+	page, err := stor.ListChanges(ctx, ownerID, 0, 2)
+	require.NoError(t, err)
+	assert.Equal(t, changes[:2], page)
+
+	last, err := stor.ListChanges(ctx, ownerID, changes[2].Seq, 0)
+	require.NoError(t, err)
+	assert.Empty(t, last)
+}
+
+/*
+	===== ApplyChanges =====
+*/
+
+func testApplyChanges(t *testing.T, stor Storage) {
+	t.Helper()
+
+	ctx := context.Background()
+	ownerID := newOwner()
+	existing := createItem(t, stor, ownerID, "Existing")
+	created := newItem(ownerID, "Created")
+
+	stale := *existing
+	stale.Version = 5
+
+	//nolint:exhaustruct // для удаления достаточно ID и Version
+	deletion := &entity.VaultItem{ID: existing.ID, Version: existing.Version}
+
+	//nolint:exhaustruct // изменения прошли проверку, Invalid не нужен
+	results, err := stor.ApplyChanges(ctx, ownerID, []*entity.Change{
+		{Item: created, Op: entity.ChangeUpsert},
+		{Item: &stale, Op: entity.ChangeUpsert},
+		{Item: deletion, Op: entity.ChangeDelete},
+		{Item: &entity.VaultItem{}, Op: "unknown"}, //nolint:exhaustruct // некорректное изменение
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	assert.Equal(t, entity.ChangeApplied, results[0].Status)
+	assert.Equal(t, created.ID, results[0].ID)
+	assert.Equal(t, int64(1), results[0].Item.Version)
+
+	assert.Equal(t, entity.ChangeConflict, results[1].Status)
+	assert.Equal(t, existing.Version, results[1].Item.Version, "conflict returns the current state")
+
+	assert.Equal(t, entity.ChangeApplied, results[2].Status)
+	assert.True(t, results[2].Item.Deleted)
+
+	assert.Equal(t, entity.ChangeRejected, results[3].Status)
+
+	list, err := stor.ListItems(ctx, ownerID)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, created.ID, list[0].ID)
+}
+
+/*
+	===== Expired items =====
+*/
+
+func testExpiredWrites(t *testing.T, stor Storage) {
+	t.Helper()
+
+	ctx := context.Background()
+	ownerID := newOwner()
+	item := createItem(t, stor, ownerID, "OTP")
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+
+	item.ExpiresAt = &past
+	require.NoError(t, stor.UpdateItem(ctx, item))
+
+	revived := *item
+	revived.Version = 0
+	revived.ExpiresAt = &future
+
+	require.ErrorIs(t, stor.UpdateItem(ctx, &revived), storage.ErrEntityNotFound)
+
+	_, err := stor.UpsertItem(ctx, &revived)
+	require.ErrorIs(t, err, storage.ErrEntityNotFound, "expired item is not recreated before it is reaped")
+
+	_, err = stor.SetItemContent(ctx, ownerID, item.ID, 0, &entity.Content{Hash: strings.Repeat("ab", 32), Size: 1})
+	require.ErrorIs(t, err, storage.ErrEntityNotFound)
+
+	//nolint:exhaustruct // изменение прошло проверку, Invalid не нужен
+	results, err := stor.ApplyChanges(ctx, ownerID, []*entity.Change{{Item: &revived, Op: entity.ChangeUpsert}})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, entity.ChangeRejected, results[0].Status)
+
+	require.NoError(t, stor.DeleteItem(ctx, ownerID, item.ID), "expired item is already deleted")
+
+	trash, err := stor.ListTrash(ctx, ownerID)
+	require.NoError(t, err)
+	assert.Empty(t, trash, "expired item does not move to the trash")
+
+	_, err = stor.GetItem(ctx, ownerID, item.ID)
+	require.ErrorIs(t, err, storage.ErrEntityNotFound)
+
+	_, err = stor.ExpireItems(ctx, time.Now())
+	require.NoError(t, err)
+
+	_, err = stor.UpsertItem(ctx, &revived)
+	require.NoError(t, err, "reaped item ID can be reused")
+
+	got, err := stor.GetItem(ctx, ownerID, item.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.ExpiresAt)
+	assert.True(t, future.Equal(*got.ExpiresAt))
+}
+
+func testExpiredUsage(t *testing.T, stor Storage) {
+	t.Helper()
+
+	ctx := context.Background()
+	ownerID := newOwner()
+	createItem(t, stor, ownerID, "Bank")
+
+	// Размер записи в хранилище зависит от реализации (например, от шифрования),
+	// поэтому ожидаемый объём берётся до добавления записи с истёкшим сроком.
+	want, err := stor.GetUsage(ctx, ownerID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), want.Items)
+
+	past := time.Now().UTC().Add(-time.Minute)
+	expired := newItem(ownerID, "OTP")
+	expired.ExpiresAt = &past
+
+	_, err = stor.CreateItem(ctx, expired)
+	require.NoError(t, err)
+
+	usage, err := stor.GetUsage(ctx, ownerID)
+	require.NoError(t, err)
+	assert.Equal(t, want, usage, "expired item is not counted before it is reaped")
+
+	_, err = stor.ExpireItems(ctx, time.Now())
+	require.NoError(t, err)
+
+	usage, err = stor.GetUsage(ctx, ownerID)
+	require.NoError(t, err)
+	assert.Equal(t, want, usage)
+}
+
+/*
+	===== Tags =====
+*/
+
+func testTags(t *testing.T, stor Storage) {
+	t.Helper()
+
+	ctx := context.Background()
+	ownerID := newOwner()
+
+	tags, err := stor.ListTags(ctx, ownerID)
+	require.NoError(t, err)
+	assert.Empty(t, tags)
+
+	for _, itemTags := range [][]string{{"finance", "personal"}, {"finance"}, {"Work"}, nil} {
+		item := newItem(ownerID, "Bank")
+		item.Tags = itemTags
+
+		_, err = stor.CreateItem(ctx, item)
+		require.NoError(t, err)
+	}
+
+	past := time.Now().UTC().Add(-time.Minute)
+	expired := newItem(ownerID, "OTP")
+	expired.Tags = []string{"finance", "otp"}
+	expired.ExpiresAt = &past
+
+	_, err = stor.CreateItem(ctx, expired)
+	require.NoError(t, err)
+
+	other := newItem(newOwner(), "Other")
+	other.Tags = []string{"finance"}
+
+	_, err = stor.CreateItem(ctx, other)
+	require.NoError(t, err)
+
+	tags, err = stor.ListTags(ctx, ownerID)
+	require.NoError(t, err)
+	assert.Equal(t, []entity.TagCount{
+		{Name: "Work", Count: 1},
+		{Name: "finance", Count: 2},
+		{Name: "personal", Count: 1},
+	}, tags, "expired and foreign items are not counted")
+}
+
+/*
+	===== Owner isolation =====
+*/
+
+func testOwnerIsolation(t *testing.T, stor Storage) {
+	t.Helper()
+
+	ctx := context.Background()
+	owner := newOwner()
+	other := newOwner()
+	item := createItem(t, stor, owner, "Owner")
+
+	_, err := stor.GetItem(ctx, other, item.ID)
+	require.ErrorIs(t, err, storage.ErrEntityNotFound)
+
+	foreign := *item
+	foreign.OwnerID = other
+	require.ErrorIs(t, stor.UpdateItem(ctx, &foreign), storage.ErrEntityNotFound)
+	require.NoError(t, stor.DeleteItem(ctx, other, item.ID))
+
+	list, err := stor.ListItems(ctx, other)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+
+	changes, err := stor.ListChanges(ctx, other, 0, 0)
+	require.NoError(t, err)
+	assert.Empty(t, changes, "deleting a foreign item leaves no tombstone")
+
+	same := newItem(other, "Other")
+	same.ID = item.ID
+	_, err = stor.CreateItem(ctx, same)
+	require.NoError(t, err, "item IDs are scoped by owner")
+
+	got, err := stor.GetItem(ctx, owner, item.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Owner", got.Title)
+	assert.Equal(t, item.Version, got.Version)
+
+	changes, err = stor.ListChanges(ctx, owner, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, item.Seq, changes[0].Seq, "sequences are independent per owner")
+}
+
+/*
+	===== Concurrent access =====
+*/
+
+// parallel выполняет fn одновременно concurrency раз и возвращает ошибки вызовов.
+func parallel(fn func(i int) error) []error {
+	errs := make([]error, concurrency)
+
+	var wg sync.WaitGroup
+
+	for i := range concurrency {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			errs[i] = fn(i)
+		}()
+	}
+
+	wg.Wait()
+
+	return errs
+}
+
+func testConcurrentCreate(t *testing.T, stor Storage) {
+	t.Helper()
+
+	ctx := context.Background()
+	ownerID := newOwner()
+
+	errs := parallel(func(int) error {
+		_, err := stor.CreateItem(ctx, newItem(ownerID, "Item"))
+
+		return err //nolint:wrapcheck // ошибка проверяется в тесте
+	})
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+
+	changes, err := stor.ListChanges(ctx, ownerID, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, changes, concurrency)
+
+	seqs := make(map[int64]bool, concurrency)
+	for _, change := range changes {
+		seqs[change.Seq] = true
+	}
+
+	assert.Len(t, seqs, concurrency, "every change gets a unique seq")
+}
+
+func testConcurrentUpdate(t *testing.T, stor Storage) {
+	t.Helper()
+
+	ctx := context.Background()
+	ownerID := newOwner()
+	item := createItem(t, stor, ownerID, "Item")
+
+	errs := parallel(func(int) error {
+		update := *item
+		update.Title = "Updated"
+
+		return stor.UpdateItem(ctx, &update) //nolint:wrapcheck // ошибка проверяется в тесте
+	})
+
+	applied := 0
+
+	for _, err := range errs {
+		if err == nil {
+			applied++
+
+			continue
+		}
+
+		require.ErrorIs(t, err, storage.ErrVersionConflict)
+	}
+
+	assert.Equal(t, 1, applied, "only one update of the same version wins")
+
+	got, err := stor.GetItem(ctx, ownerID, item.ID)
+	require.NoError(t, err)
+	assert.Equal(t, item.Version+1, got.Version)
+}
+
+func testConcurrentTokens(t *testing.T, stor Storage) {
+	t.Helper()
+
+	ctx := context.Background()
+	userIDs := make([]string, concurrency)
+
+	for i := range userIDs {
+		userIDs[i] = newOwner()
+	}
+
+	errs := parallel(func(i int) error {
+		if _, err := stor.AddNewUser(ctx, entity.NewUser(newEmail(), "hash")); err != nil {
+			return err //nolint:wrapcheck // ошибка проверяется в тесте
+		}
+
+		if _, err := stor.AddNewToken(ctx, userIDs[i], &entity.Token{}); err != nil {
+			return err //nolint:wrapcheck // ошибка проверяется в тесте
+		}
+
+		if !stor.IsTokenByUserID(ctx, userIDs[i]) {
+			return storage.ErrEntityNotFound
+		}
+
+		return stor.DeleteToken(ctx, userIDs[i]) //nolint:wrapcheck // ошибка проверяется в тесте
+	})
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+
+	for _, userID := range userIDs {
+		assert.False(t, stor.IsTokenByUserID(ctx, userID))
+	}
+}
+
+/*
+	===== Restore =====
+*/
+
+// RestoreFactory создаёт пустое хранилище для одного теста восстановления из снимка.
+type RestoreFactory func(t *testing.T) storage.IBackupStorage
+
+// RunRestore проверяет, что хранилище отказывается загружать снимок, если в нём
+// уже есть данные любого вида. Каждый тест получает от newStorage новое пустое хранилище.
+func RunRestore(t *testing.T, newStorage RestoreFactory) {
+	t.Helper()
+
+	userID := newOwner()
+	now := time.Now().UTC()
+
+	tests := []struct {
+		snap *entity.Snapshot
+		name string
+	}{
+		{
+			//nolint:exhaustruct // снимок содержит данные одного вида
+			snap: &entity.Snapshot{Users: []*entity.User{entity.NewUser(newEmail(), "hash")}},
+			name: "Users",
+		},
+		{
+			//nolint:exhaustruct // снимок содержит данные одного вида
+			snap: &entity.Snapshot{Tokens: []string{userID}},
+			name: "Tokens",
+		},
+		{
+			//nolint:exhaustruct // снимок содержит данные одного вида
+			snap: &entity.Snapshot{Keys: []*entity.DataKey{
+				{OwnerID: userID, MasterKeyID: "master", Wrapped: []byte("key"), CreatedAt: now},
+			}},
+			name: "Keys",
+		},
+		{
+			//nolint:exhaustruct // снимок содержит данные одного вида
+			snap: &entity.Snapshot{PublicKeys: []*entity.PublicKey{
+				{CreatedAt: now, UserID: userID, Algorithm: "x25519", Key: []byte("key")},
+			}},
+			name: "PublicKeys",
+		},
+		{
+			//nolint:exhaustruct // снимок содержит данные одного вида
+			snap: &entity.Snapshot{Shares: []*entity.Share{{
+				CreatedAt: now, OwnerID: userID, ItemID: newOwner(), RecipientID: newOwner(),
+				Permission: entity.ShareRead, WrappedKey: []byte("key"),
+			}}},
+			name: "Shares",
+		},
+		{
+			//nolint:exhaustruct // снимок содержит данные одного вида
+			snap: &entity.Snapshot{Orgs: []*entity.Organization{{CreatedAt: now, ID: newOwner(), Name: "Acme"}}},
+			name: "Orgs",
+		},
+		{
+			//nolint:exhaustruct // снимок содержит данные одного вида
+			snap: &entity.Snapshot{Members: []*entity.Member{{
+				CreatedAt: now, OrgID: newOwner(), UserID: userID,
+				Role: entity.OrgViewer, Status: entity.MemberActive, InvitedBy: "",
+			}}},
+			name: "Members",
+		},
+		{
+			//nolint:exhaustruct // снимок содержит данные одного вида
+			snap: &entity.Snapshot{Collections: []*entity.Collection{
+				{CreatedAt: now, ID: newOwner(), OrgID: newOwner(), Name: "Team"},
+			}},
+			name: "Collections",
+		},
+		{
+			//nolint:exhaustruct // снимок содержит данные одного вида
+			snap: &entity.Snapshot{Vaults: []*entity.VaultSnapshot{{
+				OwnerID: userID, Seq: 1, Items: nil, Tombstones: nil, Revisions: nil, Trash: nil,
+				Folders: []*entity.Folder{{
+					ID: newOwner(), OwnerID: userID, ParentID: "", Name: "Work",
+					Version: 1, UpdatedAt: now, Seq: 1, Deleted: false,
+				}},
+			}}},
+			name: "Folders",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			stor := newStorage(t)
+
+			require.NoError(t, stor.Restore(ctx, test.snap))
+
+			//nolint:exhaustruct // пустой снимок
+			err := stor.Restore(ctx, &entity.Snapshot{})
+			require.ErrorIs(t, err, storage.ErrStorageNotEmpty)
+		})
+	}
+}
@@ -0,0 +1,420 @@
+// Package upload предоставляет хранилище сессий возобновляемой загрузки содержимого.
+//
+// Содержимое передаётся частями с явным смещением. Уже принятые байты сохраняются
+// на диске, поэтому после обрыва соединения загрузка продолжается с последнего
+// сохранённого смещения. Сессия, в которую долго ничего не записывалось, удаляется.
+package upload
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	dirPerm  = 0o700   // права каталога сессий
+	filePerm = 0o600   // права файлов сессий
+	metaExt  = ".json" // расширение файла описания сессии
+	partExt  = ".part" // расширение файла принятого содержимого
+)
+
+var (
+	// ErrNotFound указывает на то, что сессия не найдена или истекла.
+	ErrNotFound = errors.New("upload not found")
+
+	// ErrOffsetMismatch указывает на расхождение смещения части с принятым размером.
+	ErrOffsetMismatch = errors.New("upload offset mismatch")
+
+	// ErrTooLarge указывает на то, что часть выходит за объявленный размер содержимого.
+	ErrTooLarge = errors.New("upload exceeds declared size")
+
+	// ErrIncomplete указывает на то, что содержимое принято не полностью.
+	ErrIncomplete = errors.New("upload incomplete")
+
+	// ErrBusy указывает на то, что с сессией уже выполняется другая операция.
+	ErrBusy = errors.New("upload busy")
+
+	// errCorrupt указывает на повреждённое описание сессии.
+	errCorrupt = errors.New("upload corrupted")
+)
+
+// Session описывает сессию загрузки содержимого записи.
+type Session struct {
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"` // время удаления сессии без новых записей
+	ID        string    `json:"id"`
+	OwnerID   string    `json:"ownerId"`
+	ItemID    string    `json:"itemId"`
+	Hash      string    `json:"hash,omitempty"` // ожидаемый хеш содержимого (пусто - без проверки)
+	Size      int64     `json:"size"`           // объявленный размер содержимого
+	Offset    int64     `json:"offset"`         // количество принятых байт
+}
+
+// Complete проверяет, принято ли содержимое полностью.
+func (s *Session) Complete() bool {
+	return s.Offset == s.Size
+}
+
+// Store описывает хранилище сессий загрузки в каталоге на локальном диске.
+//
+// Сессия хранится в двух файлах: <dir>/<id>.json с описанием и <id>.part
+// с принятым содержимым. Смещение сессии равно размеру файла содержимого,
+// а время последней записи - времени его изменения.
+type Store struct {
+	busy map[string]struct{} // сессии, с которыми выполняется операция
+	dir  string
+	ttl  time.Duration
+	mu   sync.Mutex
+}
+
+// NewStore создаёт и инициализирует новый экзепляр *Store.
+//
+// Параметры:
+//   - dir: каталог сессий (создаётся при отсутствии);
+//   - ttl: время после последней записи, через которое сессия истекает (0 - никогда).
+func NewStore(dir string, ttl time.Duration) (*Store, error) {
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return nil, fmt.Errorf("create upload dir: %w", err)
+	}
+
+	store := &Store{
+		busy: make(map[string]struct{}),
+		dir:  dir,
+		ttl:  ttl,
+		mu:   sync.Mutex{},
+	}
+
+	return store, nil
+}
+
+// Create создаёт пустую сессию загрузки содержимого записи.
+//
+// Параметры:
+//   - ownerID: идентификатор пользователя;
+//   - itemID: идентификатор записи;
+//   - size: объявленный размер содержимого;
+//   - hash: ожидаемый хеш содержимого (пусто - без проверки).
+func (s *Store) Create(ownerID, itemID string, size int64, hash string) (*Session, error) {
+	//nolint:exhaustruct // смещение и время истечения заполняются при чтении сессии
+	session := &Session{
+		CreatedAt: time.Now().UTC(),
+		ID:        uuid.New().String(),
+		OwnerID:   ownerID,
+		ItemID:    itemID,
+		Hash:      hash,
+		Size:      size,
+	}
+
+	// Сессия занята до записи описания, чтобы Expire не принял её за брошенную.
+	release, err := s.acquire(session.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	meta, err := json.Marshal(session)
+	if err != nil {
+		return nil, fmt.Errorf("encode upload: %w", err)
+	}
+
+	// Файл содержимого создаётся первым: описание без содержимого считается брошенным.
+	part, err := os.OpenFile(s.path(session.ID, partExt), os.O_CREATE|os.O_EXCL|os.O_WRONLY, filePerm)
+	if err != nil {
+		return nil, fmt.Errorf("create upload: %w", err)
+	}
+
+	if err := part.Close(); err != nil {
+		return nil, fmt.Errorf("create upload: %w", err)
+	}
+
+	if err := os.WriteFile(s.path(session.ID, metaExt), meta, filePerm); err != nil {
+		_ = os.Remove(s.path(session.ID, partExt))
+
+		return nil, fmt.Errorf("create upload: %w", err)
+	}
+
+	return s.load(ownerID, session.ID, time.Now())
+}
+
+// Get возвращает сессию пользователя.
+// Если сессии нет, она истекла или принадлежит другому пользователю, возвращается ErrNotFound.
+func (s *Store) Get(ownerID, id string) (*Session, error) {
+	return s.load(ownerID, id, time.Now())
+}
+
+// Append дописывает часть содержимого, начинающуюся со смещения offset.
+//
+// Если offset не равен количеству принятых байт, возвращается ErrOffsetMismatch.
+// При обрыве чтения src уже принятые байты сохраняются, а возвращаемая сессия
+// содержит новое смещение вместе с ошибкой.
+func (s *Store) Append(
+	ctx context.Context,
+	ownerID, id string,
+	offset int64,
+	src io.Reader,
+) (*Session, error) {
+	release, err := s.acquire(id)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	session, err := s.Get(ownerID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if offset != session.Offset {
+		return session, fmt.Errorf("%w: got %d, want %d", ErrOffsetMismatch, offset, session.Offset)
+	}
+
+	part, err := os.OpenFile(s.path(id, partExt), os.O_WRONLY|os.O_APPEND, filePerm)
+	if err != nil {
+		return nil, fmt.Errorf("open upload: %w", err)
+	}
+
+	defer func() { _ = part.Close() }()
+
+	remaining := session.Size - session.Offset
+
+	// Читается на байт больше, чтобы отличить выход за объявленный размер.
+	written, copyErr := io.Copy(part, io.LimitReader(src, remaining+1))
+
+	if written > remaining {
+		// Часть целиком отклоняется, чтобы не оставлять лишних байт.
+		if err := part.Truncate(session.Offset); err != nil {
+			return nil, fmt.Errorf("truncate upload: %w", err)
+		}
+
+		return session, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, remaining)
+	}
+
+	if err := part.Sync(); err != nil {
+		return nil, fmt.Errorf("sync upload: %w", err)
+	}
+
+	session.Offset += written
+	session.ExpiresAt = s.expiresAt(time.Now())
+
+	if copyErr != nil {
+		return session, fmt.Errorf("write upload: %w", copyErr)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return session, fmt.Errorf("write upload: %w", err)
+	}
+
+	return session, nil
+}
+
+// Finish передаёт полностью принятое содержимое в commit и удаляет сессию,
+// если commit завершился без ошибки. При ошибке сессия сохраняется для повтора.
+//
+// Если содержимое принято не полностью, возвращается ErrIncomplete.
+func (s *Store) Finish(
+	ownerID, id string,
+	commit func(session *Session, src io.Reader) error,
+) (*Session, error) {
+	release, err := s.acquire(id)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	session, err := s.Get(ownerID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !session.Complete() {
+		return session, fmt.Errorf("%w: %d of %d bytes", ErrIncomplete, session.Offset, session.Size)
+	}
+
+	part, err := os.Open(s.path(id, partExt))
+	if err != nil {
+		return nil, fmt.Errorf("open upload: %w", err)
+	}
+
+	defer func() { _ = part.Close() }()
+
+	if err := commit(session, part); err != nil {
+		return session, err
+	}
+
+	return session, s.remove(id)
+}
+
+// Remove удаляет сессию пользователя вместе с принятым содержимым.
+func (s *Store) Remove(ownerID, id string) error {
+	release, err := s.acquire(id)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if _, err := s.Get(ownerID, id); err != nil {
+		return err
+	}
+
+	return s.remove(id)
+}
+
+// Expire удаляет сессии, истёкшие к моменту now, и файлы брошенных сессий.
+// Возвращает количество удалённых сессий.
+func (s *Store) Expire(ctx context.Context, now time.Time) (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, fmt.Errorf("read upload dir: %w", err)
+	}
+
+	ids := make(map[string]struct{}, len(entries))
+
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if !entry.IsDir() && (ext == metaExt || ext == partExt) {
+			ids[strings.TrimSuffix(entry.Name(), ext)] = struct{}{}
+		}
+	}
+
+	count := 0
+
+	for id := range ids {
+		if err := ctx.Err(); err != nil {
+			return count, fmt.Errorf("expire uploads: %w", err)
+		}
+
+		expired, err := s.expireOne(id, now)
+		if err != nil {
+			return count, err
+		}
+
+		if expired {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// expireOne удаляет сессию, если она истекла, повреждена или её файлы неполны.
+func (s *Store) expireOne(id string, now time.Time) (bool, error) {
+	release, err := s.acquire(id)
+	if errors.Is(err, ErrBusy) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	_, err = s.load("", id, now)
+	if err == nil {
+		return false, nil
+	}
+
+	if !errors.Is(err, ErrNotFound) && !errors.Is(err, errCorrupt) {
+		return false, err
+	}
+
+	return true, s.remove(id)
+}
+
+// load читает сессию с диска. Пустой ownerID отключает проверку владельца.
+func (s *Store) load(ownerID, id string, now time.Time) (*Session, error) {
+	if uuid.Validate(id) != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	meta, err := os.ReadFile(s.path(id, metaExt))
+	if err != nil {
+		return nil, notFound(id, err)
+	}
+
+	var session Session
+	if err := json.Unmarshal(meta, &session); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", errCorrupt, id, err)
+	}
+
+	stat, err := os.Stat(s.path(id, partExt))
+	if err != nil {
+		return nil, notFound(id, err)
+	}
+
+	if ownerID != "" && session.OwnerID != ownerID {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	session.Offset = stat.Size()
+	session.ExpiresAt = s.expiresAt(stat.ModTime())
+
+	if !session.ExpiresAt.IsZero() && !now.Before(session.ExpiresAt) {
+		return nil, fmt.Errorf("%w: %s expired", ErrNotFound, id)
+	}
+
+	return &session, nil
+}
+
+// remove удаляет файлы сессии.
+func (s *Store) remove(id string) error {
+	for _, ext := range []string{metaExt, partExt} {
+		err := os.Remove(s.path(id, ext))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("remove upload: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// acquire отмечает сессию занятой до вызова возвращаемой функции.
+func (s *Store) acquire(id string) (func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.busy[id]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrBusy, id)
+	}
+
+	s.busy[id] = struct{}{}
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		delete(s.busy, id)
+	}, nil
+}
+
+// expiresAt возвращает время истечения сессии с последней записью в момент touched.
+func (s *Store) expiresAt(touched time.Time) time.Time {
+	if s.ttl <= 0 {
+		return time.Time{}
+	}
+
+	return touched.Add(s.ttl).UTC()
+}
+
+// path возвращает путь до файла сессии.
+func (s *Store) path(id, ext string) string {
+	return filepath.Join(s.dir, id+ext)
+}
+
+// notFound преобразует отсутствие файла сессии в ErrNotFound.
+func notFound(id string, err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	return fmt.Errorf("read upload %s: %w", id, err)
+}
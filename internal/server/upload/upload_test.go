@@ -0,0 +1,160 @@
+package upload_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/mr-filatik/go-goph-keeper/internal/server/upload"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errBroken = errors.New("connection reset")
+
+/*
+	===== Store =====
+*/
+
+func TestStore_AppendFinish(t *testing.T) {
+	t.Parallel()
+
+	store, err := upload.NewStore(t.TempDir(), time.Hour)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	session, err := store.Create("user-1", "item-1", 10, "")
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), session.Offset)
+	assert.Equal(t, int64(10), session.Size)
+	assert.False(t, session.ExpiresAt.IsZero())
+
+	session, err = store.Append(ctx, "user-1", session.ID, 0, strings.NewReader("01234"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), session.Offset)
+	assert.False(t, session.Complete())
+
+	_, err = store.Finish("user-1", session.ID, func(*upload.Session, io.Reader) error { return nil })
+	require.ErrorIs(t, err, upload.ErrIncomplete)
+
+	_, err = store.Append(ctx, "user-1", session.ID, 3, strings.NewReader("34"))
+	require.ErrorIs(t, err, upload.ErrOffsetMismatch)
+
+	_, err = store.Append(ctx, "user-1", session.ID, 5, strings.NewReader("5678901"))
+	require.ErrorIs(t, err, upload.ErrTooLarge)
+
+	got, err := store.Get("user-1", session.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), got.Offset, "rejected chunk is not kept")
+
+	session, err = store.Append(ctx, "user-1", session.ID, 5, strings.NewReader("56789"))
+	require.NoError(t, err)
+	assert.True(t, session.Complete())
+
+	commitErr := errors.New("commit failed")
+
+	_, err = store.Finish("user-1", session.ID, func(*upload.Session, io.Reader) error { return commitErr })
+	require.ErrorIs(t, err, commitErr)
+
+	var content []byte
+
+	_, err = store.Finish("user-1", session.ID, func(_ *upload.Session, src io.Reader) error {
+		content, err = io.ReadAll(src)
+
+		return err
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(content))
+
+	_, err = store.Get("user-1", session.ID)
+	require.ErrorIs(t, err, upload.ErrNotFound)
+}
+
+func TestStore_AppendInterrupted(t *testing.T) {
+	t.Parallel()
+
+	store, err := upload.NewStore(t.TempDir(), 0)
+	require.NoError(t, err)
+
+	session, err := store.Create("user-1", "item-1", 8, "")
+	require.NoError(t, err)
+	assert.True(t, session.ExpiresAt.IsZero())
+
+	broken := io.MultiReader(strings.NewReader("abc"), iotest.ErrReader(errBroken))
+
+	session, err = store.Append(context.Background(), "user-1", session.ID, 0, broken)
+	require.ErrorIs(t, err, errBroken)
+	assert.Equal(t, int64(3), session.Offset, "received bytes are kept")
+
+	session, err = store.Append(context.Background(), "user-1", session.ID, 3, bytes.NewReader([]byte("defgh")))
+	require.NoError(t, err)
+	assert.True(t, session.Complete())
+}
+
+func TestStore_Owner(t *testing.T) {
+	t.Parallel()
+
+	store, err := upload.NewStore(t.TempDir(), time.Hour)
+	require.NoError(t, err)
+
+	session, err := store.Create("user-1", "item-1", 1, "")
+	require.NoError(t, err)
+
+	_, err = store.Get("user-2", session.ID)
+	require.ErrorIs(t, err, upload.ErrNotFound)
+
+	_, err = store.Append(context.Background(), "user-2", session.ID, 0, strings.NewReader("x"))
+	require.ErrorIs(t, err, upload.ErrNotFound)
+
+	require.ErrorIs(t, store.Remove("user-2", session.ID), upload.ErrNotFound)
+	require.ErrorIs(t, store.Remove("user-1", "../escape"), upload.ErrNotFound)
+
+	require.NoError(t, store.Remove("user-1", session.ID))
+
+	_, err = store.Get("user-1", session.ID)
+	require.ErrorIs(t, err, upload.ErrNotFound)
+}
+
+func TestStore_Expire(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	store, err := upload.NewStore(dir, time.Hour)
+	require.NoError(t, err)
+
+	stale, err := store.Create("user-1", "item-1", 1, "")
+	require.NoError(t, err)
+
+	fresh, err := store.Create("user-1", "item-2", 1, "")
+	require.NoError(t, err)
+
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, stale.ID+".part"), old, old))
+
+	_, err = store.Get("user-1", stale.ID)
+	require.ErrorIs(t, err, upload.ErrNotFound, "expired session is not available")
+
+	// Описание без содержимого остаётся от прерванного создания сессии.
+	orphan := filepath.Join(dir, "00000000-0000-0000-0000-000000000000.json")
+	require.NoError(t, os.WriteFile(orphan, []byte("{}"), 0o600))
+
+	count, err := store.Expire(context.Background(), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	_, err = store.Get("user-1", fresh.ID)
+	require.NoError(t, err)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2, "only fresh session files remain")
+}
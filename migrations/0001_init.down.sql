@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS vault_items;
+DROP TABLE IF EXISTS tokens;
+DROP TABLE IF EXISTS users;
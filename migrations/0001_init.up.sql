@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS users (
+	id            TEXT PRIMARY KEY,
+	email         TEXT NOT NULL,
+	password_hash TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_lower_idx ON users (lower(email));
+
+CREATE TABLE IF NOT EXISTS tokens (
+	user_id    TEXT PRIMARY KEY,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS vault_items (
+	owner_id    TEXT NOT NULL,
+	id          TEXT NOT NULL,
+	type        TEXT NOT NULL,
+	title       TEXT NOT NULL,
+	description TEXT NOT NULL,
+	meta        JSONB,
+	username    TEXT NOT NULL,
+	data        TEXT NOT NULL,
+	version     BIGINT NOT NULL,
+	updated_at  TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (owner_id, id)
+);
+
+CREATE INDEX IF NOT EXISTS vault_items_owner_updated_idx ON vault_items (owner_id, updated_at);
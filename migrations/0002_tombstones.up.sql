@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS vault_tombstones (
+	owner_id   TEXT NOT NULL,
+	id         TEXT NOT NULL,
+	version    BIGINT NOT NULL,
+	deleted_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (owner_id, id)
+);
+
+CREATE INDEX IF NOT EXISTS vault_tombstones_owner_deleted_idx ON vault_tombstones (owner_id, deleted_at);
+CREATE INDEX IF NOT EXISTS vault_tombstones_deleted_idx ON vault_tombstones (deleted_at);
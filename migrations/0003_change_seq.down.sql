@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS vault_tombstones_owner_seq_idx;
+DROP INDEX IF EXISTS vault_items_owner_seq_idx;
+
+ALTER TABLE vault_tombstones DROP COLUMN IF EXISTS seq;
+ALTER TABLE vault_items DROP COLUMN IF EXISTS seq;
+
+DROP TABLE IF EXISTS vault_change_seq;
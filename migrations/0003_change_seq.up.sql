@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS vault_change_seq (
+	owner_id TEXT PRIMARY KEY,
+	seq      BIGINT NOT NULL
+);
+
+ALTER TABLE vault_items ADD COLUMN IF NOT EXISTS seq BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE vault_tombstones ADD COLUMN IF NOT EXISTS seq BIGINT NOT NULL DEFAULT 0;
+
+-- Нумерация существующих изменений в порядке их времени.
+CREATE TEMPORARY TABLE vault_change_backfill ON COMMIT DROP AS
+SELECT owner_id, id, deleted,
+	row_number() OVER (PARTITION BY owner_id ORDER BY changed_at, id) AS seq
+FROM (
+	SELECT owner_id, id, updated_at AS changed_at, false AS deleted FROM vault_items
+	UNION ALL
+	SELECT owner_id, id, deleted_at AS changed_at, true AS deleted FROM vault_tombstones
+) AS changes;
+
+UPDATE vault_items AS i SET seq = b.seq
+FROM vault_change_backfill AS b
+WHERE NOT b.deleted AND i.owner_id = b.owner_id AND i.id = b.id;
+
+UPDATE vault_tombstones AS t SET seq = b.seq
+FROM vault_change_backfill AS b
+WHERE b.deleted AND t.owner_id = b.owner_id AND t.id = b.id;
+
+INSERT INTO vault_change_seq (owner_id, seq)
+SELECT owner_id, max(seq) FROM vault_change_backfill GROUP BY owner_id;
+
+CREATE INDEX IF NOT EXISTS vault_items_owner_seq_idx ON vault_items (owner_id, seq);
+CREATE INDEX IF NOT EXISTS vault_tombstones_owner_seq_idx ON vault_tombstones (owner_id, seq);
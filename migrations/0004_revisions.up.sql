@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS vault_revisions (
+	owner_id    TEXT NOT NULL,
+	id          TEXT NOT NULL,
+	version     BIGINT NOT NULL,
+	type        TEXT NOT NULL,
+	title       TEXT NOT NULL,
+	description TEXT NOT NULL,
+	meta        JSONB,
+	username    TEXT NOT NULL,
+	data        TEXT NOT NULL,
+	updated_at  TIMESTAMPTZ NOT NULL,
+	created_at  TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (owner_id, id, version)
+);
+
+CREATE INDEX IF NOT EXISTS vault_revisions_created_idx ON vault_revisions (created_at);
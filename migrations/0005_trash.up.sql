@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS vault_trash (
+	owner_id    TEXT NOT NULL,
+	id          TEXT NOT NULL,
+	type        TEXT NOT NULL,
+	title       TEXT NOT NULL,
+	description TEXT NOT NULL,
+	meta        JSONB,
+	username    TEXT NOT NULL,
+	data        TEXT NOT NULL,
+	version     BIGINT NOT NULL,
+	updated_at  TIMESTAMPTZ NOT NULL,
+	deleted_at  TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (owner_id, id)
+);
+
+CREATE INDEX IF NOT EXISTS vault_trash_deleted_idx ON vault_trash (deleted_at);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS vault_items_meta_idx;
+DROP INDEX IF EXISTS vault_items_owner_title_prefix_idx;
+DROP INDEX IF EXISTS vault_items_owner_type_idx;
+
+CREATE INDEX IF NOT EXISTS vault_items_owner_updated_idx ON vault_items (owner_id, updated_at);
+
+DROP INDEX IF EXISTS vault_items_owner_updated_id_idx;
+DROP INDEX IF EXISTS vault_items_owner_title_idx;
@@ -0,0 +1,15 @@
+-- Индексы для выборки записей с фильтрами, сортировкой и постраничным выводом.
+CREATE INDEX IF NOT EXISTS vault_items_owner_title_idx
+	ON vault_items (owner_id, title COLLATE "C", id COLLATE "C");
+
+CREATE INDEX IF NOT EXISTS vault_items_owner_updated_id_idx
+	ON vault_items (owner_id, updated_at, id COLLATE "C");
+
+DROP INDEX IF EXISTS vault_items_owner_updated_idx;
+
+CREATE INDEX IF NOT EXISTS vault_items_owner_type_idx ON vault_items (owner_id, type);
+
+CREATE INDEX IF NOT EXISTS vault_items_owner_title_prefix_idx
+	ON vault_items (owner_id, lower(title) text_pattern_ops);
+
+CREATE INDEX IF NOT EXISTS vault_items_meta_idx ON vault_items USING GIN (meta);
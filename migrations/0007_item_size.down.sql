@@ -0,0 +1 @@
+ALTER TABLE vault_items DROP COLUMN IF EXISTS size;
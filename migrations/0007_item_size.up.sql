@@ -0,0 +1,10 @@
+-- Размер содержимого записи для квот пользователя (см. VaultItem.Size).
+ALTER TABLE vault_items ADD COLUMN IF NOT EXISTS size BIGINT NOT NULL DEFAULT 0;
+
+UPDATE vault_items SET size =
+	octet_length(type) + octet_length(title) + octet_length(description)
+	+ octet_length(username) + octet_length(data)
+	+ coalesce((
+		SELECT sum(octet_length(m.key) + octet_length(m.value))
+		FROM jsonb_each_text(CASE WHEN jsonb_typeof(meta) = 'object' THEN meta END) AS m
+	), 0);
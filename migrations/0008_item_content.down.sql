@@ -0,0 +1,8 @@
+ALTER TABLE vault_trash DROP COLUMN IF EXISTS content_size;
+ALTER TABLE vault_trash DROP COLUMN IF EXISTS content_hash;
+
+ALTER TABLE vault_revisions DROP COLUMN IF EXISTS content_size;
+ALTER TABLE vault_revisions DROP COLUMN IF EXISTS content_hash;
+
+ALTER TABLE vault_items DROP COLUMN IF EXISTS content_size;
+ALTER TABLE vault_items DROP COLUMN IF EXISTS content_hash;
@@ -0,0 +1,9 @@
+-- Ссылка на содержимое бинарной записи в хранилище содержимого (SHA-256, hex).
+ALTER TABLE vault_items ADD COLUMN IF NOT EXISTS content_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE vault_items ADD COLUMN IF NOT EXISTS content_size BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE vault_revisions ADD COLUMN IF NOT EXISTS content_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE vault_revisions ADD COLUMN IF NOT EXISTS content_size BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE vault_trash ADD COLUMN IF NOT EXISTS content_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE vault_trash ADD COLUMN IF NOT EXISTS content_size BIGINT NOT NULL DEFAULT 0;
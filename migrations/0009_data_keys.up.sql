@@ -0,0 +1,7 @@
+-- Ключи данных пользователей, зашифрованные мастер-ключом сервера.
+CREATE TABLE IF NOT EXISTS data_keys (
+	owner_id      TEXT PRIMARY KEY,
+	master_key_id TEXT NOT NULL,
+	wrapped       BYTEA NOT NULL,
+	created_at    TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,20 @@
+-- Открытые ключи пользователей для передачи доступа к записям.
+CREATE TABLE IF NOT EXISTS public_keys (
+	user_id    TEXT PRIMARY KEY,
+	algorithm  TEXT NOT NULL,
+	key        BYTEA NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+-- Доступы к записям с ключом записи, зашифрованным открытым ключом получателя.
+CREATE TABLE IF NOT EXISTS item_shares (
+	owner_id     TEXT NOT NULL,
+	item_id      TEXT NOT NULL,
+	recipient_id TEXT NOT NULL,
+	permission   TEXT NOT NULL,
+	wrapped_key  BYTEA NOT NULL,
+	created_at   TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (owner_id, item_id, recipient_id)
+);
+
+CREATE INDEX IF NOT EXISTS item_shares_recipient_idx ON item_shares (recipient_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS org_collections;
+DROP TABLE IF EXISTS org_members;
+DROP TABLE IF EXISTS organizations;
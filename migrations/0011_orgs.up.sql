@@ -0,0 +1,29 @@
+-- Организации с общими коллекциями записей.
+CREATE TABLE IF NOT EXISTS organizations (
+	id         TEXT PRIMARY KEY,
+	name       TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+-- Участники и приглашённые организаций.
+CREATE TABLE IF NOT EXISTS org_members (
+	org_id     TEXT NOT NULL,
+	user_id    TEXT NOT NULL,
+	role       TEXT NOT NULL,
+	status     TEXT NOT NULL,
+	invited_by TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL,
+	PRIMARY KEY (org_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS org_members_user_idx ON org_members (user_id);
+
+-- Коллекции организаций: записи коллекции хранятся с owner_id, равным id коллекции.
+CREATE TABLE IF NOT EXISTS org_collections (
+	id         TEXT PRIMARY KEY,
+	org_id     TEXT NOT NULL,
+	name       TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS org_collections_org_idx ON org_collections (org_id);
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS vault_items_owner_folder_idx;
+
+ALTER TABLE vault_trash DROP COLUMN IF EXISTS folder_id;
+ALTER TABLE vault_revisions DROP COLUMN IF EXISTS folder_id;
+ALTER TABLE vault_items DROP COLUMN IF EXISTS folder_id;
+
+DROP TABLE IF EXISTS vault_folders;
@@ -0,0 +1,22 @@
+-- Папки записей. Удалённые папки остаются строками с deleted = true,
+-- чтобы синхронизация сообщила об удалении другим устройствам.
+CREATE TABLE IF NOT EXISTS vault_folders (
+	owner_id   TEXT NOT NULL,
+	id         TEXT NOT NULL,
+	parent_id  TEXT NOT NULL,
+	name       TEXT NOT NULL,
+	version    BIGINT NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL,
+	seq        BIGINT NOT NULL,
+	deleted    BOOLEAN NOT NULL,
+	PRIMARY KEY (owner_id, id)
+);
+
+CREATE INDEX IF NOT EXISTS vault_folders_owner_seq_idx ON vault_folders (owner_id, seq);
+
+-- Папка записи (пусто - вне папок).
+ALTER TABLE vault_items ADD COLUMN IF NOT EXISTS folder_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE vault_revisions ADD COLUMN IF NOT EXISTS folder_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE vault_trash ADD COLUMN IF NOT EXISTS folder_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS vault_items_owner_folder_idx ON vault_items (owner_id, folder_id);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS vault_items_tags_idx;
+
+ALTER TABLE vault_trash DROP COLUMN IF EXISTS tags;
+ALTER TABLE vault_revisions DROP COLUMN IF EXISTS tags;
+ALTER TABLE vault_items DROP COLUMN IF EXISTS tags;
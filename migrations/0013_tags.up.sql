@@ -0,0 +1,6 @@
+-- Метки записи (NULL - без меток).
+ALTER TABLE vault_items ADD COLUMN IF NOT EXISTS tags TEXT[];
+ALTER TABLE vault_revisions ADD COLUMN IF NOT EXISTS tags TEXT[];
+ALTER TABLE vault_trash ADD COLUMN IF NOT EXISTS tags TEXT[];
+
+CREATE INDEX IF NOT EXISTS vault_items_tags_idx ON vault_items USING GIN (tags);
@@ -0,0 +1,3 @@
+ALTER TABLE vault_trash DROP COLUMN IF EXISTS attachments;
+ALTER TABLE vault_revisions DROP COLUMN IF EXISTS attachments;
+ALTER TABLE vault_items DROP COLUMN IF EXISTS attachments;
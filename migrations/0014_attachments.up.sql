@@ -0,0 +1,4 @@
+-- Вложения записи: JSON-массив с именем, типом, хешем и размером содержимого (NULL - без вложений).
+ALTER TABLE vault_items ADD COLUMN IF NOT EXISTS attachments JSONB;
+ALTER TABLE vault_revisions ADD COLUMN IF NOT EXISTS attachments JSONB;
+ALTER TABLE vault_trash ADD COLUMN IF NOT EXISTS attachments JSONB;
@@ -0,0 +1,8 @@
+ALTER TABLE vault_tombstones DROP COLUMN IF EXISTS expired;
+
+DROP INDEX IF EXISTS vault_trash_expires_at_idx;
+DROP INDEX IF EXISTS vault_items_expires_at_idx;
+
+ALTER TABLE vault_trash DROP COLUMN IF EXISTS expires_at;
+ALTER TABLE vault_revisions DROP COLUMN IF EXISTS expires_at;
+ALTER TABLE vault_items DROP COLUMN IF EXISTS expires_at;
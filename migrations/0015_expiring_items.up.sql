@@ -0,0 +1,10 @@
+-- Срок записи (NULL - бессрочная). Записи с истёкшим сроком удаляет фоновая задача.
+ALTER TABLE vault_items ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+ALTER TABLE vault_revisions ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+ALTER TABLE vault_trash ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS vault_items_expires_at_idx ON vault_items (expires_at) WHERE expires_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS vault_trash_expires_at_idx ON vault_trash (expires_at) WHERE expires_at IS NOT NULL;
+
+-- Отметка об удалении записи по истечении срока.
+ALTER TABLE vault_tombstones ADD COLUMN IF NOT EXISTS expired BOOLEAN NOT NULL DEFAULT false;
@@ -0,0 +1 @@
+UPDATE vault_items SET size = size - content_size WHERE content_size > 0;
@@ -0,0 +1,2 @@
+-- Размер записи для квот пользователя учитывает содержимое бинарной записи (см. VaultItem.UsageSize).
+UPDATE vault_items SET size = size + content_size WHERE content_size > 0;